@@ -19,8 +19,13 @@ package abi
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 // The ABI holds information about a contract's context and available
@@ -74,6 +79,10 @@ func (abi ABI) Pack(name string, args ...interface{}) ([]byte, error) {
 // Unpack output in v according to the abi specification
 func (abi ABI) Unpack(v interface{}, name string, output []byte) (err error) {
 	if len(output) == 0 {
+		// Calls to methods without return values legitimately produce no output
+		if method, ok := abi.Methods[name]; ok && len(method.Outputs) == 0 {
+			return nil
+		}
 		return fmt.Errorf("abi: unmarshalling empty output")
 	}
 	// since there can't be naming collisions with contracts and events,
@@ -89,15 +98,80 @@ func (abi ABI) Unpack(v interface{}, name string, output []byte) (err error) {
 	return fmt.Errorf("abi: could not locate named method or event")
 }
 
+// UnpackLog unpacks a log emitted by the named event into the struct pointed to
+// by out, decoding both the non-indexed data and the indexed topics. Unnamed
+// indexed arguments are stored in fields named after their position (Arg0...).
+func (abi ABI) UnpackLog(out interface{}, event string, log types.Log) error {
+	indexed, topics, err := abi.logTopics(event, log)
+	if err != nil {
+		return err
+	}
+	if len(log.Data) > 0 {
+		if err := abi.Unpack(out, event, log.Data); err != nil {
+			return err
+		}
+	}
+	return ParseTopics(out, indexed, topics)
+}
+
+// UnpackLogIntoMap unpacks a log emitted by the named event into a map keyed by
+// the names of the event arguments.
+func (abi ABI) UnpackLogIntoMap(out map[string]interface{}, event string, log types.Log) error {
+	indexed, topics, err := abi.logTopics(event, log)
+	if err != nil {
+		return err
+	}
+	if len(log.Data) > 0 {
+		values, err := abi.Events[event].Inputs.UnpackValues(log.Data)
+		if err != nil {
+			return err
+		}
+		for i, arg := range abi.Events[event].Inputs.NonIndexed() {
+			out[arg.Name] = values[i]
+		}
+	}
+	return ParseTopicsIntoMap(out, indexed, topics)
+}
+
+// logTopics returns the indexed arguments of the named event together with the
+// log topics holding their values, verifying the event signature on the way.
+func (abi ABI) logTopics(event string, log types.Log) (Arguments, []common.Hash, error) {
+	ev, ok := abi.Events[event]
+	if !ok {
+		return nil, nil, fmt.Errorf("abi: could not locate event %q", event)
+	}
+	topics := log.Topics
+	if !ev.Anonymous {
+		if len(topics) == 0 {
+			return nil, nil, errors.New("abi: missing event signature topic")
+		}
+		if topics[0] != ev.Id() {
+			return nil, nil, fmt.Errorf("abi: event signature mismatch: have %x, want %x", topics[0], ev.Id())
+		}
+		topics = topics[1:]
+	}
+	var indexed Arguments
+	for i, arg := range ev.Inputs {
+		if arg.Indexed {
+			if arg.Name == "" {
+				arg.Name = fmt.Sprintf("arg%d", i)
+			}
+			indexed = append(indexed, arg)
+		}
+	}
+	return indexed, topics, nil
+}
+
 // UnmarshalJSON implements json.Unmarshaler interface
 func (abi *ABI) UnmarshalJSON(data []byte) error {
 	var fields []struct {
-		Type      string
-		Name      string
-		Constant  bool
-		Anonymous bool
-		Inputs    []Argument
-		Outputs   []Argument
+		Type            string
+		Name            string
+		Constant        bool
+		StateMutability string
+		Anonymous       bool
+		Inputs          []Argument
+		Outputs         []Argument
 	}
 
 	if err := json.Unmarshal(data, &fields); err != nil {
@@ -114,9 +188,11 @@ func (abi *ABI) UnmarshalJSON(data []byte) error {
 			}
 		// empty defaults to function according to the abi spec
 		case "function", "":
+			// solc 0.6 and later only report the state mutability
+			isConst := field.Constant || field.StateMutability == "pure" || field.StateMutability == "view"
 			abi.Methods[field.Name] = Method{
 				Name:    field.Name,
-				Const:   field.Constant,
+				Const:   isConst,
 				Inputs:  field.Inputs,
 				Outputs: field.Outputs,
 			}
@@ -145,3 +221,24 @@ func (abi *ABI) MethodById(sigdata []byte) (*Method, error) {
 	}
 	return nil, fmt.Errorf("no method with id: %#x", sigdata[:4])
 }
+
+// revertSelector is the method id of the Error(string) function, which solidity
+// uses to abi-encode the reason passed to revert and require.
+var revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+
+// UnpackRevert resolves the abi-encoded revert reason returned by a reverted
+// call. The reason is encoded as if it were a call to `Error(string)`.
+func UnpackRevert(data []byte) (string, error) {
+	if len(data) < 4 || !bytes.Equal(data[:4], revertSelector) {
+		return "", errors.New("invalid data for unpacking")
+	}
+	typ, err := NewType("string", nil)
+	if err != nil {
+		return "", err
+	}
+	var reason string
+	if err := (Arguments{{Type: typ}}).Unpack(&reason, data[4:]); err != nil {
+		return "", err
+	}
+	return reason, nil
+}
@@ -19,6 +19,7 @@ package abi
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
@@ -99,6 +100,32 @@ func TestReader(t *testing.T) {
 	}
 }
 
+// Tests that methods are constant if either flagged so by older compilers or
+// declared pure or view in the state mutability of newer ones.
+func TestReaderStateMutability(t *testing.T) {
+	abi, err := JSON(strings.NewReader(`[
+		{ "type" : "function", "name" : "legacy", "constant" : true },
+		{ "type" : "function", "name" : "pure", "stateMutability" : "pure" },
+		{ "type" : "function", "name" : "view", "stateMutability" : "view" },
+		{ "type" : "function", "name" : "nonpayable", "stateMutability" : "nonpayable" },
+		{ "type" : "function", "name" : "payable", "stateMutability" : "payable" },
+		{ "type" : "receive", "stateMutability" : "payable" }
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"legacy": true, "pure": true, "view": true, "nonpayable": false, "payable": false} {
+		if method, ok := abi.Methods[name]; !ok {
+			t.Errorf("missing method %s", name)
+		} else if method.Const != want {
+			t.Errorf("method %s: constant %t, want %t", name, method.Const, want)
+		}
+	}
+	if len(abi.Methods) != 5 {
+		t.Errorf("%d methods parsed, want 5", len(abi.Methods))
+	}
+}
+
 func TestTestNumbers(t *testing.T) {
 	abi, err := JSON(strings.NewReader(jsondata2))
 	if err != nil {
@@ -745,3 +772,34 @@ func TestABI_MethodById(t *testing.T) {
 		t.Errorf("Expected error, nil is short to decode data")
 	}
 }
+
+func TestUnpackRevert(t *testing.T) {
+	t.Parallel()
+
+	var cases = []struct {
+		input     string
+		expect    string
+		expectErr error
+	}{
+		{"", "", errors.New("invalid data for unpacking")},
+		{"08c379a1", "", errors.New("invalid data for unpacking")},
+		{"08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000d72657665727420726561736f6e00000000000000000000000000000000000000", "revert reason", nil},
+	}
+	for index, c := range cases {
+		t.Run(fmt.Sprintf("case %d", index), func(t *testing.T) {
+			got, err := UnpackRevert(common.Hex2Bytes(c.input))
+			if c.expectErr != nil {
+				if err == nil {
+					t.Fatalf("Expected non-nil error")
+				}
+				if err.Error() != c.expectErr.Error() {
+					t.Fatalf("Expected error mismatch, want %v, got %v", c.expectErr, err)
+				}
+				return
+			}
+			if c.expect != got {
+				t.Fatalf("Output mismatch, want %v, got %v", c.expect, got)
+			}
+		})
+	}
+}
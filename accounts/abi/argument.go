@@ -34,10 +34,11 @@ type Argument struct {
 type Arguments []Argument
 
 type ArgumentMarshaling struct {
-	Name       string
-	Type       string
-	Components []ArgumentMarshaling
-	Indexed    bool
+	Name         string
+	Type         string
+	InternalType string
+	Components   []ArgumentMarshaling
+	Indexed      bool
 }
 
 // UnmarshalJSON implements json.Unmarshaler interface
@@ -48,7 +49,7 @@ func (argument *Argument) UnmarshalJSON(data []byte) error {
 		return fmt.Errorf("argument json err: %v", err)
 	}
 
-	argument.Type, err = NewType(arg.Type, arg.Components)
+	argument.Type, err = newType(arg.Type, arg.InternalType, arg.Components)
 	if err != nil {
 		return err
 	}
@@ -96,6 +97,9 @@ func (arguments Arguments) Unpack(v interface{}, data []byte) error {
 	if err != nil {
 		return err
 	}
+	if len(marshalledValues) == 0 {
+		return nil // Nothing to unpack, e.g. calls to methods without outputs
+	}
 	if arguments.isTuple() {
 		return arguments.unpackTuple(v, marshalledValues)
 	}
@@ -110,9 +114,19 @@ func unpack(t *Type, dst interface{}, src interface{}) error {
 		srcVal = reflect.ValueOf(src)
 	)
 
-	if t.T != TupleTy && !((t.T == SliceTy || t.T == ArrayTy) && t.Elem.T == TupleTy) {
+	if !containsTuple(t) {
 		return set(dstVal, srcVal)
 	}
+	// Tuples are unpacked field by field, resolve the actual destination first
+	for dstVal.Kind() == reflect.Interface || dstVal.Kind() == reflect.Ptr {
+		if dstVal.Kind() == reflect.Ptr && dstVal.IsNil() {
+			if !dstVal.CanSet() {
+				return fmt.Errorf("abi: cannot unmarshal %v in to nil %v", srcVal.Type(), dstVal.Type())
+			}
+			dstVal.Set(reflect.New(dstVal.Type().Elem()))
+		}
+		dstVal = dstVal.Elem()
+	}
 
 	switch t.T {
 	case TupleTy:
@@ -160,6 +174,18 @@ func unpack(t *Type, dst interface{}, src interface{}) error {
 	return nil
 }
 
+// containsTuple returns whether the type is a tuple or an, arbitrarily nested,
+// array or slice of tuples, which need to be unpacked field by field.
+func containsTuple(t *Type) bool {
+	switch t.T {
+	case TupleTy:
+		return true
+	case SliceTy, ArrayTy:
+		return containsTuple(t.Elem)
+	}
+	return false
+}
+
 // unpackAtomic unpacks ( hexdata -> go ) a single value
 func (arguments Arguments) unpackAtomic(v interface{}, marshalledValues interface{}) error {
 	if arguments.LengthNonIndexed() == 0 {
@@ -174,10 +200,13 @@ func (arguments Arguments) unpackAtomic(v interface{}, marshalledValues interfac
 			return err
 		}
 		field := elem.FieldByName(fieldmap[argument.Name])
-		if !field.IsValid() {
+		if field.IsValid() && (argument.Type.T != TupleTy || field.Kind() == reflect.Struct) {
+			return unpack(&argument.Type, field.Addr().Interface(), marshalledValues)
+		}
+		// A single tuple may be unpacked directly into a struct representing it
+		if argument.Type.T != TupleTy {
 			return fmt.Errorf("abi: field %s can't be found in the given value", argument.Name)
 		}
-		return unpack(&argument.Type, field.Addr().Interface(), marshalledValues)
 	}
 	return unpack(&argument.Type, elem.Addr().Interface(), marshalledValues)
 }
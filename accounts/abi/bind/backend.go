@@ -99,6 +99,21 @@ type ContractFilterer interface {
 }
 
 // DeployBackend wraps the operations needed by WaitMined and WaitDeployed.
+// LogWatcherBackend defines the methods needed to stream contract logs with a
+// LogWatcher: log filtering along with header retrieval for detecting reorgs
+// that happened while the watcher was not running.
+type LogWatcherBackend interface {
+	ContractFilterer
+
+	// HeaderByNumber returns the canonical header with the given number, or the
+	// current head if number is nil.
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+
+	// HeaderByHash returns the header with the given hash, even if it's been
+	// reorged out of the canonical chain.
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+}
+
 type DeployBackend interface {
 	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
 	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
@@ -0,0 +1,306 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// ForkSource is the remote chain a forked SimulatedBackend loads its state from.
+// It is implemented by ethclient.Client, but can be replaced by a local stub.
+type ForkSource interface {
+	// HeaderByNumber returns the header of the block to fork from.
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+
+	// BalanceAt, NonceAt, CodeAt and StorageAt return the state of an account at
+	// the forked block.
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+	StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error)
+}
+
+var (
+	// forkTombstone is stored in the local tries in place of deleted accounts and
+	// storage slots, so they are not loaded from the remote chain again. It is an
+	// empty RLP string, which is never a valid encoding of either.
+	forkTombstone = []byte{0x80}
+
+	// forkStorageRoot is the storage root of accounts loaded from the remote chain
+	// without any local storage modifications.
+	forkStorageRoot = crypto.Keccak256Hash([]byte("simulated backend fork storage"))
+)
+
+// forkDatabase is a state.Database keeping the modified state locally, but
+// lazily loading accounts, code and storage slots never touched locally from
+// a remote chain at a fixed block.
+type forkDatabase struct {
+	state.Database // Local database storing all modified state
+
+	source ForkSource
+	number *big.Int
+
+	accounts map[common.Address][]byte                 // Remote accounts loaded so far, nil if missing
+	owners   map[common.Hash]common.Address            // Address preimages of the loaded accounts
+	code     map[common.Hash][]byte                    // Remote contract code loaded so far
+	storage  map[common.Address]map[common.Hash][]byte // Remote storage slots loaded so far
+	roots    map[forkRoot]struct{}                     // Storage roots backed by remote storage
+	lock     sync.Mutex
+}
+
+// forkRoot identifies the storage trie of an account.
+type forkRoot struct {
+	owner common.Hash
+	root  common.Hash
+}
+
+// newForkDatabase creates a state database on top of the local one, loading any
+// state it doesn't have from the source at the given block number.
+func newForkDatabase(local state.Database, source ForkSource, number *big.Int) *forkDatabase {
+	return &forkDatabase{
+		Database: local,
+		source:   source,
+		number:   number,
+		accounts: make(map[common.Address][]byte),
+		owners:   make(map[common.Hash]common.Address),
+		code:     make(map[common.Hash][]byte),
+		storage:  make(map[common.Address]map[common.Hash][]byte),
+		roots:    make(map[forkRoot]struct{}),
+	}
+}
+
+// OpenTrie opens the main account trie, falling back to the remote chain for
+// accounts not present locally.
+func (db *forkDatabase) OpenTrie(root common.Hash) (state.Trie, error) {
+	tr, err := db.Database.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	return &forkTrie{Trie: tr, db: db, remote: db.account}, nil
+}
+
+// OpenStorageTrie opens the storage trie of an account. Accounts loaded from the
+// remote chain fall back to its storage for slots not present locally, whereas
+// accounts created locally have no remote storage.
+func (db *forkDatabase) OpenStorageTrie(addrHash, root common.Hash) (state.Trie, error) {
+	db.lock.Lock()
+	address, known := db.owners[addrHash]
+	_, remote := db.roots[forkRoot{addrHash, root}]
+	db.lock.Unlock()
+
+	if root == forkStorageRoot {
+		remote, root = true, common.Hash{}
+	}
+	tr, err := db.Database.OpenStorageTrie(addrHash, root)
+	if err != nil {
+		return nil, err
+	}
+	if !remote || !known {
+		return tr, nil
+	}
+	return &forkTrie{
+		Trie:  tr,
+		db:    db,
+		owner: addrHash,
+		remote: func(key []byte) ([]byte, error) {
+			return db.slot(address, common.BytesToHash(key))
+		},
+	}, nil
+}
+
+// CopyTrie returns an independent copy of the given trie.
+func (db *forkDatabase) CopyTrie(t state.Trie) state.Trie {
+	if tr, ok := t.(*forkTrie); ok {
+		cpy := *tr
+		cpy.Trie = db.Database.CopyTrie(tr.Trie)
+		return &cpy
+	}
+	return db.Database.CopyTrie(t)
+}
+
+// ContractCode retrieves a particular contract's code, either from the local
+// database or from the remote accounts loaded so far.
+func (db *forkDatabase) ContractCode(addrHash, codeHash common.Hash) ([]byte, error) {
+	db.lock.Lock()
+	code, ok := db.code[codeHash]
+	db.lock.Unlock()
+
+	if ok {
+		return code, nil
+	}
+	return db.Database.ContractCode(addrHash, codeHash)
+}
+
+// ContractCodeSize retrieves a particular contract's code size.
+func (db *forkDatabase) ContractCodeSize(addrHash, codeHash common.Hash) (int, error) {
+	code, err := db.ContractCode(addrHash, codeHash)
+	return len(code), err
+}
+
+// account returns the RLP encoded state of a remote account, loading it from
+// the source on first access.
+func (db *forkDatabase) account(key []byte) ([]byte, error) {
+	address := common.BytesToAddress(key)
+
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if blob, ok := db.accounts[address]; ok {
+		return blob, nil
+	}
+	ctx := context.Background()
+	balance, err := db.source.BalanceAt(ctx, address, db.number)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := db.source.NonceAt(ctx, address, db.number)
+	if err != nil {
+		return nil, err
+	}
+	code, err := db.source.CodeAt(ctx, address, db.number)
+	if err != nil {
+		return nil, err
+	}
+	var blob []byte
+	if nonce != 0 || balance.Sign() != 0 || len(code) != 0 {
+		codeHash := crypto.Keccak256Hash(code)
+		blob, err = rlp.EncodeToBytes(&state.Account{
+			Nonce:    nonce,
+			Balance:  balance,
+			Root:     forkStorageRoot,
+			CodeHash: codeHash[:],
+		})
+		if err != nil {
+			return nil, err
+		}
+		db.code[codeHash] = code
+		db.owners[crypto.Keccak256Hash(address[:])] = address
+	}
+	db.accounts[address] = blob
+	return blob, nil
+}
+
+// slot returns the RLP encoded value of a remote storage slot, loading it from
+// the source on first access.
+func (db *forkDatabase) slot(address common.Address, key common.Hash) ([]byte, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if value, ok := db.storage[address][key]; ok {
+		return value, nil
+	}
+	value, err := db.source.StorageAt(context.Background(), address, key, db.number)
+	if err != nil {
+		return nil, err
+	}
+	var blob []byte
+	if value = bytes.TrimLeft(value, "\x00"); len(value) > 0 {
+		if blob, err = rlp.EncodeToBytes(value); err != nil {
+			return nil, err
+		}
+	}
+	if db.storage[address] == nil {
+		db.storage[address] = make(map[common.Hash][]byte)
+	}
+	db.storage[address][key] = blob
+	return blob, nil
+}
+
+// forkTrie is a local trie falling back to remote state for keys it never stored.
+// Deletions are recorded as tombstones to avoid loading deleted keys again.
+type forkTrie struct {
+	state.Trie
+
+	db     *forkDatabase
+	owner  common.Hash                      // Account hash of storage tries, zero for the account trie
+	remote func(key []byte) ([]byte, error) // Loader of the remote value of a key
+}
+
+// TryGet returns the value of a key, loading it from the remote chain if it
+// was never stored locally.
+func (t *forkTrie) TryGet(key []byte) ([]byte, error) {
+	value, err := t.Trie.TryGet(key)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.Equal(value, forkTombstone):
+		return nil, nil
+	case len(value) > 0:
+		return value, nil
+	default:
+		return t.remote(key)
+	}
+}
+
+// TryUpdate stores a value locally, shadowing the remote one.
+func (t *forkTrie) TryUpdate(key, value []byte) error {
+	if len(value) == 0 {
+		return t.TryDelete(key)
+	}
+	return t.Trie.TryUpdate(key, value)
+}
+
+// TryDelete deletes a key by storing a tombstone, shadowing the remote value.
+func (t *forkTrie) TryDelete(key []byte) error {
+	return t.Trie.TryUpdate(key, forkTombstone)
+}
+
+// Hash returns the root hash of the local trie. For storage tries, the returned
+// root is remembered as one backed by remote storage.
+func (t *forkTrie) Hash() common.Hash {
+	return t.track(t.Trie.Hash())
+}
+
+// Commit writes the local trie to the database, returning its root hash.
+func (t *forkTrie) Commit(onleaf trie.LeafCallback) (common.Hash, error) {
+	root, err := t.Trie.Commit(onleaf)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return t.track(root), nil
+}
+
+// track records the root of a storage trie backed by remote storage, so that it
+// falls back to the remote chain when opened again. Empty storage tries are
+// reported with a placeholder root, as the empty root denotes local accounts.
+func (t *forkTrie) track(root common.Hash) common.Hash {
+	if t.owner == (common.Hash{}) {
+		return root
+	}
+	if root == emptyRoot {
+		return forkStorageRoot
+	}
+	t.db.lock.Lock()
+	t.db.roots[forkRoot{t.owner, root}] = struct{}{}
+	t.db.lock.Unlock()
+
+	return root
+}
+
+// emptyRoot is the known root hash of an empty trie.
+var emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
@@ -42,8 +42,10 @@ import (
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
-// This nil assignment ensures compile time that SimulatedBackend implements bind.ContractBackend.
+// These nil assignments ensure compile time that SimulatedBackend implements
+// bind.ContractBackend and bind.LogWatcherBackend.
 var _ bind.ContractBackend = (*SimulatedBackend)(nil)
+var _ bind.LogWatcherBackend = (*SimulatedBackend)(nil)
 
 var errBlockNumberUnsupported = errors.New("SimulatedBackend cannot access blocks other than the latest block")
 var errGasEstimationFailed = errors.New("gas required exceeds allowance or always failing transaction")
@@ -57,28 +59,78 @@ type SimulatedBackend struct {
 	mu           sync.Mutex
 	pendingBlock *types.Block   // Currently pending block that will be imported on request
 	pendingState *state.StateDB // Currently pending state that will be the active on on request
+	pendingTime  int64          // Time shift of the pending block in seconds, set by AdjustTime
 
-	events *filters.EventSystem // Event system for filtering log events live
+	snapshots    []*simSnapshot // Snapshots that can be reverted to, in creation order
+	lastSnapshot int            // Identifier of the last snapshot taken
+
+	events     *filters.EventSystem // Event system for filtering log events live
+	rmLogsFeed event.Feed           // Feed of logs removed by reverting to snapshots
 
 	config *params.ChainConfig
 }
 
+// simSnapshot is a point in the simulated chain that can be reverted to.
+type simSnapshot struct {
+	id   int
+	head *types.Block       // Head block of the chain when the snapshot was taken
+	txs  types.Transactions // Transactions pending when the snapshot was taken
+	time int64              // Time shift of the pending block
+}
+
 // NewSimulatedBackend creates a new binding backend using a simulated blockchain
 // for testing purposes.
 func NewSimulatedBackend(alloc core.GenesisAlloc, gasLimit uint64) *SimulatedBackend {
 	database := ethdb.NewMemDatabase()
 	genesis := core.Genesis{Config: params.AllEthashProtocolChanges, GasLimit: gasLimit, Alloc: alloc}
 	genesis.MustCommit(database)
-	blockchain, _ := core.NewBlockChain(database, nil, genesis.Config, ethash.NewFaker(), vm.Config{}, nil)
 
+	backend, _ := newSimulatedBackend(database, nil, genesis.Config)
+	return backend
+}
+
+// NewForkedBackend creates a new binding backend using a simulated blockchain
+// forked off the state of a remote chain at the given block, or the latest one
+// if blockNumber is nil. Accounts, code and storage are loaded lazily from the
+// source the first time they are accessed and all changes are kept locally.
+//
+// The accounts in alloc replace the remote ones entirely. A gas limit of zero
+// uses the gas limit of the forked block. Note, the simulated chain starts from
+// a new genesis block with the timestamp of the forked one, so block numbers and
+// hashes are local to the simulation.
+func NewForkedBackend(source ForkSource, blockNumber *big.Int, alloc core.GenesisAlloc, gasLimit uint64) (*SimulatedBackend, error) {
+	header, err := source.HeaderByNumber(context.Background(), blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if gasLimit == 0 {
+		gasLimit = header.GasLimit
+	}
+	database := ethdb.NewMemDatabase()
+	genesis := core.Genesis{Config: params.AllEthashProtocolChanges, Timestamp: header.Time.Uint64(), GasLimit: gasLimit, Alloc: alloc}
+	genesis.MustCommit(database)
+
+	return newSimulatedBackend(database, newForkDatabase(state.NewDatabase(database), source, header.Number), genesis.Config)
+}
+
+// newSimulatedBackend creates a simulated backend on top of a database holding
+// the genesis block, accessing state through the given state database if set.
+func newSimulatedBackend(database ethdb.Database, stateDb state.Database, config *params.ChainConfig) (*SimulatedBackend, error) {
+	// Keep the state of all blocks around, so snapshots can be reverted to
+	cacheConfig := &core.CacheConfig{Disabled: true}
+
+	blockchain, err := core.NewBlockChainWithState(database, stateDb, cacheConfig, config, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		return nil, err
+	}
 	backend := &SimulatedBackend{
 		database:   database,
 		blockchain: blockchain,
-		config:     genesis.Config,
-		events:     filters.NewEventSystem(new(event.TypeMux), &filterBackend{database, blockchain}, false),
+		config:     config,
 	}
+	backend.events = filters.NewEventSystem(new(event.TypeMux), &filterBackend{database, blockchain, &backend.rmLogsFeed}, false)
 	backend.rollback()
-	return backend
+	return backend, nil
 }
 
 // Commit imports all the pending transactions as a single block and starts a
@@ -102,11 +154,86 @@ func (b *SimulatedBackend) Rollback() {
 }
 
 func (b *SimulatedBackend) rollback() {
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.database, 1, func(int, *core.BlockGen) {})
-	statedb, _ := b.blockchain.State()
+	b.pendingTime = 0
+	b.generatePending(nil)
+}
 
+// generatePending rebuilds the pending block on top of the current head from
+// the given transactions and the pending time shift.
+func (b *SimulatedBackend) generatePending(txs types.Transactions) {
+	blocks, _ := core.GenerateChainWithState(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.blockchain.StateCache(), 1, func(number int, block *core.BlockGen) {
+		for _, tx := range txs {
+			block.AddTxWithChain(b.blockchain, tx)
+		}
+		if b.pendingTime != 0 {
+			block.OffsetTime(b.pendingTime)
+		}
+	})
 	b.pendingBlock = blocks[0]
-	b.pendingState, _ = state.New(b.pendingBlock.Root(), statedb.Database())
+	b.pendingState, _ = state.New(b.pendingBlock.Root(), b.blockchain.StateCache())
+}
+
+// Snapshot records the current state of the simulated chain, including the
+// pending transactions, and returns an identifier to revert to it later.
+func (b *SimulatedBackend) Snapshot() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastSnapshot++
+	b.snapshots = append(b.snapshots, &simSnapshot{
+		id:   b.lastSnapshot,
+		head: b.blockchain.CurrentBlock(),
+		txs:  b.pendingBlock.Transactions(),
+		time: b.pendingTime,
+	})
+	return b.lastSnapshot
+}
+
+// Revert rolls the simulated chain back to the given snapshot, dropping all the
+// blocks committed since. The logs of the dropped blocks are delivered to the
+// log subscribers as removed. The snapshot and all later ones are discarded.
+func (b *SimulatedBackend) Revert(id int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, snapshot := range b.snapshots {
+		if snapshot.id != id {
+			continue
+		}
+		b.snapshots = b.snapshots[:i]
+		if err := b.rewind(snapshot.head); err != nil {
+			return err
+		}
+		b.pendingTime = snapshot.time
+		b.generatePending(snapshot.txs)
+		return nil
+	}
+	return fmt.Errorf("unknown snapshot %d", id)
+}
+
+// rewind sets the head of the chain back to the given ancestor block, announcing
+// the logs of the dropped blocks as removed.
+func (b *SimulatedBackend) rewind(head *types.Block) error {
+	var removed []*types.Log
+	for block := b.blockchain.CurrentBlock(); block.NumberU64() > head.NumberU64(); block = b.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1) {
+		for _, receipt := range rawdb.ReadReceipts(b.database, block.Hash(), block.NumberU64()) {
+			for _, log := range receipt.Logs {
+				del := *log
+				del.Removed = true
+				removed = append(removed, &del)
+			}
+		}
+	}
+	if err := b.blockchain.SetHead(head.NumberU64()); err != nil {
+		return err
+	}
+	if current := b.blockchain.CurrentBlock(); current.Hash() != head.Hash() {
+		return fmt.Errorf("failed to rewind to block #%d [%x…], head is #%d [%x…]", head.NumberU64(), head.Hash().Bytes()[:4], current.NumberU64(), current.Hash().Bytes()[:4])
+	}
+	if len(removed) > 0 {
+		b.rmLogsFeed.Send(core.RemovedLogsEvent{Logs: removed})
+	}
+	return nil
 }
 
 // CodeAt returns the code associated with a certain account in the blockchain.
@@ -164,6 +291,33 @@ func (b *SimulatedBackend) TransactionReceipt(ctx context.Context, txHash common
 	return receipt, nil
 }
 
+// HeaderByHash returns the header of the block with the given hash, even if it
+// is not part of the canonical chain.
+func (b *SimulatedBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if header := b.blockchain.GetHeaderByHash(hash); header != nil {
+		return header, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+// HeaderByNumber returns the canonical header with the given number, or the
+// current head if number is nil.
+func (b *SimulatedBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if number == nil {
+		return b.blockchain.CurrentHeader(), nil
+	}
+	if header := b.blockchain.GetHeaderByNumber(number.Uint64()); header != nil {
+		return header, nil
+	}
+	return nil, ethereum.NotFound
+}
+
 // PendingCodeAt returns the code associated with an account in the pending state.
 func (b *SimulatedBackend) PendingCodeAt(ctx context.Context, contract common.Address) ([]byte, error) {
 	b.mu.Lock()
@@ -188,6 +342,69 @@ func (b *SimulatedBackend) CallContract(ctx context.Context, call ethereum.CallM
 	return rval, err
 }
 
+// OverrideAccount is a set of changes applied to the state of an account for the
+// duration of a call, mirroring the state override set of eth_call.
+type OverrideAccount struct {
+	Nonce     *uint64                     // Nonce to set, unchanged if nil
+	Code      []byte                      // Code to set, unchanged if nil
+	Balance   *big.Int                    // Balance to set, unchanged if nil
+	State     map[common.Hash]common.Hash // Replacement for the entire storage
+	StateDiff map[common.Hash]common.Hash // Storage slots to change, others are kept
+}
+
+// apply applies the overrides to the state of the given account.
+func (account *OverrideAccount) apply(statedb *state.StateDB, address common.Address) error {
+	if account.State != nil && account.StateDiff != nil {
+		return fmt.Errorf("account %s has both 'State' and 'StateDiff' overrides", address.Hex())
+	}
+	if account.State != nil {
+		// Recreate the account to wipe its storage, the balance is carried over
+		nonce, code := statedb.GetNonce(address), statedb.GetCode(address)
+		statedb.CreateAccount(address)
+		statedb.SetNonce(address, nonce)
+		statedb.SetCode(address, code)
+	}
+	if account.Nonce != nil {
+		statedb.SetNonce(address, *account.Nonce)
+	}
+	if account.Code != nil {
+		statedb.SetCode(address, account.Code)
+	}
+	if account.Balance != nil {
+		statedb.SetBalance(address, account.Balance)
+	}
+	for key, value := range account.State {
+		statedb.SetState(address, key, value)
+	}
+	for key, value := range account.StateDiff {
+		statedb.SetState(address, key, value)
+	}
+	return nil
+}
+
+// CallContractWithOverrides executes a contract call on top of the state of the
+// latest block, modified by the given account overrides. The overrides are only
+// visible to the call and are discarded afterwards.
+func (b *SimulatedBackend) CallContractWithOverrides(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int, overrides map[common.Address]OverrideAccount) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if blockNumber != nil && blockNumber.Cmp(b.blockchain.CurrentBlock().Number()) != 0 {
+		return nil, errBlockNumberUnsupported
+	}
+	state, err := b.blockchain.State()
+	if err != nil {
+		return nil, err
+	}
+	for address, account := range overrides {
+		if err := account.apply(state, address); err != nil {
+			return nil, err
+		}
+	}
+	rval, _, _, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), state)
+	return rval, err
+}
+
 // PendingCallContract executes a contract call on the pending state.
 func (b *SimulatedBackend) PendingCallContract(ctx context.Context, call ethereum.CallMsg) ([]byte, error) {
 	b.mu.Lock()
@@ -288,7 +505,12 @@ func (b *SimulatedBackend) callContract(ctx context.Context, call ethereum.CallM
 	vmenv := vm.NewEVM(evmContext, statedb, b.config, vm.Config{})
 	gaspool := new(core.GasPool).AddGas(math.MaxUint64)
 
-	return core.NewStateTransition(vmenv, msg, gaspool).TransitionDb()
+	rval, gas, failed, err := core.NewStateTransition(vmenv, msg, gaspool).TransitionDb()
+	if err == nil {
+		// Surface state access failures, e.g. a forked chain's remote being unavailable
+		err = statedb.Error()
+	}
+	return rval, gas, failed, err
 }
 
 // SendTransaction updates the pending block to include the given transaction.
@@ -306,16 +528,7 @@ func (b *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transa
 		panic(fmt.Errorf("invalid transaction nonce: got %d, want %d", tx.Nonce(), nonce))
 	}
 
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
-		for _, tx := range b.pendingBlock.Transactions() {
-			block.AddTxWithChain(b.blockchain, tx)
-		}
-		block.AddTxWithChain(b.blockchain, tx)
-	})
-	statedb, _ := b.blockchain.State()
-
-	b.pendingBlock = blocks[0]
-	b.pendingState, _ = state.New(b.pendingBlock.Root(), statedb.Database())
+	b.generatePending(append(b.pendingBlock.Transactions(), tx))
 	return nil
 }
 
@@ -327,7 +540,7 @@ func (b *SimulatedBackend) FilterLogs(ctx context.Context, query ethereum.Filter
 	var filter *filters.Filter
 	if query.BlockHash != nil {
 		// Block filter requested, construct a single-shot filter
-		filter = filters.NewBlockFilter(&filterBackend{b.database, b.blockchain}, *query.BlockHash, query.Addresses, query.Topics)
+		filter = filters.NewBlockFilter(&filterBackend{b.database, b.blockchain, &b.rmLogsFeed}, *query.BlockHash, query.Addresses, query.Topics)
 	} else {
 		// Initialize unset filter boundaried to run from genesis to chain head
 		from := int64(0)
@@ -339,7 +552,7 @@ func (b *SimulatedBackend) FilterLogs(ctx context.Context, query ethereum.Filter
 			to = query.ToBlock.Int64()
 		}
 		// Construct the range filter
-		filter = filters.NewRangeFilter(&filterBackend{b.database, b.blockchain}, from, to, query.Addresses, query.Topics)
+		filter = filters.NewRangeFilter(&filterBackend{b.database, b.blockchain, &b.rmLogsFeed}, from, to, query.Addresses, query.Topics)
 	}
 	// Run the filter and return all the logs
 	logs, err := filter.Logs(ctx)
@@ -387,20 +600,41 @@ func (b *SimulatedBackend) SubscribeFilterLogs(ctx context.Context, query ethere
 	}), nil
 }
 
-// AdjustTime adds a time shift to the simulated clock.
+// SubscribeNewHead returns an event subscription for a new header imported as
+// head of the chain.
+func (b *SimulatedBackend) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	sink := make(chan *types.Header)
+	sub := b.events.SubscribeNewHeads(sink)
+
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case head := <-sink:
+				select {
+				case ch <- head:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// AdjustTime adds a time shift to the simulated clock, moving the timestamp of
+// the pending block. Consecutive adjustments accumulate until the next commit.
 func (b *SimulatedBackend) AdjustTime(adjustment time.Duration) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
-		for _, tx := range b.pendingBlock.Transactions() {
-			block.AddTx(tx)
-		}
-		block.OffsetTime(int64(adjustment.Seconds()))
-	})
-	statedb, _ := b.blockchain.State()
 
-	b.pendingBlock = blocks[0]
-	b.pendingState, _ = state.New(b.pendingBlock.Root(), statedb.Database())
+	b.pendingTime += int64(adjustment.Seconds())
+	b.generatePending(b.pendingBlock.Transactions())
 
 	return nil
 }
@@ -422,8 +656,9 @@ func (m callmsg) Data() []byte         { return m.CallMsg.Data }
 // filterBackend implements filters.Backend to support filtering for logs without
 // taking bloom-bits acceleration structures into account.
 type filterBackend struct {
-	db ethdb.Database
-	bc *core.BlockChain
+	db     ethdb.Database
+	bc     *core.BlockChain
+	rmLogs *event.Feed // Logs removed by reverting the simulated chain
 }
 
 func (fb *filterBackend) ChainDb() ethdb.Database  { return fb.db }
@@ -474,13 +709,27 @@ func (fb *filterBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Su
 	return fb.bc.SubscribeChainEvent(ch)
 }
 func (fb *filterBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
-	return fb.bc.SubscribeRemovedLogsEvent(ch)
+	chainSub, revertSub := fb.bc.SubscribeRemovedLogsEvent(ch), fb.rmLogs.Subscribe(ch)
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer chainSub.Unsubscribe()
+		defer revertSub.Unsubscribe()
+
+		select {
+		case err := <-chainSub.Err():
+			return err
+		case err := <-revertSub.Err():
+			return err
+		case <-quit:
+			return nil
+		}
+	})
 }
 func (fb *filterBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
 	return fb.bc.SubscribeLogsEvent(ch)
 }
 
-func (fb *filterBackend) BloomStatus() (uint64, uint64) { return 4096, 0 }
+func (fb *filterBackend) BloomStatus() (uint64, uint64)    { return 4096, 0 }
+func (fb *filterBackend) LogIndexStatus() (uint64, uint64) { return 4096, 0 }
 func (fb *filterBackend) ServiceFilter(ctx context.Context, ms *bloombits.MatcherSession) {
 	panic("not supported")
 }
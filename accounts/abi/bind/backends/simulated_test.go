@@ -0,0 +1,333 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// Ensure at compile time that remote nodes can be forked through ethclient.
+var _ backends.ForkSource = (*ethclient.Client)(nil)
+
+// storerCode is the runtime code of a hand assembled contract, which stores the
+// first word of the call data in slot 0 and logs it. Calls without data return
+// the value of slot 0 instead:
+//
+//	CALLDATASIZE ISZERO PUSH1 0x15 JUMPI
+//	PUSH1 0 CALLDATALOAD DUP1 PUSH1 0 SSTORE PUSH1 0 MSTORE PUSH1 32 PUSH1 0 LOG0 STOP
+//	JUMPDEST PUSH1 0 SLOAD PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+var storerCode = common.FromHex("0x36156015576000358060005560005260206000a0005b60005460005260206000f3")
+
+// storerDeployCode is the init code deploying storerCode.
+var storerDeployCode = append(common.FromHex("0x602180600b6000396000f3"), storerCode...)
+
+// simTester wraps a simulated backend with a funded account sending transactions.
+type simTester struct {
+	t     *testing.T
+	sim   *backends.SimulatedBackend
+	key   *ecdsa.PrivateKey
+	from  common.Address
+	nonce uint64
+}
+
+func newSimTester(t *testing.T, fork backends.ForkSource) *simTester {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	alloc := core.GenesisAlloc{from: {Balance: big.NewInt(1000000000000000000)}}
+
+	sim := backends.NewSimulatedBackend(alloc, 10000000)
+	if fork != nil {
+		var err error
+		if sim, err = backends.NewForkedBackend(fork, nil, alloc, 0); err != nil {
+			t.Fatalf("failed to fork: %v", err)
+		}
+	}
+	return &simTester{t: t, sim: sim, key: key, from: from}
+}
+
+// send signs and sends a transaction to the given contract, or deploys the data
+// as a new contract if to is nil.
+func (st *simTester) send(to *common.Address, data []byte) *types.Transaction {
+	tx := types.NewContractCreation(st.nonce, new(big.Int), 1000000, big.NewInt(1), data)
+	if to != nil {
+		tx = types.NewTransaction(st.nonce, *to, new(big.Int), 1000000, big.NewInt(1), data)
+	}
+	tx, _ = types.SignTx(tx, types.HomesteadSigner{}, st.key)
+	if err := st.sim.SendTransaction(context.Background(), tx); err != nil {
+		st.t.Fatalf("failed to send transaction: %v", err)
+	}
+	st.nonce++
+	return tx
+}
+
+// deploy deploys the storer contract, returning its address.
+func (st *simTester) deploy() common.Address {
+	tx := st.send(nil, storerDeployCode)
+	st.sim.Commit()
+	return crypto.CreateAddress(st.from, tx.Nonce())
+}
+
+// store sets the value stored by the storer contract.
+func (st *simTester) store(contract common.Address, value int64) {
+	st.send(&contract, common.LeftPadBytes(big.NewInt(value).Bytes(), 32))
+}
+
+// stored returns the value stored by the storer contract.
+func (st *simTester) stored(contract common.Address) int64 {
+	out, err := st.sim.CallContract(context.Background(), ethereum.CallMsg{To: &contract}, nil)
+	if err != nil {
+		st.t.Fatalf("failed to call contract: %v", err)
+	}
+	return new(big.Int).SetBytes(out).Int64()
+}
+
+// Tests that the simulated chain can be reverted to snapshots, announcing the
+// logs of the dropped blocks as removed and restoring the pending transactions.
+func TestSimulatedBackendSnapshots(t *testing.T) {
+	st := newSimTester(t, nil)
+	contract := st.deploy()
+
+	logs := make(chan types.Log, 16)
+	sub, err := st.sim.SubscribeFilterLogs(context.Background(), ethereum.FilterQuery{Addresses: []common.Address{contract}}, logs)
+	if err != nil {
+		t.Fatalf("failed to subscribe to logs: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	first := st.sim.Snapshot()
+	st.store(contract, 1)
+	st.sim.Commit()
+
+	st.store(contract, 2)
+	second := st.sim.Snapshot()
+	pending := st.nonce
+
+	st.sim.Commit()
+	if have := st.stored(contract); have != 2 {
+		t.Fatalf("stored value mismatch: have %d, want 2", have)
+	}
+	// Revert to the second snapshot and check the transaction is pending again
+	if err := st.sim.Revert(second); err != nil {
+		t.Fatalf("failed to revert to second snapshot: %v", err)
+	}
+	if have := st.stored(contract); have != 1 {
+		t.Errorf("stored value mismatch after revert: have %d, want 1", have)
+	}
+	if nonce, _ := st.sim.PendingNonceAt(context.Background(), st.from); nonce != pending {
+		t.Errorf("pending nonce mismatch after revert: have %d, want %d", nonce, pending)
+	}
+	// Revert to the first snapshot and check the logs of both blocks are removed
+	if err := st.sim.Revert(first); err != nil {
+		t.Fatalf("failed to revert to first snapshot: %v", err)
+	}
+	if have := st.stored(contract); have != 0 {
+		t.Errorf("stored value mismatch after revert: have %d, want 0", have)
+	}
+	if err := st.sim.Revert(second); err == nil {
+		t.Errorf("reverted to discarded snapshot")
+	}
+	for i, want := range []struct {
+		value   byte
+		removed bool
+	}{{1, false}, {2, false}, {2, true}, {1, true}} {
+		select {
+		case log := <-logs:
+			if log.Data[31] != want.value || log.Removed != want.removed {
+				t.Errorf("log %d: mismatch: have value %d (removed %v), want %d (removed %v)", i, log.Data[31], log.Removed, want.value, want.removed)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("log %d: not delivered", i)
+		}
+	}
+	// Ensure the chain can progress again after reverting
+	st.nonce = 1
+	st.store(contract, 3)
+	st.sim.Commit()
+	if have := st.stored(contract); have != 3 {
+		t.Errorf("stored value mismatch after new commit: have %d, want 3", have)
+	}
+}
+
+// Tests that time adjustments survive further pending transactions and that
+// new heads are announced.
+func TestSimulatedBackendAdjustTime(t *testing.T) {
+	st := newSimTester(t, nil)
+
+	heads := make(chan *types.Header, 1)
+	sub, err := st.sim.SubscribeNewHead(context.Background(), heads)
+	if err != nil {
+		t.Fatalf("failed to subscribe to heads: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	parent, _ := st.sim.HeaderByNumber(context.Background(), nil)
+	st.sim.AdjustTime(time.Hour)
+	st.sim.AdjustTime(time.Minute)
+	st.send(&common.Address{}, nil)
+	st.sim.Commit()
+
+	select {
+	case head := <-heads:
+		if have, want := head.Time.Uint64()-parent.Time.Uint64(), uint64(3660+10); have != want {
+			t.Errorf("block time shift mismatch: have %d, want %d", have, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("new head not announced")
+	}
+}
+
+// Tests that calls can override the state of accounts.
+func TestSimulatedBackendCallOverrides(t *testing.T) {
+	st := newSimTester(t, nil)
+	contract := st.deploy()
+	st.store(contract, 1)
+	st.sim.Commit()
+
+	call := func(to common.Address, overrides map[common.Address]backends.OverrideAccount) int64 {
+		out, err := st.sim.CallContractWithOverrides(context.Background(), ethereum.CallMsg{To: &to}, nil, overrides)
+		if err != nil {
+			t.Fatalf("failed to call contract: %v", err)
+		}
+		return new(big.Int).SetBytes(out).Int64()
+	}
+	diff := map[common.Address]backends.OverrideAccount{
+		contract: {StateDiff: map[common.Hash]common.Hash{{31: 1}: {31: 5}}},
+	}
+	if have := call(contract, diff); have != 1 {
+		t.Errorf("untouched slot overridden: have %d, want 1", have)
+	}
+	diff[contract].StateDiff[common.Hash{}] = common.Hash{31: 7}
+	if have := call(contract, diff); have != 7 {
+		t.Errorf("state diff not applied: have %d, want 7", have)
+	}
+	wipe := map[common.Address]backends.OverrideAccount{
+		contract: {State: map[common.Hash]common.Hash{}},
+	}
+	if have := call(contract, wipe); have != 0 {
+		t.Errorf("state not replaced: have %d, want 0", have)
+	}
+	empty := common.Address{0xee}
+	code := map[common.Address]backends.OverrideAccount{
+		empty: {Code: storerCode, StateDiff: map[common.Hash]common.Hash{{}: {31: 9}}},
+	}
+	if have := call(empty, code); have != 9 {
+		t.Errorf("code override not applied: have %d, want 9", have)
+	}
+	if have := st.stored(contract); have != 1 {
+		t.Errorf("overrides leaked into the chain: have %d, want 1", have)
+	}
+}
+
+// stubForkSource is a ForkSource serving a fixed set of accounts.
+type stubForkSource struct {
+	header   *types.Header
+	balances map[common.Address]*big.Int
+	code     map[common.Address][]byte
+	storage  map[common.Address]map[common.Hash]common.Hash
+	reads    int
+}
+
+func (s *stubForkSource) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return s.header, nil
+}
+
+func (s *stubForkSource) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	if balance, ok := s.balances[account]; ok {
+		return balance, nil
+	}
+	return new(big.Int), nil
+}
+
+func (s *stubForkSource) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	if len(s.code[account]) > 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (s *stubForkSource) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return s.code[account], nil
+}
+
+func (s *stubForkSource) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	s.reads++
+	value := s.storage[account][key]
+	return value[:], nil
+}
+
+// Tests that a forked backend lazily loads the remote state, keeping all changes
+// local, including deletions.
+func TestForkedBackend(t *testing.T) {
+	var (
+		contract = common.Address{0xcc}
+		rich     = common.Address{0xaa}
+		source   = &stubForkSource{
+			header:   &types.Header{Number: big.NewInt(1000), Time: big.NewInt(1500000000), GasLimit: 8000000, Difficulty: big.NewInt(1)},
+			balances: map[common.Address]*big.Int{rich: big.NewInt(314)},
+			code:     map[common.Address][]byte{contract: storerCode},
+			storage:  map[common.Address]map[common.Hash]common.Hash{contract: {{}: {31: 42}}},
+		}
+	)
+	st := newSimTester(t, source)
+
+	if balance, _ := st.sim.BalanceAt(context.Background(), rich, nil); balance.Int64() != 314 {
+		t.Errorf("remote balance mismatch: have %v, want 314", balance)
+	}
+	if have := st.stored(contract); have != 42 {
+		t.Fatalf("remote storage mismatch: have %d, want 42", have)
+	}
+	snapshot := st.sim.Snapshot()
+
+	st.store(contract, 5)
+	st.sim.Commit()
+	if have := st.stored(contract); have != 5 {
+		t.Errorf("local storage mismatch: have %d, want 5", have)
+	}
+	st.store(contract, 0)
+	st.sim.Commit()
+	if have := st.stored(contract); have != 0 {
+		t.Errorf("deleted storage mismatch: have %d, want 0", have)
+	}
+	if source.storage[contract][common.Hash{}] != (common.Hash{31: 42}) {
+		t.Errorf("remote storage modified")
+	}
+	if source.reads != 1 {
+		t.Errorf("remote storage reads mismatch: have %d, want 1", source.reads)
+	}
+	// Reverting to before the local changes must expose the remote state again
+	if err := st.sim.Revert(snapshot); err != nil {
+		t.Fatalf("failed to revert: %v", err)
+	}
+	if have := st.stored(contract); have != 42 {
+		t.Errorf("remote storage mismatch after revert: have %d, want 42", have)
+	}
+	if head, _ := st.sim.HeaderByNumber(context.Background(), nil); head.Time.Cmp(source.header.Time) != 0 {
+		t.Errorf("fork time mismatch: have %v, want %v", head.Time, source.header.Time)
+	}
+}
@@ -324,20 +324,36 @@ func (c *BoundContract) WatchLogs(opts *WatchOpts, name string, query ...[]inter
 	return logs, sub, nil
 }
 
-// UnpackLog unpacks a retrieved log into the provided output structure.
-func (c *BoundContract) UnpackLog(out interface{}, event string, log types.Log) error {
-	if len(log.Data) > 0 {
-		if err := c.abi.Unpack(out, event, log.Data); err != nil {
-			return err
-		}
+// NewLogWatcher creates a resumable watcher for the contract logs of the named
+// event, persisting its progress into the given checkpoint store. The filterer
+// of the contract needs to implement LogWatcherBackend.
+func (c *BoundContract) NewLogWatcher(store CheckpointStore, name string, query ...[]interface{}) (*LogWatcher, error) {
+	backend, ok := c.filterer.(LogWatcherBackend)
+	if !ok {
+		return nil, errors.New("contract filterer doesn't support log watchers")
 	}
-	var indexed abi.Arguments
-	for _, arg := range c.abi.Events[event].Inputs {
-		if arg.Indexed {
-			indexed = append(indexed, arg)
-		}
+	// Append the event selector to the query parameters and construct the topic set
+	query = append([][]interface{}{{c.abi.Events[name].Id()}}, query...)
+
+	topics, err := makeTopics(query...)
+	if err != nil {
+		return nil, err
 	}
-	return parseTopics(out, indexed, log.Topics[1:])
+	config := ethereum.FilterQuery{
+		Addresses: []common.Address{c.address},
+		Topics:    topics,
+	}
+	return NewLogWatcher(backend, config, store), nil
+}
+
+// UnpackLog unpacks a retrieved log into the provided output structure.
+func (c *BoundContract) UnpackLog(out interface{}, event string, log types.Log) error {
+	return c.abi.UnpackLog(out, event, log)
+}
+
+// UnpackLogIntoMap unpacks a retrieved log into the provided map.
+func (c *BoundContract) UnpackLogIntoMap(out map[string]interface{}, event string, log types.Log) error {
+	return c.abi.UnpackLogIntoMap(out, event, log)
 }
 
 // ensureContext is a helper method to ensure a context is not nil, even if the
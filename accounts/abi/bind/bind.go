@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"go/format"
 	"regexp"
+	"sort"
 	"strings"
 	"text/template"
 	"unicode"
@@ -45,10 +46,50 @@ const (
 // to be used as is in client code, but rather as an intermediate struct which
 // enforces compile time type safety and naming convention opposed to having to
 // manually maintain hard coded strings that break on runtime.
+//
+// Tuples, and arrays of them, are bound to named structs in Go and to nested
+// classes in Java, the latter converted from and to the mobile Interfaces by
+// generated helpers. There is no Objective-C generator, LangObjC is always
+// rejected.
+//
+// Bind does not link libraries, bytecode referencing any is bound as is. Use
+// BindWithLibraries to generate deploy methods linking them.
 func Bind(types []string, abis []string, bytecodes []string, pkg string, lang Lang) (string, error) {
-	// Process each individual contract requested binding
-	contracts := make(map[string]*tmplContract)
+	return bind(types, abis, bytecodes, pkg, lang, nil)
+}
+
+// BindWithLibraries generates a wrapper around a contract ABI like Bind, also
+// linking the contracts against the libraries bound along with them.
+//
+// The libs map associates the fully qualified names of libraries (source path
+// and name, e.g. "contracts/Math.sol:Math") with the types they are bound to.
+// Contracts whose bytecode references these libraries get deploy methods that
+// link the library addresses into the bytecode, references to any other library
+// are rejected.
+func BindWithLibraries(types []string, abis []string, bytecodes []string, pkg string, lang Lang, libs map[string]string) (string, error) {
+	for name := range libs {
+		if i := strings.LastIndex(name, ":"); i <= 0 || i == len(name)-1 {
+			return "", fmt.Errorf("library %q not fully qualified, want path:Name", name)
+		}
+	}
+	if libs == nil {
+		libs = make(map[string]string)
+	}
+	return bind(types, abis, bytecodes, pkg, lang, libs)
+}
 
+// bind generates the wrapper around a contract ABI, linking the contracts against
+// the given libraries unless the map is nil.
+func bind(types []string, abis []string, bytecodes []string, pkg string, lang Lang, libs map[string]string) (string, error) {
+	// Make sure the requested language is actually supported
+	if _, ok := tmplSource[lang]; !ok {
+		return "", fmt.Errorf("unsupported binding language: %d", lang)
+	}
+	// Process each individual contract requested binding
+	var (
+		contracts = make(map[string]*tmplContract)
+		structs   = newStructBinder(types)
+	)
 	for i := 0; i < len(types); i++ {
 		// Parse the actual ABI to generate the binding for
 		evmABI, err := abi.JSON(strings.NewReader(abis[i]))
@@ -69,7 +110,8 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string, lang La
 			transacts = make(map[string]*tmplMethod)
 			events    = make(map[string]*tmplEvent)
 		)
-		for _, original := range evmABI.Methods {
+		for _, name := range sortedMethods(evmABI.Methods) {
+			original := evmABI.Methods[name]
 			// Normalize the method for capital cases and non-anonymous inputs/outputs
 			normalized := original
 			normalized.Name = methodNormalizer[lang](original.Name)
@@ -88,6 +130,13 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string, lang La
 					normalized.Outputs[j].Name = capitalise(output.Name)
 				}
 			}
+			// Generate named types for all the tuples within the method
+			if err := structs.bindArgs(lang, normalized.Inputs); err != nil {
+				return "", err
+			}
+			if err := structs.bindArgs(lang, normalized.Outputs); err != nil {
+				return "", err
+			}
 			// Append the methods to the call or transact lists
 			if original.Const {
 				calls[original.Name] = &tmplMethod{Original: original, Normalized: normalized, Structured: structured(original.Outputs)}
@@ -95,7 +144,8 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string, lang La
 				transacts[original.Name] = &tmplMethod{Original: original, Normalized: normalized, Structured: structured(original.Outputs)}
 			}
 		}
-		for _, original := range evmABI.Events {
+		for _, name := range sortedEvents(evmABI.Events) {
+			original := evmABI.Events[name]
 			// Skip anonymous events as they don't support explicit filtering
 			if original.Anonymous {
 				continue
@@ -114,9 +164,15 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string, lang La
 					}
 				}
 			}
+			if err := structs.bindArgs(lang, normalized.Inputs); err != nil {
+				return "", err
+			}
 			// Append the event to the accumulator list
 			events[original.Name] = &tmplEvent{Original: original, Normalized: normalized}
 		}
+		if err := structs.bindArgs(lang, evmABI.Constructor.Inputs); err != nil {
+			return "", err
+		}
 		contracts[types[i]] = &tmplContract{
 			Type:        capitalise(types[i]),
 			InputABI:    strings.Replace(strippedABI, "\"", "\\\"", -1),
@@ -127,19 +183,36 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string, lang La
 			Events:      events,
 		}
 	}
+	// Link the contracts against the libraries they depend on, if requested
+	if libs != nil {
+		if err := linkContracts(contracts, libs, lang); err != nil {
+			return "", err
+		}
+	}
 	// Generate the contract template data content and render it
 	data := &tmplData{
 		Package:   pkg,
 		Contracts: contracts,
+		Structs:   structs.structs,
 	}
 	buffer := new(bytes.Buffer)
 
 	funcs := map[string]interface{}{
-		"bindtype":      bindType[lang],
-		"bindtopictype": bindTopicType[lang],
-		"namedtype":     namedType[lang],
-		"capitalise":    capitalise,
-		"decapitalise":  decapitalise,
+		"bindtype": func(kind abi.Type) string {
+			return bindType[lang](kind, structs.structs)
+		},
+		"bindtopictype": func(kind abi.Type) string {
+			return bindTopicType[lang](kind, structs.structs)
+		},
+		"namedtype": namedType[lang],
+		"wrapjava": func(kind abi.Type, value string) string {
+			return wrapJava(kind, value, structs.structs)
+		},
+		"unwrapjava": func(kind abi.Type, iface string) string {
+			return unwrapJava(kind, iface, structs.structs)
+		},
+		"capitalise":   capitalise,
+		"decapitalise": decapitalise,
 	}
 	tmpl := template.Must(template.New("").Funcs(funcs).Parse(tmplSource[lang]))
 	if err := tmpl.Execute(buffer, data); err != nil {
@@ -159,7 +232,7 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string, lang La
 
 // bindType is a set of type binders that convert Solidity types to some supported
 // programming language types.
-var bindType = map[Lang]func(kind abi.Type) string{
+var bindType = map[Lang]func(kind abi.Type, structs map[string]*tmplStruct) string{
 	LangGo:   bindTypeGo,
 	LangJava: bindTypeJava,
 }
@@ -184,56 +257,50 @@ func wrapArray(stringKind string, innerLen int, innerMapping string) (string, []
 	return innerMapping, parts
 }
 
-// Translates the array sizes to a Go-lang declaration of a (nested) array of the inner type.
-// Simply returns the inner type if arraySizes is empty.
-func arrayBindingGo(inner string, arraySizes []string) string {
-	out := ""
-	//prepend all array sizes, from outer (end arraySizes) to inner (start arraySizes)
-	for i := len(arraySizes) - 1; i >= 0; i-- {
-		out += "[" + arraySizes[i] + "]"
-	}
-	out += inner
-	return out
-}
-
 // bindTypeGo converts a Solidity type to a Go one. Since there is no clear mapping
 // from all Solidity types to Go ones (e.g. uint17), those that cannot be exactly
-// mapped will use an upscaled type (e.g. *big.Int).
-func bindTypeGo(kind abi.Type) string {
-	stringKind := kind.String()
-	innerLen, innerMapping := bindUnnestedTypeGo(stringKind)
-	return arrayBindingGo(wrapArray(stringKind, innerLen, innerMapping))
+// mapped will use an upscaled type (e.g. *big.Int). Tuples are mapped to the
+// named struct types collected before code generation.
+func bindTypeGo(kind abi.Type, structs map[string]*tmplStruct) string {
+	switch kind.T {
+	case abi.TupleTy:
+		return structs[structID(kind)].Name
+	case abi.ArrayTy:
+		return fmt.Sprintf("[%d]", kind.Size) + bindTypeGo(*kind.Elem, structs)
+	case abi.SliceTy:
+		return "[]" + bindTypeGo(*kind.Elem, structs)
+	default:
+		return bindBasicTypeGo(kind)
+	}
 }
 
-// The inner function of bindTypeGo, this finds the inner type of stringKind.
-// (Or just the type itself if it is not an array or slice)
-// The length of the matched part is returned, with the translated type.
-func bindUnnestedTypeGo(stringKind string) (int, string) {
-
-	switch {
-	case strings.HasPrefix(stringKind, "address"):
-		return len("address"), "common.Address"
-
-	case strings.HasPrefix(stringKind, "bytes"):
-		parts := regexp.MustCompile(`bytes([0-9]*)`).FindStringSubmatch(stringKind)
-		return len(parts[0]), fmt.Sprintf("[%s]byte", parts[1])
-
-	case strings.HasPrefix(stringKind, "int") || strings.HasPrefix(stringKind, "uint"):
-		parts := regexp.MustCompile(`(u)?int([0-9]*)`).FindStringSubmatch(stringKind)
-		switch parts[2] {
-		case "8", "16", "32", "64":
-			return len(parts[0]), fmt.Sprintf("%sint%s", parts[1], parts[2])
+// bindBasicTypeGo converts a non-composite Solidity type to a Go one.
+func bindBasicTypeGo(kind abi.Type) string {
+	switch kind.T {
+	case abi.AddressTy:
+		return "common.Address"
+	case abi.IntTy, abi.UintTy:
+		switch kind.Size {
+		case 8, 16, 32, 64:
+			if kind.T == abi.UintTy {
+				return fmt.Sprintf("uint%d", kind.Size)
+			}
+			return fmt.Sprintf("int%d", kind.Size)
 		}
-		return len(parts[0]), "*big.Int"
-
-	case strings.HasPrefix(stringKind, "bool"):
-		return len("bool"), "bool"
-
-	case strings.HasPrefix(stringKind, "string"):
-		return len("string"), "string"
-
+		return "*big.Int"
+	case abi.FixedBytesTy:
+		return fmt.Sprintf("[%d]byte", kind.Size)
+	case abi.BytesTy:
+		return "[]byte"
+	case abi.FunctionTy:
+		return "[24]byte"
+	case abi.BoolTy:
+		return "bool"
+	case abi.StringTy:
+		return "string"
 	default:
-		return len(stringKind), stringKind
+		// Unknown types are passed through for the compiler to complain about
+		return kind.String()
 	}
 }
 
@@ -246,8 +313,12 @@ func arrayBindingJava(inner string, arraySizes []string) string {
 
 // bindTypeJava converts a Solidity type to a Java one. Since there is no clear mapping
 // from all Solidity types to Java ones (e.g. uint17), those that cannot be exactly
-// mapped will use an upscaled type (e.g. BigDecimal).
-func bindTypeJava(kind abi.Type) string {
+// mapped will use an upscaled type (e.g. BigDecimal). Tuples are mapped to the
+// nested classes generated for them.
+func bindTypeJava(kind abi.Type, structs map[string]*tmplStruct) string {
+	if tuple, dims := innerTuple(kind); tuple != nil {
+		return structs[structID(*tuple)].Name + strings.Repeat("[]", dims)
+	}
 	stringKind := kind.String()
 	innerLen, innerMapping := bindUnnestedTypeJava(stringKind)
 	return arrayBindingJava(wrapArray(stringKind, innerLen, innerMapping))
@@ -310,25 +381,29 @@ func bindUnnestedTypeJava(stringKind string) (int, string) {
 
 // bindTopicType is a set of type binders that convert Solidity types to some
 // supported programming language topic types.
-var bindTopicType = map[Lang]func(kind abi.Type) string{
+var bindTopicType = map[Lang]func(kind abi.Type, structs map[string]*tmplStruct) string{
 	LangGo:   bindTopicTypeGo,
 	LangJava: bindTopicTypeJava,
 }
 
-// bindTypeGo converts a Solidity topic type to a Go one. It is almost the same
-// funcionality as for simple types, but dynamic types get converted to hashes.
-func bindTopicTypeGo(kind abi.Type) string {
-	bound := bindTypeGo(kind)
+// bindTopicTypeGo converts a Solidity topic type to a Go one. It is almost the same
+// funcionality as for simple types, but dynamic types and tuples get converted to
+// hashes, since only the hash of their encoding is stored in the topic.
+func bindTopicTypeGo(kind abi.Type, structs map[string]*tmplStruct) string {
+	if kind.T == abi.TupleTy {
+		return "common.Hash"
+	}
+	bound := bindTypeGo(kind, structs)
 	if bound == "string" || bound == "[]byte" {
 		bound = "common.Hash"
 	}
 	return bound
 }
 
-// bindTypeGo converts a Solidity topic type to a Java one. It is almost the same
+// bindTopicTypeJava converts a Solidity topic type to a Java one. It is almost the same
 // funcionality as for simple types, but dynamic types get converted to hashes.
-func bindTopicTypeJava(kind abi.Type) string {
-	bound := bindTypeJava(kind)
+func bindTopicTypeJava(kind abi.Type, structs map[string]*tmplStruct) string {
+	bound := bindTypeJava(kind, structs)
 	if bound == "String" || bound == "Bytes" {
 		bound = "Hash"
 	}
@@ -343,8 +418,15 @@ var namedType = map[Lang]func(string, abi.Type) string{
 }
 
 // namedTypeJava converts some primitive data types to named variants that can
-// be used as parts of method names.
+// be used as parts of method names. Tuples, and arrays of them, are passed around
+// wrapped into Interfaces.
 func namedTypeJava(javaKind string, solKind abi.Type) string {
+	if tuple, dims := innerTuple(solKind); tuple != nil {
+		if dims == 0 {
+			return "Tuple"
+		}
+		return "Tuples"
+	}
 	switch javaKind {
 	case "byte[]":
 		return "Binary"
@@ -378,6 +460,38 @@ func namedTypeJava(javaKind string, solKind abi.Type) string {
 	}
 }
 
+// wrapJava returns the Java expression converting a value into the form taken by
+// the mobile Interface setters, i.e. wrapping tuples into Interfaces.
+func wrapJava(kind abi.Type, value string, structs map[string]*tmplStruct) string {
+	if tuple, _ := innerTuple(kind); tuple != nil {
+		return fmt.Sprintf("%s.wrap(%s)", structs[structID(*tuple)].Name, value)
+	}
+	return value
+}
+
+// unwrapJava returns the Java expression retrieving a value from a mobile Interface,
+// converting tuples back from the Interfaces wrapping them.
+func unwrapJava(kind abi.Type, iface string, structs map[string]*tmplStruct) string {
+	getter := fmt.Sprintf("%s.get%s()", iface, namedTypeJava(bindTypeJava(kind, structs), kind))
+	if tuple, dims := innerTuple(kind); tuple != nil {
+		return fmt.Sprintf("%s.unwrap%s(%s)", structs[structID(*tuple)].Name, strings.Repeat("Array", dims), getter)
+	}
+	return getter
+}
+
+// innerTuple returns the tuple a type is made of, along with the dimensions of
+// the arrays nesting it, or nil if the type isn't a tuple or array of tuples.
+func innerTuple(kind abi.Type) (*abi.Type, int) {
+	dims := 0
+	for kind.T == abi.SliceTy || kind.T == abi.ArrayTy {
+		kind, dims = *kind.Elem, dims+1
+	}
+	if kind.T != abi.TupleTy {
+		return nil, 0
+	}
+	return &kind, dims
+}
+
 // methodNormalizer is a name transformer that modifies Solidity method names to
 // conform to target language naming concentions.
 var methodNormalizer = map[Lang]func(string) string{
@@ -422,3 +536,201 @@ func structured(args abi.Arguments) bool {
 	}
 	return true
 }
+
+// structID returns the identifier deduplicating tuples across the bound contracts.
+// Tuples of the same shape are only merged if their source names match too.
+func structID(kind abi.Type) string {
+	return kind.TupleRawName + kind.String()
+}
+
+// structBinder collects the named Go types of all the tuples used in a set of
+// contracts, deduplicating identical ones across methods, events and contracts.
+type structBinder struct {
+	structs map[string]*tmplStruct // Named struct types keyed by their structID
+	names   map[string]bool        // Type names already taken in the generated code
+}
+
+// newStructBinder creates a tuple binder for a set of contracts, reserving the
+// type names generated for the contracts themselves.
+func newStructBinder(types []string) *structBinder {
+	b := &structBinder{
+		structs: make(map[string]*tmplStruct),
+		names:   make(map[string]bool),
+	}
+	for _, typ := range types {
+		typ = capitalise(typ)
+		for _, suffix := range []string{"", "ABI", "Bin", "Caller", "Transactor", "Filterer", "Session", "CallerSession", "TransactorSession", "Raw", "CallerRaw", "TransactorRaw"} {
+			b.names[typ+suffix] = true
+		}
+	}
+	return b
+}
+
+// bindArgs generates named types for all the tuples within a list of arguments.
+func (b *structBinder) bindArgs(lang Lang, args abi.Arguments) error {
+	for _, arg := range args {
+		if err := b.bind(lang, arg.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bind generates named types for a tuple, or array of tuples, and all the tuples
+// nested within.
+func (b *structBinder) bind(lang Lang, kind abi.Type) error {
+	tuple, dims := innerTuple(kind)
+	if tuple == nil {
+		return nil
+	}
+	id := structID(*tuple)
+	if _, ok := b.structs[id]; !ok {
+		// Name the nested tuples first, the fields need their types
+		fields := make([]*tmplField, len(tuple.TupleElems))
+		for i, elem := range tuple.TupleElems {
+			if err := b.bind(lang, *elem); err != nil {
+				return err
+			}
+			fields[i] = &tmplField{Type: bindType[lang](*elem, b.structs), Name: capitalise(tuple.TupleRawNames[i]), SolKind: *elem}
+		}
+		b.structs[id] = &tmplStruct{Name: b.name(tuple.TupleRawName), Fields: fields}
+	}
+	// Java converts arrays of tuples through helpers generated for each dimension
+	if lang == LangJava {
+		s := b.structs[id]
+		for d := len(s.Arrays) + 1; d <= dims; d++ {
+			s.Arrays = append(s.Arrays, &tmplArray{
+				Type:   s.Name + strings.Repeat("[]", d),
+				Alloc:  s.Name + "[(int) items.size()]" + strings.Repeat("[]", d-1),
+				Unwrap: "unwrap" + strings.Repeat("Array", d),
+				Elem:   "unwrap" + strings.Repeat("Array", d-1),
+				Nested: d > 1,
+			})
+		}
+	}
+	return nil
+}
+
+// name assigns a unique type name to a tuple, preferring its source name if the
+// compiler reported one, falling back to numbered ones otherwise.
+func (b *structBinder) name(raw string) string {
+	base := capitalise(raw)
+	if base != "" && !b.names[base] {
+		b.names[base] = true
+		return base
+	}
+	if base == "" {
+		base = "Struct"
+	}
+	for i := 0; ; i++ {
+		if name := fmt.Sprintf("%s%d", base, i); !b.names[name] {
+			b.names[name] = true
+			return name
+		}
+	}
+}
+
+// sortedMethods returns the names of the methods in alphabetical order, so that
+// tuples are named deterministically across runs.
+func sortedMethods(methods map[string]abi.Method) []string {
+	names := make([]string, 0, len(methods))
+	for name := range methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedEvents returns the names of the events in alphabetical order, so that
+// tuples are named deterministically across runs.
+func sortedEvents(events map[string]abi.Event) []string {
+	names := make([]string, 0, len(events))
+	for name := range events {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// linkContracts resolves the libraries each contract links against, as well as
+// the order in which all of its transitive dependencies have to be deployed.
+func linkContracts(contracts map[string]*tmplContract, libs map[string]string, lang Lang) error {
+	// Gather the libraries that have been bound along with the contracts
+	names := make([]string, 0, len(libs))
+	for name := range libs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var libraries []*tmplLibrary
+	for _, name := range names {
+		if contract, ok := contracts[libs[name]]; ok && contract.InputBin != "" {
+			libraries = append(libraries, &tmplLibrary{Type: contract.Type, Name: name, Placeholders: LibraryPlaceholders(name)})
+		}
+	}
+	// Find the libraries referenced by each contract, rejecting unknown ones
+	linked := make(map[string][]*tmplLibrary) // Direct dependencies keyed by contract type
+	for typ, contract := range contracts {
+		unlinked := contract.InputBin
+		for _, lib := range libraries {
+			if lib.Type == contract.Type {
+				continue
+			}
+			found := false
+			for _, placeholder := range lib.Placeholders {
+				if strings.Contains(unlinked, placeholder) {
+					unlinked, found = strings.Replace(unlinked, placeholder, "", -1), true
+				}
+			}
+			if found {
+				linked[contract.Type] = append(linked[contract.Type], lib)
+			}
+		}
+		if i := strings.Index(unlinked, "__"); i >= 0 {
+			end := i + placeholderLength
+			if end > len(unlinked) {
+				end = len(unlinked)
+			}
+			return fmt.Errorf("contract %s links against unknown library %s", typ, unlinked[i:end])
+		}
+		if len(linked[contract.Type]) > 0 && lang != LangGo {
+			return fmt.Errorf("contract %s links against libraries, only supported by the Go bindings", typ)
+		}
+	}
+	for _, lib := range libraries {
+		lib.Libraries = linked[lib.Type]
+	}
+	// Order the transitive dependencies of each contract for deployment
+	for _, contract := range contracts {
+		contract.Libraries = linked[contract.Type]
+
+		var (
+			visiting = make(map[string]bool)
+			visited  = make(map[string]bool)
+			visit    func(lib *tmplLibrary) error
+		)
+		visit = func(lib *tmplLibrary) error {
+			if visited[lib.Type] {
+				return nil
+			}
+			if visiting[lib.Type] {
+				return fmt.Errorf("cyclic library dependency through %s", lib.Name)
+			}
+			visiting[lib.Type] = true
+			for _, dep := range lib.Libraries {
+				if err := visit(dep); err != nil {
+					return err
+				}
+			}
+			visited[lib.Type] = true
+			contract.Dependencies = append(contract.Dependencies, lib)
+			return nil
+		}
+		for _, lib := range contract.Libraries {
+			if err := visit(lib); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// placeholderLength is the length of a library placeholder in hex bytecode,
+// which is the same as the length of the hex address replacing it.
+const placeholderLength = 2 * common.AddressLength
+
+// LibraryPlaceholders returns the placeholders the Solidity compiler leaves in
+// the bytecode of contracts linking against the library with the given fully
+// qualified name (e.g. "contracts/Math.sol:Math"). Both the hashed placeholder of
+// solc 0.5 and later and the name based one of earlier versions are returned.
+func LibraryPlaceholders(name string) []string {
+	return []string{
+		"__$" + hex.EncodeToString(crypto.Keccak256([]byte(name))[:17]) + "$__",
+		legacyPlaceholder(name),
+	}
+}
+
+// legacyPlaceholder returns the name based placeholder of a library, which is
+// the name truncated to 36 characters, wrapped in and padded with underscores
+// to the length of an address.
+func legacyPlaceholder(name string) string {
+	if len(name) > placeholderLength-4 {
+		name = name[:placeholderLength-4]
+	}
+	placeholder := "__" + name + "__"
+	return placeholder + strings.Repeat("_", placeholderLength-len(placeholder))
+}
+
+// LinkBytecode replaces the library placeholders in the hex encoded bytecode
+// with the addresses of the deployed libraries. An error is returned if any of
+// the placeholders remain unlinked.
+func LinkBytecode(bytecode string, libs map[string]common.Address) (string, error) {
+	for placeholder, address := range libs {
+		bytecode = strings.Replace(bytecode, placeholder, hex.EncodeToString(address[:]), -1)
+	}
+	if i := strings.Index(bytecode, "__"); i >= 0 {
+		end := i + placeholderLength
+		if end > len(bytecode) {
+			end = len(bytecode)
+		}
+		return "", fmt.Errorf("unlinked library placeholder %s", bytecode[i:end])
+	}
+	return bytecode, nil
+}
@@ -22,6 +22,7 @@ import "github.com/ethereum/go-ethereum/accounts/abi"
 type tmplData struct {
 	Package   string                   // Name of the package to place the generated file in
 	Contracts map[string]*tmplContract // List of contracts to generate into this file
+	Structs   map[string]*tmplStruct   // Named types of the tuples used by the contracts
 }
 
 // tmplContract contains the data needed to generate an individual contract binding.
@@ -33,6 +34,17 @@ type tmplContract struct {
 	Calls       map[string]*tmplMethod // Contract calls that only read state data
 	Transacts   map[string]*tmplMethod // Contract calls that write state data
 	Events      map[string]*tmplEvent  // Contract events accessors
+
+	Libraries    []*tmplLibrary // Libraries the contract is directly linked against
+	Dependencies []*tmplLibrary // All libraries the contract depends on, in deployment order
+}
+
+// tmplLibrary is a library bound along with the contracts linking against it.
+type tmplLibrary struct {
+	Type         string         // Type name of the library binding
+	Name         string         // Fully qualified name of the library (source path and name)
+	Placeholders []string       // Placeholders standing in for the library address in bytecode
+	Libraries    []*tmplLibrary // Libraries the library itself is directly linked against
 }
 
 // tmplMethod is a wrapper around an abi.Method that contains a few preprocessed
@@ -49,6 +61,30 @@ type tmplEvent struct {
 	Normalized abi.Event // Normalized version of the parsed fields
 }
 
+// tmplField is a field of a struct generated for a tuple.
+type tmplField struct {
+	Type    string   // Field type in the target binding language
+	Name    string   // Field name converted from the raw tuple component name
+	SolKind abi.Type // Raw abi type information of the field
+}
+
+// tmplStruct is a named struct type generated for a tuple.
+type tmplStruct struct {
+	Name   string       // Source name of the tuple if known, an auto-generated one otherwise
+	Fields []*tmplField // Fields of the tuple, in declaration order
+	Arrays []*tmplArray // Arrays of the tuple used by the Java bindings, by dimension
+}
+
+// tmplArray is an array of tuples, converted from and to the mobile Interfaces
+// by helpers generated into the Java bindings.
+type tmplArray struct {
+	Type   string // Java type of the array
+	Alloc  string // Java expression allocating the array to the size of the items
+	Unwrap string // Name of the helper converting the array from Interfaces
+	Elem   string // Name of the helper converting the array elements from Interfaces
+	Nested bool   // Whether the elements are arrays themselves
+}
+
 // tmplSource is language to template mapping containing all the supported
 // programming languages the package can generate to.
 var tmplSource = map[Lang]string{
@@ -88,6 +124,14 @@ var (
 	_ = event.NewSubscription
 )
 
+{{range .Structs}}
+	// {{.Name}} is an auto generated Go binding around a Solidity tuple.
+	type {{.Name}} struct {
+	{{range .Fields}}
+		{{.Name}} {{.Type}}{{end}}
+	}
+{{end}}
+
 {{range $contract := .Contracts}}
 	// {{.Type}}ABI is the input ABI used to generate the binding from.
 	const {{.Type}}ABI = "{{.InputABI}}"
@@ -96,6 +140,53 @@ var (
 		// {{.Type}}Bin is the compiled bytecode used for deploying new contracts.
 		const {{.Type}}Bin = ` + "`" + `{{.InputBin}}` + "`" + `
 
+		{{if .Libraries}}
+			// {{.Type}}Libraries contains the addresses of the libraries {{.Type}} is linked against.
+			type {{.Type}}Libraries struct {
+				{{range .Libraries}}{{.Type}} common.Address // Library {{.Name}}
+				{{end}}
+			}
+
+			// Deploy{{.Type}} deploys a new Ethereum contract, binding an instance of {{.Type}} to it.
+			// All the libraries {{.Type}} depends on are deployed first and linked into it.
+			func Deploy{{.Type}}(auth *bind.TransactOpts, backend bind.ContractBackend {{range .Constructor.Inputs}}, {{.Name}} {{bindtype .Type}}{{end}}) (common.Address, *types.Transaction, *{{.Type}}, error) {
+			  opts, libs := *auth, make(map[string]common.Address)
+			  {{range .Dependencies}}
+			    {
+			      address, tx, _, err := Deploy{{.Type}}{{if .Libraries}}WithLibraries{{end}}(&opts, backend{{if .Libraries}}, {{.Type}}Libraries{ {{range .Libraries}}{{.Type}}: libs["{{.Name}}"], {{end}} }{{end}})
+			      if err != nil {
+			        return common.Address{}, nil, nil, err
+			      }
+			      if opts.Nonce != nil {
+			        opts.Nonce = new(big.Int).SetUint64(tx.Nonce() + 1)
+			      }
+			      libs["{{.Name}}"] = address
+			    }
+			  {{end}}
+			  return Deploy{{.Type}}WithLibraries(&opts, backend, {{.Type}}Libraries{ {{range .Libraries}}{{.Type}}: libs["{{.Name}}"], {{end}} } {{range .Constructor.Inputs}}, {{.Name}}{{end}})
+			}
+
+			// Deploy{{.Type}}WithLibraries deploys a new Ethereum contract linked against already deployed
+			// libraries, binding an instance of {{.Type}} to it.
+			func Deploy{{.Type}}WithLibraries(auth *bind.TransactOpts, backend bind.ContractBackend, libs {{.Type}}Libraries {{range .Constructor.Inputs}}, {{.Name}} {{bindtype .Type}}{{end}}) (common.Address, *types.Transaction, *{{.Type}}, error) {
+			  parsed, err := abi.JSON(strings.NewReader({{.Type}}ABI))
+			  if err != nil {
+			    return common.Address{}, nil, nil, err
+			  }
+			  bytecode, err := bind.LinkBytecode({{.Type}}Bin, map[string]common.Address{
+			    {{range .Libraries}}{{$library := .}}{{range .Placeholders}}"{{.}}": libs.{{$library.Type}},
+			    {{end}}{{end}}
+			  })
+			  if err != nil {
+			    return common.Address{}, nil, nil, err
+			  }
+			  address, tx, contract, err := bind.DeployContract(auth, parsed, common.FromHex(bytecode), backend {{range .Constructor.Inputs}}, {{.Name}}{{end}})
+			  if err != nil {
+			    return common.Address{}, nil, nil, err
+			  }
+			  return address, tx, &{{.Type}}{ {{.Type}}Caller: {{.Type}}Caller{contract: contract}, {{.Type}}Transactor: {{.Type}}Transactor{contract: contract}, {{.Type}}Filterer: {{.Type}}Filterer{contract: contract} }, nil
+			}
+		{{else}}
 		// Deploy{{.Type}} deploys a new Ethereum contract, binding an instance of {{.Type}} to it.
 		func Deploy{{.Type}}(auth *bind.TransactOpts, backend bind.ContractBackend {{range .Constructor.Inputs}}, {{.Name}} {{bindtype .Type}}{{end}}) (common.Address, *types.Transaction, *{{.Type}}, error) {
 		  parsed, err := abi.JSON(strings.NewReader({{.Type}}ABI))
@@ -108,6 +199,7 @@ var (
 		  }
 		  return address, tx, &{{.Type}}{ {{.Type}}Caller: {{.Type}}Caller{contract: contract}, {{.Type}}Transactor: {{.Type}}Transactor{contract: contract}, {{.Type}}Filterer: {{.Type}}Filterer{contract: contract} }, nil
 		}
+		{{end}}
 	{{end}}
 
 	// {{.Type}} is an auto generated Go binding around an Ethereum contract.
@@ -439,6 +531,32 @@ var (
 				}
 			}), nil
 		}
+
+		// Parse{{.Normalized.Name}} is a log parse operation binding the contract event 0x{{printf "%x" .Original.Id}}.
+		//
+		// Solidity: {{.Original.String}}
+		func (_{{$contract.Type}} *{{$contract.Type}}Filterer) Parse{{.Normalized.Name}}(log types.Log) (*{{$contract.Type}}{{.Normalized.Name}}, error) {
+			event := new({{$contract.Type}}{{.Normalized.Name}})
+			if err := _{{$contract.Type}}.contract.UnpackLog(event, "{{.Original.Name}}", log); err != nil {
+				return nil, err
+			}
+			event.Raw = log
+			return event, nil
+		}
+
+		// New{{.Normalized.Name}}Watcher creates a resumable log watcher binding the contract event 0x{{printf "%x" .Original.Id}},
+		// persisting its progress into the given checkpoint store.
+		//
+		// Solidity: {{.Original.String}}
+		func (_{{$contract.Type}} *{{$contract.Type}}Filterer) New{{.Normalized.Name}}Watcher(store bind.CheckpointStore{{range .Normalized.Inputs}}{{if .Indexed}}, {{.Name}} []{{bindtype .Type}}{{end}}{{end}}) (*bind.LogWatcher, error) {
+			{{range .Normalized.Inputs}}
+			{{if .Indexed}}var {{.Name}}Rule []interface{}
+			for _, {{.Name}}Item := range {{.Name}} {
+				{{.Name}}Rule = append({{.Name}}Rule, {{.Name}}Item)
+			}{{end}}{{end}}
+
+			return _{{$contract.Type}}.contract.NewLogWatcher(store, "{{.Original.Name}}"{{range .Normalized.Inputs}}{{if .Indexed}}, {{.Name}}Rule{{end}}{{end}})
+		}
  	{{end}}
 {{end}}
 `
@@ -456,6 +574,49 @@ import org.ethereum.geth.internal.*;
 
 {{range $contract := .Contracts}}
 	public class {{.Type}} {
+		{{range $.Structs}}
+			// {{.Name}} is an auto generated Java binding around a Solidity tuple.
+			public static class {{.Name}} {
+				{{range .Fields}}public {{.Type}} {{.Name}};
+				{{end}}
+
+				// wrap converts a {{.Name}} into the Interfaces carrying its fields.
+				public static Interfaces wrap({{.Name}} value) throws Exception {
+					Interfaces fields = Geth.newInterfaces({{(len .Fields)}});
+					{{range $index, $field := .Fields}}fields.set({{$index}}, Geth.newInterface()); fields.get({{$index}}).set{{namedtype .Type .SolKind}}({{wrapjava .SolKind (printf "value.%s" .Name)}});
+					{{end}}
+					return fields;
+				}
+
+				// unwrap converts the Interfaces carrying the fields of a tuple into a {{.Name}}.
+				public static {{.Name}} unwrap(Interfaces fields) throws Exception {
+					{{.Name}} value = new {{.Name}}();
+					{{range $index, $field := .Fields}}value.{{.Name}} = {{unwrapjava .SolKind (printf "fields.get(%d)" $index)}};
+					{{end}}
+					return value;
+				}
+				{{range .Arrays}}
+					// wrap converts a {{.Type}} into the Interfaces carrying its items.
+					public static Interfaces wrap({{.Type}} values) throws Exception {
+						Interfaces items = Geth.newInterfaces(values.length);
+						for (int i = 0; i < values.length; i++) {
+							items.set(i, Geth.newInterface()); items.get(i).set{{if .Nested}}Tuples{{else}}Tuple{{end}}(wrap(values[i]));
+						}
+						return items;
+					}
+
+					// {{.Unwrap}} converts the Interfaces carrying the items of an array into a {{.Type}}.
+					public static {{.Type}} {{.Unwrap}}(Interfaces items) throws Exception {
+						{{.Type}} values = new {{.Alloc}};
+						for (int i = 0; i < values.length; i++) {
+							values[i] = {{.Elem}}(items.get(i).get{{if .Nested}}Tuples{{else}}Tuple{{end}}());
+						}
+						return values;
+					}
+				{{end}}
+			}
+		{{end}}
+
 		// ABI is the input ABI used to generate the binding from.
 		public final static String ABI = "{{.InputABI}}";
 
@@ -467,7 +628,7 @@ import org.ethereum.geth.internal.*;
 			public static {{.Type}} deploy(TransactOpts auth, EthereumClient client{{range .Constructor.Inputs}}, {{bindtype .Type}} {{.Name}}{{end}}) throws Exception {
 				Interfaces args = Geth.newInterfaces({{(len .Constructor.Inputs)}});
 				{{range $index, $element := .Constructor.Inputs}}
-				  args.set({{$index}}, Geth.newInterface()); args.get({{$index}}).set{{namedtype (bindtype .Type) .Type}}({{.Name}});
+				  args.set({{$index}}, Geth.newInterface()); args.get({{$index}}).set{{namedtype (bindtype .Type) .Type}}({{wrapjava .Type .Name}});
 				{{end}}
 				return new {{.Type}}(Geth.deployContract(auth, ABI, BYTECODE, client, args));
 			}
@@ -506,9 +667,9 @@ import org.ethereum.geth.internal.*;
 			// {{.Normalized.Name}} is a free data retrieval call binding the contract method 0x{{printf "%x" .Original.Id}}.
 			//
 			// Solidity: {{.Original.String}}
-			public {{if gt (len .Normalized.Outputs) 1}}{{capitalise .Normalized.Name}}Results{{else}}{{range .Normalized.Outputs}}{{bindtype .Type}}{{end}}{{end}} {{.Normalized.Name}}(CallOpts opts{{range .Normalized.Inputs}}, {{bindtype .Type}} {{.Name}}{{end}}) throws Exception {
+			public {{if gt (len .Normalized.Outputs) 1}}{{capitalise .Normalized.Name}}Results{{else}}{{range .Normalized.Outputs}}{{bindtype .Type}}{{else}}void{{end}}{{end}} {{.Normalized.Name}}(CallOpts opts{{range .Normalized.Inputs}}, {{bindtype .Type}} {{.Name}}{{end}}) throws Exception {
 				Interfaces args = Geth.newInterfaces({{(len .Normalized.Inputs)}});
-				{{range $index, $item := .Normalized.Inputs}}args.set({{$index}}, Geth.newInterface()); args.get({{$index}}).set{{namedtype (bindtype .Type) .Type}}({{.Name}});
+				{{range $index, $item := .Normalized.Inputs}}args.set({{$index}}, Geth.newInterface()); args.get({{$index}}).set{{namedtype (bindtype .Type) .Type}}({{wrapjava .Type .Name}});
 				{{end}}
 
 				Interfaces results = Geth.newInterfaces({{(len .Normalized.Outputs)}});
@@ -521,10 +682,10 @@ import org.ethereum.geth.internal.*;
 				this.Contract.call(opts, results, "{{.Original.Name}}", args);
 				{{if gt (len .Normalized.Outputs) 1}}
 					{{capitalise .Normalized.Name}}Results result = new {{capitalise .Normalized.Name}}Results();
-					{{range $index, $item := .Normalized.Outputs}}result.{{if ne .Name ""}}{{.Name}}{{else}}Return{{$index}}{{end}} = results.get({{$index}}).get{{namedtype (bindtype .Type) .Type}}();
+					{{range $index, $item := .Normalized.Outputs}}result.{{if ne .Name ""}}{{.Name}}{{else}}Return{{$index}}{{end}} = {{unwrapjava .Type (printf "results.get(%d)" $index)}};
 					{{end}}
 					return result;
-				{{else}}{{range .Normalized.Outputs}}return results.get(0).get{{namedtype (bindtype .Type) .Type}}();{{end}}
+				{{else}}{{range .Normalized.Outputs}}return {{unwrapjava .Type "results.get(0)"}};{{end}}
 				{{end}}
 			}
 		{{end}}
@@ -535,7 +696,7 @@ import org.ethereum.geth.internal.*;
 			// Solidity: {{.Original.String}}
 			public Transaction {{.Normalized.Name}}(TransactOpts opts{{range .Normalized.Inputs}}, {{bindtype .Type}} {{.Name}}{{end}}) throws Exception {
 				Interfaces args = Geth.newInterfaces({{(len .Normalized.Inputs)}});
-				{{range $index, $item := .Normalized.Inputs}}args.set({{$index}}, Geth.newInterface()); args.get({{$index}}).set{{namedtype (bindtype .Type) .Type}}({{.Name}});
+				{{range $index, $item := .Normalized.Inputs}}args.set({{$index}}, Geth.newInterface()); args.get({{$index}}).set{{namedtype (bindtype .Type) .Type}}({{wrapjava .Type .Name}});
 				{{end}}
 
 				return this.Contract.transact(opts, "{{.Original.Name}}"	, args);
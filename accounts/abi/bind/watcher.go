@@ -0,0 +1,374 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// errWatcherStopped is returned internally when the watcher is torn down while
+// waiting to deliver a log.
+var errWatcherStopped = errors.New("log watcher stopped")
+
+// LogCheckpoint is the position up to which a LogWatcher delivered logs: all the
+// logs of the block with the given number and hash, and of its ancestors.
+type LogCheckpoint struct {
+	Number uint64      `json:"number"`
+	Hash   common.Hash `json:"hash"`
+}
+
+// CheckpointStore persists the progress of a LogWatcher across restarts.
+type CheckpointStore interface {
+	// LoadCheckpoint returns the last stored checkpoint, or nil if there's none.
+	LoadCheckpoint() (*LogCheckpoint, error)
+
+	// StoreCheckpoint persists a new checkpoint, replacing the previous one.
+	StoreCheckpoint(cp *LogCheckpoint) error
+}
+
+// MemoryCheckpointStore is a CheckpointStore keeping the checkpoint in memory,
+// useful for watchers that only need to survive resubscriptions.
+type MemoryCheckpointStore struct {
+	cp   *LogCheckpoint
+	lock sync.Mutex
+}
+
+// LoadCheckpoint implements CheckpointStore, returning the last stored checkpoint.
+func (s *MemoryCheckpointStore) LoadCheckpoint() (*LogCheckpoint, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.cp == nil {
+		return nil, nil
+	}
+	cp := *s.cp
+	return &cp, nil
+}
+
+// StoreCheckpoint implements CheckpointStore, replacing the stored checkpoint.
+func (s *MemoryCheckpointStore) StoreCheckpoint(cp *LogCheckpoint) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stored := *cp
+	s.cp = &stored
+	return nil
+}
+
+// FileCheckpointStore is a CheckpointStore keeping the checkpoint JSON encoded
+// in a file. Updates are written to a temporary file first and moved in place,
+// so a crash never leaves a corrupted checkpoint behind.
+type FileCheckpointStore struct {
+	path string
+}
+
+// NewFileCheckpointStore creates a checkpoint store backed by the given file.
+// The file doesn't need to exist until the first checkpoint is stored.
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{path: path}
+}
+
+// LoadCheckpoint implements CheckpointStore, reading the checkpoint file.
+func (s *FileCheckpointStore) LoadCheckpoint() (*LogCheckpoint, error) {
+	blob, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cp := new(LogCheckpoint)
+	if err := json.Unmarshal(blob, cp); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint file %s: %v", s.path, err)
+	}
+	return cp, nil
+}
+
+// StoreCheckpoint implements CheckpointStore, atomically replacing the checkpoint
+// file.
+func (s *FileCheckpointStore) StoreCheckpoint(cp *LogCheckpoint) error {
+	blob, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(s.path), "."+filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(blob); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
+
+// LogWatcher streams the logs matching a filter query, resuming from where it
+// left off on a previous run. Progress is persisted in a CheckpointStore as the
+// last block whose logs were all delivered, so logs are delivered at least once:
+// after a crash, the logs of the blocks following the checkpoint are repeated.
+//
+// Chain reorganisations are reported by delivering the logs of the blocks that
+// were reorged out with their Removed flag set, both while streaming and for
+// reorgs that happened while the watcher wasn't running.
+type LogWatcher struct {
+	backend LogWatcherBackend
+	query   ethereum.FilterQuery
+	store   CheckpointStore
+}
+
+// NewLogWatcher creates a resumable watcher for the logs matching the addresses
+// and topics of the query. The block range of the query is ignored, it is managed
+// by the watcher itself.
+func NewLogWatcher(backend LogWatcherBackend, query ethereum.FilterQuery, store CheckpointStore) *LogWatcher {
+	query.FromBlock, query.ToBlock, query.BlockHash = nil, nil, nil
+	query.Limit, query.Cursor = 0, nil
+
+	return &LogWatcher{
+		backend: backend,
+		query:   query,
+		store:   store,
+	}
+}
+
+// Watch starts streaming logs into sink, returning a subscription that can be
+// used to tear down the watcher. If the store holds no checkpoint yet, the logs
+// are streamed from block opts.Start on (or from genesis if unset), otherwise
+// from the block following the checkpoint.
+func (w *LogWatcher) Watch(opts *WatchOpts, sink chan<- types.Log) (event.Subscription, error) {
+	// Don't crash on a lazy user
+	if opts == nil {
+		opts = new(WatchOpts)
+	}
+	ctx := ensureContext(opts.Context)
+
+	// Subscribe before looking at the past, so no logs fall into the gap between
+	// the historical and the live ones. Logs seen in both are delivered once.
+	head, err := w.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	logs := make(chan types.Log, 128)
+	sub, err := w.backend.SubscribeFilterLogs(ctx, w.query, logs)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+
+		run := &logWatch{
+			LogWatcher: w,
+			ctx:        ctx,
+			sink:       sink,
+			quit:       quit,
+			seen:       make(map[logKey]struct{}),
+		}
+		if err := run.loop(opts.Start, head.Number.Uint64(), sub, logs); err != errWatcherStopped {
+			return err
+		}
+		return nil
+	}), nil
+}
+
+// logKey identifies a log within a block for deduplication.
+type logKey struct {
+	block common.Hash
+	index uint
+}
+
+// logWatch is the state of a single run of a LogWatcher.
+type logWatch struct {
+	*LogWatcher
+
+	ctx  context.Context
+	sink chan<- types.Log
+	quit <-chan struct{}
+
+	checkpoint *LogCheckpoint      // Last block with all logs delivered
+	pending    *LogCheckpoint      // Block of the last delivered log, possibly incomplete
+	seen       map[logKey]struct{} // Historical logs which the subscription may repeat
+}
+
+// loop resumes from the stored checkpoint, delivers the historical logs up to
+// the current head and then streams the live ones until torn down.
+func (w *logWatch) loop(start *uint64, subscribed uint64, sub ethereum.Subscription, logs <-chan types.Log) error {
+	cp, err := w.store.LoadCheckpoint()
+	if err != nil {
+		return err
+	}
+	if cp != nil {
+		if err := w.resume(cp); err != nil {
+			return err
+		}
+	}
+	// Deliver all the logs between the checkpoint and the current head
+	head, err := w.backend.HeaderByNumber(w.ctx, nil)
+	if err != nil {
+		return err
+	}
+	var from uint64
+	switch {
+	case w.checkpoint != nil:
+		from = w.checkpoint.Number + 1
+	case start != nil:
+		from = *start
+	}
+	if number := head.Number.Uint64(); from <= number {
+		query := w.query
+		query.FromBlock, query.ToBlock = new(big.Int).SetUint64(from), head.Number
+
+		past, err := w.backend.FilterLogs(w.ctx, query)
+		if err != nil {
+			return err
+		}
+		for _, log := range past {
+			if log.BlockNumber > subscribed {
+				w.seen[logKey{log.BlockHash, log.Index}] = struct{}{}
+			}
+			if err := w.deliver(log); err != nil {
+				return err
+			}
+		}
+		w.pending = nil
+		if err := w.commit(&LogCheckpoint{Number: number, Hash: head.Hash()}); err != nil {
+			return err
+		}
+	}
+	// Stream the live logs, skipping the ones already delivered above
+	for {
+		select {
+		case log := <-logs:
+			if err := w.process(log); err != nil {
+				return err
+			}
+		case err := <-sub.Err():
+			return err
+		case <-w.quit:
+			return nil
+		}
+	}
+}
+
+// resume checks whether the checkpoint is still part of the canonical chain,
+// retracting the logs of the blocks reorged out while the watcher wasn't running
+// until a canonical ancestor is found.
+func (w *logWatch) resume(cp *LogCheckpoint) error {
+	for {
+		header, err := w.backend.HeaderByNumber(w.ctx, new(big.Int).SetUint64(cp.Number))
+		if err != nil && err != ethereum.NotFound {
+			return err
+		}
+		if header != nil && header.Hash() == cp.Hash {
+			w.checkpoint = cp
+			return nil
+		}
+		// The checkpoint was reorged out, retract its logs in reverse order
+		orphan, err := w.backend.HeaderByHash(w.ctx, cp.Hash)
+		if err != nil {
+			return fmt.Errorf("reorged checkpoint block #%d [%x…] unavailable: %v", cp.Number, cp.Hash[:4], err)
+		}
+		query := w.query
+		query.BlockHash = &cp.Hash
+
+		logs, err := w.backend.FilterLogs(w.ctx, query)
+		if err != nil {
+			return err
+		}
+		for i := len(logs) - 1; i >= 0; i-- {
+			log := logs[i]
+			log.Removed = true
+			if err := w.deliver(log); err != nil {
+				return err
+			}
+		}
+		cp = &LogCheckpoint{Number: cp.Number - 1, Hash: orphan.ParentHash}
+		if err := w.commit(cp); err != nil {
+			return err
+		}
+	}
+}
+
+// process handles a log arriving from the live subscription.
+func (w *logWatch) process(log types.Log) error {
+	key := logKey{log.BlockHash, log.Index}
+
+	if log.Removed {
+		delete(w.seen, key)
+		if err := w.deliver(log); err != nil {
+			return err
+		}
+		if w.pending != nil && w.pending.Hash == log.BlockHash {
+			w.pending = nil
+		}
+		// Rewind the checkpoint to before the block reorged out
+		if w.checkpoint != nil && log.BlockNumber <= w.checkpoint.Number {
+			header, err := w.backend.HeaderByHash(w.ctx, log.BlockHash)
+			if err != nil {
+				return err
+			}
+			return w.commit(&LogCheckpoint{Number: log.BlockNumber - 1, Hash: header.ParentHash})
+		}
+		return nil
+	}
+	if _, ok := w.seen[key]; ok {
+		delete(w.seen, key)
+		return nil
+	}
+	return w.deliver(log)
+}
+
+// deliver forwards a log to the sink. The first log of a new block means all the
+// logs of the previous one were delivered, so that block becomes the checkpoint.
+func (w *logWatch) deliver(log types.Log) error {
+	if !log.Removed {
+		if w.pending != nil && w.pending.Hash != log.BlockHash {
+			if err := w.commit(w.pending); err != nil {
+				return err
+			}
+		}
+		w.pending = &LogCheckpoint{Number: log.BlockNumber, Hash: log.BlockHash}
+	}
+	select {
+	case w.sink <- log:
+		return nil
+	case <-w.quit:
+		return errWatcherStopped
+	}
+}
+
+// commit persists a new checkpoint.
+func (w *logWatch) commit(cp *LogCheckpoint) error {
+	w.checkpoint = cp
+	return w.store.StoreCheckpoint(cp)
+}
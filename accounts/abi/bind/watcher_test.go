@@ -0,0 +1,248 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind_test
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// mockChain is a LogWatcherBackend serving a hand crafted chain, where each
+// block carries a single log tagged with the block's salt.
+type mockChain struct {
+	headers map[common.Hash]*types.Header
+	logs    map[common.Hash][]types.Log
+	canon   []*types.Header
+	feed    event.Feed
+	lock    sync.Mutex
+}
+
+func newMockChain() *mockChain {
+	genesis := &types.Header{Number: new(big.Int)}
+	return &mockChain{
+		headers: map[common.Hash]*types.Header{genesis.Hash(): genesis},
+		logs:    make(map[common.Hash][]types.Log),
+		canon:   []*types.Header{genesis},
+	}
+}
+
+// rewind drops the canonical blocks above number, returning the logs removed.
+func (c *mockChain) rewind(number uint64) []types.Log {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var removed []types.Log
+	for _, header := range c.canon[number+1:] {
+		for _, log := range c.logs[header.Hash()] {
+			log.Removed = true
+			removed = append(removed, log)
+		}
+	}
+	c.canon = c.canon[:number+1]
+	return removed
+}
+
+// extend adds a new block with a single log on top of the canonical chain.
+func (c *mockChain) extend(salt byte) types.Log {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	parent := c.canon[len(c.canon)-1]
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, big.NewInt(1)),
+		Extra:      []byte{salt},
+	}
+	log := types.Log{
+		Data:        []byte{salt},
+		BlockNumber: header.Number.Uint64(),
+		BlockHash:   header.Hash(),
+	}
+	c.headers[header.Hash()] = header
+	c.logs[header.Hash()] = []types.Log{log}
+	c.canon = append(c.canon, header)
+	return log
+}
+
+// announce sends logs to the live subscribers.
+func (c *mockChain) announce(logs ...types.Log) {
+	for _, log := range logs {
+		c.feed.Send(log)
+	}
+}
+
+func (c *mockChain) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if query.BlockHash != nil {
+		return c.logs[*query.BlockHash], nil
+	}
+	var logs []types.Log
+	for n := query.FromBlock.Uint64(); n <= query.ToBlock.Uint64() && n < uint64(len(c.canon)); n++ {
+		logs = append(logs, c.logs[c.canon[n].Hash()]...)
+	}
+	return logs, nil
+}
+
+func (c *mockChain) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return c.feed.Subscribe(ch), nil
+}
+
+func (c *mockChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if number == nil {
+		return c.canon[len(c.canon)-1], nil
+	}
+	if number.Uint64() >= uint64(len(c.canon)) {
+		return nil, ethereum.NotFound
+	}
+	return c.canon[number.Uint64()], nil
+}
+
+func (c *mockChain) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if header, ok := c.headers[hash]; ok {
+		return header, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+// expectLogs waits for the given logs to arrive on the channel, in order.
+func expectLogs(t *testing.T, logs <-chan types.Log, want ...types.Log) {
+	t.Helper()
+
+	for i, log := range want {
+		select {
+		case have := <-logs:
+			if have.BlockHash != log.BlockHash || have.Removed != log.Removed {
+				t.Fatalf("log %d: mismatch: have block %x (removed %v), want block %x (removed %v)", i, have.BlockHash, have.Removed, log.BlockHash, log.Removed)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("log %d: not delivered", i)
+		}
+	}
+	select {
+	case have := <-logs:
+		t.Fatalf("unexpected log delivered: %+v", have)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Tests that a watcher resumes from its persisted checkpoint, retracting the
+// logs of blocks reorged out while it wasn't running.
+func TestLogWatcherResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logwatcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		path    = filepath.Join(dir, "checkpoint.json")
+		chain   = newMockChain()
+		watcher = bind.NewLogWatcher(chain, ethereum.FilterQuery{}, bind.NewFileCheckpointStore(path))
+		logs    = make(chan types.Log, 16)
+	)
+	log1, log2, log3 := chain.extend(1), chain.extend(2), chain.extend(3)
+
+	sub, err := watcher.Watch(nil, logs)
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+	expectLogs(t, logs, log1, log2, log3)
+	sub.Unsubscribe()
+
+	// Reorg out the last two blocks while the watcher is not running
+	chain.rewind(1)
+	alt2, alt3, alt4 := chain.extend(12), chain.extend(13), chain.extend(14)
+
+	// Restart from the persisted checkpoint and check the reorg is reported
+	watcher = bind.NewLogWatcher(chain, ethereum.FilterQuery{}, bind.NewFileCheckpointStore(path))
+	if sub, err = watcher.Watch(nil, logs); err != nil {
+		t.Fatalf("failed to resume watcher: %v", err)
+	}
+	log3.Removed, log2.Removed = true, true
+	expectLogs(t, logs, log3, log2, alt2, alt3, alt4)
+	sub.Unsubscribe()
+
+	cp, err := bind.NewFileCheckpointStore(path).LoadCheckpoint()
+	if err != nil {
+		t.Fatalf("failed to load checkpoint: %v", err)
+	}
+	if want := (bind.LogCheckpoint{Number: 4, Hash: alt4.BlockHash}); cp == nil || *cp != want {
+		t.Fatalf("checkpoint mismatch: have %+v, want %+v", cp, want)
+	}
+}
+
+// Tests that a watcher forwards logs removed by live reorgs and rewinds its
+// checkpoint below the reorged blocks.
+func TestLogWatcherLiveReorg(t *testing.T) {
+	var (
+		chain   = newMockChain()
+		store   = new(bind.MemoryCheckpointStore)
+		watcher = bind.NewLogWatcher(chain, ethereum.FilterQuery{}, store)
+		logs    = make(chan types.Log, 16)
+	)
+	log1 := chain.extend(1)
+
+	sub, err := watcher.Watch(nil, logs)
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+	defer sub.Unsubscribe()
+	expectLogs(t, logs, log1)
+
+	// Stream a new block, then reorg out both blocks
+	log2 := chain.extend(2)
+	chain.announce(log2)
+	expectLogs(t, logs, log2)
+
+	removed := chain.rewind(0)
+	chain.announce(removed[1], removed[0])
+	expectLogs(t, logs, removed[1], removed[0])
+
+	cp, _ := store.LoadCheckpoint()
+	if want := (bind.LogCheckpoint{Number: 0, Hash: chain.canon[0].Hash()}); cp == nil || *cp != want {
+		t.Fatalf("checkpoint mismatch after reorg: have %+v, want %+v", cp, want)
+	}
+	// Stream the new chain and check the checkpoint follows it
+	alt1, alt2 := chain.extend(11), chain.extend(12)
+	chain.announce(alt1, alt2)
+	expectLogs(t, logs, alt1, alt2)
+
+	cp, _ = store.LoadCheckpoint()
+	if want := (bind.LogCheckpoint{Number: 1, Hash: alt1.BlockHash}); cp == nil || *cp != want {
+		t.Fatalf("checkpoint mismatch after new chain: have %+v, want %+v", cp, want)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ParseTopics converts the indexed topic fields into actual log field values,
+// storing them in the fields of the struct pointed to by out. Fields are matched
+// to arguments by their camel-cased names.
+//
+// Note, dynamic types cannot be reconstructed since they get mapped to Keccak256
+// hashes as the topic value!
+func ParseTopics(out interface{}, fields Arguments, topics []common.Hash) error {
+	value := reflect.ValueOf(out)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("abi: ParseTopics(non-struct pointer %T)", out)
+	}
+	return parseTopicsWithSetter(fields, topics, func(arg Argument, reconstr interface{}) error {
+		field := value.Elem().FieldByName(ToCamelCase(arg.Name))
+		if !field.IsValid() {
+			return fmt.Errorf("abi: field %s can't be found in the given value", ToCamelCase(arg.Name))
+		}
+		return set(field, reflect.ValueOf(reconstr))
+	})
+}
+
+// ParseTopicsIntoMap converts the indexed topic field-value pairs into a map,
+// keyed by the argument names.
+func ParseTopicsIntoMap(out map[string]interface{}, fields Arguments, topics []common.Hash) error {
+	return parseTopicsWithSetter(fields, topics, func(arg Argument, reconstr interface{}) error {
+		out[arg.Name] = reconstr
+		return nil
+	})
+}
+
+// parseTopicsWithSetter decodes each of the topics according to the type of the
+// matching indexed argument and hands the result to the setter.
+func parseTopicsWithSetter(fields Arguments, topics []common.Hash, setter func(Argument, interface{}) error) error {
+	// Sanity check that the fields and topics match up
+	if len(fields) != len(topics) {
+		return errors.New("abi: topic/field count mismatch")
+	}
+	for i, arg := range fields {
+		if !arg.Indexed {
+			return errors.New("abi: non-indexed field in topic reconstruction")
+		}
+		reconstr, err := readTopic(arg.Type, topics[i])
+		if err != nil {
+			return err
+		}
+		if err := setter(arg, reconstr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTopic reconstructs the value of an indexed argument from its topic. Values
+// of static types are encoded in place, whereas dynamic types and composites are
+// replaced by the Keccak256 hash of their encoding, which is returned as is.
+func readTopic(t Type, topic common.Hash) (interface{}, error) {
+	switch t.T {
+	case BoolTy:
+		return readBool(topic[:])
+	case IntTy, UintTy:
+		return readInteger(t.T, t.Kind, topic[:]), nil
+	case AddressTy:
+		return common.BytesToAddress(topic[common.HashLength-common.AddressLength:]), nil
+	case HashTy:
+		return topic, nil
+	case FixedBytesTy:
+		return readFixedBytes(t, topic[:])
+	case FunctionTy:
+		return readFunctionType(t, topic[:])
+	case StringTy, BytesTy, SliceTy, ArrayTy, TupleTy:
+		return topic, nil
+	default:
+		return nil, fmt.Errorf("abi: unsupported indexed type: %v", t)
+	}
+}
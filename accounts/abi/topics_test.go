@@ -0,0 +1,160 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Tests that indexed values of all static types are decoded from their topics,
+// and dynamic ones are returned as hashes.
+func TestParseTopics(t *testing.T) {
+	type topicsOut struct {
+		Flag    bool
+		Small   int8
+		Neg     *big.Int
+		Count   uint32
+		Large   *big.Int
+		Owner   common.Address
+		Id      [4]byte
+		Name    common.Hash
+		Values  common.Hash
+		Callee  [24]byte
+		Unnamed uint64
+	}
+	var fields Arguments
+	for _, field := range []struct{ name, typ string }{
+		{"flag", "bool"}, {"small", "int8"}, {"neg", "int24"}, {"count", "uint32"}, {"large", "uint256"},
+		{"owner", "address"}, {"id", "bytes4"}, {"name", "string"}, {"values", "uint8[2]"}, {"callee", "function"},
+		{"unnamed", "uint64"},
+	} {
+		typ, err := NewType(field.typ, nil)
+		if err != nil {
+			t.Fatalf("failed to create type %s: %v", field.typ, err)
+		}
+		fields = append(fields, Argument{Name: field.name, Type: typ, Indexed: true})
+	}
+	topics := []common.Hash{
+		common.HexToHash("0x01"),
+		common.HexToHash("0xfffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff9"),
+		common.HexToHash("0xfffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffc18"),
+		common.HexToHash("0x0314"),
+		common.HexToHash("0x8000000000000000000000000000000000000000000000000000000000000000"),
+		common.HexToHash("0x000000000000000000000000000000000000000000000000000000000000dead"),
+		common.HexToHash("0xcafebabe00000000000000000000000000000000000000000000000000000000"),
+		crypto.Keccak256Hash([]byte("hello")),
+		common.HexToHash("0x1234"),
+		common.HexToHash("0x000000000000000000000000000000000000beefdeadbeef0000000000000000"),
+		common.HexToHash("0x2a"),
+	}
+	want := topicsOut{
+		Flag:    true,
+		Small:   -7,
+		Neg:     big.NewInt(-1000),
+		Count:   0x314,
+		Large:   new(big.Int).Lsh(big.NewInt(1), 255),
+		Owner:   common.HexToAddress("0xdead"),
+		Id:      [4]byte{0xca, 0xfe, 0xba, 0xbe},
+		Name:    crypto.Keccak256Hash([]byte("hello")),
+		Values:  common.HexToHash("0x1234"),
+		Callee:  [24]byte{18: 0xbe, 19: 0xef, 20: 0xde, 21: 0xad, 22: 0xbe, 23: 0xef},
+		Unnamed: 42,
+	}
+	var have topicsOut
+	if err := ParseTopics(&have, fields, topics); err != nil {
+		t.Fatalf("failed to parse topics: %v", err)
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("topic struct mismatch:\nhave %+v\nwant %+v", have, want)
+	}
+	haveMap := make(map[string]interface{})
+	if err := ParseTopicsIntoMap(haveMap, fields, topics); err != nil {
+		t.Fatalf("failed to parse topics into map: %v", err)
+	}
+	if haveMap["small"] != int8(-7) || haveMap["neg"].(*big.Int).Cmp(want.Neg) != 0 || haveMap["id"] != want.Id {
+		t.Errorf("topic map mismatch: have %v", haveMap)
+	}
+	// Malformed topics must be rejected
+	if err := ParseTopics(&have, fields, topics[1:]); err == nil {
+		t.Errorf("topic count mismatch accepted")
+	}
+	topics[0] = common.HexToHash("0x02")
+	if err := ParseTopics(&have, fields, topics); err == nil {
+		t.Errorf("invalid boolean topic accepted")
+	}
+}
+
+// Tests that logs are unpacked from both their data and their topics.
+func TestUnpackLog(t *testing.T) {
+	const definition = `[
+		{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"","type":"int16","indexed":true},{"name":"value","type":"uint256","indexed":false}]},
+		{"type":"event","name":"Anonymous","anonymous":true,"inputs":[{"name":"id","type":"bytes32","indexed":true}]}
+	]`
+	abi, err := JSON(strings.NewReader(definition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := types.Log{
+		Topics: []common.Hash{
+			abi.Events["Transfer"].Id(),
+			common.HexToHash("0x0a"),
+			common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"),
+		},
+		Data: common.LeftPadBytes([]byte{0x04, 0x00}, 32),
+	}
+	var transfer struct {
+		From  common.Address
+		Arg1  int16
+		Value *big.Int
+	}
+	if err := abi.UnpackLog(&transfer, "Transfer", log); err != nil {
+		t.Fatalf("failed to unpack log: %v", err)
+	}
+	if transfer.From != common.HexToAddress("0x0a") || transfer.Arg1 != -1 || transfer.Value.Uint64() != 1024 {
+		t.Errorf("unpacked log mismatch: have %+v", transfer)
+	}
+	values := make(map[string]interface{})
+	if err := abi.UnpackLogIntoMap(values, "Transfer", log); err != nil {
+		t.Fatalf("failed to unpack log into map: %v", err)
+	}
+	if values["from"] != common.HexToAddress("0x0a") || values["arg1"] != int16(-1) || values["value"].(*big.Int).Uint64() != 1024 {
+		t.Errorf("unpacked log map mismatch: have %v", values)
+	}
+	// Anonymous events have no signature topic
+	var anonymous struct{ Id [32]byte }
+	if err := abi.UnpackLog(&anonymous, "Anonymous", types.Log{Topics: []common.Hash{{0x01}}}); err != nil {
+		t.Fatalf("failed to unpack anonymous log: %v", err)
+	}
+	if anonymous.Id != [32]byte{0x01} {
+		t.Errorf("unpacked anonymous log mismatch: have %x", anonymous.Id)
+	}
+	// Logs of other events must be rejected
+	log.Topics[0] = common.Hash{}
+	if err := abi.UnpackLog(&transfer, "Transfer", log); err == nil {
+		t.Errorf("log with mismatching signature accepted")
+	}
+	if err := abi.UnpackLog(&transfer, "Missing", log); err == nil {
+		t.Errorf("log of unknown event accepted")
+	}
+}
@@ -53,6 +53,7 @@ type Type struct {
 	stringKind string // holds the unparsed string for deriving signatures
 
 	// Tuple relative fields
+	TupleRawName  string   // Raw struct name defined in source code, may be empty
 	TupleElems    []*Type  // Type information of all tuple fields
 	TupleRawNames []string // Raw field name of all tuple fields
 }
@@ -62,8 +63,18 @@ var (
 	typeRegex = regexp.MustCompile("([a-zA-Z]+)(([0-9]+)(x([0-9]+))?)?")
 )
 
+// structPrefix is the prefix of the internal type of tuples declared as structs
+// in the contract source, e.g. "struct Foo.Bar".
+const structPrefix = "struct "
+
 // NewType creates a new reflection type of abi type given in t.
 func NewType(t string, components []ArgumentMarshaling) (typ Type, err error) {
+	return newType(t, "", components)
+}
+
+// newType creates a new reflection type of abi type given in t, using the
+// compiler reported internal type, if any, to retain the source name of tuples.
+func newType(t string, internalType string, components []ArgumentMarshaling) (typ Type, err error) {
 	// check that array brackets are equal if they exist
 	if strings.Count(t, "[") != strings.Count(t, "]") {
 		return Type{}, fmt.Errorf("invalid arg type in abi")
@@ -75,8 +86,12 @@ func NewType(t string, components []ArgumentMarshaling) (typ Type, err error) {
 	// recursively create the type
 	if strings.Count(t, "[") != 0 {
 		i := strings.LastIndex(t, "[")
-		// recursively embed the type
-		embeddedType, err := NewType(t[:i], components)
+		// recursively embed the type, stripping the same dimension off the internal type
+		embeddedInternal := internalType
+		if j := strings.LastIndex(internalType, "["); j >= 0 && strings.HasSuffix(internalType, "]") {
+			embeddedInternal = internalType[:j]
+		}
+		embeddedType, err := newType(t[:i], embeddedInternal, components)
 		if err != nil {
 			return Type{}, err
 		}
@@ -92,9 +107,7 @@ func NewType(t string, components []ArgumentMarshaling) (typ Type, err error) {
 			typ.Kind = reflect.Slice
 			typ.Elem = &embeddedType
 			typ.Type = reflect.SliceOf(embeddedType.Type)
-			if embeddedType.T == TupleTy {
-				typ.stringKind = embeddedType.stringKind + sliced
-			}
+			typ.stringKind = embeddedType.stringKind + sliced
 		} else if len(intz) == 1 {
 			// is a array
 			typ.T = ArrayTy
@@ -105,9 +118,7 @@ func NewType(t string, components []ArgumentMarshaling) (typ Type, err error) {
 				return Type{}, fmt.Errorf("abi: error parsing variable size: %v", err)
 			}
 			typ.Type = reflect.ArrayOf(typ.Size, embeddedType.Type)
-			if embeddedType.T == TupleTy {
-				typ.stringKind = embeddedType.stringKind + sliced
-			}
+			typ.stringKind = embeddedType.stringKind + sliced
 		} else {
 			return Type{}, fmt.Errorf("invalid formatting of array type")
 		}
@@ -178,7 +189,7 @@ func NewType(t string, components []ArgumentMarshaling) (typ Type, err error) {
 		)
 		expression += "("
 		for idx, c := range components {
-			cType, err := NewType(c.Type, c.Components)
+			cType, err := newType(c.Type, c.InternalType, c.Components)
 			if err != nil {
 				return Type{}, err
 			}
@@ -203,6 +214,10 @@ func NewType(t string, components []ArgumentMarshaling) (typ Type, err error) {
 		typ.TupleRawNames = names
 		typ.T = TupleTy
 		typ.stringKind = expression
+		if strings.HasPrefix(internalType, structPrefix) {
+			// Foo.Bar is not a valid Go identifier, convert it to FooBar
+			typ.TupleRawName = strings.Replace(internalType[len(structPrefix):], ".", "", -1)
+		}
 	case "function":
 		typ.Kind = reflect.Array
 		typ.T = FunctionTy
@@ -336,8 +351,8 @@ func isDynamicType(t Type) bool {
 // to store the location reference for actual value storage.
 func getTypeSize(t Type) int {
 	if t.T == ArrayTy && !isDynamicType(*t.Elem) {
-		// Recursively calculate type size if it is a nested array
-		if t.Elem.T == ArrayTy {
+		// Recursively calculate type size if it is a nested array or a tuple
+		if t.Elem.T == ArrayTy || t.Elem.T == TupleTy {
 			return t.Size * getTypeSize(*t.Elem)
 		}
 		return t.Size * 32
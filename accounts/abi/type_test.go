@@ -110,6 +110,33 @@ func TestTypeRegexp(t *testing.T) {
 	}
 }
 
+// Tests that the source names of tuples are parsed from the internal types
+// reported by the compiler, including the ones of nested arrays.
+func TestTupleRawName(t *testing.T) {
+	var arg Argument
+	blob := `{"name":"s","type":"tuple[][2]","internalType":"struct Lib.Outer[][2]","components":[
+		{"name":"a","type":"uint256","internalType":"uint256"},
+		{"name":"b","type":"tuple[]","internalType":"struct Inner[]","components":[{"name":"x","type":"uint256","internalType":"uint256"}]},
+		{"name":"c","type":"tuple","components":[{"name":"y","type":"bool"}]}
+	]}`
+	if err := arg.UnmarshalJSON([]byte(blob)); err != nil {
+		t.Fatalf("failed to parse argument: %v", err)
+	}
+	outer := arg.Type.Elem.Elem
+	if outer.T != TupleTy || outer.TupleRawName != "LibOuter" {
+		t.Errorf("outer tuple name mismatch: have %q, want %q", outer.TupleRawName, "LibOuter")
+	}
+	if name := outer.TupleElems[1].Elem.TupleRawName; name != "Inner" {
+		t.Errorf("inner tuple name mismatch: have %q, want %q", name, "Inner")
+	}
+	if name := outer.TupleElems[2].TupleRawName; name != "" {
+		t.Errorf("anonymous tuple name mismatch: have %q, want none", name)
+	}
+	if sig := arg.Type.String(); sig != "(uint256,(uint256)[],(bool))[][2]" {
+		t.Errorf("signature mismatch: have %s", sig)
+	}
+}
+
 func TestTypeCheck(t *testing.T) {
 	for i, test := range []struct {
 		typ        string
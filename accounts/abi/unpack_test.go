@@ -700,7 +700,8 @@ func TestUnmarshal(t *testing.T) {
 	{ "name" : "intArraySingle", "constant" : false, "outputs": [ { "type": "uint256[3]" } ] },
 	{ "name" : "addressSliceSingle", "constant" : false, "outputs": [ { "type": "address[]" } ] },
 	{ "name" : "addressSliceDouble", "constant" : false, "outputs": [ { "name": "a", "type": "address[]" }, { "name": "b", "type": "address[]" } ] },
-	{ "name" : "mixedBytes", "constant" : true, "outputs": [ { "name": "a", "type": "bytes" }, { "name": "b", "type": "bytes32" } ] }]`
+	{ "name" : "mixedBytes", "constant" : true, "outputs": [ { "name": "a", "type": "bytes" }, { "name": "b", "type": "bytes32" } ] },
+	{ "name" : "void", "constant" : true, "outputs": [] }]`
 
 	abi, err := JSON(strings.NewReader(definition))
 	if err != nil {
@@ -808,6 +809,11 @@ func TestUnmarshal(t *testing.T) {
 		t.Error("expected error")
 	}
 
+	// marshal empty output of a method without return values
+	if err = abi.Unpack(nil, "void", nil); err != nil {
+		t.Error(err)
+	}
+
 	// marshal dynamic bytes length 5
 	buff.Reset()
 	buff.Write(common.Hex2Bytes("0000000000000000000000000000000000000000000000000000000000000020"))
@@ -1041,6 +1047,60 @@ func TestUnpackTuple(t *testing.T) {
 	}
 }
 
+// Tests that nested arrays of static and dynamic tuples survive a round trip
+// through packing and unpacking.
+func TestUnpackNestedTupleArrays(t *testing.T) {
+	const definition = `[{"name":"echo","constant":true,"outputs":[
+		{"name":"points","type":"tuple[2][]","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]},
+		{"name":"groups","type":"tuple[][]","components":[{"name":"name","type":"string"},{"name":"members","type":"address[]"}]},
+		{"name":"a","type":"uint256"}
+	]}]`
+	abi, err := JSON(strings.NewReader(definition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type Point struct {
+		X *big.Int
+		Y *big.Int
+	}
+	type Group struct {
+		Name    string
+		Members []common.Address
+	}
+	type Ret struct {
+		Points [][2]Point
+		Groups [][]Group
+		A      *big.Int
+	}
+	want := Ret{
+		Points: [][2]Point{
+			{{big.NewInt(1), big.NewInt(2)}, {big.NewInt(3), big.NewInt(4)}},
+			{{big.NewInt(5), big.NewInt(6)}, {big.NewInt(7), big.NewInt(8)}},
+		},
+		Groups: [][]Group{
+			{{"a", []common.Address{{1}, {2}}}, {"b", []common.Address{}}},
+			{},
+			{{"c", []common.Address{{3}}}},
+		},
+		A: big.NewInt(9),
+	}
+	packed, err := abi.Methods["echo"].Outputs.Pack(want.Points, want.Groups, want.A)
+	if err != nil {
+		t.Fatalf("failed to pack: %v", err)
+	}
+	var have Ret
+	if err := abi.Unpack(&have, "echo", packed); err != nil {
+		t.Fatalf("failed to unpack: %v", err)
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("round trip mismatch:\nhave %+v\nwant %+v", have, want)
+	}
+	// Static tuple arrays are encoded in place, the dynamic data has to follow
+	if offset := new(big.Int).SetBytes(packed[:32]).Int64(); offset != 3*32 {
+		t.Errorf("points offset mismatch: have %d, want %d", offset, 3*32)
+	}
+}
+
 func TestOOMMaliciousInput(t *testing.T) {
 	oomTests := []unpackTest{
 		{
@@ -0,0 +1,257 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package external implements an account backend delegating all signing to an
+// external signer, such as clef, through its account_* JSON-RPC API.
+//
+// The node holding no keys itself, every transaction signed through the backend
+// is subject to the approval of the external signer and its rules.
+package external
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sync"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// BackendType is the reflect type of an external signer backend.
+var BackendType = reflect.TypeOf(&Backend{})
+
+// Scheme is the URL scheme of external signer wallets.
+const Scheme = "extapi"
+
+// errNotSupported is returned for wallet operations the external signer manages
+// on its own, such as opening or deriving accounts.
+var errNotSupported = errors.New("operation not supported on external signers")
+
+// Backend is an account backend consisting of a single external signer.
+type Backend struct {
+	signers []accounts.Wallet
+}
+
+// NewBackend creates an account backend delegating signing to the external
+// signer reachable at endpoint, which can be an IPC path or an HTTP or
+// WebSocket URL.
+func NewBackend(endpoint string) (*Backend, error) {
+	signer, err := NewSigner(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &Backend{signers: []accounts.Wallet{signer}}, nil
+}
+
+// Wallets implements accounts.Backend, returning the external signer.
+func (b *Backend) Wallets() []accounts.Wallet {
+	return b.signers
+}
+
+// Subscribe implements accounts.Backend. The external signer never goes away,
+// so the subscription never delivers any events.
+func (b *Backend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
+// Signer is a wallet backed by an external signer. It does not hold any keys,
+// rather forwards signing requests to the external signer for approval.
+type Signer struct {
+	client   *rpc.Client
+	endpoint string
+
+	cache   []accounts.Account // Accounts last listed by the signer, nil if never listed
+	cacheMu sync.RWMutex
+}
+
+// NewSigner connects to the external signer reachable at endpoint.
+func NewSigner(endpoint string) (*Signer, error) {
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{client: client, endpoint: endpoint}, nil
+}
+
+// URL implements accounts.Wallet, returning the endpoint of the external signer.
+func (s *Signer) URL() accounts.URL {
+	return accounts.URL{Scheme: Scheme, Path: s.endpoint}
+}
+
+// Status implements accounts.Wallet, returning whether the external signer can
+// be reached.
+func (s *Signer) Status() (string, error) {
+	modules, err := s.client.SupportedModules()
+	if err != nil {
+		return "Unreachable", err
+	}
+	if _, ok := modules["account"]; !ok {
+		return "Unsupported", errors.New("external signer does not expose the account API")
+	}
+	return "Online", nil
+}
+
+// Open implements accounts.Wallet, however the external signer manages access to
+// its accounts on its own, so this method will always return an error.
+func (s *Signer) Open(passphrase string) error {
+	return errNotSupported
+}
+
+// Close implements accounts.Wallet. The connection to the external signer is
+// kept open for the lifetime of the node, so this is a noop.
+func (s *Signer) Close() error {
+	return nil
+}
+
+// Accounts implements accounts.Wallet, listing the accounts the external signer
+// allows the node to use.
+func (s *Signer) Accounts() []accounts.Account {
+	var addresses []common.Address
+	if err := s.client.Call(&addresses, "account_list"); err != nil {
+		log.Error("Failed to list accounts of external signer", "url", s.endpoint, "err", err)
+		return nil
+	}
+	accs := make([]accounts.Account, 0, len(addresses))
+	for _, address := range addresses {
+		accs = append(accs, accounts.Account{
+			Address: address,
+			URL:     accounts.URL{Scheme: Scheme, Path: s.endpoint},
+		})
+	}
+	s.cacheMu.Lock()
+	s.cache = accs
+	s.cacheMu.Unlock()
+
+	return accs
+}
+
+// Contains implements accounts.Wallet, returning whether the external signer
+// listed the account. The signer is only queried if it was never listed, as
+// listing accounts may require user approval.
+func (s *Signer) Contains(account accounts.Account) bool {
+	s.cacheMu.RLock()
+	cache := s.cache
+	s.cacheMu.RUnlock()
+
+	if cache == nil {
+		cache = s.Accounts()
+	}
+	for _, acc := range cache {
+		if acc.Address == account.Address && (account.URL == (accounts.URL{}) || account.URL == acc.URL) {
+			return true
+		}
+	}
+	return false
+}
+
+// Derive implements accounts.Wallet, however account derivation is managed by
+// the external signer, so this method will always return an error.
+func (s *Signer) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	return accounts.Account{}, errNotSupported
+}
+
+// SelfDerive implements accounts.Wallet, but is a noop since account derivation
+// is managed by the external signer.
+func (s *Signer) SelfDerive(base accounts.DerivationPath, chain ethereum.ChainStateReader) {
+}
+
+// SignHash implements accounts.Wallet, however the external signer only signs
+// data it can display to the user, never arbitrary hashes, so this method will
+// always return an error.
+func (s *Signer) SignHash(account accounts.Account, hash []byte) ([]byte, error) {
+	return nil, accounts.ErrNotSupported
+}
+
+// sendTxArgs represents the arguments of a transaction signing request, as
+// expected by the account_signTransaction method of the external signer.
+type sendTxArgs struct {
+	From     common.MixedcaseAddress  `json:"from"`
+	To       *common.MixedcaseAddress `json:"to"`
+	Gas      hexutil.Uint64           `json:"gas"`
+	GasPrice hexutil.Big              `json:"gasPrice"`
+	Value    hexutil.Big              `json:"value"`
+	Nonce    hexutil.Uint64           `json:"nonce"`
+	Data     *hexutil.Bytes           `json:"data"`
+}
+
+// SignTx implements accounts.Wallet, sending the transaction to the external
+// signer for approval. It returns either the signed transaction, which the user
+// of the signer may have modified, or a failure if the transaction was denied.
+func (s *Signer) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	args := &sendTxArgs{
+		From:     common.NewMixedcaseAddress(account.Address),
+		Gas:      hexutil.Uint64(tx.Gas()),
+		GasPrice: hexutil.Big(*tx.GasPrice()),
+		Value:    hexutil.Big(*tx.Value()),
+		Nonce:    hexutil.Uint64(tx.Nonce()),
+	}
+	if to := tx.To(); to != nil {
+		mixed := common.NewMixedcaseAddress(*to)
+		args.To = &mixed
+	}
+	if data := tx.Data(); len(data) > 0 {
+		input := hexutil.Bytes(data)
+		args.Data = &input
+	}
+	var res ethapi.SignTransactionResult
+	if err := s.client.Call(&res, "account_signTransaction", args); err != nil {
+		return nil, err
+	}
+	signed := new(types.Transaction)
+	if err := rlp.DecodeBytes(res.Raw, signed); err != nil {
+		return nil, err
+	}
+	// Make sure the transaction was signed by the right account, for the right chain
+	var signer types.Signer = types.HomesteadSigner{}
+	if chainID != nil {
+		signer = types.NewEIP155Signer(chainID)
+	}
+	sender, err := types.Sender(signer, signed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature from external signer: %v", err)
+	}
+	if sender != account.Address {
+		return nil, fmt.Errorf("signer mismatch: expected %s, got %s", account.Address.Hex(), sender.Hex())
+	}
+	return signed, nil
+}
+
+// SignHashWithPassphrase implements accounts.Wallet, however signing arbitrary
+// hashes is not supported by the external signer, so this method will always
+// return an error.
+func (s *Signer) SignHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
+	return s.SignHash(account, hash)
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, sending the transaction to
+// the external signer for approval. Since the external signer authenticates
+// requests on its own, the passphrase is silently ignored.
+func (s *Signer) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return s.SignTx(account, tx, chainID)
+}
@@ -0,0 +1,120 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package external
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core"
+)
+
+// MockSigner is a minimal external signer exposing the account API, approving
+// every transaction sent below a value limit.
+type MockSigner struct {
+	key     *ecdsa.PrivateKey
+	chainID *big.Int
+	limit   *big.Int
+}
+
+func (s *MockSigner) List(ctx context.Context) ([]common.Address, error) {
+	return []common.Address{crypto.PubkeyToAddress(s.key.PublicKey)}, nil
+}
+
+func (s *MockSigner) SignTransaction(ctx context.Context, args core.SendTxArgs) (*ethapi.SignTransactionResult, error) {
+	if args.From.Address() != crypto.PubkeyToAddress(s.key.PublicKey) {
+		return nil, errors.New("unknown account")
+	}
+	if args.Value.ToInt().Cmp(s.limit) > 0 {
+		return nil, errors.New("request denied")
+	}
+	tx := types.NewTransaction(uint64(args.Nonce), args.To.Address(), (*big.Int)(&args.Value), uint64(args.Gas), (*big.Int)(&args.GasPrice), nil)
+	signed, err := types.SignTx(tx, types.NewEIP155Signer(s.chainID), s.key)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := rlp.EncodeToBytes(signed)
+	if err != nil {
+		return nil, err
+	}
+	return &ethapi.SignTransactionResult{Raw: hexutil.Bytes(raw), Tx: signed}, nil
+}
+
+// newTestSigner creates an external signer wallet connected to an in-process
+// test signer.
+func newTestSigner(t *testing.T, service *MockSigner) *Signer {
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", service); err != nil {
+		t.Fatalf("failed to register test signer: %v", err)
+	}
+	return &Signer{client: rpc.DialInProc(server), endpoint: "inproc"}
+}
+
+// Tests that transactions are signed by the external signer, and that denied or
+// badly signed transactions are rejected.
+func TestSignTx(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	chainID := big.NewInt(1)
+
+	signer := newTestSigner(t, &MockSigner{key: key, chainID: chainID, limit: big.NewInt(100)})
+	if status, err := signer.Status(); err != nil || status != "Online" {
+		t.Fatalf("status mismatch: have %q (%v), want %q", status, err, "Online")
+	}
+	accs := signer.Accounts()
+	if len(accs) != 1 || accs[0].Address != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("account mismatch: have %v", accs)
+	}
+	if !signer.Contains(accounts.Account{Address: accs[0].Address}) {
+		t.Errorf("listed account not contained")
+	}
+	to := common.HexToAddress("0x01")
+
+	// Approved transactions are signed by the requested account
+	tx := types.NewTransaction(1, to, big.NewInt(10), 21000, big.NewInt(1), nil)
+	signed, err := signer.SignTx(accs[0], tx, chainID)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if sender, err := types.Sender(types.NewEIP155Signer(chainID), signed); err != nil || sender != accs[0].Address {
+		t.Errorf("sender mismatch: have %x (%v), want %x", sender, err, accs[0].Address)
+	}
+	// Denied transactions are rejected
+	tx = types.NewTransaction(1, to, big.NewInt(1000), 21000, big.NewInt(1), nil)
+	if _, err := signer.SignTx(accs[0], tx, chainID); err == nil {
+		t.Errorf("denied transaction signed")
+	}
+	// Transactions signed for another chain are rejected
+	tx = types.NewTransaction(1, to, big.NewInt(10), 21000, big.NewInt(1), nil)
+	if _, err := signer.SignTx(accs[0], tx, big.NewInt(2)); err == nil {
+		t.Errorf("transaction signed for wrong chain accepted")
+	}
+	// Arbitrary hashes are never signed
+	if _, err := signer.SignHash(accs[0], crypto.Keccak256(nil)); err != accounts.ErrNotSupported {
+		t.Errorf("hash signing error mismatch: have %v, want %v", err, accounts.ErrNotSupported)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hdwallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// masterSecret is the HMAC key used to derive the master key from a seed, as
+// defined by BIP-32.
+var masterSecret = []byte("Bitcoin seed")
+
+// errInvalidChild is returned if a derivation step yields an invalid private
+// key. The probability of this is lower than 1 in 2^127, but BIP-32 mandates
+// handling it nonetheless.
+var errInvalidChild = errors.New("derived key invalid, use the next index")
+
+// extendedKey is a BIP-32 extended private key: a secp256k1 private key along
+// with the chain code needed to derive its children.
+type extendedKey struct {
+	key   []byte // 32 byte private key
+	chain []byte // 32 byte chain code
+}
+
+// newMasterKey derives the root of a BIP-32 key tree from a seed.
+func newMasterKey(seed []byte) (*extendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("invalid seed length: %d", len(seed))
+	}
+	mac := hmac.New(sha512.New, masterSecret)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	key := new(big.Int).SetBytes(sum[:32])
+	if key.Sign() == 0 || key.Cmp(crypto.S256().Params().N) >= 0 {
+		return nil, errors.New("invalid master key")
+	}
+	return &extendedKey{key: sum[:32], chain: sum[32:]}, nil
+}
+
+// child derives the child private key at the given index, indices from 2^31 up
+// denoting hardened derivation.
+func (k *extendedKey) child(index uint32) (*extendedKey, error) {
+	mac := hmac.New(sha512.New, k.chain)
+	if index >= 0x80000000 {
+		mac.Write([]byte{0x00})
+		mac.Write(k.key)
+	} else {
+		priv, err := crypto.ToECDSA(k.key)
+		if err != nil {
+			return nil, err
+		}
+		mac.Write(crypto.CompressPubkey(&priv.PublicKey))
+	}
+	var seq [4]byte
+	binary.BigEndian.PutUint32(seq[:], index)
+	mac.Write(seq[:])
+	sum := mac.Sum(nil)
+
+	n := crypto.S256().Params().N
+	key := new(big.Int).SetBytes(sum[:32])
+	if key.Cmp(n) >= 0 {
+		return nil, errInvalidChild
+	}
+	key.Add(key, new(big.Int).SetBytes(k.key))
+	key.Mod(key, n)
+	if key.Sign() == 0 {
+		return nil, errInvalidChild
+	}
+	return &extendedKey{key: math.PaddedBigBytes(key, 32), chain: sum[32:]}, nil
+}
+
+// derive walks the given derivation path from this key and returns the private
+// key at its end.
+func (k *extendedKey) derive(path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	var err error
+	for _, index := range path {
+		if k, err = k.child(index); err != nil {
+			return nil, err
+		}
+	}
+	return crypto.ToECDSA(k.key)
+}
+
+// zero wipes the key material from memory.
+func (k *extendedKey) zero() {
+	for i := range k.key {
+		k.key[i] = 0
+	}
+	for i := range k.chain {
+		k.chain[i] = 0
+	}
+}
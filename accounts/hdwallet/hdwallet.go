@@ -0,0 +1,311 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package hdwallet implements software hierarchical deterministic wallets.
+//
+// A wallet is created from a BIP-39 mnemonic (and an optional BIP-39 passphrase),
+// its seed being stored encrypted in the "hd" subfolder of the keystore. Once
+// opened with the encryption passphrase, accounts are derived from the seed as
+// defined by BIP-32, the same way USB hardware wallets derive them.
+package hdwallet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/pborman/uuid"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// BackendType is the reflect type of a software HD wallet backend.
+var BackendType = reflect.TypeOf(&Backend{})
+
+// Scheme is the URL scheme of software HD wallets.
+const Scheme = "hd"
+
+// seedDir is the subfolder of the keystore directory the encrypted seeds are
+// stored in. Being a folder, it is ignored by the keystore itself.
+const seedDir = "hd"
+
+// seedVersion is the version of the seed file format.
+const seedVersion = 1
+
+// refreshThrottling is the minimum time between wallet refreshes to avoid
+// reading the seed folder on every wallet listing.
+const refreshThrottling = time.Second
+
+// ErrWalletExists is returned if a mnemonic is imported whose wallet is already
+// known to the backend.
+var ErrWalletExists = errors.New("wallet already exists")
+
+// seedFile is the on-disk representation of a software HD wallet.
+type seedFile struct {
+	Address  common.Address      `json:"address"` // Account at the default derivation path, identifying the seed
+	Crypto   keystore.CryptoJSON `json:"crypto"`  // Encrypted BIP-39 seed
+	Accounts []seedAccount       `json:"accounts"`
+	Id       string              `json:"id"`
+	Version  int                 `json:"version"`
+}
+
+// seedAccount is an account tracked by a software HD wallet, stored along with
+// the seed to make it available without opening the wallet.
+type seedAccount struct {
+	Address common.Address `json:"address"`
+	Path    string         `json:"path"`
+}
+
+// Backend is an account backend managing software HD wallets.
+type Backend struct {
+	dir       string             // Folder the encrypted seeds are stored in
+	kdf       keystore.KDFParams // Key derivation function to encrypt new seeds with
+	wallets   []*wallet          // Wallets currently known, sorted by URL
+	refreshed time.Time          // Time instance when the seed folder was last read
+
+	updates event.Feed              // Wallet feed notifying of arrivals/departures
+	scope   event.SubscriptionScope // Subscription scope tracking current live listeners
+	lock    sync.RWMutex
+}
+
+// NewBackend creates a software HD wallet backend storing its seeds within the
+// given keystore directory, encrypting new ones with the given key derivation
+// function.
+func NewBackend(keydir string, kdf keystore.KDFParams) *Backend {
+	b := &Backend{
+		dir: filepath.Join(keydir, seedDir),
+		kdf: kdf,
+	}
+	b.refreshWallets()
+	return b
+}
+
+// Wallets implements accounts.Backend, returning all the software HD wallets
+// stored in the keystore, sorted by URL.
+func (b *Backend) Wallets() []accounts.Wallet {
+	b.refreshWallets()
+
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	cpy := make([]accounts.Wallet, len(b.wallets))
+	for i, wallet := range b.wallets {
+		cpy[i] = wallet
+	}
+	return cpy
+}
+
+// Subscribe implements accounts.Backend, creating an async subscription to
+// receive notifications on the addition or removal of software HD wallets.
+func (b *Backend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return b.scope.Track(b.updates.Subscribe(sink))
+}
+
+// Close terminates all wallet event subscriptions.
+func (b *Backend) Close() {
+	b.scope.Close()
+}
+
+// refreshWallets reads the seed folder and updates the wallet list with any
+// seeds added or removed since the last refresh.
+func (b *Backend) refreshWallets() {
+	b.lock.RLock()
+	elapsed := time.Since(b.refreshed)
+	b.lock.RUnlock()
+
+	if elapsed < refreshThrottling {
+		return
+	}
+	files, err := ioutil.ReadDir(b.dir)
+	if err != nil && !os.IsNotExist(err) {
+		log.Warn("Failed to list HD wallet seeds", "dir", b.dir, "err", err)
+	}
+	b.lock.Lock()
+	known := make(map[string]*wallet)
+	for _, wallet := range b.wallets {
+		known[wallet.url.Path] = wallet
+	}
+	var (
+		wallets []*wallet
+		events  []accounts.WalletEvent
+	)
+	for _, fi := range files {
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), ".") || strings.HasSuffix(fi.Name(), "~") {
+			continue
+		}
+		path := filepath.Join(b.dir, fi.Name())
+		if wallet, ok := known[path]; ok {
+			wallets = append(wallets, wallet)
+			delete(known, path)
+			continue
+		}
+		seed, err := readSeedFile(path)
+		if err != nil {
+			log.Debug("Failed to decode HD wallet seed", "path", path, "err", err)
+			continue
+		}
+		wallet, err := newWallet(b, path, seed)
+		if err != nil {
+			log.Debug("Failed to load HD wallet", "path", path, "err", err)
+			continue
+		}
+		wallets = append(wallets, wallet)
+		events = append(events, accounts.WalletEvent{Wallet: wallet, Kind: accounts.WalletArrived})
+	}
+	for _, wallet := range known {
+		events = append(events, accounts.WalletEvent{Wallet: wallet, Kind: accounts.WalletDropped})
+	}
+	sort.Slice(wallets, func(i, j int) bool {
+		return wallets[i].url.Cmp(wallets[j].url) < 0
+	})
+	b.wallets, b.refreshed = wallets, time.Now()
+	b.lock.Unlock()
+
+	for _, event := range events {
+		if event.Kind == accounts.WalletDropped {
+			event.Wallet.Close()
+		}
+		b.updates.Send(event)
+	}
+}
+
+// Import creates a new software HD wallet from a BIP-39 mnemonic and optional
+// BIP-39 passphrase, storing the seed encrypted with passphrase. The account at
+// the default derivation path is pinned to the new wallet and returned.
+func (b *Backend) Import(mnemonic, bip39Passphrase, passphrase string) (accounts.Account, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(strings.Join(strings.Fields(mnemonic), " "), bip39Passphrase)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	master, err := newMasterKey(seed)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	defer master.zero()
+
+	path := accounts.DefaultBaseDerivationPath
+	key, err := master.derive(path)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	// Encrypt the seed before taking the lock, the key derivation is slow
+	cryptoStruct, err := keystore.EncryptData(seed, []byte(passphrase), b.kdf)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	file := &seedFile{
+		Address:  address,
+		Crypto:   cryptoStruct,
+		Accounts: []seedAccount{{Address: address, Path: path.String()}},
+		Id:       uuid.NewRandom().String(),
+		Version:  seedVersion,
+	}
+	b.refreshWallets()
+
+	wallet, err := b.storeWallet(file)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	b.updates.Send(accounts.WalletEvent{Wallet: wallet, Kind: accounts.WalletArrived})
+
+	return wallet.accounts[0], nil
+}
+
+// storeWallet writes a new seed file into the seed folder and adds its wallet
+// to the backend, unless a wallet with the same seed is already known.
+func (b *Backend) storeWallet(file *seedFile) (*wallet, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, wallet := range b.wallets {
+		if wallet.seed.Address == file.Address {
+			return nil, ErrWalletExists
+		}
+	}
+	filename := filepath.Join(b.dir, seedFileName(file.Address))
+	if err := writeSeedFile(filename, file); err != nil {
+		return nil, err
+	}
+	wallet, err := newWallet(b, filename, file)
+	if err != nil {
+		return nil, err
+	}
+	b.wallets = append(b.wallets, wallet)
+	sort.Slice(b.wallets, func(i, j int) bool {
+		return b.wallets[i].url.Cmp(b.wallets[j].url) < 0
+	})
+	return wallet, nil
+}
+
+// seedFileName returns the name of the file storing the seed identified by the
+// given address, following the naming of the keystore.
+func seedFileName(address common.Address) string {
+	return fmt.Sprintf("UTC--%s--%x", time.Now().UTC().Format("2006-01-02T15-04-05.000000000Z"), address)
+}
+
+// readSeedFile loads a software HD wallet from disk.
+func readSeedFile(path string) (*seedFile, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed := new(seedFile)
+	if err := json.Unmarshal(blob, seed); err != nil {
+		return nil, err
+	}
+	if seed.Version != seedVersion {
+		return nil, fmt.Errorf("version not supported: %v", seed.Version)
+	}
+	return seed, nil
+}
+
+// writeSeedFile atomically stores a software HD wallet on disk.
+func writeSeedFile(path string, seed *seedFile) error {
+	blob, err := json.Marshal(seed)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// Write into a hidden temporary file first to avoid loading partial seeds
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(blob); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	f.Close()
+	return os.Rename(f.Name(), path)
+}
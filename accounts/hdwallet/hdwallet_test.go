@@ -0,0 +1,272 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hdwallet
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	testAddress  = common.HexToAddress("0x9858EfFD232B4033E47d90003D41EC34EcaEda94") // m/44'/60'/0'/0/0 of testMnemonic
+
+	testKDF = keystore.ScryptKDF(2, 1)
+)
+
+// Tests key derivation against the first test vector of BIP-32.
+func TestBIP32Derivation(t *testing.T) {
+	tests := []struct {
+		path  accounts.DerivationPath
+		key   string
+		chain string
+	}{
+		{
+			path:  accounts.DerivationPath{},
+			key:   "0xe8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35",
+			chain: "0x873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508",
+		},
+		{
+			path:  accounts.DerivationPath{0x80000000},
+			key:   "0xedb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea",
+			chain: "0x47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141",
+		},
+		{
+			path:  accounts.DerivationPath{0x80000000, 1},
+			key:   "0x3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368",
+			chain: "0x2a7857631386ba23dacac34180dd1983734e444fdbf774041578e9b6adb37c19",
+		},
+	}
+	seed := hexutil.MustDecode("0x000102030405060708090a0b0c0d0e0f")
+	for i, tt := range tests {
+		key, err := newMasterKey(seed)
+		if err != nil {
+			t.Fatalf("test %d: failed to create master key: %v", i, err)
+		}
+		for _, index := range tt.path {
+			if key, err = key.child(index); err != nil {
+				t.Fatalf("test %d: failed to derive child %d: %v", i, index, err)
+			}
+		}
+		if have := hexutil.Encode(key.key); have != tt.key {
+			t.Errorf("test %d: key mismatch: have %s, want %s", i, have, tt.key)
+		}
+		if have := hexutil.Encode(key.chain); have != tt.chain {
+			t.Errorf("test %d: chain code mismatch: have %s, want %s", i, have, tt.chain)
+		}
+	}
+}
+
+// Tests that mnemonics can be imported, and the resulting wallets are persisted
+// and reloaded.
+func TestImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hdwallet-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	backend := NewBackend(dir, testKDF)
+	if _, err := backend.Import("abandon abandon abandon", "", "foo"); err == nil {
+		t.Errorf("invalid mnemonic imported")
+	}
+	account, err := backend.Import(testMnemonic, "", "foo")
+	if err != nil {
+		t.Fatalf("failed to import mnemonic: %v", err)
+	}
+	if account.Address != testAddress {
+		t.Errorf("account mismatch: have %x, want %x", account.Address, testAddress)
+	}
+	if _, err := backend.Import(testMnemonic, "", "bar"); err != ErrWalletExists {
+		t.Errorf("duplicate import error mismatch: have %v, want %v", err, ErrWalletExists)
+	}
+	// A different BIP-39 passphrase results in a different wallet
+	other, err := backend.Import(testMnemonic, "TREZOR", "foo")
+	if err != nil {
+		t.Fatalf("failed to import mnemonic with passphrase: %v", err)
+	}
+	if other.Address == testAddress {
+		t.Errorf("BIP-39 passphrase ignored")
+	}
+	// Reload the wallets and check that the pinned accounts are available
+	wallets := NewBackend(dir, testKDF).Wallets()
+	if len(wallets) != 2 {
+		t.Fatalf("wallet count mismatch: have %d, want 2", len(wallets))
+	}
+	for _, wallet := range wallets {
+		accs := wallet.Accounts()
+		if len(accs) != 1 || (accs[0] != account && accs[0] != other) {
+			t.Errorf("wallet %s: account mismatch: have %v", wallet.URL(), accs)
+		}
+	}
+}
+
+// Tests that accounts can be derived and used for signing.
+func TestDeriveSign(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hdwallet-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	backend := NewBackend(dir, testKDF)
+	if _, err := backend.Import(testMnemonic, "", "foo"); err != nil {
+		t.Fatalf("failed to import mnemonic: %v", err)
+	}
+	wallet := backend.Wallets()[0]
+
+	path := accounts.DerivationPath{0x80000000 + 44, 0x80000000 + 60, 0x80000000 + 0, 0, 1}
+	if _, err := wallet.Derive(path, true); err != accounts.ErrWalletClosed {
+		t.Errorf("closed wallet derivation error mismatch: have %v, want %v", err, accounts.ErrWalletClosed)
+	}
+	if err := wallet.Open("bar"); err == nil {
+		t.Errorf("wallet opened with bad passphrase")
+	}
+	if err := wallet.Open("foo"); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	account, err := wallet.Derive(path, true)
+	if err != nil {
+		t.Fatalf("failed to derive account: %v", err)
+	}
+	hash := crypto.Keccak256([]byte("hello"))
+	sig, err := wallet.SignHash(account, hash)
+	if err != nil {
+		t.Fatalf("failed to sign hash: %v", err)
+	}
+	pubkey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer := crypto.PubkeyToAddress(*pubkey); signer != account.Address {
+		t.Errorf("signer mismatch: have %x, want %x", signer, account.Address)
+	}
+	if err := wallet.Close(); err != nil {
+		t.Fatalf("failed to close wallet: %v", err)
+	}
+	if _, err := wallet.SignHash(account, hash); err != accounts.ErrWalletClosed {
+		t.Errorf("closed wallet signing error mismatch: have %v, want %v", err, accounts.ErrWalletClosed)
+	}
+	// Reload the wallet and sign with the derived account without opening it
+	wallet = NewBackend(dir, testKDF).Wallets()[0]
+	if !wallet.Contains(account) {
+		t.Fatalf("derived account not persisted")
+	}
+	chainID := big.NewInt(1)
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	if _, err := wallet.SignTxWithPassphrase(account, "bar", tx, chainID); err == nil {
+		t.Errorf("transaction signed with bad passphrase")
+	}
+	signed, err := wallet.SignTxWithPassphrase(account, "foo", tx, chainID)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if sender, err := types.Sender(types.NewEIP155Signer(chainID), signed); err != nil || sender != account.Address {
+		t.Errorf("sender mismatch: have %x (%v), want %x", sender, err, account.Address)
+	}
+}
+
+// testChain is a chain state reader reporting a balance for a set of accounts.
+type testChain struct {
+	funded map[common.Address]bool
+}
+
+func (c *testChain) BalanceAt(ctx context.Context, account common.Address, number *big.Int) (*big.Int, error) {
+	if c.funded[account] {
+		return big.NewInt(1), nil
+	}
+	return new(big.Int), nil
+}
+
+func (c *testChain) StorageAt(ctx context.Context, account common.Address, key common.Hash, number *big.Int) ([]byte, error) {
+	return nil, nil
+}
+
+func (c *testChain) CodeAt(ctx context.Context, account common.Address, number *big.Int) ([]byte, error) {
+	return nil, nil
+}
+
+func (c *testChain) NonceAt(ctx context.Context, account common.Address, number *big.Int) (uint64, error) {
+	return 0, nil
+}
+
+// Tests that self-derivation discovers all used accounts and the first unused.
+func TestSelfDerive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hdwallet-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	backend := NewBackend(dir, testKDF)
+	if _, err := backend.Import(testMnemonic, "", "foo"); err != nil {
+		t.Fatalf("failed to import mnemonic: %v", err)
+	}
+	wallet := backend.Wallets()[0]
+	if err := wallet.Open("foo"); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	defer wallet.Close()
+
+	// Fund the first three accounts and self-derive
+	var want []accounts.Account
+	chain := &testChain{funded: make(map[common.Address]bool)}
+	for i := 0; i < 4; i++ {
+		path := append(accounts.DerivationPath{}, accounts.DefaultBaseDerivationPath...)
+		path[len(path)-1] = uint32(i)
+
+		account, err := wallet.Derive(path, false)
+		if err != nil {
+			t.Fatalf("failed to derive account %d: %v", i, err)
+		}
+		if i < 3 {
+			chain.funded[account.Address] = true
+		}
+		want = append(want, account)
+	}
+	wallet.SelfDerive(accounts.DefaultBaseDerivationPath, chain)
+
+	// Self-derivation is skipped if the deriver is busy, retry for a while
+	have := wallet.Accounts()
+	for i := 0; i < 50 && len(have) != len(want); i++ {
+		time.Sleep(100 * time.Millisecond)
+		have = wallet.Accounts()
+	}
+	if len(have) != len(want) {
+		t.Fatalf("account count mismatch: have %d, want %d", len(have), len(want))
+	}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Errorf("account %d mismatch: have %v, want %v", i, have[i], want[i])
+		}
+		if !bytes.HasPrefix([]byte(have[i].URL.Path), []byte(wallet.URL().Path)) {
+			t.Errorf("account %d: URL %s outside of wallet %s", i, have[i].URL, wallet.URL())
+		}
+	}
+}
@@ -0,0 +1,527 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hdwallet
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Minimum time to wait between self derivation attempts, even it the user is
+// requesting accounts like crazy.
+const selfDeriveThrottling = time.Second
+
+// wallet is a software HD wallet backed by an encrypted BIP-39 seed.
+type wallet struct {
+	backend *Backend     // Backend the wallet belongs to, for event notifications
+	url     accounts.URL // Textual URL uniquely identifying this wallet
+	seed    *seedFile    // On-disk representation of the wallet
+
+	master   *extendedKey                               // BIP-32 master key while the wallet is open
+	accounts []accounts.Account                         // List of derived accounts pinned to the wallet
+	paths    map[common.Address]accounts.DerivationPath // Known derivation paths for signing operations
+
+	deriveNextPath accounts.DerivationPath   // Next derivation path for account auto-discovery
+	deriveNextAddr common.Address            // Next derived account address for auto-discovery
+	deriveChain    ethereum.ChainStateReader // Blockchain state reader to discover used account with
+	deriveReq      chan chan struct{}        // Channel to request a self-derivation on
+	deriveQuit     chan chan error           // Channel to terminate the self-deriver with
+
+	stateLock sync.RWMutex // Protects read and write access to the wallet struct fields
+
+	log log.Logger // Contextual logger to tag the wallet with its file
+}
+
+// newWallet creates a closed wallet from its on-disk representation, tracking
+// the accounts stored along with the seed.
+func newWallet(backend *Backend, path string, seed *seedFile) (*wallet, error) {
+	w := &wallet{
+		backend: backend,
+		url:     accounts.URL{Scheme: Scheme, Path: path},
+		seed:    seed,
+		paths:   make(map[common.Address]accounts.DerivationPath),
+		log:     log.New("url", accounts.URL{Scheme: Scheme, Path: path}),
+	}
+	for _, account := range seed.Accounts {
+		path, err := accounts.ParseDerivationPath(account.Path)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := w.paths[account.Address]; !ok {
+			w.accounts = append(w.accounts, w.account(account.Address, path))
+			w.paths[account.Address] = path
+		}
+	}
+	return w, nil
+}
+
+// account assembles the account located at the given derivation path.
+func (w *wallet) account(address common.Address, path accounts.DerivationPath) accounts.Account {
+	return accounts.Account{
+		Address: address,
+		URL:     accounts.URL{Scheme: w.url.Scheme, Path: fmt.Sprintf("%s/%s", w.url.Path, path)},
+	}
+}
+
+// URL implements accounts.Wallet, returning the URL of the seed file.
+func (w *wallet) URL() accounts.URL {
+	return w.url // Immutable, no need for a lock
+}
+
+// Status implements accounts.Wallet, returning whether the seed of the wallet
+// is currently decrypted.
+func (w *wallet) Status() (string, error) {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	if w.master == nil {
+		return "Closed", nil
+	}
+	return "Open", nil
+}
+
+// Open implements accounts.Wallet, decrypting the seed of the wallet to allow
+// deriving and signing with its accounts.
+func (w *wallet) Open(passphrase string) error {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	if w.master != nil {
+		return accounts.ErrWalletAlreadyOpen
+	}
+	master, err := w.decrypt(passphrase)
+	if err != nil {
+		return err
+	}
+	w.master = master
+
+	w.deriveReq = make(chan chan struct{})
+	w.deriveQuit = make(chan chan error)
+
+	go w.selfDerive()
+
+	// Notify anyone listening for wallet events that the wallet is accessible
+	go w.backend.updates.Send(accounts.WalletEvent{Wallet: w, Kind: accounts.WalletOpened})
+
+	return nil
+}
+
+// decrypt decrypts the seed of the wallet and derives the BIP-32 master key.
+func (w *wallet) decrypt(passphrase string) (*extendedKey, error) {
+	seed, err := keystore.DecryptDataV3(w.seed.Crypto, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for i := range seed {
+			seed[i] = 0
+		}
+	}()
+	master, err := newMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	// Make sure the seed is indeed the one the wallet was created with
+	key, err := master.derive(accounts.DefaultBaseDerivationPath)
+	if err != nil {
+		master.zero()
+		return nil, err
+	}
+	defer zeroKey(key)
+
+	if address := crypto.PubkeyToAddress(key.PublicKey); address != w.seed.Address {
+		master.zero()
+		return nil, fmt.Errorf("seed mismatch: have %x, want %x", address, w.seed.Address)
+	}
+	return master, nil
+}
+
+// Close implements accounts.Wallet, wiping the decrypted seed from memory.
+func (w *wallet) Close() error {
+	w.stateLock.RLock()
+	dQuit := w.deriveQuit
+	w.stateLock.RUnlock()
+
+	// Terminate the self-derivations
+	var derr error
+	if dQuit != nil {
+		errc := make(chan error)
+		dQuit <- errc
+		derr = <-errc
+	}
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	w.deriveQuit = nil
+	w.deriveReq = nil
+
+	if w.master != nil {
+		w.master.zero()
+		w.master = nil
+	}
+	return derr
+}
+
+// Accounts implements accounts.Wallet, returning the list of accounts pinned to
+// the wallet. If self-derivation was enabled, the account list is periodically
+// expanded based on current chain state.
+func (w *wallet) Accounts() []accounts.Account {
+	// Attempt self-derivation if it's running
+	w.stateLock.RLock()
+	deriveReq := w.deriveReq
+	w.stateLock.RUnlock()
+
+	reqc := make(chan struct{}, 1)
+	select {
+	case deriveReq <- reqc:
+		// Self-derivation request accepted, wait for it
+		<-reqc
+	default:
+		// Self-derivation offline, throttled or busy, skip
+	}
+	// Return whatever account list we ended up with
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	cpy := make([]accounts.Account, len(w.accounts))
+	copy(cpy, w.accounts)
+	return cpy
+}
+
+// selfDerive is an account derivation loop that upon request attempts to find
+// new non-zero accounts.
+func (w *wallet) selfDerive() {
+	w.log.Debug("HD wallet self-derivation started")
+	defer w.log.Debug("HD wallet self-derivation stopped")
+
+	// Execute self-derivations until termination or error
+	var (
+		reqc chan struct{}
+		errc chan error
+		err  error
+	)
+	for errc == nil && err == nil {
+		// Wait until either derivation or termination is requested
+		select {
+		case errc = <-w.deriveQuit:
+			// Termination requested
+			continue
+		case reqc = <-w.deriveReq:
+			// Account discovery requested
+		}
+		// Derivation needs a chain and the seed, skip if either unavailable
+		w.stateLock.RLock()
+		if w.master == nil || w.deriveChain == nil {
+			w.stateLock.RUnlock()
+			reqc <- struct{}{}
+			continue
+		}
+		// Derive the next batch of accounts
+		var (
+			accs  []accounts.Account
+			paths []accounts.DerivationPath
+
+			nextAddr = w.deriveNextAddr
+			nextPath = w.deriveNextPath
+
+			context = context.Background()
+		)
+		for empty := false; !empty; {
+			// Retrieve the next derived Ethereum account
+			if nextAddr == (common.Address{}) {
+				var key *ecdsa.PrivateKey
+				if key, err = w.master.derive(nextPath); err != nil {
+					w.log.Warn("HD wallet account derivation failed", "err", err)
+					break
+				}
+				nextAddr = crypto.PubkeyToAddress(key.PublicKey)
+				zeroKey(key)
+			}
+			// Check the account's status against the current chain state
+			var (
+				balance *big.Int
+				nonce   uint64
+			)
+			balance, err = w.deriveChain.BalanceAt(context, nextAddr, nil)
+			if err != nil {
+				w.log.Warn("HD wallet balance retrieval failed", "err", err)
+				break
+			}
+			nonce, err = w.deriveChain.NonceAt(context, nextAddr, nil)
+			if err != nil {
+				w.log.Warn("HD wallet nonce retrieval failed", "err", err)
+				break
+			}
+			// If the next account is empty, stop self-derivation, but add it nonetheless
+			if balance.Sign() == 0 && nonce == 0 {
+				empty = true
+			}
+			// We've just self-derived a new account, start tracking it locally
+			path := make(accounts.DerivationPath, len(nextPath))
+			copy(path[:], nextPath[:])
+			paths = append(paths, path)
+
+			accs = append(accs, w.account(nextAddr, path))
+
+			// Display a log message to the user for new (or previously empty accounts)
+			if _, known := w.paths[nextAddr]; !known || (!empty && nextAddr == w.deriveNextAddr) {
+				w.log.Info("HD wallet discovered new account", "address", nextAddr, "path", path, "balance", balance, "nonce", nonce)
+			}
+			// Fetch the next potential account
+			if !empty {
+				nextAddr = common.Address{}
+				nextPath[len(nextPath)-1]++
+			}
+		}
+		w.stateLock.RUnlock()
+
+		// Insert any accounts successfully derived
+		w.stateLock.Lock()
+		var added bool
+		for i := 0; i < len(accs); i++ {
+			if _, ok := w.paths[accs[i].Address]; !ok {
+				w.accounts = append(w.accounts, accs[i])
+				w.paths[accs[i].Address] = paths[i]
+				added = true
+			}
+		}
+		if added {
+			w.persist()
+		}
+		// Shift the self-derivation forward
+		w.deriveNextAddr = nextAddr
+		w.deriveNextPath = nextPath
+		w.stateLock.Unlock()
+
+		// Notify the user of termination and loop after a bit of time (to avoid trashing)
+		reqc <- struct{}{}
+		if err == nil {
+			select {
+			case errc = <-w.deriveQuit:
+				// Termination requested, abort
+			case <-time.After(selfDeriveThrottling):
+				// Waited enough, willing to self-derive again
+			}
+		}
+	}
+	// In case of error, wait for termination
+	if err != nil {
+		w.log.Debug("HD wallet self-derivation failed", "err", err)
+		errc = <-w.deriveQuit
+	}
+	errc <- err
+}
+
+// persist stores the accounts tracked by the wallet along with its seed, so they
+// are available without opening the wallet after a restart.
+//
+// Note, persist assumes the state lock is held!
+func (w *wallet) persist() {
+	w.seed.Accounts = make([]seedAccount, len(w.accounts))
+	for i, account := range w.accounts {
+		w.seed.Accounts[i] = seedAccount{Address: account.Address, Path: w.paths[account.Address].String()}
+	}
+	if err := writeSeedFile(w.url.Path, w.seed); err != nil {
+		w.log.Warn("Failed to store HD wallet accounts", "err", err)
+	}
+}
+
+// Contains implements accounts.Wallet, returning whether a particular account is
+// or is not pinned into this wallet instance.
+func (w *wallet) Contains(account accounts.Account) bool {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	_, exists := w.paths[account.Address]
+	return exists
+}
+
+// Derive implements accounts.Wallet, deriving a new account at the specific
+// derivation path. If pin is set to true, the account will be added to the list
+// of tracked accounts.
+func (w *wallet) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	w.stateLock.RLock()
+	if w.master == nil {
+		w.stateLock.RUnlock()
+		return accounts.Account{}, accounts.ErrWalletClosed
+	}
+	key, err := w.master.derive(path)
+	w.stateLock.RUnlock()
+
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	zeroKey(key)
+
+	account := w.account(address, path)
+	if !pin {
+		return account, nil
+	}
+	// Pinning needs to modify the state
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	if _, ok := w.paths[address]; !ok {
+		w.accounts = append(w.accounts, account)
+		w.paths[address] = make(accounts.DerivationPath, len(path))
+		copy(w.paths[address], path)
+		w.persist()
+	}
+	return account, nil
+}
+
+// SelfDerive implements accounts.Wallet, trying to discover accounts that the
+// user used previously (based on the chain state), but ones that he/she did not
+// explicitly pin to the wallet manually. To avoid chain head monitoring, self
+// derivation only runs during account listing (and even then throttled).
+func (w *wallet) SelfDerive(base accounts.DerivationPath, chain ethereum.ChainStateReader) {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	w.deriveNextPath = make(accounts.DerivationPath, len(base))
+	copy(w.deriveNextPath[:], base[:])
+
+	w.deriveNextAddr = common.Address{}
+	w.deriveChain = chain
+}
+
+// signingKey derives the private key of a tracked account from the given master
+// key.
+//
+// Note, signingKey assumes the state lock is held!
+func (w *wallet) signingKey(master *extendedKey, account accounts.Account) (*ecdsa.PrivateKey, error) {
+	path, ok := w.paths[account.Address]
+	if !ok {
+		return nil, accounts.ErrUnknownAccount
+	}
+	key, err := master.derive(path)
+	if err != nil {
+		return nil, err
+	}
+	if crypto.PubkeyToAddress(key.PublicKey) != account.Address {
+		zeroKey(key)
+		return nil, errors.New("derived key does not match account")
+	}
+	return key, nil
+}
+
+// openKey derives the private key of a tracked account from the master key of
+// the open wallet.
+func (w *wallet) openKey(account accounts.Account) (*ecdsa.PrivateKey, error) {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	if w.master == nil {
+		return nil, accounts.ErrWalletClosed
+	}
+	return w.signingKey(w.master, account)
+}
+
+// passphraseKey derives the private key of a tracked account from the seed
+// decrypted with the given passphrase.
+func (w *wallet) passphraseKey(account accounts.Account, passphrase string) (*ecdsa.PrivateKey, error) {
+	master, err := w.decrypt(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer master.zero()
+
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	return w.signingKey(master, account)
+}
+
+// SignHash implements accounts.Wallet, signing the given hash with the requested
+// account if the wallet is open. The produced signature is in the [R || S || V]
+// format where V is 0 or 1.
+func (w *wallet) SignHash(account accounts.Account, hash []byte) ([]byte, error) {
+	key, err := w.openKey(account)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+
+	return crypto.Sign(hash, key)
+}
+
+// SignTx implements accounts.Wallet, signing the given transaction with the
+// requested account if the wallet is open.
+func (w *wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	key, err := w.openKey(account)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+
+	return signTx(tx, chainID, key)
+}
+
+// SignHashWithPassphrase implements accounts.Wallet, attempting to sign the
+// given hash with the given account using the passphrase to decrypt the seed.
+func (w *wallet) SignHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
+	key, err := w.passphraseKey(account, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+
+	return crypto.Sign(hash, key)
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, attempting to sign the given
+// transaction with the given account using the passphrase to decrypt the seed.
+func (w *wallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	key, err := w.passphraseKey(account, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+
+	return signTx(tx, chainID, key)
+}
+
+// signTx signs a transaction with EIP155 or homestead rules, depending on the
+// presence of the chain ID.
+func signTx(tx *types.Transaction, chainID *big.Int, key *ecdsa.PrivateKey) (*types.Transaction, error) {
+	if chainID != nil {
+		return types.SignTx(tx, types.NewEIP155Signer(chainID), key)
+	}
+	return types.SignTx(tx, types.HomesteadSigner{}, key)
+}
+
+// zeroKey zeroes a private key in memory.
+func zeroKey(k *ecdsa.PrivateKey) {
+	b := k.D.Bits()
+	for i := range b {
+		b[i] = 0
+	}
+}
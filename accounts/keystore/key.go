@@ -36,7 +36,8 @@ import (
 )
 
 const (
-	version = 3
+	version         = 3
+	versionArgon2id = 4 // Version of key files encrypted with argon2id
 )
 
 type Key struct {
@@ -77,8 +77,14 @@ type unlocked struct {
 
 // NewKeyStore creates a keystore for the given directory.
 func NewKeyStore(keydir string, scryptN, scryptP int) *KeyStore {
+	return NewKeyStoreWithKDF(keydir, ScryptKDF(scryptN, scryptP))
+}
+
+// NewKeyStoreWithKDF creates a keystore for the given directory, encrypting new
+// keys with the given key derivation function.
+func NewKeyStoreWithKDF(keydir string, kdf KDFParams) *KeyStore {
 	keydir, _ = filepath.Abs(keydir)
-	ks := &KeyStore{storage: &keyStorePassphrase{keydir, scryptN, scryptP, false}}
+	ks := &KeyStore{storage: &keyStorePassphrase{keydir, kdf, false}}
 	ks.init(keydir)
 	return ks
 }
@@ -423,13 +429,13 @@ func (ks *KeyStore) Export(a accounts.Account, passphrase, newPassphrase string)
 	if err != nil {
 		return nil, err
 	}
-	var N, P int
+	var kdf KDFParams
 	if store, ok := ks.storage.(*keyStorePassphrase); ok {
-		N, P = store.scryptN, store.scryptP
+		kdf = store.kdf
 	} else {
-		N, P = StandardScryptN, StandardScryptP
+		kdf = ScryptKDF(StandardScryptN, StandardScryptP)
 	}
-	return EncryptKey(key, newPassphrase, N, P)
+	return EncryptKeyWithKDF(key, newPassphrase, kdf)
 }
 
 // Import stores the given encrypted JSON key into the key directory.
@@ -463,7 +469,9 @@ func (ks *KeyStore) importKey(key *Key, passphrase string) (accounts.Account, er
 	return a, nil
 }
 
-// Update changes the passphrase of an existing account.
+// Update changes the passphrase of an existing account. The key is re-encrypted
+// with the key derivation function of the keystore, so Update can also be used
+// to migrate key files to a different KDF.
 func (ks *KeyStore) Update(a accounts.Account, passphrase, newPassphrase string) error {
 	a, key, err := ks.getDecryptedKey(a, passphrase)
 	if err != nil {
@@ -42,6 +42,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/pborman/uuid"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/pbkdf2"
 	"golang.org/x/crypto/scrypt"
 )
@@ -49,6 +50,14 @@ import (
 const (
 	keyHeaderKDF = "scrypt"
 
+	// KDFScrypt is the name of the scrypt key derivation function, used by
+	// default to encrypt new keys.
+	KDFScrypt = keyHeaderKDF
+
+	// KDFArgon2id is the name of the argon2id key derivation function. Keys
+	// encrypted with it are stored in version 4 key files.
+	KDFArgon2id = "argon2id"
+
 	// StandardScryptN is the N parameter of Scrypt encryption algorithm, using 256MB
 	// memory and taking approximately 1s CPU time on a modern processor.
 	StandardScryptN = 1 << 18
@@ -67,12 +76,92 @@ const (
 
 	scryptR     = 8
 	scryptDKLen = 32
+
+	// StandardArgon2idTime is the number of passes of argon2id over its memory,
+	// taking approximately 1s CPU time on a modern processor together with the
+	// rest of the standard parameters.
+	StandardArgon2idTime = 3
+
+	// StandardArgon2idMemory is the memory used by argon2id in KiB (256MB).
+	StandardArgon2idMemory = 256 * 1024
+
+	// StandardArgon2idThreads is the degree of parallelism of argon2id.
+	StandardArgon2idThreads = 4
+
+	// LightArgon2idTime is the number of passes of argon2id over its memory,
+	// taking approximately 100ms CPU time on a modern processor together with
+	// the rest of the light parameters.
+	LightArgon2idTime = 1
+
+	// LightArgon2idMemory is the memory used by argon2id in KiB (16MB).
+	LightArgon2idMemory = 16 * 1024
+
+	// LightArgon2idThreads is the degree of parallelism of argon2id.
+	LightArgon2idThreads = 4
+
+	argon2idDKLen = 32
+
+	// maxArgon2idTime, maxArgon2idMemory (in KiB, 1GB) and maxArgon2idThreads
+	// bound the argon2id parameters accepted from key files, so that a crafted
+	// file can't make decryption run or allocate without limit.
+	maxArgon2idTime    = 64
+	maxArgon2idMemory  = 1024 * 1024
+	maxArgon2idThreads = 255
+
+	// minDKLen is the shortest derived key usable for decryption, the first half
+	// of it being the AES key and the second half the MAC key.
+	minDKLen = 32
+
+	// maxDKLen bounds the derived key length accepted from key files.
+	maxDKLen = 1024
 )
 
+// KDFParams selects the key derivation function used to encrypt new keys and
+// its cost parameters. Only the parameters of the selected function are used.
+type KDFParams struct {
+	Name string // Key derivation function, KDFScrypt (default if empty) or KDFArgon2id
+
+	ScryptN int // CPU/memory cost parameter of scrypt
+	ScryptP int // Parallelization parameter of scrypt
+
+	Argon2Time    uint32 // Number of passes of argon2id over the memory
+	Argon2Memory  uint32 // Memory used by argon2id in KiB
+	Argon2Threads uint8  // Degree of parallelism of argon2id
+}
+
+// ScryptKDF returns the key derivation parameters to encrypt keys with scrypt
+// using the given cost parameters.
+func ScryptKDF(n, p int) KDFParams {
+	return KDFParams{Name: KDFScrypt, ScryptN: n, ScryptP: p}
+}
+
+// Argon2idKDF returns the key derivation parameters to encrypt keys with
+// argon2id using the given cost parameters.
+func Argon2idKDF(time, memory uint32, threads uint8) KDFParams {
+	return KDFParams{Name: KDFArgon2id, Argon2Time: time, Argon2Memory: memory, Argon2Threads: threads}
+}
+
+// StandardKDF returns the standard cost parameters of the named key derivation
+// function, or the light ones if requested.
+func StandardKDF(name string, light bool) (KDFParams, error) {
+	switch name {
+	case "", KDFScrypt:
+		if light {
+			return ScryptKDF(LightScryptN, LightScryptP), nil
+		}
+		return ScryptKDF(StandardScryptN, StandardScryptP), nil
+	case KDFArgon2id:
+		if light {
+			return Argon2idKDF(LightArgon2idTime, LightArgon2idMemory, LightArgon2idThreads), nil
+		}
+		return Argon2idKDF(StandardArgon2idTime, StandardArgon2idMemory, StandardArgon2idThreads), nil
+	}
+	return KDFParams{}, fmt.Errorf("unsupported key derivation function %q", name)
+}
+
 type keyStorePassphrase struct {
 	keysDirPath string
-	scryptN     int
-	scryptP     int
+	kdf         KDFParams
 	// skipKeyFileVerification disables the security-feature which does
 	// reads and decrypts any newly created keyfiles. This should be 'false' in all
 	// cases except tests -- setting this to 'true' is not recommended.
@@ -98,12 +187,18 @@ func (ks keyStorePassphrase) GetKey(addr common.Address, filename, auth string)
 
 // StoreKey generates a key, encrypts with 'auth' and stores in the given directory
 func StoreKey(dir, auth string, scryptN, scryptP int) (common.Address, error) {
-	_, a, err := storeNewKey(&keyStorePassphrase{dir, scryptN, scryptP, false}, rand.Reader, auth)
+	return StoreKeyWithKDF(dir, auth, ScryptKDF(scryptN, scryptP))
+}
+
+// StoreKeyWithKDF generates a key, encrypts with 'auth' using the given key
+// derivation function and stores in the given directory
+func StoreKeyWithKDF(dir, auth string, kdf KDFParams) (common.Address, error) {
+	_, a, err := storeNewKey(&keyStorePassphrase{dir, kdf, false}, rand.Reader, auth)
 	return a.Address, err
 }
 
 func (ks keyStorePassphrase) StoreKey(filename string, key *Key, auth string) error {
-	keyjson, err := EncryptKey(key, auth, ks.scryptN, ks.scryptP)
+	keyjson, err := EncryptKeyWithKDF(key, auth, ks.kdf)
 	if err != nil {
 		return err
 	}
@@ -137,14 +232,52 @@ func (ks keyStorePassphrase) JoinPath(filename string) string {
 
 // Encryptdata encrypts the data given as 'data' with the password 'auth'.
 func EncryptDataV3(data, auth []byte, scryptN, scryptP int) (CryptoJSON, error) {
+	return EncryptData(data, auth, ScryptKDF(scryptN, scryptP))
+}
 
+// EncryptData encrypts the data given as 'data' with the password 'auth', using
+// the key derivation function and parameters given as 'kdf'.
+func EncryptData(data, auth []byte, kdf KDFParams) (CryptoJSON, error) {
 	salt := make([]byte, 32)
 	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
 		panic("reading from crypto/rand failed: " + err.Error())
 	}
-	derivedKey, err := scrypt.Key(auth, salt, scryptN, scryptR, scryptP, scryptDKLen)
-	if err != nil {
-		return CryptoJSON{}, err
+	var (
+		derivedKey    []byte
+		kdfName       string
+		kdfParamsJSON map[string]interface{}
+	)
+	switch kdf.Name {
+	case "", KDFScrypt:
+		key, err := scrypt.Key(auth, salt, kdf.ScryptN, scryptR, kdf.ScryptP, scryptDKLen)
+		if err != nil {
+			return CryptoJSON{}, err
+		}
+		derivedKey, kdfName = key, keyHeaderKDF
+
+		kdfParamsJSON = make(map[string]interface{}, 5)
+		kdfParamsJSON["n"] = kdf.ScryptN
+		kdfParamsJSON["r"] = scryptR
+		kdfParamsJSON["p"] = kdf.ScryptP
+		kdfParamsJSON["dklen"] = scryptDKLen
+		kdfParamsJSON["salt"] = hex.EncodeToString(salt)
+
+	case KDFArgon2id:
+		if err := checkArgon2idParams(int(kdf.Argon2Time), int(kdf.Argon2Memory), int(kdf.Argon2Threads)); err != nil {
+			return CryptoJSON{}, err
+		}
+		derivedKey = argon2.IDKey(auth, salt, kdf.Argon2Time, kdf.Argon2Memory, kdf.Argon2Threads, argon2idDKLen)
+		kdfName = KDFArgon2id
+
+		kdfParamsJSON = make(map[string]interface{}, 5)
+		kdfParamsJSON["t"] = kdf.Argon2Time
+		kdfParamsJSON["m"] = kdf.Argon2Memory
+		kdfParamsJSON["p"] = kdf.Argon2Threads
+		kdfParamsJSON["dklen"] = argon2idDKLen
+		kdfParamsJSON["salt"] = hex.EncodeToString(salt)
+
+	default:
+		return CryptoJSON{}, fmt.Errorf("unsupported key derivation function %q", kdf.Name)
 	}
 	encryptKey := derivedKey[:16]
 
@@ -158,12 +291,6 @@ func EncryptDataV3(data, auth []byte, scryptN, scryptP int) (CryptoJSON, error)
 	}
 	mac := crypto.Keccak256(derivedKey[16:32], cipherText)
 
-	scryptParamsJSON := make(map[string]interface{}, 5)
-	scryptParamsJSON["n"] = scryptN
-	scryptParamsJSON["r"] = scryptR
-	scryptParamsJSON["p"] = scryptP
-	scryptParamsJSON["dklen"] = scryptDKLen
-	scryptParamsJSON["salt"] = hex.EncodeToString(salt)
 	cipherParamsJSON := cipherparamsJSON{
 		IV: hex.EncodeToString(iv),
 	}
@@ -172,8 +299,8 @@ func EncryptDataV3(data, auth []byte, scryptN, scryptP int) (CryptoJSON, error)
 		Cipher:       "aes-128-ctr",
 		CipherText:   hex.EncodeToString(cipherText),
 		CipherParams: cipherParamsJSON,
-		KDF:          keyHeaderKDF,
-		KDFParams:    scryptParamsJSON,
+		KDF:          kdfName,
+		KDFParams:    kdfParamsJSON,
 		MAC:          hex.EncodeToString(mac),
 	}
 	return cryptoStruct, nil
@@ -182,16 +309,27 @@ func EncryptDataV3(data, auth []byte, scryptN, scryptP int) (CryptoJSON, error)
 // EncryptKey encrypts a key using the specified scrypt parameters into a json
 // blob that can be decrypted later on.
 func EncryptKey(key *Key, auth string, scryptN, scryptP int) ([]byte, error) {
+	return EncryptKeyWithKDF(key, auth, ScryptKDF(scryptN, scryptP))
+}
+
+// EncryptKeyWithKDF encrypts a key using the specified key derivation parameters
+// into a json blob that can be decrypted later on. Keys encrypted with scrypt are
+// stored in the version 3 format, argon2id ones in version 4.
+func EncryptKeyWithKDF(key *Key, auth string, kdf KDFParams) ([]byte, error) {
 	keyBytes := math.PaddedBigBytes(key.PrivateKey.D, 32)
-	cryptoStruct, err := EncryptDataV3(keyBytes, []byte(auth), scryptN, scryptP)
+	cryptoStruct, err := EncryptData(keyBytes, []byte(auth), kdf)
 	if err != nil {
 		return nil, err
 	}
+	keyVersion := version
+	if cryptoStruct.KDF == KDFArgon2id {
+		keyVersion = versionArgon2id
+	}
 	encryptedKeyJSONV3 := encryptedKeyJSONV3{
 		hex.EncodeToString(key.Address[:]),
 		cryptoStruct,
 		key.Id.String(),
-		version,
+		keyVersion,
 	}
 	return json.Marshal(encryptedKeyJSONV3)
 }
@@ -271,7 +409,16 @@ func DecryptDataV3(cryptoJson CryptoJSON, auth string) ([]byte, error) {
 }
 
 func decryptKeyV3(keyProtected *encryptedKeyJSONV3, auth string) (keyBytes []byte, keyId []byte, err error) {
-	if keyProtected.Version != version {
+	switch keyProtected.Version {
+	case version:
+		if keyProtected.Crypto.KDF == KDFArgon2id {
+			return nil, nil, fmt.Errorf("KDF not supported in version %d: %s", keyProtected.Version, keyProtected.Crypto.KDF)
+		}
+	case versionArgon2id:
+		if keyProtected.Crypto.KDF != KDFArgon2id {
+			return nil, nil, fmt.Errorf("KDF not supported in version %d: %s", keyProtected.Version, keyProtected.Crypto.KDF)
+		}
+	default:
 		return nil, nil, fmt.Errorf("Version not supported: %v", keyProtected.Version)
 	}
 	keyId = uuid.Parse(keyProtected.Id)
@@ -323,6 +470,9 @@ func getKDFKey(cryptoJSON CryptoJSON, auth string) ([]byte, error) {
 		return nil, err
 	}
 	dkLen := ensureInt(cryptoJSON.KDFParams["dklen"])
+	if dkLen < minDKLen || dkLen > maxDKLen {
+		return nil, fmt.Errorf("Invalid derived key length: %d", dkLen)
+	}
 
 	if cryptoJSON.KDF == keyHeaderKDF {
 		n := ensureInt(cryptoJSON.KDFParams["n"])
@@ -330,6 +480,15 @@ func getKDFKey(cryptoJSON CryptoJSON, auth string) ([]byte, error) {
 		p := ensureInt(cryptoJSON.KDFParams["p"])
 		return scrypt.Key(authArray, salt, n, r, p, dkLen)
 
+	} else if cryptoJSON.KDF == KDFArgon2id {
+		t := ensureInt(cryptoJSON.KDFParams["t"])
+		m := ensureInt(cryptoJSON.KDFParams["m"])
+		p := ensureInt(cryptoJSON.KDFParams["p"])
+		if err := checkArgon2idParams(t, m, p); err != nil {
+			return nil, err
+		}
+		return argon2.IDKey(authArray, salt, uint32(t), uint32(m), uint8(p), uint32(dkLen)), nil
+
 	} else if cryptoJSON.KDF == "pbkdf2" {
 		c := ensureInt(cryptoJSON.KDFParams["c"])
 		prf := cryptoJSON.KDFParams["prf"].(string)
@@ -343,6 +502,15 @@ func getKDFKey(cryptoJSON CryptoJSON, auth string) ([]byte, error) {
 	return nil, fmt.Errorf("Unsupported KDF: %s", cryptoJSON.KDF)
 }
 
+// checkArgon2idParams verifies that the argon2id parameters are within the range
+// supported for both encryption and decryption.
+func checkArgon2idParams(t, m, p int) error {
+	if t < 1 || t > maxArgon2idTime || p < 1 || p > maxArgon2idThreads || m < 8*p || m > maxArgon2idMemory {
+		return fmt.Errorf("Invalid argon2id parameters: t=%d, m=%d, p=%d", t, m, p)
+	}
+	return nil
+}
+
 // TODO: can we do without this when unmarshalling dynamic JSON?
 // why do integers in KDF params end up as float64 and not int after
 // unmarshal?
@@ -17,7 +17,10 @@
 package keystore
 
 import (
+	"encoding/json"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -26,8 +29,14 @@ import (
 const (
 	veryLightScryptN = 2
 	veryLightScryptP = 1
+
+	veryLightArgon2idTime    = 1
+	veryLightArgon2idMemory  = 64
+	veryLightArgon2idThreads = 1
 )
 
+var veryLightArgon2id = Argon2idKDF(veryLightArgon2idTime, veryLightArgon2idMemory, veryLightArgon2idThreads)
+
 // Tests that a json key file can be decrypted and encrypted in multiple rounds.
 func TestKeyEncryptDecrypt(t *testing.T) {
 	keyjson, err := ioutil.ReadFile("testdata/very-light-scrypt.json")
@@ -58,3 +67,135 @@ func TestKeyEncryptDecrypt(t *testing.T) {
 		}
 	}
 }
+
+// Tests that keys encrypted with argon2id are stored as version 4 key files and
+// can be decrypted again.
+func TestKeyEncryptDecryptArgon2id(t *testing.T) {
+	keyjson, err := ioutil.ReadFile("testdata/very-light-scrypt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := DecryptKey(keyjson, "")
+	if err != nil {
+		t.Fatalf("json key failed to decrypt: %v", err)
+	}
+	if keyjson, err = EncryptKeyWithKDF(key, "foo", veryLightArgon2id); err != nil {
+		t.Fatalf("failed to encrypt key with argon2id: %v", err)
+	}
+	var stored encryptedKeyJSONV3
+	if err := json.Unmarshal(keyjson, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Version != versionArgon2id {
+		t.Errorf("key file version mismatch: have %d, want %d", stored.Version, versionArgon2id)
+	}
+	if stored.Crypto.KDF != KDFArgon2id {
+		t.Errorf("key file KDF mismatch: have %s, want %s", stored.Crypto.KDF, KDFArgon2id)
+	}
+	if _, err := DecryptKey(keyjson, "bar"); err != ErrDecrypt {
+		t.Errorf("json key decrypted with bad password: %v", err)
+	}
+	decrypted, err := DecryptKey(keyjson, "foo")
+	if err != nil {
+		t.Fatalf("json key failed to decrypt: %v", err)
+	}
+	if decrypted.Address != key.Address {
+		t.Errorf("key address mismatch: have %x, want %x", decrypted.Address, key.Address)
+	}
+	// An argon2id key claiming to be version 3 must be rejected
+	stored.Version = version
+	if keyjson, err = json.Marshal(stored); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptKey(keyjson, "foo"); err == nil {
+		t.Errorf("argon2id key decrypted from version 3 file")
+	}
+}
+
+// Tests that updating an account re-encrypts its key file with the KDF of the
+// keystore, migrating existing scrypt files to argon2id.
+func TestKeyStoreUpdateKDF(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eth-keystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyjson, err := ioutil.ReadFile("testdata/very-light-scrypt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "very-light-scrypt.json"), keyjson, 0600); err != nil {
+		t.Fatal(err)
+	}
+	ks := NewKeyStoreWithKDF(dir, veryLightArgon2id)
+	accs := ks.Accounts()
+	if len(accs) != 1 {
+		t.Fatalf("account count mismatch: have %d, want 1", len(accs))
+	}
+	if err := ks.Update(accs[0], "", "foo"); err != nil {
+		t.Fatalf("failed to update account: %v", err)
+	}
+	if keyjson, err = ioutil.ReadFile(accs[0].URL.Path); err != nil {
+		t.Fatal(err)
+	}
+	var stored encryptedKeyJSONV3
+	if err := json.Unmarshal(keyjson, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Version != versionArgon2id || stored.Crypto.KDF != KDFArgon2id {
+		t.Errorf("key file not migrated: version %d, KDF %s", stored.Version, stored.Crypto.KDF)
+	}
+	if _, err := ks.Export(accs[0], "foo", "bar"); err != nil {
+		t.Errorf("failed to export migrated account: %v", err)
+	}
+}
+
+// Tests that key files with key derivation parameters out of the supported range
+// are rejected instead of panicking or allocating unbounded memory.
+func TestKeyInvalidKDFParams(t *testing.T) {
+	keyjson, err := ioutil.ReadFile("testdata/very-light-scrypt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := DecryptKey(keyjson, "")
+	if err != nil {
+		t.Fatalf("json key failed to decrypt: %v", err)
+	}
+	if _, err := EncryptKeyWithKDF(key, "foo", Argon2idKDF(1, maxArgon2idMemory+1, 1)); err == nil {
+		t.Errorf("key encrypted with argon2id memory above the limit")
+	}
+	tests := []struct {
+		kdf    KDFParams
+		params map[string]interface{}
+	}{
+		{ScryptKDF(veryLightScryptN, veryLightScryptP), map[string]interface{}{"dklen": 16}},
+		{ScryptKDF(veryLightScryptN, veryLightScryptP), map[string]interface{}{"dklen": maxDKLen + 1}},
+		{veryLightArgon2id, map[string]interface{}{"dklen": 0}},
+		{veryLightArgon2id, map[string]interface{}{"t": 0}},
+		{veryLightArgon2id, map[string]interface{}{"t": maxArgon2idTime + 1}},
+		{veryLightArgon2id, map[string]interface{}{"m": 4}},
+		{veryLightArgon2id, map[string]interface{}{"m": 1 << 32}},
+		{veryLightArgon2id, map[string]interface{}{"p": 0}},
+		{veryLightArgon2id, map[string]interface{}{"p": 256}},
+	}
+	for i, tt := range tests {
+		keyjson, err := EncryptKeyWithKDF(key, "foo", tt.kdf)
+		if err != nil {
+			t.Fatalf("test %d: failed to encrypt key: %v", i, err)
+		}
+		var stored encryptedKeyJSONV3
+		if err := json.Unmarshal(keyjson, &stored); err != nil {
+			t.Fatal(err)
+		}
+		for name, value := range tt.params {
+			stored.Crypto.KDFParams[name] = value
+		}
+		if keyjson, err = json.Marshal(stored); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := DecryptKey(keyjson, "foo"); err == nil || err == ErrDecrypt {
+			t.Errorf("test %d: key with KDF params %v not rejected: %v", i, tt.params, err)
+		}
+	}
+}
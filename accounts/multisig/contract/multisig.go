@@ -0,0 +1,730 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package contract
+
+import (
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = abi.U256
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+)
+
+// MultiSigABI is the input ABI used to generate the binding from.
+const MultiSigABI = "[{\"inputs\":[{\"internalType\":\"address[]\",\"name\":\"_owners\",\"type\":\"address[]\"},{\"internalType\":\"uint256\",\"name\":\"_threshold\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"_chainId\",\"type\":\"uint256\"}],\"stateMutability\":\"nonpayable\",\"type\":\"constructor\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"bytes32\",\"name\":\"hash\",\"type\":\"bytes32\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"owner\",\"type\":\"address\"}],\"name\":\"Approved\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"uint256\",\"name\":\"nonce\",\"type\":\"uint256\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"destination\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"value\",\"type\":\"uint256\"}],\"name\":\"Executed\",\"type\":\"event\"},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"hash\",\"type\":\"bytes32\"}],\"name\":\"approve\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"\",\"type\":\"bytes32\"},{\"internalType\":\"address\",\"name\":\"\",\"type\":\"address\"}],\"name\":\"approved\",\"outputs\":[{\"internalType\":\"bool\",\"name\":\"\",\"type\":\"bool\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"chainId\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"destination\",\"type\":\"address\"},{\"internalType\":\"uint256\",\"name\":\"value\",\"type\":\"uint256\"},{\"internalType\":\"bytes\",\"name\":\"data\",\"type\":\"bytes\"},{\"internalType\":\"bytes\",\"name\":\"signatures\",\"type\":\"bytes\"}],\"name\":\"execute\",\"outputs\":[],\"stateMutability\":\"payable\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"getOwners\",\"outputs\":[{\"internalType\":\"address[]\",\"name\":\"\",\"type\":\"address[]\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"\",\"type\":\"address\"}],\"name\":\"isOwner\",\"outputs\":[{\"internalType\":\"bool\",\"name\":\"\",\"type\":\"bool\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"nonce\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"threshold\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"stateMutability\":\"payable\",\"type\":\"receive\"}]"
+
+// MultiSigBin is the compiled bytecode used for deploying new contracts.
+const MultiSigBin = `60806040523480156200001157600080fd5b5060405162000bd938038062000bd983398101604081905262000034916200023a565b60008211801562000046575082518211155b6200005057600080fd5b60005b835181101562000146576000600160a060020a03168482815181106200007d576200007d6200031f565b6020026020010151600160a060020a031614158015620000da575060046000858381518110620000b157620000b16200031f565b602090810291909101810151600160a060020a031682528101919091526040016000205460ff16155b620000e457600080fd5b600160046000868481518110620000ff57620000ff6200031f565b602090810291909101810151600160a060020a03168252810191909152604001600020805460ff1916911515919091179055806200013d816200034e565b91505062000053565b5082516200015c9060039060208601906200016d565b50600191909155600255506200038f565b828054828255906000526020600020908101928215620001c5579160200282015b82811115620001c55782518254600160a060020a031916600160a060020a039091161782556020909201916001909101906200018e565b50620001d3929150620001d7565b5090565b5b80821115620001d35760008155600101620001d8565b7f4e487b7100000000000000000000000000000000000000000000000000000000600052604160045260246000fd5b8051600160a060020a03811681146200023557600080fd5b919050565b6000806000606084860312156200025057600080fd5b835167ffffffffffffffff808211156200026957600080fd5b818601915086601f8301126200027e57600080fd5b8151602082821115620002955762000295620001ee565b808202604051601f19603f83011681018181108682111715620002bc57620002bc620001ee565b60405292835281830193508481018201928a841115620002db57600080fd5b948201945b838610156200030457620002f4866200021d565b85529482019493820193620002e0565b91890151604090990151919a98995090979650505050505050565b7f4e487b7100000000000000000000000000000000000000000000000000000000600052603260045260246000fd5b60006001820162000388577f4e487b7100000000000000000000000000000000000000000000000000000000600052601160045260246000fd5b5060010190565b61083a806200039f6000396000f3fe60806040526004361061009c576000357c010000000000000000000000000000000000000000000000000000000090048063a53a1adf1161006b578063a53a1adf14610149578063affed0e01461016b578063b984b2cd14610181578063da0980c7146101bc57600080fd5b80632f54bf6e146100a857806342cde4e8146100ed5780639a8a059214610111578063a0e67e2b1461012757600080fd5b366100a357005b600080fd5b3480156100b457600080fd5b506100d86100c3366004610576565b60046020526000908152604090205460ff1681565b60405190151581526020015b60405180910390f35b3480156100f957600080fd5b5061010360015481565b6040519081526020016100e4565b34801561011d57600080fd5b5061010360025481565b34801561013357600080fd5b5061013c6101cf565b6040516100e49190610591565b34801561015557600080fd5b506101696101643660046105de565b610231565b005b34801561017757600080fd5b5061010360005481565b34801561018d57600080fd5b506100d861019c3660046105f7565b600560209081526000928352604080842090915290825290205460ff1681565b6101696101ca3660046106df565b61029e565b6060600380548060200260200160405190810160405280929190818152602001828054801561022757602002820191906000526020600020905b8154600160a060020a03168152600190910190602001808311610209575b5050505050905090565b3360009081526004602052604090205460ff1661024d57600080fd5b6000818152600560209081526040808320338085529252808320805460ff1916600117905551909183917f90b535106f433f3869de4725076776361dc9946b091db11206fa1b526a85ecd49190a350565b6001546102ac90604161078c565b8151146102b857600080fd5b600080546002548451602080870191909120604080517f19000000000000000000000000000000000000000000000000000000000000008185015260218101879052306c010000000000000000000000000260228201526036810194909452600160a060020a038a16605685015260768401899052609684019190915260b68084018590528151808503909101815260d6909301905281519101209091805b6001548110156103c657600061036e848784610491565b905082600160a060020a031681600160a060020a03161180156103a95750600160a060020a03811660009081526004602052604090205460ff165b6103b257600080fd5b9150806103be816107a9565b915050610357565b506103d28360016107c2565b600081905550600087600160a060020a031687876040516103f391906107d5565b60006040518083038185875af1925050503d8060008114610430576040519150601f19603f3d011682016040523d82523d6000602084013e610435565b606091505b505090508061044357600080fd5b87600160a060020a0316847f12c8907d32b752d626a36cf18f31719e25f1a3b6f750ee948c22c036373d48aa8960405161047f91815260200190565b60405180910390a35050505050505050565b6041810282016020810151604082015160609092015160009290831a8084036104f0576000878152600560209081526040808320600160a060020a0387168452909152902054839060ff166104e557600080fd5b935061055392505050565b60408051600081526020810180835289905260ff831691810191909152606081018490526080810183905260019060a0016020604051602081039080840390855afa158015610543573d6000803e3d6000fd5b5050506020604051035193505050505b9392505050565b8035600160a060020a038116811461057157600080fd5b919050565b60006020828403121561058857600080fd5b6105538261055a565b6020808252825182820181905260009190848201906040850190845b818110156105d2578351600160a060020a0316835292840192918401916001016105ad565b50909695505050505050565b6000602082840312156105f057600080fd5b5035919050565b6000806040838503121561060a57600080fd5b8235915061061a6020840161055a565b90509250929050565b7f4e487b7100000000000000000000000000000000000000000000000000000000600052604160045260246000fd5b600082601f83011261066357600080fd5b813567ffffffffffffffff8082111561067e5761067e610623565b604051601f8301601f19908116603f011681019082821181831017156106a6576106a6610623565b816040528381528660208588010111156106bf57600080fd5b836020870160208301376000602085830101528094505050505092915050565b600080600080608085870312156106f557600080fd5b6106fe8561055a565b935060208501359250604085013567ffffffffffffffff8082111561072257600080fd5b61072e88838901610652565b9350606087013591508082111561074457600080fd5b5061075187828801610652565b91505092959194509250565b7f4e487b7100000000000000000000000000000000000000000000000000000000600052601160045260246000fd5b80820281158282048414176107a3576107a361075d565b92915050565b6000600182016107bb576107bb61075d565b5060010190565b808201808211156107a3576107a361075d565b6000825160005b818110156107f657602081860181015185830152016107dc565b50600092019182525091905056fea26469706673582212204b17e4a916e91ce9ea28bf0b2839d7911681c3e601a48b0830a040233438b7ec64736f6c63430008150033`
+
+// DeployMultiSig deploys a new Ethereum contract, binding an instance of MultiSig to it.
+func DeployMultiSig(auth *bind.TransactOpts, backend bind.ContractBackend, _owners []common.Address, _threshold *big.Int, _chainId *big.Int) (common.Address, *types.Transaction, *MultiSig, error) {
+	parsed, err := abi.JSON(strings.NewReader(MultiSigABI))
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	address, tx, contract, err := bind.DeployContract(auth, parsed, common.FromHex(MultiSigBin), backend, _owners, _threshold, _chainId)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	return address, tx, &MultiSig{MultiSigCaller: MultiSigCaller{contract: contract}, MultiSigTransactor: MultiSigTransactor{contract: contract}, MultiSigFilterer: MultiSigFilterer{contract: contract}}, nil
+}
+
+// MultiSig is an auto generated Go binding around an Ethereum contract.
+type MultiSig struct {
+	MultiSigCaller     // Read-only binding to the contract
+	MultiSigTransactor // Write-only binding to the contract
+	MultiSigFilterer   // Log filterer for contract events
+}
+
+// MultiSigCaller is an auto generated read-only Go binding around an Ethereum contract.
+type MultiSigCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// MultiSigTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type MultiSigTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// MultiSigFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type MultiSigFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// MultiSigSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type MultiSigSession struct {
+	Contract     *MultiSig         // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// MultiSigCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type MultiSigCallerSession struct {
+	Contract *MultiSigCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts   // Call options to use throughout this session
+}
+
+// MultiSigTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type MultiSigTransactorSession struct {
+	Contract     *MultiSigTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts   // Transaction auth options to use throughout this session
+}
+
+// MultiSigRaw is an auto generated low-level Go binding around an Ethereum contract.
+type MultiSigRaw struct {
+	Contract *MultiSig // Generic contract binding to access the raw methods on
+}
+
+// MultiSigCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type MultiSigCallerRaw struct {
+	Contract *MultiSigCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// MultiSigTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type MultiSigTransactorRaw struct {
+	Contract *MultiSigTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewMultiSig creates a new instance of MultiSig, bound to a specific deployed contract.
+func NewMultiSig(address common.Address, backend bind.ContractBackend) (*MultiSig, error) {
+	contract, err := bindMultiSig(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &MultiSig{MultiSigCaller: MultiSigCaller{contract: contract}, MultiSigTransactor: MultiSigTransactor{contract: contract}, MultiSigFilterer: MultiSigFilterer{contract: contract}}, nil
+}
+
+// NewMultiSigCaller creates a new read-only instance of MultiSig, bound to a specific deployed contract.
+func NewMultiSigCaller(address common.Address, caller bind.ContractCaller) (*MultiSigCaller, error) {
+	contract, err := bindMultiSig(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &MultiSigCaller{contract: contract}, nil
+}
+
+// NewMultiSigTransactor creates a new write-only instance of MultiSig, bound to a specific deployed contract.
+func NewMultiSigTransactor(address common.Address, transactor bind.ContractTransactor) (*MultiSigTransactor, error) {
+	contract, err := bindMultiSig(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &MultiSigTransactor{contract: contract}, nil
+}
+
+// NewMultiSigFilterer creates a new log filterer instance of MultiSig, bound to a specific deployed contract.
+func NewMultiSigFilterer(address common.Address, filterer bind.ContractFilterer) (*MultiSigFilterer, error) {
+	contract, err := bindMultiSig(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &MultiSigFilterer{contract: contract}, nil
+}
+
+// bindMultiSig binds a generic wrapper to an already deployed contract.
+func bindMultiSig(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(MultiSigABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_MultiSig *MultiSigRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _MultiSig.Contract.MultiSigCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_MultiSig *MultiSigRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _MultiSig.Contract.MultiSigTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_MultiSig *MultiSigRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _MultiSig.Contract.MultiSigTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_MultiSig *MultiSigCallerRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _MultiSig.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_MultiSig *MultiSigTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _MultiSig.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_MultiSig *MultiSigTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _MultiSig.Contract.contract.Transact(opts, method, params...)
+}
+
+// Approved is a free data retrieval call binding the contract method 0xb984b2cd.
+//
+// Solidity: function approved(bytes32 , address ) constant returns(bool)
+func (_MultiSig *MultiSigCaller) Approved(opts *bind.CallOpts, arg0 [32]byte, arg1 common.Address) (bool, error) {
+	var (
+		ret0 = new(bool)
+	)
+	out := ret0
+	err := _MultiSig.contract.Call(opts, out, "approved", arg0, arg1)
+	return *ret0, err
+}
+
+// Approved is a free data retrieval call binding the contract method 0xb984b2cd.
+//
+// Solidity: function approved(bytes32 , address ) constant returns(bool)
+func (_MultiSig *MultiSigSession) Approved(arg0 [32]byte, arg1 common.Address) (bool, error) {
+	return _MultiSig.Contract.Approved(&_MultiSig.CallOpts, arg0, arg1)
+}
+
+// Approved is a free data retrieval call binding the contract method 0xb984b2cd.
+//
+// Solidity: function approved(bytes32 , address ) constant returns(bool)
+func (_MultiSig *MultiSigCallerSession) Approved(arg0 [32]byte, arg1 common.Address) (bool, error) {
+	return _MultiSig.Contract.Approved(&_MultiSig.CallOpts, arg0, arg1)
+}
+
+// ChainId is a free data retrieval call binding the contract method 0x9a8a0592.
+//
+// Solidity: function chainId() constant returns(uint256)
+func (_MultiSig *MultiSigCaller) ChainId(opts *bind.CallOpts) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _MultiSig.contract.Call(opts, out, "chainId")
+	return *ret0, err
+}
+
+// ChainId is a free data retrieval call binding the contract method 0x9a8a0592.
+//
+// Solidity: function chainId() constant returns(uint256)
+func (_MultiSig *MultiSigSession) ChainId() (*big.Int, error) {
+	return _MultiSig.Contract.ChainId(&_MultiSig.CallOpts)
+}
+
+// ChainId is a free data retrieval call binding the contract method 0x9a8a0592.
+//
+// Solidity: function chainId() constant returns(uint256)
+func (_MultiSig *MultiSigCallerSession) ChainId() (*big.Int, error) {
+	return _MultiSig.Contract.ChainId(&_MultiSig.CallOpts)
+}
+
+// GetOwners is a free data retrieval call binding the contract method 0xa0e67e2b.
+//
+// Solidity: function getOwners() constant returns(address[])
+func (_MultiSig *MultiSigCaller) GetOwners(opts *bind.CallOpts) ([]common.Address, error) {
+	var (
+		ret0 = new([]common.Address)
+	)
+	out := ret0
+	err := _MultiSig.contract.Call(opts, out, "getOwners")
+	return *ret0, err
+}
+
+// GetOwners is a free data retrieval call binding the contract method 0xa0e67e2b.
+//
+// Solidity: function getOwners() constant returns(address[])
+func (_MultiSig *MultiSigSession) GetOwners() ([]common.Address, error) {
+	return _MultiSig.Contract.GetOwners(&_MultiSig.CallOpts)
+}
+
+// GetOwners is a free data retrieval call binding the contract method 0xa0e67e2b.
+//
+// Solidity: function getOwners() constant returns(address[])
+func (_MultiSig *MultiSigCallerSession) GetOwners() ([]common.Address, error) {
+	return _MultiSig.Contract.GetOwners(&_MultiSig.CallOpts)
+}
+
+// IsOwner is a free data retrieval call binding the contract method 0x2f54bf6e.
+//
+// Solidity: function isOwner(address ) constant returns(bool)
+func (_MultiSig *MultiSigCaller) IsOwner(opts *bind.CallOpts, arg0 common.Address) (bool, error) {
+	var (
+		ret0 = new(bool)
+	)
+	out := ret0
+	err := _MultiSig.contract.Call(opts, out, "isOwner", arg0)
+	return *ret0, err
+}
+
+// IsOwner is a free data retrieval call binding the contract method 0x2f54bf6e.
+//
+// Solidity: function isOwner(address ) constant returns(bool)
+func (_MultiSig *MultiSigSession) IsOwner(arg0 common.Address) (bool, error) {
+	return _MultiSig.Contract.IsOwner(&_MultiSig.CallOpts, arg0)
+}
+
+// IsOwner is a free data retrieval call binding the contract method 0x2f54bf6e.
+//
+// Solidity: function isOwner(address ) constant returns(bool)
+func (_MultiSig *MultiSigCallerSession) IsOwner(arg0 common.Address) (bool, error) {
+	return _MultiSig.Contract.IsOwner(&_MultiSig.CallOpts, arg0)
+}
+
+// Nonce is a free data retrieval call binding the contract method 0xaffed0e0.
+//
+// Solidity: function nonce() constant returns(uint256)
+func (_MultiSig *MultiSigCaller) Nonce(opts *bind.CallOpts) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _MultiSig.contract.Call(opts, out, "nonce")
+	return *ret0, err
+}
+
+// Nonce is a free data retrieval call binding the contract method 0xaffed0e0.
+//
+// Solidity: function nonce() constant returns(uint256)
+func (_MultiSig *MultiSigSession) Nonce() (*big.Int, error) {
+	return _MultiSig.Contract.Nonce(&_MultiSig.CallOpts)
+}
+
+// Nonce is a free data retrieval call binding the contract method 0xaffed0e0.
+//
+// Solidity: function nonce() constant returns(uint256)
+func (_MultiSig *MultiSigCallerSession) Nonce() (*big.Int, error) {
+	return _MultiSig.Contract.Nonce(&_MultiSig.CallOpts)
+}
+
+// Threshold is a free data retrieval call binding the contract method 0x42cde4e8.
+//
+// Solidity: function threshold() constant returns(uint256)
+func (_MultiSig *MultiSigCaller) Threshold(opts *bind.CallOpts) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _MultiSig.contract.Call(opts, out, "threshold")
+	return *ret0, err
+}
+
+// Threshold is a free data retrieval call binding the contract method 0x42cde4e8.
+//
+// Solidity: function threshold() constant returns(uint256)
+func (_MultiSig *MultiSigSession) Threshold() (*big.Int, error) {
+	return _MultiSig.Contract.Threshold(&_MultiSig.CallOpts)
+}
+
+// Threshold is a free data retrieval call binding the contract method 0x42cde4e8.
+//
+// Solidity: function threshold() constant returns(uint256)
+func (_MultiSig *MultiSigCallerSession) Threshold() (*big.Int, error) {
+	return _MultiSig.Contract.Threshold(&_MultiSig.CallOpts)
+}
+
+// Approve is a paid mutator transaction binding the contract method 0xa53a1adf.
+//
+// Solidity: function approve(bytes32 hash) returns()
+func (_MultiSig *MultiSigTransactor) Approve(opts *bind.TransactOpts, hash [32]byte) (*types.Transaction, error) {
+	return _MultiSig.contract.Transact(opts, "approve", hash)
+}
+
+// Approve is a paid mutator transaction binding the contract method 0xa53a1adf.
+//
+// Solidity: function approve(bytes32 hash) returns()
+func (_MultiSig *MultiSigSession) Approve(hash [32]byte) (*types.Transaction, error) {
+	return _MultiSig.Contract.Approve(&_MultiSig.TransactOpts, hash)
+}
+
+// Approve is a paid mutator transaction binding the contract method 0xa53a1adf.
+//
+// Solidity: function approve(bytes32 hash) returns()
+func (_MultiSig *MultiSigTransactorSession) Approve(hash [32]byte) (*types.Transaction, error) {
+	return _MultiSig.Contract.Approve(&_MultiSig.TransactOpts, hash)
+}
+
+// Execute is a paid mutator transaction binding the contract method 0xda0980c7.
+//
+// Solidity: function execute(address destination, uint256 value, bytes data, bytes signatures) returns()
+func (_MultiSig *MultiSigTransactor) Execute(opts *bind.TransactOpts, destination common.Address, value *big.Int, data []byte, signatures []byte) (*types.Transaction, error) {
+	return _MultiSig.contract.Transact(opts, "execute", destination, value, data, signatures)
+}
+
+// Execute is a paid mutator transaction binding the contract method 0xda0980c7.
+//
+// Solidity: function execute(address destination, uint256 value, bytes data, bytes signatures) returns()
+func (_MultiSig *MultiSigSession) Execute(destination common.Address, value *big.Int, data []byte, signatures []byte) (*types.Transaction, error) {
+	return _MultiSig.Contract.Execute(&_MultiSig.TransactOpts, destination, value, data, signatures)
+}
+
+// Execute is a paid mutator transaction binding the contract method 0xda0980c7.
+//
+// Solidity: function execute(address destination, uint256 value, bytes data, bytes signatures) returns()
+func (_MultiSig *MultiSigTransactorSession) Execute(destination common.Address, value *big.Int, data []byte, signatures []byte) (*types.Transaction, error) {
+	return _MultiSig.Contract.Execute(&_MultiSig.TransactOpts, destination, value, data, signatures)
+}
+
+// MultiSigApprovedIterator is returned from FilterApproved and is used to iterate over the raw logs and unpacked data for Approved events raised by the MultiSig contract.
+type MultiSigApprovedIterator struct {
+	Event *MultiSigApproved // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *MultiSigApprovedIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(MultiSigApproved)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(MultiSigApproved)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *MultiSigApprovedIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *MultiSigApprovedIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// MultiSigApproved represents a Approved event raised by the MultiSig contract.
+type MultiSigApproved struct {
+	Hash  [32]byte
+	Owner common.Address
+	Raw   types.Log // Blockchain specific contextual infos
+}
+
+// FilterApproved is a free log retrieval operation binding the contract event 0x90b535106f433f3869de4725076776361dc9946b091db11206fa1b526a85ecd4.
+//
+// Solidity: event Approved(bytes32 indexed hash, address indexed owner)
+func (_MultiSig *MultiSigFilterer) FilterApproved(opts *bind.FilterOpts, hash [][32]byte, owner []common.Address) (*MultiSigApprovedIterator, error) {
+
+	var hashRule []interface{}
+	for _, hashItem := range hash {
+		hashRule = append(hashRule, hashItem)
+	}
+	var ownerRule []interface{}
+	for _, ownerItem := range owner {
+		ownerRule = append(ownerRule, ownerItem)
+	}
+
+	logs, sub, err := _MultiSig.contract.FilterLogs(opts, "Approved", hashRule, ownerRule)
+	if err != nil {
+		return nil, err
+	}
+	return &MultiSigApprovedIterator{contract: _MultiSig.contract, event: "Approved", logs: logs, sub: sub}, nil
+}
+
+// WatchApproved is a free log subscription operation binding the contract event 0x90b535106f433f3869de4725076776361dc9946b091db11206fa1b526a85ecd4.
+//
+// Solidity: event Approved(bytes32 indexed hash, address indexed owner)
+func (_MultiSig *MultiSigFilterer) WatchApproved(opts *bind.WatchOpts, sink chan<- *MultiSigApproved, hash [][32]byte, owner []common.Address) (event.Subscription, error) {
+
+	var hashRule []interface{}
+	for _, hashItem := range hash {
+		hashRule = append(hashRule, hashItem)
+	}
+	var ownerRule []interface{}
+	for _, ownerItem := range owner {
+		ownerRule = append(ownerRule, ownerItem)
+	}
+
+	logs, sub, err := _MultiSig.contract.WatchLogs(opts, "Approved", hashRule, ownerRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(MultiSigApproved)
+				if err := _MultiSig.contract.UnpackLog(event, "Approved", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseApproved is a log parse operation binding the contract event 0x90b535106f433f3869de4725076776361dc9946b091db11206fa1b526a85ecd4.
+//
+// Solidity: event Approved(bytes32 indexed hash, address indexed owner)
+func (_MultiSig *MultiSigFilterer) ParseApproved(log types.Log) (*MultiSigApproved, error) {
+	event := new(MultiSigApproved)
+	if err := _MultiSig.contract.UnpackLog(event, "Approved", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// NewApprovedWatcher creates a resumable log watcher binding the contract event 0x90b535106f433f3869de4725076776361dc9946b091db11206fa1b526a85ecd4,
+// persisting its progress into the given checkpoint store.
+//
+// Solidity: event Approved(bytes32 indexed hash, address indexed owner)
+func (_MultiSig *MultiSigFilterer) NewApprovedWatcher(store bind.CheckpointStore, hash [][32]byte, owner []common.Address) (*bind.LogWatcher, error) {
+
+	var hashRule []interface{}
+	for _, hashItem := range hash {
+		hashRule = append(hashRule, hashItem)
+	}
+	var ownerRule []interface{}
+	for _, ownerItem := range owner {
+		ownerRule = append(ownerRule, ownerItem)
+	}
+
+	return _MultiSig.contract.NewLogWatcher(store, "Approved", hashRule, ownerRule)
+}
+
+// MultiSigExecutedIterator is returned from FilterExecuted and is used to iterate over the raw logs and unpacked data for Executed events raised by the MultiSig contract.
+type MultiSigExecutedIterator struct {
+	Event *MultiSigExecuted // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *MultiSigExecutedIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(MultiSigExecuted)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(MultiSigExecuted)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *MultiSigExecutedIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *MultiSigExecutedIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// MultiSigExecuted represents a Executed event raised by the MultiSig contract.
+type MultiSigExecuted struct {
+	Nonce       *big.Int
+	Destination common.Address
+	Value       *big.Int
+	Raw         types.Log // Blockchain specific contextual infos
+}
+
+// FilterExecuted is a free log retrieval operation binding the contract event 0x12c8907d32b752d626a36cf18f31719e25f1a3b6f750ee948c22c036373d48aa.
+//
+// Solidity: event Executed(uint256 indexed nonce, address indexed destination, uint256 value)
+func (_MultiSig *MultiSigFilterer) FilterExecuted(opts *bind.FilterOpts, nonce []*big.Int, destination []common.Address) (*MultiSigExecutedIterator, error) {
+
+	var nonceRule []interface{}
+	for _, nonceItem := range nonce {
+		nonceRule = append(nonceRule, nonceItem)
+	}
+	var destinationRule []interface{}
+	for _, destinationItem := range destination {
+		destinationRule = append(destinationRule, destinationItem)
+	}
+
+	logs, sub, err := _MultiSig.contract.FilterLogs(opts, "Executed", nonceRule, destinationRule)
+	if err != nil {
+		return nil, err
+	}
+	return &MultiSigExecutedIterator{contract: _MultiSig.contract, event: "Executed", logs: logs, sub: sub}, nil
+}
+
+// WatchExecuted is a free log subscription operation binding the contract event 0x12c8907d32b752d626a36cf18f31719e25f1a3b6f750ee948c22c036373d48aa.
+//
+// Solidity: event Executed(uint256 indexed nonce, address indexed destination, uint256 value)
+func (_MultiSig *MultiSigFilterer) WatchExecuted(opts *bind.WatchOpts, sink chan<- *MultiSigExecuted, nonce []*big.Int, destination []common.Address) (event.Subscription, error) {
+
+	var nonceRule []interface{}
+	for _, nonceItem := range nonce {
+		nonceRule = append(nonceRule, nonceItem)
+	}
+	var destinationRule []interface{}
+	for _, destinationItem := range destination {
+		destinationRule = append(destinationRule, destinationItem)
+	}
+
+	logs, sub, err := _MultiSig.contract.WatchLogs(opts, "Executed", nonceRule, destinationRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(MultiSigExecuted)
+				if err := _MultiSig.contract.UnpackLog(event, "Executed", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseExecuted is a log parse operation binding the contract event 0x12c8907d32b752d626a36cf18f31719e25f1a3b6f750ee948c22c036373d48aa.
+//
+// Solidity: event Executed(uint256 indexed nonce, address indexed destination, uint256 value)
+func (_MultiSig *MultiSigFilterer) ParseExecuted(log types.Log) (*MultiSigExecuted, error) {
+	event := new(MultiSigExecuted)
+	if err := _MultiSig.contract.UnpackLog(event, "Executed", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// NewExecutedWatcher creates a resumable log watcher binding the contract event 0x12c8907d32b752d626a36cf18f31719e25f1a3b6f750ee948c22c036373d48aa,
+// persisting its progress into the given checkpoint store.
+//
+// Solidity: event Executed(uint256 indexed nonce, address indexed destination, uint256 value)
+func (_MultiSig *MultiSigFilterer) NewExecutedWatcher(store bind.CheckpointStore, nonce []*big.Int, destination []common.Address) (*bind.LogWatcher, error) {
+
+	var nonceRule []interface{}
+	for _, nonceItem := range nonce {
+		nonceRule = append(nonceRule, nonceItem)
+	}
+	var destinationRule []interface{}
+	for _, destinationItem := range destination {
+		destinationRule = append(destinationRule, destinationItem)
+	}
+
+	return _MultiSig.contract.NewLogWatcher(store, "Executed", nonceRule, destinationRule)
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: LGPL-3.0-or-later
+pragma solidity ^0.8.0;
+
+/// @title MultiSig is a minimal M-of-N wallet
+/// @notice Owners approve a call off-chain by signing the EIP-191 (version 0x00)
+/// hash keccak256(0x19 0x00 wallet chainId destination value keccak256(data) nonce),
+/// every field following the wallet address being a 32 byte word, or on-chain by
+/// sending approve(hash). Anyone may then submit execute() with exactly threshold
+/// approvals, ordered by strictly increasing owner address. Each approval is 65
+/// bytes of [r, s, v]; v == 0 marks an on-chain approval of the owner held in r.
+contract MultiSig {
+    // Number of calls executed so far, part of the approved hash against replays
+    uint256 public nonce;
+
+    // Number of owner approvals needed to execute a call
+    uint256 public threshold;
+
+    // Chain the approvals are valid on, part of the approved hash against replays
+    // across chains (EIP-155)
+    uint256 public chainId;
+
+    // Owners of the wallet, in the order they were configured
+    address[] owners;
+
+    mapping(address => bool) public isOwner;
+    mapping(bytes32 => mapping(address => bool)) public approved;
+
+    event Executed(uint256 indexed nonce, address indexed destination, uint256 value);
+    event Approved(bytes32 indexed hash, address indexed owner);
+
+    /// @notice Creates a wallet owned by a set of unique, non-zero accounts,
+    /// threshold of which need to approve any call made on chain chainId.
+    constructor(address[] memory _owners, uint256 _threshold, uint256 _chainId) {
+        require(_threshold > 0 && _threshold <= _owners.length);
+        for (uint256 i = 0; i < _owners.length; i++) {
+            require(_owners[i] != address(0) && !isOwner[_owners[i]]);
+            isOwner[_owners[i]] = true;
+        }
+        owners = _owners;
+        threshold = _threshold;
+        chainId = _chainId;
+    }
+
+    /// @notice Accepts deposits.
+    receive() external payable {}
+
+    /// @notice Returns the owners of the wallet.
+    function getOwners() external view returns (address[] memory) {
+        return owners;
+    }
+
+    /// @notice Approves a call hash on-chain, for owners unable to sign it.
+    function approve(bytes32 hash) external {
+        require(isOwner[msg.sender]);
+        approved[hash][msg.sender] = true;
+        emit Approved(hash, msg.sender);
+    }
+
+    /// @notice Calls destination with the given value and data if it was approved
+    /// by threshold owners.
+    function execute(address destination, uint256 value, bytes memory data, bytes memory signatures) public payable {
+        require(signatures.length == threshold * 65);
+
+        uint256 current = nonce;
+        bytes32 hash = keccak256(abi.encodePacked(bytes1(0x19), bytes1(0), address(this), chainId, uint256(uint160(destination)), value, keccak256(data), current));
+
+        // Owners must be strictly increasing, which also rejects duplicates
+        address last = address(0);
+        for (uint256 i = 0; i < threshold; i++) {
+            address owner = recover(hash, signatures, i);
+            require(owner > last && isOwner[owner]);
+            last = owner;
+        }
+        // Bump the nonce before calling out
+        nonce = current + 1;
+
+        (bool success, ) = destination.call{value: value}(data);
+        require(success);
+        emit Executed(current, destination, value);
+    }
+
+    /// @notice Returns the owner that made the index-th approval of hash.
+    function recover(bytes32 hash, bytes memory signatures, uint256 index) internal view returns (address) {
+        bytes32 r;
+        bytes32 s;
+        uint8 v;
+        assembly {
+            let offset := add(signatures, mul(index, 65))
+            r := mload(add(offset, 32))
+            s := mload(add(offset, 64))
+            v := byte(0, mload(add(offset, 96)))
+        }
+        if (v == 0) {
+            address owner = address(uint160(uint256(r)));
+            require(approved[hash][owner]);
+            return owner;
+        }
+        return ecrecover(hash, v, r, s);
+    }
+}
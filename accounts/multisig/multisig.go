@@ -0,0 +1,379 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package multisig implements an M-of-N threshold wallet backend.
+//
+// Funds are held by an on-chain MultiSig contract (see the contract package)
+// owned by N accounts, any M of which need to approve a call before the contract
+// executes it. The backend tracks such contracts as wallets and manages signing
+// sessions for them: a session is created for a proposed call, collects the
+// approvals of the owners (signatures made by local keystore accounts, or
+// on-chain approvals sent by accounts that cannot sign raw hashes, such as
+// hardware wallets) and once enough were gathered, produces the signature blob
+// to submit alongside the call.
+package multisig
+
+//go:generate solc --optimize --evm-version byzantium --abi --bin --overwrite -o contract/build contract/multisig.sol
+//go:generate abigen --abi contract/build/MultiSig.abi --bin contract/build/MultiSig.bin --type MultiSig --pkg contract --out contract/multisig.go
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// BackendType is the reflect type of a multisig backend.
+var BackendType = reflect.TypeOf(&Backend{})
+
+// Scheme is the URL scheme of the wallets tracked by the multisig backend.
+const Scheme = "multisig"
+
+// Backend is an account backend tracking multisig wallet contracts and the
+// signing sessions pending on them.
+type Backend struct {
+	path     string                     // File to persist wallets and sessions into (empty = memory only)
+	wallets  map[common.Address]*wallet // Multisig contracts currently tracked
+	sessions map[common.Hash]*Session   // Signing sessions pending, keyed by signing hash
+	updates  event.Feed                 // Wallet feed notifying of arrivals/departures
+	scope    event.SubscriptionScope    // Subscription scope tracking current live listeners
+	lock     sync.RWMutex
+}
+
+// state is the on-disk representation of the backend.
+type state struct {
+	Wallets  []common.Address `json:"wallets"`
+	Sessions []*Session       `json:"sessions"`
+}
+
+// NewBackend creates a multisig backend, loading any previously tracked wallets
+// and pending sessions from path. If path is empty, nothing is persisted.
+func NewBackend(path string) (*Backend, error) {
+	b := &Backend{
+		path:     path,
+		wallets:  make(map[common.Address]*wallet),
+		sessions: make(map[common.Hash]*Session),
+	}
+	if path == "" {
+		return b, nil
+	}
+	blob, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st state
+	if err := json.Unmarshal(blob, &st); err != nil {
+		return nil, fmt.Errorf("invalid multisig state %s: %v", path, err)
+	}
+	for _, addr := range st.Wallets {
+		b.wallets[addr] = &wallet{address: addr}
+	}
+	for i, session := range st.Sessions {
+		if session == nil {
+			return nil, fmt.Errorf("invalid multisig state %s: missing session %d", path, i)
+		}
+		if err := session.validate(); err != nil {
+			return nil, fmt.Errorf("invalid multisig state %s: session %d: %v", path, i, err)
+		}
+		b.sessions[session.Hash] = session
+	}
+	return b, nil
+}
+
+// Wallets implements accounts.Backend, returning all the multisig wallets
+// currently tracked, sorted by URL.
+func (b *Backend) Wallets() []accounts.Wallet {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	wallets := make([]accounts.Wallet, 0, len(b.wallets))
+	for _, wallet := range b.wallets {
+		wallets = append(wallets, wallet)
+	}
+	sort.Slice(wallets, func(i, j int) bool {
+		return wallets[i].URL().Cmp(wallets[j].URL()) < 0
+	})
+	return wallets
+}
+
+// Subscribe implements accounts.Backend, creating an async subscription to
+// receive notifications on the addition or removal of multisig wallets.
+func (b *Backend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return b.scope.Track(b.updates.Subscribe(sink))
+}
+
+// Close terminates all wallet event subscriptions.
+func (b *Backend) Close() {
+	b.scope.Close()
+}
+
+// Track starts tracking the multisig contract at the given address as a wallet.
+func (b *Backend) Track(address common.Address) error {
+	b.lock.Lock()
+	if _, ok := b.wallets[address]; ok {
+		b.lock.Unlock()
+		return nil
+	}
+	w := &wallet{address: address}
+	b.wallets[address] = w
+	err := b.save()
+	b.lock.Unlock()
+
+	b.updates.Send(accounts.WalletEvent{Wallet: w, Kind: accounts.WalletArrived})
+	return err
+}
+
+// Untrack stops tracking the multisig contract at the given address, discarding
+// all sessions pending on it.
+func (b *Backend) Untrack(address common.Address) error {
+	b.lock.Lock()
+	w, ok := b.wallets[address]
+	if !ok {
+		b.lock.Unlock()
+		return accounts.ErrUnknownWallet
+	}
+	delete(b.wallets, address)
+	for hash, session := range b.sessions {
+		if session.Wallet == address {
+			delete(b.sessions, hash)
+		}
+	}
+	err := b.save()
+	b.lock.Unlock()
+
+	b.updates.Send(accounts.WalletEvent{Wallet: w, Kind: accounts.WalletDropped})
+	return err
+}
+
+// Propose opens a signing session for a call from the given wallet to the
+// destination address. The chain id and the nonce must match those of the
+// wallet contract, otherwise the collected approvals will not be accepted
+// on-chain. The wallet is tracked if it was not already.
+func (b *Backend) Propose(chainID *big.Int, wallet, to common.Address, value *big.Int, data []byte, nonce uint64) (*Session, error) {
+	if err := b.Track(wallet); err != nil {
+		return nil, err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	session := newSession(chainID, wallet, to, value, data, nonce)
+	if _, ok := b.sessions[session.Hash]; !ok {
+		b.sessions[session.Hash] = session
+		if err := b.save(); err != nil {
+			return nil, err
+		}
+	}
+	return b.sessions[session.Hash].copy(), nil
+}
+
+// Session retrieves the signing session with the given hash.
+func (b *Backend) Session(hash common.Hash) (*Session, error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	session, ok := b.sessions[hash]
+	if !ok {
+		return nil, ErrUnknownSession
+	}
+	return session.copy(), nil
+}
+
+// Sessions retrieves all pending signing sessions, ordered by wallet and nonce.
+func (b *Backend) Sessions() []*Session {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	sessions := make([]*Session, 0, len(b.sessions))
+	for _, session := range b.sessions {
+		sessions = append(sessions, session.copy())
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].Wallet != sessions[j].Wallet {
+			return bytes.Compare(sessions[i].Wallet[:], sessions[j].Wallet[:]) < 0
+		}
+		if sessions[i].Nonce != sessions[j].Nonce {
+			return sessions[i].Nonce < sessions[j].Nonce
+		}
+		return bytes.Compare(sessions[i].Hash[:], sessions[j].Hash[:]) < 0
+	})
+	return sessions
+}
+
+// Approve adds an owner's signature over the session hash to the session.
+func (b *Backend) Approve(hash common.Hash, owner common.Address, sig []byte) (*Session, error) {
+	return b.update(hash, func(session *Session) error {
+		return session.approve(owner, sig)
+	})
+}
+
+// ApproveOnChain records in the session that owner approved its hash by calling
+// approve on the wallet contract.
+func (b *Backend) ApproveOnChain(hash common.Hash, owner common.Address) (*Session, error) {
+	return b.update(hash, func(session *Session) error {
+		return session.approveOnChain(owner)
+	})
+}
+
+// Discard drops a signing session, e.g. because it was executed or became stale.
+func (b *Backend) Discard(hash common.Hash) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if _, ok := b.sessions[hash]; !ok {
+		return ErrUnknownSession
+	}
+	delete(b.sessions, hash)
+	return b.save()
+}
+
+// update applies a modification to a session and persists the result.
+func (b *Backend) update(hash common.Hash, fn func(*Session) error) (*Session, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	session, ok := b.sessions[hash]
+	if !ok {
+		return nil, ErrUnknownSession
+	}
+	if err := fn(session); err != nil {
+		return nil, err
+	}
+	if err := b.save(); err != nil {
+		return nil, err
+	}
+	return session.copy(), nil
+}
+
+// save writes the tracked wallets and pending sessions to disk, if the backend
+// is persistent. The caller must hold the lock.
+func (b *Backend) save() error {
+	if b.path == "" {
+		return nil
+	}
+	st := state{
+		Wallets:  make([]common.Address, 0, len(b.wallets)),
+		Sessions: make([]*Session, 0, len(b.sessions)),
+	}
+	for addr := range b.wallets {
+		st.Wallets = append(st.Wallets, addr)
+	}
+	for _, session := range b.sessions {
+		st.Sessions = append(st.Sessions, session)
+	}
+	blob, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write into a temporary file and move it into place to avoid corruption
+	if err := os.MkdirAll(filepath.Dir(b.path), 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(b.path), "."+filepath.Base(b.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(blob); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	f.Close()
+	return os.Rename(f.Name(), b.path)
+}
+
+// wallet represents a multisig contract as an accounts.Wallet. The contract
+// cannot sign anything by itself, calls are made on its behalf through signing
+// sessions. It is therefore not exposed as an account, keeping it out of the
+// account listings and the default etherbase.
+type wallet struct {
+	address common.Address
+}
+
+// URL implements accounts.Wallet, returning the URL of the multisig contract.
+func (w *wallet) URL() accounts.URL {
+	return accounts.URL{Scheme: Scheme, Path: strings.ToLower(w.address.Hex())}
+}
+
+// Status implements accounts.Wallet, always returning a static status as the
+// wallet has no state of its own.
+func (w *wallet) Status() (string, error) {
+	return "Tracking", nil
+}
+
+// Open implements accounts.Wallet, but is a noop for multisig wallets.
+func (w *wallet) Open(passphrase string) error { return nil }
+
+// Close implements accounts.Wallet, but is a noop for multisig wallets.
+func (w *wallet) Close() error { return nil }
+
+// Accounts implements accounts.Wallet, returning no accounts as the multisig
+// contract cannot sign anything.
+func (w *wallet) Accounts() []accounts.Account {
+	return nil
+}
+
+// Contains implements accounts.Wallet, returning whether the account is the
+// multisig contract of the wallet.
+func (w *wallet) Contains(account accounts.Account) bool {
+	return account.Address == w.address && (account.URL == (accounts.URL{}) || account.URL == w.URL())
+}
+
+// Derive implements accounts.Wallet, but is not supported for multisig wallets.
+func (w *wallet) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	return accounts.Account{}, accounts.ErrNotSupported
+}
+
+// SelfDerive implements accounts.Wallet, but is a noop for multisig wallets.
+func (w *wallet) SelfDerive(base accounts.DerivationPath, chain ethereum.ChainStateReader) {}
+
+// SignHash implements accounts.Wallet, but is not supported for multisig wallets.
+func (w *wallet) SignHash(account accounts.Account, hash []byte) ([]byte, error) {
+	return nil, accounts.ErrNotSupported
+}
+
+// SignTx implements accounts.Wallet, but is not supported for multisig wallets.
+// Transactions need to go through a signing session instead.
+func (w *wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return nil, accounts.ErrNotSupported
+}
+
+// SignHashWithPassphrase implements accounts.Wallet, but is not supported for
+// multisig wallets.
+func (w *wallet) SignHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
+	return nil, accounts.ErrNotSupported
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, but is not supported for
+// multisig wallets. Transactions need to go through a signing session instead.
+func (w *wallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return nil, accounts.ErrNotSupported
+}
@@ -0,0 +1,381 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package multisig_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/accounts/multisig"
+	"github.com/ethereum/go-ethereum/accounts/multisig/contract"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	key0, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	key1, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+	key2, _ = crypto.HexToECDSA("49a7b37aa6f6645917e7b807e9d1c00d4fa71f18343b0d4122a4d2df64dd6fee")
+	addr0   = crypto.PubkeyToAddress(key0.PublicKey)
+	addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+	addr2   = crypto.PubkeyToAddress(key2.PublicKey)
+
+	recipient = common.HexToAddress("0x0102030405060708090a0b0c0d0e0f1011121314")
+
+	chainID = big.NewInt(1337) // Chain id the test wallets are deployed with
+)
+
+func newTestBackend() *backends.SimulatedBackend {
+	return backends.NewSimulatedBackend(core.GenesisAlloc{
+		addr0: {Balance: big.NewInt(1000000000000000000)},
+		addr1: {Balance: big.NewInt(1000000000000000000)},
+		addr2: {Balance: big.NewInt(1000000000000000000)},
+	}, 10000000)
+}
+
+// deploy creates a multisig wallet owned by the test accounts and funds it.
+func deploy(t *testing.T, sim *backends.SimulatedBackend, threshold int64) (common.Address, *contract.MultiSig) {
+	address, _, wallet, err := contract.DeployMultiSig(bind.NewKeyedTransactor(key0), sim, []common.Address{addr0, addr1, addr2}, big.NewInt(threshold), chainID)
+	if err != nil {
+		t.Fatalf("failed to deploy wallet: %v", err)
+	}
+	sim.Commit()
+
+	opts := bind.NewKeyedTransactor(key0)
+	opts.Value = big.NewInt(1000000)
+	if _, err := (&contract.MultiSigRaw{Contract: wallet}).Transfer(opts); err != nil {
+		t.Fatalf("failed to fund wallet: %v", err)
+	}
+	sim.Commit()
+	return address, wallet
+}
+
+// sign signs the session hash with the given key.
+func sign(t *testing.T, session *multisig.Session, key []byte) []byte {
+	prv, _ := crypto.ToECDSA(key)
+	sig, err := crypto.Sign(session.Hash.Bytes(), prv)
+	if err != nil {
+		t.Fatalf("failed to sign session: %v", err)
+	}
+	return sig
+}
+
+// Tests that a wallet deployed from the generated bindings has the requested
+// owner set and threshold.
+func TestDeploy(t *testing.T) {
+	sim := newTestBackend()
+	_, wallet := deploy(t, sim, 2)
+
+	owners, err := wallet.GetOwners(nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve owners: %v", err)
+	}
+	if want := []common.Address{addr0, addr1, addr2}; !reflect.DeepEqual(owners, want) {
+		t.Errorf("owner mismatch: have %x, want %x", owners, want)
+	}
+	for _, owner := range owners {
+		if ok, err := wallet.IsOwner(nil, owner); err != nil || !ok {
+			t.Errorf("owner %x not recognized: %v %v", owner, ok, err)
+		}
+	}
+	if ok, _ := wallet.IsOwner(nil, recipient); ok {
+		t.Errorf("non-owner recognized as owner")
+	}
+	if threshold, _ := wallet.Threshold(nil); threshold.Int64() != 2 {
+		t.Errorf("threshold mismatch: have %v, want 2", threshold)
+	}
+	if id, _ := wallet.ChainId(nil); id.Cmp(chainID) != 0 {
+		t.Errorf("chain id mismatch: have %v, want %v", id, chainID)
+	}
+	// Invalid configurations must be rejected
+	for i, test := range []struct {
+		owners    []common.Address
+		threshold int64
+	}{
+		{[]common.Address{addr0, addr1}, 0},
+		{[]common.Address{addr0, addr1}, 3},
+		{[]common.Address{addr0, addr0}, 1},
+		{[]common.Address{addr0, {}}, 1},
+	} {
+		opts := bind.NewKeyedTransactor(key0)
+		opts.GasLimit = 1000000
+		address, _, _, err := contract.DeployMultiSig(opts, sim, test.owners, big.NewInt(test.threshold), chainID)
+		if err != nil {
+			t.Fatalf("test %d: failed to send deployment: %v", i, err)
+		}
+		sim.Commit()
+		if code, _ := sim.CodeAt(context.Background(), address, nil); len(code) != 0 {
+			t.Errorf("test %d: invalid wallet deployed", i)
+		}
+	}
+}
+
+// Tests that a call approved by a threshold of owners, mixing off-chain and
+// on-chain approvals, is executed by the wallet exactly once.
+func TestExecute(t *testing.T) {
+	sim := newTestBackend()
+	address, wallet := deploy(t, sim, 2)
+
+	backend, _ := multisig.NewBackend("")
+	session, err := backend.Propose(chainID, address, recipient, big.NewInt(1000), nil, 0)
+	if err != nil {
+		t.Fatalf("failed to propose call: %v", err)
+	}
+	// Approve off-chain with one owner and on-chain with another
+	if _, err := backend.Approve(session.Hash, addr0, sign(t, session, crypto.FromECDSA(key0))); err != nil {
+		t.Fatalf("failed to approve session: %v", err)
+	}
+	if _, err := backend.Approve(session.Hash, addr1, sign(t, session, crypto.FromECDSA(key0))); err != multisig.ErrInvalidApproval {
+		t.Fatalf("foreign signature error mismatch: have %v, want %v", err, multisig.ErrInvalidApproval)
+	}
+	if _, err := wallet.Approve(bind.NewKeyedTransactor(key2), session.Hash); err != nil {
+		t.Fatalf("failed to approve on-chain: %v", err)
+	}
+	sim.Commit()
+	if ok, _ := wallet.Approved(nil, session.Hash, addr2); !ok {
+		t.Fatalf("on-chain approval not recorded")
+	}
+	if session, err = backend.ApproveOnChain(session.Hash, addr2); err != nil {
+		t.Fatalf("failed to record on-chain approval: %v", err)
+	}
+	// Execute the call from a third account
+	owners, _ := wallet.GetOwners(nil)
+	sigs, err := session.Signatures(owners, 2)
+	if err != nil {
+		t.Fatalf("failed to assemble signatures: %v", err)
+	}
+	if _, err := wallet.Execute(bind.NewKeyedTransactor(key1), session.To, session.Value.ToInt(), session.Data, sigs); err != nil {
+		t.Fatalf("failed to execute call: %v", err)
+	}
+	sim.Commit()
+
+	if balance, _ := sim.BalanceAt(context.Background(), recipient, nil); balance.Int64() != 1000 {
+		t.Errorf("recipient balance mismatch: have %v, want 1000", balance)
+	}
+	if nonce, _ := wallet.Nonce(nil); nonce.Int64() != 1 {
+		t.Errorf("nonce mismatch: have %v, want 1", nonce)
+	}
+	// Replaying the same approvals must fail
+	if _, err := wallet.Execute(bind.NewKeyedTransactor(key1), session.To, session.Value.ToInt(), session.Data, sigs); err == nil {
+		t.Errorf("replayed call accepted")
+	}
+}
+
+// Tests that the wallet contract rejects insufficient or malformed approvals.
+func TestExecuteInvalid(t *testing.T) {
+	sim := newTestBackend()
+	address, wallet := deploy(t, sim, 2)
+
+	backend, _ := multisig.NewBackend("")
+	session, _ := backend.Propose(chainID, address, recipient, big.NewInt(1000), []byte{0x01}, 0)
+	sig0 := sign(t, session, crypto.FromECDSA(key0))
+	sig1 := sign(t, session, crypto.FromECDSA(key1))
+	sig0[64] += 27
+	sig1[64] += 27
+
+	sig0, sig1 = sig0[:65:65], sig1[:65:65]
+
+	ordered, reversed := append(sig0, sig1...), append(sig1, sig0...)
+	if bytes.Compare(addr0[:], addr1[:]) > 0 {
+		ordered, reversed = reversed, ordered
+	}
+	stranger, _ := crypto.GenerateKey()
+	sigx, _ := crypto.Sign(session.Hash.Bytes(), stranger)
+	sigx[64] += 27
+
+	for i, sigs := range [][]byte{
+		sig0,                  // below threshold
+		append(sig0, sig0...), // duplicate owner
+		reversed,              // unordered owners
+		append(sig0, sigx...), // non-owner
+	} {
+		if _, err := wallet.Execute(bind.NewKeyedTransactor(key1), recipient, big.NewInt(1000), []byte{0x01}, sigs); err == nil {
+			t.Errorf("test %d: invalid approvals accepted", i)
+		}
+	}
+	// A different call or value must not match the approved hash
+	if _, err := wallet.Execute(bind.NewKeyedTransactor(key1), recipient, big.NewInt(1001), []byte{0x01}, ordered); err == nil {
+		t.Errorf("modified call accepted")
+	}
+	// Neither must the same call approved for a different chain
+	foreign, _ := backend.Propose(big.NewInt(1), address, recipient, big.NewInt(1000), []byte{0x01}, 0)
+	if foreign.Hash == session.Hash {
+		t.Fatalf("signing hash independent of the chain id")
+	}
+	for _, key := range []*ecdsa.PrivateKey{key0, key1} {
+		if _, err := backend.Approve(foreign.Hash, crypto.PubkeyToAddress(key.PublicKey), sign(t, foreign, crypto.FromECDSA(key))); err != nil {
+			t.Fatalf("failed to approve foreign session: %v", err)
+		}
+	}
+	foreign, _ = backend.Session(foreign.Hash)
+	replayed, err := foreign.Signatures([]common.Address{addr0, addr1}, 2)
+	if err != nil {
+		t.Fatalf("failed to assemble foreign signatures: %v", err)
+	}
+	if _, err := wallet.Execute(bind.NewKeyedTransactor(key1), recipient, big.NewInt(1000), []byte{0x01}, replayed); err == nil {
+		t.Errorf("call approved for another chain accepted")
+	}
+	if _, err := wallet.Execute(bind.NewKeyedTransactor(key1), recipient, big.NewInt(1000), []byte{0x01}, ordered); err != nil {
+		t.Errorf("valid approvals rejected: %v", err)
+	}
+}
+
+// Tests that sessions collect approvals in owner order and refuse to assemble
+// the execution signatures until the threshold is met.
+func TestSessionApprovals(t *testing.T) {
+	backend, _ := multisig.NewBackend("")
+	session, _ := backend.Propose(chainID, common.Address{0x11}, recipient, big.NewInt(1), nil, 3)
+
+	if _, err := session.Signatures([]common.Address{addr0, addr1, addr2}, 2); err == nil {
+		t.Fatalf("signatures assembled without approvals")
+	}
+	for _, prv := range [][]byte{crypto.FromECDSA(key2), crypto.FromECDSA(key1), crypto.FromECDSA(key0)} {
+		key, _ := crypto.ToECDSA(prv)
+		if _, err := backend.Approve(session.Hash, crypto.PubkeyToAddress(key.PublicKey), sign(t, session, prv)); err != nil {
+			t.Fatalf("failed to approve: %v", err)
+		}
+	}
+	if _, err := backend.Approve(session.Hash, addr0, sign(t, session, crypto.FromECDSA(key0))); err != multisig.ErrDuplicateApproval {
+		t.Errorf("duplicate approval error mismatch: have %v, want %v", err, multisig.ErrDuplicateApproval)
+	}
+	session, _ = backend.Session(session.Hash)
+	for i := 1; i < len(session.Approvals); i++ {
+		if bytes.Compare(session.Approvals[i-1].Owner[:], session.Approvals[i].Owner[:]) >= 0 {
+			t.Fatalf("approvals not sorted by owner")
+		}
+	}
+	// Only approvals of current owners may be used
+	sigs, err := session.Signatures([]common.Address{addr0, addr2}, 2)
+	if err != nil {
+		t.Fatalf("failed to assemble signatures: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		pubkey, err := crypto.SigToPub(session.Hash.Bytes(), append(common.CopyBytes(sigs[i*65:i*65+64]), sigs[i*65+64]-27))
+		if err != nil {
+			t.Fatalf("signature %d invalid: %v", i, err)
+		}
+		if signer := crypto.PubkeyToAddress(*pubkey); signer == addr1 {
+			t.Errorf("signature %d made by non-owner", i)
+		}
+	}
+	if _, err := session.Signatures([]common.Address{addr0}, 2); err == nil {
+		t.Errorf("signatures assembled from non-owners")
+	}
+}
+
+// Tests that tracked wallets and pending sessions survive a restart.
+func TestPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multisig-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "multisig.json")
+
+	backend, err := multisig.NewBackend(path)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	events := make(chan accounts.WalletEvent, 1)
+	sub := backend.Subscribe(events)
+	defer sub.Unsubscribe()
+
+	session, _ := backend.Propose(chainID, common.Address{0x11}, recipient, big.NewInt(1), []byte{0xca, 0xfe}, 0)
+	if ev := <-events; ev.Kind != accounts.WalletArrived || ev.Wallet.URL().Path != strings.ToLower((common.Address{0x11}).Hex()) {
+		t.Errorf("wallet arrival event mismatch: %+v", ev)
+	}
+	// The wallet contract cannot sign, so it must not be listed as an account
+	if accs := backend.Wallets()[0].Accounts(); len(accs) != 0 {
+		t.Errorf("multisig wallet exposed accounts: %v", accs)
+	}
+	session, _ = backend.Approve(session.Hash, addr0, sign(t, session, crypto.FromECDSA(key0)))
+	backend.Propose(chainID, common.Address{0x22}, recipient, big.NewInt(2), nil, 5)
+	<-events
+
+	reloaded, err := multisig.NewBackend(path)
+	if err != nil {
+		t.Fatalf("failed to reload backend: %v", err)
+	}
+	if have, want := len(reloaded.Wallets()), 2; have != want {
+		t.Fatalf("wallet count mismatch: have %d, want %d", have, want)
+	}
+	have, _ := json.Marshal(reloaded.Sessions())
+	want, _ := json.Marshal(backend.Sessions())
+	if !bytes.Equal(have, want) {
+		t.Errorf("session mismatch:\nhave %s\nwant %s", have, want)
+	}
+	if reloaded, _ := reloaded.Session(session.Hash); len(reloaded.Approvals) != 1 || reloaded.Approvals[0].Owner != addr0 {
+		t.Errorf("approvals not persisted: %+v", reloaded.Approvals)
+	}
+	// Untracking a wallet drops its sessions
+	if err := reloaded.Untrack(common.Address{0x11}); err != nil {
+		t.Fatalf("failed to untrack wallet: %v", err)
+	}
+	if _, err := reloaded.Session(session.Hash); err != multisig.ErrUnknownSession {
+		t.Errorf("session of untracked wallet retained: %v", err)
+	}
+}
+
+// Tests that malformed sessions persisted on disk are rejected on load instead
+// of crashing the backend later.
+func TestCorruptPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multisig-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "multisig.json")
+
+	backend, _ := multisig.NewBackend("")
+	session, _ := backend.Propose(chainID, common.Address{0x11}, recipient, big.NewInt(1), nil, 0)
+	valid, _ := json.Marshal(session)
+
+	tests := []string{
+		`{"sessions": [null]}`,
+		strings.Replace(string(valid), `"value":"0x1"`, `"value":null`, 1),
+		strings.Replace(string(valid), `"chainId":"0x539"`, `"chainId":null`, 1),
+		strings.Replace(string(valid), `"approvals":[]`, `"approvals":[null]`, 1),
+		strings.Replace(string(valid), `"nonce":"0x0"`, `"nonce":"0x1"`, 1),
+	}
+	for i, test := range tests {
+		if !strings.HasPrefix(test, `{"sessions"`) {
+			if test == string(valid) {
+				t.Fatalf("test %d: session not modified", i)
+			}
+			test = `{"sessions": [` + test + `]}`
+		}
+		if err := ioutil.WriteFile(path, []byte(test), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := multisig.NewBackend(path); err == nil {
+			t.Errorf("test %d: corrupt session loaded", i)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package multisig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// ErrUnknownSession is returned if an operation is requested on a signing
+	// session the backend is not aware of.
+	ErrUnknownSession = errors.New("unknown multisig session")
+
+	// ErrDuplicateApproval is returned if an owner attempts to approve the same
+	// signing session twice.
+	ErrDuplicateApproval = errors.New("duplicate approval")
+
+	// ErrInvalidApproval is returned if an approval signature does not recover
+	// to the owner it was submitted for.
+	ErrInvalidApproval = errors.New("approval signature does not match owner")
+)
+
+// SigningHash calculates the hash the owners of a multisig wallet need to
+// approve to let the wallet call destination with the given value and data. The
+// hash follows EIP-191 version 0x00 with the wallet as the intended validator,
+// i.e. keccak256(0x19 0x00 wallet chainId destination value keccak256(data) nonce),
+// all fields following the wallet address being padded to 32 bytes. Like in
+// EIP-155, the chain id prevents replaying approvals on other chains.
+func SigningHash(chainID *big.Int, wallet, destination common.Address, value *big.Int, data []byte, nonce uint64) common.Hash {
+	if chainID == nil {
+		chainID = new(big.Int)
+	}
+	if value == nil {
+		value = new(big.Int)
+	}
+	return crypto.Keccak256Hash(
+		[]byte{0x19, 0x00},
+		wallet.Bytes(),
+		math.PaddedBigBytes(math.U256(new(big.Int).Set(chainID)), 32),
+		common.LeftPadBytes(destination.Bytes(), 32),
+		math.PaddedBigBytes(math.U256(new(big.Int).Set(value)), 32),
+		crypto.Keccak256(data),
+		math.PaddedBigBytes(new(big.Int).SetUint64(nonce), 32),
+	)
+}
+
+// Approval is a single owner's consent to a signing session, either in the form
+// of a signature over the session hash, or as a reference to an approve call
+// the owner made on-chain.
+type Approval struct {
+	Owner     common.Address `json:"owner"`
+	Signature hexutil.Bytes  `json:"signature,omitempty"` // 65 byte [R || S || V] signature, V being 27 or 28
+	OnChain   bool           `json:"onChain,omitempty"`   // Whether the owner approved the hash on-chain
+}
+
+// encode converts the approval into the 65 byte format expected by the wallet
+// contract. On-chain approvals are encoded as the owner address in R and a zero
+// V value.
+func (a *Approval) encode() []byte {
+	if a.OnChain {
+		blob := make([]byte, 65)
+		copy(blob[12:32], a.Owner.Bytes())
+		return blob
+	}
+	return common.CopyBytes(a.Signature)
+}
+
+// Session is a pending multisig transaction, collecting approvals from the
+// wallet owners until enough are gathered for it to be executed.
+type Session struct {
+	ChainID   *hexutil.Big   `json:"chainId"`   // Chain the wallet contract lives on
+	Wallet    common.Address `json:"wallet"`    // Multisig contract executing the call
+	To        common.Address `json:"to"`        // Destination of the call
+	Value     *hexutil.Big   `json:"value"`     // Amount of wei to send along
+	Data      hexutil.Bytes  `json:"data"`      // Call data to send along
+	Nonce     hexutil.Uint64 `json:"nonce"`     // Wallet nonce the session is valid for
+	Hash      common.Hash    `json:"hash"`      // Signing hash owners need to approve
+	Approvals []*Approval    `json:"approvals"` // Approvals collected, sorted by owner
+}
+
+// newSession creates a signing session for the given wallet call.
+func newSession(chainID *big.Int, wallet, to common.Address, value *big.Int, data []byte, nonce uint64) *Session {
+	if chainID == nil {
+		chainID = new(big.Int)
+	}
+	if value == nil {
+		value = new(big.Int)
+	}
+	return &Session{
+		ChainID:   (*hexutil.Big)(new(big.Int).Set(chainID)),
+		Wallet:    wallet,
+		To:        to,
+		Value:     (*hexutil.Big)(new(big.Int).Set(value)),
+		Data:      common.CopyBytes(data),
+		Nonce:     hexutil.Uint64(nonce),
+		Hash:      SigningHash(chainID, wallet, to, value, data, nonce),
+		Approvals: []*Approval{},
+	}
+}
+
+// validate checks a session loaded from disk: all fields must be present, the
+// hash must match the call and the approvals must be well formed.
+func (s *Session) validate() error {
+	if s.ChainID == nil || s.Value == nil {
+		return errors.New("missing chain id or value")
+	}
+	if hash := SigningHash(s.ChainID.ToInt(), s.Wallet, s.To, s.Value.ToInt(), s.Data, uint64(s.Nonce)); hash != s.Hash {
+		return fmt.Errorf("hash mismatch: have %x, want %x", s.Hash, hash)
+	}
+	for _, approval := range s.Approvals {
+		if approval == nil {
+			return errors.New("missing approval")
+		}
+		if !approval.OnChain && len(approval.Signature) != 65 {
+			return fmt.Errorf("invalid signature length of owner %x: %d", approval.Owner, len(approval.Signature))
+		}
+	}
+	return nil
+}
+
+// Approved returns whether the given owner already approved the session.
+func (s *Session) Approved(owner common.Address) bool {
+	for _, approval := range s.Approvals {
+		if approval.Owner == owner {
+			return true
+		}
+	}
+	return false
+}
+
+// approve verifies that sig is a signature of the session hash made by owner and
+// adds it to the collected approvals. The V value of the signature may be either
+// 0/1 or 27/28.
+func (s *Session) approve(owner common.Address, sig []byte) error {
+	if len(sig) != 65 {
+		return fmt.Errorf("invalid signature length: %d", len(sig))
+	}
+	sig = common.CopyBytes(sig)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	pubkey, err := crypto.SigToPub(s.Hash.Bytes(), sig)
+	if err != nil {
+		return err
+	}
+	if crypto.PubkeyToAddress(*pubkey) != owner {
+		return ErrInvalidApproval
+	}
+	sig[64] += 27
+	return s.add(&Approval{Owner: owner, Signature: sig})
+}
+
+// approveOnChain records that owner approved the session hash by calling the
+// wallet contract directly.
+func (s *Session) approveOnChain(owner common.Address) error {
+	return s.add(&Approval{Owner: owner, OnChain: true})
+}
+
+// add inserts an approval, keeping the list sorted by owner address.
+func (s *Session) add(approval *Approval) error {
+	if s.Approved(approval.Owner) {
+		return ErrDuplicateApproval
+	}
+	s.Approvals = append(s.Approvals, approval)
+	sort.Slice(s.Approvals, func(i, j int) bool {
+		return bytes.Compare(s.Approvals[i].Owner[:], s.Approvals[j].Owner[:]) < 0
+	})
+	return nil
+}
+
+// Signatures assembles the approval blob accepted by the wallet contract's
+// execute method: exactly threshold approvals, ordered by owner address.
+// Approvals by accounts not among the given owners are skipped.
+func (s *Session) Signatures(owners []common.Address, threshold int) ([]byte, error) {
+	valid := make(map[common.Address]bool)
+	for _, owner := range owners {
+		valid[owner] = true
+	}
+	var blob []byte
+	for _, approval := range s.Approvals {
+		if len(blob) == 65*threshold {
+			break
+		}
+		if valid[approval.Owner] {
+			blob = append(blob, approval.encode()...)
+		}
+	}
+	if len(blob) < 65*threshold {
+		return nil, fmt.Errorf("insufficient approvals: have %d, want %d", len(blob)/65, threshold)
+	}
+	return blob, nil
+}
+
+// copy creates a deep copy of the session.
+func (s *Session) copy() *Session {
+	cpy := *s
+	cpy.ChainID = (*hexutil.Big)(new(big.Int).Set(s.ChainID.ToInt()))
+	cpy.Value = (*hexutil.Big)(new(big.Int).Set(s.Value.ToInt()))
+	cpy.Data = common.CopyBytes(s.Data)
+	cpy.Approvals = make([]*Approval, len(s.Approvals))
+	for i, approval := range s.Approvals {
+		a := *approval
+		a.Signature = common.CopyBytes(approval.Signature)
+		cpy.Approvals[i] = &a
+	}
+	return &cpy
+}
@@ -19,6 +19,7 @@ package main
 
 import (
 	"crypto/ecdsa"
+	"errors"
 	"flag"
 	"fmt"
 	"net"
@@ -28,7 +29,6 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/discover"
-	"github.com/ethereum/go-ethereum/p2p/discv5"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/nat"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
@@ -43,7 +43,7 @@ func main() {
 		nodeKeyHex  = flag.String("nodekeyhex", "", "private key as hex (for testing)")
 		natdesc     = flag.String("nat", "none", "port mapping mechanism (any|none|upnp|pmp|extip:<IP>)")
 		netrestrict = flag.String("netrestrict", "", "restrict network communication to the given IP networks (CIDR masks)")
-		runv5       = flag.Bool("v5", false, "run a v5 topic discovery bootnode")
+		runv5       = flag.Bool("v5", false, "run v5 topic discovery alongside v4 on the same port")
 		verbosity   = flag.Int("verbosity", int(log.LvlInfo), "log verbosity (0-9)")
 		vmodule     = flag.String("vmodule", "", "log verbosity pattern")
 
@@ -118,21 +118,48 @@ func main() {
 		}
 	}
 
+	db, _ := enode.OpenDB("")
+	ln := enode.NewLocalNode(db, nodeKey)
+	ln.SetFallbackIP(realaddr.IP)
+	ln.SetFallbackUDP(realaddr.Port)
+
+	cfg := discover.Config{
+		PrivateKey:  nodeKey,
+		NetRestrict: restrictList,
+	}
+	var unhandled chan discover.ReadPacket
 	if *runv5 {
-		if _, err := discv5.ListenUDP(nodeKey, conn, "", restrictList); err != nil {
-			utils.Fatalf("%v", err)
-		}
-	} else {
-		db, _ := enode.OpenDB("")
-		ln := enode.NewLocalNode(db, nodeKey)
-		cfg := discover.Config{
-			PrivateKey:  nodeKey,
-			NetRestrict: restrictList,
-		}
-		if _, err := discover.ListenUDP(conn, ln, cfg); err != nil {
+		unhandled = make(chan discover.ReadPacket, 100)
+		cfg.Unhandled = unhandled
+	}
+	if _, err := discover.ListenUDP(conn, ln, cfg); err != nil {
+		utils.Fatalf("%v", err)
+	}
+	if *runv5 {
+		cfg.Unhandled = nil
+		if _, err := discover.ListenV5(&sharedUDPConn{conn, unhandled}, ln, cfg); err != nil {
 			utils.Fatalf("%v", err)
 		}
 	}
 
 	select {}
 }
+
+// sharedUDPConn passes the packets not handled by discovery v4 to v5, while
+// writing directly to the underlying socket.
+type sharedUDPConn struct {
+	*net.UDPConn
+	unhandled chan discover.ReadPacket
+}
+
+func (s *sharedUDPConn) ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err error) {
+	packet, ok := <-s.unhandled
+	if !ok {
+		return 0, nil, errors.New("connection was closed")
+	}
+	return copy(b, packet.Data), packet.Addr, nil
+}
+
+func (s *sharedUDPConn) Close() error {
+	return nil
+}
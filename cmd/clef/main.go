@@ -50,10 +50,10 @@ import (
 )
 
 // ExternalAPIVersion -- see extapi_changelog.md
-const ExternalAPIVersion = "4.0.0"
+const ExternalAPIVersion = "4.1.0"
 
 // InternalAPIVersion -- see intapi_changelog.md
-const InternalAPIVersion = "3.0.0"
+const InternalAPIVersion = "3.1.0"
 
 const legalWarning = `
 WARNING! 
@@ -115,6 +115,15 @@ var (
 		Usage: "Enable rule-engine",
 		Value: "rules.json",
 	}
+	policyFlag = cli.StringFlag{
+		Name:  "policy",
+		Usage: "File containing declarative signing policies (spending limits, recipients, methods, time windows)",
+	}
+	attestTargetFlag = cli.StringFlag{
+		Name:  "target",
+		Usage: "File whose sha256 is attested, either \"rules\" or \"policy\"",
+		Value: "rules",
+	}
 	stdiouiFlag = cli.BoolFlag{
 		Name: "stdio-ui",
 		Usage: "Use STDIN/STDOUT as a channel for an external UI. " +
@@ -148,13 +157,16 @@ the rule-engine to work.`,
 			logLevelFlag,
 			configdirFlag,
 			signerSecretFlag,
+			attestTargetFlag,
 		},
 		Description: `
 The attest command stores the sha256 of the rule.js-file that you want to use for automatic processing of 
 incoming requests. 
 
 Whenever you make an edit to the rule file, you need to use attestation to tell 
-Clef that the file is 'safe' to execute.`,
+Clef that the file is 'safe' to execute.
+
+With --target policy, the sha256 of the declarative policy file is attested instead.`,
 	}
 
 	setCredentialCommand = cli.Command{
@@ -195,6 +207,7 @@ func init() {
 		customDBFlag,
 		auditLogFlag,
 		ruleFlag,
+		policyFlag,
 		stdiouiFlag,
 		testFlag,
 		advancedMode,
@@ -288,8 +301,16 @@ func attestFile(ctx *cli.Context) error {
 	// Initialize the encrypted storages
 	configStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "config.json"), confKey)
 	val := ctx.Args().First()
-	configStorage.Put("ruleset_sha256", val)
-	log.Info("Ruleset attestation updated", "sha256", val)
+	switch target := ctx.String(attestTargetFlag.Name); target {
+	case "rules":
+		configStorage.Put("ruleset_sha256", val)
+		log.Info("Ruleset attestation updated", "sha256", val)
+	case "policy":
+		configStorage.Put("policy_sha256", val)
+		log.Info("Policy attestation updated", "sha256", val)
+	default:
+		utils.Fatalf("Unknown attestation target %q, want \"rules\" or \"policy\"", target)
+	}
 	return nil
 }
 
@@ -359,11 +380,22 @@ func signer(c *cli.Context) error {
 	log.Info("Loaded 4byte db", "signatures", db.Size(), "file", fourByteDb, "local", fourByteLocal)
 
 	var (
-		api core.ExternalAPI
+		api      core.ExternalAPI
+		auditLog log.Logger
 	)
-
+	// Audit logging, shared by the API and the policy engine
+	if logfile := c.GlobalString(auditLogFlag.Name); logfile != "" {
+		auditLog, err = core.NewAuditLog(logfile)
+		if err != nil {
+			utils.Fatalf(err.Error())
+		}
+		log.Info("Audit logs configured", "file", logfile)
+	}
 	configDir := c.GlobalString(configdirFlag.Name)
 	if stretchedKey, err := readMasterKey(c, ui); err != nil {
+		if c.GlobalIsSet(policyFlag.Name) {
+			utils.Fatalf("Signing policies require a master seed: %v", err)
+		}
 		log.Info("No master seed provided, rules disabled", "error", err)
 	} else {
 
@@ -376,11 +408,13 @@ func signer(c *cli.Context) error {
 		pwkey := crypto.Keccak256([]byte("credentials"), stretchedKey)
 		jskey := crypto.Keccak256([]byte("jsstorage"), stretchedKey)
 		confkey := crypto.Keccak256([]byte("config"), stretchedKey)
+		policykey := crypto.Keccak256([]byte("policystorage"), stretchedKey)
 
 		// Initialize the encrypted storages
 		pwStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "credentials.json"), pwkey)
 		jsStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "jsstorage.json"), jskey)
 		configStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "config.json"), confkey)
+		policyStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "policy.json"), policykey)
 
 		//Do we have a rule-file?
 		ruleJS, err := ioutil.ReadFile(c.GlobalString(ruleFlag.Name))
@@ -404,6 +438,29 @@ func signer(c *cli.Context) error {
 				log.Info("Rule engine configured", "file", c.String(ruleFlag.Name))
 			}
 		}
+		// Declarative policies are evaluated before the rules. Unlike the rules, they
+		// only ever reject requests, so failing to load them is fatal.
+		if file := c.GlobalString(policyFlag.Name); file != "" {
+			policyJSON, err := ioutil.ReadFile(file)
+			if err != nil {
+				utils.Fatalf("Could not load policy file: %v", err)
+			}
+			shasum := sha256.Sum256(policyJSON)
+			if stored := configStorage.Get("policy_sha256"); stored != hex.EncodeToString(shasum[:]) {
+				utils.Fatalf("Could not validate policy hash, got %x, expected %s", shasum, stored)
+			}
+			// Parse the very bytes that were hashed, the file may change meanwhile
+			policy, err := rules.ParsePolicy(policyJSON)
+			if err != nil {
+				utils.Fatalf("Invalid policy file: %v", err)
+			}
+			policyEngine, err := rules.NewPolicyEvaluator(ui, policy, db, policyStorage, auditLog)
+			if err != nil {
+				utils.Fatalf(err.Error())
+			}
+			ui = policyEngine
+			log.Info("Policy engine configured", "file", file)
+		}
 	}
 
 	apiImpl := core.NewSignerAPI(
@@ -415,12 +472,8 @@ func signer(c *cli.Context) error {
 		c.GlobalBool(advancedMode.Name))
 	api = apiImpl
 	// Audit logging
-	if logfile := c.GlobalString(auditLogFlag.Name); logfile != "" {
-		api, err = core.NewAuditLogger(logfile, api)
-		if err != nil {
-			utils.Fatalf(err.Error())
-		}
-		log.Info("Audit logs configured", "file", logfile)
+	if auditLog != nil {
+		api = core.NewAuditLoggerWithLog(auditLog, api)
 	}
 	// register signer API with server
 	var (
@@ -0,0 +1,155 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	crawlWorkers    = 16             // number of concurrent record requests
+	nodeRemoveAfter = 12 * time.Hour // unresponsive nodes are removed after this time
+)
+
+// discoverySource is the part of the discovery table used by the crawler.
+type discoverySource interface {
+	LookupRandom() []*enode.Node
+	RequestENR(n *enode.Node) (*enode.Node, error)
+}
+
+// crawler collects the signed records of all nodes reachable through the
+// discovery network, revalidating the nodes of an existing set first.
+type crawler struct {
+	input  nodeSet
+	disc   discoverySource
+	recent time.Duration // nodes checked more recently are skipped
+
+	mu     sync.Mutex
+	output nodeSet
+}
+
+func newCrawler(input nodeSet, disc discoverySource, recent time.Duration) *crawler {
+	output := make(nodeSet, len(input))
+	for id, n := range input {
+		output[id] = n
+	}
+	return &crawler{input: input, disc: disc, recent: recent, output: output}
+}
+
+// run crawls the network for the given duration and returns the resulting set.
+func (c *crawler) run(timeout time.Duration) nodeSet {
+	var (
+		nodes = make(chan *enode.Node)
+		done  = make(chan struct{})
+		wg    sync.WaitGroup
+	)
+	// Feed the input nodes, then random lookup results to the workers.
+	go func() {
+		defer close(nodes)
+		for _, n := range c.input.nodes() {
+			select {
+			case nodes <- n:
+			case <-done:
+				return
+			}
+		}
+		for {
+			results := c.disc.LookupRandom()
+			for _, n := range results {
+				select {
+				case nodes <- n:
+				case <-done:
+					return
+				}
+			}
+			wait := time.Duration(0)
+			if len(results) == 0 {
+				wait = time.Second // don't spin on an empty table
+			}
+			select {
+			case <-time.After(wait):
+			case <-done:
+				return
+			}
+		}
+	}()
+	for i := 0; i < crawlWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range nodes {
+				c.updateNode(n)
+			}
+		}()
+	}
+
+	<-time.After(timeout)
+	close(done)
+	wg.Wait()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.output
+}
+
+// updateNode requests the record of a node and updates its entry in the output.
+func (c *crawler) updateNode(n *enode.Node) {
+	c.mu.Lock()
+	node, ok := c.output[n.ID()]
+	if ok && time.Since(node.LastCheck) < c.recent {
+		c.mu.Unlock()
+		return
+	}
+	if node.N == nil {
+		node.N = n
+	}
+	node.LastCheck = time.Now()
+	c.output[n.ID()] = node
+	c.mu.Unlock()
+
+	// Fetch the signed record of the node.
+	nn, err := c.disc.RequestENR(n)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node = c.output[n.ID()]
+	if err != nil {
+		node.Score /= 2
+		if node.LastResponse.IsZero() || time.Since(node.LastResponse) > nodeRemoveAfter {
+			delete(c.output, n.ID())
+			log.Debug("Removing unresponsive node", "id", n.ID(), "err", err)
+			return
+		}
+		c.output[n.ID()] = node
+		return
+	}
+	if node.N == nil || nn.Seq() >= node.N.Seq() {
+		node.N = nn
+	}
+	node.Score++
+	if node.FirstResponse.IsZero() {
+		node.FirstResponse = time.Now()
+	}
+	node.LastResponse = time.Now()
+	c.output[n.ID()] = node
+	log.Debug("Updated node record", "id", n.ID(), "seq", node.N.Seq(), "score", node.Score)
+}
@@ -0,0 +1,165 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	discv4Command = cli.Command{
+		Name:  "discv4",
+		Usage: "Node Discovery v4 tools",
+		Subcommands: []cli.Command{
+			discv4RequestRecordCommand,
+			discv4CrawlCommand,
+		},
+	}
+	discv4RequestRecordCommand = cli.Command{
+		Name:      "requestenr",
+		Usage:     "Requests a node record using EIP-868 enrRequest",
+		Action:    discv4RequestRecord,
+		ArgsUsage: "<node>",
+		Flags:     []cli.Flag{bootnodesFlag},
+	}
+	discv4CrawlCommand = cli.Command{
+		Name:      "crawl",
+		Usage:     "Updates a nodes.json file with random nodes found in the DHT",
+		Action:    discv4Crawl,
+		ArgsUsage: "<nodes.json>",
+		Flags:     []cli.Flag{bootnodesFlag, crawlTimeoutFlag},
+	}
+)
+
+var (
+	bootnodesFlag = cli.StringFlag{
+		Name:  "bootnodes",
+		Usage: "Comma separated nodes used for bootstrapping",
+	}
+	crawlTimeoutFlag = cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "Time limit for the crawl",
+		Value: 30 * time.Minute,
+	}
+)
+
+func discv4RequestRecord(ctx *cli.Context) error {
+	arg, err := getNodeArg(ctx)
+	if err != nil {
+		return err
+	}
+	n, err := enode.Parse(enode.ValidSchemes, arg)
+	if err != nil {
+		return fmt.Errorf("invalid node: %v", err)
+	}
+	disc, err := startV4(ctx)
+	if err != nil {
+		return err
+	}
+	defer disc.Close()
+
+	r, err := disc.RequestENR(n)
+	if err != nil {
+		return fmt.Errorf("can't retrieve record: %v", err)
+	}
+	text, err := r.TextRecord()
+	if err != nil {
+		return err
+	}
+	fmt.Println(text)
+	return nil
+}
+
+func discv4Crawl(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("need nodes file as argument")
+	}
+	nodesFile := ctx.Args().First()
+	inputSet := make(nodeSet)
+	if fileExists(nodesFile) {
+		set, err := loadNodesJSON(nodesFile)
+		if err != nil {
+			return err
+		}
+		inputSet = set
+	}
+
+	disc, err := startV4(ctx)
+	if err != nil {
+		return err
+	}
+	defer disc.Close()
+
+	c := newCrawler(inputSet, disc, 15*time.Minute)
+	output := c.run(ctx.Duration(crawlTimeoutFlag.Name))
+	return writeNodesJSON(nodesFile, output)
+}
+
+// startV4 starts an ephemeral discovery v4 node.
+func startV4(ctx *cli.Context) (*discover.Table, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	bootnodes, err := parseBootnodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	db, err := enode.OpenDB("")
+	if err != nil {
+		return nil, err
+	}
+	ln := enode.NewLocalNode(db, key)
+
+	socket, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IP{0, 0, 0, 0}})
+	if err != nil {
+		return nil, err
+	}
+	addr := socket.LocalAddr().(*net.UDPAddr)
+	ln.SetFallbackIP(net.IP{127, 0, 0, 1})
+	ln.SetFallbackUDP(addr.Port)
+
+	cfg := discover.Config{PrivateKey: key, Bootnodes: bootnodes}
+	return discover.ListenUDP(socket, ln, cfg)
+}
+
+// parseBootnodes returns the bootstrap nodes given on the command line, or the
+// main network bootnodes if none were given.
+func parseBootnodes(ctx *cli.Context) ([]*enode.Node, error) {
+	s := params.MainnetBootnodes
+	if ctx.IsSet(bootnodesFlag.Name) {
+		s = strings.Split(ctx.String(bootnodesFlag.Name), ",")
+	}
+	nodes := make([]*enode.Node, len(s))
+	for i, record := range s {
+		n, err := enode.Parse(enode.ValidSchemes, strings.TrimSpace(record))
+		if err != nil {
+			return nil, fmt.Errorf("invalid bootstrap node: %v", err)
+		}
+		nodes[i] = n
+	}
+	return nodes, nil
+}
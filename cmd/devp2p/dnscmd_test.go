@@ -0,0 +1,116 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// This test checks that a tree definition can be signed and exported as TXT
+// records, and that the signature is bound to the node list.
+func TestDNSSignExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devp2p-dns-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Create the definition of a tree with a few nodes.
+	treedir := filepath.Join(dir, "nodes.example.org")
+	nodes := make(nodeSet)
+	for i := 0; i < 5; i++ {
+		nodes.add(testNode(t, i))
+	}
+	if err := writeTreeDefinition(treedir, &dnsDefinition{Nodes: nodes}); err != nil {
+		t.Fatal(err)
+	}
+	keyfile := filepath.Join(dir, "key")
+	key, _ := crypto.GenerateKey()
+	if err := crypto.SaveECDSA(keyfile, key); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unsigned trees can't be exported.
+	if _, _, err := loadTreeDefinitionForExport(treedir); err == nil {
+		t.Fatal("exported unsigned tree")
+	}
+	// Sign the tree and export it.
+	if err := dnsSign(newContext(t, dnsSignCommand, treedir, keyfile)); err != nil {
+		t.Fatal("sign error:", err)
+	}
+	domain, tree, err := loadTreeDefinitionForExport(treedir)
+	if err != nil {
+		t.Fatal("export error:", err)
+	}
+	if domain != "nodes.example.org" {
+		t.Errorf("wrong domain %q", domain)
+	}
+	if tree.Seq() != 1 || len(tree.Nodes()) != len(nodes) {
+		t.Errorf("wrong tree: seq %d, %d nodes", tree.Seq(), len(tree.Nodes()))
+	}
+	if txt := tree.ToTXT(domain); txt[domain] == "" {
+		t.Errorf("missing root record")
+	}
+
+	// Modifying the node list invalidates the signature.
+	def := loadTreeDefinition(treedir)
+	def.Nodes.add(testNode(t, 5))
+	if err := writeTreeDefinition(treedir, def); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := loadTreeDefinitionForExport(treedir); err == nil {
+		t.Fatal("exported tree with stale signature")
+	}
+}
+
+func testNode(t *testing.T, i int) *enode.Node {
+	key, _ := crypto.GenerateKey()
+	var r enr.Record
+	r.Set(enr.IP(net.IP{127, 0, 0, 1}))
+	r.Set(enr.TCP(30303 + i))
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func newContext(t *testing.T, cmd cli.Command, args ...string) *cli.Context {
+	set := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	for _, f := range cmd.Flags {
+		f.Apply(set)
+	}
+	if err := set.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	ctx := cli.NewContext(app, set, nil)
+	ctx.Command = cmd
+	return ctx
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// nodeSet is the content of a nodes.json file, a set of node records keyed by
+// node ID.
+type nodeSet map[enode.ID]nodeJSON
+
+type nodeJSON struct {
+	Seq    uint64      `json:"seq"`
+	N      *enode.Node `json:"-"`
+	Record string      `json:"record"`
+
+	// The score counts successful liveness checks. It is incremented every time
+	// the node answers and halved every time it doesn't.
+	Score int `json:"score,omitempty"`
+
+	FirstResponse time.Time `json:"firstResponse,omitempty"` // time of first successful contact
+	LastResponse  time.Time `json:"lastResponse,omitempty"`  // time of last successful contact
+	LastCheck     time.Time `json:"lastCheck,omitempty"`     // time of last contact attempt
+}
+
+// loadNodesJSON reads a node set from the given file.
+func loadNodesJSON(file string) (nodeSet, error) {
+	blob, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[enode.ID]nodeJSON
+	if err := json.Unmarshal(blob, &raw); err != nil {
+		return nil, fmt.Errorf("invalid node set %s: %v", file, err)
+	}
+	ns := make(nodeSet, len(raw))
+	for id, n := range raw {
+		node, err := enode.Parse(enode.ValidSchemes, n.Record)
+		if err != nil {
+			return nil, fmt.Errorf("invalid record of node %v in %s: %v", id, file, err)
+		}
+		if node.ID() != id {
+			return nil, fmt.Errorf("record of node %v in %s has ID %v", id, file, node.ID())
+		}
+		n.N = node
+		ns[id] = n
+	}
+	return ns, nil
+}
+
+// writeNodesJSON writes a node set to the given file, or to stdout if the file
+// name is "-".
+func writeNodesJSON(file string, nodes nodeSet) error {
+	for id, n := range nodes {
+		record, err := n.N.TextRecord()
+		if err != nil {
+			return fmt.Errorf("can't encode record of node %v: %v", id, err)
+		}
+		n.Record, n.Seq = record, n.N.Seq()
+		nodes[id] = n
+	}
+	nodesJSON, err := json.MarshalIndent(nodes, "", jsonIndent)
+	if err != nil {
+		return err
+	}
+	if file == "-" {
+		os.Stdout.Write(nodesJSON)
+		return nil
+	}
+	return ioutil.WriteFile(file, nodesJSON, 0644)
+}
+
+// nodes returns the node records contained in the set.
+func (ns nodeSet) nodes() []*enode.Node {
+	result := make([]*enode.Node, 0, len(ns))
+	for _, n := range ns {
+		result = append(result, n.N)
+	}
+	// Sort by ID.
+	sort.Slice(result, func(i, j int) bool {
+		return bytes.Compare(result[i].ID().Bytes(), result[j].ID().Bytes()) < 0
+	})
+	return result
+}
+
+// add inserts the given nodes into the set, replacing older records.
+func (ns nodeSet) add(nodes ...*enode.Node) {
+	for _, n := range nodes {
+		v := ns[n.ID()]
+		if v.N == nil || n.Seq() >= v.N.Seq() {
+			v.N = n
+			ns[n.ID()] = v
+		}
+	}
+}
+
+// verify checks that all records of the set are signed and carry an endpoint.
+func (ns nodeSet) verify() error {
+	for id, n := range ns {
+		if _, err := n.N.TextRecord(); err != nil {
+			return fmt.Errorf("node %v: %v", id, err)
+		}
+		if n.N.IP() == nil || n.N.TCP() == 0 {
+			return fmt.Errorf("node %v has no TCP endpoint", id)
+		}
+	}
+	return nil
+}
@@ -20,10 +20,14 @@ var commandChangePassphrase = cli.Command{
 	Usage:     "change the passphrase on a keyfile",
 	ArgsUsage: "<keyfile>",
 	Description: `
-Change the passphrase of a keyfile.`,
+Change the passphrase of a keyfile.
+
+The key is re-encrypted with the key-derivation function given by --kdf, which
+can be used to migrate existing scrypt keyfiles to argon2id.`,
 	Flags: []cli.Flag{
 		passphraseFlag,
 		newPassphraseFlag,
+		kdfFlag,
 	},
 	Action: func(ctx *cli.Context) error {
 		keyfilepath := ctx.Args().First()
@@ -55,7 +59,7 @@ Change the passphrase of a keyfile.`,
 		}
 
 		// Encrypt the key with the new passphrase.
-		newJson, err := keystore.EncryptKey(key, newPhrase, keystore.StandardScryptN, keystore.StandardScryptP)
+		newJson, err := keystore.EncryptKeyWithKDF(key, newPhrase, getKDF(ctx))
 		if err != nil {
 			utils.Fatalf("Error encrypting with new passphrase: %v", err)
 		}
@@ -48,6 +48,7 @@ If you want to encrypt an existing private key, it can be specified by setting
 	Flags: []cli.Flag{
 		passphraseFlag,
 		jsonFlag,
+		kdfFlag,
 		cli.StringFlag{
 			Name:  "privatekey",
 			Usage: "file containing a raw private key to encrypt",
@@ -91,7 +92,7 @@ If you want to encrypt an existing private key, it can be specified by setting
 
 		// Encrypt key with passphrase.
 		passphrase := promptPassphrase(true)
-		keyjson, err := keystore.EncryptKey(key, passphrase, keystore.StandardScryptN, keystore.StandardScryptP)
+		keyjson, err := keystore.EncryptKeyWithKDF(key, passphrase, getKDF(ctx))
 		if err != nil {
 			utils.Fatalf("Error encrypting key: %v", err)
 		}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -54,6 +55,11 @@ var (
 		Name:  "json",
 		Usage: "output JSON instead of human-readable format",
 	}
+	kdfFlag = cli.StringFlag{
+		Name:  "kdf",
+		Usage: `the key-derivation function to encrypt the key with ("scrypt" or "argon2id")`,
+		Value: keystore.KDFScrypt,
+	}
 )
 
 func main() {
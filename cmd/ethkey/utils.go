@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 	"strings"
 
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/console"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -68,6 +69,16 @@ func getPassphrase(ctx *cli.Context) string {
 	return promptPassphrase(false)
 }
 
+// getKDF returns the standard parameters of the key-derivation function given
+// by the --kdf command line flag.
+func getKDF(ctx *cli.Context) keystore.KDFParams {
+	kdf, err := keystore.StandardKDF(ctx.String(kdfFlag.Name), false)
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	return kdf
+}
+
 // signHash is a helper function that calculates a hash for the given message
 // that can be safely used to calculate a signature from.
 //
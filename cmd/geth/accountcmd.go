@@ -17,19 +17,28 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/hdwallet"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/console"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
 	"gopkg.in/urfave/cli.v1"
 )
 
 var (
+	bip39PassphraseFlag = cli.StringFlag{
+		Name:  "bip39password",
+		Usage: "File containing the BIP-39 passphrase of the mnemonic, if any",
+	}
+
 	walletCommand = cli.Command{
 		Name:      "wallet",
 		Usage:     "Manage Ethereum presale wallets",
@@ -54,6 +63,7 @@ passwordfile as argument containing the wallet password in plaintext.`,
 					utils.KeyStoreDirFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
+					utils.KDFFlag,
 				},
 				Description: `
 	geth wallet [options] /path/to/my/presale.wallet
@@ -110,6 +120,7 @@ Print a short summary of all accounts`,
 					utils.KeyStoreDirFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
+					utils.KDFFlag,
 				},
 				Description: `
     geth account new
@@ -135,6 +146,7 @@ password to file or expose in any other way.
 					utils.DataDirFlag,
 					utils.KeyStoreDirFlag,
 					utils.LightKDFFlag,
+					utils.KDFFlag,
 				},
 				Description: `
     geth account update <address>
@@ -147,6 +159,11 @@ for a passphrase to unlock the account and another to save the updated file.
 This same command can therefore be used to migrate an account of a deprecated
 format to the newest format or change the password for an account.
 
+The key is re-encrypted with the key-derivation function selected by --kdf, so
+existing scrypt key files can be migrated to argon2id:
+
+    geth account update --kdf argon2id <address>
+
 For non-interactive use the passphrase can be specified with the --password flag:
 
     geth account update [options] <address>
@@ -164,6 +181,7 @@ changing your password is only possible interactively.
 					utils.KeyStoreDirFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
+					utils.KDFFlag,
 				},
 				ArgsUsage: "<keyFile>",
 				Description: `
@@ -186,6 +204,40 @@ Note:
 As you can directly copy your encrypted accounts to another ethereum instance,
 this import mechanism is not needed when you transfer an account between
 nodes.
+`,
+			},
+			{
+				Name:   "import-mnemonic",
+				Usage:  "Import a BIP-39 mnemonic into a new HD wallet",
+				Action: utils.MigrateFlags(accountImportMnemonic),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.LightKDFFlag,
+					utils.KDFFlag,
+					bip39PassphraseFlag,
+				},
+				ArgsUsage: "[ <mnemonicFile> ]",
+				Description: `
+    geth account import-mnemonic [<mnemonicfile>]
+
+Imports a BIP-39 mnemonic and creates a new hierarchical deterministic wallet
+from it. Prints the URL of the wallet and the address of its first account,
+derived at m/44'/60'/0'/0/0.
+
+If no mnemonic file is given, you are prompted for the mnemonic and its optional
+BIP-39 passphrase. Otherwise the BIP-39 passphrase can be given in a file with
+the --bip39password flag.
+
+The seed of the wallet is saved in encrypted format in the "hd" folder of the
+keystore, you are prompted for a passphrase. Further accounts can be derived
+from the wallet with personal.deriveAccount, after opening it with
+personal.openWallet.
+
+For non-interactive use the passphrase can be specified with the --password flag:
+
+    geth account import-mnemonic [options] <mnemonicfile>
 `,
 			},
 		},
@@ -289,6 +341,16 @@ func ambiguousAddrRecovery(ks *keystore.KeyStore, err *keystore.AmbiguousAddrErr
 	return *match
 }
 
+// fetchKeystore retrieves the local keystore of the node, failing if signing is
+// delegated to an external signer.
+func fetchKeystore(stack *node.Node) *keystore.KeyStore {
+	backends := stack.AccountManager().Backends(keystore.KeyStoreType)
+	if len(backends) == 0 {
+		utils.Fatalf("Local accounts are not available when signing is delegated to an external signer")
+	}
+	return backends[0].(*keystore.KeyStore)
+}
+
 // accountCreate creates a new account into the keystore defined by the CLI flags.
 func accountCreate(ctx *cli.Context) error {
 	cfg := gethConfig{Node: defaultNodeConfig()}
@@ -299,7 +361,7 @@ func accountCreate(ctx *cli.Context) error {
 		}
 	}
 	utils.SetNodeConfig(ctx, &cfg.Node)
-	scryptN, scryptP, keydir, err := cfg.Node.AccountConfig()
+	kdf, keydir, err := cfg.Node.KeyStoreConfig()
 
 	if err != nil {
 		utils.Fatalf("Failed to read configuration: %v", err)
@@ -307,7 +369,7 @@ func accountCreate(ctx *cli.Context) error {
 
 	password := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
 
-	address, err := keystore.StoreKey(keydir, password, scryptN, scryptP)
+	address, err := keystore.StoreKeyWithKDF(keydir, password, kdf)
 
 	if err != nil {
 		utils.Fatalf("Failed to create account: %v", err)
@@ -323,7 +385,7 @@ func accountUpdate(ctx *cli.Context) error {
 		utils.Fatalf("No accounts specified to update")
 	}
 	stack, _ := makeConfigNode(ctx)
-	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	ks := fetchKeystore(stack)
 
 	for _, addr := range ctx.Args() {
 		account, oldPassword := unlockAccount(ctx, ks, addr, 0, nil)
@@ -346,9 +408,9 @@ func importWallet(ctx *cli.Context) error {
 	}
 
 	stack, _ := makeConfigNode(ctx)
+	ks := fetchKeystore(stack)
 	passphrase := getPassPhrase("", false, 0, utils.MakePasswordList(ctx))
 
-	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
 	acct, err := ks.ImportPreSaleKey(keyJSON, passphrase)
 	if err != nil {
 		utils.Fatalf("%v", err)
@@ -367,9 +429,9 @@ func accountImport(ctx *cli.Context) error {
 		utils.Fatalf("Failed to load the private key: %v", err)
 	}
 	stack, _ := makeConfigNode(ctx)
+	ks := fetchKeystore(stack)
 	passphrase := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
 
-	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
 	acct, err := ks.ImportECDSA(key, passphrase)
 	if err != nil {
 		utils.Fatalf("Could not create the account: %v", err)
@@ -377,3 +439,52 @@ func accountImport(ctx *cli.Context) error {
 	fmt.Printf("Address: {%x}\n", acct.Address)
 	return nil
 }
+
+func accountImportMnemonic(ctx *cli.Context) error {
+	var (
+		mnemonic        string
+		bip39Passphrase string
+		err             error
+	)
+	if file := ctx.Args().First(); file != "" {
+		blob, err := ioutil.ReadFile(file)
+		if err != nil {
+			utils.Fatalf("Failed to read the mnemonic: %v", err)
+		}
+		mnemonic = string(blob)
+	} else {
+		if mnemonic, err = console.Stdin.PromptPassword("Mnemonic: "); err != nil {
+			utils.Fatalf("Failed to read the mnemonic: %v", err)
+		}
+	}
+	if file := ctx.String(bip39PassphraseFlag.Name); file != "" {
+		blob, err := ioutil.ReadFile(file)
+		if err != nil {
+			utils.Fatalf("Failed to read the BIP-39 passphrase: %v", err)
+		}
+		bip39Passphrase = strings.TrimRight(string(blob), "\r\n")
+	} else if ctx.NArg() == 0 {
+		if bip39Passphrase, err = console.Stdin.PromptPassword("BIP-39 passphrase (empty if none): "); err != nil {
+			utils.Fatalf("Failed to read the BIP-39 passphrase: %v", err)
+		}
+	}
+	stack, _ := makeConfigNode(ctx)
+	backends := stack.AccountManager().Backends(hdwallet.BackendType)
+	if len(backends) == 0 {
+		return errors.New("HD wallets are not available when signing with an external signer")
+	}
+	backend := backends[0].(*hdwallet.Backend)
+
+	passphrase := getPassPhrase("Your new wallet is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+	acct, err := backend.Import(mnemonic, bip39Passphrase, passphrase)
+	if err != nil {
+		utils.Fatalf("Could not create the wallet: %v", err)
+	}
+	for _, wallet := range backend.Wallets() {
+		if wallet.Contains(acct) {
+			fmt.Printf("Wallet: %s\n", wallet.URL())
+		}
+	}
+	fmt.Printf("Address: {%x}\n", acct.Address)
+	return nil
+}
@@ -131,6 +131,39 @@ Fatal: could not decrypt key with given passphrase
 `)
 }
 
+func TestAccountImportMnemonicExternalSigner(t *testing.T) {
+	datadir := tmpdir(t)
+	mnemonic := filepath.Join(datadir, "mnemonic.txt")
+	if err := ioutil.WriteFile(mnemonic, []byte("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	geth := runGeth(t, "--datadir", datadir, "--signer", "http://127.0.0.1:1", "account", "import-mnemonic", "--lightkdf", mnemonic)
+	geth.ExpectExit()
+
+	if want := "HD wallets are not available when signing with an external signer"; !strings.Contains(geth.StderrText(), want) {
+		t.Errorf("stderr text does not contain %q", want)
+	}
+}
+
+func TestAccountCommandsExternalSigner(t *testing.T) {
+	datadir := tmpdir(t)
+	keyfile := filepath.Join(datadir, "key.prv")
+	if err := ioutil.WriteFile(keyfile, []byte("289c2857d4598e37fb9647507e47a309d6133539bf21a8b9cb6df88fd5232032"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"account", "update", "f466859ead1932d743d622cb74fc058882e8648a"},
+		{"account", "import", keyfile},
+		{"wallet", "import", "testdata/guswallet.json"},
+	} {
+		geth := runGeth(t, append([]string{"--datadir", datadir, "--signer", "http://127.0.0.1:1"}, args...)...)
+		geth.Expect(`
+Fatal: Local accounts are not available when signing is delegated to an external signer
+`)
+		geth.ExpectExit()
+	}
+}
+
 func TestUnlockFlag(t *testing.T) {
 	datadir := tmpDatadirWithKeystore(t)
 	geth := runGeth(t,
@@ -61,6 +61,7 @@ var (
 		utils.DataDirFlag,
 		utils.KeyStoreDirFlag,
 		utils.NoUSBFlag,
+		utils.ExternalSignerFlag,
 		utils.DashboardEnabledFlag,
 		utils.DashboardAddrFlag,
 		utils.DashboardPortFlag,
@@ -84,9 +85,13 @@ var (
 		utils.TxPoolLifetimeFlag,
 		utils.SyncModeFlag,
 		utils.GCModeFlag,
+		utils.LogIndexFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
+		utils.ULCServersFlag,
+		utils.ULCFractionFlag,
 		utils.LightKDFFlag,
+		utils.KDFFlag,
 		utils.WhitelistFlag,
 		utils.CacheFlag,
 		utils.CacheDatabaseFlag,
@@ -114,6 +119,7 @@ var (
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
+		utils.DNSDiscoveryFlag,
 		utils.NetrestrictFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
@@ -280,13 +286,18 @@ func startNode(ctx *cli.Context, stack *node.Node) {
 	utils.StartNode(stack)
 
 	// Unlock any account specifically requested
-	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	if unlocks := strings.TrimSpace(ctx.GlobalString(utils.UnlockedAccountFlag.Name)); unlocks != "" {
+		keystores := stack.AccountManager().Backends(keystore.KeyStoreType)
+		if len(keystores) == 0 {
+			utils.Fatalf("Accounts cannot be unlocked when signing is delegated to an external signer")
+		}
+		ks := keystores[0].(*keystore.KeyStore)
 
-	passwords := utils.MakePasswordList(ctx)
-	unlocks := strings.Split(ctx.GlobalString(utils.UnlockedAccountFlag.Name), ",")
-	for i, account := range unlocks {
-		if trimmed := strings.TrimSpace(account); trimmed != "" {
-			unlockAccount(ctx, ks, trimmed, i, passwords)
+		passwords := utils.MakePasswordList(ctx)
+		for i, account := range strings.Split(unlocks, ",") {
+			if trimmed := strings.TrimSpace(account); trimmed != "" {
+				unlockAccount(ctx, ks, trimmed, i, passwords)
+			}
 		}
 	}
 	// Register wallet event handlers to open and auto-derive wallets
@@ -19,6 +19,7 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/big"
@@ -51,7 +52,6 @@ import (
 	"github.com/ethereum/go-ethereum/metrics/influxdb"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
-	"github.com/ethereum/go-ethereum/p2p/discv5"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/nat"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
@@ -127,6 +127,10 @@ var (
 		Name:  "nousb",
 		Usage: "Disables monitoring for and managing USB hardware wallets",
 	}
+	ExternalSignerFlag = cli.StringFlag{
+		Name:  "signer",
+		Usage: "External signer (url or path to ipc file) to delegate all signing to",
+	}
 	NetworkIdFlag = cli.Uint64Flag{
 		Name:  "networkid",
 		Usage: "Network identifier (integer, 1=Frontier, 2=Morden (disused), 3=Ropsten, 4=Rinkeby)",
@@ -172,6 +176,10 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	LogIndexFlag = cli.BoolFlag{
+		Name:  "logindex",
+		Usage: "Maintain an exact address and topic index of logs for fast historical log queries",
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -182,10 +190,24 @@ var (
 		Usage: "Maximum number of LES client peers",
 		Value: eth.DefaultConfig.LightPeers,
 	}
+	ULCServersFlag = cli.StringFlag{
+		Name:  "ulc.servers",
+		Usage: "Comma separated node URLs of trusted LES servers, enables ultra-light client mode (light sync only)",
+	}
+	ULCFractionFlag = cli.IntFlag{
+		Name:  "ulc.fraction",
+		Usage: "Minimum percentage of trusted LES servers that must announce a head in ultra-light client mode",
+		Value: eth.DefaultULCMinTrustedFraction,
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
 	}
+	KDFFlag = cli.StringFlag{
+		Name:  "kdf",
+		Usage: `Key-derivation function used to encrypt new keys ("scrypt" or "argon2id")`,
+		Value: keystore.KDFScrypt,
+	}
 	WhitelistFlag = cli.StringFlag{
 		Name:  "whitelist",
 		Usage: "Comma separated block number-to-hash mappings to enforce (<number>=<hash>)",
@@ -541,7 +563,11 @@ var (
 	}
 	DiscoveryV5Flag = cli.BoolFlag{
 		Name:  "v5disc",
-		Usage: "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
+		Usage: "Enables the RLPx V5 (Topic Discovery) mechanism alongside V4, on by default (--v5disc=false disables it)",
+	}
+	DNSDiscoveryFlag = cli.StringFlag{
+		Name:  "discovery.dns",
+		Usage: "Comma separated enrtree:// URLs of DNS node lists to query for peers",
 	}
 	NetrestrictFlag = cli.StringFlag{
 		Name:  "netrestrict",
@@ -732,9 +758,9 @@ func setBootstrapNodesV5(ctx *cli.Context, cfg *p2p.Config) {
 		return // already set, don't apply defaults.
 	}
 
-	cfg.BootstrapNodesV5 = make([]*discv5.Node, 0, len(urls))
+	cfg.BootstrapNodesV5 = make([]*enode.Node, 0, len(urls))
 	for _, url := range urls {
-		node, err := discv5.ParseNode(url)
+		node, err := enode.Parse(enode.ValidSchemes, url)
 		if err != nil {
 			log.Error("Bootstrap URL invalid", "enode", url, "err", err)
 			continue
@@ -843,7 +869,8 @@ func makeDatabaseHandles() int {
 }
 
 // MakeAddress converts an account specified directly as a hex encoded string or
-// a key index in the key store to an internal account representation.
+// a key index in the key store to an internal account representation. The key
+// store may be nil if the node delegates signing to an external signer.
 func MakeAddress(ks *keystore.KeyStore, account string) (accounts.Account, error) {
 	// If the specified account is a valid address, return it
 	if common.IsHexAddress(account) {
@@ -860,6 +887,9 @@ func MakeAddress(ks *keystore.KeyStore, account string) (accounts.Account, error
 	log.Warn("Please use explicit addresses! (can search via `geth account list`)")
 	log.Warn("-------------------------------------------------------------------")
 
+	if ks == nil {
+		return accounts.Account{}, errors.New("no keystore to look up account index in")
+	}
 	accs := ks.Accounts()
 	if len(accs) <= index {
 		return accounts.Account{}, fmt.Errorf("index %d higher than number of accounts %d", index, len(accs))
@@ -946,13 +976,12 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		cfg.NoDiscovery = true
 	}
 
-	// if we're running a light client or server, force enable the v5 peer discovery
-	// unless it is explicitly disabled with --nodiscover note that explicitly specifying
-	// --v5disc overrides --nodiscover, in which case the later only disables v4 discovery
-	forceV5Discovery := (lightClient || lightServer) && !ctx.GlobalBool(NoDiscoverFlag.Name)
+	// v5 peer discovery runs alongside v4 on the same UDP socket by default, unless
+	// disabled along with it by --nodiscover. Note that explicitly specifying --v5disc
+	// overrides --nodiscover, in which case the later only disables v4 discovery
 	if ctx.GlobalIsSet(DiscoveryV5Flag.Name) {
 		cfg.DiscoveryV5 = ctx.GlobalBool(DiscoveryV5Flag.Name)
-	} else if forceV5Discovery {
+	} else if !ctx.GlobalBool(NoDiscoverFlag.Name) {
 		cfg.DiscoveryV5 = true
 	}
 
@@ -989,9 +1018,15 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(LightKDFFlag.Name) {
 		cfg.UseLightweightKDF = ctx.GlobalBool(LightKDFFlag.Name)
 	}
+	if ctx.GlobalIsSet(KDFFlag.Name) {
+		cfg.KeyStoreKDF = ctx.GlobalString(KDFFlag.Name)
+	}
 	if ctx.GlobalIsSet(NoUSBFlag.Name) {
 		cfg.NoUSB = ctx.GlobalBool(NoUSBFlag.Name)
 	}
+	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
+		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
+	}
 }
 
 func setDataDir(ctx *cli.Context, cfg *node.Config) {
@@ -1157,18 +1192,53 @@ func SetShhConfig(ctx *cli.Context, stack *node.Node, cfg *whisper.Config) {
 	}
 }
 
+// setDNSDiscovery configures the DNS node lists to discover peers from.
+func setDNSDiscovery(ctx *cli.Context, cfg *eth.Config) {
+	if ctx.GlobalBool(NoDiscoverFlag.Name) {
+		cfg.DiscoveryURLs = nil // peer discovery disabled entirely
+		return
+	}
+	if ctx.GlobalIsSet(DNSDiscoveryFlag.Name) {
+		cfg.DiscoveryURLs = nil
+		for _, url := range strings.Split(ctx.GlobalString(DNSDiscoveryFlag.Name), ",") {
+			if url = strings.TrimSpace(url); url != "" {
+				cfg.DiscoveryURLs = append(cfg.DiscoveryURLs, url)
+			}
+		}
+	}
+}
+
+// setULC creates the ultra-light client configuration from the command line flags.
+func setULC(ctx *cli.Context, cfg *eth.Config) {
+	if !ctx.GlobalIsSet(ULCServersFlag.Name) {
+		return
+	}
+	if cfg.SyncMode != downloader.LightSync {
+		Fatalf("--%s requires --%s light", ULCServersFlag.Name, SyncModeFlag.Name)
+	}
+	cfg.ULC = &eth.ULCConfig{
+		TrustedServers:     splitAndTrim(ctx.GlobalString(ULCServersFlag.Name)),
+		MinTrustedFraction: ctx.GlobalInt(ULCFractionFlag.Name),
+	}
+}
+
 // SetEthConfig applies eth-related command line flags to the config.
 func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	// Avoid conflicting network flags
 	checkExclusive(ctx, DeveloperFlag, TestnetFlag, RinkebyFlag)
 	checkExclusive(ctx, LightServFlag, SyncModeFlag, "light")
 
-	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	// Nodes delegating signing to an external signer have no keystore
+	var ks *keystore.KeyStore
+	if keystores := stack.AccountManager().Backends(keystore.KeyStoreType); len(keystores) > 0 {
+		ks = keystores[0].(*keystore.KeyStore)
+	}
 	setEtherbase(ctx, ks, cfg)
 	setGPO(ctx, &cfg.GPO)
 	setTxPool(ctx, &cfg.TxPool)
 	setEthash(ctx, cfg)
 	setWhitelist(ctx, cfg)
+	setDNSDiscovery(ctx, cfg)
 
 	if ctx.GlobalIsSet(SyncModeFlag.Name) {
 		cfg.SyncMode = *GlobalTextMarshaler(ctx, SyncModeFlag.Name).(*downloader.SyncMode)
@@ -1179,6 +1249,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(LightPeersFlag.Name) {
 		cfg.LightPeers = ctx.GlobalInt(LightPeersFlag.Name)
 	}
+	setULC(ctx, cfg)
 	if ctx.GlobalIsSet(NetworkIdFlag.Name) {
 		cfg.NetworkId = ctx.GlobalUint64(NetworkIdFlag.Name)
 	}
@@ -1192,6 +1263,10 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
 
+	if ctx.GlobalIsSet(LogIndexFlag.Name) {
+		cfg.LogIndex = ctx.GlobalBool(LogIndexFlag.Name)
+	}
+
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
 	}
@@ -1261,6 +1336,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 			cfg.NetworkId = 1337
 		}
 		// Create new developer account or reuse existing one
+		if ks == nil {
+			Fatalf("Developer mode requires a local keystore, cannot use an external signer")
+		}
 		var (
 			developer accounts.Account
 			err       error
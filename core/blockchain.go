@@ -142,6 +142,14 @@ type BlockChain struct {
 // available in the database. It initialises the default Ethereum Validator and
 // Processor.
 func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config, shouldPreserve func(block *types.Block) bool) (*BlockChain, error) {
+	return NewBlockChainWithState(db, nil, cacheConfig, chainConfig, engine, vmConfig, shouldPreserve)
+}
+
+// NewBlockChainWithState is like NewBlockChain, but accesses all the state of the
+// chain through the given state database instead of a caching one opened on db.
+// This allows serving state from other sources, e.g. lazily from a remote node
+// in a forked simulation. The state database must keep its tries in db.
+func NewBlockChainWithState(db ethdb.Database, stateDb state.Database, cacheConfig *CacheConfig, chainConfig *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config, shouldPreserve func(block *types.Block) bool) (*BlockChain, error) {
 	if cacheConfig == nil {
 		cacheConfig = &CacheConfig{
 			TrieCleanLimit: 256,
@@ -156,12 +164,15 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	futureBlocks, _ := lru.New(maxFutureBlocks)
 	badBlocks, _ := lru.New(badBlockLimit)
 
+	if stateDb == nil {
+		stateDb = state.NewDatabaseWithCache(db, cacheConfig.TrieCleanLimit)
+	}
 	bc := &BlockChain{
 		chainConfig:    chainConfig,
 		cacheConfig:    cacheConfig,
 		db:             db,
 		triegc:         prque.New(nil),
-		stateCache:     state.NewDatabaseWithCache(db, cacheConfig.TrieCleanLimit),
+		stateCache:     stateDb,
 		quit:           make(chan struct{}),
 		shouldPreserve: shouldPreserve,
 		bodyCache:      bodyCache,
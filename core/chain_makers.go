@@ -170,6 +170,12 @@ func (b *BlockGen) OffsetTime(seconds int64) {
 // values. Inserting them into BlockChain requires use of FakePow or
 // a similar non-validating proof of work implementation.
 func GenerateChain(config *params.ChainConfig, parent *types.Block, engine consensus.Engine, db ethdb.Database, n int, gen func(int, *BlockGen)) ([]*types.Block, []types.Receipts) {
+	return GenerateChainWithState(config, parent, engine, state.NewDatabase(db), n, gen)
+}
+
+// GenerateChainWithState is like GenerateChain, but executes the blocks on top
+// of the given state database, which should contain the parent's state.
+func GenerateChainWithState(config *params.ChainConfig, parent *types.Block, engine consensus.Engine, stateDb state.Database, n int, gen func(int, *BlockGen)) ([]*types.Block, []types.Receipts) {
 	if config == nil {
 		config = params.TestChainConfig
 	}
@@ -212,7 +218,7 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 		return nil, nil
 	}
 	for i := 0; i < n; i++ {
-		statedb, err := state.New(parent.Root(), state.NewDatabase(db))
+		statedb, err := state.New(parent.Root(), stateDb)
 		if err != nil {
 			panic(err)
 		}
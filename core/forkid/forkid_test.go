@@ -0,0 +1,202 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package forkid
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// math32 is a far future fork block unknown to the local chains.
+const math32 = 0xffffffff
+
+// ethereumConfig is the fork schedule of the Ethereum main network up to
+// Constantinople, used to check against the EIP-2124 reference vectors.
+var (
+	ethereumConfig = &params.ChainConfig{
+		ChainID:             big.NewInt(1),
+		HomesteadBlock:      big.NewInt(1150000),
+		DAOForkBlock:        big.NewInt(1920000),
+		DAOForkSupport:      true,
+		EIP150Block:         big.NewInt(2463000),
+		EIP155Block:         big.NewInt(2675000),
+		EIP158Block:         big.NewInt(2675000),
+		ByzantiumBlock:      big.NewInt(4370000),
+		ConstantinopleBlock: big.NewInt(7280000),
+	}
+	ethereumGenesis = common.HexToHash("0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3")
+)
+
+// TestCreation tests that different genesis and fork rule combinations result in
+// the correct fork ID.
+func TestCreation(t *testing.T) {
+	tests := []struct {
+		head uint64
+		want ID
+	}{
+		{0, ID{Hash: checksumToBytes(0xfc64ec04), Next: 1150000}},       // Unsynced
+		{1149999, ID{Hash: checksumToBytes(0xfc64ec04), Next: 1150000}}, // Last Frontier block
+		{1150000, ID{Hash: checksumToBytes(0x97c2c34c), Next: 1920000}}, // First Homestead block
+		{1919999, ID{Hash: checksumToBytes(0x97c2c34c), Next: 1920000}}, // Last Homestead block
+		{1920000, ID{Hash: checksumToBytes(0x91d1f948), Next: 2463000}}, // First DAO block
+		{2462999, ID{Hash: checksumToBytes(0x91d1f948), Next: 2463000}}, // Last DAO block
+		{2463000, ID{Hash: checksumToBytes(0x7a64da13), Next: 2675000}}, // First Tangerine block
+		{2674999, ID{Hash: checksumToBytes(0x7a64da13), Next: 2675000}}, // Last Tangerine block
+		{2675000, ID{Hash: checksumToBytes(0x3edd5b10), Next: 4370000}}, // First Spurious block
+		{4369999, ID{Hash: checksumToBytes(0x3edd5b10), Next: 4370000}}, // Last Spurious block
+		{4370000, ID{Hash: checksumToBytes(0xa00bc324), Next: 7280000}}, // First Byzantium block
+		{7279999, ID{Hash: checksumToBytes(0xa00bc324), Next: 7280000}}, // Last Byzantium block
+		{7280000, ID{Hash: checksumToBytes(0x668db0af), Next: 0}},       // First Constantinople block
+		{7987396, ID{Hash: checksumToBytes(0x668db0af), Next: 0}},       // Future block
+	}
+	for i, tt := range tests {
+		if have := newID(ethereumConfig, ethereumGenesis, tt.head); have != tt.want {
+			t.Errorf("test %d: fork ID mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that the SGC premine emission blocks are part of the fork schedule, so
+// nodes disagreeing on the emission are told apart.
+func TestCreationPremine(t *testing.T) {
+	config := params.MainnetChainConfig
+	genesis := params.MainnetGenesisHash
+
+	// The first emission block is the first fork, everything else is at genesis
+	id := newID(config, genesis, 0)
+	if id.Next != config.Premine2019Start.Uint64() {
+		t.Fatalf("next fork mismatch: have %d, want %d", id.Next, config.Premine2019Start)
+	}
+	if passed := newID(config, genesis, id.Next); passed.Hash == id.Hash || passed.Next != config.Premine2020Start.Uint64() {
+		t.Fatalf("emission fork not applied: before %x, after %x", id, passed)
+	}
+	// A chain with a different emission schedule must diverge once it's passed
+	other := *config
+	other.Premine2020Start = new(big.Int).Add(config.Premine2020Start, big.NewInt(1))
+
+	head := config.Premine2021Start.Uint64()
+	if newID(&other, genesis, head) == newID(config, genesis, head) {
+		t.Fatalf("differing emission schedules produced the same fork ID")
+	}
+	if err := newFilter(config, genesis, func() uint64 { return head })(newID(&other, genesis, head)); err != ErrLocalIncompatibleOrStale {
+		t.Fatalf("differing emission schedule accepted: %v", err)
+	}
+	// Ethereum derived chains with a different genesis must be rejected too
+	if err := newFilter(config, genesis, func() uint64 { return head })(newID(ethereumConfig, ethereumGenesis, 0)); err != ErrLocalIncompatibleOrStale {
+		t.Fatalf("foreign chain accepted: %v", err)
+	}
+}
+
+// TestValidation tests that a local peer correctly validates and accepts a remote
+// fork ID.
+func TestValidation(t *testing.T) {
+	tests := []struct {
+		head uint64
+		id   ID
+		err  error
+	}{
+		// Local is mainnet Constantinople, remote announces the same. No future fork is announced.
+		{7987396, ID{Hash: checksumToBytes(0x668db0af), Next: 0}, nil},
+
+		// Local is mainnet Constantinople, remote announces the same. Remote also announces a next fork
+		// at block 0xffffffff, but that is uncertain.
+		{7987396, ID{Hash: checksumToBytes(0x668db0af), Next: math32}, nil},
+
+		// Local is mainnet currently in Byzantium only (so it's aware of Constantinople), remote announces
+		// also Byzantium, but it's not yet aware of Constantinople (e.g. non updated node before the fork).
+		// In this case we don't know if Constantinople passed yet or not.
+		{7279999, ID{Hash: checksumToBytes(0xa00bc324), Next: 0}, nil},
+
+		// Local is mainnet currently in Byzantium only (so it's aware of Constantinople), remote announces
+		// also Byzantium, and it's also aware of Constantinople (e.g. updated node before the fork). We
+		// don't know if Constantinople passed yet (will pass) or not.
+		{7279999, ID{Hash: checksumToBytes(0xa00bc324), Next: 7280000}, nil},
+
+		// Local is mainnet currently in Byzantium only (so it's aware of Constantinople), remote announces
+		// also Byzantium, and it's also aware of some random fork (e.g. misconfigured Constantinople). As
+		// neither forks passed at neither nodes, they may mismatch, but we still connect for now.
+		{7279999, ID{Hash: checksumToBytes(0xa00bc324), Next: math32}, nil},
+
+		// Local is mainnet Constantinople, remote announces Byzantium + knowledge about Constantinople. Remote
+		// is simply out of sync, accept.
+		{7987396, ID{Hash: checksumToBytes(0xa00bc324), Next: 7280000}, nil},
+
+		// Local is mainnet Constantinople, remote announces Spurious + knowledge about Byzantium. Remote
+		// is definitely out of sync. It may or may not need the Constantinople update, we don't know yet.
+		{7987396, ID{Hash: checksumToBytes(0x3edd5b10), Next: 4370000}, nil},
+
+		// Local is mainnet Byzantium, remote announces Constantinople. Local is out of sync, accept.
+		{7279999, ID{Hash: checksumToBytes(0x668db0af), Next: 0}, nil},
+
+		// Local is mainnet Spurious, remote announces Byzantium, but is not aware of Constantinople. Local
+		// out of sync. Local also knows about a future fork, but that is uncertain yet.
+		{4369999, ID{Hash: checksumToBytes(0xa00bc324), Next: 0}, nil},
+
+		// Local is mainnet Constantinople. remote announces Byzantium but is not aware of further forks.
+		// Remote needs software update.
+		{7987396, ID{Hash: checksumToBytes(0xa00bc324), Next: 0}, ErrRemoteStale},
+
+		// Local is mainnet Constantinople, and isn't aware of more forks. Remote announces Constantinople +
+		// 0xffffffff. Local needs software update, reject.
+		{7987396, ID{Hash: checksumToBytes(0x5cddc0e1), Next: 0}, ErrLocalIncompatibleOrStale},
+
+		// Local is mainnet Byzantium, and is aware of Constantinople. Remote announces Constantinople +
+		// 0xffffffff. Local needs software update, reject.
+		{7279999, ID{Hash: checksumToBytes(0x5cddc0e1), Next: 0}, ErrLocalIncompatibleOrStale},
+
+		// Local is mainnet Constantinople, remote is Rinkeby Constantinople.
+		{7987396, ID{Hash: checksumToBytes(0xafec6b27), Next: 0}, ErrLocalIncompatibleOrStale},
+
+		// Local is mainnet Constantinople, far in the future. Remote announces a fork at a block already
+		// passed locally, the chains are incompatible.
+		{88888888, ID{Hash: checksumToBytes(0x668db0af), Next: 88888888}, ErrLocalIncompatibleOrStale},
+	}
+	for i, tt := range tests {
+		filter := newFilter(ethereumConfig, ethereumGenesis, func() uint64 { return tt.head })
+		if err := filter(tt.id); err != tt.err {
+			t.Errorf("test %d: validation error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+// Tests that IDs are properly RLP encoded (specifically important because we
+// use uint32 to store the hash, but we need to encode it as [4]byte).
+func TestEncoding(t *testing.T) {
+	tests := []struct {
+		id   ID
+		want []byte
+	}{
+		{ID{Hash: checksumToBytes(0), Next: 0}, common.Hex2Bytes("c6840000000080")},
+		{ID{Hash: checksumToBytes(0xdeadbeef), Next: 0xBADDCAFE}, common.Hex2Bytes("ca84deadbeef84baddcafe")},
+		{ID{Hash: checksumToBytes(math32), Next: 1<<64 - 1}, common.Hex2Bytes("ce84ffffffff88ffffffffffffffff")},
+	}
+	for i, tt := range tests {
+		have, err := rlp.EncodeToBytes(tt.id)
+		if err != nil {
+			t.Errorf("test %d: failed to encode forkid: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(have, tt.want) {
+			t.Errorf("test %d: RLP mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+}
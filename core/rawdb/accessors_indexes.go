@@ -105,3 +105,21 @@ func WriteBloomBits(db DatabaseWriter, bit uint, section uint64, head common.Has
 		log.Crit("Failed to store bloom bits", "err", err)
 	}
 }
+
+// LogIndexAddressField is the log index field under which log addresses are
+// indexed. Topics are indexed under their position within the topic list.
+const LogIndexAddressField = 0xff
+
+// ReadLogIndex retrieves the compressed bit vector of the given section marking
+// the blocks which contain a log with the given value in the given field.
+func ReadLogIndex(db DatabaseReader, field byte, value []byte, section uint64, head common.Hash) ([]byte, error) {
+	return db.Get(logIndexKey(field, value, section, head))
+}
+
+// WriteLogIndex stores the compressed bit vector of the given section marking
+// the blocks which contain a log with the given value in the given field.
+func WriteLogIndex(db DatabaseWriter, field byte, value []byte, section uint64, head common.Hash, bits []byte) {
+	if err := db.Put(logIndexKey(field, value, section, head), bits); err != nil {
+		log.Crit("Failed to store log index", "err", err)
+	}
+}
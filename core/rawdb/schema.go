@@ -52,12 +52,14 @@ var (
 
 	txLookupPrefix  = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
+	logIndexPrefix  = []byte("L") // logIndexPrefix + field (1 byte) + value + section (uint64 big endian) + hash -> block bits
 
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	LogIndexIndexPrefix  = []byte("iL") // LogIndexIndexPrefix is the data table of the log index chain indexer to track its progress
 
 	preimageCounter    = metrics.NewRegisteredCounter("db/preimage/total", nil)
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
@@ -123,6 +125,13 @@ func bloomBitsKey(bit uint, section uint64, hash common.Hash) []byte {
 	return key
 }
 
+// logIndexKey = logIndexPrefix + field (1 byte) + value + section (uint64 big endian) + hash
+func logIndexKey(field byte, value []byte, section uint64, hash common.Hash) []byte {
+	key := append(append(logIndexPrefix, field), value...)
+	key = append(key, encodeBlockNumber(section)...)
+	return append(key, hash.Bytes()...)
+}
+
 // preimageKey = preimagePrefix + hash
 func preimageKey(hash common.Hash) []byte {
 	return append(preimagePrefix, hash.Bytes()...)
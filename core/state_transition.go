@@ -58,6 +58,7 @@ type StateTransition struct {
 	data       []byte
 	state      vm.StateDB
 	evm        *vm.EVM
+	vmerr      error
 }
 
 // Message represents a message sent to a contract.
@@ -132,6 +133,13 @@ func ApplyMessage(evm *vm.EVM, msg Message, gp *GasPool) ([]byte, uint64, bool,
 	return NewStateTransition(evm, msg, gp).TransitionDb()
 }
 
+// VMError returns the error raised by the EVM while executing the message, if
+// any. These errors (e.g. vm.ErrExecutionReverted) do not affect consensus and
+// are therefore only reported through the failed flag of TransitionDb.
+func (st *StateTransition) VMError() error {
+	return st.vmerr
+}
+
 // to returns the recipient of the message.
 func (st *StateTransition) to() common.Address {
 	if st.msg == nil || st.msg.To() == nil /* contract creation */ {
@@ -212,6 +220,7 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 		st.state.SetNonce(msg.From(), st.state.GetNonce(sender.Address())+1)
 		ret, st.gas, vmerr = evm.Call(sender, st.to(), st.data, st.gas, st.value)
 	}
+	st.vmerr = vmerr
 	if vmerr != nil {
 		log.Debug("VM returned with error", "err", vmerr)
 		// The only possible consensus-error would be if there wasn't
@@ -35,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -336,6 +337,102 @@ func (api *PrivateDebugAPI) GetBadBlocks(ctx context.Context) ([]*BadBlockArgs,
 	return results, nil
 }
 
+// maxExportBlockRange is the maximum number of blocks a single block range
+// export may stream. Longer ranges need to be exported in multiple requests.
+const maxExportBlockRange = 10000
+
+// ExportedBlock is a block along with the receipts and logs of its transactions,
+// as streamed by debug_exportBlockRange. The final notification of an export
+// carries no block but has Done set, along with the Error that ended the export
+// early, if any.
+type ExportedBlock struct {
+	Block    map[string]interface{}   `json:"block,omitempty"`
+	Receipts []map[string]interface{} `json:"receipts"`
+	Done     bool                     `json:"done,omitempty"`
+	Error    string                   `json:"error,omitempty"`
+}
+
+// ExportBlockRange streams the canonical blocks in the given range, including
+// full transactions and all receipts and logs, one notification per block,
+// followed by a final notification marking the end of the export. It is meant
+// for indexers which would otherwise need a request per transaction.
+func (api *PrivateDebugAPI) ExportBlockRange(ctx context.Context, start, end rpc.BlockNumber) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	// Resolve the block tags and sanity check the range
+	head := api.eth.blockchain.CurrentBlock().NumberU64()
+
+	first, last := uint64(start.Int64()), uint64(end.Int64())
+	if start == rpc.LatestBlockNumber || start == rpc.PendingBlockNumber {
+		first = head
+	}
+	if end == rpc.LatestBlockNumber || end == rpc.PendingBlockNumber {
+		last = head
+	}
+	if first > last {
+		return &rpc.Subscription{}, fmt.Errorf("start block (#%d) must be less than or equal to end block (#%d)", first, last)
+	}
+	if last-first >= maxExportBlockRange {
+		return &rpc.Subscription{}, fmt.Errorf("block range #%d-#%d exceeds the limit of %d blocks", first, last, maxExportBlockRange)
+	}
+	if last > head {
+		return &rpc.Subscription{}, fmt.Errorf("end block #%d not yet available, head is #%d", last, head)
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		for number := first; number <= last; number++ {
+			select {
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			default:
+			}
+			exported, err := api.exportBlock(number)
+			if err != nil {
+				log.Warn("Failed to export block", "number", number, "err", err)
+				notifier.Notify(rpcSub.ID, &ExportedBlock{Done: true, Error: err.Error()})
+				return
+			}
+			notifier.Notify(rpcSub.ID, exported)
+		}
+		notifier.Notify(rpcSub.ID, &ExportedBlock{Done: true})
+	}()
+	return rpcSub, nil
+}
+
+// exportBlock assembles the RPC representation of the canonical block with the
+// given number along with its receipts.
+func (api *PrivateDebugAPI) exportBlock(number uint64) (*ExportedBlock, error) {
+	block := api.eth.blockchain.GetBlockByNumber(number)
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	fields, err := ethapi.RPCMarshalBlock(block, true, true)
+	if err != nil {
+		return nil, err
+	}
+	receipts := rawdb.ReadReceipts(api.eth.ChainDb(), block.Hash(), number)
+
+	txs := block.Transactions()
+	if len(txs) != len(receipts) {
+		return nil, fmt.Errorf("receipts length mismatch: %d vs %d", len(txs), len(receipts))
+	}
+	signer := types.MakeSigner(api.config, block.Number())
+
+	exported := &ExportedBlock{
+		Block:    fields,
+		Receipts: make([]map[string]interface{}, len(receipts)),
+	}
+	for i, receipt := range receipts {
+		exported.Receipts[i] = ethapi.RPCMarshalReceipt(receipt, block.Hash(), number, signer, txs[i], uint64(i))
+	}
+	return exported, nil
+}
+
 // StorageRangeResult is the result of a debug_storageRangeAt API call.
 type StorageRangeResult struct {
 	Storage storageMap   `json:"storage"`
@@ -86,14 +86,22 @@ type Config struct {
 	NetworkId uint64 // Network ID to use for selecting peers to connect to
 	SyncMode  downloader.SyncMode
 	NoPruning bool
+	LogIndex  bool // Whether to maintain an exact address and topic index of logs
 
 	// Whitelist of required block number -> hash values to accept
 	Whitelist map[uint64]common.Hash `toml:"-"`
 
+	// DiscoveryURLs are the enrtree:// URLs of DNS node lists used as an
+	// additional source of eth peers.
+	DiscoveryURLs []string `toml:",omitempty"`
+
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
 
+	// Ultra light client options
+	ULC *ULCConfig `toml:",omitempty"`
+
 	// Database options
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
@@ -140,3 +148,15 @@ type Config struct {
 type configMarshaling struct {
 	MinerExtraData hexutil.Bytes
 }
+
+// DefaultULCMinTrustedFraction is the default minimum percentage of trusted
+// servers that must announce a head before an ultra-light client accepts it.
+const DefaultULCMinTrustedFraction = 75
+
+// ULCConfig is the configuration of an ultra-light client, which follows the
+// heads announced by a set of trusted LES servers instead of downloading and
+// verifying the header chain.
+type ULCConfig struct {
+	TrustedServers     []string `toml:",omitempty"` // Node URLs of the trusted LES servers
+	MinTrustedFraction int      `toml:",omitempty"` // Minimum percentage of trusted servers that must announce a head
+}
@@ -334,8 +334,10 @@ func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode
 			// The dropPeer method is nil when `--copydb` is used for a local copy.
 			// Timeouts can occur if e.g. compaction hits at the wrong time, and can be ignored
 			log.Warn("Downloader wants to drop peer, but peerdrop-function is not set", "peer", id)
+		} else if err == errTimeout || err == errStallingPeer {
+			d.dropPeer(id, DropStalling)
 		} else {
-			d.dropPeer(id)
+			d.dropPeer(id, DropInvalidData)
 		}
 	default:
 		log.Warn("Synchronisation failed, retrying", "err", err)
@@ -995,7 +997,7 @@ func (d *Downloader) fetchHeaders(p *peerConnection, from uint64, pivot uint64)
 			// Header retrieval timed out, consider the peer bad and drop
 			p.log.Debug("Header request timed out", "elapsed", ttl)
 			headerTimeoutMeter.Mark(1)
-			d.dropPeer(p.id)
+			d.dropPeer(p.id, DropStalling)
 
 			// Finish the sync gracefully instead of dumping the gathered data though
 			for _, ch := range []chan bool{d.bodyWakeCh, d.receiptWakeCh} {
@@ -1215,7 +1217,7 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 							// Timeouts can occur if e.g. compaction hits at the wrong time, and can be ignored
 							peer.log.Warn("Downloader wants to drop peer, but peerdrop-function is not set", "peer", pid)
 						} else {
-							d.dropPeer(pid)
+							d.dropPeer(pid, DropStalling)
 						}
 					}
 				}
@@ -49,6 +49,7 @@ type downloadTester struct {
 	stateDb ethdb.Database // Database used by the tester for syncing from peers
 	peerDb  ethdb.Database // Database of the peers containing all data
 	peers   map[string]*downloadTesterPeer
+	drops   map[string]DropReason // Reasons the peers were dropped for
 
 	ownHashes   []common.Hash                  // Hash chain belonging to the tester
 	ownHeaders  map[common.Hash]*types.Header  // Headers belonging to the tester
@@ -65,6 +66,7 @@ func newTester() *downloadTester {
 		genesis:     testGenesis,
 		peerDb:      testDB,
 		peers:       make(map[string]*downloadTesterPeer),
+		drops:       make(map[string]DropReason),
 		ownHashes:   []common.Hash{testGenesis.Hash()},
 		ownHeaders:  map[common.Hash]*types.Header{testGenesis.Hash(): testGenesis.Header()},
 		ownBlocks:   map[common.Hash]*types.Block{testGenesis.Hash(): testGenesis},
@@ -296,11 +298,12 @@ func (dl *downloadTester) newPeer(id string, version int, chain *testChain) erro
 }
 
 // dropPeer simulates a hard peer removal from the connection pool.
-func (dl *downloadTester) dropPeer(id string) {
+func (dl *downloadTester) dropPeer(id string, reason DropReason) {
 	dl.lock.Lock()
 	defer dl.lock.Unlock()
 
 	delete(dl.peers, id)
+	dl.drops[id] = reason
 	dl.downloader.UnregisterPeer(id)
 }
 
@@ -1043,27 +1046,28 @@ func testBlockHeaderAttackerDropping(t *testing.T, protocol int) {
 	tests := []struct {
 		result error
 		drop   bool
+		reason DropReason
 	}{
-		{nil, false},                        // Sync succeeded, all is well
-		{errBusy, false},                    // Sync is already in progress, no problem
-		{errUnknownPeer, false},             // Peer is unknown, was already dropped, don't double drop
-		{errBadPeer, true},                  // Peer was deemed bad for some reason, drop it
-		{errStallingPeer, true},             // Peer was detected to be stalling, drop it
-		{errNoPeers, false},                 // No peers to download from, soft race, no issue
-		{errTimeout, true},                  // No hashes received in due time, drop the peer
-		{errEmptyHeaderSet, true},           // No headers were returned as a response, drop as it's a dead end
-		{errPeersUnavailable, true},         // Nobody had the advertised blocks, drop the advertiser
-		{errInvalidAncestor, true},          // Agreed upon ancestor is not acceptable, drop the chain rewriter
-		{errInvalidChain, true},             // Hash chain was detected as invalid, definitely drop
-		{errInvalidBlock, false},            // A bad peer was detected, but not the sync origin
-		{errInvalidBody, false},             // A bad peer was detected, but not the sync origin
-		{errInvalidReceipt, false},          // A bad peer was detected, but not the sync origin
-		{errCancelBlockFetch, false},        // Synchronisation was canceled, origin may be innocent, don't drop
-		{errCancelHeaderFetch, false},       // Synchronisation was canceled, origin may be innocent, don't drop
-		{errCancelBodyFetch, false},         // Synchronisation was canceled, origin may be innocent, don't drop
-		{errCancelReceiptFetch, false},      // Synchronisation was canceled, origin may be innocent, don't drop
-		{errCancelHeaderProcessing, false},  // Synchronisation was canceled, origin may be innocent, don't drop
-		{errCancelContentProcessing, false}, // Synchronisation was canceled, origin may be innocent, don't drop
+		{nil, false, 0},                              // Sync succeeded, all is well
+		{errBusy, false, 0},                          // Sync is already in progress, no problem
+		{errUnknownPeer, false, 0},                   // Peer is unknown, was already dropped, don't double drop
+		{errBadPeer, true, DropInvalidData},          // Peer was deemed bad for some reason, drop it
+		{errStallingPeer, true, DropStalling},        // Peer was detected to be stalling, drop it
+		{errNoPeers, false, 0},                       // No peers to download from, soft race, no issue
+		{errTimeout, true, DropStalling},             // No hashes received in due time, drop the peer
+		{errEmptyHeaderSet, true, DropInvalidData},   // No headers were returned as a response, drop as it's a dead end
+		{errPeersUnavailable, true, DropInvalidData}, // Nobody had the advertised blocks, drop the advertiser
+		{errInvalidAncestor, true, DropInvalidData},  // Agreed upon ancestor is not acceptable, drop the chain rewriter
+		{errInvalidChain, true, DropInvalidData},     // Hash chain was detected as invalid, definitely drop
+		{errInvalidBlock, false, 0},                  // A bad peer was detected, but not the sync origin
+		{errInvalidBody, false, 0},                   // A bad peer was detected, but not the sync origin
+		{errInvalidReceipt, false, 0},                // A bad peer was detected, but not the sync origin
+		{errCancelBlockFetch, false, 0},              // Synchronisation was canceled, origin may be innocent, don't drop
+		{errCancelHeaderFetch, false, 0},             // Synchronisation was canceled, origin may be innocent, don't drop
+		{errCancelBodyFetch, false, 0},               // Synchronisation was canceled, origin may be innocent, don't drop
+		{errCancelReceiptFetch, false, 0},            // Synchronisation was canceled, origin may be innocent, don't drop
+		{errCancelHeaderProcessing, false, 0},        // Synchronisation was canceled, origin may be innocent, don't drop
+		{errCancelContentProcessing, false, 0},       // Synchronisation was canceled, origin may be innocent, don't drop
 	}
 	// Run the tests and check disconnection status
 	tester := newTester()
@@ -1086,6 +1090,9 @@ func testBlockHeaderAttackerDropping(t *testing.T, protocol int) {
 		if _, ok := tester.peers[id]; !ok != tt.drop {
 			t.Errorf("test %d: peer drop mismatch for %v: have %v, want %v", i, tt.result, !ok, tt.drop)
 		}
+		if reason, ok := tester.drops[id]; ok && reason != tt.reason {
+			t.Errorf("test %d: drop reason mismatch for %v: have %v, want %v", i, tt.result, reason, tt.reason)
+		}
 	}
 }
 
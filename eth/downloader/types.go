@@ -22,8 +22,16 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
+// DropReason is the cause for which the downloader drops a peer.
+type DropReason int
+
+const (
+	DropInvalidData DropReason = iota // Peer delivered malformed, inconsistent or useless data
+	DropStalling                      // Peer failed to deliver the requested data in time
+)
+
 // peerDropFn is a callback type for dropping a peer detected as malicious.
-type peerDropFn func(id string)
+type peerDropFn func(id string, reason DropReason)
 
 // dataPack is a data message returned by a peer for some query.
 type dataPack interface {
@@ -0,0 +1,91 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ethEntry is the "eth" ENR entry which advertises eth protocol
+// on the discovery network.
+type ethEntry struct {
+	ForkID forkid.ID // Fork identifier per EIP-2124
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e ethEntry) ENRKey() string {
+	return "eth"
+}
+
+// startEthEntryUpdate keeps the "eth" entry of the local node record in sync
+// with the fork ID of the current chain head.
+func (s *Ethereum) startEthEntryUpdate(ln *enode.LocalNode) {
+	var (
+		newHead = make(chan core.ChainHeadEvent, 10)
+		sub     = s.blockchain.SubscribeChainHeadEvent(newHead)
+	)
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case <-newHead:
+				ln.Set(s.currentEthEntry())
+			case <-sub.Err():
+				// Would be nice to sync with s.Stop, but there is no
+				// good way to do that.
+				return
+			}
+		}
+	}()
+}
+
+// currentEthEntry returns the "eth" entry matching the current chain head.
+func (s *Ethereum) currentEthEntry() *ethEntry {
+	return &ethEntry{ForkID: forkid.NewID(s.blockchain)}
+}
+
+// setupDiscovery creates the DNS discovery source of eth nodes if any node
+// lists are configured, and feeds it into the dialer of the eth protocol.
+// Only nodes advertising a compatible fork ID are dialed.
+func (s *Ethereum) setupDiscovery() error {
+	if len(s.config.DiscoveryURLs) == 0 {
+		return nil
+	}
+	client := dnsdisc.NewClient(dnsdisc.Config{})
+	it, err := client.NewIterator(s.config.DiscoveryURLs...)
+	if err != nil {
+		return err
+	}
+	filter := s.protocolManager.forkFilter
+	it = enode.Filter(it, func(n *enode.Node) bool {
+		var entry ethEntry
+		if err := n.Load(&entry); err != nil {
+			return false
+		}
+		return filter(entry.ForkID) == nil
+	})
+	// All protocol versions share the dialer, attach the source only once.
+	s.protocolManager.SubProtocols[0].DialCandidates = it
+	return nil
+}
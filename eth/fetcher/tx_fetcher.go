@@ -0,0 +1,422 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	txArriveTimeout = 500 * time.Millisecond // Time allowance before an announced transaction is explicitly requested
+	txFetchTimeout  = 5 * time.Second        // Maximum allotted time to return an explicitly requested transaction
+	maxTxRetrievals = 256                    // Maximum number of transactions to request from a peer in one go
+	maxTxAnnounces  = 4096                   // Maximum number of unique transactions a peer may have pending
+)
+
+// txHasFn is a callback type for checking whether a transaction is already
+// known to the local pool.
+type txHasFn func(common.Hash) bool
+
+// txAddFn is a callback type for inserting a batch of transactions into the
+// local pool.
+type txAddFn func([]*types.Transaction) []error
+
+// txRequestFn is a callback type for sending a transaction retrieval request.
+type txRequestFn func(string, []common.Hash) error
+
+// txAnnounce is the notification of the availability of a batch of new
+// transactions in the network.
+type txAnnounce struct {
+	origin string        // Identifier of the peer originating the notification
+	hashes []common.Hash // Batch of transaction hashes being announced
+}
+
+// txDelivery is the notification that a batch of transactions have been added
+// to the pool and should be untracked.
+type txDelivery struct {
+	origin string        // Identifier of the peer originating the notification
+	hashes []common.Hash // Batch of transaction hashes having been delivered
+	direct bool          // Whether this is a direct reply or a broadcast
+}
+
+// txRequest represents an in-flight transaction retrieval request destined to
+// a specific peer.
+type txRequest struct {
+	hashes []common.Hash  // Transactions having been requested
+	time   mclock.AbsTime // Timestamp of the request
+}
+
+// TxFetcher is responsible for retrieving new transactions based on hash
+// announcements. Announced transactions are given a short grace period to
+// arrive via direct broadcast, after which they are explicitly requested from
+// one of the announcing peers. If the request times out or the peer doesn't
+// deliver, the retrieval is rescheduled from an alternate announcer.
+type TxFetcher struct {
+	// Various event channels
+	notify  chan *txAnnounce
+	cleanup chan *txDelivery
+	drop    chan string
+	quit    chan struct{}
+
+	// Announce states
+	waittime  map[common.Hash]mclock.AbsTime      // Timestamps of the first announcement of not yet fetched transactions
+	announces map[string]map[common.Hash]struct{} // Transactions announced by a peer and not yet requested from it
+	announced map[common.Hash]map[string]struct{} // Peers having announced a transaction (inverse of announces)
+	fetching  map[common.Hash]string              // Transactions currently being fetched, and the peer they are fetched from
+	requests  map[string]*txRequest               // In-flight transaction retrievals, keyed by peer
+
+	// Callbacks
+	hasTx    txHasFn     // Checks if a transaction is already in the local pool
+	addTxs   txAddFn     // Inserts a batch of transactions into the local pool
+	fetchTxs txRequestFn // Retrieves a batch of transactions from a remote peer
+
+	// Testing hooks
+	step  chan struct{} // Notification channel when the fetcher loop iterates
+	clock mclock.Clock  // Time wrapper to simulate in tests
+}
+
+// NewTxFetcher creates a transaction fetcher to retrieve transactions based on
+// hash announcements.
+func NewTxFetcher(hasTx txHasFn, addTxs txAddFn, fetchTxs txRequestFn) *TxFetcher {
+	return newTxFetcher(hasTx, addTxs, fetchTxs, mclock.System{})
+}
+
+// newTxFetcher creates a transaction fetcher running on a custom clock.
+func newTxFetcher(hasTx txHasFn, addTxs txAddFn, fetchTxs txRequestFn, clock mclock.Clock) *TxFetcher {
+	return &TxFetcher{
+		notify:    make(chan *txAnnounce),
+		cleanup:   make(chan *txDelivery),
+		drop:      make(chan string),
+		quit:      make(chan struct{}),
+		waittime:  make(map[common.Hash]mclock.AbsTime),
+		announces: make(map[string]map[common.Hash]struct{}),
+		announced: make(map[common.Hash]map[string]struct{}),
+		fetching:  make(map[common.Hash]string),
+		requests:  make(map[string]*txRequest),
+		hasTx:     hasTx,
+		addTxs:    addTxs,
+		fetchTxs:  fetchTxs,
+		clock:     clock,
+	}
+}
+
+// Start boots up the announcement based transaction retriever, accepting and
+// processing hash notifications and deliveries until termination requested.
+func (f *TxFetcher) Start() {
+	go f.loop()
+}
+
+// Stop terminates the announcement based transaction retriever, canceling all
+// pending operations.
+func (f *TxFetcher) Stop() {
+	close(f.quit)
+}
+
+// Notify announces the fetcher of the potential availability of a batch of new
+// transactions in the network.
+func (f *TxFetcher) Notify(peer string, hashes []common.Hash) error {
+	txAnnounceInMeter.Mark(int64(len(hashes)))
+
+	// Skip any transaction announcements that we already know of
+	unknown := make([]common.Hash, 0, len(hashes))
+	for _, hash := range hashes {
+		if !f.hasTx(hash) {
+			unknown = append(unknown, hash)
+		}
+	}
+	txAnnounceKnownMeter.Mark(int64(len(hashes) - len(unknown)))
+
+	if len(unknown) == 0 {
+		return nil
+	}
+	select {
+	case f.notify <- &txAnnounce{origin: peer, hashes: unknown}:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// Enqueue imports a batch of received transactions into the transaction pool
+// and the fetcher. The direct flag marks replies to explicit requests, in which
+// case any transaction requested but not delivered is considered unavailable
+// at the origin peer.
+func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool) error {
+	if direct {
+		txReplyInMeter.Mark(int64(len(txs)))
+	} else {
+		txBroadcastInMeter.Mark(int64(len(txs)))
+	}
+	// Push all the transactions into the pool. Any error is irrelevant for the
+	// fetcher: a rejected transaction shouldn't be retrieved again either.
+	f.addTxs(txs)
+
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	select {
+	case f.cleanup <- &txDelivery{origin: peer, hashes: hashes, direct: direct}:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// Drop should be called when a peer disconnects. It cleans up all the internal
+// data structures of the given peer.
+func (f *TxFetcher) Drop(peer string) error {
+	select {
+	case f.drop <- peer:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// loop is the main fetcher loop, tracking announcements, scheduling retrievals
+// and expiring stale requests.
+func (f *TxFetcher) loop() {
+	var timeout <-chan time.Time
+
+	for {
+		select {
+		case ann := <-f.notify:
+			f.announce(ann.origin, ann.hashes)
+
+		case delivery := <-f.cleanup:
+			f.deliver(delivery.origin, delivery.hashes, delivery.direct)
+
+		case peer := <-f.drop:
+			f.dropPeer(peer)
+
+		case <-timeout:
+			f.expire()
+
+		case <-f.quit:
+			return
+		}
+		// Issue any retrievals that became possible and rearm the timer. This
+		// needs to happen before notifying tests to avoid racing the clock.
+		f.schedule()
+		timeout = f.nextTimeout()
+
+		if f.step != nil {
+			f.step <- struct{}{}
+		}
+	}
+}
+
+// announce tracks a batch of transaction hashes announced by a peer.
+func (f *TxFetcher) announce(peer string, hashes []common.Hash) {
+	now := f.clock.Now()
+
+	pending := f.announces[peer]
+	if pending == nil {
+		pending = make(map[common.Hash]struct{})
+		f.announces[peer] = pending
+	}
+	for i, hash := range hashes {
+		if len(pending) >= maxTxAnnounces {
+			log.Debug("Peer exceeded outstanding transaction announces", "peer", peer, "limit", maxTxAnnounces)
+			txAnnounceDOSMeter.Mark(int64(len(hashes) - i))
+			break
+		}
+		if _, ok := f.waittime[hash]; !ok {
+			f.waittime[hash] = now
+		}
+		if f.announced[hash] == nil {
+			f.announced[hash] = make(map[string]struct{})
+		}
+		f.announced[hash][peer] = struct{}{}
+
+		// If we're already fetching from this very peer, don't reschedule
+		if f.fetching[hash] != peer {
+			pending[hash] = struct{}{}
+		}
+	}
+	if len(pending) == 0 {
+		delete(f.announces, peer)
+	}
+}
+
+// deliver untracks a batch of transactions that arrived from a peer. If it was
+// the reply to a request, any missing transactions are rescheduled from other
+// announcers.
+func (f *TxFetcher) deliver(peer string, hashes []common.Hash, direct bool) {
+	for _, hash := range hashes {
+		f.forget(hash)
+	}
+	if !direct {
+		return
+	}
+	req := f.requests[peer]
+	if req == nil {
+		return // timed out already or unsolicited
+	}
+	delete(f.requests, peer)
+	for _, hash := range req.hashes {
+		if f.fetching[hash] == peer {
+			f.unavailable(hash, peer)
+		}
+	}
+}
+
+// dropPeer removes all traces of a disconnected peer, rescheduling any of its
+// in-flight retrievals.
+func (f *TxFetcher) dropPeer(peer string) {
+	if req := f.requests[peer]; req != nil {
+		for _, hash := range req.hashes {
+			if f.fetching[hash] == peer {
+				f.unavailable(hash, peer)
+			}
+		}
+		delete(f.requests, peer)
+	}
+	for hash := range f.announces[peer] {
+		f.unavailable(hash, peer)
+	}
+	delete(f.announces, peer)
+}
+
+// expire reschedules all the retrievals that have not been answered in time.
+func (f *TxFetcher) expire() {
+	now := f.clock.Now()
+
+	for peer, req := range f.requests {
+		if req.time.Add(txFetchTimeout) > now {
+			continue
+		}
+		log.Debug("Transaction retrieval timed out", "peer", peer, "count", len(req.hashes))
+		txRequestTimeoutMeter.Mark(int64(len(req.hashes)))
+
+		for _, hash := range req.hashes {
+			if f.fetching[hash] == peer {
+				f.unavailable(hash, peer)
+			}
+		}
+		delete(f.requests, peer)
+	}
+}
+
+// schedule assigns retrieval requests to all idle peers having announced
+// transactions whose arrival grace period has expired.
+func (f *TxFetcher) schedule() {
+	now := f.clock.Now()
+
+	for peer, pending := range f.announces {
+		if f.requests[peer] != nil {
+			continue // peer busy, wait for its request to finish
+		}
+		var hashes []common.Hash
+		for hash := range pending {
+			if _, ok := f.fetching[hash]; ok {
+				continue // retrieval in progress from an alternate peer
+			}
+			if f.waittime[hash].Add(txArriveTimeout) > now {
+				continue // give broadcasts a chance to deliver
+			}
+			hashes = append(hashes, hash)
+			if len(hashes) >= maxTxRetrievals {
+				break
+			}
+		}
+		if len(hashes) == 0 {
+			continue
+		}
+		for _, hash := range hashes {
+			f.fetching[hash] = peer
+			delete(pending, hash)
+		}
+		if len(pending) == 0 {
+			delete(f.announces, peer)
+		}
+		f.requests[peer] = &txRequest{hashes: hashes, time: now}
+		txRequestOutMeter.Mark(int64(len(hashes)))
+
+		go func(peer string, hashes []common.Hash) {
+			if err := f.fetchTxs(peer, hashes); err != nil {
+				log.Debug("Failed to request transactions", "peer", peer, "err", err)
+			}
+		}(peer, hashes)
+	}
+}
+
+// nextTimeout creates a timer firing when the earliest pending announcement
+// becomes retrievable or the oldest request expires. Nil is returned if there
+// is nothing to wait for.
+func (f *TxFetcher) nextTimeout() <-chan time.Time {
+	var (
+		now      = f.clock.Now()
+		earliest mclock.AbsTime
+		found    bool
+	)
+	for hash, wait := range f.waittime {
+		if _, ok := f.fetching[hash]; ok {
+			continue
+		}
+		if deadline := wait.Add(txArriveTimeout); deadline > now && (!found || deadline < earliest) {
+			earliest, found = deadline, true
+		}
+	}
+	for _, req := range f.requests {
+		if deadline := req.time.Add(txFetchTimeout); !found || deadline < earliest {
+			earliest, found = deadline, true
+		}
+	}
+	if !found {
+		return nil
+	}
+	delay := time.Duration(earliest - now)
+	if delay < 0 {
+		delay = 0
+	}
+	return f.clock.After(delay)
+}
+
+// forget removes all traces of a transaction, after it was delivered.
+func (f *TxFetcher) forget(hash common.Hash) {
+	for peer := range f.announced[hash] {
+		if pending := f.announces[peer]; pending != nil {
+			delete(pending, hash)
+			if len(pending) == 0 {
+				delete(f.announces, peer)
+			}
+		}
+	}
+	delete(f.announced, hash)
+	delete(f.waittime, hash)
+	delete(f.fetching, hash)
+}
+
+// unavailable marks a transaction as not retrievable from the given peer. If
+// no other peer announced it, the transaction is forgotten altogether.
+func (f *TxFetcher) unavailable(hash common.Hash, peer string) {
+	if f.fetching[hash] == peer {
+		delete(f.fetching, hash)
+	}
+	delete(f.announced[hash], peer)
+	if len(f.announced[hash]) == 0 {
+		delete(f.announced, hash)
+		delete(f.waittime, hash)
+	}
+}
@@ -0,0 +1,318 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// txFetchRequest is a retrieval request issued by the fetcher under test.
+type txFetchRequest struct {
+	peer   string
+	hashes []common.Hash
+}
+
+// txFetcherTester is a test simulator for mocking out the transaction pool
+// and the remote peers.
+type txFetcherTester struct {
+	fetcher  *TxFetcher
+	clock    *mclock.Simulated
+	requests chan txFetchRequest
+
+	lock sync.RWMutex
+	pool map[common.Hash]*types.Transaction
+}
+
+// newTxFetcherTester creates a new transaction fetcher test mocker.
+func newTxFetcherTester() *txFetcherTester {
+	tester := &txFetcherTester{
+		clock:    new(mclock.Simulated),
+		requests: make(chan txFetchRequest, 16),
+		pool:     make(map[common.Hash]*types.Transaction),
+	}
+	tester.fetcher = newTxFetcher(tester.hasTx, tester.addTxs, tester.fetchTxs, tester.clock)
+	tester.fetcher.step = make(chan struct{})
+	tester.fetcher.Start()
+	return tester
+}
+
+// hasTx checks whether the mock pool contains a transaction.
+func (f *txFetcherTester) hasTx(hash common.Hash) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.pool[hash] != nil
+}
+
+// addTxs inserts a batch of transactions into the mock pool.
+func (f *txFetcherTester) addTxs(txs []*types.Transaction) []error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, tx := range txs {
+		f.pool[tx.Hash()] = tx
+	}
+	return make([]error, len(txs))
+}
+
+// fetchTxs records a retrieval request issued by the fetcher.
+func (f *txFetcherTester) fetchTxs(peer string, hashes []common.Hash) error {
+	f.requests <- txFetchRequest{peer, hashes}
+	return nil
+}
+
+// notify announces a batch of transactions and waits for the fetcher to process it.
+func (f *txFetcherTester) notify(t *testing.T, peer string, hashes []common.Hash) {
+	if err := f.fetcher.Notify(peer, hashes); err != nil {
+		t.Fatalf("failed to notify fetcher: %v", err)
+	}
+	<-f.fetcher.step
+}
+
+// enqueue delivers a batch of transactions and waits for the fetcher to process it.
+func (f *txFetcherTester) enqueue(t *testing.T, peer string, txs []*types.Transaction, direct bool) {
+	if err := f.fetcher.Enqueue(peer, txs, direct); err != nil {
+		t.Fatalf("failed to enqueue transactions: %v", err)
+	}
+	<-f.fetcher.step
+}
+
+// advance moves the simulated clock forward and waits for the fetcher to
+// react to the expiring timer.
+func (f *txFetcherTester) advance(t *testing.T, d time.Duration) {
+	f.clock.Run(d)
+	select {
+	case <-f.fetcher.step:
+	case <-time.After(time.Second):
+		t.Fatalf("fetcher timer didn't fire after %v", d)
+	}
+}
+
+// verifyRequest checks that the fetcher issued a retrieval for exactly the
+// given transactions to the given peer.
+func (f *txFetcherTester) verifyRequest(t *testing.T, peer string, hashes []common.Hash) {
+	select {
+	case req := <-f.requests:
+		if req.peer != peer {
+			t.Fatalf("request peer mismatch: have %s, want %s", req.peer, peer)
+		}
+		want := make(map[common.Hash]bool)
+		for _, hash := range hashes {
+			want[hash] = true
+		}
+		if len(req.hashes) != len(want) {
+			t.Fatalf("request size mismatch: have %d, want %d", len(req.hashes), len(want))
+		}
+		for _, hash := range req.hashes {
+			if !want[hash] {
+				t.Fatalf("unexpected transaction requested: %x", hash)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no retrieval request to %s", peer)
+	}
+}
+
+// verifyIdle checks that the fetcher has no retrievals in flight.
+func (f *txFetcherTester) verifyIdle(t *testing.T) {
+	if len(f.fetcher.requests) != 0 || len(f.fetcher.fetching) != 0 {
+		t.Fatalf("fetcher not idle: requests %d, fetching %d", len(f.fetcher.requests), len(f.fetcher.fetching))
+	}
+	select {
+	case req := <-f.requests:
+		t.Fatalf("unexpected retrieval request to %s", req.peer)
+	default:
+	}
+}
+
+// makeTxs creates a batch of distinct dummy transactions.
+func makeTxs(n int) ([]*types.Transaction, []common.Hash) {
+	txs := make([]*types.Transaction, n)
+	hashes := make([]common.Hash, n)
+	for i := 0; i < n; i++ {
+		txs[i] = types.NewTransaction(uint64(i), common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+		hashes[i] = txs[i].Hash()
+	}
+	return txs, hashes
+}
+
+// Tests that announced transactions are only requested after the arrival
+// grace period, and that delivering them cleans up the fetcher.
+func TestTxFetcherRetrieval(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs, hashes := makeTxs(4)
+	tester.notify(t, "A", hashes)
+	tester.verifyIdle(t)
+
+	tester.advance(t, txArriveTimeout)
+	tester.verifyRequest(t, "A", hashes)
+
+	tester.enqueue(t, "A", txs, true)
+	tester.verifyIdle(t)
+	if len(tester.fetcher.waittime) != 0 || len(tester.fetcher.announced) != 0 || len(tester.fetcher.announces) != 0 {
+		t.Fatalf("fetcher state not cleaned up")
+	}
+	for _, hash := range hashes {
+		if !tester.hasTx(hash) {
+			t.Fatalf("transaction %x not imported", hash)
+		}
+	}
+}
+
+// Tests that transactions already in the pool are not tracked at all.
+func TestTxFetcherKnownFiltering(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs, hashes := makeTxs(2)
+	tester.addTxs(txs[:1])
+
+	tester.notify(t, "A", hashes)
+	if _, ok := tester.fetcher.waittime[hashes[0]]; ok {
+		t.Fatalf("known transaction tracked")
+	}
+	if _, ok := tester.fetcher.waittime[hashes[1]]; !ok {
+		t.Fatalf("unknown transaction not tracked")
+	}
+	tester.advance(t, txArriveTimeout)
+	tester.verifyRequest(t, "A", hashes[1:])
+}
+
+// Tests that transactions arriving via broadcast during the grace period are
+// not explicitly requested.
+func TestTxFetcherBroadcastCancelsRetrieval(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs, hashes := makeTxs(2)
+	tester.notify(t, "A", hashes)
+	tester.enqueue(t, "B", txs[:1], false)
+
+	tester.advance(t, txArriveTimeout)
+	tester.verifyRequest(t, "A", hashes[1:])
+}
+
+// Tests that timed out retrievals are rescheduled from alternate announcers.
+func TestTxFetcherTimeoutReschedule(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs, hashes := makeTxs(1)
+	tester.notify(t, "A", hashes)
+	tester.advance(t, txArriveTimeout)
+	tester.verifyRequest(t, "A", hashes)
+
+	// Announce from a second peer while the first one is fetching
+	tester.notify(t, "B", hashes)
+	if peer := tester.fetcher.fetching[hashes[0]]; peer != "A" {
+		t.Fatalf("transaction fetched from wrong peer: have %s, want A", peer)
+	}
+	select {
+	case req := <-tester.requests:
+		t.Fatalf("unexpected retrieval request to %s", req.peer)
+	default:
+	}
+
+	tester.advance(t, txFetchTimeout)
+	tester.verifyRequest(t, "B", hashes)
+
+	// A late reply from the timed out peer should still be accepted
+	tester.enqueue(t, "A", txs, true)
+	if len(tester.fetcher.waittime) != 0 || len(tester.fetcher.fetching) != 0 {
+		t.Fatalf("delivered transaction still tracked")
+	}
+}
+
+// Tests that transactions missing from a direct reply are rescheduled from
+// alternate announcers, or forgotten if there are none.
+func TestTxFetcherPartialReply(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs, hashes := makeTxs(3)
+	tester.notify(t, "A", hashes)
+	tester.advance(t, txArriveTimeout)
+	tester.verifyRequest(t, "A", hashes)
+
+	tester.notify(t, "B", hashes[1:2])
+	tester.enqueue(t, "A", txs[:1], true)
+	tester.verifyRequest(t, "B", hashes[1:2])
+
+	if _, ok := tester.fetcher.waittime[hashes[2]]; ok {
+		t.Fatalf("unavailable transaction still tracked")
+	}
+}
+
+// Tests that dropping a peer reschedules its in-flight retrievals.
+func TestTxFetcherDropReschedule(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	_, hashes := makeTxs(2)
+	tester.notify(t, "A", hashes)
+	tester.advance(t, txArriveTimeout)
+	tester.verifyRequest(t, "A", hashes)
+
+	tester.notify(t, "B", hashes[:1])
+	if err := tester.fetcher.Drop("A"); err != nil {
+		t.Fatalf("failed to drop peer: %v", err)
+	}
+	<-tester.fetcher.step
+	tester.verifyRequest(t, "B", hashes[:1])
+
+	if _, ok := tester.fetcher.requests["A"]; ok {
+		t.Fatalf("dropped peer still has a request")
+	}
+	if _, ok := tester.fetcher.waittime[hashes[1]]; ok {
+		t.Fatalf("transaction announced only by dropped peer still tracked")
+	}
+}
+
+// Tests that a peer cannot make the fetcher track an unbounded number of
+// transactions.
+func TestTxFetcherAnnounceLimit(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	_, hashes := makeTxs(maxTxAnnounces + 10)
+	tester.notify(t, "A", hashes)
+
+	if n := len(tester.fetcher.announces["A"]); n != maxTxAnnounces {
+		t.Fatalf("announce count mismatch: have %d, want %d", n, maxTxAnnounces)
+	}
+	if n := len(tester.fetcher.waittime); n != maxTxAnnounces {
+		t.Fatalf("tracked transaction count mismatch: have %d, want %d", n, maxTxAnnounces)
+	}
+	tester.advance(t, txArriveTimeout)
+	select {
+	case req := <-tester.requests:
+		if len(req.hashes) != maxTxRetrievals {
+			t.Fatalf("request size mismatch: have %d, want %d", len(req.hashes), maxTxRetrievals)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no retrieval request")
+	}
+}
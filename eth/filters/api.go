@@ -31,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
@@ -133,9 +134,34 @@ func (api *PublicFilterAPI) NewPendingTransactionFilter() rpc.ID {
 	return pendingTxSub.ID
 }
 
+// PendingTransactionsCriteria restricts a pending transaction subscription to
+// the transactions sent from or to one of the given addresses.
+type PendingTransactionsCriteria struct {
+	Addresses []common.Address `json:"addresses"`
+}
+
+// matches reports whether the transaction was sent from or to one of the
+// addresses of the criteria. An empty address list matches everything.
+func (crit *PendingTransactionsCriteria) matches(tx *types.Transaction) bool {
+	if crit == nil || len(crit.Addresses) == 0 {
+		return true
+	}
+	if to := tx.To(); to != nil && includes(crit.Addresses, *to) {
+		return true
+	}
+	var signer types.Signer = types.HomesteadSigner{}
+	if tx.Protected() {
+		signer = types.NewEIP155Signer(tx.ChainId())
+	}
+	from, err := types.Sender(signer, tx)
+	return err == nil && includes(crit.Addresses, from)
+}
+
 // NewPendingTransactions creates a subscription that is triggered each time a transaction
 // enters the transaction pool and was signed from one of the transactions this nodes manages.
-func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+// If fullTx is set, the full transactions are sent instead of only their hashes. The optional
+// criteria restrict the notifications to transactions sent from or to the given addresses.
+func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context, fullTx *bool, crit *PendingTransactionsCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
@@ -144,16 +170,22 @@ func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Su
 	rpcSub := notifier.CreateSubscription()
 
 	go func() {
-		txHashes := make(chan []common.Hash, 128)
-		pendingTxSub := api.events.SubscribePendingTxs(txHashes)
+		txs := make(chan []*types.Transaction, 128)
+		pendingTxSub := api.events.SubscribeFullPendingTxs(txs)
 
 		for {
 			select {
-			case hashes := <-txHashes:
-				// To keep the original behaviour, send a single tx hash in one notification.
-				// TODO(rjl493456442) Send a batch of tx hashes in one notification
-				for _, h := range hashes {
-					notifier.Notify(rpcSub.ID, h)
+			case batch := <-txs:
+				// To keep the original behaviour, send a single tx in one notification.
+				for _, tx := range batch {
+					if !crit.matches(tx) {
+						continue
+					}
+					if fullTx != nil && *fullTx {
+						notifier.Notify(rpcSub.ID, ethapi.NewRPCPendingTransaction(tx))
+					} else {
+						notifier.Notify(rpcSub.ID, tx.Hash())
+					}
 				}
 			case <-rpcSub.Err():
 				pendingTxSub.Unsubscribe()
@@ -271,6 +303,41 @@ func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc
 	return rpcSub, nil
 }
 
+// PendingLogs creates a subscription that fires for the logs matching the given
+// filter criteria which are produced by the transactions of the miner's pending
+// block. The from and to block criteria are ignored.
+func (api *PublicFilterAPI) PendingLogs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	var (
+		rpcSub      = notifier.CreateSubscription()
+		matchedLogs = make(chan []*types.Log)
+		logsSub     = api.events.SubscribePendingLogs(ethereum.FilterQuery(crit), matchedLogs)
+	)
+
+	go func() {
+		for {
+			select {
+			case logs := <-matchedLogs:
+				for _, log := range logs {
+					notifier.Notify(rpcSub.ID, log)
+				}
+			case <-rpcSub.Err(): // client send an unsubscribe request
+				logsSub.Unsubscribe()
+				return
+			case <-notifier.Closed(): // connection dropped
+				logsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // FilterCriteria represents a request to create a new filter.
 // Same as ethereum.FilterQuery but with UnmarshalJSON() method.
 type FilterCriteria ethereum.FilterQuery
@@ -324,6 +391,38 @@ func (api *PublicFilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
 //
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getlogs
 func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
+	// Run the filter and return all the logs
+	logs, err := api.criteriaFilter(crit).Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return returnLogs(logs), err
+}
+
+// LogsPage is a page of logs returned by eth_getLogsPage, along with the cursor
+// to continue the query from. The cursor is nil if no further logs match.
+type LogsPage struct {
+	Logs   []*types.Log `json:"logs"`
+	Cursor *logCursor   `json:"cursor"`
+}
+
+// GetLogsPage returns at most limit logs matching the given argument, starting
+// after the cursor if one is given. Contrary to eth_getLogs, the result tells
+// whether the query is complete or how to continue it.
+func (api *PublicFilterAPI) GetLogsPage(ctx context.Context, crit FilterCriteria) (*LogsPage, error) {
+	logs, cursor, err := api.criteriaFilter(crit).Page(ctx)
+	if err != nil {
+		return nil, err
+	}
+	page := &LogsPage{Logs: returnLogs(logs)}
+	if cursor != nil {
+		page.Cursor = &logCursor{BlockNumber: hexutil.Uint64(cursor.BlockNumber), Index: hexutil.Uint(cursor.Index)}
+	}
+	return page, nil
+}
+
+// criteriaFilter constructs the one-off filter for the given criteria.
+func (api *PublicFilterAPI) criteriaFilter(crit FilterCriteria) *Filter {
 	var filter *Filter
 	if crit.BlockHash != nil {
 		// Block filter requested, construct a single-shot filter
@@ -341,12 +440,8 @@ func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([
 		// Construct the range filter
 		filter = NewRangeFilter(api.backend, begin, end, crit.Addresses, crit.Topics)
 	}
-	// Run the filter and return all the logs
-	logs, err := filter.Logs(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return returnLogs(logs), err
+	filter.Paginate(crit.Limit, crit.Cursor)
+	return filter
 }
 
 // UninstallFilter removes the filter with the given filter id.
@@ -456,6 +551,12 @@ func returnLogs(logs []*types.Log) []*types.Log {
 	return logs
 }
 
+// logCursor is the JSON representation of an ethereum.LogCursor.
+type logCursor struct {
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	Index       hexutil.Uint   `json:"logIndex"`
+}
+
 // UnmarshalJSON sets *args fields with given data.
 func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 	type input struct {
@@ -464,6 +565,8 @@ func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 		ToBlock   *rpc.BlockNumber `json:"toBlock"`
 		Addresses interface{}      `json:"address"`
 		Topics    []interface{}    `json:"topics"`
+		Limit     *hexutil.Uint64  `json:"limit"`
+		Cursor    *logCursor       `json:"cursor"`
 	}
 
 	var raw input
@@ -487,6 +590,16 @@ func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 		}
 	}
 
+	if raw.Limit != nil {
+		args.Limit = uint64(*raw.Limit)
+	}
+	if raw.Cursor != nil {
+		args.Cursor = &ethereum.LogCursor{
+			BlockNumber: uint64(raw.Cursor.BlockNumber),
+			Index:       uint(raw.Cursor.Index),
+		}
+	}
+
 	args.Addresses = []common.Address{}
 
 	if raw.Addresses != nil {
@@ -19,9 +19,12 @@ package filters
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
@@ -183,3 +186,33 @@ func TestUnmarshalJSONNewFilterArgs(t *testing.T) {
 		t.Fatalf("expected 0 topics, got %d topics", len(test7.Topics[2]))
 	}
 }
+
+func TestPendingTransactionsCriteria(t *testing.T) {
+	var (
+		key, _    = crypto.GenerateKey()
+		sender    = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268")
+		other     = common.HexToAddress("0x70c87d191324e6712a591f304b4eedef6ad9bb9d")
+		signer    = types.NewEIP155Signer(big.NewInt(1))
+	)
+	tx, err := types.SignTx(types.NewTransaction(0, recipient, new(big.Int), 21000, new(big.Int), nil), signer, key)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	tests := []struct {
+		crit  *PendingTransactionsCriteria
+		match bool
+	}{
+		{nil, true},
+		{&PendingTransactionsCriteria{}, true},
+		{&PendingTransactionsCriteria{Addresses: []common.Address{sender}}, true},
+		{&PendingTransactionsCriteria{Addresses: []common.Address{recipient}}, true},
+		{&PendingTransactionsCriteria{Addresses: []common.Address{other, sender}}, true},
+		{&PendingTransactionsCriteria{Addresses: []common.Address{other}}, false},
+	}
+	for i, test := range tests {
+		if match := test.crit.matches(tx); match != test.match {
+			t.Errorf("test %d: match mismatch: have %v, want %v", i, match, test.match)
+		}
+	}
+}
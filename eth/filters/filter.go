@@ -21,9 +21,12 @@ import (
 	"errors"
 	"math/big"
 
+	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/bitutil"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
@@ -44,6 +47,7 @@ type Backend interface {
 	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
 
 	BloomStatus() (uint64, uint64)
+	LogIndexStatus() (uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
 }
 
@@ -58,6 +62,9 @@ type Filter struct {
 	block      common.Hash // Block hash if filtering a single block
 	begin, end int64       // Range interval if filtering multiple blocks
 
+	limit  uint64              // Maximum number of logs to return (0 = unlimited)
+	cursor *ethereum.LogCursor // Position of the last log already delivered, if any
+
 	matcher *bloombits.Matcher
 }
 
@@ -114,6 +121,33 @@ func newFilter(backend Backend, addresses []common.Address, topics [][]common.Ha
 	}
 }
 
+// Paginate limits the number of logs returned by the filter, zero meaning no
+// limit. If a cursor is given, only the logs after its position are returned.
+func (f *Filter) Paginate(limit uint64, cursor *ethereum.LogCursor) {
+	f.limit, f.cursor = limit, cursor
+}
+
+// Page retrieves the next page of at most limit matching logs like Logs does,
+// along with the cursor to continue the query from. The cursor is nil if no
+// further logs match the filter.
+func (f *Filter) Page(ctx context.Context) ([]*types.Log, *ethereum.LogCursor, error) {
+	if f.limit == 0 {
+		logs, err := f.Logs(ctx)
+		return logs, nil, err
+	}
+	// Look for one more log than requested to know whether more exist
+	f.limit++
+	logs, err := f.Logs(ctx)
+	f.limit--
+
+	if err != nil || uint64(len(logs)) <= f.limit {
+		return logs, nil, err
+	}
+	logs = logs[:f.limit]
+	last := logs[len(logs)-1]
+	return logs, &ethereum.LogCursor{BlockNumber: last.BlockNumber, Index: last.Index}, nil
+}
+
 // Logs searches the blockchain for matching log entries, returning all from the
 // first block that contains matches, updating the start of the filter accordingly.
 func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
@@ -126,7 +160,8 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		if header == nil {
 			return nil, errors.New("unknown block")
 		}
-		return f.blockLogs(ctx, header)
+		found, err := f.blockLogs(ctx, header)
+		return f.appendLogs(nil, found), err
 	}
 	// Figure out the limits of the filter range
 	header, _ := f.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
@@ -142,30 +177,175 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	if f.end == -1 {
 		end = head
 	}
-	// Gather all indexed logs, and finish with non indexed ones
+	// Skip the blocks already delivered before the pagination cursor
+	if f.cursor != nil && f.cursor.BlockNumber > uint64(f.begin) {
+		f.begin = int64(f.cursor.BlockNumber)
+	}
+	// Gather all exactly indexed logs, continue with bloom indexed ones and
+	// finish with non indexed ones
 	var (
 		logs []*types.Log
 		err  error
 	)
+	if f.exactIndexable() {
+		size, sections := f.backend.LogIndexStatus()
+		if indexed := sections * size; indexed > uint64(f.begin) {
+			if indexed > end {
+				logs, err = f.exactLogs(ctx, end, logs)
+			} else {
+				logs, err = f.exactLogs(ctx, indexed-1, logs)
+			}
+			if err != nil || f.full(logs) {
+				return logs, err
+			}
+		}
+	}
 	size, sections := f.backend.BloomStatus()
 	if indexed := sections * size; indexed > uint64(f.begin) {
 		if indexed > end {
-			logs, err = f.indexedLogs(ctx, end)
+			logs, err = f.indexedLogs(ctx, end, logs)
 		} else {
-			logs, err = f.indexedLogs(ctx, indexed-1)
+			logs, err = f.indexedLogs(ctx, indexed-1, logs)
 		}
+		if err != nil || f.full(logs) {
+			return logs, err
+		}
+	}
+	return f.unindexedLogs(ctx, end, logs)
+}
+
+// exactIndexable returns whether the filter has any criteria that can be looked
+// up in the exact log index. Filters matching every log can't use the index.
+func (f *Filter) exactIndexable() bool {
+	if len(f.addresses) > 0 {
+		return true
+	}
+	for _, topicList := range f.topics {
+		if len(topicList) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// exactLogs appends the logs matching the filter criteria based on the exact
+// log index available locally.
+func (f *Filter) exactLogs(ctx context.Context, end uint64, logs []*types.Log) ([]*types.Log, error) {
+	size, _ := f.backend.LogIndexStatus()
+
+	for f.begin <= int64(end) {
+		if err := ctx.Err(); err != nil {
+			return logs, err
+		}
+		section := uint64(f.begin) / size
+		bits, err := f.exactMatches(section, size)
 		if err != nil {
 			return logs, err
 		}
+		last := (section+1)*size - 1
+		if last > end {
+			last = end
+		}
+		for number := uint64(f.begin); number <= last; number++ {
+			if index := number - section*size; bits[index/8]&(1<<byte(7-index%8)) == 0 {
+				continue
+			}
+			// Retrieve the matching block and pull the logs
+			header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+			if header == nil || err != nil {
+				return logs, err
+			}
+			found, err := f.checkMatches(ctx, header)
+			if err != nil {
+				return logs, err
+			}
+			f.begin = int64(number) + 1
+
+			if logs = f.appendLogs(logs, found); f.full(logs) {
+				return logs, nil
+			}
+		}
+		f.begin = int64(last) + 1
+	}
+	return logs, nil
+}
+
+// exactMatches returns the bit vector of the blocks in the given section that
+// contain logs satisfying every filter clause according to the exact log index.
+func (f *Filter) exactMatches(section, size uint64) ([]byte, error) {
+	head := rawdb.ReadCanonicalHash(f.db, (section+1)*size-1)
+
+	var matches []byte
+	clause := func(field byte, values [][]byte) error {
+		bits := make([]byte, size/8)
+		for _, value := range values {
+			// Values missing from the index don't occur within the section
+			comp, err := rawdb.ReadLogIndex(f.db, field, value, section, head)
+			if err != nil {
+				continue
+			}
+			blob, err := bitutil.DecompressBytes(comp, int(size/8))
+			if err != nil {
+				return err
+			}
+			bitutil.ORBytes(bits, bits, blob)
+		}
+		if matches == nil {
+			matches = bits
+		} else {
+			bitutil.ANDBytes(matches, matches, bits)
+		}
+		return nil
+	}
+	if len(f.addresses) > 0 {
+		values := make([][]byte, len(f.addresses))
+		for i, address := range f.addresses {
+			values[i] = address.Bytes()
+		}
+		if err := clause(rawdb.LogIndexAddressField, values); err != nil {
+			return nil, err
+		}
+	}
+	for i, topicList := range f.topics {
+		if len(topicList) == 0 {
+			continue // wildcard
+		}
+		values := make([][]byte, len(topicList))
+		for j, topic := range topicList {
+			values[j] = topic.Bytes()
+		}
+		if err := clause(byte(i), values); err != nil {
+			return nil, err
+		}
 	}
-	rest, err := f.unindexedLogs(ctx, end)
-	logs = append(logs, rest...)
-	return logs, err
+	return matches, nil
 }
 
-// indexedLogs returns the logs matching the filter criteria based on the bloom
+// appendLogs appends the found logs to the result set, skipping any log that
+// isn't past the pagination cursor and stopping once the limit is reached.
+func (f *Filter) appendLogs(logs []*types.Log, found []*types.Log) []*types.Log {
+	for _, log := range found {
+		if f.full(logs) {
+			break
+		}
+		if f.cursor != nil {
+			if log.BlockNumber < f.cursor.BlockNumber || (log.BlockNumber == f.cursor.BlockNumber && log.Index <= f.cursor.Index) {
+				continue
+			}
+		}
+		logs = append(logs, log)
+	}
+	return logs
+}
+
+// full returns whether the result set reached the limit of the filter.
+func (f *Filter) full(logs []*types.Log) bool {
+	return f.limit > 0 && uint64(len(logs)) >= f.limit
+}
+
+// indexedLogs appends the logs matching the filter criteria based on the bloom
 // bits indexed available locally or via the network.
-func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
+func (f *Filter) indexedLogs(ctx context.Context, end uint64, logs []*types.Log) ([]*types.Log, error) {
 	// Create a matcher session and request servicing from the backend
 	matches := make(chan uint64, 64)
 
@@ -178,8 +358,6 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 	f.backend.ServiceFilter(ctx, session)
 
 	// Iterate over the matches until exhausted or context closed
-	var logs []*types.Log
-
 	for {
 		select {
 		case number, ok := <-matches:
@@ -202,7 +380,9 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 			if err != nil {
 				return logs, err
 			}
-			logs = append(logs, found...)
+			if logs = f.appendLogs(logs, found); f.full(logs) {
+				return logs, nil
+			}
 
 		case <-ctx.Done():
 			return logs, ctx.Err()
@@ -210,11 +390,9 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 	}
 }
 
-// indexedLogs returns the logs matching the filter criteria based on raw block
+// unindexedLogs appends the logs matching the filter criteria based on raw block
 // iteration and bloom matching.
-func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
-	var logs []*types.Log
-
+func (f *Filter) unindexedLogs(ctx context.Context, end uint64, logs []*types.Log) ([]*types.Log, error) {
 	for ; f.begin <= int64(end); f.begin++ {
 		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(f.begin))
 		if header == nil || err != nil {
@@ -224,7 +402,10 @@ func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, e
 		if err != nil {
 			return logs, err
 		}
-		logs = append(logs, found...)
+		if logs = f.appendLogs(logs, found); f.full(logs) {
+			f.begin++
+			return logs, nil
+		}
 	}
 	return logs, nil
 }
@@ -22,6 +22,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/big"
 	"sync"
 	"time"
 
@@ -48,8 +49,8 @@ const (
 	PendingLogsSubscription
 	// MinedAndPendingLogsSubscription queries for logs in mined and pending blocks.
 	MinedAndPendingLogsSubscription
-	// PendingTransactionsSubscription queries tx hashes for pending
-	// transactions entering the pending state
+	// PendingTransactionsSubscription queries tx hashes (or full transactions)
+	// for pending transactions entering the pending state
 	PendingTransactionsSubscription
 	// BlocksSubscription queries hashes for blocks that are imported
 	BlocksSubscription
@@ -81,6 +82,7 @@ type subscription struct {
 	logsCrit  ethereum.FilterQuery
 	logs      chan []*types.Log
 	hashes    chan []common.Hash
+	txs       chan []*types.Transaction // full pending transactions, nil if only hashes are wanted
 	headers   chan *types.Header
 	installed chan struct{} // closed when the filter is installed
 	err       chan error    // closed when the filter is uninstalled
@@ -265,8 +267,17 @@ func (es *EventSystem) subscribeLogs(crit ethereum.FilterQuery, logs chan []*typ
 	return es.subscribe(sub)
 }
 
-// subscribePendingLogs creates a subscription that writes transaction hashes for
-// transactions that enter the transaction pool.
+// SubscribePendingLogs creates a subscription that writes the logs matching the
+// given criteria produced by the transactions of the miner's pending block. The
+// from and to block fields of the criteria are ignored.
+func (es *EventSystem) SubscribePendingLogs(crit ethereum.FilterQuery, logs chan []*types.Log) *Subscription {
+	crit.FromBlock = big.NewInt(rpc.PendingBlockNumber.Int64())
+	crit.ToBlock = big.NewInt(rpc.PendingBlockNumber.Int64())
+	return es.subscribePendingLogs(crit, logs)
+}
+
+// subscribePendingLogs creates a subscription that writes logs of the pending
+// block matching the given criteria to the given logs channel.
 func (es *EventSystem) subscribePendingLogs(crit ethereum.FilterQuery, logs chan []*types.Log) *Subscription {
 	sub := &subscription{
 		id:        rpc.NewID(),
@@ -314,6 +325,23 @@ func (es *EventSystem) SubscribePendingTxs(hashes chan []common.Hash) *Subscript
 	return es.subscribe(sub)
 }
 
+// SubscribeFullPendingTxs creates a subscription that writes the transactions
+// that enter the transaction pool.
+func (es *EventSystem) SubscribeFullPendingTxs(txs chan []*types.Transaction) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       PendingTransactionsSubscription,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		hashes:    make(chan []common.Hash),
+		txs:       txs,
+		headers:   make(chan *types.Header),
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
 type filterIndex map[Type]map[rpc.ID]*subscription
 
 // broadcast event to filters that match criteria.
@@ -353,7 +381,11 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 			hashes = append(hashes, tx.Hash())
 		}
 		for _, f := range filters[PendingTransactionsSubscription] {
-			f.hashes <- hashes
+			if f.txs != nil {
+				f.txs <- e.Txs
+			} else {
+				f.hashes <- hashes
+			}
 		}
 	case core.ChainEvent:
 		for _, f := range filters[BlocksSubscription] {
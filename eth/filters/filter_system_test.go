@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/bloombits"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/params"
@@ -124,6 +125,10 @@ func (b *testBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, b.sections
 }
 
+func (b *testBackend) LogIndexStatus() (uint64, uint64) {
+	return params.BloomBitsBlocks, b.sections
+}
+
 func (b *testBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
 	requests := make(chan chan *bloombits.Retrieval)
 
@@ -614,3 +619,116 @@ func TestPendingLogsSubscription(t *testing.T) {
 		}
 	}
 }
+
+// TestPendingSubscriptionsRPC tests that the pendingLogs and the full pending
+// transaction subscriptions deliver every matching event exactly once.
+func TestPendingSubscriptionsRPC(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mux        = new(event.TypeMux)
+		db         = ethdb.NewMemDatabase()
+		txFeed     = new(event.Feed)
+		rmLogsFeed = new(event.Feed)
+		logsFeed   = new(event.Feed)
+		chainFeed  = new(event.Feed)
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		server     = rpc.NewServer()
+
+		key, _    = crypto.GenerateKey()
+		sender    = crypto.PubkeyToAddress(key.PublicKey)
+		other, _  = crypto.GenerateKey()
+		signer    = types.HomesteadSigner{}
+		recipient = common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268")
+		firstAddr = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		otherAddr = common.HexToAddress("0x2222222222222222222222222222222222222222")
+	)
+	if err := server.RegisterName("eth", NewPublicFilterAPI(backend, false)); err != nil {
+		t.Fatalf("failed to register filter API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	// Subscribe to the full transactions of the sender, all transaction hashes
+	// and the pending logs of a single address
+	var (
+		txCh   = make(chan *types.Transaction, 16)
+		hashCh = make(chan common.Hash, 16)
+		logCh  = make(chan types.Log, 16)
+	)
+	txSub, err := client.EthSubscribe(context.Background(), txCh, "newPendingTransactions", true, map[string]interface{}{"addresses": []common.Address{sender}})
+	if err != nil {
+		t.Fatalf("failed to subscribe to full pending transactions: %v", err)
+	}
+	defer txSub.Unsubscribe()
+	hashSub, err := client.EthSubscribe(context.Background(), hashCh, "newPendingTransactions")
+	if err != nil {
+		t.Fatalf("failed to subscribe to pending transaction hashes: %v", err)
+	}
+	defer hashSub.Unsubscribe()
+	logSub, err := client.EthSubscribe(context.Background(), logCh, "pendingLogs", map[string]interface{}{"address": []common.Address{firstAddr}})
+	if err != nil {
+		t.Fatalf("failed to subscribe to pending logs: %v", err)
+	}
+	defer logSub.Unsubscribe()
+
+	var txs []*types.Transaction
+	for i := uint64(0); i < 3; i++ {
+		tx, _ := types.SignTx(types.NewTransaction(i, recipient, big.NewInt(1), params.TxGas, big.NewInt(1), nil), signer, key)
+		txs = append(txs, tx)
+	}
+	foreign, _ := types.SignTx(types.NewTransaction(0, recipient, big.NewInt(1), params.TxGas, big.NewInt(1), nil), signer, other)
+
+	txFeed.Send(core.NewTxsEvent{Txs: []*types.Transaction{txs[0], foreign}})
+	txFeed.Send(core.NewTxsEvent{Txs: txs[1:]})
+
+	logs := []*types.Log{
+		{Address: firstAddr, Topics: []common.Hash{}, Data: []byte{}, TxHash: txs[0].Hash(), Index: 0},
+		{Address: otherAddr, Topics: []common.Hash{}, Data: []byte{}, TxHash: txs[0].Hash(), Index: 1},
+		{Address: firstAddr, Topics: []common.Hash{}, Data: []byte{}, TxHash: txs[1].Hash(), Index: 2},
+	}
+	mux.Post(core.PendingLogsEvent{Logs: logs[:2]})
+	mux.Post(core.PendingLogsEvent{Logs: logs[2:]})
+
+	timeout := time.After(2 * time.Second)
+	for i := 0; i < len(txs); i++ {
+		select {
+		case tx := <-txCh:
+			if tx.Hash() != txs[i].Hash() {
+				t.Errorf("pending transaction %d mismatch: have %x, want %x", i, tx.Hash(), txs[i].Hash())
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting for pending transaction %d", i)
+		}
+	}
+	for i, want := range append(txs[:1:1], foreign, txs[1], txs[2]) {
+		select {
+		case hash := <-hashCh:
+			if hash != want.Hash() {
+				t.Errorf("pending transaction hash %d mismatch: have %x, want %x", i, hash, want.Hash())
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting for pending transaction hash %d", i)
+		}
+	}
+	for i, want := range []*types.Log{logs[0], logs[2]} {
+		select {
+		case log := <-logCh:
+			if log.TxHash != want.TxHash || log.Index != want.Index || log.Address != want.Address {
+				t.Errorf("pending log %d mismatch: have %v, want %v", i, log, want)
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting for pending log %d", i)
+		}
+	}
+	// Nothing may be delivered twice or beyond the criteria
+	select {
+	case tx := <-txCh:
+		t.Errorf("unexpected pending transaction %x", tx.Hash())
+	case hash := <-hashCh:
+		t.Errorf("unexpected pending transaction hash %x", hash)
+	case log := <-logCh:
+		t.Errorf("unexpected pending log %v", log)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
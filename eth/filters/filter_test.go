@@ -23,6 +23,7 @@ import (
 	"os"
 	"testing"
 
+	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
@@ -230,3 +231,91 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 0 log, got", len(logs))
 	}
 }
+
+func TestFilterPagination(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filtertest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		db, _      = ethdb.NewLDBDatabase(dir, 0, 0)
+		mux        = new(event.TypeMux)
+		txFeed     = new(event.Feed)
+		rmLogsFeed = new(event.Feed)
+		logsFeed   = new(event.Feed)
+		chainFeed  = new(event.Feed)
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		key1, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr       = crypto.PubkeyToAddress(key1.PublicKey)
+		other      = common.BytesToAddress([]byte("other"))
+	)
+	defer db.Close()
+
+	genesis := core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 30, func(i int, gen *core.BlockGen) {
+		switch i {
+		case 10, 20, 25:
+			receipt := types.NewReceipt(nil, false, 0)
+			receipt.Logs = []*types.Log{
+				{Address: addr, BlockNumber: gen.Number().Uint64(), Index: 0},
+				{Address: other, BlockNumber: gen.Number().Uint64(), Index: 1},
+				{Address: addr, BlockNumber: gen.Number().Uint64(), Index: 2},
+			}
+			gen.AddUncheckedReceipt(receipt)
+		}
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	// Page through the logs of an address, the last page must be flagged as such
+	tests := []struct {
+		limit uint64
+		pages int
+	}{
+		{0, 1}, {1, 6}, {2, 3}, {4, 2}, {6, 1}, {7, 1},
+	}
+	for i, tt := range tests {
+		var (
+			cursor *ethereum.LogCursor
+			paged  []*types.Log
+			pages  int
+		)
+		for {
+			filter := NewRangeFilter(backend, 0, -1, []common.Address{addr}, nil)
+			filter.Paginate(tt.limit, cursor)
+
+			logs, next, err := filter.Page(context.Background())
+			if err != nil {
+				t.Fatalf("test %d: failed to filter logs: %v", i, err)
+			}
+			if tt.limit > 0 && uint64(len(logs)) > tt.limit {
+				t.Fatalf("test %d: limit exceeded: have %d logs, want at most %d", i, len(logs), tt.limit)
+			}
+			paged = append(paged, logs...)
+			if pages++; next == nil || pages > 10 {
+				break
+			}
+			if last := logs[len(logs)-1]; next.BlockNumber != last.BlockNumber || next.Index != last.Index {
+				t.Fatalf("test %d: cursor %+v not at the last log #%d/%d", i, next, last.BlockNumber, last.Index)
+			}
+			cursor = next
+		}
+		if pages != tt.pages {
+			t.Errorf("test %d: page count mismatch: have %d, want %d", i, pages, tt.pages)
+		}
+		if len(paged) != 6 {
+			t.Fatalf("test %d: paged log count mismatch: have %d, want %d", i, len(paged), 6)
+		}
+		for j := 1; j < len(paged); j++ {
+			prev, cur := paged[j-1], paged[j]
+			if cur.BlockNumber < prev.BlockNumber || (cur.BlockNumber == prev.BlockNumber && cur.Index <= prev.Index) {
+				t.Errorf("test %d: log %d out of order: #%d/%d after #%d/%d", i, j, cur.BlockNumber, cur.Index, prev.BlockNumber, prev.Index)
+			}
+		}
+	}
+}
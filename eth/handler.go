@@ -30,6 +30,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/misc"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/fetcher"
@@ -67,7 +68,8 @@ func errResp(code errCode, format string, v ...interface{}) error {
 }
 
 type ProtocolManager struct {
-	networkID uint64
+	networkID  uint64
+	forkFilter forkid.Filter // Fork ID filter, constant across the lifetime of the node
 
 	fastSync  uint32 // Flag whether fast sync is enabled (gets disabled if we already have blocks)
 	acceptTxs uint32 // Flag whether we're considered synchronised (enables transaction processing)
@@ -79,6 +81,7 @@ type ProtocolManager struct {
 
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
+	txFetcher  *fetcher.TxFetcher
 	peers      *peerSet
 
 	SubProtocols []p2p.Protocol
@@ -107,6 +110,7 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 	// Create the protocol manager with the base fields
 	manager := &ProtocolManager{
 		networkID:   networkID,
+		forkFilter:  forkid.NewFilter(blockchain),
 		eventMux:    mux,
 		txpool:      txpool,
 		blockchain:  blockchain,
@@ -165,7 +169,7 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		return nil, errIncompatibleConfig
 	}
 	// Construct the different synchronisation mechanisms
-	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.removePeer)
+	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.dropSyncPeer)
 
 	validator := func(header *types.Header) error {
 		return engine.VerifyHeader(blockchain, header, true)
@@ -182,11 +186,41 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		atomic.StoreUint32(&manager.acceptTxs, 1) // Mark initial sync done on any fetcher import
 		return manager.blockchain.InsertChain(blocks)
 	}
-	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.removePeer)
+	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.dropPeerFor(p2p.ScoreInvalidBlock))
+
+	fetchTx := func(id string, hashes []common.Hash) error {
+		p := manager.peers.Peer(id)
+		if p == nil {
+			return errNotRegistered
+		}
+		return p.RequestTxs(hashes)
+	}
+	manager.txFetcher = fetcher.NewTxFetcher(txpool.Has, txpool.AddRemotes, fetchTx)
 
 	return manager, nil
 }
 
+// dropPeerFor creates a peer drop callback for the synchronisation components,
+// lowering the reputation of the offending peer before disconnecting it.
+func (pm *ProtocolManager) dropPeerFor(ev p2p.ScoreEvent) func(id string) {
+	return func(id string) {
+		if peer := pm.peers.Peer(id); peer != nil {
+			peer.Report(ev)
+		}
+		pm.removePeer(id)
+	}
+}
+
+// dropSyncPeer is the peer drop callback of the downloader, penalising the peer
+// according to the reason it is dropped for.
+func (pm *ProtocolManager) dropSyncPeer(id string, reason downloader.DropReason) {
+	ev := p2p.ScoreInvalidData
+	if reason == downloader.DropStalling {
+		ev = p2p.ScoreSlowResponse
+	}
+	pm.dropPeerFor(ev)(id)
+}
+
 func (pm *ProtocolManager) removePeer(id string) {
 	// Short circuit if the peer was already removed
 	peer := pm.peers.Peer(id)
@@ -195,8 +229,9 @@ func (pm *ProtocolManager) removePeer(id string) {
 	}
 	log.Debug("Removing Ethereum peer", "peer", id)
 
-	// Unregister the peer from the downloader and Ethereum peer set
+	// Unregister the peer from the downloader, fetchers and Ethereum peer set
 	pm.downloader.UnregisterPeer(id)
+	pm.txFetcher.Drop(id)
 	if err := pm.peers.Unregister(id); err != nil {
 		log.Error("Peer removal failed", "peer", id, "err", err)
 	}
@@ -269,7 +304,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 		number  = head.Number.Uint64()
 		td      = pm.blockchain.GetTd(hash, number)
 	)
-	if err := p.Handshake(pm.networkID, td, hash, genesis.Hash()); err != nil {
+	if err := p.Handshake(pm.networkID, td, hash, genesis.Hash(), forkid.NewID(pm.blockchain), pm.forkFilter); err != nil {
 		p.Log().Debug("Ethereum handshake failed", "err", err)
 		return err
 	}
@@ -294,7 +329,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	// If we're DAO hard-fork aware, validate any remote peer with regard to the hard-fork
 	if daoBlock := pm.chainconfig.DAOForkBlock; daoBlock != nil {
 		// Request the peer's DAO fork header for extra-data validation
-		if err := p.RequestHeadersByNumber(daoBlock.Uint64(), 1, 0, false); err != nil {
+		if err := p.RequestCheckHeader(daoBlock.Uint64()); err != nil {
 			return err
 		}
 		// Start a timer to disconnect if the peer doesn't reply in time
@@ -312,7 +347,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	}
 	// If we have any explicit whitelist block hashes, request them
 	for number := range pm.whitelist {
-		if err := p.RequestHeadersByNumber(number, 1, 0, false); err != nil {
+		if err := p.RequestCheckHeader(number); err != nil {
 			return err
 		}
 	}
@@ -348,7 +383,8 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	case msg.Code == GetBlockHeadersMsg:
 		// Decode the complex header query
 		var query getBlockHeadersData
-		if err := msg.Decode(&query); err != nil {
+		reqID, err := p.decodeRequest(msg, &query)
+		if err != nil {
 			return errResp(ErrDecode, "%v: %v", msg, err)
 		}
 		hashMode := query.Origin.Hash != (common.Hash{})
@@ -430,13 +466,28 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				query.Origin.Number += query.Skip + 1
 			}
 		}
-		return p.SendBlockHeaders(headers)
+		return p.SendBlockHeaders(reqID, headers)
 
 	case msg.Code == BlockHeadersMsg:
 		// A batch of headers arrived to one of our previous requests
 		var headers []*types.Header
-		if err := msg.Decode(&headers); err != nil {
-			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		origin, err := p.decodeResponse(msg, &headers)
+		if err != nil {
+			return err
+		}
+		// If the requester is known, route the headers exactly
+		switch origin {
+		case fetcherRequest:
+			pm.fetcher.FilterHeaders(p.id, headers, time.Now())
+			return nil
+
+		case downloaderRequest:
+			if err := pm.downloader.DeliverHeaders(p.id, headers); err != nil {
+				log.Debug("Failed to deliver headers", "err", err)
+			} else if len(headers) > 0 {
+				p.Report(p2p.ScoreUsefulData)
+			}
+			return nil
 		}
 		// If no headers were received, but we're expending a DAO fork check, maybe it's that
 		if len(headers) == 0 && p.forkDrop != nil {
@@ -483,20 +534,27 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				}
 				p.Log().Debug("Whitelist block verified", "number", headers[0].Number.Uint64(), "hash", want)
 			}
+			// Replies to our own checks are not meant for the sync machinery
+			if origin == handlerRequest {
+				return nil
+			}
 			// Irrelevant of the fork checks, send the header to the fetcher just in case
 			headers = pm.fetcher.FilterHeaders(p.id, headers, time.Now())
 		}
-		if len(headers) > 0 || !filter {
+		if origin == legacyRequest && (len(headers) > 0 || !filter) {
 			err := pm.downloader.DeliverHeaders(p.id, headers)
 			if err != nil {
 				log.Debug("Failed to deliver headers", "err", err)
+			} else if len(headers) > 0 {
+				p.Report(p2p.ScoreUsefulData)
 			}
 		}
 
 	case msg.Code == GetBlockBodiesMsg:
 		// Decode the retrieval message
 		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
-		if _, err := msgStream.List(); err != nil {
+		reqID, err := p.openRequest(msgStream)
+		if err != nil {
 			return err
 		}
 		// Gather blocks until the fetch or network limits is reached
@@ -518,13 +576,14 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				bytes += len(data)
 			}
 		}
-		return p.SendBlockBodiesRLP(bodies)
+		return p.SendBlockBodiesRLP(reqID, bodies)
 
 	case msg.Code == BlockBodiesMsg:
 		// A batch of block bodies arrived to one of our previous requests
 		var request blockBodiesData
-		if err := msg.Decode(&request); err != nil {
-			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		origin, err := p.decodeResponse(msg, &request)
+		if err != nil {
+			return err
 		}
 		// Deliver them all to the downloader for queuing
 		transactions := make([][]*types.Transaction, len(request))
@@ -534,6 +593,20 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			transactions[i] = body.Transactions
 			uncles[i] = body.Uncles
 		}
+		// If the requester is known, route the bodies exactly
+		switch origin {
+		case fetcherRequest:
+			pm.fetcher.FilterBodies(p.id, transactions, uncles, time.Now())
+			return nil
+
+		case downloaderRequest:
+			if err := pm.downloader.DeliverBodies(p.id, transactions, uncles); err != nil {
+				log.Debug("Failed to deliver bodies", "err", err)
+			} else if len(request) > 0 {
+				p.Report(p2p.ScoreUsefulData)
+			}
+			return nil
+		}
 		// Filter out any explicitly requested bodies, deliver the rest to the downloader
 		filter := len(transactions) > 0 || len(uncles) > 0
 		if filter {
@@ -543,13 +616,16 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			err := pm.downloader.DeliverBodies(p.id, transactions, uncles)
 			if err != nil {
 				log.Debug("Failed to deliver bodies", "err", err)
+			} else if len(transactions) > 0 {
+				p.Report(p2p.ScoreUsefulData)
 			}
 		}
 
 	case p.version >= eth63 && msg.Code == GetNodeDataMsg:
 		// Decode the retrieval message
 		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
-		if _, err := msgStream.List(); err != nil {
+		reqID, err := p.openRequest(msgStream)
+		if err != nil {
 			return err
 		}
 		// Gather state data until the fetch or network limits is reached
@@ -571,23 +647,26 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				bytes += len(entry)
 			}
 		}
-		return p.SendNodeData(data)
+		return p.SendNodeData(reqID, data)
 
 	case p.version >= eth63 && msg.Code == NodeDataMsg:
 		// A batch of node state data arrived to one of our previous requests
 		var data [][]byte
-		if err := msg.Decode(&data); err != nil {
-			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		if _, err := p.decodeResponse(msg, &data); err != nil {
+			return err
 		}
 		// Deliver all to the downloader
 		if err := pm.downloader.DeliverNodeData(p.id, data); err != nil {
 			log.Debug("Failed to deliver node state data", "err", err)
+		} else if len(data) > 0 {
+			p.Report(p2p.ScoreUsefulData)
 		}
 
 	case p.version >= eth63 && msg.Code == GetReceiptsMsg:
 		// Decode the retrieval message
 		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
-		if _, err := msgStream.List(); err != nil {
+		reqID, err := p.openRequest(msgStream)
+		if err != nil {
 			return err
 		}
 		// Gather state data until the fetch or network limits is reached
@@ -618,17 +697,19 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				bytes += len(encoded)
 			}
 		}
-		return p.SendReceiptsRLP(receipts)
+		return p.SendReceiptsRLP(reqID, receipts)
 
 	case p.version >= eth63 && msg.Code == ReceiptsMsg:
 		// A batch of receipts arrived to one of our previous requests
 		var receipts [][]*types.Receipt
-		if err := msg.Decode(&receipts); err != nil {
-			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		if _, err := p.decodeResponse(msg, &receipts); err != nil {
+			return err
 		}
 		// Deliver all to the downloader
 		if err := pm.downloader.DeliverReceipts(p.id, receipts); err != nil {
 			log.Debug("Failed to deliver receipts", "err", err)
+		} else if len(receipts) > 0 {
+			p.Report(p2p.ScoreUsefulData)
 		}
 
 	case msg.Code == NewBlockHashesMsg:
@@ -648,7 +729,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 		}
 		for _, block := range unknown {
-			pm.fetcher.Notify(p.id, block.Hash, block.Number, time.Now(), p.RequestOneHeader, p.RequestBodies)
+			pm.fetcher.Notify(p.id, block.Hash, block.Number, time.Now(), p.RequestOneHeader, p.FetchBodies)
 		}
 
 	case msg.Code == NewBlockMsg:
@@ -683,6 +764,59 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 		}
 
+	case p.version >= eth65 && msg.Code == NewPooledTransactionHashesMsg:
+		// New transaction announcement arrived, make sure we have a valid and fresh
+		// chain to handle them
+		if atomic.LoadUint32(&pm.acceptTxs) == 0 {
+			break
+		}
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Schedule all the unknown hashes for retrieval
+		for _, hash := range hashes {
+			p.MarkTransaction(hash)
+		}
+		pm.txFetcher.Notify(p.id, hashes)
+
+	case p.version >= eth65 && msg.Code == GetPooledTransactionsMsg:
+		// Decode the retrieval message
+		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
+		reqID, err := p.openRequest(msgStream)
+		if err != nil {
+			return err
+		}
+		// Gather transactions until the fetch or network limits is reached
+		var (
+			hash   common.Hash
+			bytes  int
+			hashes []common.Hash
+			txs    []rlp.RawValue
+		)
+		for bytes < softResponseLimit {
+			// Retrieve the hash of the next transaction
+			if err := msgStream.Decode(&hash); err == rlp.EOL {
+				break
+			} else if err != nil {
+				return errResp(ErrDecode, "msg %v: %v", msg, err)
+			}
+			// Retrieve the requested transaction, skipping if unknown to us
+			tx := pm.txpool.Get(hash)
+			if tx == nil {
+				continue
+			}
+			// If known, encode and queue for response packet
+			if encoded, err := rlp.EncodeToBytes(tx); err != nil {
+				log.Error("Failed to encode transaction", "err", err)
+			} else {
+				hashes = append(hashes, hash)
+				txs = append(txs, encoded)
+				bytes += len(encoded)
+			}
+		}
+		return p.SendPooledTransactionsRLP(reqID, hashes, txs)
+
 	case msg.Code == TxMsg:
 		// Transactions arrived, make sure we have a valid and fresh chain to handle them
 		if atomic.LoadUint32(&pm.acceptTxs) == 0 {
@@ -700,7 +834,25 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 			p.MarkTransaction(tx.Hash())
 		}
-		pm.txpool.AddRemotes(txs)
+		pm.txFetcher.Enqueue(p.id, txs, false)
+
+	case p.version >= eth65 && msg.Code == PooledTransactionsMsg:
+		// A batch of transactions arrived to one of our previous requests
+		var txs []*types.Transaction
+		if _, err := p.decodeResponse(msg, &txs); err != nil {
+			return err
+		}
+		if atomic.LoadUint32(&pm.acceptTxs) == 0 {
+			break
+		}
+		for i, tx := range txs {
+			// Validate and mark the remote transaction
+			if tx == nil {
+				return errResp(ErrDecode, "transaction %d is nil", i)
+			}
+			p.MarkTransaction(tx.Hash())
+		}
+		pm.txFetcher.Enqueue(p.id, txs, true)
 
 	default:
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
@@ -748,23 +900,38 @@ func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
 	}
 }
 
-// BroadcastTxs will propagate a batch of transactions to all peers which are not known to
-// already have the given transaction.
+// BroadcastTxs will propagate a batch of transactions to a square root of the
+// peers which are not known to already have the given transaction, and announce
+// their hashes to the rest. Peers predating eth/65 can't retrieve announced
+// transactions, so they are sent the full transactions instead.
 func (pm *ProtocolManager) BroadcastTxs(txs types.Transactions) {
-	var txset = make(map[*peer]types.Transactions)
-
+	var (
+		txset = make(map[*peer]types.Transactions)
+		annos = make(map[*peer][]common.Hash)
+	)
 	// Broadcast transactions to a batch of peers not knowing about it
 	for _, tx := range txs {
 		peers := pm.peers.PeersWithoutTx(tx.Hash())
-		for _, peer := range peers {
+
+		direct := int(math.Sqrt(float64(len(peers))))
+		for _, peer := range peers[:direct] {
 			txset[peer] = append(txset[peer], tx)
 		}
-		log.Trace("Broadcast transaction", "hash", tx.Hash(), "recipients", len(peers))
+		for _, peer := range peers[direct:] {
+			if peer.version >= eth65 {
+				annos[peer] = append(annos[peer], tx.Hash())
+			} else {
+				txset[peer] = append(txset[peer], tx)
+			}
+		}
+		log.Trace("Broadcast transaction", "hash", tx.Hash(), "direct", direct, "recipients", len(peers))
 	}
-	// FIXME include this again: peers = peers[:int(math.Sqrt(float64(len(peers))))]
 	for peer, txs := range txset {
 		peer.AsyncSendTransactions(txs)
 	}
+	for peer, hashes := range annos {
+		peer.AsyncSendPooledTransactionHashes(hashes)
+	}
 }
 
 // Mined broadcast loop
@@ -21,6 +21,7 @@ import (
 	"math"
 	"math/big"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
@@ -46,8 +47,10 @@ func TestProtocolCompatibility(t *testing.T) {
 		mode       downloader.SyncMode
 		compatible bool
 	}{
-		{61, downloader.FullSync, true}, {62, downloader.FullSync, true}, {63, downloader.FullSync, true},
-		{61, downloader.FastSync, false}, {62, downloader.FastSync, false}, {63, downloader.FastSync, true},
+		{61, downloader.FullSync, true}, {62, downloader.FullSync, true}, {63, downloader.FullSync, true}, {64, downloader.FullSync, true},
+		{65, downloader.FullSync, true}, {66, downloader.FullSync, true},
+		{61, downloader.FastSync, false}, {62, downloader.FastSync, false}, {63, downloader.FastSync, true}, {64, downloader.FastSync, true},
+		{65, downloader.FastSync, true}, {66, downloader.FastSync, true},
 	}
 	// Make sure anything we screw up is restored
 	backup := ProtocolVersions
@@ -70,6 +73,9 @@ func TestProtocolCompatibility(t *testing.T) {
 // Tests that block headers can be retrieved from a remote chain based on user queries.
 func TestGetBlockHeaders62(t *testing.T) { testGetBlockHeaders(t, 62) }
 func TestGetBlockHeaders63(t *testing.T) { testGetBlockHeaders(t, 63) }
+func TestGetBlockHeaders64(t *testing.T) { testGetBlockHeaders(t, 64) }
+func TestGetBlockHeaders65(t *testing.T) { testGetBlockHeaders(t, 65) }
+func TestGetBlockHeaders66(t *testing.T) { testGetBlockHeaders(t, 66) }
 
 func testGetBlockHeaders(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, downloader.MaxHashFetch+15, nil, nil)
@@ -208,8 +214,8 @@ func testGetBlockHeaders(t *testing.T, protocol int) {
 			headers = append(headers, pm.blockchain.GetBlockByHash(hash).Header())
 		}
 		// Send the hash request and verify the response
-		p2p.Send(peer.app, 0x03, tt.query)
-		if err := p2p.ExpectMsg(peer.app, 0x04, headers); err != nil {
+		peer.sendPacket(0x03, uint64(i+1), tt.query)
+		if _, err := peer.expectPacket(0x04, uint64(i+1), headers); err != nil {
 			t.Errorf("test %d: headers mismatch: %v", i, err)
 		}
 		// If the test used number origins, repeat with hashes as the too
@@ -217,8 +223,8 @@ func testGetBlockHeaders(t *testing.T, protocol int) {
 			if origin := pm.blockchain.GetBlockByNumber(tt.query.Origin.Number); origin != nil {
 				tt.query.Origin.Hash, tt.query.Origin.Number = origin.Hash(), 0
 
-				p2p.Send(peer.app, 0x03, tt.query)
-				if err := p2p.ExpectMsg(peer.app, 0x04, headers); err != nil {
+				peer.sendPacket(0x03, uint64(i+1), tt.query)
+				if _, err := peer.expectPacket(0x04, uint64(i+1), headers); err != nil {
 					t.Errorf("test %d: headers mismatch: %v", i, err)
 				}
 			}
@@ -229,6 +235,9 @@ func testGetBlockHeaders(t *testing.T, protocol int) {
 // Tests that block contents can be retrieved from a remote chain based on their hashes.
 func TestGetBlockBodies62(t *testing.T) { testGetBlockBodies(t, 62) }
 func TestGetBlockBodies63(t *testing.T) { testGetBlockBodies(t, 63) }
+func TestGetBlockBodies64(t *testing.T) { testGetBlockBodies(t, 64) }
+func TestGetBlockBodies65(t *testing.T) { testGetBlockBodies(t, 65) }
+func TestGetBlockBodies66(t *testing.T) { testGetBlockBodies(t, 66) }
 
 func testGetBlockBodies(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, downloader.MaxBlockFetch+15, nil, nil)
@@ -291,8 +300,8 @@ func testGetBlockBodies(t *testing.T, protocol int) {
 			}
 		}
 		// Send the hash request and verify the response
-		p2p.Send(peer.app, 0x05, hashes)
-		if err := p2p.ExpectMsg(peer.app, 0x06, bodies); err != nil {
+		peer.sendPacket(0x05, uint64(i+1), hashes)
+		if _, err := peer.expectPacket(0x06, uint64(i+1), bodies); err != nil {
 			t.Errorf("test %d: bodies mismatch: %v", i, err)
 		}
 	}
@@ -300,6 +309,9 @@ func testGetBlockBodies(t *testing.T, protocol int) {
 
 // Tests that the node state database can be retrieved based on hashes.
 func TestGetNodeData63(t *testing.T) { testGetNodeData(t, 63) }
+func TestGetNodeData64(t *testing.T) { testGetNodeData(t, 64) }
+func TestGetNodeData65(t *testing.T) { testGetNodeData(t, 65) }
+func TestGetNodeData66(t *testing.T) { testGetNodeData(t, 66) }
 
 func testGetNodeData(t *testing.T, protocol int) {
 	// Define three accounts to simulate transactions with
@@ -349,7 +361,7 @@ func testGetNodeData(t *testing.T, protocol int) {
 			hashes = append(hashes, common.BytesToHash(key))
 		}
 	}
-	p2p.Send(peer.app, 0x0d, hashes)
+	peer.sendPacket(0x0d, 1, hashes)
 	msg, err := peer.app.ReadMsg()
 	if err != nil {
 		t.Fatalf("failed to read node data response: %v", err)
@@ -358,7 +370,19 @@ func testGetNodeData(t *testing.T, protocol int) {
 		t.Fatalf("response packet code mismatch: have %x, want %x", msg.Code, 0x0c)
 	}
 	var data [][]byte
-	if err := msg.Decode(&data); err != nil {
+	if peer.version >= eth66 {
+		var packet struct {
+			ReqID uint64
+			Data  [][]byte
+		}
+		if err := msg.Decode(&packet); err != nil {
+			t.Fatalf("failed to decode response node data: %v", err)
+		}
+		if packet.ReqID != 1 {
+			t.Fatalf("response request id mismatch: have %d, want %d", packet.ReqID, 1)
+		}
+		data = packet.Data
+	} else if err := msg.Decode(&data); err != nil {
 		t.Fatalf("failed to decode response node data: %v", err)
 	}
 	// Verify that all hashes correspond to the requested data, and reconstruct a state tree
@@ -392,6 +416,9 @@ func testGetNodeData(t *testing.T, protocol int) {
 
 // Tests that the transaction receipts can be retrieved based on hashes.
 func TestGetReceipt63(t *testing.T) { testGetReceipt(t, 63) }
+func TestGetReceipt64(t *testing.T) { testGetReceipt(t, 64) }
+func TestGetReceipt65(t *testing.T) { testGetReceipt(t, 65) }
+func TestGetReceipt66(t *testing.T) { testGetReceipt(t, 66) }
 
 func testGetReceipt(t *testing.T, protocol int) {
 	// Define three accounts to simulate transactions with
@@ -443,23 +470,116 @@ func testGetReceipt(t *testing.T, protocol int) {
 		receipts = append(receipts, pm.blockchain.GetReceiptsByHash(block.Hash()))
 	}
 	// Send the hash request and verify the response
-	p2p.Send(peer.app, 0x0f, hashes)
-	if err := p2p.ExpectMsg(peer.app, 0x10, receipts); err != nil {
+	peer.sendPacket(0x0f, 1, hashes)
+	if _, err := peer.expectPacket(0x10, 1, receipts); err != nil {
 		t.Errorf("receipts mismatch: %v", err)
 	}
 }
 
+// Tests that pooled transactions can be retrieved based on hashes.
+func TestGetPooledTransactions65(t *testing.T) { testGetPooledTransactions(t, 65) }
+func TestGetPooledTransactions66(t *testing.T) { testGetPooledTransactions(t, 66) }
+
+func testGetPooledTransactions(t *testing.T, protocol int) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	peer, _ := newTestPeer("peer", protocol, pm, true)
+	defer peer.close()
+
+	// Fill the pool with a few transactions and request some known and unknown ones
+	txs := make([]*types.Transaction, 3)
+	for nonce := range txs {
+		txs[nonce] = newTestTransaction(testAccount, uint64(nonce), 0)
+	}
+	pm.txpool.AddRemotes(txs)
+
+	unknown := newTestTransaction(testAccount, uint64(len(txs)), 0)
+	hashes := []common.Hash{txs[0].Hash(), unknown.Hash(), txs[2].Hash()}
+
+	// Send the hash request and verify that only the known transactions are returned
+	peer.sendPacket(GetPooledTransactionsMsg, 1, hashes)
+	if _, err := peer.expectPacket(PooledTransactionsMsg, 1, []*types.Transaction{txs[0], txs[2]}); err != nil {
+		t.Errorf("transactions mismatch: %v", err)
+	}
+}
+
+// Tests that replies not matching any pending request are rejected on eth/66
+// and above, dropping the offending peer.
+func TestUnsolicitedResponse66(t *testing.T) { testUnsolicitedResponse(t, 66) }
+
+func testUnsolicitedResponse(t *testing.T, protocol int) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	tests := []struct {
+		code uint64
+		data interface{}
+	}{
+		{BlockHeadersMsg, []*types.Header{}},
+		{BlockBodiesMsg, []*blockBody{}},
+		{NodeDataMsg, [][]byte{}},
+		{ReceiptsMsg, [][]*types.Receipt{}},
+		{PooledTransactionsMsg, []*types.Transaction{}},
+	}
+	for i, tt := range tests {
+		peer, errc := newTestPeer(fmt.Sprintf("peer #%d", i), protocol, pm, true)
+		if err := peer.sendPacket(tt.code, 42, tt.data); err != nil {
+			t.Fatalf("test %d: failed to send reply: %v", i, err)
+		}
+		select {
+		case err := <-errc:
+			if want := errResp(ErrUnsolicitedResponse, ""); err == nil || !strings.HasPrefix(err.Error(), want.Error()) {
+				t.Errorf("test %d: drop error mismatch: have %v, want %v", i, err, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("test %d: peer not dropped for unsolicited reply", i)
+		}
+		peer.close()
+	}
+}
+
+// Tests that a reply with a valid request ID is only accepted once.
+func TestDuplicateResponse66(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	peer, errc := newTestPeer("peer", eth66, pm, true)
+	defer peer.close()
+
+	// Issue a request from the local side and answer it twice
+	go peer.RequestReceipts([]common.Hash{{}})
+	reqID, err := peer.expectPacket(GetReceiptsMsg, 0, []common.Hash{{}})
+	if err != nil {
+		t.Fatalf("request mismatch: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := peer.sendPacket(ReceiptsMsg, reqID, [][]*types.Receipt{}); err != nil {
+			t.Fatalf("failed to send reply %d: %v", i, err)
+		}
+	}
+	select {
+	case err := <-errc:
+		if want := errResp(ErrUnsolicitedResponse, ""); err == nil || !strings.HasPrefix(err.Error(), want.Error()) {
+			t.Errorf("drop error mismatch: have %v, want %v", err, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("peer not dropped for duplicate reply")
+	}
+}
+
 // Tests that post eth protocol handshake, DAO fork-enabled clients also execute
 // a DAO "challenge" verifying each others' DAO fork headers to ensure they're on
 // compatible chains.
-func TestDAOChallengeNoVsNo(t *testing.T)       { testDAOChallenge(t, false, false, false) }
-func TestDAOChallengeNoVsPro(t *testing.T)      { testDAOChallenge(t, false, true, false) }
-func TestDAOChallengeProVsNo(t *testing.T)      { testDAOChallenge(t, true, false, false) }
-func TestDAOChallengeProVsPro(t *testing.T)     { testDAOChallenge(t, true, true, false) }
-func TestDAOChallengeNoVsTimeout(t *testing.T)  { testDAOChallenge(t, false, false, true) }
-func TestDAOChallengeProVsTimeout(t *testing.T) { testDAOChallenge(t, true, true, true) }
-
-func testDAOChallenge(t *testing.T, localForked, remoteForked bool, timeout bool) {
+func TestDAOChallengeNoVsNo(t *testing.T)       { testDAOChallenge(t, eth63, false, false, false) }
+func TestDAOChallengeNoVsPro(t *testing.T)      { testDAOChallenge(t, eth63, false, true, false) }
+func TestDAOChallengeProVsNo(t *testing.T)      { testDAOChallenge(t, eth63, true, false, false) }
+func TestDAOChallengeProVsPro(t *testing.T)     { testDAOChallenge(t, eth63, true, true, false) }
+func TestDAOChallengeNoVsTimeout(t *testing.T)  { testDAOChallenge(t, eth63, false, false, true) }
+func TestDAOChallengeProVsTimeout(t *testing.T) { testDAOChallenge(t, eth63, true, true, true) }
+
+func TestDAOChallengeNoVsNo66(t *testing.T)  { testDAOChallenge(t, eth66, false, false, false) }
+func TestDAOChallengeNoVsPro66(t *testing.T) { testDAOChallenge(t, eth66, false, true, false) }
+
+func testDAOChallenge(t *testing.T, protocol int, localForked, remoteForked bool, timeout bool) {
 	// Reduce the DAO handshake challenge timeout
 	if timeout {
 		defer func(old time.Duration) { daoChallengeTimeout = old }(daoChallengeTimeout)
@@ -486,7 +606,7 @@ func testDAOChallenge(t *testing.T, localForked, remoteForked bool, timeout bool
 	defer pm.Stop()
 
 	// Connect a new peer and check that we receive the DAO challenge
-	peer, _ := newTestPeer("peer", eth63, pm, true)
+	peer, _ := newTestPeer("peer", protocol, pm, true)
 	defer peer.close()
 
 	challenge := &getBlockHeadersData{
@@ -495,7 +615,8 @@ func testDAOChallenge(t *testing.T, localForked, remoteForked bool, timeout bool
 		Skip:    0,
 		Reverse: false,
 	}
-	if err := p2p.ExpectMsg(peer.app, GetBlockHeadersMsg, challenge); err != nil {
+	reqID, err := peer.expectPacket(GetBlockHeadersMsg, 0, challenge)
+	if err != nil {
 		t.Fatalf("challenge mismatch: %v", err)
 	}
 	// Create a block to reply to the challenge if no timeout is simulated
@@ -505,7 +626,7 @@ func testDAOChallenge(t *testing.T, localForked, remoteForked bool, timeout bool
 				block.SetExtra(params.DAOForkBlockExtra)
 			}
 		})
-		if err := p2p.Send(peer.app, BlockHeadersMsg, []*types.Header{blocks[0].Header()}); err != nil {
+		if err := peer.sendPacket(BlockHeadersMsg, reqID, []*types.Header{blocks[0].Header()}); err != nil {
 			t.Fatalf("failed to answer challenge: %v", err)
 		}
 		time.Sleep(100 * time.Millisecond) // Sleep to avoid the verification racing with the drops
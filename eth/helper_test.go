@@ -20,8 +20,11 @@
 package eth
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/rand"
+	"fmt"
+	"io/ioutil"
 	"math/big"
 	"sort"
 	"sync"
@@ -30,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -39,6 +43,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 var (
@@ -95,6 +100,26 @@ type testTxPool struct {
 	lock sync.RWMutex // Protects the transaction pool
 }
 
+// Has returns an indicator whether the pool contains a transaction with the
+// given hash.
+func (p *testTxPool) Has(hash common.Hash) bool {
+	return p.Get(hash) != nil
+}
+
+// Get retrieves the transaction with the given hash from the pool, or nil if
+// it's unknown.
+func (p *testTxPool) Get(hash common.Hash) *types.Transaction {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	for _, tx := range p.pool {
+		if tx.Hash() == hash {
+			return tx
+		}
+	}
+	return nil
+}
+
 // AddRemotes appends a batch of transactions to the pool, and notifies any
 // listeners if the addition channel is non nil
 func (p *testTxPool) AddRemotes(txs []*types.Transaction) []error {
@@ -171,20 +196,33 @@ func newTestPeer(name string, version int, pm *ProtocolManager, shake bool) (*te
 			head    = pm.blockchain.CurrentHeader()
 			td      = pm.blockchain.GetTd(head.Hash(), head.Number.Uint64())
 		)
-		tp.handshake(nil, td, head.Hash(), genesis.Hash())
+		tp.handshake(nil, td, head.Hash(), genesis.Hash(), forkid.NewID(pm.blockchain))
 	}
 	return tp, errc
 }
 
 // handshake simulates a trivial handshake that expects the same state from the
 // remote side as we are simulating locally.
-func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, genesis common.Hash) {
-	msg := &statusData{
-		ProtocolVersion: uint32(p.version),
-		NetworkId:       DefaultConfig.NetworkId,
-		TD:              td,
-		CurrentBlock:    head,
-		GenesisBlock:    genesis,
+func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, genesis common.Hash, forkID forkid.ID) {
+	var msg interface{}
+	switch {
+	case p.version >= eth64:
+		msg = &statusData64{
+			ProtocolVersion: uint32(p.version),
+			NetworkId:       DefaultConfig.NetworkId,
+			TD:              td,
+			CurrentBlock:    head,
+			GenesisBlock:    genesis,
+			ForkID:          forkID,
+		}
+	default:
+		msg = &statusData{
+			ProtocolVersion: uint32(p.version),
+			NetworkId:       DefaultConfig.NetworkId,
+			TD:              td,
+			CurrentBlock:    head,
+			GenesisBlock:    genesis,
+		}
 	}
 	if err := p2p.ExpectMsg(p.app, StatusMsg, msg); err != nil {
 		t.Fatalf("status recv: %v", err)
@@ -194,6 +232,52 @@ func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, genesi
 	}
 }
 
+// sendPacket sends a request or response to the protocol manager, wrapping it
+// into the request ID envelope on eth/66 and above.
+func (p *testPeer) sendPacket(code uint64, reqID uint64, data interface{}) error {
+	if p.version >= eth66 {
+		return p2p.Send(p.app, code, &packet66{ReqID: reqID, Data: data})
+	}
+	return p2p.Send(p.app, code, data)
+}
+
+// expectPacket reads the next message from the protocol manager and checks its
+// code and contents, returning the request ID it was wrapped with on eth/66 and
+// above. The expected request ID is only verified if non-zero.
+func (p *testPeer) expectPacket(code uint64, reqID uint64, data interface{}) (uint64, error) {
+	msg, err := p.app.ReadMsg()
+	if err != nil {
+		return 0, err
+	}
+	defer msg.Discard()
+
+	if msg.Code != code {
+		return 0, fmt.Errorf("message code mismatch: got %d, expected %d", msg.Code, code)
+	}
+	want, err := rlp.EncodeToBytes(data)
+	if err != nil {
+		return 0, err
+	}
+	payload, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return 0, err
+	}
+	if p.version >= eth66 {
+		var packet rawPacket66
+		if err := rlp.DecodeBytes(payload, &packet); err != nil {
+			return 0, err
+		}
+		if reqID != 0 && packet.ReqID != reqID {
+			return 0, fmt.Errorf("request id mismatch: got %d, expected %d", packet.ReqID, reqID)
+		}
+		reqID, payload = packet.ReqID, packet.Data
+	}
+	if !bytes.Equal(payload, want) {
+		return 0, fmt.Errorf("message payload mismatch:\ngot:  %x\nwant: %x", payload, want)
+	}
+	return reqID, nil
+}
+
 // close terminates the local side of the peer, notifying the remote protocol
 // manager of termination.
 func (p *testPeer) close() {
@@ -0,0 +1,122 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/bitutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+const (
+	// logIndexThrottling is the time to wait between processing two consecutive
+	// log index sections. It's useful during chain upgrades to prevent disk overload.
+	logIndexThrottling = 100 * time.Millisecond
+)
+
+// logIndexEntry identifies a single value of a log field in the log index.
+type logIndexEntry struct {
+	field byte
+	value string
+}
+
+// LogIndexer implements a core.ChainIndexer, building up an exact inverted index
+// of log addresses and topics to the blocks containing them. Contrary to the
+// bloom bits, the index doesn't produce false positives, so popular addresses
+// and topics can be filtered without checking every block of a section.
+type LogIndexer struct {
+	size    uint64                   // section size to generate the log index for
+	db      ethdb.Database           // database instance to write index data and metadata into
+	bits    map[logIndexEntry][]byte // block bit vectors of the values seen in the current section
+	section uint64                   // Section is the section number being processed currently
+	head    common.Hash              // Head is the hash of the last header processed
+}
+
+// NewLogIndexer returns a chain indexer that generates an exact address and
+// topic index of the logs in the canonical chain.
+func NewLogIndexer(db ethdb.Database, size, confirms uint64) *core.ChainIndexer {
+	backend := &LogIndexer{
+		db:   db,
+		size: size,
+	}
+	table := ethdb.NewTable(db, string(rawdb.LogIndexIndexPrefix))
+
+	return core.NewChainIndexer(db, table, backend, size, confirms, logIndexThrottling, "logindex")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new log index section.
+func (l *LogIndexer) Reset(ctx context.Context, section uint64, lastSectionHead common.Hash) error {
+	l.bits, l.section, l.head = make(map[logIndexEntry][]byte), section, common.Hash{}
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, adding the addresses and topics
+// of a new header's logs into the index.
+func (l *LogIndexer) Process(ctx context.Context, header *types.Header) error {
+	hash, number := header.Hash(), header.Number.Uint64()
+
+	receipts := rawdb.ReadReceipts(l.db, hash, number)
+	if receipts == nil && header.Bloom != (types.Bloom{}) {
+		return fmt.Errorf("missing receipts for block #%d [%x…]", number, hash[:4])
+	}
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			l.add(rawdb.LogIndexAddressField, log.Address.Bytes(), number)
+			for i, topic := range log.Topics {
+				l.add(byte(i), topic.Bytes(), number)
+			}
+		}
+	}
+	l.head = hash
+	return nil
+}
+
+// add marks the given block as containing a log with the given field value.
+func (l *LogIndexer) add(field byte, value []byte, number uint64) {
+	entry := logIndexEntry{field: field, value: string(value)}
+
+	bits := l.bits[entry]
+	if bits == nil {
+		bits = make([]byte, l.size/8)
+		l.bits[entry] = bits
+	}
+	index := number - l.section*l.size
+	bits[index/8] |= 1 << byte(7-index%8)
+}
+
+// Commit implements core.ChainIndexerBackend, finalizing the log index section
+// and writing it out into the database.
+func (l *LogIndexer) Commit() error {
+	batch := l.db.NewBatch()
+	for entry, bits := range l.bits {
+		rawdb.WriteLogIndex(batch, entry.field, []byte(entry.value), l.section, l.head, bitutil.CompressBytes(bits))
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	return batch.Write()
+}
@@ -0,0 +1,268 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/bitutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// logIndexTestSize is the section size used by the log indexer tests.
+const logIndexTestSize = 16
+
+// logIndexTestBackend is a filter backend serving the canonical chain of a
+// database, with the first sections covered by the exact log index.
+type logIndexTestBackend struct {
+	filters.Backend // Unused methods panic
+
+	db       ethdb.Database
+	sections uint64
+}
+
+func (b *logIndexTestBackend) ChainDb() ethdb.Database { return b.db }
+
+func (b *logIndexTestBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.LatestBlockNumber {
+		hash := rawdb.ReadHeadHeaderHash(b.db)
+		return rawdb.ReadHeader(b.db, hash, *rawdb.ReadHeaderNumber(b.db, hash)), nil
+	}
+	return rawdb.ReadHeader(b.db, rawdb.ReadCanonicalHash(b.db, uint64(number)), uint64(number)), nil
+}
+
+func (b *logIndexTestBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return rawdb.ReadReceipts(b.db, hash, *rawdb.ReadHeaderNumber(b.db, hash)), nil
+}
+
+func (b *logIndexTestBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
+	receipts, _ := b.GetReceipts(ctx, hash)
+	logs := make([][]*types.Log, len(receipts))
+	for i, receipt := range receipts {
+		logs[i] = receipt.Logs
+	}
+	return logs, nil
+}
+
+func (b *logIndexTestBackend) BloomStatus() (uint64, uint64) { return logIndexTestSize, 0 }
+
+func (b *logIndexTestBackend) LogIndexStatus() (uint64, uint64) {
+	return logIndexTestSize, b.sections
+}
+
+// makeLogIndexChain generates blocks on top of parent, with a receipt holding
+// the given logs in the blocks listed in logs.
+func makeLogIndexChain(db ethdb.Database, parent *types.Block, n int, seed byte, logs map[uint64][]*types.Log) ([]*types.Block, []types.Receipts) {
+	return core.GenerateChain(params.TestChainConfig, parent, ethash.NewFaker(), db, n, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(common.Address{seed})
+		number := gen.Number().Uint64()
+		if len(logs[number]) == 0 {
+			return
+		}
+		receipt := types.NewReceipt(nil, false, 0)
+		for i, log := range logs[number] {
+			cpy := *log
+			cpy.BlockNumber, cpy.Index, cpy.TxHash = number, uint(i), common.Hash{seed, byte(number)}
+			receipt.Logs = append(receipt.Logs, &cpy)
+		}
+		gen.AddUncheckedReceipt(receipt)
+	})
+}
+
+// writeLogIndexChain makes the given blocks canonical along with their receipts.
+func writeLogIndexChain(db ethdb.Database, blocks []*types.Block, receipts []types.Receipts) {
+	for i, block := range blocks {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+	}
+	head := blocks[len(blocks)-1]
+	rawdb.WriteHeadHeaderHash(db, head.Hash())
+	rawdb.WriteHeadBlockHash(db, head.Hash())
+}
+
+// indexLogSection runs the log indexer over a section of the canonical chain
+// the same way the chain indexer does.
+func indexLogSection(t *testing.T, indexer *LogIndexer, db ethdb.Database, section uint64) {
+	var prev common.Hash
+	if section > 0 {
+		prev = rawdb.ReadCanonicalHash(db, section*logIndexTestSize-1)
+	}
+	if err := indexer.Reset(context.Background(), section, prev); err != nil {
+		t.Fatalf("section %d: reset failed: %v", section, err)
+	}
+	for number := section * logIndexTestSize; number < (section+1)*logIndexTestSize; number++ {
+		header := rawdb.ReadHeader(db, rawdb.ReadCanonicalHash(db, number), number)
+		if err := indexer.Process(context.Background(), header); err != nil {
+			t.Fatalf("section %d: failed to process block #%d: %v", section, number, err)
+		}
+	}
+	if err := indexer.Commit(); err != nil {
+		t.Fatalf("section %d: commit failed: %v", section, err)
+	}
+}
+
+// indexedBlocks returns the block numbers the log index marks for the given
+// field value within a section.
+func indexedBlocks(t *testing.T, db ethdb.Database, field byte, value []byte, section uint64) []uint64 {
+	head := rawdb.ReadCanonicalHash(db, (section+1)*logIndexTestSize-1)
+	comp, err := rawdb.ReadLogIndex(db, field, value, section, head)
+	if err != nil {
+		return nil
+	}
+	bits, err := bitutil.DecompressBytes(comp, logIndexTestSize/8)
+	if err != nil {
+		t.Fatalf("invalid log index bits: %v", err)
+	}
+	var numbers []uint64
+	for i := uint64(0); i < logIndexTestSize; i++ {
+		if bits[i/8]&(1<<byte(7-i%8)) != 0 {
+			numbers = append(numbers, section*logIndexTestSize+i)
+		}
+	}
+	return numbers
+}
+
+// checkIndexedLogs runs the test filters both through the exact log index and
+// through raw block iteration and checks that they produce the same logs.
+func checkIndexedLogs(t *testing.T, db ethdb.Database, sections uint64, addresses []common.Address, topics [][]common.Hash, want int) {
+	indexed, err := filters.NewRangeFilter(&logIndexTestBackend{db: db, sections: sections}, 0, -1, addresses, topics).Logs(context.Background())
+	if err != nil {
+		t.Fatalf("failed to filter indexed logs: %v", err)
+	}
+	plain, err := filters.NewRangeFilter(&logIndexTestBackend{db: db}, 0, -1, addresses, topics).Logs(context.Background())
+	if err != nil {
+		t.Fatalf("failed to filter unindexed logs: %v", err)
+	}
+	if len(indexed) != want {
+		t.Errorf("addresses %x, topics %x: log count mismatch: have %d, want %d", addresses, topics, len(indexed), want)
+	}
+	if !reflect.DeepEqual(indexed, plain) {
+		t.Errorf("addresses %x, topics %x: indexed logs differ from unindexed ones", addresses, topics)
+	}
+}
+
+func TestLogIndexer(t *testing.T) {
+	var (
+		db      = ethdb.NewMemDatabase()
+		genesis = core.GenesisBlockForTesting(db, common.Address{}, big.NewInt(0))
+		indexer = &LogIndexer{db: db, size: logIndexTestSize}
+
+		addr1, addr2   = common.Address{1}, common.Address{2}
+		topic1, topic2 = common.Hash{1}, common.Hash{2}
+	)
+	// Two indexed sections and an unindexed tail, with logs in all of them
+	logsA := map[uint64][]*types.Log{
+		3:  {{Address: addr1, Topics: []common.Hash{topic1}}, {Address: addr2, Topics: []common.Hash{topic2}}},
+		17: {{Address: addr1, Topics: []common.Hash{topic2, topic1}}},
+		21: {{Address: addr1, Topics: []common.Hash{topic1}}},
+		35: {{Address: addr1}, {Address: addr2, Topics: []common.Hash{topic1}}},
+	}
+	blocksA, receiptsA := makeLogIndexChain(db, genesis, 40, 0xa, logsA)
+	writeLogIndexChain(db, blocksA, receiptsA)
+
+	indexLogSection(t, indexer, db, 0)
+	indexLogSection(t, indexer, db, 1)
+
+	if have := indexedBlocks(t, db, rawdb.LogIndexAddressField, addr1.Bytes(), 1); !reflect.DeepEqual(have, []uint64{17, 21}) {
+		t.Fatalf("indexed blocks of address mismatch: have %v, want %v", have, []uint64{17, 21})
+	}
+	if have := indexedBlocks(t, db, 1, topic1.Bytes(), 1); !reflect.DeepEqual(have, []uint64{17}) {
+		t.Fatalf("indexed blocks of second topic mismatch: have %v, want %v", have, []uint64{17})
+	}
+	checkIndexedLogs(t, db, 2, []common.Address{addr1}, nil, 4)
+	checkIndexedLogs(t, db, 2, []common.Address{addr1, addr2}, nil, 6)
+	checkIndexedLogs(t, db, 2, nil, [][]common.Hash{{topic1}}, 3)
+	checkIndexedLogs(t, db, 2, nil, [][]common.Hash{{}, {topic1}}, 1)
+	checkIndexedLogs(t, db, 2, []common.Address{addr2}, [][]common.Hash{{topic1}}, 1)
+	checkIndexedLogs(t, db, 2, []common.Address{addr1}, [][]common.Hash{{topic2}, {topic1}}, 1)
+
+	// Page through the logs of an address across the index and the unindexed tail
+	var (
+		cursor *ethereum.LogCursor
+		paged  []*types.Log
+	)
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatalf("paging did not terminate")
+		}
+		filter := filters.NewRangeFilter(&logIndexTestBackend{db: db, sections: 2}, 0, -1, []common.Address{addr1}, nil)
+		filter.Paginate(2, cursor)
+
+		logs, next, err := filter.Page(context.Background())
+		if err != nil {
+			t.Fatalf("failed to page logs: %v", err)
+		}
+		if len(logs) > 2 || (next != nil && len(logs) != 2) {
+			t.Fatalf("page %d: have %d logs, more: %t", pages, len(logs), next != nil)
+		}
+		paged = append(paged, logs...)
+		if next == nil {
+			break
+		}
+		cursor = next
+	}
+	if len(paged) != 4 {
+		t.Fatalf("paged log count mismatch: have %d, want %d", len(paged), 4)
+	}
+	for i := 1; i < len(paged); i++ {
+		prev, cur := paged[i-1], paged[i]
+		if cur.BlockNumber < prev.BlockNumber || (cur.BlockNumber == prev.BlockNumber && cur.Index <= prev.Index) {
+			t.Errorf("log %d out of order: #%d/%d after #%d/%d", i, cur.BlockNumber, cur.Index, prev.BlockNumber, prev.Index)
+		}
+	}
+
+	// Reorg the second section while it's being indexed. The indexer is reset
+	// by the chain indexer, nothing of the dropped blocks may remain.
+	if err := indexer.Reset(context.Background(), 1, blocksA[logIndexTestSize-2].Hash()); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	for _, block := range blocksA[logIndexTestSize-1 : 23] {
+		if err := indexer.Process(context.Background(), block.Header()); err != nil {
+			t.Fatalf("failed to process block #%d: %v", block.NumberU64(), err)
+		}
+	}
+	logsB := map[uint64][]*types.Log{
+		22: {{Address: addr2, Topics: []common.Hash{topic2}}},
+	}
+	blocksB, receiptsB := makeLogIndexChain(db, blocksA[19], 20, 0xb, logsB)
+	writeLogIndexChain(db, blocksB, receiptsB)
+
+	indexLogSection(t, indexer, db, 1)
+
+	if have := indexedBlocks(t, db, rawdb.LogIndexAddressField, addr1.Bytes(), 1); !reflect.DeepEqual(have, []uint64{17}) {
+		t.Fatalf("indexed blocks of address after reorg mismatch: have %v, want %v", have, []uint64{17})
+	}
+	if have := indexedBlocks(t, db, rawdb.LogIndexAddressField, addr2.Bytes(), 1); !reflect.DeepEqual(have, []uint64{22}) {
+		t.Fatalf("indexed blocks of reorged address mismatch: have %v, want %v", have, []uint64{22})
+	}
+	checkIndexedLogs(t, db, 2, []common.Address{addr1}, nil, 2)
+	checkIndexedLogs(t, db, 2, []common.Address{addr2}, nil, 2)
+	checkIndexedLogs(t, db, 2, nil, [][]common.Hash{{topic1}}, 1)
+}
@@ -25,6 +25,7 @@ import (
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -45,6 +46,11 @@ const (
 	// contain a single transaction, or thousands.
 	maxQueuedTxs = 128
 
+	// maxQueuedTxAnns is the maximum number of transaction announcement lists to
+	// queue up before dropping broadcasts. Announcements are cheap compared to
+	// full transactions, so allow a larger backlog.
+	maxQueuedTxAnns = 128
+
 	// maxQueuedProps is the maximum number of block propagations to queue up before
 	// dropping broadcasts. There's not much point in queueing stale blocks, so a few
 	// that might cover uncles should be enough.
@@ -55,9 +61,36 @@ const (
 	// above some healthy uncle limit, so use that.
 	maxQueuedAnns = 4
 
+	// maxRequestAge is the time after which a pending request is forgotten. Any
+	// reply arriving later is treated as unsolicited.
+	maxRequestAge = 2 * time.Minute
+
+	// slowResponseTime is the time after which a reply to a request is deemed
+	// slow, lowering the reputation of the peer.
+	slowResponseTime = 10 * time.Second
+
 	handshakeTimeout = 5 * time.Second
 )
 
+// requestOrigin identifies the component which issued a request, so that the
+// reply can be routed back to it.
+type requestOrigin int
+
+const (
+	legacyRequest     requestOrigin = iota // Reply from a pre eth/66 peer, origin unknown
+	handlerRequest                         // Header checks issued by the protocol manager
+	downloaderRequest                      // Retrievals issued by the chain downloader
+	fetcherRequest                         // Retrievals issued by the block fetcher
+	txFetcherRequest                       // Retrievals issued by the transaction fetcher
+)
+
+// pendingRequest is an eth/66 request still awaiting its reply.
+type pendingRequest struct {
+	code   uint64        // Message code the reply is expected to arrive with
+	origin requestOrigin // Component the reply needs to be routed to
+	time   time.Time     // Timestamp of the request to expire it eventually
+}
+
 // PeerInfo represents a short summary of the Ethereum sub-protocol metadata known
 // about a connected peer.
 type PeerInfo struct {
@@ -87,24 +120,31 @@ type peer struct {
 
 	knownTxs    mapset.Set                // Set of transaction hashes known to be known by this peer
 	knownBlocks mapset.Set                // Set of block hashes known to be known by this peer
-	queuedTxs   chan []*types.Transaction // Queue of transactions to broadcast to the peer
-	queuedProps chan *propEvent           // Queue of blocks to broadcast to the peer
-	queuedAnns  chan *types.Block         // Queue of blocks to announce to the peer
-	term        chan struct{}             // Termination channel to stop the broadcaster
+	queuedTxs    chan []*types.Transaction // Queue of transactions to broadcast to the peer
+	queuedTxAnns chan []common.Hash        // Queue of transaction hashes to announce to the peer
+	queuedProps  chan *propEvent           // Queue of blocks to broadcast to the peer
+	queuedAnns   chan *types.Block         // Queue of blocks to announce to the peer
+	term         chan struct{}             // Termination channel to stop the broadcaster
+
+	reqID    uint64                     // Last request ID issued to the peer
+	requests map[uint64]*pendingRequest // Requests awaiting a reply (eth/66 and above)
+	reqLock  sync.Mutex                 // Protects the request tracking fields
 }
 
 func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
 	return &peer{
-		Peer:        p,
-		rw:          rw,
-		version:     version,
-		id:          fmt.Sprintf("%x", p.ID().Bytes()[:8]),
-		knownTxs:    mapset.NewSet(),
-		knownBlocks: mapset.NewSet(),
-		queuedTxs:   make(chan []*types.Transaction, maxQueuedTxs),
-		queuedProps: make(chan *propEvent, maxQueuedProps),
-		queuedAnns:  make(chan *types.Block, maxQueuedAnns),
-		term:        make(chan struct{}),
+		Peer:         p,
+		rw:           rw,
+		version:      version,
+		id:           fmt.Sprintf("%x", p.ID().Bytes()[:8]),
+		knownTxs:     mapset.NewSet(),
+		knownBlocks:  mapset.NewSet(),
+		queuedTxs:    make(chan []*types.Transaction, maxQueuedTxs),
+		queuedTxAnns: make(chan []common.Hash, maxQueuedTxAnns),
+		queuedProps:  make(chan *propEvent, maxQueuedProps),
+		queuedAnns:   make(chan *types.Block, maxQueuedAnns),
+		term:         make(chan struct{}),
+		requests:     make(map[uint64]*pendingRequest),
 	}
 }
 
@@ -120,6 +160,12 @@ func (p *peer) broadcast() {
 			}
 			p.Log().Trace("Broadcast transactions", "count", len(txs))
 
+		case hashes := <-p.queuedTxAnns:
+			if err := p.SendPooledTransactionHashes(hashes); err != nil {
+				return
+			}
+			p.Log().Trace("Announced transactions", "count", len(hashes))
+
 		case prop := <-p.queuedProps:
 			if err := p.SendNewBlock(prop.block, prop.td); err != nil {
 				return
@@ -215,6 +261,40 @@ func (p *peer) AsyncSendTransactions(txs []*types.Transaction) {
 	}
 }
 
+// SendPooledTransactionHashes announces the availability of a batch of
+// transactions through a hash notification and includes the hashes in its
+// transaction hash set for future reference.
+func (p *peer) SendPooledTransactionHashes(hashes []common.Hash) error {
+	for _, hash := range hashes {
+		p.knownTxs.Add(hash)
+	}
+	return p2p.Send(p.rw, NewPooledTransactionHashesMsg, hashes)
+}
+
+// AsyncSendPooledTransactionHashes queues a list of transaction hashes to be
+// announced to a remote peer. If the peer's announcement queue is full, the
+// event is silently dropped.
+func (p *peer) AsyncSendPooledTransactionHashes(hashes []common.Hash) {
+	select {
+	case p.queuedTxAnns <- hashes:
+		for _, hash := range hashes {
+			p.knownTxs.Add(hash)
+		}
+	default:
+		p.Log().Debug("Dropping transaction announcement", "count", len(hashes))
+	}
+}
+
+// SendPooledTransactionsRLP sends requested transactions to the peer from an
+// already RLP encoded format and includes the hashes in its transaction hash
+// set for future reference.
+func (p *peer) SendPooledTransactionsRLP(reqID uint64, hashes []common.Hash, txs []rlp.RawValue) error {
+	for _, hash := range hashes {
+		p.knownTxs.Add(hash)
+	}
+	return p.sendResponse(PooledTransactionsMsg, reqID, txs)
+}
+
 // SendNewBlockHashes announces the availability of a number of blocks through
 // a hash notification.
 func (p *peer) SendNewBlockHashes(hashes []common.Hash, numbers []uint64) error {
@@ -259,92 +339,231 @@ func (p *peer) AsyncSendNewBlock(block *types.Block, td *big.Int) {
 }
 
 // SendBlockHeaders sends a batch of block headers to the remote peer.
-func (p *peer) SendBlockHeaders(headers []*types.Header) error {
-	return p2p.Send(p.rw, BlockHeadersMsg, headers)
-}
-
-// SendBlockBodies sends a batch of block contents to the remote peer.
-func (p *peer) SendBlockBodies(bodies []*blockBody) error {
-	return p2p.Send(p.rw, BlockBodiesMsg, blockBodiesData(bodies))
+func (p *peer) SendBlockHeaders(reqID uint64, headers []*types.Header) error {
+	return p.sendResponse(BlockHeadersMsg, reqID, headers)
 }
 
 // SendBlockBodiesRLP sends a batch of block contents to the remote peer from
 // an already RLP encoded format.
-func (p *peer) SendBlockBodiesRLP(bodies []rlp.RawValue) error {
-	return p2p.Send(p.rw, BlockBodiesMsg, bodies)
+func (p *peer) SendBlockBodiesRLP(reqID uint64, bodies []rlp.RawValue) error {
+	return p.sendResponse(BlockBodiesMsg, reqID, bodies)
 }
 
-// SendNodeDataRLP sends a batch of arbitrary internal data, corresponding to the
+// SendNodeData sends a batch of arbitrary internal data, corresponding to the
 // hashes requested.
-func (p *peer) SendNodeData(data [][]byte) error {
-	return p2p.Send(p.rw, NodeDataMsg, data)
+func (p *peer) SendNodeData(reqID uint64, data [][]byte) error {
+	return p.sendResponse(NodeDataMsg, reqID, data)
 }
 
 // SendReceiptsRLP sends a batch of transaction receipts, corresponding to the
 // ones requested from an already RLP encoded format.
-func (p *peer) SendReceiptsRLP(receipts []rlp.RawValue) error {
-	return p2p.Send(p.rw, ReceiptsMsg, receipts)
+func (p *peer) SendReceiptsRLP(reqID uint64, receipts []rlp.RawValue) error {
+	return p.sendResponse(ReceiptsMsg, reqID, receipts)
 }
 
 // RequestOneHeader is a wrapper around the header query functions to fetch a
 // single header. It is used solely by the fetcher.
 func (p *peer) RequestOneHeader(hash common.Hash) error {
 	p.Log().Debug("Fetching single header", "hash", hash)
-	return p2p.Send(p.rw, GetBlockHeadersMsg, &getBlockHeadersData{Origin: hashOrNumber{Hash: hash}, Amount: uint64(1), Skip: uint64(0), Reverse: false})
+	return p.sendRequest(GetBlockHeadersMsg, fetcherRequest, &getBlockHeadersData{Origin: hashOrNumber{Hash: hash}, Amount: uint64(1), Skip: uint64(0), Reverse: false})
+}
+
+// RequestCheckHeader fetches a single header by number. It is used solely by
+// the protocol manager to validate the DAO fork and whitelisted blocks.
+func (p *peer) RequestCheckHeader(number uint64) error {
+	p.Log().Debug("Fetching check header", "number", number)
+	return p.sendRequest(GetBlockHeadersMsg, handlerRequest, &getBlockHeadersData{Origin: hashOrNumber{Number: number}, Amount: uint64(1), Skip: uint64(0), Reverse: false})
 }
 
 // RequestHeadersByHash fetches a batch of blocks' headers corresponding to the
 // specified header query, based on the hash of an origin block.
 func (p *peer) RequestHeadersByHash(origin common.Hash, amount int, skip int, reverse bool) error {
 	p.Log().Debug("Fetching batch of headers", "count", amount, "fromhash", origin, "skip", skip, "reverse", reverse)
-	return p2p.Send(p.rw, GetBlockHeadersMsg, &getBlockHeadersData{Origin: hashOrNumber{Hash: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
+	return p.sendRequest(GetBlockHeadersMsg, downloaderRequest, &getBlockHeadersData{Origin: hashOrNumber{Hash: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
 }
 
 // RequestHeadersByNumber fetches a batch of blocks' headers corresponding to the
 // specified header query, based on the number of an origin block.
 func (p *peer) RequestHeadersByNumber(origin uint64, amount int, skip int, reverse bool) error {
 	p.Log().Debug("Fetching batch of headers", "count", amount, "fromnum", origin, "skip", skip, "reverse", reverse)
-	return p2p.Send(p.rw, GetBlockHeadersMsg, &getBlockHeadersData{Origin: hashOrNumber{Number: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
+	return p.sendRequest(GetBlockHeadersMsg, downloaderRequest, &getBlockHeadersData{Origin: hashOrNumber{Number: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
 }
 
 // RequestBodies fetches a batch of blocks' bodies corresponding to the hashes
 // specified.
 func (p *peer) RequestBodies(hashes []common.Hash) error {
 	p.Log().Debug("Fetching batch of block bodies", "count", len(hashes))
-	return p2p.Send(p.rw, GetBlockBodiesMsg, hashes)
+	return p.sendRequest(GetBlockBodiesMsg, downloaderRequest, hashes)
+}
+
+// FetchBodies fetches the bodies of freshly announced blocks. It is the block
+// fetcher's counterpart of RequestBodies, used solely by the fetcher.
+func (p *peer) FetchBodies(hashes []common.Hash) error {
+	p.Log().Debug("Fetching announced block bodies", "count", len(hashes))
+	return p.sendRequest(GetBlockBodiesMsg, fetcherRequest, hashes)
 }
 
 // RequestNodeData fetches a batch of arbitrary data from a node's known state
 // data, corresponding to the specified hashes.
 func (p *peer) RequestNodeData(hashes []common.Hash) error {
 	p.Log().Debug("Fetching batch of state data", "count", len(hashes))
-	return p2p.Send(p.rw, GetNodeDataMsg, hashes)
+	return p.sendRequest(GetNodeDataMsg, downloaderRequest, hashes)
 }
 
 // RequestReceipts fetches a batch of transaction receipts from a remote node.
 func (p *peer) RequestReceipts(hashes []common.Hash) error {
 	p.Log().Debug("Fetching batch of receipts", "count", len(hashes))
-	return p2p.Send(p.rw, GetReceiptsMsg, hashes)
+	return p.sendRequest(GetReceiptsMsg, downloaderRequest, hashes)
+}
+
+// RequestTxs fetches a batch of transactions from a remote node's pool.
+func (p *peer) RequestTxs(hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of transactions", "count", len(hashes))
+	return p.sendRequest(GetPooledTransactionsMsg, txFetcherRequest, hashes)
+}
+
+// sendRequest sends a retrieval request to the remote peer. On eth/66 and above
+// the request is tagged with a fresh ID and tracked until its reply arrives.
+func (p *peer) sendRequest(code uint64, origin requestOrigin, data interface{}) error {
+	if p.version < eth66 {
+		return p2p.Send(p.rw, code, data)
+	}
+	p.reqLock.Lock()
+	now, expired := time.Now(), 0
+	for id, req := range p.requests {
+		if now.Sub(req.time) > maxRequestAge {
+			delete(p.requests, id)
+			expired++
+		}
+	}
+	p.reqID++
+	reqID := p.reqID
+	p.requests[reqID] = &pendingRequest{code: responseCodes[code], origin: origin, time: now}
+	p.reqLock.Unlock()
+
+	// Requests never answered count as slow responses
+	for i := 0; i < expired; i++ {
+		p.Report(p2p.ScoreSlowResponse)
+	}
+	return p2p.Send(p.rw, code, &packet66{ReqID: reqID, Data: data})
+}
+
+// sendResponse sends the reply to a retrieval request, echoing back the
+// request's ID on eth/66 and above.
+func (p *peer) sendResponse(code uint64, reqID uint64, data interface{}) error {
+	if p.version < eth66 {
+		return p2p.Send(p.rw, code, data)
+	}
+	return p2p.Send(p.rw, code, &packet66{ReqID: reqID, Data: data})
+}
+
+// decodeRequest decodes the payload of a retrieval request into val, returning
+// the ID to reply with (always zero before eth/66).
+func (p *peer) decodeRequest(msg p2p.Msg, val interface{}) (uint64, error) {
+	if p.version < eth66 {
+		return 0, msg.Decode(val)
+	}
+	var packet rawPacket66
+	if err := msg.Decode(&packet); err != nil {
+		return 0, err
+	}
+	return packet.ReqID, rlp.DecodeBytes(packet.Data, val)
+}
+
+// openRequest positions a stream over a retrieval request at the start of its
+// list of queried items, returning the ID to reply with (always zero before
+// eth/66).
+func (p *peer) openRequest(s *rlp.Stream) (uint64, error) {
+	if _, err := s.List(); err != nil {
+		return 0, err
+	}
+	if p.version < eth66 {
+		return 0, nil
+	}
+	id, err := s.Uint()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.List(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// decodeResponse decodes the payload of a reply into val. On eth/66 and above
+// the reply is matched against the pending requests, returning the component
+// which issued it. Replies not matching any request are rejected.
+func (p *peer) decodeResponse(msg p2p.Msg, val interface{}) (requestOrigin, error) {
+	if p.version < eth66 {
+		if err := msg.Decode(val); err != nil {
+			return legacyRequest, errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		return legacyRequest, nil
+	}
+	var packet rawPacket66
+	if err := msg.Decode(&packet); err != nil {
+		return legacyRequest, errResp(ErrDecode, "msg %v: %v", msg, err)
+	}
+	p.reqLock.Lock()
+	req := p.requests[packet.ReqID]
+	if req != nil && req.code == msg.Code {
+		delete(p.requests, packet.ReqID)
+	}
+	p.reqLock.Unlock()
+
+	if req == nil || req.code != msg.Code {
+		reqUnsolicitedInMeter.Mark(1)
+		p.Report(p2p.ScoreInvalidData)
+		return legacyRequest, errResp(ErrUnsolicitedResponse, "msg %v: id %d", msg, packet.ReqID)
+	}
+	if time.Since(req.time) > slowResponseTime {
+		p.Report(p2p.ScoreSlowResponse)
+	}
+	if err := rlp.DecodeBytes(packet.Data, val); err != nil {
+		return legacyRequest, errResp(ErrDecode, "msg %v: %v", msg, err)
+	}
+	return req.origin, nil
 }
 
 // Handshake executes the eth protocol handshake, negotiating version number,
-// network IDs, difficulties, head and genesis blocks.
-func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash) error {
+// network IDs, difficulties, head and genesis blocks. From eth/64 on, the fork
+// IDs are exchanged too and the remote one is validated against forkFilter.
+func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash, forkID forkid.ID, forkFilter forkid.Filter) error {
 	// Send out own handshake in a new thread
 	errc := make(chan error, 2)
-	var status statusData // safe to read after two values have been received from errc
 
+	var (
+		status   statusData   // safe to read after two values have been received from errc
+		status64 statusData64 // safe to read after two values have been received from errc
+	)
 	go func() {
-		errc <- p2p.Send(p.rw, StatusMsg, &statusData{
-			ProtocolVersion: uint32(p.version),
-			NetworkId:       network,
-			TD:              td,
-			CurrentBlock:    head,
-			GenesisBlock:    genesis,
-		})
+		switch {
+		case p.version >= eth64:
+			errc <- p2p.Send(p.rw, StatusMsg, &statusData64{
+				ProtocolVersion: uint32(p.version),
+				NetworkId:       network,
+				TD:              td,
+				CurrentBlock:    head,
+				GenesisBlock:    genesis,
+				ForkID:          forkID,
+			})
+		default:
+			errc <- p2p.Send(p.rw, StatusMsg, &statusData{
+				ProtocolVersion: uint32(p.version),
+				NetworkId:       network,
+				TD:              td,
+				CurrentBlock:    head,
+				GenesisBlock:    genesis,
+			})
+		}
 	}()
 	go func() {
-		errc <- p.readStatus(network, &status, genesis)
+		switch {
+		case p.version >= eth64:
+			errc <- p.readStatus(network, &status64, genesis, forkFilter)
+		default:
+			errc <- p.readStatusLegacy(network, &status, genesis)
+		}
 	}()
 	timeout := time.NewTimer(handshakeTimeout)
 	defer timeout.Stop()
@@ -358,24 +577,38 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 			return p2p.DiscReadTimeout
 		}
 	}
-	p.td, p.head = status.TD, status.CurrentBlock
+	switch {
+	case p.version >= eth64:
+		p.td, p.head = status64.TD, status64.CurrentBlock
+	default:
+		p.td, p.head = status.TD, status.CurrentBlock
+	}
 	return nil
 }
 
-func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash) (err error) {
-	msg, err := p.rw.ReadMsg()
-	if err != nil {
+// readStatusLegacy reads and validates the status message of eth/62 and eth/63
+// peers, which carry no fork identifier.
+func (p *peer) readStatusLegacy(network uint64, status *statusData, genesis common.Hash) error {
+	if err := p.readStatusMsg(status); err != nil {
 		return err
 	}
-	if msg.Code != StatusMsg {
-		return errResp(ErrNoStatusMsg, "first msg has code %x (!= %x)", msg.Code, StatusMsg)
+	if status.GenesisBlock != genesis {
+		return errResp(ErrGenesisBlockMismatch, "%x (!= %x)", status.GenesisBlock[:8], genesis[:8])
 	}
-	if msg.Size > ProtocolMaxMsgSize {
-		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	if status.NetworkId != network {
+		return errResp(ErrNetworkIdMismatch, "%d (!= %d)", status.NetworkId, network)
 	}
-	// Decode the handshake and make sure everything matches
-	if err := msg.Decode(&status); err != nil {
-		return errResp(ErrDecode, "msg %v: %v", msg, err)
+	if int(status.ProtocolVersion) != p.version {
+		return errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)
+	}
+	return nil
+}
+
+// readStatus reads and validates the status message of eth/64 peers, rejecting
+// the ones whose fork ID is incompatible with the local chain.
+func (p *peer) readStatus(network uint64, status *statusData64, genesis common.Hash, forkFilter forkid.Filter) error {
+	if err := p.readStatusMsg(status); err != nil {
+		return err
 	}
 	if status.GenesisBlock != genesis {
 		return errResp(ErrGenesisBlockMismatch, "%x (!= %x)", status.GenesisBlock[:8], genesis[:8])
@@ -386,6 +619,29 @@ func (p *peer) readStatus(network uint64, status *statusData, genesis common.Has
 	if int(status.ProtocolVersion) != p.version {
 		return errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)
 	}
+	if err := forkFilter(status.ForkID); err != nil {
+		return errResp(ErrForkIDRejected, "%v", err)
+	}
+	return nil
+}
+
+// readStatusMsg reads the first message of the remote peer, expecting it to be a
+// status message and decoding it into status.
+func (p *peer) readStatusMsg(status interface{}) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Code != StatusMsg {
+		return errResp(ErrNoStatusMsg, "first msg has code %x (!= %x)", msg.Code, StatusMsg)
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	// Decode the handshake, the caller makes sure everything matches
+	if err := msg.Decode(status); err != nil {
+		return errResp(ErrDecode, "msg %v: %v", msg, err)
+	}
 	return nil
 }
 
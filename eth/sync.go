@@ -61,7 +61,8 @@ func (pm *ProtocolManager) syncTransactions(p *peer) {
 // txsyncLoop takes care of the initial transaction sync for each new
 // connection. When a new peer appears, we relay all currently pending
 // transactions. In order to minimise egress bandwidth usage, we send
-// the transactions in small packs to one peer at a time.
+// the transactions (or only their hashes to eth/65 peers) in small packs
+// to one peer at a time.
 func (pm *ProtocolManager) txsyncLoop() {
 	var (
 		pending = make(map[enode.ID]*txsync)
@@ -88,7 +89,19 @@ func (pm *ProtocolManager) txsyncLoop() {
 		// Send the pack in the background.
 		s.p.Log().Trace("Sending batch of transactions", "count", len(pack.txs), "bytes", size)
 		sending = true
-		go func() { done <- pack.p.SendTransactions(pack.txs) }()
+		go func() {
+			// Peers supporting eth/65 can retrieve the transactions they miss,
+			// so only announce them instead of pushing full transactions.
+			if pack.p.version >= eth65 {
+				hashes := make([]common.Hash, len(pack.txs))
+				for i, tx := range pack.txs {
+					hashes[i] = tx.Hash()
+				}
+				done <- pack.p.SendPooledTransactionHashes(hashes)
+				return
+			}
+			done <- pack.p.SendTransactions(pack.txs)
+		}()
 	}
 
 	// pick chooses the next pending sync.
@@ -135,6 +148,8 @@ func (pm *ProtocolManager) syncer() {
 	// Start and ensure cleanup of sync mechanisms
 	pm.fetcher.Start()
 	defer pm.fetcher.Stop()
+	pm.txFetcher.Start()
+	defer pm.txFetcher.Stop()
 	defer pm.downloader.Terminate()
 
 	// Wait for different events to fire synchronisation operations
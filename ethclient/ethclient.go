@@ -267,6 +267,16 @@ func (ec *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*
 	return r, err
 }
 
+// BlockReceipts returns the receipts of all transactions in the given block.
+func (ec *Client) BlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]*types.Receipt, error) {
+	var r []*types.Receipt
+	err := ec.c.CallContext(ctx, &r, "eth_getBlockReceipts", blockNrOrHash)
+	if err == nil && r == nil {
+		return nil, ethereum.NotFound
+	}
+	return r, err
+}
+
 func toBlockNumArg(number *big.Int) string {
 	if number == nil {
 		return "latest"
@@ -313,6 +323,67 @@ func (ec *Client) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header)
 	return ec.c.EthSubscribe(ctx, ch, "newHeads")
 }
 
+// ExportedBlock is a block along with the receipts of its transactions, as
+// streamed by SubscribeExportBlockRange.
+type ExportedBlock struct {
+	Header       *types.Header
+	Transactions []*types.Transaction
+	Receipts     []*types.Receipt
+
+	// Done is set on the final notification of an export, which carries no
+	// block. Err is the reason if the export ended before the end block.
+	Done bool
+	Err  error
+}
+
+// UnmarshalJSON decodes an exported block notification.
+func (b *ExportedBlock) UnmarshalJSON(input []byte) error {
+	var raw struct {
+		Block    json.RawMessage  `json:"block"`
+		Receipts []*types.Receipt `json:"receipts"`
+		Done     bool             `json:"done"`
+		Error    string           `json:"error"`
+	}
+	if err := json.Unmarshal(input, &raw); err != nil {
+		return err
+	}
+	if raw.Done {
+		b.Done = true
+		if raw.Error != "" {
+			b.Err = errors.New(raw.Error)
+		}
+		return nil
+	}
+	var head *types.Header
+	var body rpcBlock
+	if err := json.Unmarshal(raw.Block, &head); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw.Block, &body); err != nil {
+		return err
+	}
+	if len(body.Transactions) != len(raw.Receipts) {
+		return fmt.Errorf("receipts length mismatch: %d vs %d", len(body.Transactions), len(raw.Receipts))
+	}
+	txs := make([]*types.Transaction, len(body.Transactions))
+	for i, tx := range body.Transactions {
+		if tx.From != nil {
+			setSenderFromServer(tx.tx, *tx.From, body.Hash)
+		}
+		txs[i] = tx.tx
+	}
+	b.Header, b.Transactions, b.Receipts = head, txs, raw.Receipts
+	return nil
+}
+
+// SubscribeExportBlockRange streams the canonical blocks in the given range along
+// with their receipts, one notification per block, followed by a final one with
+// Done set. A nil start or end means the latest block. The subscription requires
+// the debug API to be enabled.
+func (ec *Client) SubscribeExportBlockRange(ctx context.Context, start, end *big.Int, ch chan<- *ExportedBlock) (ethereum.Subscription, error) {
+	return ec.c.Subscribe(ctx, "debug", ch, "exportBlockRange", toBlockNumArg(start), toBlockNumArg(end))
+}
+
 // State Access
 
 // NetworkID returns the network ID (also known as the chain ID) for this chain.
@@ -373,6 +444,29 @@ func (ec *Client) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]typ
 	return result, err
 }
 
+// FilterLogsPage executes a filter query limited to q.Limit logs. Besides the logs,
+// it returns the cursor to continue the query from, or nil if no further logs match.
+func (ec *Client) FilterLogsPage(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, *ethereum.LogCursor, error) {
+	var page struct {
+		Logs   []types.Log `json:"logs"`
+		Cursor *struct {
+			BlockNumber hexutil.Uint64 `json:"blockNumber"`
+			Index       hexutil.Uint   `json:"logIndex"`
+		} `json:"cursor"`
+	}
+	arg, err := toFilterArg(q)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ec.c.CallContext(ctx, &page, "eth_getLogsPage", arg); err != nil {
+		return nil, nil, err
+	}
+	if page.Cursor == nil {
+		return page.Logs, nil, nil
+	}
+	return page.Logs, &ethereum.LogCursor{BlockNumber: uint64(page.Cursor.BlockNumber), Index: uint(page.Cursor.Index)}, nil
+}
+
 // SubscribeFilterLogs subscribes to the results of a streaming filter query.
 func (ec *Client) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
 	arg, err := toFilterArg(q)
@@ -382,6 +476,33 @@ func (ec *Client) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuer
 	return ec.c.EthSubscribe(ctx, ch, "logs", arg)
 }
 
+// SubscribePendingLogs subscribes to the logs produced by the transactions of the
+// pending block which match the given filter query. The block range of the query
+// is ignored.
+func (ec *Client) SubscribePendingLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	arg := map[string]interface{}{
+		"address": q.Addresses,
+		"topics":  q.Topics,
+	}
+	return ec.c.EthSubscribe(ctx, ch, "pendingLogs", arg)
+}
+
+// SubscribePendingTransactions subscribes to the hashes of the transactions
+// entering the transaction pool.
+func (ec *Client) SubscribePendingTransactions(ctx context.Context, ch chan<- common.Hash) (ethereum.Subscription, error) {
+	return ec.c.EthSubscribe(ctx, ch, "newPendingTransactions")
+}
+
+// SubscribeFullPendingTransactions subscribes to the full transactions entering
+// the transaction pool. If any addresses are given, only the transactions sent
+// from or to one of them are delivered.
+func (ec *Client) SubscribeFullPendingTransactions(ctx context.Context, addresses []common.Address, ch chan<- *types.Transaction) (ethereum.Subscription, error) {
+	crit := map[string]interface{}{
+		"addresses": addresses,
+	}
+	return ec.c.EthSubscribe(ctx, ch, "newPendingTransactions", true, crit)
+}
+
 func toFilterArg(q ethereum.FilterQuery) (interface{}, error) {
 	arg := map[string]interface{}{
 		"address": q.Addresses,
@@ -400,6 +521,15 @@ func toFilterArg(q ethereum.FilterQuery) (interface{}, error) {
 		}
 		arg["toBlock"] = toBlockNumArg(q.ToBlock)
 	}
+	if q.Limit > 0 {
+		arg["limit"] = hexutil.Uint64(q.Limit)
+	}
+	if q.Cursor != nil {
+		arg["cursor"] = map[string]interface{}{
+			"blockNumber": hexutil.Uint64(q.Cursor.BlockNumber),
+			"logIndex":    hexutil.Uint(q.Cursor.Index),
+		}
+	}
 	return arg, nil
 }
 
@@ -17,13 +17,26 @@
 package ethclient
 
 import (
+	"context"
 	"fmt"
 	"math/big"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 // Verify that Client implements the ethereum interfaces.
@@ -150,3 +163,236 @@ func TestToFilterArg(t *testing.T) {
 		})
 	}
 }
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testBalance = big.NewInt(2e18)
+)
+
+// testGenesis returns the genesis specification of the test chains.
+func testGenesis() *core.Genesis {
+	return &core.Genesis{
+		Config:    params.AllEthashProtocolChanges,
+		Alloc:     core.GenesisAlloc{testAddr: {Balance: testBalance}},
+		ExtraData: []byte("test genesis"),
+		Timestamp: 9000,
+	}
+}
+
+// newTestBackend starts an in-memory node with a full eth service whose chain
+// consists of the given number of test blocks.
+func newTestBackend(t *testing.T, blocks int) (*node.Node, *eth.Ethereum, []*types.Block) {
+	n, err := node.New(&node.Config{P2P: p2p.Config{NoDiscovery: true, NoDial: true}})
+	if err != nil {
+		t.Fatalf("can't create new node: %v", err)
+	}
+	config := &eth.Config{Genesis: testGenesis()}
+	config.Ethash.PowMode = ethash.ModeFake
+
+	if err := n.Register(func(ctx *node.ServiceContext) (node.Service, error) { return eth.New(ctx, config) }); err != nil {
+		t.Fatalf("can't register eth service: %v", err)
+	}
+	if err := n.Start(); err != nil {
+		t.Fatalf("can't start test node: %v", err)
+	}
+	var ethservice *eth.Ethereum
+	if err := n.Service(&ethservice); err != nil {
+		t.Fatalf("can't retrieve eth service: %v", err)
+	}
+	chain := generateTestChain(blocks, 1)
+	if _, err := ethservice.BlockChain().InsertChain(chain); err != nil {
+		t.Fatalf("can't import test blocks: %v", err)
+	}
+	return n, ethservice, chain
+}
+
+// generateTestChain creates blocks on top of the test genesis. Every block has
+// a value transfer to the seed address, which makes chains with different seeds
+// distinct, and a contract creation emitting a log.
+func generateTestChain(blocks int, seed byte) []*types.Block {
+	var (
+		db      = ethdb.NewMemDatabase()
+		genesis = testGenesis().MustCommit(db)
+		signer  = types.HomesteadSigner{}
+		logCode = common.FromHex("0x60006000a0") // PUSH1 0 PUSH1 0 LOG0
+	)
+	chain, _ := core.GenerateChain(params.AllEthashProtocolChanges, genesis, ethash.NewFaker(), db, blocks, func(i int, gen *core.BlockGen) {
+		nonce := gen.TxNonce(testAddr)
+		tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{seed}, big.NewInt(1), params.TxGas, big.NewInt(1), nil), signer, testKey)
+		gen.AddTx(tx)
+		tx, _ = types.SignTx(types.NewContractCreation(nonce+1, big.NewInt(0), 100000, big.NewInt(1), logCode), signer, testKey)
+		gen.AddTx(tx)
+	})
+	return chain
+}
+
+func TestBlockReceipts(t *testing.T) {
+	n, ethservice, chain := newTestBackend(t, 3)
+	defer n.Stop()
+
+	rpcClient, _ := n.Attach()
+	client := NewClient(rpcClient)
+
+	tests := []struct {
+		arg   rpc.BlockNumberOrHash
+		block *types.Block
+	}{
+		{rpc.BlockNumberOrHashWithNumber(1), chain[0]},
+		{rpc.BlockNumberOrHashWithNumber(3), chain[2]},
+		{rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), chain[2]},
+		{rpc.BlockNumberOrHashWithHash(chain[1].Hash(), false), chain[1]},
+	}
+	for i, tt := range tests {
+		receipts, err := client.BlockReceipts(context.Background(), tt.arg)
+		if err != nil {
+			t.Fatalf("test %d: failed to retrieve receipts: %v", i, err)
+		}
+		txs := tt.block.Transactions()
+		if len(receipts) != len(txs) {
+			t.Fatalf("test %d: receipt count mismatch: have %d, want %d", i, len(receipts), len(txs))
+		}
+		want := ethservice.BlockChain().GetReceiptsByHash(tt.block.Hash())
+		for j, receipt := range receipts {
+			if receipt.TxHash != txs[j].Hash() {
+				t.Errorf("test %d, receipt %d: tx hash mismatch: have %x, want %x", i, j, receipt.TxHash, txs[j].Hash())
+			}
+			if len(receipt.Logs) != len(want[j].Logs) {
+				t.Fatalf("test %d, receipt %d: log count mismatch: have %d, want %d", i, j, len(receipt.Logs), len(want[j].Logs))
+			}
+			for _, log := range receipt.Logs {
+				if log.BlockHash != tt.block.Hash() || log.TxIndex != uint(j) {
+					t.Errorf("test %d, receipt %d: log position mismatch: have %x/%d", i, j, log.BlockHash, log.TxIndex)
+				}
+			}
+		}
+	}
+	if _, err := client.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithNumber(4)); err != ethereum.NotFound {
+		t.Fatalf("receipts of unknown block: have error %v, want %v", err, ethereum.NotFound)
+	}
+	if _, err := client.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)); err == nil {
+		t.Fatalf("receipts of the pending block returned")
+	}
+}
+
+func TestSubscribeExportBlockRange(t *testing.T) {
+	n, _, chain := newTestBackend(t, 5)
+	defer n.Stop()
+
+	rpcClient, _ := n.Attach()
+	client := NewClient(rpcClient)
+
+	ch := make(chan *ExportedBlock)
+	sub, err := client.SubscribeExportBlockRange(context.Background(), big.NewInt(2), big.NewInt(4), ch)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	for number := 2; ; number++ {
+		var exported *ExportedBlock
+		select {
+		case exported = <-ch:
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for block #%d", number)
+		}
+		if exported.Done {
+			if exported.Err != nil {
+				t.Fatalf("export failed: %v", exported.Err)
+			}
+			if number != 5 {
+				t.Fatalf("export ended before block #%d", number)
+			}
+			break
+		}
+		block := chain[number-1]
+		if exported.Header.Hash() != block.Hash() {
+			t.Fatalf("block #%d: hash mismatch: have %x, want %x", number, exported.Header.Hash(), block.Hash())
+		}
+		if len(exported.Transactions) != len(block.Transactions()) || len(exported.Receipts) != len(block.Transactions()) {
+			t.Fatalf("block #%d: have %d transactions and %d receipts, want %d", number, len(exported.Transactions), len(exported.Receipts), len(block.Transactions()))
+		}
+		for i, tx := range exported.Transactions {
+			if tx.Hash() != block.Transactions()[i].Hash() || exported.Receipts[i].TxHash != tx.Hash() {
+				t.Errorf("block #%d: transaction %d mismatch", number, i)
+			}
+		}
+		if logs := exported.Receipts[1].Logs; len(logs) != 1 || logs[0].BlockNumber != uint64(number) {
+			t.Errorf("block #%d: contract creation logs mismatch: %v", number, logs)
+		}
+	}
+	// Invalid and oversized ranges are rejected
+	for _, r := range [][2]int64{{4, 2}, {1, 6}, {0, 100000}} {
+		if _, err := client.SubscribeExportBlockRange(context.Background(), big.NewInt(r[0]), big.NewInt(r[1]), make(chan *ExportedBlock)); err == nil {
+			t.Errorf("range #%d-#%d: export succeeded", r[0], r[1])
+		}
+	}
+}
+
+func TestBlockNumberOrHashStateLookup(t *testing.T) {
+	n, ethservice, chain := newTestBackend(t, 3)
+	defer n.Stop()
+
+	// Import a shorter fork, which stays a side chain
+	fork := generateTestChain(2, 2)
+	if _, err := ethservice.BlockChain().InsertChain(fork); err != nil {
+		t.Fatalf("can't import fork: %v", err)
+	}
+	if head := ethservice.BlockChain().CurrentBlock().Hash(); head != chain[2].Hash() {
+		t.Fatalf("fork became canonical")
+	}
+	rpcClient, _ := n.Attach()
+
+	// Each chain transfers one wei per block to the address of its seed
+	canonicalAddr, forkAddr := common.Address{1}, common.Address{2}
+	tests := []struct {
+		addr    common.Address
+		arg     rpc.BlockNumberOrHash
+		balance int64
+		fail    bool
+	}{
+		{canonicalAddr, rpc.BlockNumberOrHashWithNumber(2), 2, false},
+		{canonicalAddr, rpc.BlockNumberOrHashWithHash(chain[1].Hash(), false), 2, false},
+		{canonicalAddr, rpc.BlockNumberOrHashWithHash(chain[1].Hash(), true), 2, false},
+		{canonicalAddr, rpc.BlockNumberOrHashWithHash(chain[2].Hash(), true), 3, false},
+		{forkAddr, rpc.BlockNumberOrHashWithHash(fork[1].Hash(), false), 2, false},
+		{canonicalAddr, rpc.BlockNumberOrHashWithHash(fork[1].Hash(), false), 0, false},
+		{forkAddr, rpc.BlockNumberOrHashWithHash(fork[1].Hash(), true), 0, true},
+		{forkAddr, rpc.BlockNumberOrHashWithHash(common.Hash{1}, false), 0, true},
+	}
+	for i, tt := range tests {
+		var balance hexutil.Big
+		err := rpcClient.CallContext(context.Background(), &balance, "eth_getBalance", tt.addr, tt.arg)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: lookup of %v succeeded", i, tt.arg.String())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: lookup of %v failed: %v", i, tt.arg.String(), err)
+			continue
+		}
+		if balance.ToInt().Int64() != tt.balance {
+			t.Errorf("test %d: balance mismatch: have %v, want %d", i, balance.ToInt(), tt.balance)
+		}
+	}
+	// Block based queries honour the canonical requirement too
+	client := NewClient(rpcClient)
+	if _, err := client.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithHash(fork[1].Hash(), false)); err != nil {
+		t.Errorf("receipts of side chain block not returned: %v", err)
+	}
+	if _, err := client.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithHash(fork[1].Hash(), true)); err == nil {
+		t.Errorf("receipts of side chain block returned despite canonical requirement")
+	}
+	// The backend resolves headers by hash the same way
+	backend := ethservice.APIBackend
+	if header, err := backend.HeaderByNumberOrHash(context.Background(), rpc.BlockNumberOrHashWithHash(fork[0].Hash(), false)); err != nil || header.Hash() != fork[0].Hash() {
+		t.Errorf("side chain header lookup: have %v, %v", header, err)
+	}
+	if _, err := backend.HeaderByNumberOrHash(context.Background(), rpc.BlockNumberOrHashWithHash(fork[0].Hash(), true)); err == nil {
+		t.Errorf("side chain header returned despite canonical requirement")
+	}
+}
@@ -148,6 +148,20 @@ type FilterQuery struct {
 	// {{A}, {B}}         matches topic A in first position, B in second position
 	// {{A, B}, {C, D}}   matches topic (A OR B) in first position, (C OR D) in second position
 	Topics [][]common.Hash
+
+	// Limit caps the number of logs returned by a one-off query, zero means no limit.
+	// A query that hit the limit can be continued by repeating it with the Cursor
+	// set to the position of the last log received. Paged queries also return the
+	// cursor to continue from, which is nil once no further logs match.
+	Limit  uint64
+	Cursor *LogCursor
+}
+
+// LogCursor is the position of a log within the chain, used to continue log
+// queries that were cut short by their result limit.
+type LogCursor struct {
+	BlockNumber uint64 // Number of the block containing the log
+	Index       uint   // Index of the log within the block
 }
 
 // LogFilterer provides access to contract log events using a one-off query or continuous
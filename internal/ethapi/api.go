@@ -26,8 +26,14 @@ import (
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/hdwallet"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/accounts/multisig"
+	"github.com/ethereum/go-ethereum/accounts/multisig/contract"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/common/math"
@@ -42,6 +48,7 @@ import (
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/typeddata"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/util"
 )
@@ -118,7 +125,7 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range pending {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = NewRPCPendingTransaction(tx)
 		}
 		content["pending"][account.Hex()] = dump
 	}
@@ -126,7 +133,7 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range queue {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = NewRPCPendingTransaction(tx)
 		}
 		content["queued"][account.Hex()] = dump
 	}
@@ -291,7 +298,11 @@ func (s *PrivateAccountAPI) DeriveAccount(url string, path string, pin *bool) (a
 
 // NewAccount will create a new account and returns the address for the new account.
 func (s *PrivateAccountAPI) NewAccount(password string) (common.Address, error) {
-	acc, err := fetchKeystore(s.am).NewAccount(password)
+	ks, err := fetchKeystore(s.am)
+	if err != nil {
+		return common.Address{}, err
+	}
+	acc, err := ks.NewAccount(password)
 	if err == nil {
 		return acc.Address, nil
 	}
@@ -299,8 +310,12 @@ func (s *PrivateAccountAPI) NewAccount(password string) (common.Address, error)
 }
 
 // fetchKeystore retrives the encrypted keystore from the account manager.
-func fetchKeystore(am *accounts.Manager) *keystore.KeyStore {
-	return am.Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+func fetchKeystore(am *accounts.Manager) (*keystore.KeyStore, error) {
+	backends := am.Backends(keystore.KeyStoreType)
+	if len(backends) == 0 {
+		return nil, errors.New("local keystore not used")
+	}
+	return backends[0].(*keystore.KeyStore), nil
 }
 
 // ImportRawKey stores the given hex encoded ECDSA key into the key directory,
@@ -310,10 +325,36 @@ func (s *PrivateAccountAPI) ImportRawKey(privkey string, password string) (commo
 	if err != nil {
 		return common.Address{}, err
 	}
-	acc, err := fetchKeystore(s.am).ImportECDSA(key, password)
+	ks, err := fetchKeystore(s.am)
+	if err != nil {
+		return common.Address{}, err
+	}
+	acc, err := ks.ImportECDSA(key, password)
 	return acc.Address, err
 }
 
+// fetchHDWallets retrieves the software HD wallet backend used by the account
+// manager.
+func fetchHDWallets(am *accounts.Manager) (*hdwallet.Backend, error) {
+	backends := am.Backends(hdwallet.BackendType)
+	if len(backends) == 0 {
+		return nil, errors.New("HD wallets not supported")
+	}
+	return backends[0].(*hdwallet.Backend), nil
+}
+
+// ImportMnemonic creates a new HD wallet from the given BIP-39 mnemonic and
+// optional BIP-39 passphrase, encrypting its seed with password. It returns the
+// account at the default derivation path, which is pinned to the wallet. The
+// wallet can be opened with the password to derive further accounts.
+func (s *PrivateAccountAPI) ImportMnemonic(mnemonic string, bip39Passphrase string, password string) (accounts.Account, error) {
+	backend, err := fetchHDWallets(s.am)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	return backend.Import(mnemonic, bip39Passphrase, password)
+}
+
 // UnlockAccount will unlock the account associated with the given address with
 // the given password for duration seconds. If duration is nil it will use a
 // default of 300 seconds. It returns an indication if the account was unlocked.
@@ -327,7 +368,11 @@ func (s *PrivateAccountAPI) UnlockAccount(addr common.Address, password string,
 	} else {
 		d = time.Duration(*duration) * time.Second
 	}
-	err := fetchKeystore(s.am).TimedUnlock(accounts.Account{Address: addr}, password, d)
+	ks, err := fetchKeystore(s.am)
+	if err != nil {
+		return false, err
+	}
+	err = ks.TimedUnlock(accounts.Account{Address: addr}, password, d)
 	if err != nil {
 		log.Warn("Failed account unlock attempt", "address", addr, "err", err)
 	}
@@ -336,7 +381,10 @@ func (s *PrivateAccountAPI) UnlockAccount(addr common.Address, password string,
 
 // LockAccount will lock the account associated with the given address when it's unlocked.
 func (s *PrivateAccountAPI) LockAccount(addr common.Address) bool {
-	return fetchKeystore(s.am).Lock(addr) == nil
+	if ks, err := fetchKeystore(s.am); err == nil {
+		return ks.Lock(addr) == nil
+	}
+	return false
 }
 
 // signTransaction sets defaults and signs the given transaction
@@ -480,6 +528,363 @@ func (s *PrivateAccountAPI) SignAndSendTransaction(ctx context.Context, args Sen
 	return s.SendTransaction(ctx, args, passwd)
 }
 
+// fetchMultisig retrieves the multisig wallet backend from the account manager.
+func fetchMultisig(am *accounts.Manager) (*multisig.Backend, error) {
+	backends := am.Backends(multisig.BackendType)
+	if len(backends) == 0 {
+		return nil, errors.New("multisig wallets not supported")
+	}
+	return backends[0].(*multisig.Backend), nil
+}
+
+// contractBackend adapts the API backend to the interfaces needed by contract
+// bindings: calls are executed on top of the latest state and transactions are
+// submitted into the local pool.
+type contractBackend struct {
+	b Backend
+}
+
+// CodeAt implements bind.ContractCaller, returning the code of the given account.
+func (cb *contractBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return NewPublicBlockChainAPI(cb.b).GetCode(ctx, contract, cb.block(blockNumber))
+}
+
+// CallContract implements bind.ContractCaller, executing a call without creating
+// a transaction. Failed executions are reported as errors.
+func (cb *contractBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	output, _, failed, err := NewPublicBlockChainAPI(cb.b).doCall(ctx, toCallArgs(call), cb.block(blockNumber), 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if failed {
+		return nil, errors.New("contract call failed")
+	}
+	return output, nil
+}
+
+// PendingCodeAt implements bind.ContractTransactor, returning the code of the
+// given account in the pending state.
+func (cb *contractBackend) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return NewPublicBlockChainAPI(cb.b).GetCode(ctx, account, rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber))
+}
+
+// PendingNonceAt implements bind.ContractTransactor, returning the next nonce of
+// the account taking the transaction pool into account.
+func (cb *contractBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return cb.b.GetPoolNonce(ctx, account)
+}
+
+// SuggestGasPrice implements bind.ContractTransactor, returning the gas price
+// suggested by the price oracle.
+func (cb *contractBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return cb.b.SuggestPrice(ctx)
+}
+
+// EstimateGas implements bind.ContractTransactor, estimating the gas needed to
+// execute the call.
+func (cb *contractBackend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	gas, err := NewPublicBlockChainAPI(cb.b).EstimateGas(ctx, toCallArgs(call))
+	return uint64(gas), err
+}
+
+// SendTransaction implements bind.ContractTransactor, submitting a signed
+// transaction into the local pool.
+func (cb *contractBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	_, err := submitTransaction(ctx, cb.b, tx)
+	return err
+}
+
+// FilterLogs implements bind.ContractFilterer, but is not supported.
+func (cb *contractBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	return nil, errors.New("log filtering not supported")
+}
+
+// SubscribeFilterLogs implements bind.ContractFilterer, but is not supported.
+func (cb *contractBackend) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return nil, errors.New("log filtering not supported")
+}
+
+// block converts a binding block number into a block selector, nil meaning the
+// latest block.
+func (cb *contractBackend) block(number *big.Int) rpc.BlockNumberOrHash {
+	if number == nil {
+		return rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	}
+	return rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(number.Int64()))
+}
+
+// toCallArgs converts a binding call message into the arguments of a call.
+func toCallArgs(call ethereum.CallMsg) CallArgs {
+	args := CallArgs{From: call.From, To: call.To, Gas: hexutil.Uint64(call.Gas), Data: call.Data}
+	if call.GasPrice != nil {
+		args.GasPrice = hexutil.Big(*call.GasPrice)
+	}
+	if call.Value != nil {
+		args.Value = hexutil.Big(*call.Value)
+	}
+	return args
+}
+
+// multisigChainID returns the chain id multisig wallets are deployed for and
+// their calls are approved on.
+func (s *PrivateAccountAPI) multisigChainID() *big.Int {
+	if id := s.b.ChainConfig().ChainID; id != nil {
+		return id
+	}
+	return new(big.Int)
+}
+
+// multisigWallet binds the multisig wallet contract at the given address.
+func (s *PrivateAccountAPI) multisigWallet(address common.Address) (*contract.MultiSig, error) {
+	return contract.NewMultiSig(address, &contractBackend{s.b})
+}
+
+// multisigTransactor assembles the options for sending a transaction through the
+// multisig bindings from args.From, signed with the given passphrase. The gas,
+// gas price, nonce and value in args are used if set.
+func (s *PrivateAccountAPI) multisigTransactor(ctx context.Context, args SendTxArgs, passwd string) (*bind.TransactOpts, error) {
+	account := accounts.Account{Address: args.From}
+	wallet, err := s.am.Find(account)
+	if err != nil {
+		return nil, err
+	}
+	var chainID *big.Int
+	if config := s.b.ChainConfig(); config.IsEIP155(s.b.CurrentBlock().Number()) {
+		chainID = config.ChainID
+	}
+	opts := &bind.TransactOpts{
+		From: args.From,
+		Signer: func(signer types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			return wallet.SignTxWithPassphrase(account, passwd, tx, chainID)
+		},
+		Context: ctx,
+	}
+	if args.Nonce != nil {
+		opts.Nonce = new(big.Int).SetUint64(uint64(*args.Nonce))
+	}
+	if args.Gas != nil {
+		opts.GasLimit = uint64(*args.Gas)
+	}
+	if args.GasPrice != nil {
+		opts.GasPrice = args.GasPrice.ToInt()
+	}
+	if args.Value != nil {
+		opts.Value = args.Value.ToInt()
+	}
+	return opts, nil
+}
+
+// DeployMultisig creates a multisig wallet contract owned by the given accounts,
+// threshold of which need to approve any call made by the wallet. The contract
+// is deployed from args.From and tracked as a wallet once the deployment is
+// submitted; the hash of the deployment transaction is returned.
+func (s *PrivateAccountAPI) DeployMultisig(ctx context.Context, args SendTxArgs, owners []common.Address, threshold hexutil.Uint64, passwd string) (common.Hash, error) {
+	backend, err := fetchMultisig(s.am)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if threshold == 0 || int(threshold) > len(owners) {
+		return common.Hash{}, fmt.Errorf("invalid threshold %d for %d owners", threshold, len(owners))
+	}
+	opts, err := s.multisigTransactor(ctx, args, passwd)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if args.Nonce == nil {
+		s.nonceLock.LockAddr(args.From)
+		defer s.nonceLock.UnlockAddr(args.From)
+	}
+	address, tx, _, err := contract.DeployMultiSig(opts, &contractBackend{s.b}, owners, new(big.Int).SetUint64(uint64(threshold)), s.multisigChainID())
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), backend.Track(address)
+}
+
+// TrackMultisig starts tracking an existing multisig wallet contract deployed
+// for the current chain.
+func (s *PrivateAccountAPI) TrackMultisig(ctx context.Context, wallet common.Address) error {
+	backend, err := fetchMultisig(s.am)
+	if err != nil {
+		return err
+	}
+	msig, err := s.multisigWallet(wallet)
+	if err != nil {
+		return err
+	}
+	chainID, err := msig.ChainId(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return fmt.Errorf("no multisig wallet at %x: %v", wallet, err)
+	}
+	if chainID.Cmp(s.multisigChainID()) != 0 {
+		return fmt.Errorf("multisig wallet %x deployed for chain %v, not %v", wallet, chainID, s.multisigChainID())
+	}
+	return backend.Track(wallet)
+}
+
+// UntrackMultisig stops tracking a multisig wallet, discarding all its pending
+// signing sessions.
+func (s *PrivateAccountAPI) UntrackMultisig(wallet common.Address) error {
+	backend, err := fetchMultisig(s.am)
+	if err != nil {
+		return err
+	}
+	return backend.Untrack(wallet)
+}
+
+// ProposeMultisigTx opens a signing session for the multisig wallet to call the
+// destination address with the given value and data. The returned session hash
+// needs to be approved by threshold owners before the call can be executed.
+func (s *PrivateAccountAPI) ProposeMultisigTx(ctx context.Context, wallet common.Address, to common.Address, value *hexutil.Big, data *hexutil.Bytes) (*multisig.Session, error) {
+	backend, err := fetchMultisig(s.am)
+	if err != nil {
+		return nil, err
+	}
+	msig, err := s.multisigWallet(wallet)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := msig.Nonce(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return nil, fmt.Errorf("no multisig wallet at %x: %v", wallet, err)
+	}
+	var input []byte
+	if data != nil {
+		input = *data
+	}
+	return backend.Propose(s.multisigChainID(), wallet, to, value.ToInt(), input, nonce.Uint64())
+}
+
+// ApproveMultisigTx approves a signing session on behalf of one of the owners of
+// the multisig wallet. Accounts able to sign hashes (e.g. keystore accounts)
+// approve off-chain, all others (e.g. hardware wallets) by sending an approve
+// transaction to the wallet contract, which needs to be mined before the call
+// can be executed.
+func (s *PrivateAccountAPI) ApproveMultisigTx(ctx context.Context, hash common.Hash, owner common.Address, passwd string) (*multisig.Session, error) {
+	backend, err := fetchMultisig(s.am)
+	if err != nil {
+		return nil, err
+	}
+	session, err := backend.Session(hash)
+	if err != nil {
+		return nil, err
+	}
+	msig, err := s.multisigWallet(session.Wallet)
+	if err != nil {
+		return nil, err
+	}
+	isOwner, err := msig.IsOwner(&bind.CallOpts{Context: ctx}, owner)
+	if err != nil {
+		return nil, err
+	}
+	if !isOwner {
+		return nil, fmt.Errorf("%x is not an owner of multisig wallet %x", owner, session.Wallet)
+	}
+	account := accounts.Account{Address: owner}
+	wallet, err := s.am.Find(account)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := wallet.SignHashWithPassphrase(account, passwd, hash[:])
+	switch {
+	case err == accounts.ErrNotSupported:
+		opts, err := s.multisigTransactor(ctx, SendTxArgs{From: owner}, passwd)
+		if err != nil {
+			return nil, err
+		}
+		s.nonceLock.LockAddr(owner)
+		defer s.nonceLock.UnlockAddr(owner)
+
+		if _, err := msig.Approve(opts, hash); err != nil {
+			return nil, err
+		}
+		return backend.ApproveOnChain(hash, owner)
+
+	case err != nil:
+		return nil, err
+	}
+	sig[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	return backend.Approve(hash, owner, sig)
+}
+
+// ExecuteMultisigTx submits a sufficiently approved signing session to its
+// multisig wallet for execution. The transaction is sent from args.From, which
+// does not need to be an owner. The session is kept until the transaction was
+// successfully submitted, so that a failed attempt can be retried.
+func (s *PrivateAccountAPI) ExecuteMultisigTx(ctx context.Context, args SendTxArgs, hash common.Hash, passwd string) (common.Hash, error) {
+	backend, err := fetchMultisig(s.am)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	session, err := backend.Session(hash)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if session.ChainID.ToInt().Cmp(s.multisigChainID()) != 0 {
+		return common.Hash{}, fmt.Errorf("session for chain %v, not %v", session.ChainID, s.multisigChainID())
+	}
+	msig, err := s.multisigWallet(session.Wallet)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	call := &bind.CallOpts{Context: ctx}
+
+	nonce, err := msig.Nonce(call)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if nonce.Uint64() != uint64(session.Nonce) {
+		return common.Hash{}, fmt.Errorf("stale session: nonce %d, wallet at %d", session.Nonce, nonce)
+	}
+	threshold, err := msig.Threshold(call)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	owners, err := msig.GetOwners(call)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	sigs, err := session.Signatures(owners, int(threshold.Int64()))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	opts, err := s.multisigTransactor(ctx, args, passwd)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if args.Nonce == nil {
+		s.nonceLock.LockAddr(args.From)
+		defer s.nonceLock.UnlockAddr(args.From)
+	}
+	tx, err := msig.Execute(opts, session.To, session.Value.ToInt(), session.Data, sigs)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	// The call was submitted, the session is of no further use
+	if err := backend.Discard(hash); err != nil {
+		log.Warn("Failed to discard executed multisig session", "hash", hash, "err", err)
+	}
+	return tx.Hash(), nil
+}
+
+// MultisigSessions returns all pending multisig signing sessions.
+func (s *PrivateAccountAPI) MultisigSessions() ([]*multisig.Session, error) {
+	backend, err := fetchMultisig(s.am)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Sessions(), nil
+}
+
+// DiscardMultisigTx drops a pending multisig signing session.
+func (s *PrivateAccountAPI) DiscardMultisigTx(hash common.Hash) error {
+	backend, err := fetchMultisig(s.am)
+	if err != nil {
+		return err
+	}
+	return backend.Discard(hash)
+}
+
 // PublicBlockChainAPI provides an API to access the Ethereum blockchain.
 // It offers only methods that operate on public data that is freely available to anyone.
 type PublicBlockChainAPI struct {
@@ -498,10 +903,10 @@ func (s *PublicBlockChainAPI) BlockNumber() hexutil.Uint64 {
 }
 
 // GetBalance returns the amount of wei for the given address in the state of the
-// given block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
-// block numbers are also allowed.
-func (s *PublicBlockChainAPI) GetBalance(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*hexutil.Big, error) {
-	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+// given block number or hash. The rpc.LatestBlockNumber and rpc.PendingBlockNumber
+// meta block numbers are also allowed.
+func (s *PublicBlockChainAPI) GetBalance(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
 	}
@@ -525,8 +930,8 @@ type StorageResult struct {
 }
 
 // GetProof returns the Merkle-proof for a given account and optionally some storage keys.
-func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNr rpc.BlockNumber) (*AccountResult, error) {
-	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNrOrHash rpc.BlockNumberOrHash) (*AccountResult, error) {
+	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
 	}
@@ -601,6 +1006,33 @@ func (s *PublicBlockChainAPI) GetBlockByHash(ctx context.Context, blockHash comm
 	return nil, err
 }
 
+// GetBlockReceipts returns the receipts of all transactions in the block with
+// the given number or hash, in the order of the transactions within the block.
+func (s *PublicBlockChainAPI) GetBlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	if number, ok := blockNrOrHash.Number(); ok && number == rpc.PendingBlockNumber {
+		return nil, errors.New("receipts of the pending block are not available")
+	}
+	block, err := s.b.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if block == nil || err != nil {
+		return nil, err
+	}
+	receipts, err := s.b.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	txs := block.Transactions()
+	if len(txs) != len(receipts) {
+		return nil, fmt.Errorf("receipts length mismatch: %d vs %d", len(txs), len(receipts))
+	}
+	signer := types.MakeSigner(s.b.ChainConfig(), block.Number())
+
+	result := make([]map[string]interface{}, len(receipts))
+	for i, receipt := range receipts {
+		result[i] = RPCMarshalReceipt(receipt, block.Hash(), block.NumberU64(), signer, txs[i], uint64(i))
+	}
+	return result, nil
+}
+
 // GetUncleByBlockNumberAndIndex returns the uncle block for the given block hash and index. When fullTx is true
 // all transactions in the block are returned in full detail, otherwise only the transaction hash is returned.
 func (s *PublicBlockChainAPI) GetUncleByBlockNumberAndIndex(ctx context.Context, blockNr rpc.BlockNumber, index hexutil.Uint) (map[string]interface{}, error) {
@@ -651,9 +1083,9 @@ func (s *PublicBlockChainAPI) GetUncleCountByBlockHash(ctx context.Context, bloc
 	return nil
 }
 
-// GetCode returns the code stored at the given address in the state for the given block number.
-func (s *PublicBlockChainAPI) GetCode(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+// GetCode returns the code stored at the given address in the state for the given block number or hash.
+func (s *PublicBlockChainAPI) GetCode(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
 	}
@@ -662,10 +1094,10 @@ func (s *PublicBlockChainAPI) GetCode(ctx context.Context, address common.Addres
 }
 
 // GetStorageAt returns the storage from the state at the given address, key and
-// block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta block
-// numbers are also allowed.
-func (s *PublicBlockChainAPI) GetStorageAt(ctx context.Context, address common.Address, key string, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+// block number or hash. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
+// block numbers are also allowed.
+func (s *PublicBlockChainAPI) GetStorageAt(ctx context.Context, address common.Address, key string, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
 	}
@@ -683,10 +1115,10 @@ type CallArgs struct {
 	Data     hexutil.Bytes   `json:"data"`
 }
 
-func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, timeout time.Duration) ([]byte, uint64, bool, error) {
+func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, timeout time.Duration) ([]byte, uint64, bool, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
-	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	state, header, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, 0, false, err
 	}
@@ -738,17 +1170,53 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	// Setup the gas pool (also for unmetered requests)
 	// and apply the message.
 	gp := new(core.GasPool).AddGas(math.MaxUint64)
-	res, gas, failed, err := core.ApplyMessage(evm, msg, gp)
+	st := core.NewStateTransition(evm, msg, gp)
+	res, gas, failed, err := st.TransitionDb()
 	if err := vmError(); err != nil {
 		return nil, 0, false, err
 	}
+	// If the call was reverted, surface the revert data to the caller
+	if err == nil && st.VMError() == vm.ErrExecutionReverted {
+		err = newRevertError(res)
+	}
 	return res, gas, failed, err
 }
 
-// Call executes the given transaction on the state for the given block number.
+// revertError is an API error that encompasses an EVM revert with JSON error
+// code and a binary data blob.
+type revertError struct {
+	error
+	reason string // revert data, hex encoded
+}
+
+// newRevertError creates a revertError from the data returned by a reverted
+// call, decoding the solidity revert reason if there is one.
+func newRevertError(data []byte) *revertError {
+	err := errors.New("execution reverted")
+	if reason, errUnpack := abi.UnpackRevert(data); errUnpack == nil {
+		err = fmt.Errorf("execution reverted: %v", reason)
+	}
+	return &revertError{
+		error:  err,
+		reason: hexutil.Encode(data),
+	}
+}
+
+// ErrorCode returns the JSON error code for a revert.
+// See: https://github.com/ethereum/wiki/wiki/JSON-RPC-Error-Codes-Improvement-Proposal
+func (e *revertError) ErrorCode() int {
+	return 3
+}
+
+// ErrorData returns the hex encoded revert data.
+func (e *revertError) ErrorData() interface{} {
+	return e.reason
+}
+
+// Call executes the given transaction on the state for the given block number or hash.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
-func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, _, err := s.doCall(ctx, args, blockNr, 5*time.Second)
+func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	result, _, _, err := s.doCall(ctx, args, blockNrOrHash, 5*time.Second)
 	return (hexutil.Bytes)(result), err
 }
 
@@ -774,19 +1242,19 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	cap = hi
 
 	// Create a helper to check if a gas allowance results in an executable transaction
-	executable := func(gas uint64) bool {
+	executable := func(gas uint64) (bool, error) {
 		args.Gas = hexutil.Uint64(gas)
 
-		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, 0)
+		_, _, failed, err := s.doCall(ctx, args, rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber), 0)
 		if err != nil || failed {
-			return false
+			return false, err
 		}
-		return true
+		return true, nil
 	}
 	// Execute the binary search and hone in on an executable gas limit
 	for lo+1 < hi {
 		mid := (hi + lo) / 2
-		if !executable(mid) {
+		if ok, _ := executable(mid); !ok {
 			lo = mid
 		} else {
 			hi = mid
@@ -794,7 +1262,10 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	}
 	// Reject the transaction as invalid if it still fails at the highest allowance
 	if hi == cap {
-		if !executable(hi) {
+		if ok, err := executable(hi); !ok {
+			if revert, isRevert := err.(*revertError); isRevert {
+				return 0, revert
+			}
 			return 0, fmt.Errorf("gas required exceeds allowance or always failing transaction")
 		}
 	}
@@ -977,8 +1448,8 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 	return result
 }
 
-// newRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
-func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
+// NewRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
+func NewRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
 	return newRPCTransaction(tx, common.Hash{}, 0, 0)
 }
 
@@ -1072,18 +1543,18 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByBlockHashAndIndex(ctx cont
 	return nil
 }
 
-// GetTransactionCount returns the number of transactions the given address has sent for the given block number
-func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*hexutil.Uint64, error) {
+// GetTransactionCount returns the number of transactions the given address has sent for the given block number or hash
+func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Uint64, error) {
 	// Ask transaction pool for the nonce which includes pending transactions
-	if blockNr == rpc.PendingBlockNumber {
+	if blockNr, ok := blockNrOrHash.Number(); ok && blockNr == rpc.PendingBlockNumber {
 		nonce, err := s.b.GetPoolNonce(ctx, address)
 		if err != nil {
 			return nil, err
 		}
 		return (*hexutil.Uint64)(&nonce), nil
 	}
-	// Resolve block number and use its state to ask for the nonce
-	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	// Resolve block number or hash and use its state to ask for the nonce
+	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
 	}
@@ -1099,7 +1570,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, has
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx)
+		return NewRPCPendingTransaction(tx)
 	}
 	// Transaction unknown, return as such
 	return nil
@@ -1139,12 +1610,18 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if tx.Protected() {
 		signer = types.NewEIP155Signer(tx.ChainId())
 	}
+	return RPCMarshalReceipt(receipt, blockHash, blockNumber, signer, tx, index), nil
+}
+
+// RPCMarshalReceipt converts the given receipt to the RPC output, which includes
+// the positional and sender information of the transaction it belongs to.
+func RPCMarshalReceipt(receipt *types.Receipt, blockHash common.Hash, blockNumber uint64, signer types.Signer, tx *types.Transaction, index uint64) map[string]interface{} {
 	from, _ := types.Sender(signer, tx)
 
 	fields := map[string]interface{}{
 		"blockHash":         blockHash,
 		"blockNumber":       hexutil.Uint64(blockNumber),
-		"transactionHash":   hash,
+		"transactionHash":   tx.Hash(),
 		"transactionIndex":  hexutil.Uint64(index),
 		"from":              from,
 		"to":                tx.To(),
@@ -1168,7 +1645,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
-	return fields, nil
+	return fields
 }
 
 // sign is a helper function that signs a transaction with the private key of the given address.
@@ -1347,6 +1824,33 @@ func (s *PublicTransactionPoolAPI) Sign(addr common.Address, data hexutil.Bytes)
 	return signature, err
 }
 
+// SignTypedData calculates an ECDSA signature for the EIP-712 typed data:
+// keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message)).
+//
+// Note, the produced signature conforms to the secp256k1 curve R, S and V values,
+// where the V value will be 27 or 28 for legacy reasons.
+//
+// The account associated with addr must be unlocked.
+func (s *PublicTransactionPoolAPI) SignTypedData(addr common.Address, typedData typeddata.TypedData) (hexutil.Bytes, error) {
+	sighash, err := typedData.SignatureHash()
+	if err != nil {
+		return nil, err
+	}
+	// Look up the wallet containing the requested signer
+	account := accounts.Account{Address: addr}
+
+	wallet, err := s.b.AccountManager().Find(account)
+	if err != nil {
+		return nil, err
+	}
+	// Sign the requested hash with the wallet
+	signature, err := wallet.SignHash(account, sighash)
+	if err == nil {
+		signature[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	}
+	return signature, err
+}
+
 // SignTransactionResult represents a RLP encoded signed transaction.
 type SignTransactionResult struct {
 	Raw hexutil.Bytes      `json:"raw"`
@@ -1401,7 +1905,7 @@ func (s *PublicTransactionPoolAPI) PendingTransactions() ([]*RPCTransaction, err
 		}
 		from, _ := types.Sender(signer, tx)
 		if _, exists := accounts[from]; exists {
-			transactions = append(transactions, newRPCPendingTransaction(tx))
+			transactions = append(transactions, NewRPCPendingTransaction(tx))
 		}
 	}
 	return transactions, nil
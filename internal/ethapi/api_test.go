@@ -0,0 +1,244 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/accounts/multisig"
+	"github.com/ethereum/go-ethereum/accounts/multisig/contract"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const testPassphrase = "secret"
+
+var (
+	sealerKey, _ = crypto.GenerateKey()
+	ownerKeys    = make([]*ecdsa.PrivateKey, 3)
+	owners       = make([]common.Address, 3)
+
+	recipient = common.HexToAddress("0x0102030405060708090a0b0c0d0e0f1011121314")
+)
+
+func init() {
+	for i := range ownerKeys {
+		ownerKeys[i], _ = crypto.GenerateKey()
+		owners[i] = crypto.PubkeyToAddress(ownerKeys[i].PublicKey)
+	}
+}
+
+// newTestNode creates a node sealing a block for every transaction, with the
+// multisig owners imported into its keystore and funded in the genesis.
+func newTestNode(t *testing.T) (*node.Node, *eth.Ethereum) {
+	n, err := node.New(&node.Config{UseLightweightKDF: true, P2P: p2p.Config{NoDiscovery: true, NoDial: true}})
+	if err != nil {
+		t.Fatalf("can't create new node: %v", err)
+	}
+	ks := n.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	sealer, err := ks.ImportECDSA(sealerKey, testPassphrase)
+	if err != nil {
+		t.Fatalf("can't import sealer key: %v", err)
+	}
+	if err := ks.Unlock(sealer, testPassphrase); err != nil {
+		t.Fatalf("can't unlock sealer: %v", err)
+	}
+	genesis := core.DeveloperGenesisBlock(0, sealer.Address)
+	for i, key := range ownerKeys {
+		if _, err := ks.ImportECDSA(key, testPassphrase); err != nil {
+			t.Fatalf("can't import owner key %d: %v", i, err)
+		}
+		genesis.Alloc[owners[i]] = core.GenesisAccount{Balance: big.NewInt(1000000000000000000)}
+	}
+	config := eth.DefaultConfig
+	config.Genesis = genesis
+	config.Etherbase = sealer.Address
+
+	if err := n.Register(func(ctx *node.ServiceContext) (node.Service, error) { return eth.New(ctx, &config) }); err != nil {
+		t.Fatalf("can't register eth service: %v", err)
+	}
+	if err := n.Start(); err != nil {
+		t.Fatalf("can't start test node: %v", err)
+	}
+	var ethservice *eth.Ethereum
+	if err := n.Service(&ethservice); err != nil {
+		t.Fatalf("can't retrieve eth service: %v", err)
+	}
+	if err := ethservice.StartMining(1); err != nil {
+		t.Fatalf("can't start sealing: %v", err)
+	}
+	return n, ethservice
+}
+
+// waitMined waits for the transaction to be included and checks that it did not
+// fail.
+func waitMined(t *testing.T, client *ethclient.Client, hash common.Hash) *types.Receipt {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for {
+		receipt, err := client.TransactionReceipt(ctx, hash)
+		if receipt != nil {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				t.Fatalf("transaction %x failed", hash)
+			}
+			return receipt
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("transaction %x not mined: %v", hash, err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// sessions retrieves the pending multisig sessions of the node.
+func sessions(t *testing.T, client *rpc.Client) []*multisig.Session {
+	var sessions []*multisig.Session
+	if err := client.Call(&sessions, "personal_multisigSessions"); err != nil {
+		t.Fatalf("failed to retrieve sessions: %v", err)
+	}
+	return sessions
+}
+
+// Tests the lifecycle of a multisig wallet through the personal RPC API: deploying
+// and funding it, proposing a call, approving it by a threshold of owners and
+// executing it.
+func TestMultisigAPI(t *testing.T) {
+	n, ethservice := newTestNode(t)
+	defer n.Stop()
+
+	client, err := n.Attach()
+	if err != nil {
+		t.Fatalf("can't attach to node: %v", err)
+	}
+	defer client.Close()
+	ec := ethclient.NewClient(client)
+
+	// Deploy a 2-of-3 wallet and fund it
+	var hash common.Hash
+	if err := client.Call(&hash, "personal_deployMultisig", map[string]interface{}{"from": owners[0]}, owners, hexutil.Uint64(2), testPassphrase); err != nil {
+		t.Fatalf("failed to deploy wallet: %v", err)
+	}
+	wallet := waitMined(t, ec, hash).ContractAddress
+
+	if err := client.Call(&hash, "personal_sendTransaction", map[string]interface{}{"from": owners[0], "to": wallet, "value": (*hexutil.Big)(big.NewInt(1000000))}, testPassphrase); err != nil {
+		t.Fatalf("failed to fund wallet: %v", err)
+	}
+	waitMined(t, ec, hash)
+
+	// The wallet must be tracked, but not listed as an account
+	var wallets []struct{ URL string }
+	if err := client.Call(&wallets, "personal_listWallets"); err != nil {
+		t.Fatalf("failed to list wallets: %v", err)
+	}
+	url := (&accounts.URL{Scheme: multisig.Scheme, Path: hexutil.Encode(wallet[:])}).String()
+	tracked := false
+	for _, w := range wallets {
+		tracked = tracked || w.URL == url
+	}
+	if !tracked {
+		t.Errorf("deployed wallet %s not tracked: %v", url, wallets)
+	}
+	var accs []common.Address
+	if err := client.Call(&accs, "eth_accounts"); err != nil {
+		t.Fatalf("failed to list accounts: %v", err)
+	}
+	for _, acc := range accs {
+		if acc == wallet {
+			t.Errorf("multisig wallet listed in eth_accounts")
+		}
+	}
+	// Propose a transfer and approve it by two owners
+	var session *multisig.Session
+	if err := client.Call(&session, "personal_proposeMultisigTx", wallet, recipient, (*hexutil.Big)(big.NewInt(1000)), nil); err != nil {
+		t.Fatalf("failed to propose call: %v", err)
+	}
+	if chainID := ethservice.BlockChain().Config().ChainID; session.ChainID.ToInt().Cmp(chainID) != 0 {
+		t.Errorf("session chain id mismatch: have %v, want %v", session.ChainID, chainID)
+	}
+	if err := client.Call(nil, "personal_approveMultisigTx", session.Hash, recipient, testPassphrase); err == nil {
+		t.Errorf("approval by non-owner accepted")
+	}
+	for _, owner := range owners[1:] {
+		if err := client.Call(&session, "personal_approveMultisigTx", session.Hash, owner, testPassphrase); err != nil {
+			t.Fatalf("failed to approve session: %v", err)
+		}
+	}
+	if len(session.Approvals) != 2 {
+		t.Fatalf("approval count mismatch: have %d, want 2", len(session.Approvals))
+	}
+	// A failed execution must retain the session for a retry
+	if err := client.Call(&hash, "personal_executeMultisigTx", map[string]interface{}{"from": owners[0]}, session.Hash, "wrong"); err == nil {
+		t.Fatalf("execution with invalid passphrase succeeded")
+	}
+	if pending := sessions(t, client); len(pending) != 1 || pending[0].Hash != session.Hash {
+		t.Fatalf("session dropped by failed execution: %v", pending)
+	}
+	if err := client.Call(&hash, "personal_executeMultisigTx", map[string]interface{}{"from": owners[0]}, session.Hash, testPassphrase); err != nil {
+		t.Fatalf("failed to execute session: %v", err)
+	}
+	waitMined(t, ec, hash)
+
+	if balance, _ := ec.BalanceAt(context.Background(), recipient, nil); balance.Int64() != 1000 {
+		t.Errorf("recipient balance mismatch: have %v, want 1000", balance)
+	}
+	if pending := sessions(t, client); len(pending) != 0 {
+		t.Errorf("executed session retained: %v", pending)
+	}
+}
+
+// Tests that wallets deployed for a different chain are not tracked.
+func TestMultisigAPIForeignChain(t *testing.T) {
+	n, _ := newTestNode(t)
+	defer n.Stop()
+
+	client, err := n.Attach()
+	if err != nil {
+		t.Fatalf("can't attach to node: %v", err)
+	}
+	defer client.Close()
+	ec := ethclient.NewClient(client)
+
+	wallet, tx, _, err := contract.DeployMultiSig(bind.NewKeyedTransactor(ownerKeys[0]), ec, owners, big.NewInt(2), big.NewInt(1))
+	if err != nil {
+		t.Fatalf("failed to deploy wallet: %v", err)
+	}
+	waitMined(t, ec, tx.Hash())
+
+	if err := client.Call(nil, "personal_trackMultisig", wallet); err == nil {
+		t.Errorf("wallet of foreign chain tracked")
+	}
+	if err := client.Call(nil, "personal_trackMultisig", recipient); err == nil {
+		t.Errorf("non-contract account tracked")
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+var errNotStarted = errors.New("light server not started")
+
+// PrivateLightServerAPI provides an API to manage the priority clients of a
+// light server.
+type PrivateLightServerAPI struct {
+	server *LesServer
+}
+
+// NewPrivateLightServerAPI creates a new API for managing light server clients.
+func NewPrivateLightServerAPI(server *LesServer) *PrivateLightServerAPI {
+	return &PrivateLightServerAPI{server: server}
+}
+
+// pool returns the client pool of the server, which only exists after the
+// server has been started.
+func (api *PrivateLightServerAPI) pool() (*priorityClientPool, error) {
+	if pool := api.server.protocolManager.clientPool; pool != nil {
+		return pool, nil
+	}
+	return nil, errNotStarted
+}
+
+// AddBalance adds the given amount (which may be negative) to the token balance
+// of a client and returns the balance before and after the operation. Connected
+// priority clients spend one token per second for each unit of their capacity.
+func (api *PrivateLightServerAPI) AddBalance(id enode.ID, value int64) ([2]uint64, error) {
+	pool, err := api.pool()
+	if err != nil {
+		return [2]uint64{}, err
+	}
+	old, balance, err := pool.addBalance(id, value)
+	return [2]uint64{old, balance}, err
+}
+
+// SetClientCapacity assigns a guaranteed capacity to a client, measured in
+// the units of the flow control minimum recharge rate. Clients are served with
+// their assigned capacity as long as their balance is positive. Zero capacity
+// turns the client into a free client.
+func (api *PrivateLightServerAPI) SetClientCapacity(id enode.ID, capacity uint64) error {
+	pool, err := api.pool()
+	if err != nil {
+		return err
+	}
+	return pool.setCapacity(id, capacity)
+}
+
+// ClientInfo returns information about the given clients, or about all
+// connected clients and clients known by the priority pool if no IDs are given.
+func (api *PrivateLightServerAPI) ClientInfo(ids []enode.ID) (map[enode.ID]map[string]interface{}, error) {
+	pool, err := api.pool()
+	if err != nil {
+		return nil, err
+	}
+	connected := make(map[enode.ID]*peer)
+	for _, p := range api.server.protocolManager.peers.AllPeers() {
+		connected[p.ID()] = p
+	}
+	if len(ids) == 0 {
+		ids = pool.knownClients()
+		for id := range connected {
+			ids = append(ids, id)
+		}
+	}
+	res := make(map[enode.ID]map[string]interface{})
+	for _, id := range ids {
+		capacity, balance, priority := pool.clientInfo(id)
+		info := map[string]interface{}{
+			"isConnected": connected[id] != nil,
+			"isPriority":  priority,
+			"capacity":    capacity,
+			"balance":     balance,
+		}
+		if p := connected[id]; p != nil && p.fcClient != nil {
+			info["servedCapacity"] = p.fcClient.Params().MinRecharge
+		}
+		res[id] = info
+	}
+	return res, nil
+}
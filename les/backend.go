@@ -40,7 +40,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
-	"github.com/ethereum/go-ethereum/p2p/discv5"
+	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/params"
 	rpc "github.com/ethereum/go-ethereum/rpc"
 )
@@ -51,6 +51,7 @@ type LightEthereum struct {
 	odr         *LesOdr
 	relay       *LesTxRelay
 	chainConfig *params.ChainConfig
+	ulc         *ulc // nil if not in ultra-light client mode
 	// Channel for shutting down the service
 	shutdownChan chan bool
 
@@ -88,6 +89,13 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 	}
 	log.Info("Initialised chain configuration", "config", chainConfig)
 
+	var ulc *ulc
+	if config.ULC != nil {
+		if ulc, err = newULC(config.ULC); err != nil {
+			return nil, fmt.Errorf("invalid ultra-light client config: %v", err)
+		}
+		log.Info("Ultra-light client mode enabled", "servers", len(ulc.nodes), "minTrusted", ulc.minTrusted)
+	}
 	peers := newPeerSet()
 	quitSync := make(chan struct{})
 
@@ -98,6 +106,7 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 			iConfig: light.DefaultClientIndexerConfig,
 		},
 		chainConfig:    chainConfig,
+		ulc:            ulc,
 		eventMux:       ctx.EventMux,
 		peers:          peers,
 		reqDist:        newRequestDistributor(peers, quitSync),
@@ -125,8 +134,12 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 	}
 	// Note: AddChildIndexer starts the update process for the child
 	leth.bloomIndexer.AddChildIndexer(leth.bloomTrieIndexer)
-	leth.chtIndexer.Start(leth.blockchain)
-	leth.bloomIndexer.Start(leth.blockchain)
+	if ulc == nil {
+		// Ultra-light clients do not have the header chain needed for indexing,
+		// they rely on the sections of the trusted checkpoint only
+		leth.chtIndexer.Start(leth.blockchain)
+		leth.bloomIndexer.Start(leth.blockchain)
+	}
 
 	// Rewind the chain in case of an incompatible config upgrade.
 	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
@@ -136,7 +149,7 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 	}
 
 	leth.txPool = light.NewTxPool(leth.chainConfig, leth.blockchain, leth.relay)
-	if leth.protocolManager, err = NewProtocolManager(leth.chainConfig, light.DefaultClientIndexerConfig, true, config.NetworkId, leth.eventMux, leth.engine, leth.peers, leth.blockchain, nil, chainDb, leth.odr, leth.relay, leth.serverPool, quitSync, &leth.wg); err != nil {
+	if leth.protocolManager, err = NewProtocolManager(leth.chainConfig, light.DefaultClientIndexerConfig, true, config.NetworkId, leth.eventMux, leth.engine, leth.peers, leth.blockchain, nil, chainDb, leth.odr, leth.relay, leth.serverPool, ulc, quitSync, &leth.wg); err != nil {
 		return nil, err
 	}
 	leth.ApiBackend = &LesApiBackend{leth, nil}
@@ -148,7 +161,7 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 	return leth, nil
 }
 
-func lesTopic(genesisHash common.Hash, protocolVersion uint) discv5.Topic {
+func lesTopic(genesisHash common.Hash, protocolVersion uint) discover.Topic {
 	var name string
 	switch protocolVersion {
 	case lpv1:
@@ -158,7 +171,7 @@ func lesTopic(genesisHash common.Hash, protocolVersion uint) discv5.Topic {
 	default:
 		panic(nil)
 	}
-	return discv5.Topic(name + "@" + common.Bytes2Hex(genesisHash.Bytes()[0:8]))
+	return discover.Topic(name + "@" + common.Bytes2Hex(genesisHash.Bytes()[0:8]))
 }
 
 type LightDummyAPI struct{}
@@ -237,6 +250,12 @@ func (s *LightEthereum) Start(srvr *p2p.Server) error {
 	// clients are searching for the first advertised protocol in the list
 	protocolVersion := AdvertiseProtocolVersions[0]
 	s.serverPool.start(srvr, lesTopic(s.blockchain.Genesis().Hash(), protocolVersion))
+	if s.ulc != nil {
+		// Keep connected to the trusted servers of the ultra-light client
+		for _, n := range s.ulc.nodes {
+			srvr.AddPeer(n)
+		}
+	}
 	s.protocolManager.Start(s.config.LightPeers)
 	return nil
 }
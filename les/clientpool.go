@@ -0,0 +1,475 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"errors"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const balanceDecayTC = 7 * 24 * time.Hour // time constant of the exponential decay of client balances
+
+var (
+	errPoolClosed       = errors.New("client pool closed")
+	errCapacityTooLow   = errors.New("priority capacity must exceed free client capacity")
+	errCapacityExceeded = errors.New("total priority capacity exceeds server capacity")
+	errNegativeBalance  = errors.New("balance cannot be negative")
+)
+
+// priorityClientPool manages clients that have been assigned a guaranteed capacity
+// through the server API, and passes every other client on to a freeClientPool.
+//
+// Capacity is measured in the units of the flow control minimum recharge rate,
+// free clients receive freeCapacity. Priority clients are identified by their node
+// ID and pay for their capacity with a token balance: one token pays for one unit
+// of capacity for one second. Balances also decay exponentially with balanceDecayTC
+// while the server is running, connected or not. A client is served as a priority
+// client as long as its balance is positive; once it runs out the client is
+// disconnected and may reconnect as a free client.
+//
+// The sum of assigned priority capacities never exceeds the total capacity of the
+// server, so connecting priority clients can always be accepted by kicking out free
+// clients if necessary.
+//
+// The disconnect callbacks of clients are never called while holding the lock of
+// the pool, they may call back into it.
+type priorityClientPool struct {
+	lock   sync.Mutex
+	db     ethdb.Database
+	clock  mclock.Clock
+	child  *freeClientPool
+	closed bool
+	quit   chan struct{}
+
+	freeCapacity, totalCapacity         uint64
+	assignedCapacity, connectedCapacity uint64
+	clients                             map[enode.ID]*priorityClient
+}
+
+// priorityClient represents a client known by the priority pool.
+type priorityClient struct {
+	id         enode.ID
+	capacity   uint64         // assigned capacity, zero if not a priority client
+	balance    uint64         // token balance at lastUpdate
+	lastUpdate mclock.AbsTime // time of the last balance update
+
+	// fields of clients connected with priority
+	connected    bool
+	connCapacity uint64 // capacity the client is currently served with
+	disconnectFn func()
+	stopTimer    chan struct{}
+}
+
+// newPriorityClientPool creates a new priority client pool. The total capacity of
+// the server is shared by at most maxPeers free clients.
+func newPriorityClientPool(db ethdb.Database, freeCapacity uint64, maxPeers int, clock mclock.Clock) *priorityClientPool {
+	pool := &priorityClientPool{
+		db:            db,
+		clock:         clock,
+		child:         newFreeClientPool(db, maxPeers, 10000, clock),
+		quit:          make(chan struct{}),
+		freeCapacity:  freeCapacity,
+		totalCapacity: freeCapacity * uint64(maxPeers),
+		clients:       make(map[enode.ID]*priorityClient),
+	}
+	pool.loadFromDb()
+	return pool
+}
+
+func (pool *priorityClientPool) stop() {
+	pool.lock.Lock()
+	pool.closed = true
+	close(pool.quit)
+	pool.saveToDb()
+	pool.lock.Unlock()
+
+	pool.child.stop()
+}
+
+// capacity returns the capacity a connecting client would be served with.
+func (pool *priorityClientPool) capacity(id enode.ID) uint64 {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if c := pool.clients[id]; c != nil && !c.connected && c.capacity != 0 {
+		pool.updateBalance(c, pool.clock.Now())
+		if c.balance > 0 {
+			return c.capacity
+		}
+	}
+	return pool.freeCapacity
+}
+
+// connect should be called after a successful handshake with the capacity the
+// client was offered during the handshake (see capacity). Clients offered the free
+// capacity are passed on to the free client pool which identifies them by address.
+// If the connection was rejected, there is no need to call disconnect.
+func (pool *priorityClientPool) connect(id enode.ID, address string, capacity uint64, disconnectFn func()) bool {
+	var kicked []func()
+	defer func() { callAll(kicked) }() // after releasing the lock
+
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if pool.closed {
+		return false
+	}
+	if capacity == pool.freeCapacity {
+		if c := pool.clients[id]; c != nil && c.connected {
+			log.Debug("Client already connected", "id", id)
+			return false
+		}
+		accepted, kickedFree := pool.child.tryConnect(address, disconnectFn)
+		if kickedFree != nil {
+			kicked = append(kicked, kickedFree)
+		}
+		return accepted
+	}
+	c := pool.clients[id]
+	if c == nil || c.connected || c.capacity != capacity {
+		log.Debug("Priority client rejected", "id", id, "capacity", capacity)
+		return false
+	}
+	now := pool.clock.Now()
+	pool.updateBalance(c, now)
+	if c.balance == 0 {
+		log.Debug("Priority client rejected, balance exhausted", "id", id)
+		return false
+	}
+	c.connected = true
+	c.connCapacity = capacity
+	c.disconnectFn = disconnectFn
+	pool.connectedCapacity += capacity
+	kicked = pool.setFreeLimit()
+	pool.armTimer(c)
+	log.Debug("Priority client accepted", "id", id, "capacity", capacity)
+	return true
+}
+
+// disconnect should be called when a connection is terminated. It is permitted
+// to call it after the pool itself initiated the disconnection.
+func (pool *priorityClientPool) disconnect(id enode.ID, address string) {
+	var kicked []func()
+	defer func() { callAll(kicked) }() // after releasing the lock
+
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if pool.closed {
+		return
+	}
+	c := pool.clients[id]
+	if c == nil || !c.connected {
+		pool.child.disconnect(address)
+		return
+	}
+	pool.updateBalance(c, pool.clock.Now())
+	c.connected = false
+	pool.connectedCapacity -= c.connCapacity
+	c.connCapacity, c.disconnectFn = 0, nil
+	if c.stopTimer != nil {
+		close(c.stopTimer)
+		c.stopTimer = nil
+	}
+	kicked = pool.setFreeLimit()
+	pool.dropIfUnused(c)
+	pool.saveToDb()
+	log.Debug("Priority client disconnected", "id", id)
+}
+
+// addBalance adds amount to the balance of a client and returns the balance
+// before and after the operation.
+func (pool *priorityClientPool) addBalance(id enode.ID, amount int64) (uint64, uint64, error) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if pool.closed {
+		return 0, 0, errPoolClosed
+	}
+	c := pool.client(id)
+	pool.updateBalance(c, pool.clock.Now())
+	old := c.balance
+	if amount < 0 && uint64(-amount) > old {
+		pool.dropIfUnused(c)
+		return old, old, errNegativeBalance
+	}
+	if amount < 0 {
+		c.balance -= uint64(-amount)
+	} else {
+		c.balance += uint64(amount)
+		if c.balance < old {
+			c.balance = math.MaxUint64
+		}
+	}
+	if c.connected {
+		pool.armTimer(c)
+	}
+	pool.dropIfUnused(c)
+	pool.saveToDb()
+	return old, c.balance, nil
+}
+
+// setCapacity assigns a priority capacity to a client. Zero capacity turns the
+// client into a free client. A connected client is disconnected on any change of
+// its capacity, as flow control parameters are only announced in the handshake,
+// so that it can reconnect with its new capacity.
+func (pool *priorityClientPool) setCapacity(id enode.ID, capacity uint64) error {
+	var disconnectFn func()
+	defer func() {
+		if disconnectFn != nil {
+			disconnectFn() // after releasing the lock
+		}
+	}()
+
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if pool.closed {
+		return errPoolClosed
+	}
+	if capacity != 0 && capacity <= pool.freeCapacity {
+		return errCapacityTooLow
+	}
+	c := pool.client(id)
+	if pool.assignedCapacity-c.capacity+capacity > pool.totalCapacity {
+		pool.dropIfUnused(c)
+		return errCapacityExceeded
+	}
+	pool.updateBalance(c, pool.clock.Now())
+	pool.assignedCapacity += capacity - c.capacity
+	c.capacity = capacity
+	if c.connected && capacity != c.connCapacity {
+		disconnectFn = c.disconnectFn
+	}
+	pool.dropIfUnused(c)
+	pool.saveToDb()
+	return nil
+}
+
+// clientInfo returns the assigned capacity and the current balance of a client
+// and whether it is connected with priority.
+func (pool *priorityClientPool) clientInfo(id enode.ID) (capacity, balance uint64, connected bool) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	c := pool.clients[id]
+	if c == nil {
+		return 0, 0, false
+	}
+	pool.updateBalance(c, pool.clock.Now())
+	return c.capacity, c.balance, c.connected
+}
+
+// knownClients returns the IDs of all clients with an assigned capacity or a
+// positive balance.
+func (pool *priorityClientPool) knownClients() []enode.ID {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	ids := make([]enode.ID, 0, len(pool.clients))
+	for id := range pool.clients {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// client returns the entry of a client, creating it if necessary.
+func (pool *priorityClientPool) client(id enode.ID) *priorityClient {
+	c := pool.clients[id]
+	if c == nil {
+		c = &priorityClient{id: id, lastUpdate: pool.clock.Now()}
+		pool.clients[id] = c
+	}
+	return c
+}
+
+// dropIfUnused removes a disconnected client without capacity and balance.
+func (pool *priorityClientPool) dropIfUnused(c *priorityClient) {
+	if !c.connected && c.capacity == 0 && c.balance == 0 {
+		delete(pool.clients, c.id)
+	}
+}
+
+// setFreeLimit updates the connection limit of the free client pool according
+// to the capacity not used by connected priority clients. It returns the
+// disconnect callbacks of the free clients kicked out.
+func (pool *priorityClientPool) setFreeLimit() []func() {
+	return pool.child.setConnectedLimit(int((pool.totalCapacity - pool.connectedCapacity) / pool.freeCapacity))
+}
+
+// updateBalance applies the decay and the spending of a connected client since
+// the last update to its balance.
+func (pool *priorityClientPool) updateBalance(c *priorityClient, now mclock.AbsTime) {
+	c.balance = decayBalance(c.balance, c.connCapacity, time.Duration(now-c.lastUpdate))
+	c.lastUpdate = now
+}
+
+// armTimer schedules the disconnection of a connected priority client at the
+// time its balance runs out, replacing any previously scheduled one. The balance
+// of the client should be up to date.
+func (pool *priorityClientPool) armTimer(c *priorityClient) {
+	if c.stopTimer != nil {
+		close(c.stopTimer)
+	}
+	stop := make(chan struct{})
+	c.stopTimer = stop
+	timer := pool.clock.After(balanceExpiry(c.balance, c.connCapacity))
+	go func() {
+		select {
+		case <-timer:
+			pool.balanceExpired(c, stop)
+		case <-stop:
+		case <-pool.quit:
+		}
+	}()
+}
+
+// balanceExpired is called when the balance of a connected priority client is
+// expected to run out.
+func (pool *priorityClientPool) balanceExpired(c *priorityClient, stop chan struct{}) {
+	var disconnectFn func()
+	defer func() {
+		if disconnectFn != nil {
+			disconnectFn() // after releasing the lock
+		}
+	}()
+
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if pool.closed || c.stopTimer != stop {
+		return
+	}
+	pool.updateBalance(c, pool.clock.Now())
+	if c.balance > 0 {
+		pool.armTimer(c)
+		return
+	}
+	c.stopTimer = nil
+	log.Debug("Priority client balance exhausted", "id", c.id)
+	disconnectFn = c.disconnectFn
+}
+
+// callAll calls the given functions in order.
+func callAll(fns []func()) {
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// decayBalance returns the balance after time dt if it is spent with the given
+// rate (tokens per second) while also decaying exponentially:
+//
+//	b(t) = (b(0) + rate*tc) * exp(-t/tc) - rate*tc
+func decayBalance(balance, rate uint64, dt time.Duration) uint64 {
+	if dt <= 0 {
+		return balance
+	}
+	tc := balanceDecayTC.Seconds()
+	rtc := float64(rate) * tc
+	b := (float64(balance)+rtc)*math.Exp(-dt.Seconds()/tc) - rtc
+	switch {
+	case b <= 0:
+		return 0
+	case b >= math.MaxUint64:
+		return math.MaxUint64
+	}
+	return uint64(b)
+}
+
+// balanceExpiry returns the time until a balance runs out when spent with the
+// given rate (see decayBalance).
+func balanceExpiry(balance, rate uint64) time.Duration {
+	if rate == 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	tc := balanceDecayTC.Seconds()
+	rtc := float64(rate) * tc
+	d := tc * math.Log((float64(balance)+rtc)/rtc) * float64(time.Second)
+	if d >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(d)
+}
+
+var priorityClientPoolKey = []byte("priorityClientPool")
+
+// loadFromDb restores the known clients from the database storage
+// (automatically called at initialization)
+func (pool *priorityClientPool) loadFromDb() {
+	enc, err := pool.db.Get(priorityClientPoolKey)
+	if err != nil {
+		return
+	}
+	var list []*priorityClient
+	if err := rlp.DecodeBytes(enc, &list); err != nil {
+		log.Error("Failed to decode priority client list", "err", err)
+		return
+	}
+	now := pool.clock.Now()
+	for _, c := range list {
+		log.Debug("Loaded priority client record", "id", c.id, "capacity", c.capacity, "balance", c.balance)
+		if pool.assignedCapacity+c.capacity > pool.totalCapacity {
+			log.Warn("Priority capacity exceeds server capacity", "id", c.id, "capacity", c.capacity)
+			c.capacity = 0
+		}
+		pool.assignedCapacity += c.capacity
+		c.lastUpdate = now
+		pool.clients[c.id] = c
+	}
+}
+
+// saveToDb saves the known clients to the database storage
+func (pool *priorityClientPool) saveToDb() {
+	now := pool.clock.Now()
+	list := make([]*priorityClient, 0, len(pool.clients))
+	for _, c := range pool.clients {
+		pool.updateBalance(c, now)
+		list = append(list, c)
+	}
+	enc, err := rlp.EncodeToBytes(list)
+	if err != nil {
+		log.Error("Failed to encode priority client list", "err", err)
+	} else {
+		pool.db.Put(priorityClientPoolKey, enc)
+	}
+}
+
+func (c *priorityClient) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, []interface{}{c.id, c.capacity, c.balance})
+}
+
+func (c *priorityClient) DecodeRLP(s *rlp.Stream) error {
+	var entry struct {
+		ID                enode.ID
+		Capacity, Balance uint64
+	}
+	if err := s.Decode(&entry); err != nil {
+		return err
+	}
+	c.id, c.capacity, c.balance = entry.ID, entry.Capacity, entry.Balance
+	return nil
+}
@@ -0,0 +1,224 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func TestDecayBalance(t *testing.T) {
+	halfLife := time.Duration(balanceDecayTC.Seconds() * math.Ln2 * float64(time.Second))
+	if b := decayBalance(1000000, 0, halfLife); b < 499999 || b > 500001 {
+		t.Errorf("balance after half-life: got %d, want 500000", b)
+	}
+	if b := decayBalance(1000000, 0, 0); b != 1000000 {
+		t.Errorf("balance changed without elapsed time: %d", b)
+	}
+	// spending alone uses up about rate tokens per second over short periods
+	if b := decayBalance(1000000, 1000, 100*time.Second); b < 899000 || b > 900000 {
+		t.Errorf("balance after spending: got %d, want about 900000", b)
+	}
+	for _, rate := range []uint64{1, 1000, 1000000} {
+		exp := balanceExpiry(1000000, rate)
+		if b := decayBalance(1000000, rate, exp); b != 0 {
+			t.Errorf("rate %d: balance %d left at expiry", rate, b)
+		}
+		if b := decayBalance(1000000, rate, exp-exp/100); b == 0 {
+			t.Errorf("rate %d: balance exhausted before expiry", rate)
+		}
+	}
+}
+
+// testPoolClient records the callbacks of the priority client pool.
+type testPoolClient struct {
+	id           enode.ID
+	address      string
+	disconnected chan struct{}
+}
+
+func newTestPoolClient(i int) *testPoolClient {
+	return &testPoolClient{
+		id:           enode.ID{byte(i), byte(i >> 8)},
+		address:      fmt.Sprintf("test address #%d", i),
+		disconnected: make(chan struct{}, 1),
+	}
+}
+
+func (c *testPoolClient) connect(pool *priorityClientPool) bool {
+	return pool.connect(c.id, c.address, pool.capacity(c.id), func() { c.disconnected <- struct{}{} })
+}
+
+func (c *testPoolClient) wasDisconnected(timeout time.Duration) bool {
+	select {
+	case <-c.disconnected:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func TestPriorityClientPool(t *testing.T) {
+	var (
+		clock    mclock.Simulated
+		db       = ethdb.NewMemDatabase()
+		pool     = newPriorityClientPool(db, 10, 4, &clock)
+		free     = make([]*testPoolClient, 4)
+		priority = newTestPoolClient(100)
+	)
+	for i := range free {
+		free[i] = newTestPoolClient(i)
+		if !free[i].connect(pool) {
+			t.Fatalf("free client #%d rejected", i)
+		}
+	}
+	if newTestPoolClient(4).connect(pool) {
+		t.Fatalf("free client accepted over the connected limit")
+	}
+
+	// assign priority capacity within the server capacity
+	if err := pool.setCapacity(priority.id, 10); err != errCapacityTooLow {
+		t.Fatalf("wrong error for free capacity: %v", err)
+	}
+	if err := pool.setCapacity(priority.id, 20); err != nil {
+		t.Fatalf("setCapacity failed: %v", err)
+	}
+	if err := pool.setCapacity(newTestPoolClient(101).id, 30); err != errCapacityExceeded {
+		t.Fatalf("wrong error for exceeded capacity: %v", err)
+	}
+	if cap := pool.capacity(priority.id); cap != 10 {
+		t.Fatalf("client without balance offered capacity %d, want free capacity", cap)
+	}
+	if _, _, err := pool.addBalance(priority.id, -1); err != errNegativeBalance {
+		t.Fatalf("wrong error for negative balance: %v", err)
+	}
+	if old, balance, err := pool.addBalance(priority.id, 1000); err != nil || old != 0 || balance != 1000 {
+		t.Fatalf("addBalance returned %d, %d, %v", old, balance, err)
+	}
+
+	// a connecting priority client kicks out free clients to make room
+	if cap := pool.capacity(priority.id); cap != 20 {
+		t.Fatalf("priority client offered capacity %d, want 20", cap)
+	}
+	if !priority.connect(pool) {
+		t.Fatalf("priority client rejected")
+	}
+	var kicked int
+	for _, c := range free {
+		select {
+		case <-c.disconnected:
+			kicked++
+			pool.disconnect(c.id, c.address)
+		default:
+		}
+	}
+	if kicked != 2 {
+		t.Fatalf("%d free clients kicked out, want 2", kicked)
+	}
+
+	// any capacity change disconnects the client to renegotiate its parameters
+	for _, capacity := range []uint64{25, 20} {
+		if err := pool.setCapacity(priority.id, capacity); err != nil {
+			t.Fatalf("setCapacity failed: %v", err)
+		}
+		if !priority.wasDisconnected(time.Second) {
+			t.Fatalf("priority client not disconnected after capacity change to %d", capacity)
+		}
+		pool.disconnect(priority.id, priority.address)
+		if cap := pool.capacity(priority.id); cap != capacity {
+			t.Fatalf("priority client offered capacity %d, want %d", cap, capacity)
+		}
+		if !priority.connect(pool) {
+			t.Fatalf("priority client rejected after capacity change to %d", capacity)
+		}
+	}
+	if err := pool.setCapacity(priority.id, 20); err != nil {
+		t.Fatalf("setCapacity failed: %v", err)
+	}
+	if priority.wasDisconnected(100 * time.Millisecond) {
+		t.Fatalf("priority client disconnected without capacity change")
+	}
+	pool.disconnect(priority.id, priority.address)
+
+	// the balance runs out after about 50 seconds of connection time
+	if !priority.connect(pool) {
+		t.Fatalf("priority client rejected after reconnecting")
+	}
+	clock.Run(45 * time.Second)
+	if priority.wasDisconnected(100 * time.Millisecond) {
+		t.Fatalf("priority client disconnected before its balance ran out")
+	}
+	clock.Run(10 * time.Second)
+	if !priority.wasDisconnected(time.Second) {
+		t.Fatalf("priority client not disconnected after its balance ran out")
+	}
+	pool.disconnect(priority.id, priority.address)
+	if cap := pool.capacity(priority.id); cap != 10 {
+		t.Fatalf("client with exhausted balance offered capacity %d, want free capacity", cap)
+	}
+	for i := 4; i < 6; i++ {
+		if !newTestPoolClient(i).connect(pool) {
+			t.Fatalf("free client #%d rejected after priority client left", i)
+		}
+	}
+
+	// assigned capacities and balances are persisted
+	pool.addBalance(priority.id, 500)
+	pool.stop()
+	pool = newPriorityClientPool(db, 10, 4, &clock)
+	if cap, balance, connected := pool.clientInfo(priority.id); cap != 20 || balance != 500 || connected {
+		t.Fatalf("restored client info: capacity %d, balance %d, connected %t", cap, balance, connected)
+	}
+	pool.stop()
+}
+
+// Tests that disconnect callbacks may call back into the pool, as they are not
+// invoked while holding its lock.
+func TestPriorityClientPoolReentrantDisconnect(t *testing.T) {
+	var (
+		clock    mclock.Simulated
+		pool     = newPriorityClientPool(ethdb.NewMemDatabase(), 10, 2, &clock)
+		priority = newTestPoolClient(100)
+		kicked   int
+	)
+	defer pool.stop()
+
+	for i := 0; i < 2; i++ {
+		c := newTestPoolClient(i)
+		if !pool.connect(c.id, c.address, pool.capacity(c.id), func() { kicked++; pool.disconnect(c.id, c.address) }) {
+			t.Fatalf("free client #%d rejected", i)
+		}
+	}
+	pool.setCapacity(priority.id, 20)
+	pool.addBalance(priority.id, 1000)
+	if !pool.connect(priority.id, priority.address, pool.capacity(priority.id), func() { pool.disconnect(priority.id, priority.address) }) {
+		t.Fatalf("priority client rejected")
+	}
+	if kicked != 2 {
+		t.Fatalf("%d free clients kicked out, want 2", kicked)
+	}
+	pool.setCapacity(priority.id, 15)
+	if _, _, connected := pool.clientInfo(priority.id); connected {
+		t.Fatalf("priority client still connected after capacity change")
+	}
+}
@@ -419,6 +419,14 @@ func (f *lightFetcher) nextRequest() (*distReq, uint64, bool) {
 
 	for p, fp := range f.peers {
 		for hash, n := range fp.nodeByHash {
+			if f.pm.ulc != nil {
+				// ultra-light clients only fetch the heads confirmed by their
+				// trusted servers, without their ancestors
+				if !f.checkKnownNode(p, n) && !n.requested && (bestTd == nil || n.td.Cmp(bestTd) > 0) && f.trustedTd(hash) != nil {
+					bestHash, bestAmount, bestTd = hash, 1, n.td
+				}
+				continue
+			}
 			if !f.checkKnownNode(p, n) && !n.requested && (bestTd == nil || n.td.Cmp(bestTd) >= 0) {
 				amount := f.requestAmount(p, n)
 				if bestTd == nil || n.td.Cmp(bestTd) > 0 || amount < bestAmount {
@@ -516,6 +524,9 @@ func (f *lightFetcher) processResponse(req fetchRequest, resp fetchResponse) boo
 		req.peer.Log().Debug("Response content mismatch", "requested", len(resp.headers), "reqfrom", resp.headers[0], "delivered", req.amount, "delfrom", req.hash)
 		return false
 	}
+	if f.pm.ulc != nil {
+		return f.processTrustedHead(resp.headers[0])
+	}
 	headers := make([]*types.Header, req.amount)
 	for i, header := range resp.headers {
 		headers[int(req.amount)-1-i] = header
@@ -540,6 +551,48 @@ func (f *lightFetcher) processResponse(req fetchRequest, resp fetchResponse) boo
 	return true
 }
 
+// processTrustedHead sets a head confirmed by the trusted servers of an ultra-light
+// client as the new head of the chain, returns true if successful
+func (f *lightFetcher) processTrustedHead(header *types.Header) bool {
+	td := f.trustedTd(header.Hash())
+	if td == nil {
+		// the trusted servers no longer agree on this head, wait for a new one
+		return true
+	}
+	head := f.chain.CurrentHeader()
+	if headTd := f.chain.GetTd(head.Hash(), head.Number.Uint64()); headTd != nil && td.Cmp(headTd) <= 0 {
+		return true
+	}
+	f.chain.SetTrustedHead(header, td)
+	f.newHeaders([]*types.Header{header}, []*big.Int{td})
+	return true
+}
+
+// trustedTd returns the total difficulty of a block if it has been announced with
+// the same total difficulty by the required number of trusted servers, or nil.
+func (f *lightFetcher) trustedTd(hash common.Hash) *big.Int {
+	var (
+		td    *big.Int
+		count int
+	)
+	for p, fp := range f.peers {
+		if !p.trusted {
+			continue
+		}
+		if n := fp.nodeByHash[hash]; n != nil && n.td != nil {
+			if td != nil && td.Cmp(n.td) != 0 {
+				return nil
+			}
+			td = n.td
+			count++
+		}
+	}
+	if count < f.pm.ulc.minTrusted {
+		return nil
+	}
+	return td
+}
+
 // newHeaders updates the block trees of all active peers according to a newly
 // downloaded and validated batch or headers
 func (f *lightFetcher) newHeaders(headers []*types.Header, tds []*big.Int) {
@@ -582,6 +635,10 @@ func (f *lightFetcher) checkAnnouncedHeaders(fp *fetcherPeerInfo, headers []*typ
 			td = f.chain.GetTd(hash, number)
 			header = f.chain.GetHeader(hash, number)
 			if header == nil || td == nil {
+				if f.pm.ulc != nil {
+					// ultra-light clients do not have the ancestors of trusted heads
+					return true
+				}
 				log.Error("Missing parent of validated header", "hash", hash, "number", number)
 				return false
 			}
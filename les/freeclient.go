@@ -88,13 +88,25 @@ func (f *freeClientPool) stop() {
 // connect should be called after a successful handshake. If the connection was
 // rejected, there is no need to call disconnect.
 //
-// Note: the disconnectFn callback should not block.
+// Note: the disconnectFn callback is not called while holding the lock of the
+// pool, it may call disconnect.
 func (f *freeClientPool) connect(address string, disconnectFn func()) bool {
+	accepted, kicked := f.tryConnect(address, disconnectFn)
+	if kicked != nil {
+		kicked()
+	}
+	return accepted
+}
+
+// tryConnect accepts or rejects a connecting client like connect, returning the
+// disconnect callback of the client kicked out to make room, if any, instead of
+// calling it.
+func (f *freeClientPool) tryConnect(address string, disconnectFn func()) (bool, func()) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
 	if f.closed {
-		return false
+		return false, nil
 	}
 	e := f.addressMap[address]
 	now := f.clock.Now()
@@ -105,13 +117,18 @@ func (f *freeClientPool) connect(address string, disconnectFn func()) bool {
 	} else {
 		if e.connected {
 			log.Debug("Client already connected", "address", address)
-			return false
+			return false, nil
 		}
 		recentUsage = int64(math.Exp(float64(e.logUsage-f.logOffset(now)) / fixedPointMultiplier))
 	}
 	e.linUsage = recentUsage - int64(now)
+	if f.connectedLimit == 0 {
+		log.Debug("Client rejected", "address", address)
+		return false, nil
+	}
 	// check whether (linUsage+connectedBias) is smaller than the highest entry in the connected pool
-	if f.connPool.Size() == f.connectedLimit {
+	var kicked func()
+	if f.connPool.Size() >= f.connectedLimit {
 		i := f.connPool.PopItem().(*freeClientPoolEntry)
 		if e.linUsage+int64(connectedBias)-i.linUsage < 0 {
 			// kick it out and accept the new client
@@ -120,12 +137,12 @@ func (f *freeClientPool) connect(address string, disconnectFn func()) bool {
 			i.connected = false
 			f.disconnPool.Push(i, -i.logUsage)
 			log.Debug("Client kicked out", "address", i.address)
-			i.disconnectFn()
+			kicked = i.disconnectFn
 		} else {
 			// keep the old client and reject the new one
 			f.connPool.Push(i, i.linUsage)
 			log.Debug("Client rejected", "address", address)
-			return false
+			return false, nil
 		}
 	}
 	f.disconnPool.Remove(e.index)
@@ -136,7 +153,7 @@ func (f *freeClientPool) connect(address string, disconnectFn func()) bool {
 		f.disconnPool.Pop()
 	}
 	log.Debug("Client accepted", "address", address)
-	return true
+	return true, kicked
 }
 
 // disconnect should be called when a connection is terminated. If the disconnection
@@ -151,7 +168,7 @@ func (f *freeClientPool) disconnect(address string) {
 	}
 	e := f.addressMap[address]
 	now := f.clock.Now()
-	if !e.connected {
+	if e == nil || !e.connected {
 		log.Debug("Client already disconnected", "address", address)
 		return
 	}
@@ -163,6 +180,31 @@ func (f *freeClientPool) disconnect(address string) {
 	log.Debug("Client disconnected", "address", address)
 }
 
+// setConnectedLimit changes the maximum number of connected clients. If there are
+// more clients connected than the new limit, the ones with the highest recent
+// usage are kicked out. Their disconnect callbacks are returned to be called by
+// the caller once it released its own locks.
+func (f *freeClientPool) setConnectedLimit(limit int) []func() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.connectedLimit = limit
+	if f.closed {
+		return nil
+	}
+	var kicked []func()
+	now := f.clock.Now()
+	for f.connPool.Size() > limit {
+		i := f.connPool.PopItem().(*freeClientPoolEntry)
+		f.calcLogUsage(i, now)
+		i.connected = false
+		f.disconnPool.Push(i, -i.logUsage)
+		log.Debug("Client kicked out", "address", i.address)
+		kicked = append(kicked, i.disconnectFn)
+	}
+	return kicked
+}
+
 // logOffset calculates the time-dependent offset for the logarithmic
 // representation of recent usage
 func (f *freeClientPool) logOffset(now mclock.AbsTime) int64 {
@@ -39,7 +39,7 @@ import (
 	"github.com/ethereum/go-ethereum/light"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
-	"github.com/ethereum/go-ethereum/p2p/discv5"
+	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
@@ -100,8 +100,9 @@ type ProtocolManager struct {
 	odr         *LesOdr
 	server      *LesServer
 	serverPool  *serverPool
-	clientPool  *freeClientPool
-	lesTopic    discv5.Topic
+	clientPool  *priorityClientPool
+	ulc         *ulc // nil if not in ultra-light client mode
+	lesTopic    discover.Topic
 	reqDist     *requestDistributor
 	retriever   *retrieveManager
 
@@ -124,7 +125,7 @@ type ProtocolManager struct {
 
 // NewProtocolManager returns a new ethereum sub protocol manager. The Ethereum sub protocol manages peers capable
 // with the ethereum network.
-func NewProtocolManager(chainConfig *params.ChainConfig, indexerConfig *light.IndexerConfig, lightSync bool, networkId uint64, mux *event.TypeMux, engine consensus.Engine, peers *peerSet, blockchain BlockChain, txpool txPool, chainDb ethdb.Database, odr *LesOdr, txrelay *LesTxRelay, serverPool *serverPool, quitSync chan struct{}, wg *sync.WaitGroup) (*ProtocolManager, error) {
+func NewProtocolManager(chainConfig *params.ChainConfig, indexerConfig *light.IndexerConfig, lightSync bool, networkId uint64, mux *event.TypeMux, engine consensus.Engine, peers *peerSet, blockchain BlockChain, txpool txPool, chainDb ethdb.Database, odr *LesOdr, txrelay *LesTxRelay, serverPool *serverPool, ulc *ulc, quitSync chan struct{}, wg *sync.WaitGroup) (*ProtocolManager, error) {
 	// Create the protocol manager with the base fields
 	manager := &ProtocolManager{
 		lightSync:   lightSync,
@@ -138,6 +139,7 @@ func NewProtocolManager(chainConfig *params.ChainConfig, indexerConfig *light.In
 		txpool:      txpool,
 		txrelay:     txrelay,
 		serverPool:  serverPool,
+		ulc:         ulc,
 		peers:       peers,
 		newPeerCh:   make(chan *peer),
 		quitSync:    quitSync,
@@ -155,7 +157,7 @@ func NewProtocolManager(chainConfig *params.ChainConfig, indexerConfig *light.In
 	}
 
 	if lightSync {
-		manager.downloader = downloader.New(downloader.LightSync, chainDb, manager.eventMux, nil, blockchain, removePeer)
+		manager.downloader = downloader.New(downloader.LightSync, chainDb, manager.eventMux, nil, blockchain, func(id string, reason downloader.DropReason) { removePeer(id) })
 		manager.peers.notify((*downloaderPeerNotify)(manager))
 		manager.fetcher = newLightFetcher(manager)
 	}
@@ -174,7 +176,7 @@ func (pm *ProtocolManager) Start(maxPeers int) {
 	if pm.lightSync {
 		go pm.syncer()
 	} else {
-		pm.clientPool = newFreeClientPool(pm.chainDb, maxPeers, 10000, mclock.System{})
+		pm.clientPool = newPriorityClientPool(pm.chainDb, pm.server.defParams.MinRecharge, maxPeers, mclock.System{})
 		go func() {
 			for range pm.newPeerCh {
 			}
@@ -234,7 +236,9 @@ func (pm *ProtocolManager) runPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWrit
 }
 
 func (pm *ProtocolManager) newPeer(pv int, nv uint64, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
-	return newPeer(pv, nv, p, newMeteredMsgWriter(rw))
+	peer := newPeer(pv, nv, p, newMeteredMsgWriter(rw))
+	peer.trusted = pm.ulc != nil && pm.ulc.trusted(p.ID())
+	return peer
 }
 
 // handle is the callback invoked to manage the life cycle of a les peer. When
@@ -242,12 +246,16 @@ func (pm *ProtocolManager) newPeer(pv int, nv uint64, p *p2p.Peer, rw p2p.MsgRea
 func (pm *ProtocolManager) handle(p *peer) error {
 	// Ignore maxPeers if this is a trusted peer
 	// In server mode we try to check into the client pool after handshake
-	if pm.lightSync && pm.peers.Len() >= pm.maxPeers && !p.Peer.Info().Network.Trusted {
+	if pm.lightSync && pm.peers.Len() >= pm.maxPeers && !p.Peer.Info().Network.Trusted && !p.trusted {
 		return p2p.DiscTooManyPeers
 	}
 
 	p.Log().Debug("Light Ethereum peer connected", "name", p.Name())
 
+	// Offer priority clients their assigned capacity
+	if !pm.lightSync && !p.Peer.Info().Network.Trusted {
+		p.fcParams = pm.server.capacityParams(pm.clientPool.capacity(p.ID()))
+	}
 	// Execute the LES handshake
 	var (
 		genesis = pm.blockchain.Genesis()
@@ -266,10 +274,10 @@ func (pm *ProtocolManager) handle(p *peer) error {
 		// test peer address is not a tcp address, don't use client pool if can not typecast
 		if ok {
 			id := addr.IP.String()
-			if !pm.clientPool.connect(id, func() { go pm.removePeer(p.id) }) {
+			if !pm.clientPool.connect(p.ID(), id, p.fcParams.MinRecharge, func() { pm.removePeer(p.id) }) {
 				return p2p.DiscTooManyPeers
 			}
-			defer pm.clientPool.disconnect(id)
+			defer pm.clientPool.disconnect(p.ID(), id)
 		}
 	}
 
@@ -342,12 +350,13 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			return true
 		}
 		bufValue, _ := p.fcClient.AcceptRequest()
+		params := p.fcClient.Params()
 		cost := costs.baseCost + reqCnt*costs.reqCost
-		if cost > pm.server.defParams.BufLimit {
-			cost = pm.server.defParams.BufLimit
+		if cost > params.BufLimit {
+			cost = params.BufLimit
 		}
 		if cost > bufValue {
-			recharge := time.Duration((cost - bufValue) * 1000000 / pm.server.defParams.MinRecharge)
+			recharge := time.Duration((cost - bufValue) * 1000000 / params.MinRecharge)
 			p.Log().Error("Request came too early", "recharge", common.PrettyDuration(recharge))
 			return true
 		}
@@ -176,7 +176,7 @@ func newTestProtocolManager(lightSync bool, blocks int, generator func(int, *cor
 	if lightSync {
 		indexConfig = light.TestClientIndexerConfig
 	}
-	pm, err := NewProtocolManager(gspec.Config, indexConfig, lightSync, NetworkId, evmux, engine, peers, chain, nil, db, odr, nil, nil, make(chan struct{}), new(sync.WaitGroup))
+	pm, err := NewProtocolManager(gspec.Config, indexConfig, lightSync, NetworkId, evmux, engine, peers, chain, nil, db, odr, nil, nil, nil, make(chan struct{}), new(sync.WaitGroup))
 	if err != nil {
 		return nil, err
 	}
@@ -254,13 +254,19 @@ func newTestPeer(t *testing.T, name string, version int, pm *ProtocolManager, sh
 }
 
 func newTestPeerPair(name string, version int, pm, pm2 *ProtocolManager) (*peer, <-chan error, *peer, <-chan error) {
-	// Create a message pipe to communicate through
-	app, net := p2p.MsgPipe()
-
-	// Generate a random id and create the peer
+	// Generate a random id and create the peers
 	var id enode.ID
 	rand.Read(id[:])
 
+	return newTestPeerPairWithID(id, name, version, pm, pm2)
+}
+
+// newTestPeerPairWithID connects two protocol managers through a peer pair using
+// the given node ID on both sides.
+func newTestPeerPairWithID(id enode.ID, name string, version int, pm, pm2 *ProtocolManager) (*peer, <-chan error, *peer, <-chan error) {
+	// Create a message pipe to communicate through
+	app, net := p2p.MsgPipe()
+
 	peer := pm.newPeer(version, NetworkId, p2p.NewPeer(id, name, nil), net)
 	peer2 := pm2.newPeer(version, NetworkId, p2p.NewPeer(id, name, nil), app)
 
@@ -0,0 +1,105 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// startDiscoveryServer starts a p2p server running discovery v5 on localhost,
+// which reports the peers of its stub light protocol on the given channel, if
+// there is room.
+func startDiscoveryServer(t *testing.T, name string, bootnodes []*enode.Node, peers chan<- enode.ID) *p2p.Server {
+	key, _ := crypto.GenerateKey()
+	srv := &p2p.Server{
+		Config: p2p.Config{
+			Name:             name,
+			PrivateKey:       key,
+			MaxPeers:         10,
+			ListenAddr:       "127.0.0.1:0",
+			NoDiscovery:      true,
+			DiscoveryV5:      true,
+			BootstrapNodesV5: bootnodes,
+			Protocols: []p2p.Protocol{{
+				Name:    "les",
+				Version: lpv2,
+				Length:  ProtocolLengths[lpv2],
+				Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+					select {
+					case peers <- p.ID():
+					default:
+					}
+					_, err := rw.ReadMsg()
+					return err
+				},
+			}},
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("failed to start %s: %v", name, err)
+	}
+	return srv
+}
+
+// Tests that the server pool of a light client finds a light server through the
+// topic advertisements of discovery v5 and dials it.
+func TestServerPoolTopicDiscovery(t *testing.T) {
+	var (
+		topic = lesTopic(common.Hash{}, lpv2)
+		peers = make(chan enode.ID, 1)
+	)
+	registrar := startDiscoveryServer(t, "registrar", nil, nil)
+	defer registrar.Stop()
+	bootnodes := []*enode.Node{registrar.Self()}
+
+	server := startDiscoveryServer(t, "server", bootnodes, peers)
+	defer server.Stop()
+	stop := make(chan struct{})
+	defer close(stop)
+	go server.DiscV5.RegisterTopic(topic, stop)
+
+	client := startDiscoveryServer(t, "client", bootnodes, nil)
+	defer client.Stop()
+
+	var (
+		quit = make(chan struct{})
+		wg   sync.WaitGroup
+		pool = newServerPool(ethdb.NewMemDatabase(), quit, &wg)
+	)
+	pool.start(client, topic)
+	defer func() {
+		close(quit)
+		wg.Wait()
+	}()
+
+	select {
+	case id := <-peers:
+		if want := client.Self().ID(); id != want {
+			t.Fatalf("light server connected to %v, want client %v", id, want)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("light server not found by the server pool")
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// ulc holds the trusted server set of an ultra-light client. Ultra-light clients
+// request signed announcements from the trusted servers and accept a new head
+// once it has been announced by at least minTrusted of them, without downloading
+// and verifying the header chain.
+type ulc struct {
+	nodes      []*enode.Node
+	keys       map[enode.ID]struct{}
+	minTrusted int
+}
+
+// newULC parses the trusted server set of an ultra-light client.
+func newULC(config *eth.ULCConfig) (*ulc, error) {
+	if len(config.TrustedServers) == 0 {
+		return nil, errors.New("no trusted servers")
+	}
+	if config.MinTrustedFraction <= 0 || config.MinTrustedFraction > 100 {
+		return nil, fmt.Errorf("invalid minimum trusted fraction %d%%", config.MinTrustedFraction)
+	}
+	u := &ulc{keys: make(map[enode.ID]struct{})}
+	for _, url := range config.TrustedServers {
+		node, err := enode.Parse(enode.ValidSchemes, url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted server %q: %v", url, err)
+		}
+		if _, ok := u.keys[node.ID()]; ok {
+			continue
+		}
+		u.keys[node.ID()] = struct{}{}
+		u.nodes = append(u.nodes, node)
+	}
+	// round up so that the fraction is always reached
+	u.minTrusted = (len(u.nodes)*config.MinTrustedFraction + 99) / 100
+	return u, nil
+}
+
+// trusted reports whether the given server is in the trusted set.
+func (u *ulc) trusted(id enode.ID) bool {
+	_, ok := u.keys[id]
+	return ok
+}
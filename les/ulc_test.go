@@ -0,0 +1,154 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"crypto/rand"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/light"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func TestNewULC(t *testing.T) {
+	urls := make([]string, 3)
+	for i := range urls {
+		key, _ := crypto.GenerateKey()
+		urls[i] = enode.NewV4(&key.PublicKey, nil, 30303, 30303).String()
+	}
+	tests := []struct {
+		servers    []string
+		fraction   int
+		minTrusted int
+		err        bool
+	}{
+		{servers: urls, fraction: 100, minTrusted: 3},
+		{servers: urls, fraction: 75, minTrusted: 3},
+		{servers: urls, fraction: 50, minTrusted: 2},
+		{servers: urls, fraction: 1, minTrusted: 1},
+		{servers: append(urls[:1:1], urls[0], urls[1]), fraction: 100, minTrusted: 2},
+		{servers: urls, fraction: 0, err: true},
+		{servers: urls, fraction: 101, err: true},
+		{servers: nil, fraction: 75, err: true},
+		{servers: []string{"enode://invalid"}, fraction: 75, err: true},
+	}
+	for i, test := range tests {
+		u, err := newULC(&eth.ULCConfig{TrustedServers: test.servers, MinTrustedFraction: test.fraction})
+		if test.err {
+			if err == nil {
+				t.Errorf("test %d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if u.minTrusted != test.minTrusted {
+			t.Errorf("test %d: minTrusted %d, want %d", i, u.minTrusted, test.minTrusted)
+		}
+		for _, n := range u.nodes {
+			if !u.trusted(n.ID()) {
+				t.Errorf("test %d: server %v not trusted", i, n.ID())
+			}
+		}
+	}
+}
+
+func TestULCSyncWithTwoServersLes2(t *testing.T) {
+	testULCSync(t, lpv2)
+}
+
+func testULCSync(t *testing.T, protocol int) {
+	const blocks = 4
+	var (
+		ldb    = ethdb.NewMemDatabase()
+		lPeers = newPeerSet()
+		dist   = newRequestDistributor(lPeers, make(chan struct{}))
+		rm     = newRetrieveManager(lPeers, dist, nil)
+		odr    = NewLesOdr(ldb, light.TestClientIndexerConfig, rm)
+		lpm    = newTestProtocolManagerMust(t, true, 0, nil, odr, lPeers, ldb)
+		ids    = make([]enode.ID, 2)
+	)
+	for i := range ids {
+		rand.Read(ids[i][:])
+	}
+	lpm.ulc = &ulc{keys: map[enode.ID]struct{}{ids[0]: {}, ids[1]: {}}, minTrusted: 2}
+	chain := lpm.blockchain.(*light.LightChain)
+
+	// Two servers with the same chain, the client has to hear from both
+	servers := make([]*ProtocolManager, len(ids))
+	for i := range servers {
+		servers[i] = newTestProtocolManagerMust(t, false, blocks, testChainGen, nil, nil, ethdb.NewMemDatabase())
+	}
+	serverHead := servers[0].blockchain.CurrentHeader()
+
+	_, err1, _, err2 := newTestPeerPairWithID(ids[0], "server1", protocol, servers[0], lpm)
+	select {
+	case <-time.After(200 * time.Millisecond):
+	case err := <-err1:
+		t.Fatalf("server handshake error: %v", err)
+	case err := <-err2:
+		t.Fatalf("client handshake error: %v", err)
+	}
+	if head := chain.CurrentHeader().Number.Uint64(); head != 0 {
+		t.Fatalf("head %d accepted from a single trusted server", head)
+	}
+
+	_, err1, _, err2 = newTestPeerPairWithID(ids[1], "server2", protocol, servers[1], lpm)
+	if err := waitForHead(chain, blocks, time.Second, err1, err2); err != nil {
+		t.Fatal(err)
+	}
+	head := chain.CurrentHeader()
+	if head.Hash() != serverHead.Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head.Hash(), serverHead.Hash())
+	}
+	if td, want := chain.GetTd(head.Hash(), blocks), servers[0].blockchain.GetTd(serverHead.Hash(), blocks); td == nil || td.Cmp(want) != 0 {
+		t.Fatalf("head td mismatch: have %v, want %v", td, want)
+	}
+	// The header chain below the trusted head is not downloaded
+	for i := uint64(1); i < blocks; i++ {
+		if h := chain.GetHeaderByNumber(i); h != nil {
+			t.Errorf("header #%d downloaded by ultra-light client", i)
+		}
+	}
+}
+
+// waitForHead waits until the head of the chain reaches the given number.
+func waitForHead(chain *light.LightChain, number uint64, timeout time.Duration, errc ...<-chan error) error {
+	deadline := time.After(timeout)
+	for chain.CurrentHeader().Number.Uint64() != number {
+		for _, c := range errc {
+			select {
+			case err := <-c:
+				return fmt.Errorf("peer error: %v", err)
+			default:
+			}
+		}
+		select {
+		case <-deadline:
+			return fmt.Errorf("head %d not reached, have %d", number, chain.CurrentHeader().Number)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return nil
+}
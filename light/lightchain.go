@@ -393,6 +393,31 @@ func (self *LightChain) InsertHeaderChain(chain []*types.Header, checkFreq int)
 	return i, err
 }
 
+// SetTrustedHead makes a header the new head of the canonical chain without
+// validating it or requiring its ancestors to be known. It is used by ultra-light
+// clients which accept the heads announced by a set of trusted servers instead
+// of downloading the header chain. The total difficulty of the header is taken
+// from the announcements.
+func (self *LightChain) SetTrustedHead(header *types.Header, td *big.Int) {
+	self.chainmu.Lock()
+	defer self.chainmu.Unlock()
+
+	self.mu.Lock()
+	hash, number := header.Hash(), header.Number.Uint64()
+	rawdb.WriteTd(self.chainDb, hash, number, td)
+	rawdb.WriteHeader(self.chainDb, header)
+	rawdb.WriteCanonicalHash(self.chainDb, hash, number)
+	// Drop the canonical mappings of a previous head above the new one
+	for i := number + 1; rawdb.ReadCanonicalHash(self.chainDb, i) != (common.Hash{}); i++ {
+		rawdb.DeleteCanonicalHash(self.chainDb, i)
+	}
+	self.hc.SetCurrentHeader(header)
+	self.mu.Unlock()
+
+	log.Debug("Set trusted head", "number", number, "hash", hash, "td", td)
+	self.postChainEvents([]interface{}{core.ChainEvent{Block: types.NewBlockWithHeader(header), Hash: hash}})
+}
+
 // CurrentHeader retrieves the current head header of the canonical chain. The
 // header is retrieved from the HeaderChain's internal cache.
 func (self *LightChain) CurrentHeader() *types.Header {
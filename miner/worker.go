@@ -164,6 +164,9 @@ type worker struct {
 	snapshotBlock *types.Block
 	snapshotState *state.StateDB
 
+	pendingLogsNumber uint64                   // Number of the pending block whose logs were last announced
+	pendingLogsSent   map[common.Hash]struct{} // Transactions whose pending logs were already announced
+
 	// atomic status counters
 	running int32 // The indicator whether the consensus engine is running or not.
 	newTxs  int32 // New arrival transaction count since last sealing work submitting.
@@ -792,20 +795,36 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 		}
 	}
 
-	if !w.isRunning() && len(coalescedLogs) > 0 {
-		// We don't push the pendingLogsEvent while we are mining. The reason is that
-		// when we are mining, the worker will regenerate a mining block every 3 seconds.
-		// In order to avoid pushing the repeated pendingLog, we disable the pending log pushing.
-
+	if len(coalescedLogs) > 0 {
+		// When we are mining, the worker regenerates the mining block every few seconds,
+		// executing the same transactions again. In order to avoid pushing repeated
+		// pending logs, only announce the logs of transactions that weren't announced
+		// yet for the current pending block number.
+		if number := w.current.header.Number.Uint64(); w.pendingLogsSent == nil || w.pendingLogsNumber != number {
+			w.pendingLogsNumber, w.pendingLogsSent = number, make(map[common.Hash]struct{})
+		}
 		// make a copy, the state caches the logs and these logs get "upgraded" from pending to mined
 		// logs by filling in the block hash when the block was mined by the local miner. This can
 		// cause a race condition if a log was "upgraded" before the PendingLogsEvent is processed.
-		cpy := make([]*types.Log, len(coalescedLogs))
-		for i, l := range coalescedLogs {
-			cpy[i] = new(types.Log)
-			*cpy[i] = *l
+		var (
+			cpy       []*types.Log
+			announced = make(map[common.Hash]struct{})
+		)
+		for _, l := range coalescedLogs {
+			if _, ok := w.pendingLogsSent[l.TxHash]; ok {
+				continue
+			}
+			announced[l.TxHash] = struct{}{}
+
+			logcopy := *l
+			cpy = append(cpy, &logcopy)
+		}
+		for hash := range announced {
+			w.pendingLogsSent[hash] = struct{}{}
+		}
+		if len(cpy) > 0 {
+			go w.mux.Post(core.PendingLogsEvent{Logs: cpy})
 		}
-		go w.mux.Post(core.PendingLogsEvent{Logs: cpy})
 	}
 	// Notify resubmit loop to decrease resubmitting interval if current interval is larger
 	// than the user-specified one.
@@ -452,3 +452,77 @@ func testAdjustInterval(t *testing.T, chainConfig *params.ChainConfig, engine co
 		t.Error("interval reset timeout")
 	}
 }
+
+func TestPendingLogsOnce(t *testing.T) {
+	engine := ethash.NewFaker()
+	defer engine.Close()
+
+	w, b := newTestWorker(t, ethashChainConfig, engine, 0)
+	defer w.close()
+
+	sub := w.mux.Subscribe(core.PendingLogsEvent{})
+	defer sub.Unsubscribe()
+
+	var taskCh = make(chan int, 16)
+	w.newTaskHook = func(task *task) {
+		if task.block.NumberU64() == 1 {
+			taskCh <- len(task.receipts)
+		}
+	}
+	w.skipSealHook = func(task *task) bool {
+		return true
+	}
+	// Ensure worker has finished initialization
+	for {
+		b := w.pendingBlock()
+		if b != nil && b.NumberU64() == 1 {
+			break
+		}
+	}
+	// Add two log emitting contract creations, one before and one after the
+	// worker started, then a plain transfer to force yet another regeneration
+	// of the pending block re-executing both of them
+	var logTxs []*types.Transaction
+	for nonce := uint64(1); nonce <= 2; nonce++ {
+		tx, _ := types.SignTx(types.NewContractCreation(nonce, new(big.Int), 100000, nil, common.FromHex("0x60006000a0")), types.HomesteadSigner{}, testBankKey)
+		logTxs = append(logTxs, tx)
+	}
+	transfer, _ := types.SignTx(types.NewTransaction(3, testUserAddress, big.NewInt(1000), params.TxGas, nil, nil), types.HomesteadSigner{}, testBankKey)
+
+	b.txPool.AddLocals(logTxs[:1])
+	w.start()
+	for i, txs := range [][]*types.Transaction{nil, logTxs[1:], {transfer}} {
+		b.txPool.AddLocals(txs)
+
+		// Wait for the pending block including all transactions added so far
+		timeout := time.NewTimer(3 * time.Second)
+		for receipts := 0; receipts != len(pendingTxs)+1+i; {
+			select {
+			case receipts = <-taskCh:
+			case <-timeout.C:
+				t.Fatalf("round %d: new task timeout", i)
+			}
+		}
+	}
+	// Every transaction's logs must have been announced exactly once
+	announced := make(map[common.Hash]int)
+	for {
+		select {
+		case ev := <-sub.Chan():
+			for _, log := range ev.Data.(core.PendingLogsEvent).Logs {
+				announced[log.TxHash]++
+			}
+			continue
+		case <-time.After(100 * time.Millisecond):
+		}
+		break
+	}
+	if len(announced) != len(logTxs) {
+		t.Errorf("announced transaction count mismatch: have %d, want %d", len(announced), len(logTxs))
+	}
+	for i, tx := range logTxs {
+		if n := announced[tx.Hash()]; n != 1 {
+			t.Errorf("tx %d: pending logs announced %d times, want 1", i, n)
+		}
+	}
+}
@@ -20,6 +20,7 @@ package geth
 
 import (
 	"math/big"
+	"reflect"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -106,6 +107,7 @@ func (opts *TransactOpts) SetContext(context *Context) { opts.opts.Context = con
 // Ethereum network. It contains a collection of methods that are used by the
 // higher level contract bindings to operate.
 type BoundContract struct {
+	abi      abi.ABI
 	contract *bind.BoundContract
 	address  common.Address
 	deployer *types.Transaction
@@ -119,11 +121,16 @@ func DeployContract(opts *TransactOpts, abiJSON string, bytecode []byte, client
 	if err != nil {
 		return nil, err
 	}
-	addr, tx, bound, err := bind.DeployContract(&opts.opts, parsed, common.CopyBytes(bytecode), client.client, args.objects...)
+	params, err := unwrapArgs(parsed.Constructor.Inputs, args.objects)
+	if err != nil {
+		return nil, err
+	}
+	addr, tx, bound, err := bind.DeployContract(&opts.opts, parsed, common.CopyBytes(bytecode), client.client, params...)
 	if err != nil {
 		return nil, err
 	}
 	return &BoundContract{
+		abi:      parsed,
 		contract: bound,
 		address:  addr,
 		deployer: tx,
@@ -138,6 +145,7 @@ func BindContract(address *Address, abiJSON string, client *EthereumClient) (con
 		return nil, err
 	}
 	return &BoundContract{
+		abi:      parsed,
 		contract: bind.NewBoundContract(address.address, parsed, client.client, client.client, client.client),
 		address:  address.address,
 	}, nil
@@ -154,26 +162,32 @@ func (c *BoundContract) GetDeployer() *Transaction {
 // Call invokes the (constant) contract method with params as input values and
 // sets the output to result.
 func (c *BoundContract) Call(opts *CallOpts, out *Interfaces, method string, args *Interfaces) error {
-	if len(out.objects) == 1 {
-		result := out.objects[0]
-		if err := c.contract.Call(&opts.opts, result, method, args.objects...); err != nil {
+	params, err := unwrapArgs(c.abi.Methods[method].Inputs, args.objects)
+	if err != nil {
+		return err
+	}
+	outputs := c.abi.Methods[method].Outputs
+	results := makeResults(outputs, out.objects)
+	if len(results) == 1 {
+		if err := c.contract.Call(&opts.opts, results[0], method, params...); err != nil {
 			return err
 		}
-		out.objects[0] = result
 	} else {
-		results := make([]interface{}, len(out.objects))
-		copy(results, out.objects)
-		if err := c.contract.Call(&opts.opts, &results, method, args.objects...); err != nil {
+		if err := c.contract.Call(&opts.opts, &results, method, params...); err != nil {
 			return err
 		}
-		copy(out.objects, results)
 	}
+	wrapResults(outputs, results, out.objects)
 	return nil
 }
 
 // Transact invokes the (paid) contract method with params as input values.
 func (c *BoundContract) Transact(opts *TransactOpts, method string, args *Interfaces) (tx *Transaction, _ error) {
-	rawTx, err := c.contract.Transact(&opts.opts, method, args.objects...)
+	params, err := unwrapArgs(c.abi.Methods[method].Inputs, args.objects)
+	if err != nil {
+		return nil, err
+	}
+	rawTx, err := c.contract.Transact(&opts.opts, method, params...)
 	if err != nil {
 		return nil, err
 	}
@@ -189,3 +203,44 @@ func (c *BoundContract) Transfer(opts *TransactOpts) (tx *Transaction, _ error)
 	}
 	return &Transaction{rawTx}, nil
 }
+
+// unwrapArgs converts the wrapped tuples among the arguments of a contract method
+// into the Go values the abi package packs, passing all others through as is.
+func unwrapArgs(inputs abi.Arguments, objects []interface{}) ([]interface{}, error) {
+	params := make([]interface{}, len(objects))
+	copy(params, objects)
+	for i, input := range inputs {
+		if i < len(params) && hasTuples(input.Type) {
+			val, err := unwrap(input.Type, params[i])
+			if err != nil {
+				return nil, err
+			}
+			params[i] = val.Interface()
+		}
+	}
+	return params, nil
+}
+
+// makeResults creates the values a contract method call is unpacked into, which
+// are the objects set by the caller, apart from tuples decoded into Go structs.
+func makeResults(outputs abi.Arguments, objects []interface{}) []interface{} {
+	results := make([]interface{}, len(objects))
+	copy(results, objects)
+	for i, output := range outputs {
+		if i < len(results) && hasTuples(output.Type) {
+			results[i] = reflect.New(output.Type.Type).Interface()
+		}
+	}
+	return results
+}
+
+// wrapResults stores the unpacked results of a contract method call into the
+// objects of the caller, wrapping tuples decoded into Go structs.
+func wrapResults(outputs abi.Arguments, results []interface{}, objects []interface{}) {
+	copy(objects, results)
+	for i, output := range outputs {
+		if i < len(objects) && hasTuples(output.Type) {
+			objects[i] = wrap(output.Type, reflect.ValueOf(results[i]).Elem())
+		}
+	}
+}
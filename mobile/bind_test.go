@@ -0,0 +1,112 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package geth
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// tupleABI is a method echoing nested tuples, fixed size byte arrays among their
+// fields and fixed size arrays of them.
+const tupleABI = `[{"type":"function","name":"echo","constant":true,
+	"inputs":[
+		{"name":"s","type":"tuple","components":[{"name":"a","type":"uint256"},{"name":"h","type":"bytes32"},{"name":"c","type":"tuple[]","components":[{"name":"x","type":"uint8"},{"name":"y","type":"address"}]}]},
+		{"name":"t","type":"tuple[2][]","components":[{"name":"x","type":"uint8"},{"name":"y","type":"address"}]}
+	],
+	"outputs":[
+		{"name":"s","type":"tuple","components":[{"name":"a","type":"uint256"},{"name":"h","type":"bytes32"},{"name":"c","type":"tuple[]","components":[{"name":"x","type":"uint8"},{"name":"y","type":"address"}]}]},
+		{"name":"t","type":"tuple[2][]","components":[{"name":"x","type":"uint8"},{"name":"y","type":"address"}]}
+	]
+}]`
+
+// Tests that tuples wrapped into Interfaces are converted to and from the values
+// packed and unpacked by the abi package.
+func TestTupleWrapping(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(tupleABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	method := parsed.Methods["echo"]
+
+	// Assemble the arguments the way the Java bindings do
+	wrapFields := func(setters ...func(*Interface)) *Interfaces {
+		fields := NewInterfaces(len(setters))
+		for i, set := range setters {
+			field := NewInterface()
+			set(field)
+			fields.Set(i, field)
+		}
+		return fields
+	}
+	newT := func(x int64, y byte) func(*Interface) {
+		return func(iface *Interface) {
+			iface.SetTuple(wrapFields(
+				func(iface *Interface) { iface.SetUint8(NewBigInt(x)) },
+				func(iface *Interface) { iface.SetAddress(&Address{common.Address{y}}) },
+			))
+		}
+	}
+	newTs := func(items ...func(*Interface)) func(*Interface) {
+		return func(iface *Interface) { iface.SetTuples(wrapFields(items...)) }
+	}
+	newS := func(iface *Interface) {
+		iface.SetTuple(wrapFields(
+			func(iface *Interface) { iface.SetBigInt(NewBigInt(1)) },
+			func(iface *Interface) { iface.SetBinary(common.Hash{1}.Bytes()) },
+			newTs(newT(2, 3)),
+		))
+	}
+	args := wrapFields(newS, newTs(newTs(newT(4, 5), newT(6, 7))))
+	params, err := unwrapArgs(method.Inputs, args.objects)
+	if err != nil {
+		t.Fatalf("failed to unwrap arguments: %v", err)
+	}
+	packed, err := method.Inputs.Pack(params...)
+	if err != nil {
+		t.Fatalf("failed to pack arguments: %v", err)
+	}
+	// Unpack the echoed arguments as results and ensure they are wrapped back
+	out := wrapFields(
+		func(iface *Interface) { iface.SetDefaultTuple() },
+		func(iface *Interface) { iface.SetDefaultTuples() },
+	)
+	results := makeResults(method.Outputs, out.objects)
+	if err := parsed.Unpack(&results, "echo", packed); err != nil {
+		t.Fatalf("failed to unpack results: %v", err)
+	}
+	wrapResults(method.Outputs, results, out.objects)
+
+	if !reflect.DeepEqual(out.objects, args.objects) {
+		t.Errorf("results mismatch: have %v, want %v", out.objects, args.objects)
+	}
+	s, _ := out.Get(0)
+	c, _ := s.GetTuple().Get(2)
+	item, _ := c.GetTuples().Get(0)
+	if x, _ := item.GetTuple().Get(0); x.GetUint8().GetInt64() != 2 {
+		t.Errorf("nested tuple field mismatch: have %d, want 2", x.GetUint8().GetInt64())
+	}
+	// Ensure fixed size arrays of tuples are length checked
+	short := wrapFields(newS, newTs(newTs(newT(4, 5))))
+	if _, err := unwrapArgs(method.Inputs, short.objects); err == nil {
+		t.Errorf("short tuple array unwrapped")
+	}
+}
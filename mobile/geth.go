@@ -71,6 +71,15 @@ type NodeConfig struct {
 	// It has the form "nodename:secret@host:port"
 	EthereumNetStats string
 
+	// UltraLightServers is the list of trusted LES servers. If set, the node runs
+	// as an ultra-light client that follows the heads announced by these servers
+	// instead of downloading and verifying the header chain.
+	UltraLightServers *Enodes
+
+	// UltraLightFraction is the minimum percentage of trusted servers that must
+	// announce a head before the ultra-light client accepts it.
+	UltraLightFraction int
+
 	// WhisperEnabled specifies whether the node should run the Whisper protocol.
 	WhisperEnabled bool
 
@@ -86,6 +95,7 @@ var defaultNodeConfig = &NodeConfig{
 	EthereumEnabled:       true,
 	EthereumNetworkID:     1,
 	EthereumDatabaseCache: 16,
+	UltraLightFraction:    eth.DefaultULCMinTrustedFraction,
 }
 
 // NewNodeConfig creates a new node option set, initialized to the default values.
@@ -111,6 +121,9 @@ func NewNode(datadir string, config *NodeConfig) (stack *Node, _ error) {
 	if config.BootstrapNodes == nil || config.BootstrapNodes.Size() == 0 {
 		config.BootstrapNodes = defaultNodeConfig.BootstrapNodes
 	}
+	if config.UltraLightFraction == 0 {
+		config.UltraLightFraction = defaultNodeConfig.UltraLightFraction
+	}
 
 	if config.PprofAddress != "" {
 		debug.StartPProf(config.PprofAddress)
@@ -160,6 +173,12 @@ func NewNode(datadir string, config *NodeConfig) (stack *Node, _ error) {
 		ethConf.SyncMode = downloader.LightSync
 		ethConf.NetworkId = uint64(config.EthereumNetworkID)
 		ethConf.DatabaseCache = config.EthereumDatabaseCache
+		if config.UltraLightServers != nil && config.UltraLightServers.Size() > 0 {
+			ethConf.ULC = &eth.ULCConfig{MinTrustedFraction: config.UltraLightFraction}
+			for _, n := range config.UltraLightServers.nodes {
+				ethConf.ULC.TrustedServers = append(ethConf.ULC.TrustedServers, n.String())
+			}
+		}
 		if err := rawStack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
 			return les.New(ctx, &ethConf)
 		}); err != nil {
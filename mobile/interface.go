@@ -20,8 +20,11 @@ package geth
 
 import (
 	"errors"
+	"fmt"
 	"math/big"
+	"reflect"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -62,6 +65,8 @@ func (i *Interface) SetUint32(bigint *BigInt)      { n := uint32(bigint.bigint.U
 func (i *Interface) SetUint64(bigint *BigInt)      { n := bigint.bigint.Uint64(); i.object = &n }
 func (i *Interface) SetBigInt(bigint *BigInt)      { i.object = &bigint.bigint }
 func (i *Interface) SetBigInts(bigints *BigInts)   { i.object = &bigints.bigints }
+func (i *Interface) SetTuple(fields *Interfaces)   { i.object = &tuple{fields.objects} }
+func (i *Interface) SetTuples(items *Interfaces)   { i.object = &tuples{items.objects} }
 
 func (i *Interface) SetDefaultBool()      { i.object = new(bool) }
 func (i *Interface) SetDefaultBools()     { i.object = new([]bool) }
@@ -83,6 +88,8 @@ func (i *Interface) SetDefaultUint32()    { i.object = new(uint32) }
 func (i *Interface) SetDefaultUint64()    { i.object = new(uint64) }
 func (i *Interface) SetDefaultBigInt()    { i.object = new(*big.Int) }
 func (i *Interface) SetDefaultBigInts()   { i.object = new([]*big.Int) }
+func (i *Interface) SetDefaultTuple()     { i.object = new(tuple) }
+func (i *Interface) SetDefaultTuples()    { i.object = new(tuples) }
 
 func (i *Interface) GetBool() bool            { return *i.object.(*bool) }
 func (i *Interface) GetBools() []bool         { return *i.object.(*[]bool) }
@@ -110,8 +117,10 @@ func (i *Interface) GetUint32() *BigInt {
 func (i *Interface) GetUint64() *BigInt {
 	return &BigInt{new(big.Int).SetUint64(*i.object.(*uint64))}
 }
-func (i *Interface) GetBigInt() *BigInt   { return &BigInt{*i.object.(**big.Int)} }
-func (i *Interface) GetBigInts() *BigInts { return &BigInts{*i.object.(*[]*big.Int)} }
+func (i *Interface) GetBigInt() *BigInt     { return &BigInt{*i.object.(**big.Int)} }
+func (i *Interface) GetBigInts() *BigInts   { return &BigInts{*i.object.(*[]*big.Int)} }
+func (i *Interface) GetTuple() *Interfaces  { return &Interfaces{i.object.(*tuple).fields} }
+func (i *Interface) GetTuples() *Interfaces { return &Interfaces{i.object.(*tuples).items} }
 
 // Interfaces is a slices of wrapped generic objects.
 type Interfaces struct {
@@ -146,3 +155,151 @@ func (i *Interfaces) Set(index int, object *Interface) error {
 	i.objects[index] = object.object
 	return nil
 }
+
+// tuple is the wrapped form of a Solidity tuple, holding the wrapped objects of
+// its fields.
+type tuple struct {
+	fields []interface{}
+}
+
+// tuples is the wrapped form of an array of Solidity tuples (or of arrays of them),
+// holding the wrapped objects of its items.
+type tuples struct {
+	items []interface{}
+}
+
+// hasTuples reports whether the values of an ABI type are wrapped into tuples.
+func hasTuples(kind abi.Type) bool {
+	switch kind.T {
+	case abi.TupleTy:
+		return true
+	case abi.SliceTy, abi.ArrayTy:
+		return hasTuples(*kind.Elem)
+	}
+	return false
+}
+
+// unwrap converts a wrapped object into a value of the Go type the abi package
+// uses for an ABI type, turning tuples into structs and the slices stored by the
+// setters into fixed size arrays where needed.
+func unwrap(kind abi.Type, object interface{}) (reflect.Value, error) {
+	switch {
+	case kind.T == abi.TupleTy:
+		t, ok := object.(*tuple)
+		if !ok || len(t.fields) != len(kind.TupleElems) {
+			return reflect.Value{}, fmt.Errorf("cannot use %T as %v", object, kind)
+		}
+		val := reflect.New(kind.Type).Elem()
+		for i, elem := range kind.TupleElems {
+			field, err := unwrap(*elem, t.fields[i])
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			val.Field(i).Set(field)
+		}
+		return val, nil
+
+	case hasTuples(kind):
+		t, ok := object.(*tuples)
+		if !ok || (kind.T == abi.ArrayTy && len(t.items) != kind.Size) {
+			return reflect.Value{}, fmt.Errorf("cannot use %T as %v", object, kind)
+		}
+		val := reflect.New(kind.Type).Elem()
+		if kind.T == abi.SliceTy {
+			val = reflect.MakeSlice(kind.Type, len(t.items), len(t.items))
+		}
+		for i, item := range t.items {
+			elem, err := unwrap(*kind.Elem, item)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			val.Index(i).Set(elem)
+		}
+		return val, nil
+	}
+	ptr := reflect.ValueOf(object)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return reflect.Value{}, fmt.Errorf("cannot use %T as %v", object, kind)
+	}
+	return unflatten(ptr.Elem(), kind.Type)
+}
+
+// unflatten converts a value stored by the Interface setters into a Go type,
+// turning slices into fixed size arrays where needed.
+func unflatten(val reflect.Value, typ reflect.Type) (reflect.Value, error) {
+	if val.Type().AssignableTo(typ) {
+		return val, nil
+	}
+	if val.Kind() != reflect.Slice || (typ.Kind() != reflect.Slice && typ.Kind() != reflect.Array) {
+		return reflect.Value{}, fmt.Errorf("cannot use %v as %v", val.Type(), typ)
+	}
+	out := reflect.MakeSlice(reflect.SliceOf(typ.Elem()), val.Len(), val.Len())
+	if typ.Kind() == reflect.Array {
+		if val.Len() != typ.Len() {
+			return reflect.Value{}, fmt.Errorf("cannot use %d items as %v", val.Len(), typ)
+		}
+		out = reflect.New(typ).Elem()
+	}
+	for i := 0; i < val.Len(); i++ {
+		elem, err := unflatten(val.Index(i), typ.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out.Index(i).Set(elem)
+	}
+	return out, nil
+}
+
+// wrap converts a value decoded by the abi package into a wrapped object, turning
+// structs into tuples and fixed size arrays into the slices the getters expect.
+func wrap(kind abi.Type, val reflect.Value) interface{} {
+	switch {
+	case kind.T == abi.TupleTy:
+		fields := make([]interface{}, len(kind.TupleElems))
+		for i, elem := range kind.TupleElems {
+			fields[i] = wrap(*elem, val.Field(i))
+		}
+		return &tuple{fields}
+
+	case hasTuples(kind):
+		items := make([]interface{}, val.Len())
+		for i := range items {
+			items[i] = wrap(*kind.Elem, val.Index(i))
+		}
+		return &tuples{items}
+	}
+	flat := flatten(kind, val)
+	ptr := reflect.New(flat.Type())
+	ptr.Elem().Set(flat)
+	return ptr.Interface()
+}
+
+// flatten converts the fixed size arrays within a value decoded by the abi package
+// into slices, byte arrays included.
+func flatten(kind abi.Type, val reflect.Value) reflect.Value {
+	switch kind.T {
+	case abi.FixedBytesTy:
+		flat := make([]byte, val.Len())
+		reflect.Copy(reflect.ValueOf(flat), val)
+		return reflect.ValueOf(flat)
+
+	case abi.SliceTy, abi.ArrayTy:
+		flat := reflect.MakeSlice(flatType(kind), val.Len(), val.Len())
+		for i := 0; i < val.Len(); i++ {
+			flat.Index(i).Set(flatten(*kind.Elem, val.Index(i)))
+		}
+		return flat
+	}
+	return val
+}
+
+// flatType returns the Go type of the values of an ABI type stored by the setters.
+func flatType(kind abi.Type) reflect.Type {
+	switch kind.T {
+	case abi.FixedBytesTy:
+		return reflect.TypeOf([]byte(nil))
+	case abi.SliceTy, abi.ArrayTy:
+		return reflect.SliceOf(flatType(*kind.Elem))
+	}
+	return kind.Type
+}
@@ -22,7 +22,7 @@ import (
 	"encoding/json"
 
 	"github.com/ethereum/go-ethereum/core"
-	"github.com/ethereum/go-ethereum/p2p/discv5"
+	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -51,11 +51,16 @@ func RinkebyGenesis() string {
 }
 
 // FoundationBootnodes returns the enode URLs of the P2P bootstrap nodes operated
-// by the foundation running the V5 discovery protocol.
+// by the foundation running the V5 discovery protocol. If there are no dedicated
+// V5 bootnodes, the mainnet ones are used, which run V5 on the same port.
 func FoundationBootnodes() *Enodes {
-	nodes := &Enodes{nodes: make([]*discv5.Node, len(params.DiscoveryV5Bootnodes))}
-	for i, url := range params.DiscoveryV5Bootnodes {
-		nodes.nodes[i] = discv5.MustParseNode(url)
+	urls := params.DiscoveryV5Bootnodes
+	if len(urls) == 0 {
+		urls = params.MainnetBootnodes
+	}
+	nodes := &Enodes{nodes: make([]*enode.Node, len(urls))}
+	for i, url := range urls {
+		nodes.nodes[i] = enode.MustParseV4(url)
 	}
 	return nodes
 }
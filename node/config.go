@@ -27,7 +27,10 @@ import (
 	"sync"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/external"
+	"github.com/ethereum/go-ethereum/accounts/hdwallet"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/accounts/multisig"
 	"github.com/ethereum/go-ethereum/accounts/usbwallet"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -43,6 +46,7 @@ const (
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+	datadirMultisig        = "multisig.json"      // Path within the datadir to the multisig wallets and sessions
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -80,13 +84,22 @@ type Config struct {
 	// is created by New and destroyed when the node is stopped.
 	KeyStoreDir string `toml:",omitempty"`
 
+	// KeyStoreKDF selects the key derivation function used to encrypt new keys,
+	// either "scrypt" (the default if empty) or "argon2id".
+	KeyStoreKDF string `toml:",omitempty"`
+
 	// UseLightweightKDF lowers the memory and CPU requirements of the key store
-	// scrypt KDF at the expense of security.
+	// KDF at the expense of security.
 	UseLightweightKDF bool `toml:",omitempty"`
 
 	// NoUSB disables hardware wallet monitoring and connectivity.
 	NoUSB bool `toml:",omitempty"`
 
+	// ExternalSigner is the endpoint (IPC path or HTTP/WebSocket URL) of an
+	// external signer, such as clef. If set, all signing is delegated to it and
+	// no local keystore or hardware wallets are used.
+	ExternalSigner string `toml:",omitempty"`
+
 	// IPCPath is the requested location to place the IPC endpoint. If the path is
 	// a simple file name, it is placed inside the data directory (or on the root
 	// pipe path on Windows), whereas if it's a resolvable path name (absolute or
@@ -394,7 +407,24 @@ func (c *Config) AccountConfig() (int, int, string, error) {
 		scryptN = keystore.LightScryptN
 		scryptP = keystore.LightScryptP
 	}
+	keydir, err := c.keyStoreDir()
+	return scryptN, scryptP, keydir, err
+}
 
+// KeyStoreConfig determines the settings for the key derivation function and
+// keydirectory
+func (c *Config) KeyStoreConfig() (keystore.KDFParams, string, error) {
+	kdf, err := keystore.StandardKDF(c.KeyStoreKDF, c.UseLightweightKDF)
+	if err != nil {
+		return kdf, "", err
+	}
+	keydir, err := c.keyStoreDir()
+	return kdf, keydir, err
+}
+
+// keyStoreDir determines the absolute path of the key directory, or an empty
+// string if there is no datadir.
+func (c *Config) keyStoreDir() (string, error) {
 	var (
 		keydir string
 		err    error
@@ -411,11 +441,14 @@ func (c *Config) AccountConfig() (int, int, string, error) {
 	case c.KeyStoreDir != "":
 		keydir, err = filepath.Abs(c.KeyStoreDir)
 	}
-	return scryptN, scryptP, keydir, err
+	return keydir, err
 }
 
 func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
-	scryptN, scryptP, keydir, err := conf.AccountConfig()
+	kdf, keydir, err := conf.KeyStoreConfig()
+	if err != nil {
+		return nil, "", err
+	}
 	var ephemeral string
 	if keydir == "" {
 		// There is no datadir.
@@ -430,10 +463,26 @@ func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
 		return nil, "", err
 	}
 	// Assemble the account manager and supported backends
-	backends := []accounts.Backend{
-		keystore.NewKeyStore(keydir, scryptN, scryptP),
+	var backends []accounts.Backend
+	if conf.ExternalSigner != "" {
+		// Delegate all signing to the external signer, holding no keys locally
+		log.Info("Using external signer", "url", conf.ExternalSigner)
+		extapi, err := external.NewBackend(conf.ExternalSigner)
+		if err != nil {
+			return nil, "", fmt.Errorf("error connecting to external signer: %v", err)
+		}
+		backends = append(backends, extapi)
+	} else {
+		backends = append(backends, keystore.NewKeyStoreWithKDF(keydir, kdf), hdwallet.NewBackend(keydir, kdf))
 	}
-	if !conf.NoUSB {
+	// Track multisig wallets, persisting them along the node data if there is any
+	multisigs, err := multisig.NewBackend(conf.ResolvePath(datadirMultisig))
+	if err != nil {
+		return nil, "", err
+	}
+	backends = append(backends, multisigs)
+
+	if !conf.NoUSB && conf.ExternalSigner == "" {
 		// Start a USB hub for Ledger hardware wallets
 		if ledgerhub, err := usbwallet.NewLedgerHub(); err != nil {
 			log.Warn(fmt.Sprintf("Failed to start Ledger hub, disabling: %v", err))
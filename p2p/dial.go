@@ -18,12 +18,18 @@ package p2p
 
 import (
 	"container/heap"
+	crand "crypto/rand"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	mrand "math/rand"
 	"net"
+	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
 )
@@ -33,9 +39,9 @@ const (
 	// redialing a certain node.
 	dialHistoryExpiration = 30 * time.Second
 
-	// Discovery lookups are throttled and can only run
-	// once every few seconds.
-	lookupInterval = 4 * time.Second
+	// Failed dynamic dials are retried with exponential backoff,
+	// starting at dialHistoryExpiration.
+	maxDialBackoff = 30 * time.Minute
 
 	// If no peers are found for this amount of time, the initial bootnodes are
 	// attempted to be connected.
@@ -44,6 +50,10 @@ const (
 	// Endpoint resolution is throttled with bounded backoff.
 	initialResolveDelay = 60 * time.Second
 	maxResolveDelay     = time.Hour
+
+	// Dynamically dialed peers are spread across IP subnets: at most
+	// dialSubnetLimit of them may share a /dialSubnet network.
+	dialSubnet, dialSubnetLimit = 24, 2
 )
 
 // NodeDialer is used to connect to nodes in the network, typically by using
@@ -64,246 +74,611 @@ func (t TCPDialer) Dial(dest *enode.Node) (net.Conn, error) {
 	return t.Dialer.Dial("tcp", addr.String())
 }
 
-// dialstate schedules dials and discovery lookups.
-// it get's a chance to compute new tasks on every iteration
-// of the main loop in Server.run.
-type dialstate struct {
-	maxDynDials int
-	ntab        discoverTable
-	netrestrict *netutil.Netlist
-	self        enode.ID
-
-	lookupRunning bool
-	dialing       map[enode.ID]connFlag
-	lookupBuf     []*enode.Node // current discovery lookup results
-	randomNodes   []*enode.Node // filled from Table
-	static        map[enode.ID]*dialTask
-	hist          *dialHistory
-
-	start     time.Time     // time when the dialer was first used
-	bootnodes []*enode.Node // default dials when there are no peers
-}
-
+// discoverTable is the discovery interface used by Server.
 type discoverTable interface {
 	Close()
 	Resolve(*enode.Node) *enode.Node
-	LookupRandom() []*enode.Node
-	ReadRandomNodes([]*enode.Node) int
+	RandomNodes() enode.Iterator
 }
 
-// the dial history remembers recent dials.
-type dialHistory []pastDial
+// nodeResolver finds the current endpoint of static nodes.
+type nodeResolver interface {
+	Resolve(*enode.Node) *enode.Node
+}
 
-// pastDial is an entry in the dial history.
-type pastDial struct {
-	id  enode.ID
-	exp time.Time
+var (
+	errSelf             = errors.New("is self")
+	errAlreadyDialing   = errors.New("already dialing")
+	errAlreadyConnected = errors.New("already connected")
+	errRecentlyDialed   = errors.New("recently dialed")
+	errNotWhitelisted   = errors.New("not contained in netrestrict whitelist")
+	errNoPort           = errors.New("node does not provide TCP endpoint")
+	errNetLimit         = errors.New("too many dialed peers in subnet")
+	errUnresolved       = errors.New("could not resolve node endpoint")
+)
+
+// dialSetupFunc runs the handshakes on a dialed connection.
+type dialSetupFunc func(net.Conn, connFlag, *enode.Node) error
+
+// dialConfig holds the settings of the dial scheduler.
+type dialConfig struct {
+	self           enode.ID         // our own ID
+	maxDialPeers   int              // maximum number of dynamically dialed peers
+	maxActiveDials int              // maximum number of active dials
+	sourceQuota    int              // maximum number of active dials per source
+	netRestrict    *netutil.Netlist // IP whitelist, disabled if nil
+	resolver       nodeResolver     // endpoint resolver for static nodes, may be nil
+	dialer         NodeDialer
+	bootnodes      []*enode.Node // dialed when there are no peers at all
+	log            log.Logger
+	clock          mclock.Clock
+	rand           *mrand.Rand
+}
+
+func (cfg dialConfig) withDefaults() dialConfig {
+	if cfg.maxActiveDials == 0 {
+		cfg.maxActiveDials = maxActiveDialTasks
+	}
+	if cfg.sourceQuota == 0 {
+		cfg.sourceQuota = (cfg.maxActiveDials + 1) / 2
+	}
+	if cfg.log == nil {
+		cfg.log = log.Root()
+	}
+	if cfg.clock == nil {
+		cfg.clock = mclock.System{}
+	}
+	if cfg.rand == nil {
+		var seed [8]byte
+		crand.Read(seed[:])
+		cfg.rand = mrand.New(mrand.NewSource(int64(binary.BigEndian.Uint64(seed[:]))))
+	}
+	return cfg
 }
 
-type task interface {
-	Do(*Server)
+// dialSource is a named iterator of dynamic dial candidates.
+type dialSource struct {
+	name  string
+	it    enode.Iterator
+	slots chan struct{} // one token per free dial slot of the source
+	meter metrics.Meter
 }
 
-// A dialTask is generated for each node that is dialed. Its
-// fields cannot be accessed while the task is running.
-type dialTask struct {
-	flags        connFlag
-	dest         *enode.Node
-	lastResolved time.Time
-	resolveDelay time.Duration
+func newDialSource(name string, it enode.Iterator) *dialSource {
+	return &dialSource{
+		name:  name,
+		it:    it,
+		meter: metrics.GetOrRegisterMeter(MetricsDials+"/"+name, nil),
+	}
 }
 
-// discoverTask runs discovery table operations.
-// Only one discoverTask is active at any time.
-// discoverTask.Do performs a random lookup.
-type discoverTask struct {
-	results []*enode.Node
+// release returns a dial slot to the source.
+func (s *dialSource) release() {
+	s.slots <- struct{}{}
 }
 
-// A waitExpireTask is generated if there are no other tasks
-// to keep the loop in Server.run ticking.
-type waitExpireTask struct {
-	time.Duration
+// dialCandidate is a node read from a dial source.
+type dialCandidate struct {
+	src  *dialSource
+	node *enode.Node
 }
 
-func newDialState(self enode.ID, static []*enode.Node, bootnodes []*enode.Node, ntab discoverTable, maxdyn int, netrestrict *netutil.Netlist) *dialstate {
-	s := &dialstate{
-		maxDynDials: maxdyn,
-		ntab:        ntab,
-		self:        self,
-		netrestrict: netrestrict,
+// dialFailure tracks consecutive failed dials of a node.
+type dialFailure struct {
+	count  int
+	forget mclock.AbsTime // when the failures are forgotten
+}
+
+// dialScheduler creates outbound connections and submits them into Server.
+// Two types of peer connections can be created:
+//
+//   - static dials are pre-configured connections. The scheduler attempts to
+//     keep these nodes connected at all times.
+//
+//   - dynamic dials are created from candidates read from dial sources, e.g.
+//     discovery or DNS node lists. Each source may only occupy sourceQuota dial
+//     slots at any time, so a fast source can't starve the others. Dynamic
+//     dials are spread across IP subnets, and nodes which fail to connect are
+//     retried with exponential backoff.
+//
+// The scheduler runs its own goroutine, all state below the channels is owned
+// by the loop.
+type dialScheduler struct {
+	dialConfig
+	setupFunc dialSetupFunc
+	sources   []*dialSource
+	wg        sync.WaitGroup
+	closing   chan struct{}
+
+	nodesIn     chan dialCandidate
+	doneCh      chan *dialTask
+	addStaticCh chan *enode.Node
+	remStaticCh chan *enode.Node
+	addPeerCh   chan *conn
+	remPeerCh   chan *conn
+
+	dialing    map[enode.ID]*dialTask // active tasks
+	dynDialing int                    // number of active dynamic dials
+	peers      map[enode.ID]struct{}  // all connected peers
+	dynPeers   int                    // number of dynamically dialed peers
+	netset     netutil.DistinctNetSet // IPs of dynamic dials and dialed peers
+	netPeers   map[enode.ID]net.IP    // dialed peers counted in netset
+
+	static     map[enode.ID]*dialTask
+	staticPool []*dialTask // static dials which can be started now
+
+	history      dialHistory // recently dialed nodes
+	historyTimer <-chan time.Time
+	historyAt    mclock.AbsTime // expiry time of historyTimer
+	failures     map[enode.ID]*dialFailure
+}
+
+func newDialScheduler(config dialConfig, sources []*dialSource, setupFunc dialSetupFunc) *dialScheduler {
+	d := &dialScheduler{
+		dialConfig:  config.withDefaults(),
+		setupFunc:   setupFunc,
+		sources:     sources,
+		closing:     make(chan struct{}),
+		nodesIn:     make(chan dialCandidate),
+		doneCh:      make(chan *dialTask),
+		addStaticCh: make(chan *enode.Node),
+		remStaticCh: make(chan *enode.Node),
+		addPeerCh:   make(chan *conn),
+		remPeerCh:   make(chan *conn),
+		dialing:     make(map[enode.ID]*dialTask),
+		peers:       make(map[enode.ID]struct{}),
+		netset:      netutil.DistinctNetSet{Subnet: dialSubnet, Limit: dialSubnetLimit},
+		netPeers:    make(map[enode.ID]net.IP),
 		static:      make(map[enode.ID]*dialTask),
-		dialing:     make(map[enode.ID]connFlag),
-		bootnodes:   make([]*enode.Node, len(bootnodes)),
-		randomNodes: make([]*enode.Node, maxdyn/2),
-		hist:        new(dialHistory),
+		failures:    make(map[enode.ID]*dialFailure),
 	}
-	copy(s.bootnodes, bootnodes)
-	for _, n := range static {
-		s.addStatic(n)
+	d.bootnodes = make([]*enode.Node, len(config.bootnodes))
+	copy(d.bootnodes, config.bootnodes)
+
+	d.wg.Add(1 + len(sources))
+	for _, src := range sources {
+		src.slots = make(chan struct{}, d.sourceQuota)
+		for i := 0; i < d.sourceQuota; i++ {
+			src.slots <- struct{}{}
+		}
+		go d.readNodes(src)
+	}
+	go d.loop()
+	return d
+}
+
+// stop shuts down the dialer, canceling all current dial tasks.
+func (d *dialScheduler) stop() {
+	close(d.closing)
+	for _, src := range d.sources {
+		src.it.Close()
 	}
-	return s
+	d.wg.Wait()
 }
 
-func (s *dialstate) addStatic(n *enode.Node) {
-	// This overwrites the task instead of updating an existing
-	// entry, giving users the opportunity to force a resolve operation.
-	s.static[n.ID()] = &dialTask{flags: staticDialedConn, dest: n}
+// addStatic adds a static dial candidate.
+func (d *dialScheduler) addStatic(n *enode.Node) {
+	select {
+	case d.addStaticCh <- n:
+	case <-d.closing:
+	}
 }
 
-func (s *dialstate) removeStatic(n *enode.Node) {
-	// This removes a task so future attempts to connect will not be made.
-	delete(s.static, n.ID())
-	// This removes a previous dial timestamp so that application
-	// can force a server to reconnect with chosen peer immediately.
-	s.hist.remove(n.ID())
+// removeStatic removes a static dial candidate.
+func (d *dialScheduler) removeStatic(n *enode.Node) {
+	select {
+	case d.remStaticCh <- n:
+	case <-d.closing:
+	}
 }
 
-func (s *dialstate) newTasks(nRunning int, peers map[enode.ID]*Peer, now time.Time) []task {
-	if s.start.IsZero() {
-		s.start = now
+// peerAdded updates the peer set.
+func (d *dialScheduler) peerAdded(c *conn) {
+	select {
+	case d.addPeerCh <- c:
+	case <-d.closing:
 	}
+}
 
-	var newtasks []task
-	addDial := func(flag connFlag, n *enode.Node) bool {
-		if err := s.checkDial(n, peers); err != nil {
-			log.Trace("Skipping dial candidate", "id", n.ID(), "addr", &net.TCPAddr{IP: n.IP(), Port: n.TCP()}, "err", err)
-			return false
-		}
-		s.dialing[n.ID()] = flag
-		newtasks = append(newtasks, &dialTask{flags: flag, dest: n})
-		return true
+// peerRemoved updates the peer set.
+func (d *dialScheduler) peerRemoved(c *conn) {
+	select {
+	case d.remPeerCh <- c:
+	case <-d.closing:
 	}
+}
 
-	// Compute number of dynamic dials necessary at this point.
-	needDynDials := s.maxDynDials
-	for _, p := range peers {
-		if p.rw.is(dynDialedConn) {
-			needDynDials--
-		}
+// loop is the main loop of the dialer.
+func (d *dialScheduler) loop() {
+	defer d.wg.Done()
+
+	var fallback <-chan time.Time
+	if len(d.bootnodes) > 0 {
+		fallback = d.clock.After(fallbackInterval)
 	}
-	for _, flag := range s.dialing {
-		if flag&dynDialedConn != 0 {
-			needDynDials--
+	var nodesCh chan dialCandidate
+
+loop:
+	for {
+		// Launch new dials if slots are available.
+		d.expireHistory()
+		d.startStaticDials()
+		if d.freeDynSlots() > 0 {
+			nodesCh = d.nodesIn
+		} else {
+			nodesCh = nil
 		}
-	}
+		d.rearmHistoryTimer()
+		activeDialsGauge.Update(int64(len(d.dialing)))
+
+		select {
+		case c := <-nodesCh:
+			d.expireHistory()
+			d.startDynDial(c)
+
+		case task := <-d.doneCh:
+			d.dialDone(task)
+
+		case c := <-d.addPeerCh:
+			id := c.node.ID()
+			d.peers[id] = struct{}{}
+			if c.is(dynDialedConn) {
+				d.dynPeers++
+				if ip := c.node.IP(); d.netAdd(ip) {
+					d.netPeers[id] = ip
+				}
+			}
+			if task := d.static[id]; task != nil && task.staticPoolIndex >= 0 {
+				d.removeFromStaticPool(task.staticPoolIndex)
+			}
+
+		case c := <-d.remPeerCh:
+			id := c.node.ID()
+			if c.is(dynDialedConn) {
+				d.dynPeers--
+			}
+			if ip, ok := d.netPeers[id]; ok {
+				d.netRemove(ip)
+				delete(d.netPeers, id)
+			}
+			delete(d.peers, id)
+			d.updateStaticPool(id)
+
+		case n := <-d.addStaticCh:
+			d.addStaticTask(n)
+
+		case n := <-d.remStaticCh:
+			id := n.ID()
+			d.log.Trace("Removing static node", "id", id)
+			if task := d.static[id]; task != nil {
+				delete(d.static, id)
+				if task.staticPoolIndex >= 0 {
+					d.removeFromStaticPool(task.staticPoolIndex)
+				}
+			}
+			// Forget the previous dial so the application can
+			// reconnect to the node immediately.
+			d.history.remove(id)
 
-	// Expire the dial history on every invocation.
-	s.hist.expire(now)
+		case <-d.historyTimer:
+			d.historyTimer = nil
 
-	// Create dials for static nodes if they are not connected.
-	for id, t := range s.static {
-		err := s.checkDial(t.dest, peers)
-		switch err {
-		case errNotWhitelisted, errSelf:
-			log.Warn("Removing static dial candidate", "id", t.dest.ID, "addr", &net.TCPAddr{IP: t.dest.IP(), Port: t.dest.TCP()}, "err", err)
-			delete(s.static, t.dest.ID())
-		case nil:
-			s.dialing[id] = t.flags
-			newtasks = append(newtasks, t)
+		case <-fallback:
+			d.dialFallback()
+			fallback = d.clock.After(fallbackInterval)
+
+		case <-d.closing:
+			break loop
 		}
 	}
-	// If we don't have any peers whatsoever, try to dial a random bootnode. This
-	// scenario is useful for the testnet (and private networks) where the discovery
-	// table might be full of mostly bad peers, making it hard to find good ones.
-	if len(peers) == 0 && len(s.bootnodes) > 0 && needDynDials > 0 && now.Sub(s.start) > fallbackInterval {
-		bootnode := s.bootnodes[0]
-		s.bootnodes = append(s.bootnodes[:0], s.bootnodes[1:]...)
-		s.bootnodes = append(s.bootnodes, bootnode)
 
-		if addDial(dynDialedConn, bootnode) {
-			needDynDials--
-		}
+	for range d.dialing {
+		d.dialDone(<-d.doneCh)
 	}
-	// Use random nodes from the table for half of the necessary
-	// dynamic dials.
-	randomCandidates := needDynDials / 2
-	if randomCandidates > 0 {
-		n := s.ntab.ReadRandomNodes(s.randomNodes)
-		for i := 0; i < randomCandidates && i < n; i++ {
-			if addDial(dynDialedConn, s.randomNodes[i]) {
-				needDynDials--
-			}
+}
+
+// readNodes runs in its own goroutine and delivers the candidates of a source.
+// A node is read only when the source has a free dial slot.
+func (d *dialScheduler) readNodes(src *dialSource) {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-src.slots:
+		case <-d.closing:
+			return
 		}
-	}
-	// Create dynamic dials from random lookup results, removing tried
-	// items from the result buffer.
-	i := 0
-	for ; i < len(s.lookupBuf) && needDynDials > 0; i++ {
-		if addDial(dynDialedConn, s.lookupBuf[i]) {
-			needDynDials--
+		if !src.it.Next() {
+			return
+		}
+		select {
+		case d.nodesIn <- dialCandidate{src, src.it.Node()}:
+		case <-d.closing:
+			return
 		}
 	}
-	s.lookupBuf = s.lookupBuf[:copy(s.lookupBuf, s.lookupBuf[i:])]
-	// Launch a discovery lookup if more candidates are needed.
-	if len(s.lookupBuf) < needDynDials && !s.lookupRunning {
-		s.lookupRunning = true
-		newtasks = append(newtasks, &discoverTask{})
-	}
+}
 
-	// Launch a timer to wait for the next node to expire if all
-	// candidates have been tried and no task is currently active.
-	// This should prevent cases where the dialer logic is not ticked
-	// because there are no pending events.
-	if nRunning == 0 && len(newtasks) == 0 && s.hist.Len() > 0 {
-		t := &waitExpireTask{s.hist.min().exp.Sub(now)}
-		newtasks = append(newtasks, t)
-	}
-	return newtasks
+// freeDialSlots returns the number of dials which can be started now.
+func (d *dialScheduler) freeDialSlots() int {
+	return d.maxActiveDials - len(d.dialing)
 }
 
-var (
-	errSelf             = errors.New("is self")
-	errAlreadyDialing   = errors.New("already dialing")
-	errAlreadyConnected = errors.New("already connected")
-	errRecentlyDialed   = errors.New("recently dialed")
-	errNotWhitelisted   = errors.New("not contained in netrestrict whitelist")
-)
+// freeDynSlots returns the number of dynamic dials which can be started now.
+func (d *dialScheduler) freeDynSlots() int {
+	free := d.maxDialPeers - d.dynPeers - d.dynDialing
+	if slots := d.freeDialSlots(); slots < free {
+		free = slots
+	}
+	return free
+}
 
-func (s *dialstate) checkDial(n *enode.Node, peers map[enode.ID]*Peer) error {
-	_, dialing := s.dialing[n.ID()]
-	switch {
-	case dialing:
+// checkDial returns an error if node n should not be dialed.
+func (d *dialScheduler) checkDial(n *enode.Node) error {
+	if n.ID() == d.self {
+		return errSelf
+	}
+	if n.IP() != nil && n.TCP() == 0 {
+		return errNoPort
+	}
+	if _, ok := d.dialing[n.ID()]; ok {
 		return errAlreadyDialing
-	case peers[n.ID()] != nil:
+	}
+	if _, ok := d.peers[n.ID()]; ok {
 		return errAlreadyConnected
-	case n.ID() == s.self:
-		return errSelf
-	case s.netrestrict != nil && !s.netrestrict.Contains(n.IP()):
+	}
+	if d.netRestrict != nil && !d.netRestrict.Contains(n.IP()) {
 		return errNotWhitelisted
-	case s.hist.contains(n.ID()):
+	}
+	if d.history.contains(n.ID()) {
 		return errRecentlyDialed
 	}
 	return nil
 }
 
-func (s *dialstate) taskDone(t task, now time.Time) {
-	switch t := t.(type) {
-	case *dialTask:
-		s.hist.add(t.dest.ID(), now.Add(dialHistoryExpiration))
-		delete(s.dialing, t.dest.ID())
-	case *discoverTask:
-		s.lookupRunning = false
-		s.lookupBuf = append(s.lookupBuf, t.results...)
+// startDynDial starts a dynamic dial to a candidate if it passes all checks.
+// The dial slot of the source is returned when the candidate is discarded.
+func (d *dialScheduler) startDynDial(c dialCandidate) {
+	err := d.checkDial(c.node)
+	if err == nil && c.node.IP() == nil {
+		err = errNoPort
+	}
+	if err == nil && !d.netAdd(c.node.IP()) {
+		dialNetLimitMeter.Mark(1)
+		err = errNetLimit
+	}
+	if err != nil {
+		d.log.Trace("Discarding dial candidate", "id", c.node.ID(), "ip", c.node.IP(), "source", c.src.name, "reason", err)
+		c.src.release()
+		return
+	}
+	c.src.meter.Mark(1)
+	task := newDialTask(c.node, dynDialedConn)
+	task.src = c.src
+	d.startDial(task)
+}
+
+// dialFallback dials the next bootnode if there are no peers at all. This is
+// useful for the testnet (and private networks) where the discovery table
+// might be full of mostly bad peers, making it hard to find good ones.
+func (d *dialScheduler) dialFallback() {
+	if len(d.peers) > 0 || d.freeDynSlots() <= 0 {
+		return
+	}
+	bootnode := d.bootnodes[0]
+	d.bootnodes = append(d.bootnodes[:0], d.bootnodes[1:]...)
+	d.bootnodes = append(d.bootnodes, bootnode)
+
+	if err := d.checkDial(bootnode); err != nil {
+		d.log.Trace("Skipping bootnode dial", "id", bootnode.ID(), "reason", err)
+		return
+	}
+	d.startDial(newDialTask(bootnode, dynDialedConn))
+}
+
+// addStaticTask creates the dial task of a static node.
+func (d *dialScheduler) addStaticTask(n *enode.Node) {
+	id := n.ID()
+	if _, ok := d.static[id]; ok {
+		return
+	}
+	switch err := d.checkDial(n); err {
+	case errSelf, errNotWhitelisted:
+		d.log.Warn("Ignoring static dial candidate", "id", id, "ip", n.IP(), "err", err)
+		return
+	}
+	d.log.Trace("Adding static node", "id", id, "ip", n.IP())
+	task := newDialTask(n, staticDialedConn)
+	d.static[id] = task
+	if d.checkDial(n) == nil {
+		d.addToStaticPool(task)
+	}
+}
+
+// startStaticDials starts static dials while there are free dial slots.
+func (d *dialScheduler) startStaticDials() {
+	for d.freeDialSlots() > 0 && len(d.staticPool) > 0 {
+		idx := d.rand.Intn(len(d.staticPool))
+		task := d.staticPool[idx]
+		d.removeFromStaticPool(idx)
+		d.startDial(task)
+	}
+}
+
+// updateStaticPool attempts to move the given static dial back into staticPool.
+func (d *dialScheduler) updateStaticPool(id enode.ID) {
+	task, ok := d.static[id]
+	if ok && task.staticPoolIndex < 0 && d.checkDial(task.dest) == nil {
+		d.addToStaticPool(task)
 	}
 }
 
-func (t *dialTask) Do(srv *Server) {
+func (d *dialScheduler) addToStaticPool(task *dialTask) {
+	if task.staticPoolIndex >= 0 {
+		panic("attempt to add task to staticPool twice")
+	}
+	d.staticPool = append(d.staticPool, task)
+	task.staticPoolIndex = len(d.staticPool) - 1
+}
+
+// removeFromStaticPool removes the task at idx from staticPool. It does that by moving
+// the current last element of the pool to idx and then shortening the pool by one.
+func (d *dialScheduler) removeFromStaticPool(idx int) {
+	task := d.staticPool[idx]
+	end := len(d.staticPool) - 1
+	d.staticPool[idx] = d.staticPool[end]
+	d.staticPool[idx].staticPoolIndex = idx
+	d.staticPool[end] = nil
+	d.staticPool = d.staticPool[:end]
+	task.staticPoolIndex = -1
+}
+
+// startDial runs the given dial task in a separate goroutine.
+func (d *dialScheduler) startDial(task *dialTask) {
+	d.log.Trace("Starting p2p dial", "id", task.dest.ID(), "ip", task.dest.IP(), "flag", task.flags)
+	d.dialing[task.dest.ID()] = task
+	if task.flags&dynDialedConn != 0 {
+		d.dynDialing++
+	}
+	dialMeter.Mark(1)
+	go func() {
+		task.run(d)
+		d.doneCh <- task
+	}()
+}
+
+// dialDone updates the scheduler state when a dial task has finished. Failed
+// dynamic dials are backed off exponentially.
+func (d *dialScheduler) dialDone(task *dialTask) {
+	id := task.dest.ID()
+	delete(d.dialing, id)
+	if task.flags&dynDialedConn != 0 {
+		d.dynDialing--
+	}
+	if task.src != nil {
+		d.netRemove(task.dest.IP())
+		task.src.release()
+	}
+
+	now := d.clock.Now()
+	switch {
+	case task.err == nil:
+		dialSuccessMeter.Mark(1)
+		delete(d.failures, id)
+		d.history.add(id, now.Add(dialHistoryExpiration), false)
+	case task.flags&staticDialedConn != 0:
+		d.history.add(id, now.Add(dialHistoryExpiration), false)
+	default:
+		dialFailureMeter.Mark(1)
+		f := d.failures[id]
+		if f == nil {
+			f = new(dialFailure)
+			d.failures[id] = f
+		}
+		f.count++
+		backoff := dialBackoff(f.count)
+		f.forget = now.Add(2 * backoff)
+		d.history.add(id, now.Add(backoff), false)
+		d.history.add(id, f.forget, true)
+		d.log.Trace("Dial failed", "id", id, "failures", f.count, "backoff", backoff, "err", task.err)
+	}
+	d.updateStaticPool(id)
+}
+
+// dialBackoff returns the time to wait before redialing a node which
+// failed the given number of consecutive dials.
+func dialBackoff(failures int) time.Duration {
+	backoff := dialHistoryExpiration
+	for i := 1; i < failures && backoff < maxDialBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxDialBackoff {
+		backoff = maxDialBackoff
+	}
+	return backoff
+}
+
+// expireHistory removes expired items from the dial history. Static
+// nodes become dialable again and stale failure counters are dropped.
+func (d *dialScheduler) expireHistory() {
+	d.history.expire(d.clock.Now(), func(pd pastDial) {
+		if pd.forget {
+			if f := d.failures[pd.id]; f != nil && f.forget <= pd.exp {
+				delete(d.failures, pd.id)
+			}
+			return
+		}
+		d.updateStaticPool(pd.id)
+	})
+}
+
+// rearmHistoryTimer configures the timer to wake up the loop when the next
+// history entry expires.
+func (d *dialScheduler) rearmHistoryTimer() {
+	if d.history.Len() == 0 {
+		return
+	}
+	next := d.history.min().exp
+	if d.historyTimer != nil && next == d.historyAt {
+		return
+	}
+	d.historyAt = next
+	d.historyTimer = d.clock.After(time.Duration(next - d.clock.Now()))
+}
+
+// netAdd adds the IP of a dynamic dial to the subnet set. It returns false
+// if the subnet limit is reached. LAN addresses are exempt from the limit.
+func (d *dialScheduler) netAdd(ip net.IP) bool {
+	if netutil.IsLAN(ip) {
+		return true
+	}
+	return d.netset.Add(ip)
+}
+
+func (d *dialScheduler) netRemove(ip net.IP) {
+	if !netutil.IsLAN(ip) {
+		d.netset.Remove(ip)
+	}
+}
+
+// A dialTask is generated for each node that is dialed. Its
+// fields cannot be accessed while the task is running.
+type dialTask struct {
+	staticPoolIndex int
+	flags           connFlag
+	src             *dialSource // source of dynamic dials, nil for static dials and bootnodes
+
+	// These fields are private to the task and should not be
+	// accessed by dialScheduler while the task is running.
+	dest         *enode.Node
+	lastResolved mclock.AbsTime
+	resolveDelay time.Duration
+	err          error // result of the dial
+}
+
+func newDialTask(dest *enode.Node, flags connFlag) *dialTask {
+	return &dialTask{dest: dest, flags: flags, staticPoolIndex: -1}
+}
+
+func (t *dialTask) run(d *dialScheduler) {
+	t.err = nil
 	if t.dest.Incomplete() {
-		if !t.resolve(srv) {
+		if !t.resolve(d) {
+			t.err = errUnresolved
 			return
 		}
 	}
-	err := t.dial(srv, t.dest)
+	err := t.dial(d, t.dest)
 	if err != nil {
-		log.Trace("Dial error", "task", t, "err", err)
+		d.log.Trace("Dial error", "task", t, "err", err)
 		// Try resolving the ID of static nodes if dialing failed.
 		if _, ok := err.(*dialError); ok && t.flags&staticDialedConn != 0 {
-			if t.resolve(srv) {
-				t.dial(srv, t.dest)
+			if t.resolve(d) {
+				err = t.dial(d, t.dest)
 			}
 		}
 	}
+	t.err = err
 }
 
 // resolve attempts to find the current endpoint for the destination
@@ -312,31 +687,31 @@ func (t *dialTask) Do(srv *Server) {
 // Resolve operations are throttled with backoff to avoid flooding the
 // discovery network with useless queries for nodes that don't exist.
 // The backoff delay resets when the node is found.
-func (t *dialTask) resolve(srv *Server) bool {
-	if srv.ntab == nil {
-		log.Debug("Can't resolve node", "id", t.dest.ID, "err", "discovery is disabled")
+func (t *dialTask) resolve(d *dialScheduler) bool {
+	if d.resolver == nil {
+		d.log.Debug("Can't resolve node", "id", t.dest.ID(), "err", "discovery is disabled")
 		return false
 	}
 	if t.resolveDelay == 0 {
 		t.resolveDelay = initialResolveDelay
 	}
-	if time.Since(t.lastResolved) < t.resolveDelay {
+	if t.lastResolved > 0 && time.Duration(d.clock.Now()-t.lastResolved) < t.resolveDelay {
 		return false
 	}
-	resolved := srv.ntab.Resolve(t.dest)
-	t.lastResolved = time.Now()
+	resolved := d.resolver.Resolve(t.dest)
+	t.lastResolved = d.clock.Now()
 	if resolved == nil {
 		t.resolveDelay *= 2
 		if t.resolveDelay > maxResolveDelay {
 			t.resolveDelay = maxResolveDelay
 		}
-		log.Debug("Resolving node failed", "id", t.dest.ID, "newdelay", t.resolveDelay)
+		d.log.Debug("Resolving node failed", "id", t.dest.ID(), "newdelay", t.resolveDelay)
 		return false
 	}
 	// The node was found.
 	t.resolveDelay = initialResolveDelay
 	t.dest = resolved
-	log.Debug("Resolved node", "id", t.dest.ID, "addr", &net.TCPAddr{IP: t.dest.IP(), Port: t.dest.TCP()})
+	d.log.Debug("Resolved node", "id", t.dest.ID(), "addr", &net.TCPAddr{IP: t.dest.IP(), Port: t.dest.TCP()})
 	return true
 }
 
@@ -345,13 +720,13 @@ type dialError struct {
 }
 
 // dial performs the actual connection attempt.
-func (t *dialTask) dial(srv *Server, dest *enode.Node) error {
-	fd, err := srv.Dialer.Dial(dest)
+func (t *dialTask) dial(d *dialScheduler, dest *enode.Node) error {
+	fd, err := d.dialer.Dial(dest)
 	if err != nil {
 		return &dialError{err}
 	}
 	mfd := newMeteredConn(fd, false, dest.IP())
-	return srv.SetupConn(mfd, t.flags, dest)
+	return d.setupFunc(mfd, t.flags, dest)
 }
 
 func (t *dialTask) String() string {
@@ -359,44 +734,27 @@ func (t *dialTask) String() string {
 	return fmt.Sprintf("%v %x %v:%d", t.flags, id[:8], t.dest.IP(), t.dest.TCP())
 }
 
-func (t *discoverTask) Do(srv *Server) {
-	// newTasks generates a lookup task whenever dynamic dials are
-	// necessary. Lookups need to take some time, otherwise the
-	// event loop spins too fast.
-	next := srv.lastLookup.Add(lookupInterval)
-	if now := time.Now(); now.Before(next) {
-		time.Sleep(next.Sub(now))
-	}
-	srv.lastLookup = time.Now()
-	t.results = srv.ntab.LookupRandom()
-}
-
-func (t *discoverTask) String() string {
-	s := "discovery lookup"
-	if len(t.results) > 0 {
-		s += fmt.Sprintf(" (%d results)", len(t.results))
-	}
-	return s
-}
+// the dial history remembers recent dials.
+type dialHistory []pastDial
 
-func (t waitExpireTask) Do(*Server) {
-	time.Sleep(t.Duration)
-}
-func (t waitExpireTask) String() string {
-	return fmt.Sprintf("wait for dial hist expire (%v)", t.Duration)
+// pastDial is an entry in the dial history. Entries with forget set
+// mark the time when the failure counter of the node is dropped.
+type pastDial struct {
+	id     enode.ID
+	exp    mclock.AbsTime
+	forget bool
 }
 
 // Use only these methods to access or modify dialHistory.
 func (h dialHistory) min() pastDial {
 	return h[0]
 }
-func (h *dialHistory) add(id enode.ID, exp time.Time) {
-	heap.Push(h, pastDial{id, exp})
-
+func (h *dialHistory) add(id enode.ID, exp mclock.AbsTime, forget bool) {
+	heap.Push(h, pastDial{id, exp, forget})
 }
 func (h *dialHistory) remove(id enode.ID) bool {
 	for i, v := range *h {
-		if v.id == id {
+		if v.id == id && !v.forget {
 			heap.Remove(h, i)
 			return true
 		}
@@ -405,21 +763,21 @@ func (h *dialHistory) remove(id enode.ID) bool {
 }
 func (h dialHistory) contains(id enode.ID) bool {
 	for _, v := range h {
-		if v.id == id {
+		if v.id == id && !v.forget {
 			return true
 		}
 	}
 	return false
 }
-func (h *dialHistory) expire(now time.Time) {
-	for h.Len() > 0 && h.min().exp.Before(now) {
-		heap.Pop(h)
+func (h *dialHistory) expire(now mclock.AbsTime, onExp func(pastDial)) {
+	for h.Len() > 0 && h.min().exp <= now {
+		onExp(heap.Pop(h).(pastDial))
 	}
 }
 
 // heap.Interface boilerplate
 func (h dialHistory) Len() int           { return len(h) }
-func (h dialHistory) Less(i, j int) bool { return h[i].exp.Before(h[j].exp) }
+func (h dialHistory) Less(i, j int) bool { return h[i].exp < h[j].exp }
 func (h dialHistory) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
 func (h *dialHistory) Push(x interface{}) {
 	*h = append(*h, x.(pastDial))
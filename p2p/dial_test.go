@@ -18,668 +18,410 @@ package p2p
 
 import (
 	"encoding/binary"
+	"errors"
+	mrand "math/rand"
 	"net"
-	"reflect"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
-	"github.com/davecgh/go-spew/spew"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
 )
 
-func init() {
-	spew.Config.Indent = "\t"
+var errDialTest = errors.New("dial failed in test")
+
+// dialTest runs a dial scheduler on a simulated clock. Dials are
+// blocked until the test completes them.
+type dialTest struct {
+	t      *testing.T
+	clock  *mclock.Simulated
+	dialer *dialTestDialer
+	sched  *dialScheduler
+
+	mu    sync.Mutex
+	conns map[enode.ID]*conn // connections set up by the scheduler
 }
 
-type dialtest struct {
-	init   *dialstate // state before and after the test.
-	rounds []round
+func newDialTest(t *testing.T, config dialConfig, sources ...*dialSource) *dialTest {
+	dt := &dialTest{
+		t:      t,
+		clock:  new(mclock.Simulated),
+		dialer: newDialTestDialer(),
+		conns:  make(map[enode.ID]*conn),
+	}
+	config.clock = dt.clock
+	config.dialer = dt.dialer
+	config.log = log.New()
+	config.rand = mrand.New(mrand.NewSource(1))
+	dt.sched = newDialScheduler(config, sources, dt.setup)
+	return dt
 }
 
-type round struct {
-	peers []*Peer // current peer set
-	done  []task  // tasks that got done this round
-	new   []task  // the result must match this one
+// setup is the dialSetupFunc of the test. It adds all dialed nodes as peers.
+func (dt *dialTest) setup(fd net.Conn, flags connFlag, dest *enode.Node) error {
+	fd.Close()
+	c := &conn{flags: flags, node: dest}
+	dt.mu.Lock()
+	dt.conns[dest.ID()] = c
+	dt.mu.Unlock()
+	dt.sched.peerAdded(c)
+	return nil
 }
 
-func runDialTest(t *testing.T, test dialtest) {
-	var (
-		vtime   time.Time
-		running int
-	)
-	pm := func(ps []*Peer) map[enode.ID]*Peer {
-		m := make(map[enode.ID]*Peer)
-		for _, p := range ps {
-			m[p.ID()] = p
+// disconnect removes a peer set up by the scheduler. It waits for the
+// connection because dials complete asynchronously.
+func (dt *dialTest) disconnect(id enode.ID) {
+	dt.t.Helper()
+
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		dt.mu.Lock()
+		c := dt.conns[id]
+		delete(dt.conns, id)
+		dt.mu.Unlock()
+		if c != nil {
+			dt.sched.peerRemoved(c)
+			return
 		}
-		return m
 	}
-	for i, round := range test.rounds {
-		for _, task := range round.done {
-			running--
-			if running < 0 {
-				panic("running task counter underflow")
-			}
-			test.init.taskDone(task, vtime)
-		}
+	dt.t.Fatalf("node %v is not connected", id)
+}
+
+func (dt *dialTest) close() {
+	close(dt.dialer.closed)
+	dt.sched.stop()
+}
 
-		new := test.init.newTasks(running, pm(round.peers), vtime)
-		if !sametasks(new, round.new) {
-			t.Errorf("round %d: new tasks mismatch:\ngot %v\nwant %v\nstate: %v\nrunning: %v\n",
-				i, spew.Sdump(new), spew.Sdump(round.new), spew.Sdump(test.init), spew.Sdump(running))
+// waitForDials waits until the given nodes are being dialed, in any order.
+func (dt *dialTest) waitForDials(want ...*enode.Node) []*dialTestReq {
+	dt.t.Helper()
+
+	var reqs []*dialTestReq
+	for range want {
+		select {
+		case req := <-dt.dialer.init:
+			reqs = append(reqs, req)
+		case <-time.After(time.Second):
+			dt.t.Fatalf("timed out waiting for dials, got %v, want %v", reqNodes(reqs), want)
 		}
-		t.Log("tasks:", spew.Sdump(new))
+	}
+	if !sameNodeIDs(reqNodes(reqs), want) {
+		dt.t.Fatalf("wrong dials: got %v, want %v", reqNodes(reqs), want)
+	}
+	return reqs
+}
+
+// checkNoDials checks that no dial is started within a short time.
+func (dt *dialTest) checkNoDials() {
+	dt.t.Helper()
 
-		// Time advances by 16 seconds on every round.
-		vtime = vtime.Add(16 * time.Second)
-		running += len(new)
+	select {
+	case req := <-dt.dialer.init:
+		dt.t.Fatalf("unexpected dial of %v", req.node.ID())
+	case <-time.After(50 * time.Millisecond):
 	}
 }
 
-type fakeTable []*enode.Node
-
-func (t fakeTable) Self() *enode.Node                     { return new(enode.Node) }
-func (t fakeTable) Close()                                {}
-func (t fakeTable) LookupRandom() []*enode.Node           { return nil }
-func (t fakeTable) Resolve(*enode.Node) *enode.Node       { return nil }
-func (t fakeTable) ReadRandomNodes(buf []*enode.Node) int { return copy(buf, t) }
-
-// This test checks that dynamic dials are launched from discovery results.
-func TestDialStateDynDial(t *testing.T) {
-	runDialTest(t, dialtest{
-		init: newDialState(enode.ID{}, nil, nil, fakeTable{}, 5, nil),
-		rounds: []round{
-			// A discovery query is launched.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(0), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(2), nil)}},
-				},
-				new: []task{&discoverTask{}},
-			},
-			// Dynamic dials are launched when it completes.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(0), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(2), nil)}},
-				},
-				done: []task{
-					&discoverTask{results: []*enode.Node{
-						newNode(uintID(2), nil), // this one is already connected and not dialed.
-						newNode(uintID(3), nil),
-						newNode(uintID(4), nil),
-						newNode(uintID(5), nil),
-						newNode(uintID(6), nil), // these are not tried because max dyn dials is 5
-						newNode(uintID(7), nil), // ...
-					}},
-				},
-				new: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(3), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(4), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(5), nil)},
-				},
-			},
-			// Some of the dials complete but no new ones are launched yet because
-			// the sum of active dial count and dynamic peer count is == maxDynDials.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(0), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(2), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(3), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(4), nil)}},
-				},
-				done: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(3), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(4), nil)},
-				},
-			},
-			// No new dial tasks are launched in the this round because
-			// maxDynDials has been reached.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(0), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(2), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(3), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(4), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(5), nil)}},
-				},
-				done: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(5), nil)},
-				},
-				new: []task{
-					&waitExpireTask{Duration: 14 * time.Second},
-				},
-			},
-			// In this round, the peer with id 2 drops off. The query
-			// results from last discovery lookup are reused.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(0), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(3), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(4), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(5), nil)}},
-				},
-				new: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(6), nil)},
-				},
-			},
-			// More peers (3,4) drop off and dial for ID 6 completes.
-			// The last query result from the discovery lookup is reused
-			// and a new one is spawned because more candidates are needed.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(0), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(5), nil)}},
-				},
-				done: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(6), nil)},
-				},
-				new: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(7), nil)},
-					&discoverTask{},
-				},
-			},
-			// Peer 7 is connected, but there still aren't enough dynamic peers
-			// (4 out of 5). However, a discovery is already running, so ensure
-			// no new is started.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(0), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(5), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(7), nil)}},
-				},
-				done: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(7), nil)},
-				},
-			},
-			// Finish the running node discovery with an empty set. A new lookup
-			// should be immediately requested.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(0), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(5), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(7), nil)}},
-				},
-				done: []task{
-					&discoverTask{},
-				},
-				new: []task{
-					&discoverTask{},
-				},
-			},
-		},
-	})
+// dialTestDialer is a NodeDialer which blocks dials until they are completed by the test.
+type dialTestDialer struct {
+	init   chan *dialTestReq
+	closed chan struct{}
+}
+
+type dialTestReq struct {
+	node   *enode.Node
+	result chan error
+}
+
+func newDialTestDialer() *dialTestDialer {
+	return &dialTestDialer{init: make(chan *dialTestReq), closed: make(chan struct{})}
 }
 
-// Tests that bootnodes are dialed if no peers are connectd, but not otherwise.
-func TestDialStateDynDialBootnode(t *testing.T) {
-	bootnodes := []*enode.Node{
-		newNode(uintID(1), nil),
-		newNode(uintID(2), nil),
-		newNode(uintID(3), nil),
+func (d *dialTestDialer) Dial(n *enode.Node) (net.Conn, error) {
+	req := &dialTestReq{node: n, result: make(chan error, 1)}
+	select {
+	case d.init <- req:
+	case <-d.closed:
+		return nil, errDialTest
 	}
-	table := fakeTable{
-		newNode(uintID(4), nil),
-		newNode(uintID(5), nil),
-		newNode(uintID(6), nil),
-		newNode(uintID(7), nil),
-		newNode(uintID(8), nil),
+	select {
+	case err := <-req.result:
+		if err != nil {
+			return nil, err
+		}
+		fd, _ := net.Pipe()
+		return fd, nil
+	case <-d.closed:
+		return nil, errDialTest
 	}
-	runDialTest(t, dialtest{
-		init: newDialState(enode.ID{}, nil, bootnodes, table, 5, nil),
-		rounds: []round{
-			// 2 dynamic dials attempted, bootnodes pending fallback interval
-			{
-				new: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(4), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(5), nil)},
-					&discoverTask{},
-				},
-			},
-			// No dials succeed, bootnodes still pending fallback interval
-			{
-				done: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(4), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(5), nil)},
-				},
-			},
-			// No dials succeed, bootnodes still pending fallback interval
-			{},
-			// No dials succeed, 2 dynamic dials attempted and 1 bootnode too as fallback interval was reached
-			{
-				new: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(1), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(4), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(5), nil)},
-				},
-			},
-			// No dials succeed, 2nd bootnode is attempted
-			{
-				done: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(1), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(4), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(5), nil)},
-				},
-				new: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(2), nil)},
-				},
-			},
-			// No dials succeed, 3rd bootnode is attempted
-			{
-				done: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(2), nil)},
-				},
-				new: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(3), nil)},
-				},
-			},
-			// No dials succeed, 1st bootnode is attempted again, expired random nodes retried
-			{
-				done: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(3), nil)},
-				},
-				new: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(1), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(4), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(5), nil)},
-				},
-			},
-			// Random dial succeeds, no more bootnodes are attempted
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(4), nil)}},
-				},
-				done: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(1), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(4), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(5), nil)},
-				},
-			},
-		},
-	})
 }
 
-func TestDialStateDynDialFromTable(t *testing.T) {
-	// This table always returns the same random nodes
-	// in the order given below.
-	table := fakeTable{
-		newNode(uintID(1), nil),
-		newNode(uintID(2), nil),
-		newNode(uintID(3), nil),
-		newNode(uintID(4), nil),
-		newNode(uintID(5), nil),
-		newNode(uintID(6), nil),
-		newNode(uintID(7), nil),
-		newNode(uintID(8), nil),
-	}
+// succeed lets the dial connect.
+func (req *dialTestReq) succeed() { req.result <- nil }
 
-	runDialTest(t, dialtest{
-		init: newDialState(enode.ID{}, nil, nil, table, 10, nil),
-		rounds: []round{
-			// 5 out of 8 of the nodes returned by ReadRandomNodes are dialed.
-			{
-				new: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(1), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(2), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(3), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(4), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(5), nil)},
-					&discoverTask{},
-				},
-			},
-			// Dialing nodes 1,2 succeeds. Dials from the lookup are launched.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(2), nil)}},
-				},
-				done: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(1), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(2), nil)},
-					&discoverTask{results: []*enode.Node{
-						newNode(uintID(10), nil),
-						newNode(uintID(11), nil),
-						newNode(uintID(12), nil),
-					}},
-				},
-				new: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(10), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(11), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(12), nil)},
-					&discoverTask{},
-				},
-			},
-			// Dialing nodes 3,4,5 fails. The dials from the lookup succeed.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(2), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(10), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(11), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(12), nil)}},
-				},
-				done: []task{
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(3), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(4), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(5), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(10), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(11), nil)},
-					&dialTask{flags: dynDialedConn, dest: newNode(uintID(12), nil)},
-				},
-			},
-			// Waiting for expiry. No waitExpireTask is launched because the
-			// discovery query is still running.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(2), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(10), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(11), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(12), nil)}},
-				},
-			},
-			// Nodes 3,4 are not tried again because only the first two
-			// returned random nodes (nodes 1,2) are tried and they're
-			// already connected.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(2), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(10), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(11), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(12), nil)}},
-				},
-			},
-		},
-	})
+// fail makes the dial fail.
+func (req *dialTestReq) fail() { req.result <- errDialTest }
+
+// chanIter is an iterator which yields the nodes sent on its channel.
+type chanIter struct {
+	ch        chan *enode.Node
+	cur       *enode.Node
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
-func newNode(id enode.ID, ip net.IP) *enode.Node {
-	var r enr.Record
-	if ip != nil {
-		r.Set(enr.IP(ip))
+func newChanIter() *chanIter {
+	return &chanIter{ch: make(chan *enode.Node), closed: make(chan struct{})}
+}
+
+func (it *chanIter) Next() bool {
+	select {
+	case it.cur = <-it.ch:
+		return true
+	case <-it.closed:
+		return false
 	}
-	return enode.SignNull(&r, id)
 }
 
-// This test checks that candidates that do not match the netrestrict list are not dialed.
-func TestDialStateNetRestrict(t *testing.T) {
-	// This table always returns the same random nodes
-	// in the order given below.
-	table := fakeTable{
-		newNode(uintID(1), net.ParseIP("127.0.0.1")),
-		newNode(uintID(2), net.ParseIP("127.0.0.2")),
-		newNode(uintID(3), net.ParseIP("127.0.0.3")),
-		newNode(uintID(4), net.ParseIP("127.0.0.4")),
-		newNode(uintID(5), net.ParseIP("127.0.2.5")),
-		newNode(uintID(6), net.ParseIP("127.0.2.6")),
-		newNode(uintID(7), net.ParseIP("127.0.2.7")),
-		newNode(uintID(8), net.ParseIP("127.0.2.8")),
+func (it *chanIter) Node() *enode.Node { return it.cur }
+func (it *chanIter) Close()            { it.closeOnce.Do(func() { close(it.closed) }) }
+
+// This test checks that dynamic dials are launched from the source until
+// the dialed peer limit is reached.
+func TestDialSchedDynDial(t *testing.T) {
+	nodes := []*enode.Node{
+		newNode(uintID(1), net.IP{127, 0, 0, 1}),
+		newNode(uintID(2), net.IP{127, 0, 0, 2}),
+		newNode(uintID(3), net.IP{127, 0, 0, 3}),
+		newNode(uintID(4), net.IP{127, 0, 0, 4}),
+		newNode(uintID(5), net.IP{127, 0, 0, 5}),
+	}
+	src := newDialSource("test", enode.IterNodes(nodes))
+	dt := newDialTest(t, dialConfig{maxDialPeers: 3, maxActiveDials: 10}, src)
+	defer dt.close()
+
+	// Three dials are started.
+	reqs := dt.waitForDials(nodes[:3]...)
+	dt.checkNoDials()
+	// No new dials are started when they connect.
+	for _, req := range reqs {
+		req.succeed()
 	}
-	restrict := new(netutil.Netlist)
-	restrict.Add("127.0.2.0/24")
-
-	runDialTest(t, dialtest{
-		init: newDialState(enode.ID{}, nil, nil, table, 10, restrict),
-		rounds: []round{
-			{
-				new: []task{
-					&dialTask{flags: dynDialedConn, dest: table[4]},
-					&discoverTask{},
-				},
-			},
-		},
-	})
+	dt.checkNoDials()
+	// A dial is launched when a peer disconnects.
+	dt.disconnect(reqs[0].node.ID())
+	dt.waitForDials(nodes[3])
 }
 
-// This test checks that static dials are launched.
-func TestDialStateStaticDial(t *testing.T) {
-	wantStatic := []*enode.Node{
-		newNode(uintID(1), nil),
-		newNode(uintID(2), nil),
-		newNode(uintID(3), nil),
-		newNode(uintID(4), nil),
-		newNode(uintID(5), nil),
+// This test checks that a source can't occupy more than its quota of dial slots.
+func TestDialSchedSourceQuota(t *testing.T) {
+	var nodesA, nodesB []*enode.Node
+	for i := 0; i < 5; i++ {
+		nodesA = append(nodesA, newNode(uintID(uint32(i+1)), net.IP{127, 0, 0, 1}))
+		nodesB = append(nodesB, newNode(uintID(uint32(i+11)), net.IP{127, 0, 0, 2}))
 	}
+	srcA := newDialSource("a", enode.IterNodes(nodesA))
+	srcB := newDialSource("b", enode.IterNodes(nodesB))
+	dt := newDialTest(t, dialConfig{maxDialPeers: 10, maxActiveDials: 10, sourceQuota: 2}, srcA, srcB)
+	defer dt.close()
+
+	reqs := dt.waitForDials(nodesA[0], nodesA[1], nodesB[0], nodesB[1])
+	dt.checkNoDials()
+	// A finished dial frees a slot of its source.
+	for _, req := range reqs {
+		if req.node.ID() == nodesA[0].ID() {
+			req.fail()
+		}
+	}
+	dt.waitForDials(nodesA[2])
+	dt.checkNoDials()
+}
 
-	runDialTest(t, dialtest{
-		init: newDialState(enode.ID{}, wantStatic, nil, fakeTable{}, 0, nil),
-		rounds: []round{
-			// Static dials are launched for the nodes that
-			// aren't yet connected.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(2), nil)}},
-				},
-				new: []task{
-					&dialTask{flags: staticDialedConn, dest: newNode(uintID(3), nil)},
-					&dialTask{flags: staticDialedConn, dest: newNode(uintID(4), nil)},
-					&dialTask{flags: staticDialedConn, dest: newNode(uintID(5), nil)},
-				},
-			},
-			// No new tasks are launched in this round because all static
-			// nodes are either connected or still being dialed.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(2), nil)}},
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(3), nil)}},
-				},
-				done: []task{
-					&dialTask{flags: staticDialedConn, dest: newNode(uintID(3), nil)},
-				},
-			},
-			// No new dial tasks are launched because all static
-			// nodes are now connected.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(2), nil)}},
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(3), nil)}},
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(4), nil)}},
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(5), nil)}},
-				},
-				done: []task{
-					&dialTask{flags: staticDialedConn, dest: newNode(uintID(4), nil)},
-					&dialTask{flags: staticDialedConn, dest: newNode(uintID(5), nil)},
-				},
-				new: []task{
-					&waitExpireTask{Duration: 14 * time.Second},
-				},
-			},
-			// Wait a round for dial history to expire, no new tasks should spawn.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(2), nil)}},
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(3), nil)}},
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(4), nil)}},
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(5), nil)}},
-				},
-			},
-			// If a static node is dropped, it should be immediately redialed,
-			// irrespective whether it was originally static or dynamic.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(3), nil)}},
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(5), nil)}},
-				},
-				new: []task{
-					&dialTask{flags: staticDialedConn, dest: newNode(uintID(2), nil)},
-					&dialTask{flags: staticDialedConn, dest: newNode(uintID(4), nil)},
-				},
-			},
-		},
-	})
+// This test checks that failed dynamic dials are retried with exponential backoff.
+func TestDialSchedBackoff(t *testing.T) {
+	var (
+		it   = newChanIter()
+		node = newNode(uintID(1), net.IP{127, 0, 0, 1})
+		dt   = newDialTest(t, dialConfig{maxDialPeers: 10, sourceQuota: 1}, newDialSource("test", it))
+	)
+	defer dt.close()
+
+	// The first dial fails.
+	it.ch <- node
+	dt.waitForDials(node)[0].fail()
+
+	// The node isn't dialed again before dialHistoryExpiration. The source has a single
+	// slot, so the other node is dialed only after the first one was discarded.
+	other := newNode(uintID(2), net.IP{127, 0, 0, 2})
+	it.ch <- node
+	it.ch <- other
+	dt.waitForDials(other)[0].fail()
+
+	// After dialHistoryExpiration, the node is dialed again and fails.
+	dt.clock.Run(dialHistoryExpiration)
+	it.ch <- node
+	dt.waitForDials(node)[0].fail()
+
+	// The second failure doubles the backoff.
+	dt.clock.Run(dialHistoryExpiration)
+	it.ch <- node
+	it.ch <- other
+	dt.waitForDials(other)[0].succeed()
+	dt.clock.Run(dialHistoryExpiration)
+	it.ch <- node
+	dt.waitForDials(node)[0].succeed()
 }
 
-// This test checks that static peers will be redialed immediately if they were re-added to a static list.
-func TestDialStaticAfterReset(t *testing.T) {
-	wantStatic := []*enode.Node{
-		newNode(uintID(1), nil),
-		newNode(uintID(2), nil),
+func TestDialBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, dialHistoryExpiration},
+		{2, 2 * dialHistoryExpiration},
+		{3, 4 * dialHistoryExpiration},
+		{6, 32 * dialHistoryExpiration},
+		{7, maxDialBackoff},
+		{100, maxDialBackoff},
 	}
+	for _, test := range tests {
+		if got := dialBackoff(test.failures); got != test.want {
+			t.Errorf("dialBackoff(%d) = %v, want %v", test.failures, got, test.want)
+		}
+	}
+}
 
-	rounds := []round{
-		// Static dials are launched for the nodes that aren't yet connected.
-		{
-			peers: nil,
-			new: []task{
-				&dialTask{flags: staticDialedConn, dest: newNode(uintID(1), nil)},
-				&dialTask{flags: staticDialedConn, dest: newNode(uintID(2), nil)},
-			},
-		},
-		// No new dial tasks, all peers are connected.
-		{
-			peers: []*Peer{
-				{rw: &conn{flags: staticDialedConn, node: newNode(uintID(1), nil)}},
-				{rw: &conn{flags: staticDialedConn, node: newNode(uintID(2), nil)}},
-			},
-			done: []task{
-				&dialTask{flags: staticDialedConn, dest: newNode(uintID(1), nil)},
-				&dialTask{flags: staticDialedConn, dest: newNode(uintID(2), nil)},
-			},
-			new: []task{
-				&waitExpireTask{Duration: 30 * time.Second},
-			},
-		},
+// This test checks that at most dialSubnetLimit peers are dialed per subnet.
+// LAN addresses are exempt from the limit.
+func TestDialSchedNetLimit(t *testing.T) {
+	nodes := []*enode.Node{
+		newNode(uintID(1), net.IP{1, 2, 3, 1}),
+		newNode(uintID(2), net.IP{1, 2, 3, 2}),
+		newNode(uintID(3), net.IP{1, 2, 3, 3}), // limit is exceeded, not dialed
+		newNode(uintID(4), net.IP{1, 2, 4, 1}),
+		newNode(uintID(5), net.IP{127, 0, 0, 1}),
+		newNode(uintID(6), net.IP{127, 0, 0, 2}),
+		newNode(uintID(7), net.IP{127, 0, 0, 3}),
 	}
-	dTest := dialtest{
-		init:   newDialState(enode.ID{}, wantStatic, nil, fakeTable{}, 0, nil),
-		rounds: rounds,
+	src := newDialSource("test", enode.IterNodes(nodes))
+	dt := newDialTest(t, dialConfig{maxDialPeers: 10, maxActiveDials: 10, sourceQuota: 10}, src)
+	defer dt.close()
+
+	reqs := dt.waitForDials(nodes[0], nodes[1], nodes[3], nodes[4], nodes[5], nodes[6])
+	dt.checkNoDials()
+	for _, req := range reqs {
+		req.succeed()
 	}
-	runDialTest(t, dTest)
-	for _, n := range wantStatic {
-		dTest.init.removeStatic(n)
-		dTest.init.addStatic(n)
+	if dt.sched.netset.Len() != 3 {
+		t.Errorf("wrong netset size %d, want 3", dt.sched.netset.Len())
 	}
-	// without removing peers they will be considered recently dialed
-	runDialTest(t, dTest)
 }
 
-// This test checks that past dials are not retried for some time.
-func TestDialStateCache(t *testing.T) {
-	wantStatic := []*enode.Node{
-		newNode(uintID(1), nil),
-		newNode(uintID(2), nil),
-		newNode(uintID(3), nil),
+// This test checks that candidates which do not match the netrestrict list are not dialed.
+func TestDialSchedNetRestrict(t *testing.T) {
+	nodes := []*enode.Node{
+		newNode(uintID(1), net.ParseIP("127.0.0.1")),
+		newNode(uintID(2), net.ParseIP("127.0.0.2")),
+		newNode(uintID(3), net.ParseIP("127.0.2.5")),
+		newNode(uintID(4), net.ParseIP("127.0.2.6")),
 	}
+	config := dialConfig{
+		netRestrict:  new(netutil.Netlist),
+		maxDialPeers: 10,
+	}
+	config.netRestrict.Add("127.0.2.0/24")
+	dt := newDialTest(t, config, newDialSource("test", enode.IterNodes(nodes)))
+	defer dt.close()
 
-	runDialTest(t, dialtest{
-		init: newDialState(enode.ID{}, wantStatic, nil, fakeTable{}, 0, nil),
-		rounds: []round{
-			// Static dials are launched for the nodes that
-			// aren't yet connected.
-			{
-				peers: nil,
-				new: []task{
-					&dialTask{flags: staticDialedConn, dest: newNode(uintID(1), nil)},
-					&dialTask{flags: staticDialedConn, dest: newNode(uintID(2), nil)},
-					&dialTask{flags: staticDialedConn, dest: newNode(uintID(3), nil)},
-				},
-			},
-			// No new tasks are launched in this round because all static
-			// nodes are either connected or still being dialed.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: staticDialedConn, node: newNode(uintID(2), nil)}},
-				},
-				done: []task{
-					&dialTask{flags: staticDialedConn, dest: newNode(uintID(1), nil)},
-					&dialTask{flags: staticDialedConn, dest: newNode(uintID(2), nil)},
-				},
-			},
-			// A salvage task is launched to wait for node 3's history
-			// entry to expire.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(2), nil)}},
-				},
-				done: []task{
-					&dialTask{flags: staticDialedConn, dest: newNode(uintID(3), nil)},
-				},
-				new: []task{
-					&waitExpireTask{Duration: 14 * time.Second},
-				},
-			},
-			// Still waiting for node 3's entry to expire in the cache.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(2), nil)}},
-				},
-			},
-			// The cache entry for node 3 has expired and is retried.
-			{
-				peers: []*Peer{
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
-					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(2), nil)}},
-				},
-				new: []task{
-					&dialTask{flags: staticDialedConn, dest: newNode(uintID(3), nil)},
-				},
-			},
-		},
-	})
+	dt.waitForDials(nodes[2:]...)
+	dt.checkNoDials()
 }
 
-func TestDialResolve(t *testing.T) {
-	resolved := newNode(uintID(1), net.IP{127, 0, 55, 234})
-	table := &resolveMock{answer: resolved}
-	state := newDialState(enode.ID{}, nil, nil, table, 0, nil)
-
-	// Check that the task is generated with an incomplete ID.
-	dest := newNode(uintID(1), nil)
-	state.addStatic(dest)
-	tasks := state.newTasks(0, nil, time.Time{})
-	if !reflect.DeepEqual(tasks, []task{&dialTask{flags: staticDialedConn, dest: dest}}) {
-		t.Fatalf("expected dial task, got %#v", tasks)
+// This test checks that static nodes are dialed regardless of the dynamic dial
+// limit, and redialed after they disconnect or fail.
+func TestDialSchedStaticDial(t *testing.T) {
+	var (
+		nodeA = newNode(uintID(1), net.IP{127, 0, 0, 1})
+		nodeB = newNode(uintID(2), net.IP{127, 0, 0, 2})
+		dt    = newDialTest(t, dialConfig{maxDialPeers: 0})
+	)
+	defer dt.close()
+
+	dt.sched.addStatic(nodeA)
+	dt.sched.addStatic(nodeB)
+	for _, req := range dt.waitForDials(nodeA, nodeB) {
+		if req.node.ID() == nodeA.ID() {
+			req.fail()
+		} else {
+			req.succeed()
+		}
 	}
+	dt.checkNoDials()
+
+	// A is redialed when the history expires.
+	dt.clock.WaitForTimers(1)
+	dt.clock.Run(dialHistoryExpiration)
+	dt.waitForDials(nodeA)[0].succeed()
+
+	// B is redialed after it disconnects, but not after it was removed.
+	dt.disconnect(nodeB.ID())
+	dt.waitForDials(nodeB)[0].succeed()
+	dt.sched.removeStatic(nodeB)
+	dt.disconnect(nodeB.ID())
+	dt.clock.Run(dialHistoryExpiration)
+	dt.checkNoDials()
+}
 
-	// Now run the task, it should resolve the ID once.
-	config := Config{Dialer: TCPDialer{&net.Dialer{Deadline: time.Now().Add(-5 * time.Minute)}}}
-	srv := &Server{ntab: table, Config: config}
-	tasks[0].Do(srv)
-	if !reflect.DeepEqual(table.resolveCalls, []*enode.Node{dest}) {
-		t.Fatalf("wrong resolve calls, got %v", table.resolveCalls)
-	}
+// This test checks that a bootnode is dialed when there are no peers.
+func TestDialSchedBootnodeFallback(t *testing.T) {
+	var (
+		bootnode = newNode(uintID(1), net.IP{127, 0, 0, 1})
+		dt       = newDialTest(t, dialConfig{maxDialPeers: 5, bootnodes: []*enode.Node{bootnode}})
+	)
+	defer dt.close()
 
-	// Report it as done to the dialer, which should update the static node record.
-	state.taskDone(tasks[0], time.Now())
-	if state.static[uintID(1)].dest != resolved {
-		t.Fatalf("state.dest not updated")
-	}
+	dt.checkNoDials()
+	dt.clock.WaitForTimers(1)
+	dt.clock.Run(fallbackInterval)
+	dt.waitForDials(bootnode)[0].succeed()
+
+	// It's not dialed again while connected.
+	dt.clock.Run(fallbackInterval)
+	dt.checkNoDials()
 }
 
-// compares task lists but doesn't care about the order.
-func sametasks(a, b []task) bool {
-	if len(a) != len(b) {
-		return false
+// This test checks that static nodes without an endpoint are resolved before dialing.
+func TestDialSchedResolve(t *testing.T) {
+	var (
+		resolved = newNode(uintID(1), net.IP{127, 0, 55, 234})
+		resolver = &resolveMock{answer: resolved}
+		dest     = newNode(uintID(1), nil)
+		dt       = newDialTest(t, dialConfig{resolver: resolver})
+	)
+	defer dt.close()
+
+	dt.sched.addStatic(dest)
+	req := dt.waitForDials(dest)[0]
+	if req.node != resolved {
+		t.Fatalf("dialed unresolved node %v", req.node)
 	}
-next:
-	for _, ta := range a {
-		for _, tb := range b {
-			if reflect.DeepEqual(ta, tb) {
-				continue next
-			}
-		}
-		return false
+	if calls := resolver.calls(); len(calls) != 1 || calls[0] != dest {
+		t.Fatalf("wrong resolve calls, got %v", calls)
 	}
-	return true
+	req.succeed()
+}
+
+func newNode(id enode.ID, ip net.IP) *enode.Node {
+	var r enr.Record
+	if ip != nil {
+		r.Set(enr.IP(ip))
+		r.Set(enr.TCP(30303))
+	}
+	return enode.SignNull(&r, id)
 }
 
 func uintID(i uint32) enode.ID {
@@ -688,18 +430,51 @@ func uintID(i uint32) enode.ID {
 	return id
 }
 
-// implements discoverTable for TestDialResolve
+func reqNodes(reqs []*dialTestReq) []*enode.Node {
+	nodes := make([]*enode.Node, len(reqs))
+	for i, req := range reqs {
+		nodes[i] = req.node
+	}
+	return nodes
+}
+
+func sameNodeIDs(a, b []*enode.Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	ids := func(nodes []*enode.Node) []string {
+		s := make([]string, len(nodes))
+		for i, n := range nodes {
+			s[i] = n.ID().String()
+		}
+		sort.Strings(s)
+		return s
+	}
+	ia, ib := ids(a), ids(b)
+	for i := range ia {
+		if ia[i] != ib[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveMock implements nodeResolver for TestDialSchedResolve.
 type resolveMock struct {
+	mu           sync.Mutex
 	resolveCalls []*enode.Node
 	answer       *enode.Node
 }
 
 func (t *resolveMock) Resolve(n *enode.Node) *enode.Node {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	t.resolveCalls = append(t.resolveCalls, n)
 	return t.answer
 }
 
-func (t *resolveMock) Self() *enode.Node                     { return new(enode.Node) }
-func (t *resolveMock) Close()                                {}
-func (t *resolveMock) LookupRandom() []*enode.Node           { return nil }
-func (t *resolveMock) ReadRandomNodes(buf []*enode.Node) int { return 0 }
+func (t *resolveMock) calls() []*enode.Node {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.resolveCalls
+}
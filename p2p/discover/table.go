@@ -62,6 +62,10 @@ const (
 	seedMinTableTime    = 5 * time.Minute
 	seedCount           = 30
 	seedMaxAge          = 5 * 24 * time.Hour
+
+	// Random lookups performed by node iterators are throttled and can
+	// only run once every few seconds.
+	lookupInterval = 4 * time.Second
 )
 
 type Table struct {
@@ -88,6 +92,7 @@ type transport interface {
 	self() *enode.Node
 	ping(enode.ID, *net.UDPAddr) error
 	findnode(toid enode.ID, addr *net.UDPAddr, target encPubkey) ([]*node, error)
+	requestENR(n *enode.Node) (*enode.Node, error)
 	close()
 }
 
@@ -237,6 +242,13 @@ func (tab *Table) Resolve(n *enode.Node) *enode.Node {
 	return nil
 }
 
+// RequestENR requests the signed node record of a node, which must be known by
+// its endpoint. The returned node is the one passed in if the remote node has no
+// newer record.
+func (tab *Table) RequestENR(n *enode.Node) (*enode.Node, error) {
+	return tab.net.requestENR(n)
+}
+
 // LookupRandom finds random nodes in the network.
 func (tab *Table) LookupRandom() []*enode.Node {
 	var target encPubkey
@@ -244,6 +256,57 @@ func (tab *Table) LookupRandom() []*enode.Node {
 	return unwrapNodes(tab.lookup(target, true))
 }
 
+// RandomNodes returns an iterator which yields the results of random lookups.
+// The iterator ends when it is closed or the table is closed.
+func (tab *Table) RandomNodes() enode.Iterator {
+	return &lookupIterator{tab: tab, closing: make(chan struct{})}
+}
+
+// lookupIterator performs random lookups whenever its buffer runs empty.
+type lookupIterator struct {
+	tab       *Table
+	buffer    []*enode.Node
+	last      time.Time // time of the last lookup
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+func (it *lookupIterator) Next() bool {
+	select {
+	case <-it.closing:
+		return false
+	default:
+	}
+	// Consume the current node.
+	if len(it.buffer) > 0 {
+		it.buffer = it.buffer[1:]
+	}
+	// Refill the buffer, waiting in between lookups.
+	for len(it.buffer) == 0 {
+		select {
+		case <-time.After(lookupInterval - time.Since(it.last)):
+		case <-it.closing:
+			return false
+		case <-it.tab.closed:
+			return false
+		}
+		it.last = time.Now()
+		it.buffer = it.tab.LookupRandom()
+	}
+	return true
+}
+
+func (it *lookupIterator) Node() *enode.Node {
+	if len(it.buffer) == 0 {
+		return nil
+	}
+	return it.buffer[0]
+}
+
+func (it *lookupIterator) Close() {
+	it.closeOnce.Do(func() { close(it.closing) })
+}
+
 // lookup performs a network search for nodes close to the given target. It approaches the
 // target by querying nodes that are closer to it on each iteration. The given target does
 // not need to be an actual node identifier.
@@ -540,6 +603,28 @@ func (tab *Table) bucket(id enode.ID) *bucket {
 	return tab.buckets[d-bucketMinDistance-1]
 }
 
+// nodesAtDistance returns the table entries at the given logarithmic distance
+// from the local node.
+func (tab *Table) nodesAtDistance(dist int) []*enode.Node {
+	tab.mutex.Lock()
+	defer tab.mutex.Unlock()
+
+	var (
+		self  = tab.self().ID()
+		b     = tab.buckets[0]
+		nodes []*enode.Node
+	)
+	if dist > bucketMinDistance {
+		b = tab.buckets[dist-bucketMinDistance-1]
+	}
+	for _, n := range b.entries {
+		if enode.LogDist(self, n.ID()) == dist {
+			nodes = append(nodes, unwrapNode(n))
+		}
+	}
+	return nodes
+}
+
 // add attempts to add the given node to its corresponding bucket. If the bucket has space
 // available, adding the node succeeds immediately. Otherwise, the node is added if the
 // least recently active node in the bucket does not respond to a ping packet.
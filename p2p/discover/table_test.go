@@ -309,6 +309,33 @@ func TestTable_Lookup(t *testing.T) {
 	// TODO: check result nodes are actually closest
 }
 
+func TestTable_RandomNodes(t *testing.T) {
+	tab, db := newTestTable(lookupTestnet)
+	defer tab.Close()
+	defer db.Close()
+
+	seedKey, _ := decodePubkey(lookupTestnet.dists[256][0])
+	tab.stuff([]*node{wrapNode(enode.NewV4(seedKey, net.IP{}, 0, 256))})
+
+	// Successive lookups may return the same nodes, so keep reading until enough
+	// distinct ones are found, closing the iterator if that takes too long.
+	it := tab.RandomNodes()
+	timeout := time.AfterFunc(5*time.Second, it.Close)
+	defer timeout.Stop()
+
+	seen := make(map[enode.ID]bool)
+	for len(seen) < 10 && it.Next() {
+		seen[it.Node().ID()] = true
+	}
+	if len(seen) != 10 {
+		t.Fatalf("wrong number of distinct nodes: got %d, want 10", len(seen))
+	}
+	it.Close()
+	if it.Next() {
+		t.Fatal("Next returned true after Close")
+	}
+}
+
 // This is the test network for the Lookup test.
 // The nodes were obtained by running testnet.mine with a random NodeID as target.
 var lookupTestnet = &preminedTestnet{
@@ -531,6 +558,7 @@ func (tn *preminedTestnet) findnode(toid enode.ID, toaddr *net.UDPAddr, target e
 }
 
 func (*preminedTestnet) close()                                        {}
+func (*preminedTestnet) requestENR(n *enode.Node) (*enode.Node, error) { return nil, errTimeout }
 func (*preminedTestnet) waitping(from enode.ID) error                  { return nil }
 func (*preminedTestnet) ping(toid enode.ID, toaddr *net.UDPAddr) error { return nil }
 
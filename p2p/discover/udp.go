@@ -26,9 +26,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -62,6 +64,8 @@ const (
 	pongPacket
 	findnodePacket
 	neighborsPacket
+	enrRequestPacket
+	enrResponsePacket
 )
 
 // RPC request structures
@@ -103,6 +107,21 @@ type (
 		Rest []rlp.RawValue `rlp:"tail"`
 	}
 
+	// enrRequest queries for the remote node's record.
+	enrRequest struct {
+		Expiration uint64
+		// Ignore additional fields (for forward compatibility).
+		Rest []rlp.RawValue `rlp:"tail"`
+	}
+
+	// enrResponse is the reply to enrRequest.
+	enrResponse struct {
+		ReplyTok []byte // Hash of the enrRequest packet.
+		Record   enr.Record
+		// Ignore additional fields (for forward compatibility).
+		Rest []rlp.RawValue `rlp:"tail"`
+	}
+
 	rpcNode struct {
 		IP  net.IP // len 4 for IPv4 or 16 for IPv6
 		UDP uint16 // for discovery protocol
@@ -352,6 +371,52 @@ func (t *udp) findnode(toid enode.ID, toaddr *net.UDPAddr, target encPubkey) ([]
 	return nodes, <-errc
 }
 
+// requestENR sends an enrRequest to the given node and waits for its record.
+func (t *udp) requestENR(n *enode.Node) (*enode.Node, error) {
+	addr := &net.UDPAddr{IP: n.IP(), Port: n.UDP()}
+
+	// The remote node only answers if it has a recent endpoint proof of ours,
+	// solicit a ping like for findnode.
+	if time.Since(t.db.LastPingReceived(n.ID())) > bondExpiration {
+		t.ping(n.ID(), addr)
+		t.waitping(n.ID())
+	}
+	req := &enrRequest{
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	}
+	packet, hash, err := encodePacket(t.priv, enrRequestPacket, req)
+	if err != nil {
+		return nil, err
+	}
+	var resp *enrResponse
+	errc := t.pending(n.ID(), enrResponsePacket, func(r interface{}) bool {
+		if reply := r.(*enrResponse); bytes.Equal(reply.ReplyTok, hash) {
+			resp = reply
+			return true
+		}
+		return false
+	})
+	t.write(addr, req.name(), packet)
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	// Verify the response record
+	respN, err := enode.New(enode.ValidSchemes, &resp.Record)
+	if err != nil {
+		return nil, err
+	}
+	if respN.ID() != n.ID() {
+		return nil, fmt.Errorf("invalid ID in response record")
+	}
+	if respN.Seq() < n.Seq() {
+		return n, nil // response record is older
+	}
+	if err := respN.ValidateComplete(); err != nil {
+		return nil, err
+	}
+	return respN, nil
+}
+
 // pending adds a reply callback to the pending reply queue.
 // see the documentation of type pending for a detailed explanation.
 func (t *udp) pending(id enode.ID, ptype byte, callback func(interface{}) bool) <-chan error {
@@ -565,7 +630,7 @@ func (t *udp) readLoop(unhandled chan<- ReadPacket) {
 		}
 		if t.handlePacket(from, buf[:nbytes]) != nil && unhandled != nil {
 			select {
-			case unhandled <- ReadPacket{buf[:nbytes], from}:
+			case unhandled <- ReadPacket{common.CopyBytes(buf[:nbytes]), from}:
 			default:
 			}
 		}
@@ -607,6 +672,10 @@ func decodePacket(buf []byte) (packet, encPubkey, []byte, error) {
 		req = new(findnode)
 	case neighborsPacket:
 		req = new(neighbors)
+	case enrRequestPacket:
+		req = new(enrRequest)
+	case enrResponsePacket:
+		req = new(enrResponse)
 	default:
 		return nil, fromKey, hash, fmt.Errorf("unknown type: %d", ptype)
 	}
@@ -710,6 +779,33 @@ func (req *neighbors) handle(t *udp, from *net.UDPAddr, fromKey encPubkey, mac [
 
 func (req *neighbors) name() string { return "NEIGHBORS/v4" }
 
+func (req *enrRequest) handle(t *udp, from *net.UDPAddr, fromKey encPubkey, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	// Like findnode, records are only sent to nodes with a valid endpoint proof,
+	// as the response is larger than the request.
+	if time.Since(t.db.LastPongReceived(fromKey.id())) > bondExpiration {
+		return errUnknownNode
+	}
+	t.send(from, enrResponsePacket, &enrResponse{
+		ReplyTok: mac,
+		Record:   *t.localNode.Node().Record(),
+	})
+	return nil
+}
+
+func (req *enrRequest) name() string { return "ENRREQUEST/v4" }
+
+func (req *enrResponse) handle(t *udp, from *net.UDPAddr, fromKey encPubkey, mac []byte) error {
+	if !t.handleReply(fromKey.id(), enrResponsePacket, req) {
+		return errUnsolicitedReply
+	}
+	return nil
+}
+
+func (req *enrResponse) name() string { return "ENRRESPONSE/v4" }
+
 func expired(ts uint64) bool {
 	return time.Unix(int64(ts), 0).Before(time.Now())
 }
@@ -37,6 +37,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -329,6 +330,79 @@ func TestUDP_findnodeMultiReply(t *testing.T) {
 	}
 }
 
+// Tests that the node record is served to bonded nodes only.
+func TestUDP_ENRRequest(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	// Requests of unknown nodes must be rejected
+	test.packetIn(errUnknownNode, enrRequestPacket, &enrRequest{Expiration: futureExp})
+
+	// Once bonded, the record must be served
+	remoteID := encodePubkey(&test.remotekey.PublicKey).id()
+	test.table.db.UpdateLastPongReceived(remoteID, time.Now())
+
+	test.packetIn(nil, enrRequestPacket, &enrRequest{Expiration: futureExp})
+	reqHash := test.sent[len(test.sent)-1][:macSize]
+
+	test.waitPacketOut(func(p *enrResponse) {
+		if !bytes.Equal(p.ReplyTok, reqHash) {
+			t.Errorf("wrong reply token: got %x, want %x", p.ReplyTok, reqHash)
+		}
+		n, err := enode.New(enode.ValidSchemes, &p.Record)
+		if err != nil {
+			t.Fatalf("invalid record: %v", err)
+		}
+		if want := test.udp.self(); n.ID() != want.ID() || n.Seq() != want.Seq() {
+			t.Errorf("wrong record: got %v (seq %d), want %v (seq %d)", n.ID(), n.Seq(), want.ID(), want.Seq())
+		}
+	})
+}
+
+// Tests that requested records are returned after verification.
+func TestUDP_requestENR(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	rid := enode.PubkeyToIDV4(&test.remotekey.PublicKey)
+	test.table.db.UpdateLastPingReceived(rid, time.Now())
+
+	// Create the record served by the remote node
+	var record enr.Record
+	record.Set(enr.IP(test.remoteaddr.IP))
+	record.Set(enr.UDP(test.remoteaddr.Port))
+	record.Set(enr.TCP(30303))
+	record.Set(enr.WithEntry("test", "value"))
+	if err := enode.SignV4(&record, test.remotekey); err != nil {
+		t.Fatal(err)
+	}
+	// Queue a pending request and answer it
+	node := enode.NewV4(&test.remotekey.PublicKey, test.remoteaddr.IP, 30303, test.remoteaddr.Port)
+	resultc, errc := make(chan *enode.Node, 1), make(chan error, 1)
+	go func() {
+		n, err := test.udp.requestENR(node)
+		if err != nil {
+			errc <- err
+		} else {
+			resultc <- n
+		}
+	}()
+	hash, _ := test.waitPacketOut(func(p *enrRequest) {})
+	test.packetIn(nil, enrResponsePacket, &enrResponse{ReplyTok: hash, Record: record})
+
+	select {
+	case n := <-resultc:
+		var value string
+		if err := n.Load(enr.WithEntry("test", &value)); err != nil || value != "value" {
+			t.Errorf("returned record mismatch: %v (err %v)", value, err)
+		}
+	case err := <-errc:
+		t.Errorf("requestENR error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Error("requestENR did not return within 5 seconds")
+	}
+}
+
 func TestUDP_successfulPing(t *testing.T) {
 	test := newUDPTest(t)
 	added := make(chan *node, 1)
@@ -0,0 +1,795 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/rlp"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Discovery v5 packet structure:
+//
+//     packet        = masking-iv || masked-header || message
+//     masked-header = aesctr(dest-id[:16], masking-iv, header)
+//     header        = static-header || authdata
+//     static-header = protocol-id || version || flag || nonce || authdata-size
+//     message       = aesgcm(session-key, nonce, message-type || rlp(message), masking-iv || header)
+//
+// The authdata depends on the flag of the packet:
+//
+//     ordinary message: src-id
+//     WHOAREYOU:        id-nonce || enr-seq
+//     handshake:        src-id || sig-size || eph-key-size || id-signature || eph-pubkey || [record]
+
+// Packet header flags.
+const (
+	flagMessage = iota
+	flagWhoareyou
+	flagHandshake
+)
+
+// v5 message types.
+const (
+	pingV5Msg byte = iota + 1
+	pongV5Msg
+	findnodeV5Msg
+	nodesV5Msg
+	talkreqV5Msg  // reserved
+	talkrespV5Msg // reserved
+	regtopicV5Msg
+	ticketV5Msg
+	regconfirmationV5Msg
+	topicqueryV5Msg
+
+	unknownV5Msg   = byte(255) // any non-decryptable packet
+	whoareyouV5Msg = byte(254) // the WHOAREYOU packet
+)
+
+// Packet sizes.
+const (
+	sizeofMaskingIV         = 16
+	sizeofStaticHeader      = 6 + 2 + 1 + gcmNonceSize + 2 // protocol-id, version, flag, nonce, authdata-size
+	sizeofStaticPacketData  = sizeofMaskingIV + sizeofStaticHeader
+	sizeofMessageAuthData   = 32         // src-id
+	sizeofWhoareyouAuthData = 16 + 8     // id-nonce, enr-seq
+	sizeofHandshakeAuthData = 32 + 1 + 1 // src-id, sig-size, eph-key-size
+	minPacketSizeV5         = sizeofStaticPacketData + sizeofWhoareyouAuthData
+	maxPacketSizeV5         = 1280
+	gcmNonceSize            = 12
+	randomPacketMsgSize     = 20
+)
+
+var v5ProtocolID = [6]byte{'d', 'i', 's', 'c', 'v', '5'}
+
+const v5Version = 1
+
+// Errors
+var (
+	errTooShort            = errors.New("packet too short")
+	errInvalidHeader       = errors.New("invalid packet header")
+	errInvalidFlag         = errors.New("invalid flag value in header")
+	errInvalidAuthSize     = errors.New("invalid authdata size")
+	errUnexpectedHandshake = errors.New("unexpected auth response, not in handshake")
+	errHandshakeNonceMatch = errors.New("wrong nonce in auth response")
+	errInvalidNonceSig     = errors.New("invalid ID nonce signature")
+	errMessageTooShort     = errors.New("message contains no data")
+	errMessageDecrypt      = errors.New("cannot decrypt message")
+	errNoRecord            = errors.New("expected ENR in handshake but none sent")
+)
+
+// v5Packet is implemented by all discovery v5 message types.
+type v5Packet interface {
+	name() string    // Name returns a string corresponding to the message type.
+	kind() byte      // Kind returns the message type.
+	reqid() []byte   // Returns the request ID.
+	setreqid([]byte) // Sets the request ID.
+	handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr)
+}
+
+type (
+	// unknownV5 represents any packet that can't be decrypted.
+	unknownV5 struct {
+		Nonce [gcmNonceSize]byte
+	}
+
+	// whoareyouV5 is the challenge sent in reply to an undecryptable packet.
+	whoareyouV5 struct {
+		Nonce     [gcmNonceSize]byte // nonce of the packet being answered
+		IDNonce   [16]byte           // ID proof data
+		RecordSeq uint64             // ENR sequence number of recipient
+
+		// Node is the locally known node record of recipient.
+		// This must be set by the caller of encode.
+		Node *enode.Node
+
+		// ChallengeData is the masked header of the WHOAREYOU packet, which
+		// is signed by the recipient and used as the key derivation salt.
+		ChallengeData []byte
+
+		sent mclock.AbsTime // for handshake GC
+	}
+
+	// pingV5 is sent during liveness checks.
+	pingV5 struct {
+		ReqID  []byte
+		ENRSeq uint64
+	}
+
+	// pongV5 is the reply to pingV5.
+	pongV5 struct {
+		ReqID  []byte
+		ENRSeq uint64
+		ToIP   net.IP // These fields should mirror the UDP envelope address of the ping
+		ToPort uint16 // packet, which provides a way to discover the the external address (after NAT).
+	}
+
+	// findnodeV5 is a query for nodes in the given bucket.
+	findnodeV5 struct {
+		ReqID     []byte
+		Distances []uint
+	}
+
+	// nodesV5 is the reply to findnodeV5 and topicqueryV5.
+	nodesV5 struct {
+		ReqID []byte
+		Total uint8
+		Nodes []*enr.Record
+	}
+
+	// regtopicV5 requests the registration of the sender for a topic.
+	regtopicV5 struct {
+		ReqID  []byte
+		Topic  string
+		ENR    *enr.Record
+		Ticket []byte
+	}
+
+	// ticketV5 is the reply to regtopicV5 if the registration has to wait.
+	ticketV5 struct {
+		ReqID    []byte
+		Ticket   []byte
+		WaitTime uint // in seconds
+	}
+
+	// regconfirmationV5 is the reply to regtopicV5 if the sender was registered.
+	regconfirmationV5 struct {
+		ReqID []byte
+		Topic string
+	}
+
+	// topicqueryV5 asks for the nodes registered for a topic.
+	topicqueryV5 struct {
+		ReqID []byte
+		Topic string
+	}
+)
+
+func (*unknownV5) name() string       { return "UNKNOWN/v5" }
+func (*unknownV5) kind() byte         { return unknownV5Msg }
+func (*unknownV5) reqid() []byte      { return nil }
+func (*unknownV5) setreqid(id []byte) {}
+
+func (*whoareyouV5) name() string       { return "WHOAREYOU/v5" }
+func (*whoareyouV5) kind() byte         { return whoareyouV5Msg }
+func (*whoareyouV5) reqid() []byte      { return nil }
+func (*whoareyouV5) setreqid(id []byte) {}
+
+func (*pingV5) name() string         { return "PING/v5" }
+func (*pingV5) kind() byte           { return pingV5Msg }
+func (p *pingV5) reqid() []byte      { return p.ReqID }
+func (p *pingV5) setreqid(id []byte) { p.ReqID = id }
+
+func (*pongV5) name() string         { return "PONG/v5" }
+func (*pongV5) kind() byte           { return pongV5Msg }
+func (p *pongV5) reqid() []byte      { return p.ReqID }
+func (p *pongV5) setreqid(id []byte) { p.ReqID = id }
+
+func (*findnodeV5) name() string         { return "FINDNODE/v5" }
+func (*findnodeV5) kind() byte           { return findnodeV5Msg }
+func (p *findnodeV5) reqid() []byte      { return p.ReqID }
+func (p *findnodeV5) setreqid(id []byte) { p.ReqID = id }
+
+func (*nodesV5) name() string         { return "NODES/v5" }
+func (*nodesV5) kind() byte           { return nodesV5Msg }
+func (p *nodesV5) reqid() []byte      { return p.ReqID }
+func (p *nodesV5) setreqid(id []byte) { p.ReqID = id }
+
+func (*regtopicV5) name() string         { return "REGTOPIC/v5" }
+func (*regtopicV5) kind() byte           { return regtopicV5Msg }
+func (p *regtopicV5) reqid() []byte      { return p.ReqID }
+func (p *regtopicV5) setreqid(id []byte) { p.ReqID = id }
+
+func (*ticketV5) name() string         { return "TICKET/v5" }
+func (*ticketV5) kind() byte           { return ticketV5Msg }
+func (p *ticketV5) reqid() []byte      { return p.ReqID }
+func (p *ticketV5) setreqid(id []byte) { p.ReqID = id }
+
+func (*regconfirmationV5) name() string         { return "REGCONFIRMATION/v5" }
+func (*regconfirmationV5) kind() byte           { return regconfirmationV5Msg }
+func (p *regconfirmationV5) reqid() []byte      { return p.ReqID }
+func (p *regconfirmationV5) setreqid(id []byte) { p.ReqID = id }
+
+func (*topicqueryV5) name() string         { return "TOPICQUERY/v5" }
+func (*topicqueryV5) kind() byte           { return topicqueryV5Msg }
+func (p *topicqueryV5) reqid() []byte      { return p.ReqID }
+func (p *topicqueryV5) setreqid(id []byte) { p.ReqID = id }
+
+// v5Header is the unmasked packet header.
+type v5Header struct {
+	IV [sizeofMaskingIV]byte
+	v5StaticHeader
+	AuthData []byte
+
+	src enode.ID // used by decoder
+}
+
+// v5StaticHeader contains the fixed-size fields of the packet header.
+type v5StaticHeader struct {
+	ProtocolID [6]byte
+	Version    uint16
+	Flag       byte
+	Nonce      [gcmNonceSize]byte
+	AuthSize   uint16
+}
+
+// mask returns a cipher for masking/unmasking a packet header.
+func (h *v5Header) mask(destID enode.ID) cipher.Stream {
+	block, err := aes.NewCipher(destID[:16])
+	if err != nil {
+		panic("can't create cipher")
+	}
+	return cipher.NewCTR(block, h.IV[:])
+}
+
+// wireCodec encodes and decodes discovery v5 packets. It keeps track of the
+// session keys and handshake state of the remote nodes.
+//
+// The codec is not safe for concurrent use.
+type wireCodec struct {
+	sha256    hash.Hash
+	localnode *enode.LocalNode
+	privkey   *ecdsa.PrivateKey
+	sc        *sessionCache
+
+	// encoder buffers
+	buf     bytes.Buffer // whole packet
+	headbuf bytes.Buffer // header of the packet
+	msgbuf  bytes.Buffer // message RLP plaintext
+}
+
+// newWireCodec creates a wire codec.
+func newWireCodec(ln *enode.LocalNode, key *ecdsa.PrivateKey, clock mclock.Clock) *wireCodec {
+	return &wireCodec{
+		sha256:    sha256.New(),
+		localnode: ln,
+		privkey:   key,
+		sc:        newSessionCache(1024, clock),
+	}
+}
+
+// encode encodes a packet to a node. 'id' and 'addr' specify the destination
+// node. The 'challenge' parameter should be the most recently received
+// WHOAREYOU packet from that node.
+func (c *wireCodec) encode(id enode.ID, addr string, packet v5Packet, challenge *whoareyouV5) ([]byte, [gcmNonceSize]byte, error) {
+	var (
+		head    v5Header
+		s       *session
+		msgData []byte
+		err     error
+	)
+	switch {
+	case packet.kind() == whoareyouV5Msg:
+		head, err = c.encodeWhoareyou(id, packet.(*whoareyouV5))
+	case challenge != nil:
+		// We have an unanswered challenge, send handshake.
+		head, s, err = c.encodeHandshakeHeader(id, addr, challenge)
+	default:
+		s = c.sc.session(id, addr)
+		if s != nil {
+			// There is a session, use it.
+			head, err = c.encodeMessageHeader(id, s)
+		} else {
+			// No keys, send random data to kick off the handshake.
+			head, msgData, err = c.encodeRandom(id)
+		}
+	}
+	if err != nil {
+		return nil, [gcmNonceSize]byte{}, err
+	}
+	// Generate masking IV and write the unmasked header.
+	if _, err := crand.Read(head.IV[:]); err != nil {
+		return nil, [gcmNonceSize]byte{}, fmt.Errorf("can't generate masking IV: %v", err)
+	}
+	c.writeHeaders(&head)
+
+	// Store sent WHOAREYOU challenges, encrypt everything else.
+	if challenge, ok := packet.(*whoareyouV5); ok {
+		challenge.ChallengeData = append([]byte{}, c.buf.Bytes()...)
+		c.sc.storeSentHandshake(id, addr, challenge)
+	} else if msgData == nil {
+		msgData, err = c.encryptMessage(s, packet, &head, c.buf.Bytes())
+		if err != nil {
+			return nil, [gcmNonceSize]byte{}, err
+		}
+	}
+	return c.encodeRaw(id, &head, msgData), head.Nonce, nil
+}
+
+// writeHeaders writes the unmasked header of the packet into the buffer.
+func (c *wireCodec) writeHeaders(head *v5Header) {
+	c.buf.Reset()
+	c.buf.Write(head.IV[:])
+	binary.Write(&c.buf, binary.BigEndian, &head.v5StaticHeader)
+	c.buf.Write(head.AuthData)
+}
+
+// encodeRaw masks the header in the buffer and appends the message data.
+func (c *wireCodec) encodeRaw(id enode.ID, head *v5Header, msgData []byte) []byte {
+	enc := append([]byte{}, c.buf.Bytes()...)
+	masked := enc[sizeofMaskingIV:]
+	head.mask(id).XORKeyStream(masked, masked)
+	return append(enc, msgData...)
+}
+
+// makeHeader creates a packet header with the given flag and authdata.
+func (c *wireCodec) makeHeader(flag byte, authdata []byte) v5Header {
+	return v5Header{
+		v5StaticHeader: v5StaticHeader{
+			ProtocolID: v5ProtocolID,
+			Version:    v5Version,
+			Flag:       flag,
+			AuthSize:   uint16(len(authdata)),
+		},
+		AuthData: authdata,
+	}
+}
+
+// encodeRandom encodes a packet with random content.
+func (c *wireCodec) encodeRandom(toID enode.ID) (v5Header, []byte, error) {
+	src := c.localnode.ID()
+	head := c.makeHeader(flagMessage, src[:])
+
+	// Random nonce and message data, since there are no keys to encrypt with.
+	msgData := make([]byte, randomPacketMsgSize)
+	if _, err := crand.Read(head.Nonce[:]); err != nil {
+		return head, nil, fmt.Errorf("can't get random data: %v", err)
+	}
+	if _, err := crand.Read(msgData); err != nil {
+		return head, nil, fmt.Errorf("can't get random data: %v", err)
+	}
+	return head, msgData, nil
+}
+
+// encodeWhoareyou encodes a WHOAREYOU packet.
+func (c *wireCodec) encodeWhoareyou(toID enode.ID, packet *whoareyouV5) (v5Header, error) {
+	// Sanity check node field to catch misbehaving callers.
+	if packet.RecordSeq > 0 && packet.Node == nil {
+		panic("BUG: missing node in whoareyouV5 with non-zero seq")
+	}
+	authdata := make([]byte, sizeofWhoareyouAuthData)
+	copy(authdata, packet.IDNonce[:])
+	binary.BigEndian.PutUint64(authdata[16:], packet.RecordSeq)
+
+	head := c.makeHeader(flagWhoareyou, authdata)
+	head.Nonce = packet.Nonce
+	return head, nil
+}
+
+// encodeHandshakeHeader encodes the handshake message packet header.
+func (c *wireCodec) encodeHandshakeHeader(toID enode.ID, addr string, challenge *whoareyouV5) (v5Header, *session, error) {
+	// Ensure calling code sets challenge.Node.
+	if challenge.Node == nil {
+		panic("BUG: missing challenge.Node in encode")
+	}
+	// Generate new secrets.
+	s, ephkey, idsig, err := c.makeHandshakeSecrets(toID, challenge)
+	if err != nil {
+		return v5Header{}, nil, err
+	}
+	// Create the authdata, including our record if the remote one is outdated.
+	src := c.localnode.ID()
+	authdata := make([]byte, 0, sizeofHandshakeAuthData+len(idsig)+len(ephkey))
+	authdata = append(authdata, src[:]...)
+	authdata = append(authdata, byte(len(idsig)), byte(len(ephkey)))
+	authdata = append(authdata, idsig...)
+	authdata = append(authdata, ephkey...)
+	if challenge.RecordSeq < c.localnode.Node().Seq() {
+		enc, err := rlp.EncodeToBytes(c.localnode.Node().Record())
+		if err != nil {
+			return v5Header{}, nil, fmt.Errorf("can't encode local record: %v", err)
+		}
+		authdata = append(authdata, enc...)
+	}
+	head := c.makeHeader(flagHandshake, authdata)
+
+	// Generate nonce for message and store the session.
+	nonce, err := c.sc.nextNonce(s)
+	if err != nil {
+		return v5Header{}, nil, fmt.Errorf("can't generate nonce: %v", err)
+	}
+	head.Nonce = nonce
+	c.sc.storeNewSession(toID, addr, s)
+	return head, s, nil
+}
+
+// makeHandshakeSecrets creates the session keys and ID nonce signature of a
+// handshake answering the given challenge.
+func (c *wireCodec) makeHandshakeSecrets(toID enode.ID, challenge *whoareyouV5) (*session, []byte, []byte, error) {
+	remotePubkey := challenge.Node.Pubkey()
+	if remotePubkey == nil {
+		return nil, nil, nil, errors.New("can't find secp256k1 key for recipient")
+	}
+	ephkey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("can't generate ephemeral key: %v", err)
+	}
+	ephpubkey := crypto.CompressPubkey(&ephkey.PublicKey)
+
+	idsig, err := makeIDSignature(c.sha256, c.privkey, challenge.ChallengeData, ephpubkey, toID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("can't sign: %v", err)
+	}
+	s := deriveKeys(ephkey, remotePubkey, c.localnode.ID(), toID, challenge.ChallengeData)
+	if s == nil {
+		return nil, nil, nil, errors.New("key derivation failed")
+	}
+	return s, ephpubkey, idsig, nil
+}
+
+// encodeMessageHeader encodes an encrypted message packet header.
+func (c *wireCodec) encodeMessageHeader(toID enode.ID, s *session) (v5Header, error) {
+	src := c.localnode.ID()
+	head := c.makeHeader(flagMessage, src[:])
+
+	nonce, err := c.sc.nextNonce(s)
+	if err != nil {
+		return v5Header{}, fmt.Errorf("can't generate nonce: %v", err)
+	}
+	head.Nonce = nonce
+	return head, nil
+}
+
+// encryptMessage encrypts the message of a packet, authenticating the header.
+func (c *wireCodec) encryptMessage(s *session, p v5Packet, head *v5Header, headerData []byte) ([]byte, error) {
+	c.msgbuf.Reset()
+	c.msgbuf.WriteByte(p.kind())
+	if err := rlp.Encode(&c.msgbuf, p); err != nil {
+		return nil, err
+	}
+	return encryptGCM(nil, s.writeKey, head.Nonce[:], c.msgbuf.Bytes(), headerData)
+}
+
+// decode decodes a discovery packet.
+func (c *wireCodec) decode(input []byte, addr string) (src enode.ID, n *enode.Node, p v5Packet, err error) {
+	if len(input) < minPacketSizeV5 {
+		return enode.ID{}, nil, nil, errTooShort
+	}
+	// Unmask the static header.
+	var head v5Header
+	copy(head.IV[:], input[:sizeofMaskingIV])
+	mask := head.mask(c.localnode.ID())
+	staticHeader := input[sizeofMaskingIV:sizeofStaticPacketData]
+	mask.XORKeyStream(staticHeader, staticHeader)
+
+	// Decode and verify the static header.
+	if err := binary.Read(bytes.NewReader(staticHeader), binary.BigEndian, &head.v5StaticHeader); err != nil {
+		return enode.ID{}, nil, nil, errInvalidHeader
+	}
+	if head.ProtocolID != v5ProtocolID || head.Version != v5Version {
+		return enode.ID{}, nil, nil, errInvalidHeader
+	}
+	// Unmask the auth data.
+	authDataEnd := sizeofStaticPacketData + int(head.AuthSize)
+	if authDataEnd > len(input) {
+		return enode.ID{}, nil, nil, errInvalidAuthSize
+	}
+	head.AuthData = input[sizeofStaticPacketData:authDataEnd]
+	mask.XORKeyStream(head.AuthData, head.AuthData)
+
+	// Delete timed-out handshakes. This must happen before decoding to avoid
+	// processing the same handshake twice.
+	c.sc.handshakeGC()
+
+	headerData := input[:authDataEnd]
+	msgData := input[authDataEnd:]
+	switch head.Flag {
+	case flagWhoareyou:
+		p, err = c.decodeWhoareyou(&head, headerData)
+	case flagHandshake:
+		n, p, err = c.decodeHandshakeMessage(addr, &head, headerData, msgData)
+	case flagMessage:
+		p, err = c.decodeMessage(addr, &head, headerData, msgData)
+	default:
+		err = errInvalidFlag
+	}
+	return head.src, n, p, err
+}
+
+// decodeWhoareyou reads the authdata of a WHOAREYOU packet.
+func (c *wireCodec) decodeWhoareyou(head *v5Header, headerData []byte) (v5Packet, error) {
+	if len(head.AuthData) != sizeofWhoareyouAuthData {
+		return nil, fmt.Errorf("invalid auth size %d for WHOAREYOU", len(head.AuthData))
+	}
+	p := &whoareyouV5{
+		Nonce:         head.Nonce,
+		RecordSeq:     binary.BigEndian.Uint64(head.AuthData[16:]),
+		ChallengeData: append([]byte{}, headerData...),
+	}
+	copy(p.IDNonce[:], head.AuthData[:16])
+	return p, nil
+}
+
+// decodeHandshakeMessage reads the authdata of a handshake packet, establishes
+// the session and decrypts the message.
+func (c *wireCodec) decodeHandshakeMessage(fromAddr string, head *v5Header, headerData, msgData []byte) (n *enode.Node, p v5Packet, err error) {
+	// Split the authdata into its fields.
+	auth := head.AuthData
+	if len(auth) < sizeofHandshakeAuthData {
+		return nil, nil, fmt.Errorf("header authsize %d too low for handshake", len(auth))
+	}
+	copy(head.src[:], auth[:32])
+	sigsize, keysize := int(auth[32]), int(auth[33])
+	if len(auth) < sizeofHandshakeAuthData+sigsize+keysize {
+		return nil, nil, errInvalidAuthSize
+	}
+	var (
+		idsig  = auth[sizeofHandshakeAuthData : sizeofHandshakeAuthData+sigsize]
+		ephkey = auth[sizeofHandshakeAuthData+sigsize : sizeofHandshakeAuthData+sigsize+keysize]
+		record = auth[sizeofHandshakeAuthData+sigsize+keysize:]
+	)
+	// Find the challenge we sent to the remote node.
+	challenge := c.sc.getHandshake(head.src, fromAddr)
+	if challenge == nil {
+		return nil, nil, errUnexpectedHandshake
+	}
+	// Get node record and verify the ID nonce signature.
+	n, err = c.decodeHandshakeRecord(challenge.Node, head.src, record)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := verifyIDSignature(c.sha256, idsig, n, challenge.ChallengeData, ephkey, c.localnode.ID()); err != nil {
+		return nil, nil, err
+	}
+	// Derive the session keys. The remote node is the initiator.
+	pubkey, err := crypto.DecompressPubkey(ephkey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid ephemeral pubkey: %v", err)
+	}
+	s := deriveKeys(c.privkey, pubkey, head.src, c.localnode.ID(), challenge.ChallengeData)
+	if s == nil {
+		return nil, nil, errors.New("key derivation failed")
+	}
+	s = s.keysFlipped()
+
+	// Decrypt the message using the new session keys.
+	p, err = c.decryptMessage(msgData, head.Nonce[:], headerData, s.readKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Handshake OK, drop the challenge and store the new session keys.
+	c.sc.storeNewSession(head.src, fromAddr, s)
+	c.sc.deleteHandshake(head.src, fromAddr)
+	return n, p, nil
+}
+
+// decodeHandshakeRecord verifies the node record contained in a handshake
+// packet. The remote node should include the record if we don't have one or
+// if ours is older than the latest sequence number.
+func (c *wireCodec) decodeHandshakeRecord(local *enode.Node, wantID enode.ID, remote []byte) (*enode.Node, error) {
+	node := local
+	if len(remote) > 0 {
+		var record enr.Record
+		if err := rlp.DecodeBytes(remote, &record); err != nil {
+			return nil, err
+		}
+		if local == nil || local.Seq() < record.Seq() {
+			n, err := enode.New(enode.ValidSchemes, &record)
+			if err != nil {
+				return nil, fmt.Errorf("invalid node record: %v", err)
+			}
+			if n.ID() != wantID {
+				return nil, fmt.Errorf("record in handshake has wrong ID: %v", n.ID())
+			}
+			node = n
+		}
+	}
+	if node == nil {
+		return nil, errNoRecord
+	}
+	return node, nil
+}
+
+// decodeMessage reads packet data following the header of an ordinary
+// message packet.
+func (c *wireCodec) decodeMessage(fromAddr string, head *v5Header, headerData, msgData []byte) (v5Packet, error) {
+	if len(head.AuthData) != sizeofMessageAuthData {
+		return nil, fmt.Errorf("invalid auth size %d for message packet", len(head.AuthData))
+	}
+	copy(head.src[:], head.AuthData)
+
+	// Try decrypting the message.
+	key := c.sc.readKey(head.src, fromAddr)
+	msg, err := c.decryptMessage(msgData, head.Nonce[:], headerData, key)
+	if err == errMessageDecrypt {
+		// It didn't work. Start the handshake since this is an ordinary message packet.
+		return &unknownV5{Nonce: head.Nonce}, nil
+	}
+	return msg, err
+}
+
+// decryptMessage decrypts and decodes the message of a packet.
+func (c *wireCodec) decryptMessage(input, nonce, headerData, readKey []byte) (v5Packet, error) {
+	msgdata, err := decryptGCM(readKey, nonce, input, headerData)
+	if err != nil {
+		return nil, errMessageDecrypt
+	}
+	if len(msgdata) == 0 {
+		return nil, errMessageTooShort
+	}
+	return decodeV5Message(msgdata[0], msgdata[1:])
+}
+
+// decodeV5Message decodes the message body of a packet.
+func decodeV5Message(ptype byte, body []byte) (v5Packet, error) {
+	var dec v5Packet
+	switch ptype {
+	case pingV5Msg:
+		dec = new(pingV5)
+	case pongV5Msg:
+		dec = new(pongV5)
+	case findnodeV5Msg:
+		dec = new(findnodeV5)
+	case nodesV5Msg:
+		dec = new(nodesV5)
+	case regtopicV5Msg:
+		dec = new(regtopicV5)
+	case ticketV5Msg:
+		dec = new(ticketV5)
+	case regconfirmationV5Msg:
+		dec = new(regconfirmationV5)
+	case topicqueryV5Msg:
+		dec = new(topicqueryV5)
+	default:
+		return nil, fmt.Errorf("unknown packet type %d", ptype)
+	}
+	if err := rlp.DecodeBytes(body, dec); err != nil {
+		return nil, err
+	}
+	if dec.reqid() != nil && len(dec.reqid()) > 8 {
+		return nil, errors.New("invalid request ID")
+	}
+	return dec, nil
+}
+
+// makeIDSignature creates the ID nonce signature, proving the ownership of
+// the node key to the recipient of the handshake.
+func makeIDSignature(hash hash.Hash, key *ecdsa.PrivateKey, challenge, ephkey []byte, destID enode.ID) ([]byte, error) {
+	input := idNonceHash(hash, challenge, ephkey, destID)
+	idsig, err := crypto.Sign(input, key)
+	if err != nil {
+		return nil, err
+	}
+	return idsig[:len(idsig)-1], nil // remove recovery ID
+}
+
+// verifyIDSignature checks that the ID nonce signature is valid for the
+// secp256k1 key of the node.
+func verifyIDSignature(hash hash.Hash, sig []byte, n *enode.Node, challenge, ephkey []byte, destID enode.ID) error {
+	pubkey := n.Pubkey()
+	if pubkey == nil {
+		return errors.New("no secp256k1 public key in record")
+	}
+	input := idNonceHash(hash, challenge, ephkey, destID)
+	if !crypto.VerifySignature(crypto.FromECDSAPub(pubkey), input, sig) {
+		return errInvalidNonceSig
+	}
+	return nil
+}
+
+// idNonceHash computes the input of the ID nonce signature.
+func idNonceHash(h hash.Hash, challenge, ephkey []byte, destID enode.ID) []byte {
+	h.Reset()
+	h.Write([]byte("discovery v5 identity proof"))
+	h.Write(challenge)
+	h.Write(ephkey)
+	h.Write(destID[:])
+	return h.Sum(nil)
+}
+
+// deriveKeys creates the session keys. The initiator of the handshake is n1,
+// the recipient is n2.
+func deriveKeys(priv *ecdsa.PrivateKey, pub *ecdsa.PublicKey, n1, n2 enode.ID, challenge []byte) *session {
+	const text = "discovery v5 key agreement"
+	info := make([]byte, 0, len(text)+len(n1)+len(n2))
+	info = append(info, text...)
+	info = append(info, n1[:]...)
+	info = append(info, n2[:]...)
+
+	secret := ecdh(priv, pub)
+	if secret == nil {
+		return nil
+	}
+	kdf := hkdf.New(sha256.New, secret, challenge, info)
+	sec := session{writeKey: make([]byte, aes.BlockSize), readKey: make([]byte, aes.BlockSize)}
+	kdf.Read(sec.writeKey)
+	kdf.Read(sec.readKey)
+	return &sec
+}
+
+// ecdh creates a shared secret, which is the compressed curve point of the
+// Diffie-Hellman exchange.
+func ecdh(privkey *ecdsa.PrivateKey, pubkey *ecdsa.PublicKey) []byte {
+	secX, secY := pubkey.ScalarMult(pubkey.X, pubkey.Y, privkey.D.Bytes())
+	if secX == nil {
+		return nil
+	}
+	sec := make([]byte, 33)
+	sec[0] = 0x02 | byte(secY.Bit(0))
+	math.ReadBits(secX, sec[1:])
+	return sec
+}
+
+// encryptGCM encrypts pt using AES-GCM with the given key and nonce. The
+// ciphertext is appended to dest, which must not overlap with plaintext.
+func encryptGCM(dest, key, nonce, plaintext, authData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(fmt.Errorf("can't create block cipher: %v", err))
+	}
+	aesgcm, err := cipher.NewGCMWithNonceSize(block, gcmNonceSize)
+	if err != nil {
+		panic(fmt.Errorf("can't create GCM: %v", err))
+	}
+	return aesgcm.Seal(dest, nonce, plaintext, authData), nil
+}
+
+// decryptGCM decrypts ct using AES-GCM with the given key and nonce.
+func decryptGCM(key, nonce, ct, authData []byte) ([]byte, error) {
+	if len(key) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid key length %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("can't create block cipher: %v", err)
+	}
+	if len(nonce) != gcmNonceSize {
+		return nil, fmt.Errorf("invalid GCM nonce size: %d", len(nonce))
+	}
+	aesgcm, err := cipher.NewGCMWithNonceSize(block, gcmNonceSize)
+	if err != nil {
+		return nil, fmt.Errorf("can't create GCM: %v", err)
+	}
+	pt := make([]byte, 0, len(ct))
+	return aesgcm.Open(pt, nonce, ct, authData)
+}
@@ -0,0 +1,327 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// handshakeTest is a pair of wire codecs talking to each other.
+type handshakeTest struct {
+	nodeA, nodeB handshakeTestNode
+	clock        mclock.Simulated
+}
+
+type handshakeTestNode struct {
+	ln    *enode.LocalNode
+	c     *wireCodec
+	addr  string
+	store *enode.DB
+}
+
+func newHandshakeTest() *handshakeTest {
+	t := new(handshakeTest)
+	t.nodeA.init(newkey(), net.IP{127, 0, 0, 1}, 30303, &t.clock)
+	t.nodeB.init(newkey(), net.IP{127, 0, 0, 1}, 30304, &t.clock)
+	return t
+}
+
+func (n *handshakeTestNode) init(key *ecdsa.PrivateKey, ip net.IP, port int, clock mclock.Clock) {
+	n.store, _ = enode.OpenDB("")
+	n.ln = enode.NewLocalNode(n.store, key)
+	n.ln.SetStaticIP(ip)
+	n.ln.SetFallbackUDP(port)
+	n.addr = (&net.UDPAddr{IP: ip, Port: port}).String()
+	n.c = newWireCodec(n.ln, key, clock)
+}
+
+func (n *handshakeTestNode) id() enode.ID { return n.ln.ID() }
+
+func (n *handshakeTestNode) encode(t *testing.T, to handshakeTestNode, p v5Packet, c *whoareyouV5) []byte {
+	enc, _, err := n.c.encode(to.id(), to.addr, p, c)
+	if err != nil {
+		t.Fatalf("%s encode error: %v", p.name(), err)
+	}
+	return enc
+}
+
+func (n *handshakeTestNode) decode(t *testing.T, from handshakeTestNode, enc []byte) (enode.ID, *enode.Node, v5Packet) {
+	src, node, p, err := n.c.decode(enc, from.addr)
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	return src, node, p
+}
+
+// This test checks the full handshake between two codecs: an undecryptable
+// packet is answered with WHOAREYOU, which is answered by the handshake message
+// establishing the session.
+func TestV5Encoding_handshake(t *testing.T) {
+	ht := newHandshakeTest()
+	defer ht.nodeA.store.Close()
+	defer ht.nodeB.store.Close()
+
+	// A -> B   RANDOM PACKET
+	packet := ht.nodeA.encode(t, ht.nodeB, &pingV5{ReqID: []byte("req")}, nil)
+	src, _, p := ht.nodeB.decode(t, ht.nodeA, packet)
+	if src != ht.nodeA.id() {
+		t.Fatalf("wrong source ID %v", src)
+	}
+	unknown, ok := p.(*unknownV5)
+	if !ok {
+		t.Fatalf("expected unknown packet, got %s", p.name())
+	}
+
+	// A <- B   WHOAREYOU
+	challenge := &whoareyouV5{Nonce: unknown.Nonce, IDNonce: [16]byte{1, 2, 3}}
+	whoareyou := ht.nodeB.encode(t, ht.nodeA, challenge, nil)
+	_, _, p = ht.nodeA.decode(t, ht.nodeB, whoareyou)
+	received, ok := p.(*whoareyouV5)
+	if !ok {
+		t.Fatalf("expected WHOAREYOU, got %s", p.name())
+	}
+	if received.IDNonce != challenge.IDNonce || received.Nonce != unknown.Nonce {
+		t.Fatal("wrong WHOAREYOU content")
+	}
+	if !bytes.Equal(received.ChallengeData, challenge.ChallengeData) {
+		t.Fatal("challenge data mismatch")
+	}
+
+	// A -> B   HANDSHAKE
+	received.Node = ht.nodeB.ln.Node()
+	ping := &pingV5{ReqID: []byte("req"), ENRSeq: 5}
+	handshake := ht.nodeA.encode(t, ht.nodeB, ping, received)
+	_, node, p := ht.nodeB.decode(t, ht.nodeA, handshake)
+	if !reflect.DeepEqual(p, ping) {
+		t.Fatalf("wrong message in handshake: %v", p)
+	}
+	if node == nil || node.ID() != ht.nodeA.id() || node.Seq() != ht.nodeA.ln.Node().Seq() {
+		t.Fatal("handshake didn't contain the record of the initiator")
+	}
+
+	// A <- B   MESSAGE
+	pong := &pongV5{ReqID: []byte("req"), ENRSeq: 1, ToIP: net.IP{127, 0, 0, 1}.To4(), ToPort: 30303}
+	msg := ht.nodeB.encode(t, ht.nodeA, pong, nil)
+	if _, _, p = ht.nodeA.decode(t, ht.nodeB, msg); !reflect.DeepEqual(p, pong) {
+		t.Fatalf("wrong message decoded: %v", p)
+	}
+
+	// A -> B   MESSAGE
+	findnode := &findnodeV5{ReqID: []byte("req2"), Distances: []uint{255, 256}}
+	msg = ht.nodeA.encode(t, ht.nodeB, findnode, nil)
+	if _, _, p = ht.nodeB.decode(t, ht.nodeA, msg); !reflect.DeepEqual(p, findnode) {
+		t.Fatalf("wrong message decoded: %v", p)
+	}
+}
+
+// This test checks that packets are rejected if the signature in the
+// handshake doesn't match the initiator's key.
+func TestV5Encoding_handshakeBadSignature(t *testing.T) {
+	ht := newHandshakeTest()
+	defer ht.nodeA.store.Close()
+	defer ht.nodeB.store.Close()
+
+	challenge := &whoareyouV5{Nonce: [gcmNonceSize]byte{1}, IDNonce: [16]byte{2}}
+	whoareyou := ht.nodeB.encode(t, ht.nodeA, challenge, nil)
+	_, _, p := ht.nodeA.decode(t, ht.nodeB, whoareyou)
+	received := p.(*whoareyouV5)
+	received.Node = ht.nodeB.ln.Node()
+
+	// Sign with the wrong key.
+	ht.nodeA.c.privkey = newkey()
+	handshake := ht.nodeA.encode(t, ht.nodeB, &pingV5{}, received)
+	if _, _, _, err := ht.nodeB.c.decode(handshake, ht.nodeA.addr); err != errInvalidNonceSig {
+		t.Fatalf("wrong error %v, want %v", err, errInvalidNonceSig)
+	}
+}
+
+// This test checks that tampered messages are treated as undecryptable.
+func TestV5Encoding_tamperedMessage(t *testing.T) {
+	ht := newHandshakeTest()
+	defer ht.nodeA.store.Close()
+	defer ht.nodeB.store.Close()
+
+	s := &session{writeKey: make([]byte, 16), readKey: make([]byte, 16)}
+	ht.nodeA.c.sc.storeNewSession(ht.nodeB.id(), ht.nodeB.addr, s)
+	ht.nodeB.c.sc.storeNewSession(ht.nodeA.id(), ht.nodeA.addr, s.keysFlipped())
+
+	msg := ht.nodeA.encode(t, ht.nodeB, &pingV5{ReqID: []byte{1}}, nil)
+	msg[len(msg)-1] ^= 0xff
+	if _, _, p := ht.nodeB.decode(t, ht.nodeA, msg); p.kind() != unknownV5Msg {
+		t.Fatalf("tampered message decoded as %s", p.name())
+	}
+}
+
+func TestV5Encoding_deriveKeys(t *testing.T) {
+	var (
+		keyA, keyB = newkey(), newkey()
+		idA        = enode.PubkeyToIDV4(&keyA.PublicKey)
+		idB        = enode.PubkeyToIDV4(&keyB.PublicKey)
+		challenge  = []byte("challenge")
+	)
+	sA := deriveKeys(keyA, &keyB.PublicKey, idA, idB, challenge)
+	sB := deriveKeys(keyB, &keyA.PublicKey, idA, idB, challenge)
+	if !reflect.DeepEqual(sA, sB) {
+		t.Fatal("derived keys don't match")
+	}
+	if bytes.Equal(sA.writeKey, sA.readKey) {
+		t.Fatal("read and write keys are equal")
+	}
+	other := deriveKeys(keyA, &keyB.PublicKey, idA, idB, []byte("other"))
+	if bytes.Equal(other.writeKey, sA.writeKey) {
+		t.Fatal("keys don't depend on the challenge")
+	}
+}
+
+// The test vectors below are those of the discovery v5.1 wire protocol
+// specification (discv5-wire-test-vectors.md).
+
+func TestV5Encoding_vectorECDH(t *testing.T) {
+	var (
+		staticKey = hexPrivkey("0xfb757dc581730490a1d7a00deea65e9b1936924caaea8f44d476014856b68736")
+		publicKey = hexCompressedPubkey("0x039961e4c2356d61bedb83052c115d311acb3a96f5777296dcf297351130266231")
+		want      = common.FromHex("0x033b11a2a1f214567e1537ce5e509ffd9b21373247f2a3ff6841f4976f53165e7e")
+	)
+	if result := ecdh(staticKey, publicKey); !bytes.Equal(result, want) {
+		t.Fatalf("wrong ecdh result: %x", result)
+	}
+}
+
+func TestV5Encoding_vectorKDF(t *testing.T) {
+	var (
+		ephKey    = hexPrivkey("0xfb757dc581730490a1d7a00deea65e9b1936924caaea8f44d476014856b68736")
+		destKey   = hexCompressedPubkey("0x0317931e6e0840220642f230037d285d122bc59063221ef3226b1f403ddc69ca91")
+		nodeA     = enode.HexID("0xaaaa8419e9f49d0083561b48287df592939a8d19947d8c0ef88f2a4856a69fbb")
+		nodeB     = enode.HexID("0xbbbb9d047f0488c0b5a93c1c3f2d8bafc7c8ff337024a55434a0d0555de64db9")
+		challenge = common.FromHex("0x000000000000000000000000000000006469736376350001010102030405060708090a0b0c00180102030405060708090a0b0c0d0e0f100000000000000000")
+	)
+	s := deriveKeys(ephKey, destKey, nodeA, nodeB, challenge)
+	if want := common.FromHex("0xdccc82d81bd610f4f76d3ebe97a40571"); !bytes.Equal(s.writeKey, want) {
+		t.Errorf("wrong initiator key: %x", s.writeKey)
+	}
+	if want := common.FromHex("0xac74bb8773749920b0d3a8881c173ec5"); !bytes.Equal(s.readKey, want) {
+		t.Errorf("wrong recipient key: %x", s.readKey)
+	}
+}
+
+func TestV5Encoding_vectorIDSignature(t *testing.T) {
+	var (
+		staticKey = hexPrivkey("0xfb757dc581730490a1d7a00deea65e9b1936924caaea8f44d476014856b68736")
+		challenge = common.FromHex("0x000000000000000000000000000000006469736376350001010102030405060708090a0b0c00180102030405060708090a0b0c0d0e0f100000000000000000")
+		ephKey    = common.FromHex("0x039961e4c2356d61bedb83052c115d311acb3a96f5777296dcf297351130266231")
+		nodeB     = enode.HexID("0xbbbb9d047f0488c0b5a93c1c3f2d8bafc7c8ff337024a55434a0d0555de64db9")
+		want      = common.FromHex("0x94852a1e2318c4e5e9d422c98eaf19d1d90d876b29cd06ca7cb7546d0fff7b484fe86c09a064fe72bdbef73ba8e9c34df0cd2b53e9d65528c2c7f336d5dfc6e6")
+	)
+	sig, err := makeIDSignature(sha256.New(), staticKey, challenge, ephKey, nodeB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sig, want) {
+		t.Fatalf("wrong signature: %x", sig)
+	}
+}
+
+func TestV5Encoding_vectorGCM(t *testing.T) {
+	var (
+		key   = common.FromHex("0x9f2d77db7004bf8a1a85107ac686990b")
+		nonce = common.FromHex("0x27b5af763c446acd2749fe8e")
+		pt    = common.FromHex("0x01c20101")
+		ad    = common.FromHex("0x93a7400fa0d6a694ebc24d5cf570f65d04215b6ac00757875e3f3a5f42107903")
+		want  = common.FromHex("0xa5d12a2d94b8ccb3ba55558229867dc13bfa3648")
+	)
+	ct, err := encryptGCM(nil, key, nonce, pt, ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ct, want) {
+		t.Fatalf("wrong ciphertext: %x", ct)
+	}
+}
+
+func hexPrivkey(h string) *ecdsa.PrivateKey {
+	k, err := crypto.ToECDSA(common.FromHex(h))
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+func hexCompressedPubkey(h string) *ecdsa.PublicKey {
+	k, err := crypto.DecompressPubkey(common.FromHex(h))
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+func TestV5Encoding_vectorPackets(t *testing.T) {
+	var (
+		keyA      = hexPrivkey("0xeef77acb6c6a6eebc5b363a475ac583ec7eccdb42b6481424c60f59aa326547f")
+		keyB      = hexPrivkey("0x66fb62bfbd66b9177a138c1e5cddbe4f7c30c343e94e68df8769459cb1cde628")
+		challenge = common.FromHex("0x000000000000000000000000000000006469736376350001010102030405060708090a0b0c00180102030405060708090a0b0c0d0e0f100000000000000001")
+		clock     mclock.Simulated
+		nodeA     handshakeTestNode
+		nodeB     handshakeTestNode
+	)
+	nodeA.init(keyA, net.IP{127, 0, 0, 1}, 30303, &clock)
+	nodeB.init(keyB, net.IP{127, 0, 0, 1}, 30304, &clock)
+	if nodeA.id() != enode.HexID("0xaaaa8419e9f49d0083561b48287df592939a8d19947d8c0ef88f2a4856a69fbb") {
+		t.Fatalf("wrong node A ID %v", nodeA.id())
+	}
+	if nodeB.id() != enode.HexID("0xbbbb9d047f0488c0b5a93c1c3f2d8bafc7c8ff337024a55434a0d0555de64db9") {
+		t.Fatalf("wrong node B ID %v", nodeB.id())
+	}
+
+	// Ordinary message packet.
+	nodeB.c.sc.storeNewSession(nodeA.id(), nodeA.addr, &session{readKey: make([]byte, 16), writeKey: make([]byte, 16)})
+	enc := common.FromHex("00000000000000000000000000000000088b3d4342774649325f313964a39e55ea96c005ad52be8c7560413a7008f16c9e6d2f43bbea8814a546b7409ce783d34c4f53245d08dab84102ed931f66d1492acb308fa1c6715b9d139b81acbdcc")
+	if _, _, p := nodeB.decode(t, nodeA, enc); !reflect.DeepEqual(p, &pingV5{ReqID: []byte{0, 0, 0, 1}, ENRSeq: 2}) {
+		t.Errorf("wrong message packet content: %+v", p)
+	}
+
+	// WHOAREYOU packet.
+	enc = common.FromHex("00000000000000000000000000000000088b3d434277464933a1ccc59f5967ad1d6035f15e528627dde75cd68292f9e6c27d6b66c8100a873fcbaed4e16b8d")
+	want := &whoareyouV5{
+		Nonce:         [gcmNonceSize]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12},
+		IDNonce:       [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		ChallengeData: common.FromHex("0x000000000000000000000000000000006469736376350001010102030405060708090a0b0c00180102030405060708090a0b0c0d0e0f100000000000000000"),
+	}
+	if _, _, p := nodeB.decode(t, nodeA, enc); !reflect.DeepEqual(p, want) {
+		t.Errorf("wrong WHOAREYOU packet content: %+v", p)
+	}
+
+	// Handshake message packet answering a WHOAREYOU with enr-seq 1, the
+	// recipient already has the record of the initiator.
+	nodeB.c.sc.storeSentHandshake(nodeA.id(), nodeA.addr, &whoareyouV5{RecordSeq: 1, Node: nodeA.ln.Node(), ChallengeData: challenge})
+	enc = common.FromHex("00000000000000000000000000000000088b3d4342774649305f313964a39e55ea96c005ad521d8c7560413a7008f16c9e6d2f43bbea8814a546b7409ce783d34c4f53245d08da4bb252012b2cba3f4f374a90a75cff91f142fa9be3e0a5f3ef268ccb9065aeecfd67a999e7fdc137e062b2ec4a0eb92947f0d9a74bfbf44dfba776b21301f8b65efd5796706adff216ab862a9186875f9494150c4ae06fa4d1f0396c93f215fa4ef524f1eadf5f0f4126b79336671cbcf7a885b1f8bd2a5d839cf8")
+	if _, _, p := nodeB.decode(t, nodeA, enc); !reflect.DeepEqual(p, &pingV5{ReqID: []byte{0, 0, 0, 1}, ENRSeq: 1}) {
+		t.Errorf("wrong handshake packet content: %+v", p)
+	}
+	if key := nodeB.c.sc.readKey(nodeA.id(), nodeA.addr); !bytes.Equal(key, common.FromHex("0x4f9fac6de7567d1e3b1241dffe90f662")) {
+		t.Errorf("wrong session read key %x", key)
+	}
+}
@@ -0,0 +1,468 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	crand "crypto/rand"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	topicAdLifetime  = 15 * time.Minute // Time a registration is kept by a registrar
+	topicQueueLimit  = 100              // Maximum number of ads per topic
+	topicTableLimit  = 5000             // Maximum number of ads across all topics
+	topicQueryLimit  = 15               // Maximum number of nodes returned by a topic query
+	maxTopicLength   = 64               // Maximum length of a topic name
+	ticketWindow     = 10 * time.Second // Time after the wait period in which a ticket can be used
+	maxTicketWait    = topicAdLifetime  // Registration attempts requiring a longer wait are abandoned
+	topicRegistrars  = 8                // Number of nodes a topic is registered with
+	topicRefresh     = topicAdLifetime - time.Minute
+	topicLookupDelay = 30 * time.Second // Time between lookups for more registrars
+)
+
+var errInvalidTicket = errors.New("invalid ticket")
+
+// Topic is a service identifier that nodes can advertise themselves for.
+// Topic advertisements are placed on the nodes closest to the hash of the
+// topic, which answer topic queries with the nodes registered with them.
+type Topic string
+
+// key returns the lookup target of the topic.
+func (t Topic) key() (k encPubkey) {
+	copy(k[:], crypto.Keccak512([]byte(t)))
+	return k
+}
+
+// topicAd is a registration stored by a registrar.
+type topicAd struct {
+	node    *enode.Node
+	expires mclock.AbsTime
+}
+
+// topicTable stores the topic advertisements placed on the local node. Ads are
+// kept in a FIFO queue per topic and removed when their lifetime expires. When
+// a queue or the whole table is full, new registrants have to wait until the
+// oldest ad expires, which is communicated to them through a ticket.
+type topicTable struct {
+	clock  mclock.Clock
+	queues map[Topic][]*topicAd
+	count  int
+}
+
+func newTopicTable(clock mclock.Clock) *topicTable {
+	return &topicTable{clock: clock, queues: make(map[Topic][]*topicAd)}
+}
+
+// expire drops all ads whose lifetime has ended.
+func (tt *topicTable) expire(now mclock.AbsTime) {
+	for topic, queue := range tt.queues {
+		i := 0
+		for i < len(queue) && queue[i].expires <= now {
+			i++
+		}
+		tt.count -= i
+		if i == len(queue) {
+			delete(tt.queues, topic)
+		} else {
+			tt.queues[topic] = queue[i:]
+		}
+	}
+}
+
+// waitTime returns the time a new registrant of the topic has to wait until
+// there is room for its ad.
+func (tt *topicTable) waitTime(topic Topic, now mclock.AbsTime) time.Duration {
+	var wait time.Duration
+	if queue := tt.queues[topic]; len(queue) >= topicQueueLimit {
+		wait = time.Duration(queue[0].expires - now)
+	}
+	if tt.count >= topicTableLimit {
+		for _, queue := range tt.queues {
+			if w := time.Duration(queue[0].expires - now); wait == 0 || w < wait {
+				wait = w
+			}
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// register places an ad for the node. Existing ads of the node are refreshed.
+// It returns false if there is no room for the ad, unless force is set, which
+// makes room by dropping the oldest ad.
+func (tt *topicTable) register(topic Topic, n *enode.Node, now mclock.AbsTime, force bool) bool {
+	queue := tt.queues[topic]
+	for i, ad := range queue {
+		if ad.node.ID() == n.ID() {
+			queue = append(queue[:i], queue[i+1:]...)
+			tt.queues[topic] = append(queue, &topicAd{n, now + mclock.AbsTime(topicAdLifetime)})
+			return true
+		}
+	}
+	if tt.waitTime(topic, now) > 0 {
+		if !force {
+			return false
+		}
+		tt.dropOldest(topic)
+		queue = tt.queues[topic]
+	}
+	tt.queues[topic] = append(queue, &topicAd{n, now + mclock.AbsTime(topicAdLifetime)})
+	tt.count++
+	return true
+}
+
+// dropOldest removes the oldest ad of the topic, or the oldest ad of any topic
+// if the topic queue has room but the table is full.
+func (tt *topicTable) dropOldest(topic Topic) {
+	if len(tt.queues[topic]) < topicQueueLimit {
+		for t, queue := range tt.queues {
+			if len(tt.queues[topic]) == 0 || queue[0].expires < tt.queues[topic][0].expires {
+				topic = t
+			}
+		}
+	}
+	if queue := tt.queues[topic]; len(queue) > 0 {
+		tt.count--
+		if len(queue) == 1 {
+			delete(tt.queues, topic)
+		} else {
+			tt.queues[topic] = queue[1:]
+		}
+	}
+}
+
+// query returns the most recently registered nodes of the topic.
+func (tt *topicTable) query(topic Topic, limit int) []*enode.Node {
+	queue := tt.queues[topic]
+	nodes := make([]*enode.Node, 0, limit)
+	for i := len(queue) - 1; i >= 0 && len(nodes) < limit; i-- {
+		nodes = append(nodes, queue[i].node)
+	}
+	return nodes
+}
+
+// ticket is the content of a registration ticket. Tickets are encrypted with a
+// key known only to the registrar, so they can't be forged by the registrant.
+type ticket struct {
+	Topic  string
+	NodeID enode.ID
+	IP     net.IP
+	Issued uint64 // mclock time of issuance
+	Wait   uint64 // wait period in nanoseconds
+}
+
+// sealTicket encrypts a ticket.
+func (t *UDPv5) sealTicket(tk *ticket) ([]byte, error) {
+	enc, err := rlp.EncodeToBytes(tk)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcmNonceSize)
+	if _, err := crand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return encryptGCM(nonce, t.ticketKey, nonce, enc, nil)
+}
+
+// openTicket decrypts a ticket created by sealTicket.
+func (t *UDPv5) openTicket(data []byte) (*ticket, error) {
+	if len(data) < gcmNonceSize {
+		return nil, errInvalidTicket
+	}
+	enc, err := decryptGCM(t.ticketKey, data[:gcmNonceSize], data[gcmNonceSize:], nil)
+	if err != nil {
+		return nil, errInvalidTicket
+	}
+	tk := new(ticket)
+	if err := rlp.DecodeBytes(enc, tk); err != nil {
+		return nil, errInvalidTicket
+	}
+	return tk, nil
+}
+
+// RegisterTopic advertises the local node for the given topic on the nodes
+// closest to the topic hash. Registrations are renewed before they expire
+// until the stop channel is closed.
+func (t *UDPv5) RegisterTopic(topic Topic, stop <-chan struct{}) {
+	var (
+		registrars = make(map[enode.ID]struct{})
+		done       = make(chan enode.ID)
+		cancel     = make(chan struct{})
+		lookup     = time.NewTimer(0)
+	)
+	defer lookup.Stop()
+	defer close(cancel)
+
+	for {
+		select {
+		case <-lookup.C:
+			for _, n := range t.lookupTopic(topic) {
+				if len(registrars) >= topicRegistrars {
+					break
+				}
+				if _, ok := registrars[n.ID()]; ok {
+					continue
+				}
+				registrars[n.ID()] = struct{}{}
+				go t.registerAt(n, topic, cancel, done)
+			}
+			lookup.Reset(topicLookupDelay)
+		case id := <-done:
+			delete(registrars, id)
+		case <-stop:
+			return
+		case <-t.closing:
+			return
+		}
+	}
+}
+
+// registerAt keeps the local node registered for the topic at a single
+// registrar. It returns when the registrar stops cooperating.
+func (t *UDPv5) registerAt(n *enode.Node, topic Topic, cancel <-chan struct{}, done chan<- enode.ID) {
+	defer func() {
+		select {
+		case done <- n.ID():
+		case <-cancel:
+		}
+	}()
+
+	var (
+		tk    []byte
+		timer = time.NewTimer(0)
+	)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-cancel:
+			return
+		case <-t.closing:
+			return
+		}
+		ok, newTicket, wait, err := t.regtopic(n, topic, tk)
+		switch {
+		case err != nil:
+			log.Debug("Topic registration failed", "topic", topic, "id", n.ID(), "err", err)
+			return
+		case ok:
+			log.Trace("Registered topic", "topic", topic, "id", n.ID())
+			tk = nil
+			timer.Reset(topicRefresh)
+		case wait > maxTicketWait:
+			log.Debug("Topic registration wait too long", "topic", topic, "id", n.ID(), "wait", wait)
+			return
+		default:
+			tk = newTicket
+			timer.Reset(wait)
+		}
+	}
+}
+
+// SearchTopic periodically looks up the nodes registered for the given topic.
+// Found nodes are sent on the found channel and the outcome of every search
+// round on the lookup channel. The search starts when the first period is
+// received on setPeriod and terminates when setPeriod is closed.
+func (t *UDPv5) SearchTopic(topic Topic, setPeriod <-chan time.Duration, found chan<- *enode.Node, lookup chan<- bool) {
+	var (
+		period time.Duration
+		timer  = time.NewTimer(0)
+	)
+	<-timer.C
+	defer timer.Stop()
+
+	for {
+		select {
+		case p, ok := <-setPeriod:
+			if !ok {
+				return
+			}
+			if period == 0 {
+				timer.Reset(0)
+			}
+			period = p
+		case <-timer.C:
+			nodes := t.searchTopic(topic)
+			for _, n := range nodes {
+				select {
+				case found <- n:
+				case <-t.closing:
+					return
+				}
+			}
+			select {
+			case lookup <- len(nodes) > 0:
+			case <-t.closing:
+				return
+			}
+			timer.Reset(period)
+		case <-t.closing:
+			return
+		}
+	}
+}
+
+// searchTopic queries the registrars of the topic.
+func (t *UDPv5) searchTopic(topic Topic) []*enode.Node {
+	var (
+		registrars = t.lookupTopic(topic)
+		results    = make(chan []*enode.Node, len(registrars))
+		seen       = make(map[enode.ID]bool)
+		nodes      []*enode.Node
+	)
+	for _, n := range registrars {
+		go func(n *enode.Node) {
+			ns, err := t.topicQuery(n, topic)
+			if err != nil {
+				log.Trace("Topic query failed", "topic", topic, "id", n.ID(), "err", err)
+			}
+			results <- ns
+		}(n)
+	}
+	for range registrars {
+		for _, n := range <-results {
+			if !seen[n.ID()] && n.ID() != t.localNode.ID() {
+				seen[n.ID()] = true
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	return nodes
+}
+
+// lookupTopic finds the nodes closest to the topic hash.
+func (t *UDPv5) lookupTopic(topic Topic) []*enode.Node {
+	return unwrapNodes(t.tab.lookup(topic.key(), true))
+}
+
+// regtopic sends a REGTOPIC request. It returns whether the registration was
+// confirmed, or the ticket and wait time to use for the next attempt.
+func (t *UDPv5) regtopic(n *enode.Node, topic Topic, tk []byte) (bool, []byte, time.Duration, error) {
+	req := &regtopicV5{Topic: string(topic), ENR: t.localNode.Node().Record(), Ticket: tk}
+	c := t.call(n, req, ticketV5Msg, regconfirmationV5Msg)
+	defer t.callDone(c)
+
+	select {
+	case resp := <-c.ch:
+		if resp, ok := resp.(*ticketV5); ok {
+			return false, resp.Ticket, time.Duration(resp.WaitTime) * time.Second, nil
+		}
+		return true, nil, 0, nil
+	case err := <-c.err:
+		return false, nil, 0, err
+	}
+}
+
+// topicQuery sends a TOPICQUERY request.
+func (t *UDPv5) topicQuery(n *enode.Node, topic Topic) ([]*enode.Node, error) {
+	c := t.call(n, &topicqueryV5{Topic: string(topic)}, nodesV5Msg)
+	return t.waitForNodes(c, nil)
+}
+
+// REGTOPIC
+
+func (p *regtopicV5) handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	topic := Topic(p.Topic)
+	if len(topic) == 0 || len(topic) > maxTopicLength || p.ENR == nil {
+		log.Debug("Invalid "+p.name(), "id", fromID, "addr", fromAddr, "topic", topic)
+		return
+	}
+	// The advertised record must belong to the sender and be reachable.
+	n, err := enode.New(enode.ValidSchemes, p.ENR)
+	if err != nil || n.ID() != fromID || !n.IP().Equal(fromAddr.IP) || n.UDP() != fromAddr.Port {
+		log.Debug("Invalid record in "+p.name(), "id", fromID, "addr", fromAddr, "err", err)
+		return
+	}
+	now := t.clock.Now()
+	t.topics.expire(now)
+
+	// Ads are placed without a ticket while there is room. Otherwise the
+	// registrant receives a ticket for the time when room becomes available.
+	// Holders of a ticket whose wait period has just passed are served first.
+	var ticketValid bool
+	if len(p.Ticket) > 0 {
+		tk, err := t.openTicket(p.Ticket)
+		if err != nil || tk.Topic != p.Topic || tk.NodeID != fromID || !tk.IP.Equal(fromAddr.IP) {
+			log.Debug("Invalid ticket in "+p.name(), "id", fromID, "addr", fromAddr)
+			return
+		}
+		start := mclock.AbsTime(tk.Issued + tk.Wait)
+		ticketValid = now >= start && now <= start+mclock.AbsTime(ticketWindow)
+		if !ticketValid {
+			log.Debug("Ticket used outside its window", "id", fromID, "addr", fromAddr)
+		}
+	}
+	if t.topics.register(topic, n, now, ticketValid) {
+		t.putNode(n)
+		t.sendResponse(fromID, fromAddr, &regconfirmationV5{ReqID: p.ReqID, Topic: p.Topic})
+		return
+	}
+	wait := t.topics.waitTime(topic, now)
+	sealed, err := t.sealTicket(&ticket{
+		Topic:  p.Topic,
+		NodeID: fromID,
+		IP:     fromAddr.IP,
+		Issued: uint64(now),
+		Wait:   uint64(wait),
+	})
+	if err != nil {
+		log.Warn("Can't create ticket", "err", err)
+		return
+	}
+	// Round up so the registrant doesn't come back too early.
+	secs := uint((wait + time.Second - 1) / time.Second)
+	t.sendResponse(fromID, fromAddr, &ticketV5{ReqID: p.ReqID, Ticket: sealed, WaitTime: secs})
+}
+
+// TICKET
+
+func (p *ticketV5) handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	t.handleCallResponse(fromID, fromAddr, p.ReqID, p)
+}
+
+// REGCONFIRMATION
+
+func (p *regconfirmationV5) handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	t.handleCallResponse(fromID, fromAddr, p.ReqID, p)
+}
+
+// TOPICQUERY
+
+func (p *topicqueryV5) handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	t.topics.expire(t.clock.Now())
+
+	var nodes []*enode.Node
+	for _, n := range t.topics.query(Topic(p.Topic), topicQueryLimit) {
+		if netutil.CheckRelayIP(fromAddr.IP, n.IP()) == nil {
+			nodes = append(nodes, n)
+		}
+	}
+	for _, resp := range packNodes(p.ReqID, nodes) {
+		t.sendResponse(fromID, fromAddr, resp)
+	}
+}
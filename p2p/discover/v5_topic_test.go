@@ -0,0 +1,101 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+)
+
+func TestTopicTable(t *testing.T) {
+	var (
+		clock mclock.Simulated
+		tt    = newTopicTable(&clock)
+		topic = Topic("foo")
+		first = newLocalhostNode(t, 30303)
+	)
+	// Fill the queue, the first ad being a minute older than the rest.
+	if !tt.register(topic, first, clock.Now(), false) {
+		t.Fatal("registration failed on empty table")
+	}
+	clock.Run(time.Minute)
+	for i := 1; i < topicQueueLimit; i++ {
+		if !tt.register(topic, newLocalhostNode(t, 30303+i), clock.Now(), false) {
+			t.Fatalf("registration %d failed", i)
+		}
+	}
+	// The queue is full, new registrants have to wait for the first ad to expire.
+	n := newLocalhostNode(t, 40000)
+	if tt.register(topic, n, clock.Now(), false) {
+		t.Fatal("registration succeeded on full queue")
+	}
+	if wait := tt.waitTime(topic, clock.Now()); wait != topicAdLifetime-time.Minute {
+		t.Fatalf("wrong wait time %v", wait)
+	}
+	// Other topics are not affected.
+	if tt.waitTime(Topic("bar"), clock.Now()) != 0 {
+		t.Fatal("non-zero wait time for empty topic")
+	}
+	// Once the oldest ad expires, there is room again.
+	clock.Run(topicAdLifetime - time.Minute)
+	tt.expire(clock.Now())
+	if tt.count != topicQueueLimit-1 {
+		t.Fatalf("wrong ad count %d after expiry", tt.count)
+	}
+	if !tt.register(topic, n, clock.Now(), false) {
+		t.Fatal("registration failed after expiry")
+	}
+	// Refreshing an ad works even if the queue is full.
+	second := tt.queues[topic][0].node
+	if !tt.register(topic, second, clock.Now(), false) {
+		t.Fatal("refresh failed on full queue")
+	}
+	if q := tt.query(topic, 1); len(q) != 1 || q[0].ID() != second.ID() {
+		t.Fatal("refreshed ad is not the most recent one")
+	}
+	if tt.count != topicQueueLimit {
+		t.Fatalf("wrong ad count %d after refresh", tt.count)
+	}
+}
+
+func TestTopicTableForce(t *testing.T) {
+	var (
+		clock mclock.Simulated
+		tt    = newTopicTable(&clock)
+		topic = Topic("foo")
+		first = newLocalhostNode(t, 30303)
+	)
+	tt.register(topic, first, clock.Now(), false)
+	for i := 1; i < topicQueueLimit; i++ {
+		tt.register(topic, newLocalhostNode(t, 30303+i), clock.Now(), false)
+	}
+	// Forced registration drops the oldest ad.
+	n := newLocalhostNode(t, 40000)
+	if !tt.register(topic, n, clock.Now(), true) {
+		t.Fatal("forced registration failed")
+	}
+	if tt.count != topicQueueLimit {
+		t.Fatalf("wrong ad count %d", tt.count)
+	}
+	for _, qn := range tt.query(topic, topicQueueLimit) {
+		if qn.ID() == first.ID() {
+			t.Fatal("oldest ad not dropped")
+		}
+	}
+}
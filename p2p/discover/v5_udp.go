@@ -0,0 +1,789 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+const (
+	lookupRequestLimit      = 3  // max requests against a single node during lookup
+	findnodeResultLimit     = 15 // applies in FINDNODE handler
+	totalNodesResponseLimit = 5  // applies in waitForNodes
+	nodesResponseItemLimit  = 3  // applies in sendNodes
+
+	respTimeoutV5  = 700 * time.Millisecond
+	knownNodeLimit = 1024 // max number of node records kept for session setup
+)
+
+// Errors
+var (
+	errChallengeNoCall = errors.New("no matching call")
+	errChallengeTwice  = errors.New("second handshake")
+)
+
+// UDPv5 implements the discovery v5 wire protocol. It shares the Kademlia table
+// implementation with discovery v4, but talks to remote nodes using encrypted
+// sessions and supports topic-based service advertisement.
+type UDPv5 struct {
+	// static fields
+	conn        conn
+	tab         *Table
+	netrestrict *netutil.Netlist
+	priv        *ecdsa.PrivateKey
+	localNode   *enode.LocalNode
+	db          *enode.DB
+	clock       mclock.Clock
+
+	// known node records, used to establish sessions
+	nodesMu sync.Mutex
+	nodes   *simplelru.LRU
+
+	// topic advertisement
+	topics    *topicTable
+	ticketKey []byte
+
+	// channels into dispatch
+	packetInCh    chan ReadPacket
+	readNextCh    chan struct{}
+	callCh        chan *callV5
+	callDoneCh    chan *callV5
+	respTimeoutCh chan *callTimeout
+
+	// state of dispatch
+	codec            *wireCodec
+	activeCallByNode map[enode.ID]*callV5
+	activeCallByAuth map[[gcmNonceSize]byte]*callV5
+	callQueue        map[enode.ID][]*callV5
+
+	// shutdown stuff
+	closeOnce sync.Once
+	closing   chan struct{}
+	wg        sync.WaitGroup
+}
+
+// callV5 represents a remote procedure call against another node.
+type callV5 struct {
+	node          *enode.Node
+	packet        v5Packet
+	responseTypes []byte // expected packet types of the response
+	reqid         []byte
+	ch            chan v5Packet // responses sent here
+	err           chan error    // errors sent here
+
+	// Valid for active calls only:
+	nonce          [gcmNonceSize]byte // nonce of request packet
+	handshakeCount int                // # times we attempted handshake for this call
+	challenge      *whoareyouV5       // last sent handshake challenge
+	timeout        *time.Timer
+	timeoutSeq     uint64 // identifies the current response timer
+}
+
+// callTimeout is the response timeout event of a call.
+type callTimeout struct {
+	c   *callV5
+	seq uint64
+}
+
+// ListenV5 listens on the given connection.
+func ListenV5(conn conn, ln *enode.LocalNode, cfg Config) (*UDPv5, error) {
+	t, err := newUDPv5(conn, ln, cfg)
+	if err != nil {
+		return nil, err
+	}
+	t.wg.Add(2)
+	go t.readLoop()
+	go t.dispatch()
+	return t, nil
+}
+
+// newUDPv5 creates a UDPv5 transport, but doesn't start any goroutines.
+func newUDPv5(conn conn, ln *enode.LocalNode, cfg Config) (*UDPv5, error) {
+	nodes, err := simplelru.NewLRU(knownNodeLimit, nil)
+	if err != nil {
+		return nil, err
+	}
+	ticketKey := make([]byte, 16)
+	if _, err := crand.Read(ticketKey); err != nil {
+		return nil, err
+	}
+	clock := mclock.System{}
+	t := &UDPv5{
+		// static fields
+		conn:        conn,
+		localNode:   ln,
+		db:          ln.Database(),
+		netrestrict: cfg.NetRestrict,
+		priv:        cfg.PrivateKey,
+		clock:       clock,
+		nodes:       nodes,
+		topics:      newTopicTable(clock),
+		ticketKey:   ticketKey,
+		// channels into dispatch
+		packetInCh:    make(chan ReadPacket, 1),
+		readNextCh:    make(chan struct{}, 1),
+		callCh:        make(chan *callV5),
+		callDoneCh:    make(chan *callV5),
+		respTimeoutCh: make(chan *callTimeout),
+		// state of dispatch
+		codec:            newWireCodec(ln, cfg.PrivateKey, clock),
+		activeCallByNode: make(map[enode.ID]*callV5),
+		activeCallByAuth: make(map[[gcmNonceSize]byte]*callV5),
+		callQueue:        make(map[enode.ID][]*callV5),
+		// shutdown
+		closing: make(chan struct{}),
+	}
+	for _, n := range cfg.Bootnodes {
+		t.putNode(n)
+	}
+	tab, err := newTable(t, t.db, cfg.Bootnodes)
+	if err != nil {
+		return nil, err
+	}
+	t.tab = tab
+	return t, nil
+}
+
+// Self returns the local node record.
+func (t *UDPv5) Self() *enode.Node {
+	return t.localNode.Node()
+}
+
+// Close shuts down packet processing.
+func (t *UDPv5) Close() {
+	t.closeOnce.Do(func() {
+		t.tab.Close()
+	})
+}
+
+// Ping sends a ping message to the given node.
+func (t *UDPv5) Ping(n *enode.Node) error {
+	t.putNode(n)
+	_, err := t.pingV5(n)
+	return err
+}
+
+// Resolve searches for a specific node with the given ID and tries to get the most recent
+// version of the node record for it. It returns n if the node could not be resolved.
+func (t *UDPv5) Resolve(n *enode.Node) *enode.Node {
+	if rn := t.tab.Resolve(n); rn != nil {
+		n = rn
+	}
+	// Try asking directly. This works if the node is still responding on the endpoint we have.
+	if rn, err := t.RequestENR(n); err == nil {
+		return rn
+	}
+	return n
+}
+
+// RequestENR requests n's record.
+func (t *UDPv5) RequestENR(n *enode.Node) (*enode.Node, error) {
+	t.putNode(n)
+	return t.requestENR(n)
+}
+
+// LookupRandom finds random nodes in the network.
+func (t *UDPv5) LookupRandom() []*enode.Node {
+	return t.tab.LookupRandom()
+}
+
+// RandomNodes returns an iterator which yields the results of random lookups.
+func (t *UDPv5) RandomNodes() enode.Iterator {
+	return t.tab.RandomNodes()
+}
+
+// ReadRandomNodes reads random nodes from the local table.
+func (t *UDPv5) ReadRandomNodes(buf []*enode.Node) int {
+	return t.tab.ReadRandomNodes(buf)
+}
+
+// self implements transport.
+func (t *UDPv5) self() *enode.Node {
+	return t.localNode.Node()
+}
+
+// close implements transport.
+func (t *UDPv5) close() {
+	close(t.closing)
+	t.conn.Close()
+	t.wg.Wait()
+}
+
+// ping implements transport. It is called by the table to revalidate nodes.
+func (t *UDPv5) ping(toid enode.ID, toaddr *net.UDPAddr) error {
+	n := t.getNode(toid)
+	if n == nil {
+		return errUnknownNode
+	}
+	seq, err := t.pingV5(n)
+	if err != nil {
+		return err
+	}
+	// Refresh the cached record if the remote node has a newer one.
+	if seq > n.Seq() {
+		if rn, err := t.requestENR(n); err == nil {
+			t.putNode(rn)
+		}
+	}
+	return nil
+}
+
+// findnode implements transport. It asks the node for the buckets around the
+// lookup target.
+func (t *UDPv5) findnode(toid enode.ID, toaddr *net.UDPAddr, target encPubkey) ([]*node, error) {
+	n := t.getNode(toid)
+	if n == nil {
+		return nil, errUnknownNode
+	}
+	nodes, err := t.findnodeV5(n, lookupDistances(target.id(), toid))
+	return wrapNodes(nodes), err
+}
+
+// requestENR implements transport.
+func (t *UDPv5) requestENR(n *enode.Node) (*enode.Node, error) {
+	nodes, err := t.findnodeV5(n, []uint{0})
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) != 1 {
+		return nil, fmt.Errorf("%d nodes in response for distance zero", len(nodes))
+	}
+	if nodes[0].Seq() < n.Seq() {
+		return n, nil // response record is older
+	}
+	return nodes[0], nil
+}
+
+// lookupDistances computes the distance parameter for FINDNODE calls to dest.
+// It chooses distances adjacent to logdist(target, dest), e.g. for a target
+// with logdist(target, dest) = 255 the result is [255, 256, 254].
+func lookupDistances(target, dest enode.ID) (dists []uint) {
+	td := enode.LogDist(target, dest)
+	dists = append(dists, uint(td))
+	for i := 1; len(dists) < lookupRequestLimit; i++ {
+		if td+i <= 256 {
+			dists = append(dists, uint(td+i))
+		}
+		if td-i > 0 {
+			dists = append(dists, uint(td-i))
+		}
+	}
+	return dists
+}
+
+// pingV5 sends a PING to the given node and returns the ENR sequence number
+// of the response.
+func (t *UDPv5) pingV5(n *enode.Node) (uint64, error) {
+	c := t.call(n, &pingV5{ENRSeq: t.localNode.Node().Seq()}, pongV5Msg)
+	defer t.callDone(c)
+
+	select {
+	case resp := <-c.ch:
+		return resp.(*pongV5).ENRSeq, nil
+	case err := <-c.err:
+		return 0, err
+	}
+}
+
+// findnodeV5 performs a FINDNODE request for the given distances.
+func (t *UDPv5) findnodeV5(n *enode.Node, distances []uint) ([]*enode.Node, error) {
+	c := t.call(n, &findnodeV5{Distances: distances}, nodesV5Msg)
+	return t.waitForNodes(c, distances)
+}
+
+// waitForNodes waits for NODES responses to the given call. If distances is
+// non-nil, the returned nodes must be at one of the requested distances from
+// the remote node.
+func (t *UDPv5) waitForNodes(c *callV5, distances []uint) ([]*enode.Node, error) {
+	defer t.callDone(c)
+
+	var (
+		nodes           []*enode.Node
+		seen            = make(map[enode.ID]struct{})
+		received, total = 0, -1
+	)
+	for {
+		select {
+		case resp := <-c.ch:
+			response := resp.(*nodesV5)
+			for _, record := range response.Nodes {
+				node, err := t.verifyResponseNode(c, record, distances, seen)
+				if err != nil {
+					log.Debug("Invalid record in "+response.name(), "id", c.node.ID(), "err", err)
+					continue
+				}
+				nodes = append(nodes, node)
+			}
+			if total == -1 {
+				total = int(response.Total)
+				if total > totalNodesResponseLimit {
+					total = totalNodesResponseLimit
+				}
+			}
+			if received++; received >= total {
+				return nodes, nil
+			}
+		case err := <-c.err:
+			return nodes, err
+		}
+	}
+}
+
+// verifyResponseNode checks validity of a record in a NODES response.
+func (t *UDPv5) verifyResponseNode(c *callV5, r *enr.Record, distances []uint, seen map[enode.ID]struct{}) (*enode.Node, error) {
+	node, err := enode.New(enode.ValidSchemes, r)
+	if err != nil {
+		return nil, err
+	}
+	if err := node.ValidateComplete(); err != nil {
+		return nil, err
+	}
+	if node.UDP() <= 1024 {
+		return nil, errors.New("low port")
+	}
+	if err := netutil.CheckRelayIP(c.node.IP(), node.IP()); err != nil {
+		return nil, err
+	}
+	if t.netrestrict != nil && !t.netrestrict.Contains(node.IP()) {
+		return nil, errors.New("not contained in netrestrict whitelist")
+	}
+	if distances != nil && !containsUint(uint(enode.LogDist(c.node.ID(), node.ID())), distances) {
+		return nil, errors.New("does not match any requested distance")
+	}
+	if _, ok := seen[node.ID()]; ok {
+		return nil, errors.New("duplicate record")
+	}
+	seen[node.ID()] = struct{}{}
+	t.putNode(node)
+	return node, nil
+}
+
+func containsUint(x uint, xs []uint) bool {
+	for _, v := range xs {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// call sends the given call and sets up a handler for response packets (of
+// the given types). Responses are dispatched to the call's response channel.
+func (t *UDPv5) call(node *enode.Node, packet v5Packet, responseTypes ...byte) *callV5 {
+	c := &callV5{
+		node:          node,
+		packet:        packet,
+		responseTypes: responseTypes,
+		reqid:         make([]byte, 8),
+		ch:            make(chan v5Packet, 1),
+		err:           make(chan error, 1),
+	}
+	// Assign request ID.
+	crand.Read(c.reqid)
+	packet.setreqid(c.reqid)
+	// Send call to dispatch.
+	select {
+	case t.callCh <- c:
+	case <-t.closing:
+		c.err <- errClosed
+	}
+	return c
+}
+
+// callDone tells dispatch that the active call is done.
+func (t *UDPv5) callDone(c *callV5) {
+	// This needs a loop because further responses may be incoming until the
+	// send to callDoneCh has completed. Such responses need to be discarded
+	// in order to avoid blocking the dispatch loop.
+	for {
+		select {
+		case <-c.ch:
+			// late response, discard.
+		case <-c.err:
+			// late error, discard.
+		case t.callDoneCh <- c:
+			return
+		case <-t.closing:
+			return
+		}
+	}
+}
+
+// dispatch runs in its own goroutine, handles incoming packets and deals with calls.
+//
+// For any destination node there is at most one 'active call', stored in the
+// activeCallByNode map. A call is made active when it is sent. The active call
+// can be answered by a matching response, in which case c.ch receives the
+// response; or by timing out, in which case c.err receives the error. When
+// the function that created the call signals the active call is done through
+// callDone, the next call from the call queue is started.
+//
+// Calls may also be answered by a WHOAREYOU packet referencing the call packet's
+// authentication nonce. When that happens the call is simply re-sent with a
+// handshake. We allow one handshake attempt per call.
+func (t *UDPv5) dispatch() {
+	defer t.wg.Done()
+
+	// Arm first read.
+	t.readNextCh <- struct{}{}
+
+	for {
+		select {
+		case c := <-t.callCh:
+			id := c.node.ID()
+			t.callQueue[id] = append(t.callQueue[id], c)
+			t.sendNextCall(id)
+
+		case ct := <-t.respTimeoutCh:
+			active := t.activeCallByNode[ct.c.node.ID()]
+			if ct.c == active && ct.seq == active.timeoutSeq {
+				ct.c.err <- errTimeout
+			}
+
+		case c := <-t.callDoneCh:
+			id := c.node.ID()
+			active := t.activeCallByNode[id]
+			if active != c {
+				panic("BUG: callDone for inactive call")
+			}
+			c.timeout.Stop()
+			delete(t.activeCallByAuth, c.nonce)
+			delete(t.activeCallByNode, id)
+			t.sendNextCall(id)
+
+		case p := <-t.packetInCh:
+			t.handlePacket(p.Data, p.Addr)
+			// Arm next read.
+			t.readNextCh <- struct{}{}
+
+		case <-t.closing:
+			close(t.readNextCh)
+			for id, queue := range t.callQueue {
+				for _, c := range queue {
+					c.err <- errClosed
+				}
+				delete(t.callQueue, id)
+			}
+			for id, c := range t.activeCallByNode {
+				c.err <- errClosed
+				c.timeout.Stop()
+				delete(t.activeCallByNode, id)
+				delete(t.activeCallByAuth, c.nonce)
+			}
+			return
+		}
+	}
+}
+
+// startResponseTimeout sets the response timer for a call.
+func (t *UDPv5) startResponseTimeout(c *callV5) {
+	if c.timeout != nil {
+		c.timeout.Stop()
+	}
+	c.timeoutSeq++
+	ct := &callTimeout{c, c.timeoutSeq}
+	c.timeout = time.AfterFunc(respTimeoutV5, func() {
+		select {
+		case t.respTimeoutCh <- ct:
+		case <-t.closing:
+		}
+	})
+}
+
+// sendNextCall sends the next call in the call queue if there is no active call.
+func (t *UDPv5) sendNextCall(id enode.ID) {
+	queue := t.callQueue[id]
+	if len(queue) == 0 || t.activeCallByNode[id] != nil {
+		return
+	}
+	t.activeCallByNode[id] = queue[0]
+	t.sendCall(t.activeCallByNode[id])
+	if len(queue) == 1 {
+		delete(t.callQueue, id)
+	} else {
+		copy(queue, queue[1:])
+		t.callQueue[id] = queue[:len(queue)-1]
+	}
+}
+
+// sendCall encodes and sends a request packet to the call's recipient node.
+// This performs a handshake if needed.
+func (t *UDPv5) sendCall(c *callV5) {
+	// The call might have a nonce from a previous handshake attempt. Remove the entry for
+	// the old nonce because we're about to generate a new nonce for this call.
+	if c.nonce != ([gcmNonceSize]byte{}) {
+		delete(t.activeCallByAuth, c.nonce)
+	}
+	addr := &net.UDPAddr{IP: c.node.IP(), Port: c.node.UDP()}
+	newNonce, _ := t.send(c.node.ID(), addr, c.packet, c.challenge)
+	c.nonce = newNonce
+	t.activeCallByAuth[newNonce] = c
+	t.startResponseTimeout(c)
+}
+
+// sendResponse sends a response packet to the given node.
+// This doesn't trigger a handshake even if no keys are available.
+func (t *UDPv5) sendResponse(toID enode.ID, toAddr *net.UDPAddr, packet v5Packet) error {
+	_, err := t.send(toID, toAddr, packet, nil)
+	return err
+}
+
+// send sends a packet to the given node.
+func (t *UDPv5) send(toID enode.ID, toAddr *net.UDPAddr, packet v5Packet, c *whoareyouV5) ([gcmNonceSize]byte, error) {
+	addr := toAddr.String()
+	enc, nonce, err := t.codec.encode(toID, addr, packet, c)
+	if err != nil {
+		log.Warn(">> "+packet.name(), "id", toID, "addr", addr, "err", err)
+		return nonce, err
+	}
+	_, err = t.conn.WriteToUDP(enc, toAddr)
+	log.Trace(">> "+packet.name(), "id", toID, "addr", addr, "err", err)
+	return nonce, err
+}
+
+// readLoop runs in its own goroutine and reads packets from the network.
+func (t *UDPv5) readLoop() {
+	defer t.wg.Done()
+
+	buf := make([]byte, maxPacketSizeV5)
+	for range t.readNextCh {
+		nbytes, from, err := t.conn.ReadFromUDP(buf)
+		if netutil.IsTemporaryError(err) {
+			// Ignore temporary read errors.
+			log.Debug("Temporary UDP read error", "err", err)
+			t.readNextCh <- struct{}{}
+			continue
+		} else if err != nil {
+			// Shut down the loop for permament errors.
+			if err != errClosed {
+				log.Debug("UDP read error", "err", err)
+			}
+			return
+		}
+		select {
+		case t.packetInCh <- ReadPacket{buf[:nbytes], from}:
+		case <-t.closing:
+			return
+		}
+	}
+}
+
+// handlePacket decodes and processes an incoming packet from the network.
+func (t *UDPv5) handlePacket(rawpacket []byte, fromAddr *net.UDPAddr) error {
+	addr := fromAddr.String()
+	fromID, fromNode, packet, err := t.codec.decode(rawpacket, addr)
+	if err != nil {
+		log.Debug("Bad discv5 packet", "id", fromID, "addr", addr, "err", err)
+		return err
+	}
+	if fromNode != nil {
+		// Handshake succeeded, the record of the remote node is now known.
+		t.putNode(fromNode)
+		if fromNode.IP().Equal(fromAddr.IP) && fromNode.UDP() == fromAddr.Port {
+			t.tab.addThroughPing(wrapNode(fromNode))
+		}
+	}
+	if packet.kind() != whoareyouV5Msg {
+		// WHOAREYOU logged separately to report errors.
+		log.Trace("<< "+packet.name(), "id", fromID, "addr", addr)
+	}
+	packet.handle(t, fromID, fromAddr)
+	return nil
+}
+
+// handleCallResponse dispatches a response packet to the call waiting for it.
+func (t *UDPv5) handleCallResponse(fromID enode.ID, fromAddr *net.UDPAddr, reqid []byte, p v5Packet) {
+	ac := t.activeCallByNode[fromID]
+	if ac == nil || !bytes.Equal(reqid, ac.reqid) {
+		log.Debug(fmt.Sprintf("Unsolicited/late %s response", p.name()), "id", fromID, "addr", fromAddr)
+		return
+	}
+	if !fromAddr.IP.Equal(ac.node.IP()) || fromAddr.Port != ac.node.UDP() {
+		log.Debug(fmt.Sprintf("%s from wrong endpoint", p.name()), "id", fromID, "addr", fromAddr)
+		return
+	}
+	if !bytes.Contains(ac.responseTypes, []byte{p.kind()}) {
+		log.Debug(fmt.Sprintf("Wrong discv5 response type %s", p.name()), "id", fromID, "addr", fromAddr)
+		return
+	}
+	t.startResponseTimeout(ac)
+	ac.ch <- p
+}
+
+// getNode looks for a node record in the known node cache and the database.
+func (t *UDPv5) getNode(id enode.ID) *enode.Node {
+	t.nodesMu.Lock()
+	n, ok := t.nodes.Get(id)
+	t.nodesMu.Unlock()
+	if ok {
+		return n.(*enode.Node)
+	}
+	return t.db.Node(id)
+}
+
+// putNode stores a node record in the known node cache, unless a newer record
+// of the node is already known.
+func (t *UDPv5) putNode(n *enode.Node) {
+	t.nodesMu.Lock()
+	defer t.nodesMu.Unlock()
+
+	if old, ok := t.nodes.Get(n.ID()); ok && old.(*enode.Node).Seq() > n.Seq() {
+		return
+	}
+	t.nodes.Add(n.ID(), n)
+}
+
+// UNKNOWN
+
+func (p *unknownV5) handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	challenge := &whoareyouV5{Nonce: p.Nonce}
+	crand.Read(challenge.IDNonce[:])
+	if n := t.getNode(fromID); n != nil {
+		challenge.Node = n
+		challenge.RecordSeq = n.Seq()
+	}
+	t.sendResponse(fromID, fromAddr, challenge)
+}
+
+// WHOAREYOU
+
+func (p *whoareyouV5) handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	c, err := p.matchWithCall(t, p.Nonce)
+	if err != nil {
+		log.Debug("Invalid "+p.name(), "addr", fromAddr, "err", err)
+		return
+	}
+	// Resend the call that was answered by WHOAREYOU.
+	log.Trace("<< "+p.name(), "id", c.node.ID(), "addr", fromAddr)
+	c.handshakeCount++
+	c.challenge = p
+	p.Node = c.node
+	t.sendCall(c)
+}
+
+// matchWithCall checks whether the handshake attempt matches the active call.
+func (p *whoareyouV5) matchWithCall(t *UDPv5, nonce [gcmNonceSize]byte) (*callV5, error) {
+	c := t.activeCallByAuth[nonce]
+	if c == nil {
+		return nil, errChallengeNoCall
+	}
+	if c.handshakeCount > 0 {
+		return nil, errChallengeTwice
+	}
+	return c, nil
+}
+
+// PING
+
+func (p *pingV5) handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	t.sendResponse(fromID, fromAddr, &pongV5{
+		ReqID:  p.ReqID,
+		ToIP:   fromAddr.IP,
+		ToPort: uint16(fromAddr.Port),
+		ENRSeq: t.localNode.Node().Seq(),
+	})
+}
+
+// PONG
+
+func (p *pongV5) handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	t.localNode.UDPEndpointStatement(fromAddr, &net.UDPAddr{IP: p.ToIP, Port: int(p.ToPort)})
+	t.handleCallResponse(fromID, fromAddr, p.ReqID, p)
+}
+
+// FINDNODE
+
+func (p *findnodeV5) handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	nodes := t.collectTableNodes(fromAddr.IP, p.Distances, findnodeResultLimit)
+	for _, resp := range packNodes(p.ReqID, nodes) {
+		t.sendResponse(fromID, fromAddr, resp)
+	}
+}
+
+// collectTableNodes creates a FINDNODE result set for the given distances.
+func (t *UDPv5) collectTableNodes(rip net.IP, distances []uint, limit int) []*enode.Node {
+	var (
+		nodes     []*enode.Node
+		processed = make(map[uint]struct{})
+	)
+	for _, dist := range distances {
+		// Reject duplicate / invalid distances.
+		if _, seen := processed[dist]; seen || dist > 256 {
+			continue
+		}
+		processed[dist] = struct{}{}
+
+		var bn []*enode.Node
+		if dist == 0 {
+			bn = []*enode.Node{t.Self()}
+		} else {
+			bn = t.tab.nodesAtDistance(int(dist))
+		}
+		for _, n := range bn {
+			if netutil.CheckRelayIP(rip, n.IP()) != nil {
+				continue
+			}
+			nodes = append(nodes, n)
+			if len(nodes) >= limit {
+				return nodes
+			}
+		}
+	}
+	return nodes
+}
+
+// packNodes creates NODES response packets for the given node list.
+func packNodes(reqid []byte, nodes []*enode.Node) []*nodesV5 {
+	if len(nodes) == 0 {
+		return []*nodesV5{{ReqID: reqid, Total: 1}}
+	}
+	total := uint8((len(nodes) + nodesResponseItemLimit - 1) / nodesResponseItemLimit)
+	var resp []*nodesV5
+	for len(nodes) > 0 {
+		p := &nodesV5{ReqID: reqid, Total: total}
+		items := nodesResponseItemLimit
+		if items > len(nodes) {
+			items = len(nodes)
+		}
+		for i := 0; i < items; i++ {
+			p.Nodes = append(p.Nodes, nodes[i].Record())
+		}
+		nodes = nodes[items:]
+		resp = append(resp, p)
+	}
+	return resp
+}
+
+// NODES
+
+func (p *nodesV5) handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	t.handleCallResponse(fromID, fromAddr, p.ReqID, p)
+}
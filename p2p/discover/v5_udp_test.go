@@ -0,0 +1,271 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+// startLocalhostV5 starts a discovery v5 instance listening on localhost.
+func startLocalhostV5(t *testing.T, bootnodes ...*enode.Node) *UDPv5 {
+	key := newkey()
+	db, _ := enode.OpenDB("")
+	ln := enode.NewLocalNode(db, key)
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	realaddr := conn.LocalAddr().(*net.UDPAddr)
+	ln.SetStaticIP(realaddr.IP)
+	ln.SetFallbackUDP(realaddr.Port)
+
+	udp, err := ListenV5(conn, ln, Config{PrivateKey: key, Bootnodes: bootnodes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return udp
+}
+
+// newLocalhostNode creates a node record with a localhost endpoint.
+func newLocalhostNode(t *testing.T, port int) *enode.Node {
+	var r enr.Record
+	r.Set(enr.IP(net.IP{127, 0, 0, 1}))
+	r.Set(enr.UDP(port))
+	if err := enode.SignV4(&r, newkey()); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// This test checks that two instances can talk to each other after the
+// handshake, in both directions.
+func TestUDPv5_pingHandshake(t *testing.T) {
+	t.Parallel()
+	a, b := startLocalhostV5(t), startLocalhostV5(t)
+	defer a.Close()
+	defer b.Close()
+
+	if err := a.Ping(b.Self()); err != nil {
+		t.Fatalf("ping A -> B failed: %v", err)
+	}
+	// B knows A's record from the handshake and can reuse the session.
+	if n := b.getNode(a.Self().ID()); n == nil {
+		t.Fatal("B didn't learn A's record during the handshake")
+	}
+	if err := b.Ping(a.Self()); err != nil {
+		t.Fatalf("ping B -> A failed: %v", err)
+	}
+	n, err := a.RequestENR(b.Self())
+	if err != nil {
+		t.Fatalf("ENR request failed: %v", err)
+	}
+	if n.ID() != b.Self().ID() || n.Seq() != b.Self().Seq() {
+		t.Fatalf("wrong record returned: %v", n)
+	}
+}
+
+// This test checks that FINDNODE returns the table entries at the requested
+// distances.
+func TestUDPv5_findnode(t *testing.T) {
+	t.Parallel()
+	a, b := startLocalhostV5(t), startLocalhostV5(t)
+	defer a.Close()
+	defer b.Close()
+
+	// Fill B's table with some nodes.
+	dists := make(map[uint][]*enode.Node)
+	for i := 0; i < 12; i++ {
+		n := newLocalhostNode(t, 30303+i)
+		d := uint(enode.LogDist(b.Self().ID(), n.ID()))
+		b.tab.stuff([]*node{wrapNode(n)})
+		if contains(b.tab.bucket(n.ID()).entries, n.ID()) {
+			dists[d] = append(dists[d], n)
+		}
+	}
+	for d, want := range dists {
+		nodes, err := a.findnodeV5(b.Self(), []uint{d})
+		if err != nil {
+			t.Fatalf("findnode for distance %d failed: %v", d, err)
+		}
+		// B added A to its table after the handshake.
+		for i, n := range nodes {
+			if n.ID() == a.Self().ID() {
+				nodes = append(nodes[:i], nodes[i+1:]...)
+				break
+			}
+		}
+		if !sameNodes(nodes, want) {
+			t.Errorf("wrong nodes at distance %d: got %v, want %v", d, nodes, want)
+		}
+	}
+	// Nodes at distances which don't exist in the table aren't returned.
+	nodes, err := a.findnodeV5(b.Self(), []uint{1, 2})
+	if err != nil {
+		t.Fatalf("findnode for empty distances failed: %v", err)
+	}
+	if len(nodes) != 0 {
+		t.Errorf("got %d nodes for empty distances", len(nodes))
+	}
+}
+
+// This test checks that the table lookup works over the v5 transport.
+func TestUDPv5_lookup(t *testing.T) {
+	t.Parallel()
+	boot := startLocalhostV5(t)
+	defer boot.Close()
+
+	var nodes []*UDPv5
+	for i := 0; i < 3; i++ {
+		n := startLocalhostV5(t, boot.Self())
+		defer n.Close()
+		nodes = append(nodes, n)
+	}
+	// Make everyone known to the bootnode.
+	for _, n := range nodes {
+		if err := n.Ping(boot.Self()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-boot.tab.initDone
+	result := nodes[0].LookupRandom()
+	for _, n := range nodes[1:] {
+		found := false
+		for _, rn := range result {
+			found = found || rn.ID() == n.Self().ID()
+		}
+		if !found {
+			t.Errorf("node %v not found in lookup", n.Self().ID())
+		}
+	}
+}
+
+// This test checks topic registration and search through the public API.
+func TestUDPv5_topic(t *testing.T) {
+	t.Parallel()
+	registrar := startLocalhostV5(t)
+	defer registrar.Close()
+	advertiser := startLocalhostV5(t, registrar.Self())
+	defer advertiser.Close()
+	searcher := startLocalhostV5(t, registrar.Self())
+	defer searcher.Close()
+
+	topic := Topic("les2@test")
+	stop := make(chan struct{})
+	defer close(stop)
+	go advertiser.RegisterTopic(topic, stop)
+
+	var (
+		setPeriod = make(chan time.Duration, 1)
+		found     = make(chan *enode.Node, 10)
+		lookup    = make(chan bool, 10)
+	)
+	defer close(setPeriod)
+	setPeriod <- 100 * time.Millisecond
+	go searcher.SearchTopic(topic, setPeriod, found, lookup)
+
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case n := <-found:
+			if n.ID() != advertiser.Self().ID() {
+				t.Fatalf("found unexpected node %v", n.ID())
+			}
+			return
+		case <-lookup:
+		case <-timeout:
+			t.Fatal("advertiser not found")
+		}
+	}
+}
+
+// This test checks that the registrar issues tickets when the topic queue is
+// full, and accepts them after the wait time.
+func TestUDPv5_topicTicket(t *testing.T) {
+	t.Parallel()
+	registrar := startLocalhostV5(t)
+	defer registrar.Close()
+	advertiser := startLocalhostV5(t)
+	defer advertiser.Close()
+
+	// Fill the topic queue with other nodes.
+	topic := Topic("les2@test")
+	now := registrar.clock.Now()
+	for i := 0; i < topicQueueLimit; i++ {
+		registrar.topics.register(topic, newLocalhostNode(t, 30303+i), now, false)
+	}
+	// Let the oldest ad expire in one second.
+	registrar.topics.queues[topic][0].expires = now + mclock.AbsTime(time.Second)
+
+	ok, tk, wait, err := advertiser.regtopic(registrar.Self(), topic, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok || len(tk) == 0 {
+		t.Fatal("registration confirmed despite full queue")
+	}
+	if wait != time.Second {
+		t.Fatalf("wrong wait time %v", wait)
+	}
+	time.Sleep(wait)
+	ok, _, _, err = advertiser.regtopic(registrar.Self(), topic, tk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("registration with ticket not confirmed")
+	}
+	nodes, err := advertiser.topicQuery(registrar.Self(), topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != topicQueryLimit || nodes[0].ID() != advertiser.Self().ID() {
+		t.Fatalf("advertiser not returned by topic query")
+	}
+}
+
+func sameNodes(a, b []*enode.Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	ids := func(ns []*enode.Node) []string {
+		var s []string
+		for _, n := range ns {
+			s = append(s, n.ID().String())
+		}
+		sort.Strings(s)
+		return s
+	}
+	ia, ib := ids(a), ids(b)
+	for i := range ia {
+		if ia[i] != ib[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,8 +0,0 @@
-package discv5
-
-import "github.com/ethereum/go-ethereum/metrics"
-
-var (
-	ingressTrafficMeter = metrics.NewRegisteredMeter("discv5/InboundTraffic", nil)
-	egressTrafficMeter  = metrics.NewRegisteredMeter("discv5/OutboundTraffic", nil)
-)
@@ -0,0 +1,307 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+const (
+	signingKeySeed = 0x111111
+	nodesSeed1     = 0x2945237
+	nodesSeed2     = 0x4567299
+)
+
+func TestClientSyncTree(t *testing.T) {
+	nodes := testNodes(nodesSeed1, 30)
+	tree, url := makeTestTree("n", nodes, nil)
+	r := mapResolver(tree.ToTXT("n"))
+	c := newTestClient(r, nil)
+
+	stree, err := c.SyncTree(url)
+	if err != nil {
+		t.Fatal("sync error:", err)
+	}
+	if !reflect.DeepEqual(sortByID(stree.Nodes()), sortByID(nodes)) {
+		t.Errorf("wrong nodes in synced tree")
+	}
+	if !reflect.DeepEqual(stree.Links(), tree.Links()) {
+		t.Errorf("wrong links in synced tree")
+	}
+	if stree.Seq() != tree.Seq() || stree.Signature() != tree.Signature() {
+		t.Errorf("wrong root in synced tree: seq %d, want %d", stree.Seq(), tree.Seq())
+	}
+}
+
+// In this test, syncing the tree fails because it contains an invalid ENR entry.
+func TestClientSyncTreeBadNode(t *testing.T) {
+	nodes := testNodes(nodesSeed1, 1)
+	tree, url := makeTestTree("n", nodes, nil)
+	r := mapResolver(tree.ToTXT("n"))
+
+	// Replace the node record by a different one, breaking the entry hash.
+	for name, txt := range r {
+		if len(txt) > 4 && txt[:4] == "enr:" {
+			other, _ := testNodes(nodesSeed2, 1)[0].TextRecord()
+			r[name] = other
+		}
+	}
+	c := newTestClient(r, nil)
+	_, err := c.SyncTree(url)
+	if _, ok := err.(nameError); !ok || err.(nameError).err != errHashMismatch {
+		t.Fatalf("expected hash mismatch error, got %v", err)
+	}
+}
+
+// In this test, syncing the tree fails because the root is signed by another key.
+func TestClientSyncTreeBadRoot(t *testing.T) {
+	tree, _ := makeTestTree("n", testNodes(nodesSeed1, 3), nil)
+	r := mapResolver(tree.ToTXT("n"))
+	url := newLinkEntry("n", &testKey(signingKeySeed+1).PublicKey).String()
+
+	c := newTestClient(r, nil)
+	if _, err := c.SyncTree(url); err != (entryError{"root", errInvalidSig}) {
+		t.Fatalf("expected signature error, got %v", err)
+	}
+}
+
+// This test checks that randomIterator finds all entries.
+func TestIterator(t *testing.T) {
+	nodes := testNodes(nodesSeed1, 30)
+	tree, url := makeTestTree("n", nodes, nil)
+	r := mapResolver(tree.ToTXT("n"))
+	c := newTestClient(r, nil)
+
+	it, err := c.NewIterator(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	checkIterator(t, it, nodes)
+}
+
+// This test checks that the iterator follows links to other trees.
+func TestIteratorLinks(t *testing.T) {
+	nodes := testNodes(nodesSeed1, 40)
+	tree1, url1 := makeTestTree("t1", nodes[:10], nil)
+	tree2, url2 := makeTestTree("t2", nodes[10:], []string{url1})
+	r := mapResolver(tree1.ToTXT("t1"))
+	r.add(tree2.ToTXT("t2"))
+	c := newTestClient(r, nil)
+
+	it, err := c.NewIterator(url2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	checkIterator(t, it, nodes)
+}
+
+// This test checks that the iterator works correctly when the tree is initially empty
+// and picks up nodes once the root is updated.
+func TestIteratorEmptyTree(t *testing.T) {
+	var (
+		clock    = new(mclock.Simulated)
+		nodes    = testNodes(nodesSeed1, 1)
+		resolver = make(mapResolver)
+	)
+	tree1, url := makeTestTree("n", nil, nil)
+	tree2, _ := makeTestTree("n", nodes, nil)
+	tree2.root.seq = tree1.root.seq + 1
+	if _, err := tree2.Sign(testKey(signingKeySeed), "n"); err != nil {
+		t.Fatal(err)
+	}
+	resolver.add(tree1.ToTXT("n"))
+
+	c := newTestClient(resolver, clock)
+	it, err := c.NewIterator(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	// Start the iterator, it has to wait for the next root update.
+	node := make(chan *enode.Node)
+	go func() {
+		if it.Next() {
+			node <- it.Node()
+		}
+		close(node)
+	}()
+
+	// Wait for the iterator to wait on the clock, then publish the new tree
+	// and advance the clock past the recheck interval.
+	clock.WaitForTimers(1)
+	resolver.clear()
+	resolver.add(tree2.ToTXT("n"))
+	clock.Run(c.cfg.RecheckInterval + 1*time.Second)
+
+	select {
+	case n := <-node:
+		if n == nil || n.ID() != nodes[0].ID() {
+			t.Fatalf("iterator returned wrong node %v", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for node")
+	}
+}
+
+// This test checks that Close unblocks an iterator waiting for a root update.
+func TestIteratorClose(t *testing.T) {
+	tree, url := makeTestTree("n", nil, nil)
+	c := newTestClient(mapResolver(tree.ToTXT("n")), new(mclock.Simulated))
+	it, err := c.NewIterator(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan bool)
+	go func() { done <- it.Next() }()
+	c.clock.(*mclock.Simulated).WaitForTimers(1)
+	it.Close()
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatal("Next returned true after Close")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Next didn't return after Close")
+	}
+}
+
+func checkIterator(t *testing.T, it enode.Iterator, wantNodes []*enode.Node) {
+	t.Helper()
+
+	var (
+		want     = make(map[enode.ID]*enode.Node)
+		maxCalls = len(wantNodes) * 3
+		calls    = 0
+	)
+	for _, n := range wantNodes {
+		want[n.ID()] = n
+	}
+	for ; len(want) > 0 && calls < maxCalls; calls++ {
+		if !it.Next() {
+			t.Fatalf("Next returned false (call %d)", calls)
+		}
+		delete(want, it.Node().ID())
+	}
+	if len(want) > 0 {
+		t.Fatalf("some nodes not reached after %d calls: %v", calls, want)
+	}
+}
+
+func newTestClient(r Resolver, clock mclock.Clock) *Client {
+	c := NewClient(Config{
+		Resolver:  r,
+		RateLimit: 10000,
+	})
+	if clock != nil {
+		c.clock = clock
+	}
+	return c
+}
+
+func makeTestTree(domain string, nodes []*enode.Node, links []string) (*Tree, string) {
+	tree, err := MakeTree(1, nodes, links)
+	if err != nil {
+		panic(err)
+	}
+	url, err := tree.Sign(testKey(signingKeySeed), domain)
+	if err != nil {
+		panic(err)
+	}
+	return tree, url
+}
+
+// testKeys creates deterministic private keys for testing.
+func testKeys(seed int64, n int) []*ecdsa.PrivateKey {
+	keys := make([]*ecdsa.PrivateKey, n)
+	for i := 0; i < n; i++ {
+		var input [16]byte
+		binary.BigEndian.PutUint64(input[:8], uint64(seed))
+		binary.BigEndian.PutUint64(input[8:], uint64(i))
+		key, err := crypto.ToECDSA(crypto.Keccak256(input[:]))
+		if err != nil {
+			panic("can't generate key: " + err.Error())
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+func testKey(seed int64) *ecdsa.PrivateKey {
+	return testKeys(seed, 1)[0]
+}
+
+func testNodes(seed int64, n int) []*enode.Node {
+	keys := testKeys(seed, n)
+	nodes := make([]*enode.Node, n)
+	for i, key := range keys {
+		record := new(enr.Record)
+		record.SetSeq(uint64(i))
+		enode.SignV4(record, key)
+		n, err := enode.New(enode.ValidSchemes, record)
+		if err != nil {
+			panic(err)
+		}
+		nodes[i] = n
+	}
+	return nodes
+}
+
+// mapResolver is a Resolver serving TXT records from memory.
+type mapResolver map[string]string
+
+func (mr mapResolver) clear() {
+	for k := range mr {
+		delete(mr, k)
+	}
+}
+
+func (mr mapResolver) add(m map[string]string) {
+	for k, v := range m {
+		mr[k] = v
+	}
+}
+
+func (mr mapResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if record, ok := mr[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, nil
+}
+
+func spewString(v interface{}) string {
+	return spewcfg.Sdump(v)
+}
+
+var spewcfg = spew.ConfigState{Indent: "  ", DisableMethods: true}
@@ -1,4 +1,4 @@
-// Copyright 2016 The go-ethereum Authors
+// Copyright 2019 The go-ethereum Authors
 // This file is part of the go-ethereum library.
 //
 // The go-ethereum library is free software: you can redistribute it and/or modify
@@ -14,30 +14,13 @@
 // You should have received a copy of the GNU Lesser General Public License
 // along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
 
-// +build go1.4,nacl,faketime_simulation
-
-package discv5
-
-import (
-	"os"
-	"runtime"
-	"testing"
-	"unsafe"
-)
-
-// Enable fake time mode in the runtime, like on the go playground.
-// There is a slight chance that this won't work because some go code
-// might have executed before the variable is set.
-
-//go:linkname faketime runtime.faketime
-var faketime = 1
-
-func TestMain(m *testing.M) {
-	// We need to use unsafe somehow in order to get access to go:linkname.
-	_ = unsafe.Sizeof(0)
-
-	// Run the actual test. runWithPlaygroundTime ensures that the only test
-	// that runs is the one calling it.
-	runtime.GOMAXPROCS(8)
-	os.Exit(m.Run())
-}
+// Package dnsdisc implements node discovery via DNS (EIP-1459).
+//
+// Node lists are published as merkle trees of signed node records, with each tree
+// entry stored in a DNS TXT record. The root of the tree is signed by the list
+// operator, whose public key is part of the enrtree:// URL of the list. Trees may
+// link to other trees, so lists can be composed from lists of other operators.
+//
+// The Client resolves trees lazily, syncing random leaves on demand, and can be
+// used as a dial candidate source of p2p.Server through NewIterator.
+package dnsdisc
@@ -0,0 +1,207 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func TestParseRoot(t *testing.T) {
+	tree, _ := makeTestTree("n", testNodes(nodesSeed1, 3), nil)
+	tests := []struct {
+		input string
+		e     rootEntry
+		err   error
+	}{
+		{
+			input: "enrtree-root:v1 e=TO4Q75OQ2N7DX4EOOR7X66A6OM seq=3 sig=N-YY6UB9xD0hFx1Gmnt7v0RfSxch5tKyry2SRDoLx7B4GfPXagwLxQqyf7gAMvApFn_ORwZQekMWa_pXrcGCtw",
+			err:   entryError{"root", errSyntax},
+		},
+		{
+			input: "enrtree-root:v1 e=TO4Q75OQ2N7DX4EOOR7X66A6OM l=TO4Q75OQ2N7DX4EOOR7X66A6OM seq=3 sig=N-YY6UB9xD0hFx1Gmnt7v0RfSxch5tKyry2SRDoLx7B4GfPXagwLxQqyf7gAMvApFn_ORwZQekMWa_pXrcGCtw",
+			err:   entryError{"root", errInvalidSig},
+		},
+		{
+			input: tree.root.String(),
+			e:     *tree.root,
+		},
+	}
+	for i, test := range tests {
+		e, err := parseRoot(test.input)
+		if !reflect.DeepEqual(e, test.e) {
+			t.Errorf("test %d: wrong entry %s, want %s", i, spewString(e), spewString(test.e))
+		}
+		if err != test.err {
+			t.Errorf("test %d: wrong error %q, want %q", i, err, test.err)
+		}
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	testkey := testKey(signingKeySeed)
+	tests := []struct {
+		input string
+		e     entry
+		err   error
+	}{
+		// Subtrees:
+		{
+			input: "enrtree-branch:1,2",
+			err:   entryError{"branch", errInvalidChild},
+		},
+		{
+			input: "enrtree-branch:AAAAAAAAAAAAAAAA",
+			err:   entryError{"branch", errInvalidChild},
+		},
+		{
+			input: "enrtree-branch:",
+			e:     &branchEntry{},
+		},
+		{
+			input: "enrtree-branch:AAAAAAAAAAAAAAAAAAAAAAAAAA",
+			e:     &branchEntry{[]string{"AAAAAAAAAAAAAAAAAAAAAAAAAA"}},
+		},
+		{
+			input: "enrtree-branch:AAAAAAAAAAAAAAAAAAAAAAAAAA,BBBBBBBBBBBBBBBBBBBBBBBBBB",
+			e:     &branchEntry{[]string{"AAAAAAAAAAAAAAAAAAAAAAAAAA", "BBBBBBBBBBBBBBBBBBBBBBBBBB"}},
+		},
+		// Links
+		{
+			input: "enrtree://" + b32format.EncodeToString(crypto.CompressPubkey(&testkey.PublicKey)) + "@nodes.example.org",
+			e:     newLinkEntry("nodes.example.org", &testkey.PublicKey),
+		},
+		{
+			input: "enrtree://nodes.example.org",
+			err:   entryError{"link", errNoPubkey},
+		},
+		{
+			input: "enrtree://AP62DT7WOTEQZGQZOU474PP3KMEGVTTE7A7NPRXKX3DUD57@nodes.example.org",
+			err:   entryError{"link", errBadPubkey},
+		},
+		// ENRs
+		{
+			input: "enr:-HW4QES8QIeXTYlDzbfr1WEzF-XKY4f8gJFJzjJL-9D7TC9lJb4Z3JPRRz1lP4pL_N_QpT6rGQjAU9Apnc-C1iMP36OAgmlkgnY0iXNlY3AyNTZrMaED5IdwfMxdmR8W37HqSFdQLjDkIwBd4Q_MjxgZifgKSdM",
+			err:   entryError{"enr", errInvalidENR},
+		},
+		// Invalid:
+		{input: "", err: errUnknownEntry},
+		{input: "foo", err: errUnknownEntry},
+		{input: "enrtree", err: errUnknownEntry},
+		{input: "enrtree-x=", err: errUnknownEntry},
+	}
+	for i, test := range tests {
+		e, err := parseEntry(test.input, enode.ValidSchemes)
+		if !reflect.DeepEqual(e, test.e) {
+			t.Errorf("test %d: wrong entry %s, want %s", i, spewString(e), spewString(test.e))
+		}
+		if err != test.err {
+			t.Errorf("test %d: wrong error %q, want %q", i, err, test.err)
+		}
+	}
+}
+
+func TestMakeTree(t *testing.T) {
+	nodes := testNodes(nodesSeed1, 50)
+	tree, err := MakeTree(2, nodes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt := tree.ToTXT("")
+	if len(txt) < len(nodes)+1 {
+		t.Fatal("too few TXT records in output")
+	}
+	for name, rec := range txt {
+		if name == "" {
+			continue
+		}
+		e, err := parseEntry(rec, enode.ValidSchemes)
+		if err != nil {
+			t.Fatalf("can't parse record %q: %v", name, err)
+		}
+		if sd := subdomain(e); sd != name {
+			t.Fatalf("entry %q stored at %q", sd, name)
+		}
+		if br, ok := e.(*branchEntry); ok && len(br.children) > maxChildren {
+			t.Fatalf("branch %q has too many children: %d", name, len(br.children))
+		}
+	}
+	if have := sortByID(tree.Nodes()); !reflect.DeepEqual(have, sortByID(nodes)) {
+		t.Fatal("tree nodes don't match input")
+	}
+}
+
+func TestMakeTreeUnsigned(t *testing.T) {
+	n := enode.NewV4(&testKey(nodesSeed1).PublicKey, nil, 30303, 30303)
+	if _, err := MakeTree(1, []*enode.Node{n}, nil); err == nil {
+		t.Fatal("MakeTree accepted node without a signed record")
+	}
+}
+
+func TestTreeSignature(t *testing.T) {
+	tree, err := MakeTree(1, testNodes(nodesSeed1, 3), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := testKey(signingKeySeed)
+	url, err := tree.Sign(key, "n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	domain, pubkey, err := ParseURL(url)
+	if err != nil {
+		t.Fatal("can't parse signed tree URL:", err)
+	}
+	if domain != "n" || !reflect.DeepEqual(pubkey, &key.PublicKey) {
+		t.Fatalf("wrong URL components: %s %x", domain, crypto.FromECDSAPub(pubkey))
+	}
+	if err := tree.SetSignature(&key.PublicKey, tree.Signature()); err != nil {
+		t.Fatal("can't set valid signature:", err)
+	}
+	other := testKey(signingKeySeed + 1)
+	if err := tree.SetSignature(&other.PublicKey, tree.Signature()); err != errInvalidSig {
+		t.Fatalf("wrong error for signature of other key: %v", err)
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	testkey := testKey(signingKeySeed)
+	encodedKey := b32format.EncodeToString(crypto.CompressPubkey(&testkey.PublicKey))
+	tests := []struct {
+		input  string
+		domain string
+		err    bool
+	}{
+		{input: "enrtree://" + encodedKey + "@nodes.example.org", domain: "nodes.example.org"},
+		{input: "https://" + encodedKey + "@nodes.example.org", err: true},
+		{input: "enrtree://nodes.example.org", err: true},
+		{input: "enrtree://AAAA@nodes.example.org", err: true},
+	}
+	for i, test := range tests {
+		domain, pubkey, err := ParseURL(test.input)
+		if (err != nil) != test.err {
+			t.Errorf("test %d: wrong error %v", i, err)
+			continue
+		}
+		if err == nil && (domain != test.domain || !reflect.DeepEqual(pubkey, &testkey.PublicKey)) {
+			t.Errorf("test %d: wrong result %q %x", i, domain, crypto.FromECDSAPub(pubkey))
+		}
+	}
+}
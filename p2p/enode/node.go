@@ -18,6 +18,7 @@ package enode
 
 import (
 	"crypto/ecdsa"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -27,6 +28,7 @@ import (
 	"strings"
 
 	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // Node represents a host on the network.
@@ -48,6 +50,37 @@ func New(validSchemes enr.IdentityScheme, r *enr.Record) (*Node, error) {
 	return node, nil
 }
 
+// Parse decodes and verifies a node given in text form, either as a record with
+// the "enr:" prefix or as an enode:// URL.
+func Parse(validSchemes enr.IdentityScheme, input string) (*Node, error) {
+	if !strings.HasPrefix(input, "enr:") {
+		return ParseV4(input)
+	}
+	bin, err := base64.RawURLEncoding.DecodeString(input[4:])
+	if err != nil {
+		return nil, err
+	}
+	var r enr.Record
+	if err := rlp.DecodeBytes(bin, &r); err != nil {
+		return nil, err
+	}
+	return New(validSchemes, &r)
+}
+
+// TextRecord returns the record of the node in text form, as the URL-safe base64
+// encoding of its RLP with the "enr:" prefix. Nodes without a validly signed record
+// (such as the ones parsed from enode:// URLs) can't be encoded.
+func (n *Node) TextRecord() (string, error) {
+	if err := n.r.VerifySignature(ValidSchemes); err != nil {
+		return "", fmt.Errorf("unsigned node record: %v", err)
+	}
+	bin, err := rlp.EncodeToBytes(&n.r)
+	if err != nil {
+		return "", err
+	}
+	return "enr:" + base64.RawURLEncoding.EncodeToString(bin), nil
+}
+
 // ID returns the node identifier.
 func (n *Node) ID() ID {
 	return n.id
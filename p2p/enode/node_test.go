@@ -17,6 +17,7 @@
 package enode
 
 import (
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"testing"
@@ -60,3 +61,45 @@ func TestPythonInterop(t *testing.T) {
 		}
 	}
 }
+
+// Tests that records round-trip through their text form, and that enode:// URLs
+// are accepted by Parse too.
+func TestNodeTextRecord(t *testing.T) {
+	var r enr.Record
+	if err := rlp.DecodeBytes(pyRecord, &r); err != nil {
+		t.Fatalf("can't decode: %v", err)
+	}
+	n, err := New(ValidSchemes, &r)
+	if err != nil {
+		t.Fatalf("can't verify record: %v", err)
+	}
+	text, err := n.TextRecord()
+	if err != nil {
+		t.Fatalf("can't encode record: %v", err)
+	}
+	if want := "enr:" + base64.RawURLEncoding.EncodeToString(pyRecord); text != want {
+		t.Fatalf("wrong text record:\nhave %s\nwant %s", text, want)
+	}
+	parsed, err := Parse(ValidSchemes, text)
+	if err != nil {
+		t.Fatalf("can't parse text record: %v", err)
+	}
+	if parsed.ID() != n.ID() || parsed.Seq() != n.Seq() {
+		t.Errorf("parsed node mismatch: have %v, want %v", parsed.ID(), n.ID())
+	}
+	// Tampered records must be rejected
+	if _, err := Parse(ValidSchemes, text[:len(text)-2]+"AA"); err == nil {
+		t.Errorf("tampered record accepted")
+	}
+	// Nodes from URLs have no signed record to encode
+	url, err := Parse(ValidSchemes, parsed.String())
+	if err != nil {
+		t.Fatalf("can't parse node URL: %v", err)
+	}
+	if url.ID() != n.ID() {
+		t.Errorf("parsed URL mismatch: have %v, want %v", url.ID(), n.ID())
+	}
+	if _, err := url.TextRecord(); err == nil {
+		t.Errorf("unsigned record encoded")
+	}
+}
@@ -47,6 +47,8 @@ const (
 	dbDiscoverFindFails = dbDiscoverRoot + ":findfail"
 	dbLocalRoot         = ":local"
 	dbLocalSeq          = dbLocalRoot + ":seq"
+	dbPeerRoot          = ":peer"
+	dbPeerBanExpiry     = dbPeerRoot + ":banexpiry"
 )
 
 var (
@@ -326,6 +328,16 @@ func (db *DB) UpdateFindFails(id ID, fails int) error {
 	return db.storeInt64(makeKey(id, dbDiscoverFindFails), int64(fails))
 }
 
+// BanExpiry retrieves the time until which a node is banned from connecting.
+func (db *DB) BanExpiry(id ID) time.Time {
+	return time.Unix(db.fetchInt64(makeKey(id, dbPeerBanExpiry)), 0)
+}
+
+// UpdateBanExpiry updates the time until which a node is banned from connecting.
+func (db *DB) UpdateBanExpiry(id ID, instance time.Time) error {
+	return db.storeInt64(makeKey(id, dbPeerBanExpiry), instance.Unix())
+}
+
 // LocalSeq retrieves the local record sequence counter.
 func (db *DB) localSeq(id ID) uint64 {
 	return db.fetchUint64(makeKey(id, dbLocalSeq))
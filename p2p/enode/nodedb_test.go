@@ -142,6 +142,16 @@ func TestDBFetchStore(t *testing.T) {
 	if stored := db.FindFails(node.ID()); stored != num {
 		t.Errorf("find-node fails: value mismatch: have %v, want %v", stored, num)
 	}
+	// Check fetch/store operations on a node ban object
+	if stored := db.BanExpiry(node.ID()); stored.Unix() != 0 {
+		t.Errorf("ban: non-existing object: %v", stored)
+	}
+	if err := db.UpdateBanExpiry(node.ID(), inst); err != nil {
+		t.Errorf("ban: failed to update: %v", err)
+	}
+	if stored := db.BanExpiry(node.ID()); stored.Unix() != inst.Unix() {
+		t.Errorf("ban: value mismatch: have %v, want %v", stored, inst)
+	}
 	// Check fetch/store operations on an actual node object
 	if stored := db.Node(node.ID()); stored != nil {
 		t.Errorf("node: non-existing object: %v", stored)
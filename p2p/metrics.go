@@ -37,6 +37,7 @@ const (
 	MetricsInboundTraffic   = "p2p/InboundTraffic"   // Name for the registered inbound traffic meter
 	MetricsOutboundConnects = "p2p/OutboundConnects" // Name for the registered outbound connects meter
 	MetricsOutboundTraffic  = "p2p/OutboundTraffic"  // Name for the registered outbound traffic meter
+	MetricsDials            = "p2p/Dials"            // Name for the registered dial attempt meter, prefix of the dial source meters
 
 	MeteredPeerLimit = 1024 // This amount of peers are individually metered
 )
@@ -54,6 +55,14 @@ var (
 	meteredPeerCount int32      // Actually stored peer connection count
 )
 
+var (
+	dialMeter         = metrics.NewRegisteredMeter(MetricsDials, nil)             // Meter counting the dial attempts
+	dialSuccessMeter  = metrics.NewRegisteredMeter(MetricsDials+"/success", nil)  // Meter counting the successful dials
+	dialFailureMeter  = metrics.NewRegisteredMeter(MetricsDials+"/failure", nil)  // Meter counting the failed dynamic dials
+	dialNetLimitMeter = metrics.NewRegisteredMeter(MetricsDials+"/netlimit", nil) // Meter counting the candidates rejected by the subnet limit
+	activeDialsGauge  = metrics.NewRegisteredGauge(MetricsDials+"/active", nil)   // Gauge tracking the number of active dials
+)
+
 // MeteredPeerEventType is the type of peer events emitted by a metered connection.
 type MeteredPeerEventType int
 
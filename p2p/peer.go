@@ -113,6 +113,13 @@ type Peer struct {
 
 	// events receives message send / receive events if set
 	events *event.Feed
+
+	// scores tracks the reputation of the peer if set
+	scores *scoreTable
+
+	// evicted is set by the server loop when the peer is being
+	// disconnected to make room for a better one
+	evicted bool
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -190,6 +197,23 @@ func newPeer(conn *conn, protocols []Protocol) *Peer {
 	return p
 }
 
+// Report records an observation about the behaviour of the peer, adjusting its
+// reputation. Peers whose score drops below the ban threshold are disconnected
+// and refused connections for a while, unless they are trusted.
+func (p *Peer) Report(ev ScoreEvent) {
+	if p.scores == nil {
+		return
+	}
+	score := p.scores.add(p.ID(), ev)
+	p.log.Trace("Peer score updated", "event", ev, "score", score)
+
+	if score <= scoreBanThreshold && !p.rw.is(trustedConn) {
+		p.log.Debug("Banning misbehaving peer", "score", score, "duration", scoreBanDuration)
+		p.scores.ban(p.ID())
+		p.Disconnect(DiscUselessPeer)
+	}
+}
+
 func (p *Peer) Log() log.Logger {
 	return p.log
 }
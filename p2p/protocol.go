@@ -56,6 +56,11 @@ type Protocol struct {
 
 	// Attributes contains protocol specific information for the node record.
 	Attributes []enr.Entry
+
+	// DialCandidates, if non-nil, is a source of protocol specific nodes that
+	// should be dialed. The server reads nodes from the iterator while running
+	// and uses them as candidates for dynamic dials.
+	DialCandidates enode.Iterator
 }
 
 func (p Protocol) cap() Cap {
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"crypto/ecdsa"
 	"crypto/rand"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -376,6 +377,137 @@ func TestRLPXFrameRW(t *testing.T) {
 	}
 }
 
+// newTestFrameRWPair creates two frame codecs sharing conn, where the
+// second one reads the messages written by the first.
+func newTestFrameRWPair(conn io.ReadWriter) (*rlpxFrameRW, *rlpxFrameRW) {
+	var (
+		aesSecret      = make([]byte, 16)
+		macSecret      = make([]byte, 16)
+		egressMACinit  = make([]byte, 32)
+		ingressMACinit = make([]byte, 32)
+	)
+	for _, s := range [][]byte{aesSecret, macSecret, egressMACinit, ingressMACinit} {
+		rand.Read(s)
+	}
+	s1 := secrets{AES: aesSecret, MAC: macSecret, EgressMAC: sha3.NewLegacyKeccak256(), IngressMAC: sha3.NewLegacyKeccak256()}
+	s1.EgressMAC.Write(egressMACinit)
+	s1.IngressMAC.Write(ingressMACinit)
+	s2 := secrets{AES: aesSecret, MAC: macSecret, EgressMAC: sha3.NewLegacyKeccak256(), IngressMAC: sha3.NewLegacyKeccak256()}
+	s2.EgressMAC.Write(ingressMACinit)
+	s2.IngressMAC.Write(egressMACinit)
+	return newRLPXFrameRW(conn, s1), newRLPXFrameRW(conn, s2)
+}
+
+// This test checks that Snappy compressed messages are decoded correctly
+// and that compression actually shrinks the frames on the wire.
+func TestRLPXFrameRWSnappy(t *testing.T) {
+	conn := new(bytes.Buffer)
+	rw1, rw2 := newTestFrameRWPair(conn)
+	rw1.snappy, rw2.snappy = true, true
+
+	wmsg := []interface{}{"foo", strings.Repeat("test", 1000)}
+	wantPayload, _ := rlp.EncodeToBytes(wmsg)
+	if err := Send(rw1, 10, wmsg); err != nil {
+		t.Fatalf("WriteMsg error: %v", err)
+	}
+	if conn.Len() >= len(wantPayload) {
+		t.Errorf("frame not compressed: %d bytes written for %d byte payload", conn.Len(), len(wantPayload))
+	}
+	msg, err := rw2.ReadMsg()
+	if err != nil {
+		t.Fatalf("ReadMsg error: %v", err)
+	}
+	if msg.Code != 10 || msg.Size != uint32(len(wantPayload)) {
+		t.Fatalf("wrong message: code %d, size %d", msg.Code, msg.Size)
+	}
+	payload, _ := ioutil.ReadAll(msg.Payload)
+	if !bytes.Equal(payload, wantPayload) {
+		t.Fatalf("msg payload mismatch:\ngot  %x\nwant %x", payload, wantPayload)
+	}
+}
+
+// This test checks that compressed messages exceeding the maximum message
+// size are rejected before they are decompressed.
+func TestRLPXFrameRWSnappyTooLarge(t *testing.T) {
+	conn := new(bytes.Buffer)
+	rw1, rw2 := newTestFrameRWPair(conn)
+
+	// The sender refuses to compress oversized messages.
+	rw1.snappy = true
+	err := rw1.WriteMsg(Msg{Code: 1, Size: maxUint24 + 1, Payload: bytes.NewReader(nil)})
+	if err != errPlainMessageTooLarge {
+		t.Fatalf("wrong error for oversized write: %v", err)
+	}
+	// A small frame announcing a huge decompressed size is rejected by the receiver.
+	rw1.snappy, rw2.snappy = false, true
+	bomb := make([]byte, binary.MaxVarintLen32)
+	bomb = bomb[:binary.PutUvarint(bomb, uint64(maxUint24)+1)]
+	if err := rw1.WriteMsg(Msg{Code: 1, Size: uint32(len(bomb)), Payload: bytes.NewReader(bomb)}); err != nil {
+		t.Fatalf("WriteMsg error: %v", err)
+	}
+	if _, err := rw2.ReadMsg(); err != errPlainMessageTooLarge {
+		t.Fatalf("wrong error for decompression bomb: %v", err)
+	}
+}
+
+// This test checks that Snappy is enabled only if both sides of the
+// protocol handshake support it, and that messages can be exchanged
+// between compressing and legacy peers.
+func TestProtocolHandshakeSnappy(t *testing.T) {
+	tests := []struct {
+		v0, v1     uint64
+		wantSnappy bool
+	}{
+		{v0: baseProtocolVersion, v1: baseProtocolVersion, wantSnappy: true},
+		{v0: baseProtocolVersion, v1: snappyProtocolVersion - 1, wantSnappy: false},
+		{v0: snappyProtocolVersion - 1, v1: baseProtocolVersion, wantSnappy: false},
+		{v0: snappyProtocolVersion - 1, v1: snappyProtocolVersion - 1, wantSnappy: false},
+	}
+	for i, test := range tests {
+		var (
+			prv0, _ = crypto.GenerateKey()
+			prv1, _ = crypto.GenerateKey()
+			hs0     = &protoHandshake{Version: test.v0, ID: crypto.FromECDSAPub(&prv0.PublicKey)[1:]}
+			hs1     = &protoHandshake{Version: test.v1, ID: crypto.FromECDSAPub(&prv1.PublicKey)[1:]}
+			wmsg    = []interface{}{strings.Repeat("test", 100)}
+			wg      sync.WaitGroup
+		)
+		fd0, fd1, err := pipes.TCPPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		run := func(fd net.Conn, prv *ecdsa.PrivateKey, remote *ecdsa.PublicKey, hs *protoHandshake) {
+			defer wg.Done()
+			defer fd.Close()
+			c := newRLPX(fd).(*rlpx)
+			if _, err := c.doEncHandshake(prv, remote); err != nil {
+				t.Errorf("test %d: enc handshake failed: %v", i, err)
+				return
+			}
+			if _, err := c.doProtoHandshake(hs); err != nil {
+				t.Errorf("test %d: proto handshake failed: %v", i, err)
+				return
+			}
+			if c.rw.snappy != test.wantSnappy {
+				t.Errorf("test %d: snappy = %t, want %t", i, c.rw.snappy, test.wantSnappy)
+			}
+			// Both sides send a message and read the other one.
+			werr := make(chan error, 1)
+			go func() { werr <- Send(c, 16, wmsg) }()
+			if err := ExpectMsg(c, 16, wmsg); err != nil {
+				t.Errorf("test %d: message exchange failed: %v", i, err)
+			}
+			if err := <-werr; err != nil {
+				t.Errorf("test %d: write failed: %v", i, err)
+			}
+		}
+		wg.Add(2)
+		go run(fd0, prv0, &prv1.PublicKey, hs0)
+		go run(fd1, prv1, nil, hs1)
+		wg.Wait()
+	}
+}
+
 type handshakeAuthTest struct {
 	input       string
 	isPlain     bool
@@ -0,0 +1,228 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	scoreHalfLife     = 30 * time.Minute // Time after which a peer's score decays to half
+	scoreMaxValue     = 100              // Upper bound of the score to prevent banking unlimited credit
+	scoreBanThreshold = -100             // Score at which a peer is disconnected and banned
+	scoreBanDuration  = time.Hour        // Time a misbehaving peer is refused connections for
+
+	scoreEvictionMargin = 1 // Score difference by which a newcomer must beat a peer to replace it
+
+	maxScoreEntries = 1024 // Maximum number of peer scores tracked in memory
+	minScoreEntry   = 1    // Absolute score below which entries are dropped when pruning
+)
+
+// ScoreEvent is an observation made by a sub-protocol about the behaviour of a
+// remote peer, used to adjust the peer's reputation.
+type ScoreEvent int
+
+const (
+	ScoreUsefulData   ScoreEvent = iota // Peer served data that was requested and accepted
+	ScoreSlowResponse                   // Peer replied too late or not at all to a request
+	ScoreInvalidData                    // Peer sent malformed, unrequested or useless data
+	ScoreInvalidBlock                   // Peer propagated a block that failed validation
+)
+
+// scoreWeights is the score adjustment applied for each event type.
+var scoreWeights = map[ScoreEvent]float64{
+	ScoreUsefulData:   1,
+	ScoreSlowResponse: -2,
+	ScoreInvalidData:  -10,
+	ScoreInvalidBlock: -50,
+}
+
+var scoreEventNames = map[ScoreEvent]string{
+	ScoreUsefulData:   "usefulData",
+	ScoreSlowResponse: "slowResponse",
+	ScoreInvalidData:  "invalidData",
+	ScoreInvalidBlock: "invalidBlock",
+}
+
+func (ev ScoreEvent) String() string {
+	if name, ok := scoreEventNames[ev]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// PeerScoreInfo represents a short summary of the reputation of a peer.
+type PeerScoreInfo struct {
+	ID          string            `json:"id"`                    // Unique node identifier
+	Score       float64           `json:"score"`                 // Current (decayed) score of the peer
+	Connected   bool              `json:"connected"`             // Whether the peer is currently connected
+	BannedUntil *time.Time        `json:"bannedUntil,omitempty"` // Expiry of the temporary ban, if any
+	Events      map[string]uint64 `json:"events"`                // Number of reported events per type
+}
+
+// peerScore is the reputation tracked for a single remote node.
+type peerScore struct {
+	value   float64               // Score at the time of the last update
+	updated mclock.AbsTime        // Time of the last update, used for decaying
+	banned  time.Time             // Expiry of the ban issued in this session, if any
+	events  map[ScoreEvent]uint64 // Number of reported events per type
+}
+
+// scoreTable tracks the reputation of remote peers based on the events reported
+// by the sub-protocols. Scores decay exponentially towards zero, so that both
+// good and bad behaviour is eventually forgotten. Peers whose score drops below
+// the ban threshold are banned for a while, which is persisted in the node
+// database to survive restarts.
+type scoreTable struct {
+	db    *enode.DB
+	clock mclock.Clock
+
+	lock   sync.Mutex
+	scores map[enode.ID]*peerScore
+}
+
+// newScoreTable creates a peer score tracker, persisting bans into db.
+func newScoreTable(db *enode.DB, clock mclock.Clock) *scoreTable {
+	return &scoreTable{
+		db:     db,
+		clock:  clock,
+		scores: make(map[enode.ID]*peerScore),
+	}
+}
+
+// decay brings the score of a peer up to date with the current time.
+func (t *scoreTable) decay(s *peerScore, now mclock.AbsTime) {
+	if elapsed := time.Duration(now - s.updated); elapsed > 0 {
+		s.value *= math.Exp2(-float64(elapsed) / float64(scoreHalfLife))
+	}
+	s.updated = now
+}
+
+// add applies the weight of a reported event to the score of a peer, returning
+// the updated score.
+func (t *scoreTable) add(id enode.ID, ev ScoreEvent) float64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.clock.Now()
+	s := t.scores[id]
+	if s == nil {
+		if len(t.scores) >= maxScoreEntries {
+			t.prune(now)
+		}
+		s = &peerScore{updated: now, events: make(map[ScoreEvent]uint64)}
+		t.scores[id] = s
+	}
+	t.decay(s, now)
+	s.value = math.Min(s.value+scoreWeights[ev], scoreMaxValue)
+	s.events[ev]++
+
+	return s.value
+}
+
+// score retrieves the current score of a peer. Unknown peers have a neutral
+// score of zero.
+func (t *scoreTable) score(id enode.ID) float64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	s := t.scores[id]
+	if s == nil {
+		return 0
+	}
+	t.decay(s, t.clock.Now())
+	return s.value
+}
+
+// ban refuses any connections from a peer for the ban duration and resets its
+// score, so it gets a fresh start after the ban expires.
+func (t *scoreTable) ban(id enode.ID) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	until := time.Now().Add(scoreBanDuration)
+	if s := t.scores[id]; s != nil {
+		s.value, s.updated, s.banned = 0, t.clock.Now(), until
+	}
+	t.db.UpdateBanExpiry(id, until)
+}
+
+// banned returns whether a peer is currently banned from connecting.
+func (t *scoreTable) banned(id enode.ID) bool {
+	return t.db.BanExpiry(id).After(time.Now())
+}
+
+// prune drops the entries of peers whose behaviour has been mostly forgotten.
+// If that doesn't make enough room, the least remarkable entries are dropped.
+func (t *scoreTable) prune(now mclock.AbsTime) {
+	type entry struct {
+		id    enode.ID
+		value float64
+	}
+	var entries []entry
+	for id, s := range t.scores {
+		t.decay(s, now)
+		if math.Abs(s.value) < minScoreEntry && !s.banned.After(time.Now()) {
+			delete(t.scores, id)
+			continue
+		}
+		entries = append(entries, entry{id, math.Abs(s.value)})
+	}
+	if len(entries) < maxScoreEntries {
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].value < entries[j].value })
+	for _, e := range entries[:len(entries)-maxScoreEntries+1] {
+		delete(t.scores, e.id)
+	}
+}
+
+// info gathers the reputation of all tracked peers.
+func (t *scoreTable) info(connected map[enode.ID]bool) []*PeerScoreInfo {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var (
+		now   = t.clock.Now()
+		infos = make([]*PeerScoreInfo, 0, len(t.scores))
+	)
+	for id, s := range t.scores {
+		t.decay(s, now)
+		info := &PeerScoreInfo{
+			ID:        id.String(),
+			Score:     s.value,
+			Connected: connected[id],
+			Events:    make(map[string]uint64),
+		}
+		if s.banned.After(time.Now()) {
+			until := s.banned
+			info.BannedUntil = &until
+		}
+		for ev, n := range s.events {
+			info.Events[ev.String()] = n
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
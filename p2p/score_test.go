@@ -0,0 +1,120 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func newTestScoreTable(t *testing.T) (*scoreTable, *mclock.Simulated) {
+	db, err := enode.OpenDB("")
+	if err != nil {
+		t.Fatalf("can't open node database: %v", err)
+	}
+	clock := new(mclock.Simulated)
+	return newScoreTable(db, clock), clock
+}
+
+// Tests that scores accumulate reported events and decay towards zero.
+func TestScoreDecay(t *testing.T) {
+	table, clock := newTestScoreTable(t)
+	defer table.db.Close()
+
+	id := randomID()
+	if score := table.score(id); score != 0 {
+		t.Fatalf("unknown peer score mismatch: have %v, want 0", score)
+	}
+	table.add(id, ScoreInvalidData)
+	table.add(id, ScoreUsefulData)
+	if score, want := table.score(id), scoreWeights[ScoreInvalidData]+scoreWeights[ScoreUsefulData]; score != want {
+		t.Fatalf("score mismatch: have %v, want %v", score, want)
+	}
+	clock.Run(scoreHalfLife)
+	if score, want := table.score(id), (scoreWeights[ScoreInvalidData]+scoreWeights[ScoreUsefulData])/2; math.Abs(score-want) > 1e-9 {
+		t.Fatalf("decayed score mismatch: have %v, want %v", score, want)
+	}
+}
+
+// Tests that positive scores are capped.
+func TestScoreCap(t *testing.T) {
+	table, _ := newTestScoreTable(t)
+	defer table.db.Close()
+
+	id := randomID()
+	for i := 0; i < 2*scoreMaxValue; i++ {
+		table.add(id, ScoreUsefulData)
+	}
+	if score := table.score(id); score != scoreMaxValue {
+		t.Fatalf("score mismatch: have %v, want %v", score, scoreMaxValue)
+	}
+}
+
+// Tests that bans are persisted into the node database and reset the score.
+func TestScoreBan(t *testing.T) {
+	table, _ := newTestScoreTable(t)
+	defer table.db.Close()
+
+	id := randomID()
+	table.add(id, ScoreInvalidBlock)
+	if table.banned(id) {
+		t.Fatalf("peer banned before misbehaving")
+	}
+	table.ban(id)
+	if !table.banned(id) {
+		t.Fatalf("peer not banned")
+	}
+	if score := table.score(id); score != 0 {
+		t.Fatalf("score not reset after ban: have %v", score)
+	}
+	// A fresh table over the same database should still see the ban
+	if !newScoreTable(table.db, mclock.System{}).banned(id) {
+		t.Fatalf("ban not persisted")
+	}
+	infos := table.info(map[enode.ID]bool{id: true})
+	if len(infos) != 1 || !infos[0].Connected || infos[0].BannedUntil == nil || infos[0].Events["invalidBlock"] != 1 {
+		t.Fatalf("score info mismatch: %+v", infos[0])
+	}
+}
+
+// Tests that the number of tracked peers is bounded, dropping the forgotten
+// and least remarkable entries first.
+func TestScorePrune(t *testing.T) {
+	table, clock := newTestScoreTable(t)
+	defer table.db.Close()
+
+	bad := randomID()
+	table.add(bad, ScoreInvalidBlock)
+	for i := 0; i < maxScoreEntries; i++ {
+		table.add(randomID(), ScoreUsefulData)
+	}
+	if len(table.scores) > maxScoreEntries {
+		t.Fatalf("too many entries: have %d, max %d", len(table.scores), maxScoreEntries)
+	}
+	if _, ok := table.scores[bad]; !ok {
+		t.Fatalf("remarkable entry dropped")
+	}
+	// Once forgotten, entries get dropped wholesale
+	clock.Run(10 * scoreHalfLife)
+	table.add(randomID(), ScoreUsefulData)
+	if len(table.scores) != 1 {
+		t.Fatalf("forgotten entries not dropped: have %d, want 1", len(table.scores))
+	}
+}
@@ -34,7 +34,6 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/discover"
-	"github.com/ethereum/go-ethereum/p2p/discv5"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/p2p/nat"
@@ -83,8 +82,8 @@ type Config struct {
 	// Disabling is useful for protocol debugging (manual topology).
 	NoDiscovery bool
 
-	// DiscoveryV5 specifies whether the new topic-discovery based V5 discovery
-	// protocol should be started or not.
+	// DiscoveryV5 specifies whether the topic-discovery based V5 discovery
+	// protocol should be started or not. It shares the UDP port with V4.
 	DiscoveryV5 bool `toml:",omitempty"`
 
 	// Name sets the node name of this server.
@@ -97,8 +96,13 @@ type Config struct {
 
 	// BootstrapNodesV5 are used to establish connectivity
 	// with the rest of the network using the V5 discovery
-	// protocol.
-	BootstrapNodesV5 []*discv5.Node `toml:",omitempty"`
+	// protocol. If empty, BootstrapNodes are used instead.
+	//
+	// This used to be a list of *discv5.Node, the experimental p2p/discv5
+	// package having been removed. Existing configuration files listing enode://
+	// URLs remain valid, code building the list should parse the URLs using
+	// enode.ParseV4 instead of discv5.ParseNode.
+	BootstrapNodesV5 []*enode.Node `toml:",omitempty"`
 
 	// Static nodes are used as pre-configured connections which are always
 	// maintained and re-connected on disconnects.
@@ -142,6 +146,11 @@ type Config struct {
 	// If NoDial is true, the server will not dial any peers.
 	NoDial bool `toml:",omitempty"`
 
+	// If NoCompression is true, the server advertises the legacy devp2p
+	// version 4 in the protocol handshake, so messages exchanged with all
+	// peers are sent uncompressed instead of Snappy compressed.
+	NoCompression bool `toml:",omitempty"`
+
 	// If EnableMsgEvents is set then the server will emit PeerEvents
 	// whenever a message is sent to or received from a peer
 	EnableMsgEvents bool
@@ -164,12 +173,13 @@ type Server struct {
 	running bool
 
 	nodedb       *enode.DB
+	scores       *scoreTable
 	localnode    *enode.LocalNode
 	ntab         discoverTable
 	listener     net.Listener
 	ourHandshake *protoHandshake
-	lastLookup   time.Time
-	DiscV5       *discv5.Network
+	DiscV5       *discover.UDPv5
+	dialsched    *dialScheduler
 
 	// These are for Peers, PeerCount (and nothing else).
 	peerOp     chan peerOpFunc
@@ -361,6 +371,15 @@ func (srv *Server) Self() *enode.Node {
 	return ln.Node()
 }
 
+// LocalNode returns the local node record, allowing protocols to advertise their
+// own entries in it. It is only available once the server has been started.
+func (srv *Server) LocalNode() *enode.LocalNode {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	return srv.localnode
+}
+
 // Stop terminates the server and all active peer connections.
 // It blocks until all active connections have been closed.
 func (srv *Server) Stop() {
@@ -386,7 +405,7 @@ type sharedUDPConn struct {
 	unhandled chan discover.ReadPacket
 }
 
-// ReadFromUDP implements discv5.conn
+// ReadFromUDP implements discover.conn
 func (s *sharedUDPConn) ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err error) {
 	packet, ok := <-s.unhandled
 	if !ok {
@@ -400,7 +419,7 @@ func (s *sharedUDPConn) ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err err
 	return l, packet.Addr, nil
 }
 
-// Close implements discv5.conn
+// Close implements discover.conn
 func (s *sharedUDPConn) Close() error {
 	return nil
 }
@@ -446,6 +465,8 @@ func (srv *Server) Start() (err error) {
 	if err := srv.setupLocalNode(); err != nil {
 		return err
 	}
+	srv.scores = newScoreTable(srv.nodedb, mclock.System{})
+
 	if srv.ListenAddr != "" {
 		if err := srv.setupListening(); err != nil {
 			return err
@@ -454,11 +475,10 @@ func (srv *Server) Start() (err error) {
 	if err := srv.setupDiscovery(); err != nil {
 		return err
 	}
+	srv.setupDialScheduler()
 
-	dynPeers := srv.maxDialedConns()
-	dialer := newDialState(srv.localnode.ID(), srv.StaticNodes, srv.BootstrapNodes, srv.ntab, dynPeers, srv.NetRestrict)
 	srv.loopWG.Add(1)
-	go srv.run(dialer)
+	go srv.run()
 	return nil
 }
 
@@ -466,6 +486,9 @@ func (srv *Server) setupLocalNode() error {
 	// Create the devp2p handshake.
 	pubkey := crypto.FromECDSAPub(&srv.PrivateKey.PublicKey)
 	srv.ourHandshake = &protoHandshake{Version: baseProtocolVersion, Name: srv.Name, ID: pubkey[1:]}
+	if srv.NoCompression {
+		srv.ourHandshake.Version = snappyProtocolVersion - 1
+	}
 	for _, p := range srv.Protocols {
 		srv.ourHandshake.Caps = append(srv.ourHandshake.Caps, p.cap())
 	}
@@ -507,6 +530,50 @@ func (srv *Server) setupLocalNode() error {
 	return nil
 }
 
+func (srv *Server) setupDialScheduler() {
+	config := dialConfig{
+		self:           srv.localnode.ID(),
+		maxDialPeers:   srv.maxDialedConns(),
+		maxActiveDials: maxActiveDialTasks,
+		netRestrict:    srv.NetRestrict,
+		dialer:         srv.Dialer,
+		bootnodes:      srv.BootstrapNodes,
+		log:            srv.log,
+	}
+	if srv.ntab != nil {
+		config.resolver = srv.ntab
+	}
+	srv.dialsched = newDialScheduler(config, srv.dialSources(), srv.SetupConn)
+	for _, n := range srv.StaticNodes {
+		srv.dialsched.addStatic(n)
+	}
+}
+
+// dialSources returns the sources of dynamic dial candidates: the discovery
+// table and the dial candidate iterators of all protocols.
+func (srv *Server) dialSources() []*dialSource {
+	var sources []*dialSource
+	if srv.ntab != nil {
+		sources = append(sources, newDialSource("discv4", srv.ntab.RandomNodes()))
+	}
+	for _, p := range srv.Protocols {
+		if p.DialCandidates != nil {
+			sources = append(sources, newDialSource(p.Name, p.DialCandidates))
+		}
+	}
+	return sources
+}
+
+// hasDialCandidates reports whether any protocol provides dial candidates.
+func (srv *Server) hasDialCandidates() bool {
+	for _, p := range srv.Protocols {
+		if p.DialCandidates != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (srv *Server) setupDiscovery() error {
 	if srv.NoDiscovery && !srv.DiscoveryV5 {
 		return nil
@@ -551,20 +618,25 @@ func (srv *Server) setupDiscovery() error {
 	}
 	// Discovery V5
 	if srv.DiscoveryV5 {
-		var ntab *discv5.Network
+		cfg := discover.Config{
+			PrivateKey:  srv.PrivateKey,
+			NetRestrict: srv.NetRestrict,
+			Bootnodes:   srv.BootstrapNodesV5,
+		}
+		if len(cfg.Bootnodes) == 0 {
+			// Both protocols run on the same port, so v4 bootnodes are
+			// reachable through v5 as long as they run it too.
+			cfg.Bootnodes = srv.BootstrapNodes
+		}
 		var err error
 		if sconn != nil {
-			ntab, err = discv5.ListenUDP(srv.PrivateKey, sconn, "", srv.NetRestrict)
+			srv.DiscV5, err = discover.ListenV5(sconn, srv.localnode, cfg)
 		} else {
-			ntab, err = discv5.ListenUDP(srv.PrivateKey, conn, "", srv.NetRestrict)
+			srv.DiscV5, err = discover.ListenV5(conn, srv.localnode, cfg)
 		}
 		if err != nil {
 			return err
 		}
-		if err := ntab.SetFallbackNodes(srv.BootstrapNodesV5); err != nil {
-			return err
-		}
-		srv.DiscV5 = ntab
 	}
 	return nil
 }
@@ -594,14 +666,7 @@ func (srv *Server) setupListening() error {
 	return nil
 }
 
-type dialer interface {
-	newTasks(running int, peers map[enode.ID]*Peer, now time.Time) []task
-	taskDone(task, time.Time)
-	addStatic(*enode.Node)
-	removeStatic(*enode.Node)
-}
-
-func (srv *Server) run(dialstate dialer) {
+func (srv *Server) run() {
 	srv.log.Info("Started P2P networking", "self", srv.localnode.Node())
 	defer srv.loopWG.Done()
 	defer srv.nodedb.Close()
@@ -610,9 +675,6 @@ func (srv *Server) run(dialstate dialer) {
 		peers        = make(map[enode.ID]*Peer)
 		inboundCount = 0
 		trusted      = make(map[enode.ID]bool, len(srv.TrustedNodes))
-		taskdone     = make(chan task, maxActiveDialTasks)
-		runningTasks []task
-		queuedTasks  []task // tasks that can't run yet
 	)
 	// Put trusted nodes into a map to speed up checks.
 	// Trusted peers are loaded on startup or added via AddTrustedPeer RPC.
@@ -620,40 +682,8 @@ func (srv *Server) run(dialstate dialer) {
 		trusted[n.ID()] = true
 	}
 
-	// removes t from runningTasks
-	delTask := func(t task) {
-		for i := range runningTasks {
-			if runningTasks[i] == t {
-				runningTasks = append(runningTasks[:i], runningTasks[i+1:]...)
-				break
-			}
-		}
-	}
-	// starts until max number of active tasks is satisfied
-	startTasks := func(ts []task) (rest []task) {
-		i := 0
-		for ; len(runningTasks) < maxActiveDialTasks && i < len(ts); i++ {
-			t := ts[i]
-			srv.log.Trace("New dial task", "task", t)
-			go func() { t.Do(srv); taskdone <- t }()
-			runningTasks = append(runningTasks, t)
-		}
-		return ts[i:]
-	}
-	scheduleTasks := func() {
-		// Start from queue first.
-		queuedTasks = append(queuedTasks[:0], startTasks(queuedTasks)...)
-		// Query dialer for new tasks and start as many as possible now.
-		if len(runningTasks) < maxActiveDialTasks {
-			nt := dialstate.newTasks(len(runningTasks)+len(queuedTasks), peers, time.Now())
-			queuedTasks = append(queuedTasks, startTasks(nt)...)
-		}
-	}
-
 running:
 	for {
-		scheduleTasks()
-
 		select {
 		case <-srv.quit:
 			// The server was stopped. Run the cleanup logic.
@@ -663,13 +693,13 @@ running:
 			// ephemeral static peer list. Add it to the dialer,
 			// it will keep the node connected.
 			srv.log.Trace("Adding static node", "node", n)
-			dialstate.addStatic(n)
+			srv.dialsched.addStatic(n)
 		case n := <-srv.removestatic:
 			// This channel is used by RemovePeer to send a
 			// disconnect request to a peer and begin the
 			// stop keeping the node connected.
 			srv.log.Trace("Removing static node", "node", n)
-			dialstate.removeStatic(n)
+			srv.dialsched.removeStatic(n)
 			if p, ok := peers[n.ID()]; ok {
 				p.Disconnect(DiscRequested)
 			}
@@ -697,13 +727,6 @@ running:
 			// This channel is used by Peers and PeerCount.
 			op(peers)
 			srv.peerOpDone <- struct{}{}
-		case t := <-taskdone:
-			// A task got done. Tell dialstate about it so it
-			// can update its state and remove it from the active
-			// tasks list.
-			srv.log.Trace("Dial task done", "task", t)
-			dialstate.taskDone(t, time.Now())
-			delTask(t)
 		case c := <-srv.posthandshake:
 			// A connection has passed the encryption handshake so
 			// the remote identity is known (but hasn't been verified yet).
@@ -723,7 +746,11 @@ running:
 			err := srv.protoHandshakeChecks(peers, inboundCount, c)
 			if err == nil {
 				// The handshakes are done and it passed all checks.
+				// Make room for the new peer if the limits are reached.
+				srv.evictPeer(peers, inboundCount, c)
+
 				p := newPeer(c, srv.Protocols)
+				p.scores = srv.scores
 				// If message events are enabled, pass the peerFeed
 				// to the peer
 				if srv.EnableMsgEvents {
@@ -733,6 +760,7 @@ running:
 				srv.log.Debug("Adding p2p peer", "name", name, "addr", c.fd.RemoteAddr(), "peers", len(peers)+1)
 				go srv.runPeer(p)
 				peers[c.node.ID()] = p
+				srv.dialsched.peerAdded(c)
 				if p.Inbound() {
 					inboundCount++
 				}
@@ -750,6 +778,7 @@ running:
 			d := common.PrettyDuration(mclock.Now() - pd.created)
 			pd.log.Debug("Removing p2p peer", "duration", d, "peers", len(peers)-1, "req", pd.requested, "err", pd.err)
 			delete(peers, pd.ID())
+			srv.dialsched.peerRemoved(pd.rw)
 			if pd.Inbound() {
 				inboundCount--
 			}
@@ -765,16 +794,19 @@ running:
 	if srv.DiscV5 != nil {
 		srv.DiscV5.Close()
 	}
+	// Stop the dialer. Pending dials terminate soon because srv.quit
+	// is closed.
+	srv.dialsched.stop()
 	// Disconnect all peers.
 	for _, p := range peers {
 		p.Disconnect(DiscQuitting)
 	}
-	// Wait for peers to shut down. Pending connections and tasks are
-	// not handled here and will terminate soon-ish because srv.quit
+	// Wait for peers to shut down. Pending connections are not
+	// handled here and will terminate soon-ish because srv.quit
 	// is closed.
 	for len(peers) > 0 {
 		p := <-srv.delpeer
-		p.log.Trace("<-delpeer (spindown)", "remainingTasks", len(runningTasks))
+		p.log.Trace("<-delpeer (spindown)")
 		delete(peers, p.ID())
 	}
 }
@@ -791,24 +823,64 @@ func (srv *Server) protoHandshakeChecks(peers map[enode.ID]*Peer, inboundCount i
 
 func (srv *Server) encHandshakeChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) error {
 	switch {
-	case !c.is(trustedConn|staticDialedConn) && len(peers) >= srv.MaxPeers:
+	case !c.is(trustedConn|staticDialedConn) && len(peers) >= srv.MaxPeers && srv.evictionCandidate(peers, c, false) == nil:
 		return DiscTooManyPeers
-	case !c.is(trustedConn) && c.is(inboundConn) && inboundCount >= srv.maxInboundConns():
+	case !c.is(trustedConn) && c.is(inboundConn) && inboundCount >= srv.maxInboundConns() && srv.evictionCandidate(peers, c, true) == nil:
 		return DiscTooManyPeers
 	case peers[c.node.ID()] != nil:
 		return DiscAlreadyConnected
 	case c.node.ID() == srv.localnode.ID():
 		return DiscSelf
+	case !c.is(trustedConn) && srv.scores.banned(c.node.ID()):
+		return DiscUselessPeer
 	default:
 		return nil
 	}
 }
 
+// evictPeer disconnects the lowest scored peer if admitting c would exceed
+// the peer limits. The checks must have passed for c, ensuring that a peer
+// eligible for eviction exists.
+func (srv *Server) evictPeer(peers map[enode.ID]*Peer, inboundCount int, c *conn) {
+	var victim *Peer
+	switch {
+	case !c.is(trustedConn) && c.is(inboundConn) && inboundCount >= srv.maxInboundConns():
+		victim = srv.evictionCandidate(peers, c, true)
+	case !c.is(trustedConn|staticDialedConn) && len(peers) >= srv.MaxPeers:
+		victim = srv.evictionCandidate(peers, c, false)
+	}
+	if victim != nil {
+		victim.log.Debug("Evicting p2p peer", "score", srv.scores.score(victim.ID()), "replacement", c.node.ID())
+		victim.evicted = true
+		victim.Disconnect(DiscTooManyPeers)
+	}
+}
+
+// evictionCandidate selects the lowest scored peer which may be disconnected to
+// make room for c, considering only inbound peers if requested. Trusted and
+// static peers are never evicted, and only peers with a worse reputation than
+// the newcomer are eligible.
+func (srv *Server) evictionCandidate(peers map[enode.ID]*Peer, c *conn, inbound bool) *Peer {
+	var (
+		victim *Peer
+		worst  = srv.scores.score(c.node.ID()) - scoreEvictionMargin
+	)
+	for _, p := range peers {
+		if p.evicted || p.rw.is(trustedConn|staticDialedConn) || (inbound && !p.Inbound()) {
+			continue
+		}
+		if score := srv.scores.score(p.ID()); score < worst {
+			victim, worst = p, score
+		}
+	}
+	return victim
+}
+
 func (srv *Server) maxInboundConns() int {
 	return srv.MaxPeers - srv.maxDialedConns()
 }
 func (srv *Server) maxDialedConns() int {
-	if srv.NoDiscovery || srv.NoDial {
+	if srv.NoDial || (srv.NoDiscovery && !srv.hasDialCandidates()) {
 		return 0
 	}
 	r := srv.DialRatio
@@ -1059,6 +1131,19 @@ func (srv *Server) NodeInfo() *NodeInfo {
 	return info
 }
 
+// PeerScores returns the reputation of the peers tracked by the server, both
+// connected and recently seen ones.
+func (srv *Server) PeerScores() []*PeerScoreInfo {
+	if srv.scores == nil {
+		return nil
+	}
+	connected := make(map[enode.ID]bool)
+	for _, p := range srv.Peers() {
+		connected[p.ID()] = true
+	}
+	return srv.scores.info(connected)
+}
+
 // PeersInfo returns an array of metadata objects describing connected peers.
 func (srv *Server) PeersInfo() []*PeerInfo {
 	// Gather all the generic and sub-protocol specific infos
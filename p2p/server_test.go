@@ -17,8 +17,10 @@
 package p2p
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"errors"
+	"fmt"
 	"math/rand"
 	"net"
 	"reflect"
@@ -202,151 +204,71 @@ func TestServerDial(t *testing.T) {
 	}
 }
 
-// This test checks that tasks generated by dialstate are
-// actually executed and taskdone is called for them.
-func TestServerTaskScheduling(t *testing.T) {
+// This test checks that nodes provided by a protocol's dial candidate iterator
+// are dialed even with discovery disabled, and that Stop closes the iterator.
+func TestServerDialCandidates(t *testing.T) {
 	var (
-		done           = make(chan *testTask)
-		quit, returned = make(chan struct{}), make(chan struct{})
-		tc             = 0
-		tg             = taskgen{
-			newFunc: func(running int, peers map[enode.ID]*Peer) []task {
-				tc++
-				return []task{&testTask{index: tc - 1}}
-			},
-			doneFunc: func(t task) {
-				select {
-				case done <- t.(*testTask):
-				case <-quit:
-				}
-			},
-		}
+		node   = enode.NewV4(&newkey().PublicKey, net.IP{127, 0, 0, 1}, 30303, 0)
+		it     = &blockingIter{next: []*enode.Node{node}, closed: make(chan struct{})}
+		dialed = make(chan *enode.Node, 1)
 	)
-
-	// The Server in this test isn't actually running
-	// because we're only interested in what run does.
-	db, _ := enode.OpenDB("")
 	srv := &Server{
-		Config:    Config{MaxPeers: 10},
-		localnode: enode.NewLocalNode(db, newkey()),
-		nodedb:    db,
-		quit:      make(chan struct{}),
-		ntab:      fakeTable{},
-		running:   true,
-		log:       log.New(),
-	}
-	srv.loopWG.Add(1)
-	go func() {
-		srv.run(tg)
-		close(returned)
-	}()
-
-	var gotdone []*testTask
-	for i := 0; i < 100; i++ {
-		gotdone = append(gotdone, <-done)
+		Config: Config{
+			PrivateKey:  newkey(),
+			MaxPeers:    10,
+			NoDiscovery: true,
+			Protocols:   []Protocol{{Name: "test", Version: 1, DialCandidates: it}},
+			Dialer:      dialRecorder(dialed),
+		},
 	}
-	for i, task := range gotdone {
-		if task.index != i {
-			t.Errorf("task %d has wrong index, got %d", i, task.index)
-			break
-		}
-		if !task.called {
-			t.Errorf("task %d was not called", i)
-			break
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case n := <-dialed:
+		if n.ID() != node.ID() {
+			t.Errorf("dialed wrong node %v", n.ID())
 		}
+	case <-time.After(time.Second):
+		t.Error("dial candidate was not dialed")
 	}
-
-	close(quit)
 	srv.Stop()
 	select {
-	case <-returned:
-	case <-time.After(500 * time.Millisecond):
-		t.Error("Server.run did not return within 500ms")
+	case <-it.closed:
+	default:
+		t.Error("iterator not closed by Stop")
 	}
 }
 
-// This test checks that Server doesn't drop tasks,
-// even if newTasks returns more than the maximum number of tasks.
-func TestServerManyTasks(t *testing.T) {
-	alltasks := make([]task, 300)
-	for i := range alltasks {
-		alltasks[i] = &testTask{index: i}
-	}
-
-	var (
-		db, _ = enode.OpenDB("")
-		srv   = &Server{
-			quit:      make(chan struct{}),
-			localnode: enode.NewLocalNode(db, newkey()),
-			nodedb:    db,
-			ntab:      fakeTable{},
-			running:   true,
-			log:       log.New(),
-		}
-		done       = make(chan *testTask)
-		start, end = 0, 0
-	)
-	defer srv.Stop()
-	srv.loopWG.Add(1)
-	go srv.run(taskgen{
-		newFunc: func(running int, peers map[enode.ID]*Peer) []task {
-			start, end = end, end+maxActiveDialTasks+10
-			if end > len(alltasks) {
-				end = len(alltasks)
-			}
-			return alltasks[start:end]
-		},
-		doneFunc: func(tt task) {
-			done <- tt.(*testTask)
-		},
-	})
+// dialRecorder is a NodeDialer which reports all dialed nodes and fails.
+type dialRecorder chan *enode.Node
 
-	doneset := make(map[int]bool)
-	timeout := time.After(2 * time.Second)
-	for len(doneset) < len(alltasks) {
-		select {
-		case tt := <-done:
-			if doneset[tt.index] {
-				t.Errorf("task %d got done more than once", tt.index)
-			} else {
-				doneset[tt.index] = true
-			}
-		case <-timeout:
-			t.Errorf("%d of %d tasks got done within 2s", len(doneset), len(alltasks))
-			for i := 0; i < len(alltasks); i++ {
-				if !doneset[i] {
-					t.Logf("task %d not done", i)
-				}
-			}
-			return
-		}
+func (d dialRecorder) Dial(n *enode.Node) (net.Conn, error) {
+	select {
+	case d <- n:
+	default:
 	}
+	return nil, errors.New("dial disabled in test")
 }
 
-type taskgen struct {
-	newFunc  func(running int, peers map[enode.ID]*Peer) []task
-	doneFunc func(task)
+// blockingIter returns the given nodes, then blocks until closed.
+type blockingIter struct {
+	next   []*enode.Node
+	cur    *enode.Node
+	closed chan struct{}
 }
 
-func (tg taskgen) newTasks(running int, peers map[enode.ID]*Peer, now time.Time) []task {
-	return tg.newFunc(running, peers)
-}
-func (tg taskgen) taskDone(t task, now time.Time) {
-	tg.doneFunc(t)
-}
-func (tg taskgen) addStatic(*enode.Node) {
-}
-func (tg taskgen) removeStatic(*enode.Node) {
-}
-
-type testTask struct {
-	index  int
-	called bool
+func (it *blockingIter) Next() bool {
+	if len(it.next) > 0 {
+		it.cur, it.next = it.next[0], it.next[1:]
+		return true
+	}
+	<-it.closed
+	return false
 }
 
-func (t *testTask) Do(srv *Server) {
-	t.called = true
-}
+func (it *blockingIter) Node() *enode.Node { return it.cur }
+func (it *blockingIter) Close()            { close(it.closed) }
 
 // This test checks that connections are disconnected
 // just after the encryption handshake when the server is
@@ -616,3 +538,180 @@ func randomID() (id enode.ID) {
 	}
 	return id
 }
+
+func TestServerPeerEviction(t *testing.T) {
+	srv := &Server{
+		Config: Config{
+			PrivateKey: newkey(),
+			MaxPeers:   10,
+			NoDial:     true,
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	remote := newkey()
+	newconn := func(id enode.ID) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&remote.PublicKey, fd)
+		node := enode.SignNull(new(enr.Record), id)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}
+	}
+	// Inject a few connections to fill up the peer set.
+	ids := make([]enode.ID, 10)
+	for i := range ids {
+		ids[i] = randomID()
+		if err := srv.checkpoint(newconn(ids[i]), srv.addpeer); err != nil {
+			t.Fatalf("could not add conn %d: %v", i, err)
+		}
+	}
+	// A newcomer can't replace peers with a neutral reputation.
+	if err := srv.checkpoint(newconn(randomID()), srv.posthandshake); err != DiscTooManyPeers {
+		t.Fatal("wrong error for insert:", err)
+	}
+	// Misbehaving peers get evicted in favour of the newcomer.
+	srv.scores.add(ids[3], ScoreInvalidData)
+
+	newID := randomID()
+	if err := srv.checkpoint(newconn(newID), srv.posthandshake); err != nil {
+		t.Fatal("unexpected error for insert @posthandshake:", err)
+	}
+	if err := srv.checkpoint(newconn(newID), srv.addpeer); err != nil {
+		t.Fatal("unexpected error for insert @addpeer:", err)
+	}
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		connected := make(map[enode.ID]bool)
+		for _, p := range srv.Peers() {
+			connected[p.ID()] = true
+		}
+		if !connected[ids[3]] && connected[newID] && len(connected) == 10 {
+			break
+		}
+		if time.Since(start) > time.Second {
+			t.Fatalf("peer not evicted: connected %v", connected)
+		}
+	}
+	// Another newcomer can't get in once the misbehaving peer is gone.
+	if err := srv.checkpoint(newconn(randomID()), srv.posthandshake); err != DiscTooManyPeers {
+		t.Fatal("wrong error for insert:", err)
+	}
+}
+
+func TestServerBannedPeer(t *testing.T) {
+	srv := &Server{
+		Config: Config{
+			PrivateKey: newkey(),
+			MaxPeers:   10,
+			NoDial:     true,
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	remote := newkey()
+	newconn := func(id enode.ID) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&remote.PublicKey, fd)
+		node := enode.SignNull(new(enr.Record), id)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}
+	}
+	id := randomID()
+	if err := srv.checkpoint(newconn(id), srv.addpeer); err != nil {
+		t.Fatalf("could not add conn: %v", err)
+	}
+	peers := srv.Peers()
+	if len(peers) != 1 {
+		t.Fatalf("peer count mismatch: have %d, want 1", len(peers))
+	}
+	// Misbehave until the peer gets banned and disconnected.
+	for i := 0; i < 3; i++ {
+		peers[0].Report(ScoreInvalidBlock)
+	}
+	for start := time.Now(); srv.PeerCount() != 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("banned peer not disconnected")
+		}
+	}
+	if err := srv.checkpoint(newconn(id), srv.posthandshake); err != DiscUselessPeer {
+		t.Fatal("wrong error for banned peer:", err)
+	}
+	scores := srv.PeerScores()
+	if len(scores) != 1 || scores[0].ID != id.String() || scores[0].BannedUntil == nil {
+		t.Fatalf("ban not reported: %+v", scores)
+	}
+	// Trusted peers ignore bans.
+	srv.AddTrustedPeer(newNode(id, nil))
+	if err := srv.checkpoint(newconn(id), srv.posthandshake); err != nil {
+		t.Fatal("unexpected error for trusted peer:", err)
+	}
+}
+
+// Tests that servers exchange messages whether or not compression is disabled
+// on either side, compressing them only if it is enabled on both.
+func TestServerCompressionInterop(t *testing.T) {
+	tests := []struct{ noCompression0, noCompression1 bool }{
+		{false, false},
+		{false, true},
+		{true, false},
+		{true, true},
+	}
+	payload := make([]byte, 64*1024)
+	for i, test := range tests {
+		results := make(chan error, 2)
+		start := func(noCompression bool) *Server {
+			srv := &Server{Config: Config{
+				PrivateKey:    newkey(),
+				MaxPeers:      10,
+				ListenAddr:    "127.0.0.1:0",
+				NoDiscovery:   true,
+				NoCompression: noCompression,
+				Protocols: []Protocol{{
+					Name:    "test",
+					Version: 1,
+					Length:  1,
+					Run: func(p *Peer, rw MsgReadWriter) error {
+						if snappy := p.rw.transport.(*rlpx).rw.snappy; snappy != (!test.noCompression0 && !test.noCompression1) {
+							results <- fmt.Errorf("snappy enabled: %v", snappy)
+							return nil
+						}
+						go Send(rw, 0, payload)
+						var have []byte
+						msg, err := rw.ReadMsg()
+						if err == nil {
+							err = msg.Decode(&have)
+						}
+						if err == nil && !bytes.Equal(have, payload) {
+							err = errors.New("payload mismatch")
+						}
+						results <- err
+						_, err = rw.ReadMsg()
+						return err
+					},
+				}},
+			}}
+			if err := srv.Start(); err != nil {
+				t.Fatalf("test %d: could not start server: %v", i, err)
+			}
+			return srv
+		}
+		srv0, srv1 := start(test.noCompression0), start(test.noCompression1)
+		srv0.AddPeer(srv1.Self())
+
+		for j := 0; j < 2; j++ {
+			select {
+			case err := <-results:
+				if err != nil {
+					t.Errorf("test %d: exchange failed: %v", i, err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("test %d: peers not connected", i)
+			}
+		}
+		srv0.Stop()
+		srv1.Stop()
+	}
+}
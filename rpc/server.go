@@ -313,13 +313,26 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 	if req.callb.errPos >= 0 { // test if method returned an error
 		if !reply[req.callb.errPos].IsNil() {
 			e := reply[req.callb.errPos].Interface().(error)
-			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
-			return res, nil
+			return callbackErrorResponse(codec, &req.id, e), nil
 		}
 	}
 	return codec.CreateResponse(req.id, reply[0].Interface()), nil
 }
 
+// callbackErrorResponse assembles the error response for an error returned by
+// a callback. Errors implementing Error keep their own code, errors implementing
+// DataError have their data attached to the response.
+func callbackErrorResponse(codec ServerCodec, id interface{}, err error) interface{} {
+	rpcErr, ok := err.(Error)
+	if !ok {
+		rpcErr = &callbackError{err.Error()}
+	}
+	if de, ok := err.(DataError); ok {
+		return codec.CreateErrorResponseWithInfo(id, rpcErr, de.ErrorData())
+	}
+	return codec.CreateErrorResponse(id, rpcErr)
+}
+
 // exec executes the given request and writes the result back using the codec.
 func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest) {
 	var response interface{}
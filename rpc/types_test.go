@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
 )
 
@@ -64,3 +65,80 @@ func TestBlockNumberJSONUnmarshal(t *testing.T) {
 		}
 	}
 }
+
+func TestBlockNumberOrHashJSONUnmarshal(t *testing.T) {
+	tests := []struct {
+		input    string
+		mustFail bool
+		expected BlockNumberOrHash
+	}{
+		0:  {`"0x"`, true, BlockNumberOrHash{}},
+		1:  {`"0x0"`, false, BlockNumberOrHashWithNumber(0)},
+		2:  {`"0X1"`, false, BlockNumberOrHashWithNumber(1)},
+		3:  {`"0x00"`, true, BlockNumberOrHash{}},
+		4:  {`"0x12"`, false, BlockNumberOrHashWithNumber(18)},
+		5:  {`"0x7fffffffffffffff"`, false, BlockNumberOrHashWithNumber(math.MaxInt64)},
+		6:  {`"0x8000000000000000"`, true, BlockNumberOrHash{}},
+		7:  {"0", true, BlockNumberOrHash{}},
+		8:  {`"pending"`, false, BlockNumberOrHashWithNumber(PendingBlockNumber)},
+		9:  {`"latest"`, false, BlockNumberOrHashWithNumber(LatestBlockNumber)},
+		10: {`"earliest"`, false, BlockNumberOrHashWithNumber(EarliestBlockNumber)},
+		11: {`someString`, true, BlockNumberOrHash{}},
+		12: {`""`, true, BlockNumberOrHash{}},
+		13: {``, true, BlockNumberOrHash{}},
+		14: {`"0x0000000000000000000000000000000000000000000000000000000000000000"`, false, BlockNumberOrHashWithHash(common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000000"), false)},
+		15: {`{"blockHash":"0x0000000000000000000000000000000000000000000000000000000000000000"}`, false, BlockNumberOrHashWithHash(common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000000"), false)},
+		16: {`{"blockNumber":"0x1"}`, false, BlockNumberOrHashWithNumber(1)},
+		17: {`{"blockNumber":"pending"}`, false, BlockNumberOrHashWithNumber(PendingBlockNumber)},
+		18: {`{"blockNumber":"0x1","blockHash":"0x0000000000000000000000000000000000000000000000000000000000000000"}`, true, BlockNumberOrHash{}},
+		19: {`{"blockHash":"0x0000000000000000000000000000000000000000000000000000000000000000","requireCanonical":true}`, false, BlockNumberOrHashWithHash(common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000000"), true)},
+	}
+
+	for i, test := range tests {
+		var bnh BlockNumberOrHash
+		err := json.Unmarshal([]byte(test.input), &bnh)
+		if test.mustFail && err == nil {
+			t.Errorf("Test %d should fail", i)
+			continue
+		}
+		if !test.mustFail && err != nil {
+			t.Errorf("Test %d should pass but got err: %v", i, err)
+			continue
+		}
+		if test.mustFail {
+			continue
+		}
+		if bnh.String() != test.expected.String() {
+			t.Errorf("Test %d got unexpected value, want %v, got %v", i, test.expected.String(), bnh.String())
+		}
+		if bnh.RequireCanonical != test.expected.RequireCanonical {
+			t.Errorf("Test %d got unexpected canonical flag, want %v, got %v", i, test.expected.RequireCanonical, bnh.RequireCanonical)
+		}
+	}
+}
+
+func TestBlockNumberOrHashJSONRoundTrip(t *testing.T) {
+	tests := []BlockNumberOrHash{
+		BlockNumberOrHashWithNumber(0),
+		BlockNumberOrHashWithNumber(18),
+		BlockNumberOrHashWithNumber(LatestBlockNumber),
+		BlockNumberOrHashWithNumber(PendingBlockNumber),
+		BlockNumberOrHashWithHash(common.HexToHash("0x01"), false),
+		BlockNumberOrHashWithHash(common.HexToHash("0x01"), true),
+	}
+	for i, test := range tests {
+		blob, err := json.Marshal(test)
+		if err != nil {
+			t.Errorf("Test %d: marshal failed: %v", i, err)
+			continue
+		}
+		var bnh BlockNumberOrHash
+		if err := json.Unmarshal(blob, &bnh); err != nil {
+			t.Errorf("Test %d: unmarshal of %s failed: %v", i, blob, err)
+			continue
+		}
+		if bnh.String() != test.String() || bnh.RequireCanonical != test.RequireCanonical {
+			t.Errorf("Test %d: round trip of %s mismatch, want %v, got %v", i, blob, test, bnh)
+		}
+	}
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/signer/typeddata"
 )
 
 // numberOfAccountsToDerive For hardware wallets, the number of accounts to derive
@@ -49,6 +50,8 @@ type ExternalAPI interface {
 	SignTransaction(ctx context.Context, args SendTxArgs, methodSelector *string) (*ethapi.SignTransactionResult, error)
 	// Sign - request to sign the given data (plus prefix)
 	Sign(ctx context.Context, addr common.MixedcaseAddress, data hexutil.Bytes) (hexutil.Bytes, error)
+	// SignTypedData - request to sign the given EIP-712 typed data
+	SignTypedData(ctx context.Context, addr common.MixedcaseAddress, typedData typeddata.TypedData) (hexutil.Bytes, error)
 	// Export - request to export an account
 	Export(ctx context.Context, addr common.Address) (json.RawMessage, error)
 	// Import - request to import an account
@@ -170,11 +173,13 @@ type (
 		NewPassword string `json:"new_password"`
 	}
 	SignDataRequest struct {
-		Address common.MixedcaseAddress `json:"address"`
-		Rawdata hexutil.Bytes           `json:"raw_data"`
-		Message string                  `json:"message"`
-		Hash    hexutil.Bytes           `json:"hash"`
-		Meta    Metadata                `json:"meta"`
+		Address   common.MixedcaseAddress    `json:"address"`
+		Rawdata   hexutil.Bytes              `json:"raw_data"`
+		Message   string                     `json:"message"`
+		Hash      hexutil.Bytes              `json:"hash"`
+		TypedData *typeddata.TypedData       `json:"typed_data,omitempty"`
+		Messages  []*typeddata.NameValueType `json:"messages,omitempty"`
+		Meta      Metadata                   `json:"meta"`
 	}
 	SignDataResponse struct {
 		Approved bool `json:"approved"`
@@ -521,9 +526,33 @@ func (api *SignerAPI) SignTransaction(ctx context.Context, args SendTxArgs, meth
 // https://github.com/ethereum/go-ethereum/wiki/Management-APIs#personal_sign
 func (api *SignerAPI) Sign(ctx context.Context, addr common.MixedcaseAddress, data hexutil.Bytes) (hexutil.Bytes, error) {
 	sighash, msg := SignHash(data)
+	req := &SignDataRequest{Address: addr, Rawdata: data, Message: msg, Hash: sighash, Meta: MetadataFromContext(ctx)}
+	return api.signData(req)
+}
+
+// SignTypedData calculates an Ethereum ECDSA signature for the EIP-712 typed data:
+// keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message))
+//
+// The typed message is shown to the user in a human readable form for approval,
+// and is available to the rule engine as the typed_data field of the request.
+func (api *SignerAPI) SignTypedData(ctx context.Context, addr common.MixedcaseAddress, typedData typeddata.TypedData) (hexutil.Bytes, error) {
+	sighash, err := typedData.SignatureHash()
+	if err != nil {
+		return nil, err
+	}
+	messages, err := typedData.Format()
+	if err != nil {
+		return nil, err
+	}
+	req := &SignDataRequest{Address: addr, Hash: sighash, TypedData: &typedData, Messages: messages, Meta: MetadataFromContext(ctx)}
+	return api.signData(req)
+}
+
+// signData asks the UI to approve the signing request, and signs the hash of the
+// request if approved.
+func (api *SignerAPI) signData(req *SignDataRequest) (hexutil.Bytes, error) {
 	// We make the request prior to looking up if we actually have the account, to prevent
 	// account-enumeration via the API
-	req := &SignDataRequest{Address: addr, Rawdata: data, Message: msg, Hash: sighash, Meta: MetadataFromContext(ctx)}
 	res, err := api.UI.ApproveSignData(req)
 
 	if err != nil {
@@ -533,13 +562,13 @@ func (api *SignerAPI) Sign(ctx context.Context, addr common.MixedcaseAddress, da
 		return nil, ErrRequestDenied
 	}
 	// Look up the wallet containing the requested signer
-	account := accounts.Account{Address: addr.Address()}
+	account := accounts.Account{Address: req.Address.Address()}
 	wallet, err := api.am.Find(account)
 	if err != nil {
 		return nil, err
 	}
 	// Assemble sign the data with the wallet
-	signature, err := wallet.SignHashWithPassphrase(account, res.Password, sighash)
+	signature, err := wallet.SignHashWithPassphrase(account, res.Password, req.Hash)
 	if err != nil {
 		api.UI.ShowError(err.Error())
 		return nil, err
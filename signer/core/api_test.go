@@ -33,8 +33,10 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/signer/typeddata"
 )
 
 //Used for testing
@@ -284,6 +286,60 @@ func TestSignData(t *testing.T) {
 		t.Errorf("Expected 65 byte signature (got %d bytes)", len(h))
 	}
 }
+func TestSignTypedData(t *testing.T) {
+	api, control := setup(t)
+	createAccount(control, api, t)
+	control <- "A"
+	list, err := api.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := common.NewMixedcaseAddress(list[0])
+
+	typedData := typeddata.TypedData{
+		Types: typeddata.Types{
+			"EIP712Domain": {{Name: "name", Type: "string"}},
+			"Greeting":     {{Name: "text", Type: "string"}, {Name: "count", Type: "uint8"}},
+		},
+		PrimaryType: "Greeting",
+		Domain:      typeddata.TypedDataDomain{Name: "Test"},
+		Message:     typeddata.TypedDataMessage{"text": "EHLO world", "count": 1.0},
+	}
+	control <- "No way"
+	h, err := api.SignTypedData(context.Background(), a, typedData)
+	if h != nil {
+		t.Errorf("Expected nil-data, got %x", h)
+	}
+	if err != ErrRequestDenied {
+		t.Errorf("Expected ErrRequestDenied! %v", err)
+	}
+	control <- "Y"
+	control <- "a_long_password"
+	h, err = api.SignTypedData(context.Background(), a, typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h == nil || len(h) != 65 {
+		t.Fatalf("Expected 65 byte signature (got %d bytes)", len(h))
+	}
+	// Ensure the signature was made over the EIP-712 hash
+	sighash, _ := typedData.SignatureHash()
+	sig := common.CopyBytes(h)
+	sig[64] -= 27
+	pubkey, err := crypto.SigToPub(sighash, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr := crypto.PubkeyToAddress(*pubkey); addr != a.Address() {
+		t.Errorf("Signer mismatch: have %x, want %x", addr, a.Address())
+	}
+	// Invalid typed data must be rejected before reaching the UI
+	typedData.Message["count"] = 256.0
+	if _, err := api.SignTypedData(context.Background(), a, typedData); err == nil {
+		t.Errorf("Expected error for overflowing field")
+	}
+}
+
 func mkTestTx(from common.MixedcaseAddress) SendTxArgs {
 	to := common.NewMixedcaseAddress(common.HexToAddress("0x1337"))
 	gas := hexutil.Uint64(21000)
@@ -26,6 +26,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/signer/typeddata"
 )
 
 type AuditLogger struct {
@@ -71,6 +72,14 @@ func (l *AuditLogger) Sign(ctx context.Context, addr common.MixedcaseAddress, da
 	return b, e
 }
 
+func (l *AuditLogger) SignTypedData(ctx context.Context, addr common.MixedcaseAddress, typedData typeddata.TypedData) (hexutil.Bytes, error) {
+	l.log.Info("SignTypedData", "type", "request", "metadata", MetadataFromContext(ctx).String(),
+		"addr", addr.String(), "primaryType", typedData.PrimaryType, "domain", typedData.Domain.Name)
+	b, e := l.api.SignTypedData(ctx, addr, typedData)
+	l.log.Info("SignTypedData", "type", "response", "data", common.Bytes2Hex(b), "error", e)
+	return b, e
+}
+
 func (l *AuditLogger) Export(ctx context.Context, addr common.Address) (json.RawMessage, error) {
 	l.log.Info("Export", "type", "request", "metadata", MetadataFromContext(ctx).String(),
 		"addr", addr.Hex())
@@ -89,7 +98,9 @@ func (l *AuditLogger) Export(ctx context.Context, addr common.Address) (json.Raw
 //	return a, e
 //}
 
-func NewAuditLogger(path string, api ExternalAPI) (*AuditLogger, error) {
+// NewAuditLog creates a logger writing audit entries to the given file. It can
+// be shared by the audit logger and the rule engine to keep a single audit trail.
+func NewAuditLog(path string) (log.Logger, error) {
 	l := log.New("api", "signer")
 	handler, err := log.FileHandler(path, log.LogfmtFormat())
 	if err != nil {
@@ -97,5 +108,19 @@ func NewAuditLogger(path string, api ExternalAPI) (*AuditLogger, error) {
 	}
 	l.SetHandler(handler)
 	l.Info("Configured", "audit log", path)
-	return &AuditLogger{l, api}, nil
+	return l, nil
+}
+
+func NewAuditLogger(path string, api ExternalAPI) (*AuditLogger, error) {
+	l, err := NewAuditLog(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewAuditLoggerWithLog(l, api), nil
+}
+
+// NewAuditLoggerWithLog creates an audit logger writing into an already opened
+// audit log.
+func NewAuditLoggerWithLog(l log.Logger, api ExternalAPI) *AuditLogger {
+	return &AuditLogger{l, api}
 }
@@ -0,0 +1,558 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/signer/core"
+	"github.com/ethereum/go-ethereum/signer/storage"
+)
+
+// spendingWindow is the period over which the spending limits of accounts are
+// enforced.
+const spendingWindow = 24 * time.Hour
+
+// reservationTimeout is the time the cost of an approved transaction is held
+// against the spending limit of its sender while waiting for it to be signed.
+// Reservations of transactions failing to be signed expire after it.
+const reservationTimeout = 5 * time.Minute
+
+// weekdays maps the accepted day names of time windows to their weekday.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Policy is a set of declarative signing policies. Policies can only restrict
+// what is signed: requests passing them are still subject to the javascript
+// rules and manual approval.
+type Policy struct {
+	Default  *AccountPolicy                    `json:"default,omitempty"`  // Policy of accounts not listed explicitly
+	Accounts map[common.Address]*AccountPolicy `json:"accounts,omitempty"` // Policies of individual accounts
+}
+
+// AccountPolicy contains the restrictions on the requests of a single account.
+// Empty fields do not restrict anything.
+type AccountPolicy struct {
+	DailyLimit  *math.HexOrDecimal256 `json:"dailyLimit,omitempty"`  // Maximum wei spent (value and gas) within 24 hours
+	Recipients  []common.Address      `json:"recipients,omitempty"`  // Accounts transactions may be sent to
+	Methods     []string              `json:"methods,omitempty"`     // Contract methods that may be called, by signature or 4byte selector
+	TimeWindows []*TimeWindow         `json:"timeWindows,omitempty"` // Times of the week requests may be signed at
+}
+
+// TimeWindow is a recurring period of time during which requests may be signed.
+// Windows ending before their start wrap around midnight, windows ending at
+// their start span the whole day.
+type TimeWindow struct {
+	Days  []string `json:"days,omitempty"` // Days of the week the window applies to ("mon", "tue", ...), all if empty
+	Start string   `json:"start"`          // Start of the window in UTC ("15:04")
+	End   string   `json:"end"`            // End of the window in UTC ("15:04"), exclusive
+
+	days       map[time.Weekday]bool
+	start, end time.Duration
+}
+
+// LoadPolicy reads and validates the signing policies stored in the given file.
+func LoadPolicy(path string) (*Policy, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicy(blob)
+}
+
+// ParsePolicy parses and validates the JSON encoded signing policies.
+func ParsePolicy(blob []byte) (*Policy, error) {
+	policy := new(Policy)
+	if err := json.Unmarshal(blob, policy); err != nil {
+		return nil, err
+	}
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// validate checks the policy for sanity and prepares it for evaluation.
+func (p *Policy) validate() error {
+	if p.Default != nil {
+		if err := p.Default.validate(); err != nil {
+			return fmt.Errorf("default policy: %v", err)
+		}
+	}
+	for addr, policy := range p.Accounts {
+		if policy == nil {
+			return fmt.Errorf("account %s: empty policy", addr.Hex())
+		}
+		if err := policy.validate(); err != nil {
+			return fmt.Errorf("account %s: %v", addr.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// account returns the policy of the given account, or nil if unrestricted.
+func (p *Policy) account(addr common.Address) *AccountPolicy {
+	if policy, ok := p.Accounts[addr]; ok {
+		return policy
+	}
+	return p.Default
+}
+
+func (p *AccountPolicy) validate() error {
+	if p.DailyLimit != nil && (*big.Int)(p.DailyLimit).Sign() < 0 {
+		return errors.New("negative daily limit")
+	}
+	for _, method := range p.Methods {
+		if isSelector(method) {
+			continue
+		}
+		if _, err := core.MethodSelectorToAbi(method); err != nil {
+			return fmt.Errorf("invalid method %q: %v", method, err)
+		}
+	}
+	for i, window := range p.TimeWindows {
+		if window == nil {
+			return fmt.Errorf("time window %d: empty window", i)
+		}
+		if err := window.validate(); err != nil {
+			return fmt.Errorf("time window %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func (w *TimeWindow) validate() error {
+	var err error
+	if w.start, err = parseTimeOfDay(w.Start); err != nil {
+		return fmt.Errorf("invalid start: %v", err)
+	}
+	if w.end, err = parseTimeOfDay(w.End); err != nil {
+		return fmt.Errorf("invalid end: %v", err)
+	}
+	w.days = make(map[time.Weekday]bool)
+	for _, day := range w.Days {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return fmt.Errorf("invalid day %q", day)
+		}
+		w.days[weekday] = true
+	}
+	return nil
+}
+
+// contains returns whether the given time falls within the window. Windows
+// wrapping around midnight belong to the day they start on.
+func (w *TimeWindow) contains(t time.Time) bool {
+	t = t.UTC()
+	offset := t.Sub(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+
+	day := t.Weekday()
+	switch {
+	case w.start == w.end:
+	case w.start < w.end:
+		if offset < w.start || offset >= w.end {
+			return false
+		}
+	case offset >= w.start:
+	case offset < w.end:
+		day = (day + 6) % 7
+	default:
+		return false
+	}
+	return len(w.days) == 0 || w.days[day]
+}
+
+// parseTimeOfDay parses a "15:04" formatted time into an offset from midnight.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// isSelector returns whether a method of a policy is a raw 4byte selector
+// rather than a method signature.
+func isSelector(method string) bool {
+	if len(method) != 10 || !strings.HasPrefix(method, "0x") {
+		return false
+	}
+	_, err := hexutil.Decode(method)
+	return err == nil
+}
+
+// spending is a single amount spent by an account, tracked to enforce its
+// spending limit.
+type spending struct {
+	Time   int64        `json:"time"` // Unix time the spending was signed at
+	Amount *hexutil.Big `json:"amount"`
+}
+
+// reservationKey identifies an approved transaction waiting to be signed.
+type reservationKey struct {
+	from  common.Address
+	nonce uint64
+}
+
+// reservation is the cost of an approved transaction, held against the spending
+// limit of its sender until the transaction is signed.
+type reservation struct {
+	time   time.Time
+	amount *big.Int
+}
+
+// policyUI provides an implementation of SignerUI that evaluates the declarative
+// signing policies before handing the requests over to the next handler.
+type policyUI struct {
+	next   core.SignerUI // The next handler, e.g. the javascript rules
+	policy *Policy
+	db     *core.AbiDb     // 4byte database to resolve contract methods with
+	state  storage.Storage // Storage to persist the spendings of accounts in
+	audit  log.Logger      // Logger to record policy decisions in
+
+	reserved map[reservationKey]reservation // Costs of approved transactions not yet signed
+
+	now  func() time.Time // Source of the current time, overridable for tests
+	lock sync.Mutex       // Lock serialising spending limit checks and updates
+}
+
+// NewPolicyEvaluator creates a SignerUI evaluating the given policies, keeping
+// track of the spendings of accounts in state and recording all decisions in
+// the audit log. Rejected requests are never forwarded to the next handler.
+func NewPolicyEvaluator(next core.SignerUI, policy *Policy, db *core.AbiDb, state storage.Storage, audit log.Logger) (*policyUI, error) {
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	if audit == nil {
+		audit = log.Root()
+	}
+	return &policyUI{
+		next:     next,
+		policy:   policy,
+		db:       db,
+		state:    state,
+		audit:    audit,
+		reserved: make(map[reservationKey]reservation),
+		now:      time.Now,
+	}, nil
+}
+
+func (r *policyUI) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse, error) {
+	from := request.Transaction.From.Address()
+
+	policy := r.policy.account(from)
+	if policy == nil {
+		return r.next.ApproveTx(request)
+	}
+	// Reject requests violating the policy before bothering the next handler
+	if err := r.checkTx(policy, &request.Transaction); err != nil {
+		r.reject("ApproveTx", from, err)
+		return core.SignTxResponse{Approved: false}, err
+	}
+	response, err := r.next.ApproveTx(request)
+	if err != nil || !response.Approved {
+		return response, err
+	}
+	// The next handler may have modified the transaction, check it once more and
+	// reserve its cost until it's signed, atomically with other approvals
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if response.Transaction.From.Address() != from {
+		policy = r.policy.account(response.Transaction.From.Address())
+	}
+	if policy != nil {
+		key := reservationKey{response.Transaction.From.Address(), uint64(response.Transaction.Nonce)}
+		delete(r.reserved, key) // Superseded by this approval
+
+		if err := r.checkTx(policy, &response.Transaction); err != nil {
+			r.reject("ApproveTx", from, err)
+			return core.SignTxResponse{Approved: false}, err
+		}
+		if policy.DailyLimit != nil {
+			r.reserved[key] = reservation{time: r.now(), amount: txCost(&response.Transaction)}
+		}
+	}
+	r.audit.Info("Policy", "type", "decision", "request", "ApproveTx", "from", from.Hex(), "approved", true)
+	return response, nil
+}
+
+// checkTx checks the transaction against the policy of its sender.
+func (r *policyUI) checkTx(policy *AccountPolicy, tx *core.SendTxArgs) error {
+	if err := r.checkTime(policy); err != nil {
+		return err
+	}
+	if len(policy.Recipients) > 0 {
+		if tx.To == nil {
+			return errors.New("policy violation: contract creation not allowed")
+		}
+		allowed := false
+		for _, recipient := range policy.Recipients {
+			if recipient == tx.To.Address() {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("policy violation: recipient %s not allowed", tx.To.Address().Hex())
+		}
+	}
+	if len(policy.Methods) > 0 {
+		if err := r.checkMethod(policy, tx); err != nil {
+			return err
+		}
+	}
+	if policy.DailyLimit != nil {
+		limit := (*big.Int)(policy.DailyLimit)
+		total := new(big.Int).Add(r.spent(tx.From.Address()), r.reservedBy(tx.From.Address()))
+		total.Add(total, txCost(tx))
+		if total.Cmp(limit) > 0 {
+			return fmt.Errorf("policy violation: daily limit of %v wei exceeded", limit)
+		}
+	}
+	return nil
+}
+
+// checkMethod checks that the transaction either does not call a contract, or
+// calls one of the methods allowed by the policy.
+func (r *policyUI) checkMethod(policy *AccountPolicy, tx *core.SendTxArgs) error {
+	var data []byte
+	if tx.Data != nil {
+		data = *tx.Data
+	} else if tx.Input != nil {
+		data = *tx.Input
+	}
+	if tx.To == nil {
+		return errors.New("policy violation: contract creation not allowed")
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if len(data) < 4 {
+		return errors.New("policy violation: invalid contract call data")
+	}
+	selector := data[:4]
+
+	// Resolve the method via the 4byte database, making sure it actually matches
+	// the selector as the database may contain user submitted signatures
+	var signature string
+	if r.db != nil {
+		if sig, err := r.db.LookupMethodSelector(selector); err == nil && bytes.Equal(crypto.Keccak256([]byte(sig))[:4], selector) {
+			signature = sig
+		}
+	}
+	for _, method := range policy.Methods {
+		if isSelector(method) {
+			if bytes.Equal(hexutil.MustDecode(method), selector) {
+				return nil
+			}
+		} else if method == signature {
+			return nil
+		}
+	}
+	if signature == "" {
+		return fmt.Errorf("policy violation: unknown method %s not allowed", hexutil.Encode(selector))
+	}
+	return fmt.Errorf("policy violation: method %s not allowed", signature)
+}
+
+// checkTime checks that the current time is within the time windows of the
+// policy.
+func (r *policyUI) checkTime(policy *AccountPolicy) error {
+	if len(policy.TimeWindows) == 0 {
+		return nil
+	}
+	now := r.now()
+	for _, window := range policy.TimeWindows {
+		if window.contains(now) {
+			return nil
+		}
+	}
+	return fmt.Errorf("policy violation: signing not allowed at %v", now.UTC().Format(time.RFC1123))
+}
+
+// txCost returns the maximum amount of wei the transaction may spend.
+func txCost(tx *core.SendTxArgs) *big.Int {
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(uint64(tx.Gas)), tx.GasPrice.ToInt())
+	return cost.Add(cost, tx.Value.ToInt())
+}
+
+// spendingKey returns the storage key of the spendings of an account.
+func spendingKey(addr common.Address) string {
+	return "spendings:" + strings.ToLower(addr.Hex())
+}
+
+// spendings returns the spendings of the account within the spending window.
+func (r *policyUI) spendings(addr common.Address) []spending {
+	blob := r.state.Get(spendingKey(addr))
+	if blob == "" {
+		return nil
+	}
+	var all []spending
+	if err := json.Unmarshal([]byte(blob), &all); err != nil {
+		log.Warn("Failed to decode policy state", "addr", addr, "err", err)
+		return nil
+	}
+	cutoff := r.now().Add(-spendingWindow).Unix()
+
+	var recent []spending
+	for _, s := range all {
+		if s.Time > cutoff && s.Amount != nil {
+			recent = append(recent, s)
+		}
+	}
+	return recent
+}
+
+// spent returns the amount the account spent within the spending window.
+func (r *policyUI) spent(addr common.Address) *big.Int {
+	total := new(big.Int)
+	for _, s := range r.spendings(addr) {
+		total.Add(total, s.Amount.ToInt())
+	}
+	return total
+}
+
+// reservedBy returns the costs of the approved transactions of the account still
+// waiting to be signed, dropping any expired reservations.
+func (r *policyUI) reservedBy(addr common.Address) *big.Int {
+	cutoff := r.now().Add(-reservationTimeout)
+
+	total := new(big.Int)
+	for key, res := range r.reserved {
+		if res.time.Before(cutoff) {
+			delete(r.reserved, key)
+			continue
+		}
+		if key.from == addr {
+			total.Add(total, res.amount)
+		}
+	}
+	return total
+}
+
+// record converts the reservation of a signed transaction into a spending of its
+// sender.
+func (r *policyUI) record(tx *types.Transaction) {
+	var signer types.Signer = types.HomesteadSigner{}
+	if tx.Protected() {
+		signer = types.NewEIP155Signer(tx.ChainId())
+	}
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		log.Warn("Failed to recover sender of signed transaction", "hash", tx.Hash(), "err", err)
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := reservationKey{from, tx.Nonce()}
+	if _, ok := r.reserved[key]; !ok {
+		return // Not subject to a spending limit
+	}
+	delete(r.reserved, key)
+	r.spend(from, tx.Cost())
+}
+
+// spend records the amount as spent by the account, dropping any spendings out
+// of the spending window.
+func (r *policyUI) spend(addr common.Address, amount *big.Int) {
+	spendings := append(r.spendings(addr), spending{
+		Time:   r.now().Unix(),
+		Amount: (*hexutil.Big)(amount),
+	})
+	blob, err := json.Marshal(spendings)
+	if err != nil {
+		log.Warn("Failed to encode policy state", "addr", addr, "err", err)
+		return
+	}
+	r.state.Put(spendingKey(addr), string(blob))
+	r.audit.Info("Policy", "type", "spending", "from", addr.Hex(), "amount", amount, "spent", r.spent(addr))
+}
+
+// reject records a request rejected by the policies.
+func (r *policyUI) reject(request string, addr common.Address, err error) {
+	log.Info("Request rejected by policy", "request", request, "addr", addr, "reason", err)
+	r.audit.Info("Policy", "type", "decision", "request", request, "from", addr.Hex(), "approved", false, "reason", err)
+}
+
+func (r *policyUI) ApproveSignData(request *core.SignDataRequest) (core.SignDataResponse, error) {
+	addr := request.Address.Address()
+	if policy := r.policy.account(addr); policy != nil {
+		if err := r.checkTime(policy); err != nil {
+			r.reject("ApproveSignData", addr, err)
+			return core.SignDataResponse{Approved: false}, err
+		}
+	}
+	return r.next.ApproveSignData(request)
+}
+
+func (r *policyUI) ApproveExport(request *core.ExportRequest) (core.ExportResponse, error) {
+	return r.next.ApproveExport(request)
+}
+
+func (r *policyUI) ApproveImport(request *core.ImportRequest) (core.ImportResponse, error) {
+	return r.next.ApproveImport(request)
+}
+
+func (r *policyUI) ApproveListing(request *core.ListRequest) (core.ListResponse, error) {
+	return r.next.ApproveListing(request)
+}
+
+func (r *policyUI) ApproveNewAccount(request *core.NewAccountRequest) (core.NewAccountResponse, error) {
+	return r.next.ApproveNewAccount(request)
+}
+
+func (r *policyUI) ShowError(message string) {
+	r.next.ShowError(message)
+}
+
+func (r *policyUI) ShowInfo(message string) {
+	r.next.ShowInfo(message)
+}
+
+func (r *policyUI) OnApprovedTx(tx ethapi.SignTransactionResult) {
+	if tx.Tx != nil {
+		r.record(tx.Tx)
+	}
+	r.next.OnApprovedTx(tx)
+}
+
+func (r *policyUI) OnSignerStartup(info core.StartupInfo) {
+	r.next.OnSignerStartup(info)
+}
+
+func (r *policyUI) OnInputRequired(info core.UserInputRequest) (core.UserInputResponse, error) {
+	return r.next.OnInputRequired(info)
+}
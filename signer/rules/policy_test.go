@@ -0,0 +1,285 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/signer/core"
+	"github.com/ethereum/go-ethereum/signer/storage"
+)
+
+// approvingUI is a next-handler approving all transactions unmodified.
+type approvingUI struct {
+	dummyUI
+}
+
+func (a *approvingUI) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse, error) {
+	a.calls = append(a.calls, "ApproveTx")
+	return core.SignTxResponse{Transaction: request.Transaction, Approved: true}, nil
+}
+
+func parsePolicy(t *testing.T, spec string) *Policy {
+	policy := new(Policy)
+	if err := json.Unmarshal([]byte(spec), policy); err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	return policy
+}
+
+func newPolicyEngine(t *testing.T, next core.SignerUI, spec string, db *core.AbiDb, state storage.Storage, now *time.Time) *policyUI {
+	r, err := NewPolicyEvaluator(next, parsePolicy(t, spec), db, state, nil)
+	if err != nil {
+		t.Fatalf("failed to create policy engine: %v", err)
+	}
+	r.now = func() time.Time { return *now }
+	return r
+}
+
+// policyKey is the account whose spendings are limited by the policy tests.
+var policyKey, _ = crypto.ToECDSA(crypto.Keccak256([]byte("policy")))
+
+// limitedTx creates a transfer request of the limited test account.
+func limitedTx(value hexutil.Big, nonce uint64) *core.SignTxRequest {
+	req := dummyTx(value)
+	req.Transaction.From = common.NewMixedcaseAddress(crypto.PubkeyToAddress(policyKey.PublicKey))
+	req.Transaction.Nonce = hexutil.Uint64(nonce)
+	return req
+}
+
+// approveAndSign requests approval of the transaction and, if approved, signs it
+// and reports it as such.
+func approveAndSign(r *policyUI, req *core.SignTxRequest) error {
+	resp, err := r.ApproveTx(req)
+	if err != nil {
+		return err
+	}
+	if !resp.Approved {
+		return errors.New("request denied")
+	}
+	args := resp.Transaction
+	tx := types.NewTransaction(uint64(args.Nonce), args.To.Address(), args.Value.ToInt(), uint64(args.Gas), args.GasPrice.ToInt(), nil)
+	signed, err := types.SignTx(tx, types.NewEIP155Signer(big.NewInt(1)), policyKey)
+	if err != nil {
+		return err
+	}
+	r.OnApprovedTx(ethapi.SignTransactionResult{Tx: signed})
+	return nil
+}
+
+// Tests that the daily spending limit covers value and gas over 24 hours, and
+// that the spendings survive restarts.
+func TestPolicyDailyLimit(t *testing.T) {
+	// dummyTx spends 21000 * 2000000 wei for gas, allow three 0.3 ether transfers
+	spec := fmt.Sprintf(`{"accounts": {"%s": {"dailyLimit": "1000000000000000000"}}}`, crypto.PubkeyToAddress(policyKey.PublicKey).Hex())
+
+	now := time.Date(2019, 1, 7, 12, 0, 0, 0, time.UTC)
+	state := storage.NewEphemeralStorage()
+	r := newPolicyEngine(t, &approvingUI{}, spec, nil, state, &now)
+
+	value := hexutil.Big(*big.NewInt(300000000000000000))
+	for i := 0; i < 3; i++ {
+		if err := approveAndSign(r, limitedTx(value, uint64(i))); err != nil {
+			t.Fatalf("transaction %d: rejected: %v", i, err)
+		}
+		now = now.Add(time.Hour)
+	}
+	if err := approveAndSign(r, limitedTx(value, 3)); err == nil {
+		t.Fatalf("transaction over the daily limit approved")
+	}
+	// Restart the engine, the limit should still be reached
+	r = newPolicyEngine(t, &approvingUI{}, spec, nil, state, &now)
+	if err := approveAndSign(r, limitedTx(value, 3)); err == nil {
+		t.Fatalf("transaction over the daily limit approved after restart")
+	}
+	// Once the first spending leaves the window, another transfer fits
+	now = now.Add(21*time.Hour + time.Second)
+	if err := approveAndSign(r, limitedTx(value, 3)); err != nil {
+		t.Fatalf("transaction within the daily limit rejected: %v", err)
+	}
+	if err := approveAndSign(r, limitedTx(value, 4)); err == nil {
+		t.Fatalf("transaction over the daily limit approved")
+	}
+}
+
+// Tests that approved transactions count against the spending limit while they
+// are being signed, but are only recorded as spent once signed.
+func TestPolicyReservations(t *testing.T) {
+	spec := fmt.Sprintf(`{"accounts": {"%s": {"dailyLimit": "1000000000000000000"}}}`, crypto.PubkeyToAddress(policyKey.PublicKey).Hex())
+
+	now := time.Date(2019, 1, 7, 12, 0, 0, 0, time.UTC)
+	state := storage.NewEphemeralStorage()
+	r := newPolicyEngine(t, &approvingUI{}, spec, nil, state, &now)
+
+	// Approving the same transaction again must not count it twice
+	value := hexutil.Big(*big.NewInt(300000000000000000))
+	for i := 0; i < 4; i++ {
+		if resp, err := r.ApproveTx(limitedTx(value, 0)); err != nil || !resp.Approved {
+			t.Fatalf("approval %d of the same transaction rejected: %v", i, err)
+		}
+	}
+	// Pending transactions must count against the limit
+	for i := 1; i < 3; i++ {
+		if resp, err := r.ApproveTx(limitedTx(value, uint64(i))); err != nil || !resp.Approved {
+			t.Fatalf("transaction %d: rejected: %v", i, err)
+		}
+	}
+	if resp, err := r.ApproveTx(limitedTx(value, 3)); err == nil || resp.Approved {
+		t.Fatalf("transaction over the daily limit approved")
+	}
+	// Nothing was signed, so nothing must have been spent
+	if blob := state.Get(spendingKey(crypto.PubkeyToAddress(policyKey.PublicKey))); blob != "" {
+		t.Fatalf("unsigned transactions recorded as spent: %s", blob)
+	}
+	// Reservations of transactions never signed must expire
+	now = now.Add(reservationTimeout + time.Second)
+	for i := 0; i < 3; i++ {
+		if err := approveAndSign(r, limitedTx(value, uint64(i))); err != nil {
+			t.Fatalf("transaction %d: rejected after reservations expired: %v", i, err)
+		}
+	}
+	if err := approveAndSign(r, limitedTx(value, 3)); err == nil {
+		t.Fatalf("transaction over the daily limit approved")
+	}
+}
+
+// Tests that transactions to recipients or contract methods not allowed by the
+// policy are rejected without consulting the next handler.
+func TestPolicyRecipientsAndMethods(t *testing.T) {
+	spec := `{"default": {
+		"recipients": ["0x000000000000000000000000000000000000dead"],
+		"methods": ["transfer(address,uint256)", "0x095ea7b3"]
+	}}`
+	db, _ := core.NewEmptyAbiDB()
+	db.AddSignature("transfer(address,uint256)", hexutil.MustDecode("0xa9059cbb"))
+	db.AddSignature("forged(uint256)", hexutil.MustDecode("0x12345678"))
+
+	now := time.Now()
+	call := func(data string) *core.SignTxRequest {
+		req := dummyTx(hexutil.Big{})
+		input := hexutil.Bytes(hexutil.MustDecode(data))
+		req.Transaction.Data = &input
+		return req
+	}
+	other := dummyTx(hexutil.Big{})
+	to := common.NewMixedcaseAddress(common.HexToAddress("0x000000000000000000000000000000000000beef"))
+	other.Transaction.To = &to
+
+	creation := dummyTx(hexutil.Big{})
+	creation.Transaction.To = nil
+
+	tests := []struct {
+		request  *core.SignTxRequest
+		approved bool
+	}{
+		{dummyTx(hexutil.Big{}), true},
+		{other, false},
+		{creation, false},
+		{call("0xa9059cbb" + "00000000000000000000000000000000000000000000000000000000000000aa" + "0000000000000000000000000000000000000000000000000000000000000001"), true},
+		{call("0x095ea7b3"), true},
+		{call("0x23b872dd"), false}, // transferFrom, unknown to the database
+		{call("0x12345678"), false}, // forged signature in the database
+		{call("0x1234"), false},
+	}
+	for i, tt := range tests {
+		var next core.SignerUI = &dontCallMe{t}
+		if tt.approved {
+			next = &approvingUI{}
+		}
+		r := newPolicyEngine(t, next, spec, db, storage.NewEphemeralStorage(), &now)
+		resp, err := r.ApproveTx(tt.request)
+		if resp.Approved != tt.approved {
+			t.Errorf("test %d: approval mismatch: have %v (%v), want %v", i, resp.Approved, err, tt.approved)
+		}
+	}
+	// Accounts with a policy of their own are not subject to the default one
+	spec = `{
+		"default": {"recipients": ["0x000000000000000000000000000000000000beef"]},
+		"accounts": {"0x000000000000000000000000000000000000dead": {}}
+	}`
+	r := newPolicyEngine(t, &approvingUI{}, spec, db, storage.NewEphemeralStorage(), &now)
+	if resp, err := r.ApproveTx(dummyTx(hexutil.Big{})); err != nil || !resp.Approved {
+		t.Errorf("account policy overridden by default policy: %v", err)
+	}
+}
+
+// Tests that time windows are evaluated in UTC, including the ones wrapping
+// around midnight.
+func TestPolicyTimeWindows(t *testing.T) {
+	tests := []struct {
+		window *TimeWindow
+		time   time.Time
+		within bool
+	}{
+		{&TimeWindow{Start: "09:00", End: "17:00"}, time.Date(2019, 1, 7, 9, 0, 0, 0, time.UTC), true},
+		{&TimeWindow{Start: "09:00", End: "17:00"}, time.Date(2019, 1, 7, 17, 0, 0, 0, time.UTC), false},
+		{&TimeWindow{Start: "09:00", End: "17:00"}, time.Date(2019, 1, 7, 8, 59, 0, 0, time.UTC), false},
+		{&TimeWindow{Start: "09:00", End: "17:00"}, time.Date(2019, 1, 7, 10, 0, 0, 0, time.FixedZone("CET", 3600)), true},
+		{&TimeWindow{Start: "09:00", End: "17:00", Days: []string{"Mon"}}, time.Date(2019, 1, 7, 12, 0, 0, 0, time.UTC), true},
+		{&TimeWindow{Start: "09:00", End: "17:00", Days: []string{"Tue"}}, time.Date(2019, 1, 7, 12, 0, 0, 0, time.UTC), false},
+		{&TimeWindow{Start: "22:00", End: "06:00", Days: []string{"sun"}}, time.Date(2019, 1, 7, 5, 0, 0, 0, time.UTC), true},
+		{&TimeWindow{Start: "22:00", End: "06:00", Days: []string{"mon"}}, time.Date(2019, 1, 7, 5, 0, 0, 0, time.UTC), false},
+		{&TimeWindow{Start: "22:00", End: "06:00"}, time.Date(2019, 1, 7, 12, 0, 0, 0, time.UTC), false},
+		{&TimeWindow{Start: "00:00", End: "00:00", Days: []string{"mon"}}, time.Date(2019, 1, 7, 23, 59, 0, 0, time.UTC), true},
+	}
+	for i, tt := range tests {
+		if err := tt.window.validate(); err != nil {
+			t.Fatalf("test %d: invalid window: %v", i, err)
+		}
+		if within := tt.window.contains(tt.time); within != tt.within {
+			t.Errorf("test %d: containment mismatch: have %v, want %v", i, within, tt.within)
+		}
+	}
+	// Requests outside of the windows are rejected
+	spec := `{"default": {"timeWindows": [{"start": "09:00", "end": "17:00"}]}}`
+	now := time.Date(2019, 1, 7, 18, 0, 0, 0, time.UTC)
+
+	r := newPolicyEngine(t, &dontCallMe{t}, spec, nil, storage.NewEphemeralStorage(), &now)
+	if resp, err := r.ApproveTx(dummyTx(hexutil.Big{})); err == nil || resp.Approved {
+		t.Errorf("transaction outside of time window approved")
+	}
+	from, _ := mixAddr("0x000000000000000000000000000000000000dead")
+	if resp, err := r.ApproveSignData(&core.SignDataRequest{Address: *from}); err == nil || resp.Approved {
+		t.Errorf("data signing outside of time window approved")
+	}
+}
+
+// Tests that invalid policies are rejected.
+func TestPolicyValidation(t *testing.T) {
+	specs := []string{
+		`{"default": {"dailyLimit": "-1"}}`,
+		`{"default": {"methods": ["transfer"]}}`,
+		`{"default": {"timeWindows": [{"start": "9am", "end": "17:00"}]}}`,
+		`{"default": {"timeWindows": [{"start": "09:00", "end": "17:00", "days": ["monday"]}]}}`,
+		`{"accounts": {"0x000000000000000000000000000000000000dead": null}}`,
+	}
+	for i, spec := range specs {
+		if _, err := ParsePolicy([]byte(spec)); err == nil {
+			t.Errorf("test %d: invalid policy accepted", i)
+		}
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/signer/core"
 	"github.com/ethereum/go-ethereum/signer/storage"
+	"github.com/ethereum/go-ethereum/signer/typeddata"
 )
 
 const JS = `
@@ -656,3 +657,58 @@ function ApproveSignData(r){
 		t.Fatalf("Expected approved")
 	}
 }
+
+func TestSignTypedData(t *testing.T) {
+
+	js := `function ApproveSignData(r){
+    if(r.typed_data && r.typed_data.primaryType == "Mail" && r.typed_data.domain.name == "Ether Mail")
+    {
+        if(r.typed_data.message.to.name == "Bob"){
+            return "Approve"
+        }
+        return "Reject"
+    }
+    // Otherwise goes to manual processing
+}`
+	r, err := initRuleEngine(js)
+	if err != nil {
+		t.Errorf("Couldn't create evaluator %v", err)
+		return
+	}
+	addr, _ := mixAddr("0x694267f14675d7e1b9494fd8d72fefe1755710fa")
+
+	mail := func(to string) *typeddata.TypedData {
+		return &typeddata.TypedData{
+			Types: typeddata.Types{
+				"EIP712Domain": {{Name: "name", Type: "string"}},
+				"Person":       {{Name: "name", Type: "string"}},
+				"Mail":         {{Name: "to", Type: "Person"}, {Name: "contents", Type: "string"}},
+			},
+			PrimaryType: "Mail",
+			Domain:      typeddata.TypedDataDomain{Name: "Ether Mail"},
+			Message: typeddata.TypedDataMessage{
+				"to":       map[string]interface{}{"name": to},
+				"contents": "Hello!",
+			},
+		}
+	}
+	for _, test := range []struct {
+		to       string
+		approved bool
+	}{{"Bob", true}, {"Eve", false}} {
+		typed := mail(test.to)
+		hash, _ := typed.SignatureHash()
+		resp, err := r.ApproveSignData(&core.SignDataRequest{
+			Address:   *addr,
+			Hash:      hash,
+			TypedData: typed,
+			Meta:      core.Metadata{Remote: "remoteip", Local: "localip", Scheme: "inproc"},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if resp.Approved != test.approved {
+			t.Errorf("Mail to %s: approval mismatch: have %v, want %v", test.to, resp.Approved, test.approved)
+		}
+	}
+}
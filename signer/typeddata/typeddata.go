@@ -0,0 +1,619 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package typeddata implements the EIP-712 encoding and hashing of typed
+// structured data, as signed by the clef signer and the node's eth API.
+//
+// https://eips.ethereum.org/EIPS/eip-712
+package typeddata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// maxDepth is the maximum nesting of structs and arrays accepted within a typed
+// message, protecting the signer against maliciously deep (or cyclic) data.
+const maxDepth = 32
+
+// maxSafeInteger is the largest integer a float64 represents exactly, so that
+// every integer up to it is distinguishable from its neighbours.
+const maxSafeInteger = 1<<53 - 1
+
+// domainType is the name of the type describing the signing domain.
+const domainType = "EIP712Domain"
+
+var (
+	// arrayRegexp matches an array type, capturing its element type and length.
+	arrayRegexp = regexp.MustCompile(`^(.+)\[([0-9]*)\]$`)
+
+	// identifierRegexp matches the valid names of struct types and fields.
+	identifierRegexp = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z_$0-9]*$`)
+
+	// intRegexp matches the signed and unsigned integer types.
+	intRegexp = regexp.MustCompile(`^(u?)int([0-9]+)$`)
+
+	// bytesRegexp matches the fixed size byte array types.
+	bytesRegexp = regexp.MustCompile(`^bytes([0-9]+)$`)
+
+	errTooDeep = errors.New("typed data nested too deep")
+)
+
+// Type is a single named and typed field of a struct type.
+type Type struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Types maps the names of the struct types to their fields.
+type Types map[string][]Type
+
+// TypedDataMessage is the untyped JSON representation of a struct instance.
+type TypedDataMessage map[string]interface{}
+
+// UnmarshalJSON decodes the message keeping JSON numbers as json.Number, so
+// that integers above 2^53 are not rounded by a conversion to float64.
+func (msg *TypedDataMessage) UnmarshalJSON(input []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(input))
+	dec.UseNumber()
+
+	var data map[string]interface{}
+	if err := dec.Decode(&data); err != nil {
+		return err
+	}
+	*msg = data
+	return nil
+}
+
+// TypedDataDomain holds the fields of the EIP712Domain separating the messages
+// of different applications and chains. Unset fields are omitted from the domain.
+type TypedDataDomain struct {
+	Name              string                `json:"name"`
+	Version           string                `json:"version"`
+	ChainId           *math.HexOrDecimal256 `json:"chainId"`
+	VerifyingContract string                `json:"verifyingContract"`
+	Salt              string                `json:"salt"`
+}
+
+// TypedData is a typed structured message along with its type definitions and
+// signing domain, as defined by EIP-712.
+type TypedData struct {
+	Types       Types            `json:"types"`
+	PrimaryType string           `json:"primaryType"`
+	Domain      TypedDataDomain  `json:"domain"`
+	Message     TypedDataMessage `json:"message"`
+}
+
+// NameValueType is a human readable rendering of a typed value, used to show the
+// contents of a typed message to the user before signing it.
+type NameValueType struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+	Typ   string      `json:"type"`
+}
+
+// Pprint returns a pretty-printed, indented version of the value.
+func (nvt *NameValueType) Pprint(depth int) string {
+	var output bytes.Buffer
+	output.WriteString(strings.Repeat("  ", depth))
+	output.WriteString(fmt.Sprintf("%s [%s]: ", nvt.Name, nvt.Typ))
+	if nvts, ok := nvt.Value.([]*NameValueType); ok {
+		output.WriteString("\n")
+		for _, next := range nvts {
+			output.WriteString(next.Pprint(depth + 1))
+		}
+	} else {
+		output.WriteString(fmt.Sprintf("%v\n", nvt.Value))
+	}
+	return output.String()
+}
+
+// UnmarshalJSON decodes the domain, accepting the chain id both as a JSON number
+// and as a decimal or hex string.
+func (domain *TypedDataDomain) UnmarshalJSON(input []byte) error {
+	var dec struct {
+		Name              string          `json:"name"`
+		Version           string          `json:"version"`
+		ChainId           json.RawMessage `json:"chainId"`
+		VerifyingContract string          `json:"verifyingContract"`
+		Salt              string          `json:"salt"`
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	domain.Name, domain.Version = dec.Name, dec.Version
+	domain.VerifyingContract, domain.Salt = dec.VerifyingContract, dec.Salt
+
+	domain.ChainId = nil
+	if len(dec.ChainId) > 0 && string(dec.ChainId) != "null" {
+		chainID := new(math.HexOrDecimal256)
+		if err := chainID.UnmarshalText(bytes.Trim(dec.ChainId, `"`)); err != nil {
+			return fmt.Errorf("invalid chainId: %v", err)
+		}
+		domain.ChainId = chainID
+	}
+	return nil
+}
+
+// Map returns the domain as an untyped message, omitting the unset fields.
+func (domain *TypedDataDomain) Map() TypedDataMessage {
+	data := TypedDataMessage{}
+	if domain.Name != "" {
+		data["name"] = domain.Name
+	}
+	if domain.Version != "" {
+		data["version"] = domain.Version
+	}
+	if domain.ChainId != nil {
+		data["chainId"] = (*big.Int)(domain.ChainId)
+	}
+	if domain.VerifyingContract != "" {
+		data["verifyingContract"] = domain.VerifyingContract
+	}
+	if domain.Salt != "" {
+		data["salt"] = domain.Salt
+	}
+	return data
+}
+
+// Validate checks that the type definitions are well formed and that both the
+// primary type and the domain type are defined.
+func (typedData *TypedData) Validate() error {
+	if _, ok := typedData.Types[domainType]; !ok {
+		return fmt.Errorf("missing %s type definition", domainType)
+	}
+	if _, ok := typedData.Types[typedData.PrimaryType]; !ok {
+		return fmt.Errorf("primary type %q is not defined", typedData.PrimaryType)
+	}
+	for name, fields := range typedData.Types {
+		if !identifierRegexp.MatchString(name) {
+			return fmt.Errorf("invalid type name %q", name)
+		}
+		for _, field := range fields {
+			if !identifierRegexp.MatchString(field.Name) {
+				return fmt.Errorf("invalid field name %q in type %s", field.Name, name)
+			}
+			if !typedData.validType(field.Type) {
+				return fmt.Errorf("invalid type %q of field %s.%s", field.Type, name, field.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// validType reports whether the type is a primitive, a defined struct or an
+// array of valid types.
+func (typedData *TypedData) validType(typ string) bool {
+	if elem, _, ok := parseArray(typ); ok {
+		return typedData.validType(elem)
+	}
+	if _, ok := typedData.Types[typ]; ok {
+		return true
+	}
+	return isPrimitive(typ)
+}
+
+// SignatureHash returns the hash to sign for the typed data, calculated as
+// keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message)).
+func (typedData *TypedData) SignatureHash() ([]byte, error) {
+	if err := typedData.Validate(); err != nil {
+		return nil, err
+	}
+	domainSeparator, err := typedData.HashStruct(domainType, typedData.Domain.Map())
+	if err != nil {
+		return nil, fmt.Errorf("invalid domain: %v", err)
+	}
+	messageHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message: %v", err)
+	}
+	rawData := make([]byte, 0, 66)
+	rawData = append(rawData, 0x19, 0x01)
+	rawData = append(rawData, domainSeparator...)
+	rawData = append(rawData, messageHash...)
+	return crypto.Keccak256(rawData), nil
+}
+
+// HashStruct returns the hash of a struct instance of the given type.
+func (typedData *TypedData) HashStruct(primaryType string, data TypedDataMessage) ([]byte, error) {
+	return typedData.hashStruct(primaryType, data, 0)
+}
+
+func (typedData *TypedData) hashStruct(primaryType string, data TypedDataMessage, depth int) ([]byte, error) {
+	encoded, err := typedData.encodeData(primaryType, data, depth)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(encoded), nil
+}
+
+// TypeHash returns the hash of the encoded type of the given struct type.
+func (typedData *TypedData) TypeHash(primaryType string) []byte {
+	return crypto.Keccak256(typedData.EncodeType(primaryType))
+}
+
+// EncodeType returns the encoding of a struct type, formed by its own signature
+// followed by the signatures of all referenced struct types in alphabetic order,
+// e.g. "Mail(Person from,Person to,string contents)Person(string name,address wallet)".
+func (typedData *TypedData) EncodeType(primaryType string) []byte {
+	deps := typedData.dependencies(primaryType, make(map[string]bool))
+	sort.Strings(deps)
+
+	var buffer bytes.Buffer
+	for _, dep := range append([]string{primaryType}, deps...) {
+		buffer.WriteString(dep)
+		buffer.WriteString("(")
+		for i, field := range typedData.Types[dep] {
+			if i > 0 {
+				buffer.WriteString(",")
+			}
+			buffer.WriteString(field.Type)
+			buffer.WriteString(" ")
+			buffer.WriteString(field.Name)
+		}
+		buffer.WriteString(")")
+	}
+	return buffer.Bytes()
+}
+
+// dependencies returns the struct types referenced by the given type, directly
+// or indirectly, excluding the type itself.
+func (typedData *TypedData) dependencies(primaryType string, found map[string]bool) []string {
+	found[primaryType] = true
+
+	var deps []string
+	for _, field := range typedData.Types[primaryType] {
+		typ := baseType(field.Type)
+		if _, ok := typedData.Types[typ]; !ok || found[typ] {
+			continue
+		}
+		deps = append(deps, typ)
+		deps = append(deps, typedData.dependencies(typ, found)...)
+	}
+	return deps
+}
+
+// encodeData returns the encoding of a struct instance: the type hash followed
+// by the 32 byte encoding of each field value.
+func (typedData *TypedData) encodeData(primaryType string, data TypedDataMessage, depth int) ([]byte, error) {
+	if depth > maxDepth {
+		return nil, errTooDeep
+	}
+	fields := typedData.Types[primaryType]
+	if len(data) > len(fields) {
+		return nil, fmt.Errorf("%s has more fields than defined (%d > %d)", primaryType, len(data), len(fields))
+	}
+	var buffer bytes.Buffer
+	buffer.Write(typedData.TypeHash(primaryType))
+
+	for _, field := range fields {
+		value, ok := data[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing field %s.%s", primaryType, field.Name)
+		}
+		encoded, err := typedData.encodeValue(field.Type, value, depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("field %s.%s: %v", primaryType, field.Name, err)
+		}
+		buffer.Write(encoded)
+	}
+	return buffer.Bytes(), nil
+}
+
+// encodeValue returns the 32 byte encoding of a value of the given type. Structs
+// are encoded by their hash, arrays by the hash of their concatenated items and
+// dynamic primitives by the hash of their contents.
+func (typedData *TypedData) encodeValue(typ string, value interface{}, depth int) ([]byte, error) {
+	if depth > maxDepth {
+		return nil, errTooDeep
+	}
+	if elem, size, ok := parseArray(typ); ok {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid value %v for array type %s", value, typ)
+		}
+		if size >= 0 && len(items) != size {
+			return nil, fmt.Errorf("invalid length %d for array type %s", len(items), typ)
+		}
+		var buffer bytes.Buffer
+		for i, item := range items {
+			encoded, err := typedData.encodeValue(elem, item, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %v", i, err)
+			}
+			buffer.Write(encoded)
+		}
+		return crypto.Keccak256(buffer.Bytes()), nil
+	}
+	if _, ok := typedData.Types[typ]; ok {
+		data, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid value %v for struct type %s", value, typ)
+		}
+		return typedData.hashStruct(typ, data, depth+1)
+	}
+	return encodePrimitive(typ, value)
+}
+
+// Format returns a human readable rendering of the domain and the message, for
+// the user to inspect before approving the signature.
+func (typedData *TypedData) Format() ([]*NameValueType, error) {
+	domain, err := typedData.formatData(domainType, typedData.Domain.Map(), 0)
+	if err != nil {
+		return nil, err
+	}
+	message, err := typedData.formatData(typedData.PrimaryType, typedData.Message, 0)
+	if err != nil {
+		return nil, err
+	}
+	return []*NameValueType{
+		{Name: domainType, Value: domain, Typ: "domain"},
+		{Name: typedData.PrimaryType, Value: message, Typ: "primary type"},
+	}, nil
+}
+
+func (typedData *TypedData) formatData(primaryType string, data TypedDataMessage, depth int) ([]*NameValueType, error) {
+	if depth > maxDepth {
+		return nil, errTooDeep
+	}
+	var output []*NameValueType
+	for _, field := range typedData.Types[primaryType] {
+		value, err := typedData.formatValue(field.Type, data[field.Name], depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("field %s.%s: %v", primaryType, field.Name, err)
+		}
+		output = append(output, &NameValueType{Name: field.Name, Value: value, Typ: field.Type})
+	}
+	return output, nil
+}
+
+func (typedData *TypedData) formatValue(typ string, value interface{}, depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errTooDeep
+	}
+	if elem, _, ok := parseArray(typ); ok {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid value %v for array type %s", value, typ)
+		}
+		output := make([]*NameValueType, len(items))
+		for i, item := range items {
+			formatted, err := typedData.formatValue(elem, item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			output[i] = &NameValueType{Name: fmt.Sprintf("[%d]", i), Value: formatted, Typ: elem}
+		}
+		return output, nil
+	}
+	if _, ok := typedData.Types[typ]; ok {
+		data, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid value %v for struct type %s", value, typ)
+		}
+		return typedData.formatData(typ, data, depth+1)
+	}
+	return formatPrimitive(typ, value)
+}
+
+// parseArray splits an array type into its element type and length. The length
+// is -1 for dynamically sized arrays.
+func parseArray(typ string) (string, int, bool) {
+	match := arrayRegexp.FindStringSubmatch(typ)
+	if match == nil {
+		return "", 0, false
+	}
+	if match[2] == "" {
+		return match[1], -1, true
+	}
+	size, err := strconv.Atoi(match[2])
+	if err != nil {
+		return "", 0, false
+	}
+	return match[1], size, true
+}
+
+// baseType strips all array dimensions from a type.
+func baseType(typ string) string {
+	for {
+		elem, _, ok := parseArray(typ)
+		if !ok {
+			return typ
+		}
+		typ = elem
+	}
+}
+
+// isPrimitive reports whether the type is one of the atomic or dynamic
+// primitive types.
+func isPrimitive(typ string) bool {
+	switch typ {
+	case "address", "bool", "string", "bytes":
+		return true
+	}
+	if match := intRegexp.FindStringSubmatch(typ); match != nil {
+		bits, err := strconv.Atoi(match[2])
+		return err == nil && bits > 0 && bits <= 256 && bits%8 == 0
+	}
+	if match := bytesRegexp.FindStringSubmatch(typ); match != nil {
+		size, err := strconv.Atoi(match[1])
+		return err == nil && size > 0 && size <= 32
+	}
+	return false
+}
+
+// encodePrimitive returns the 32 byte encoding of a primitive value.
+func encodePrimitive(typ string, value interface{}) ([]byte, error) {
+	switch typ {
+	case "address":
+		addr, err := parseAddress(value)
+		if err != nil {
+			return nil, err
+		}
+		return common.LeftPadBytes(addr.Bytes(), 32), nil
+
+	case "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("invalid bool %v", value)
+		}
+		if b {
+			return math.PaddedBigBytes(common.Big1, 32), nil
+		}
+		return math.PaddedBigBytes(common.Big0, 32), nil
+
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid string %v", value)
+		}
+		return crypto.Keccak256([]byte(s)), nil
+
+	case "bytes":
+		b, err := parseBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(b), nil
+	}
+	if match := bytesRegexp.FindStringSubmatch(typ); match != nil {
+		size, _ := strconv.Atoi(match[1])
+		b, err := parseBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != size {
+			return nil, fmt.Errorf("invalid length %d for %s", len(b), typ)
+		}
+		return common.RightPadBytes(b, 32), nil
+	}
+	if match := intRegexp.FindStringSubmatch(typ); match != nil {
+		n, err := parseInteger(typ, match[1] == "u", match[2], value)
+		if err != nil {
+			return nil, err
+		}
+		return math.PaddedBigBytes(math.U256(new(big.Int).Set(n)), 32), nil
+	}
+	return nil, fmt.Errorf("unknown type %q", typ)
+}
+
+// formatPrimitive returns the human readable rendering of a primitive value.
+func formatPrimitive(typ string, value interface{}) (interface{}, error) {
+	switch typ {
+	case "address":
+		addr, err := parseAddress(value)
+		if err != nil {
+			return nil, err
+		}
+		return addr.Hex(), nil
+
+	case "bool", "string":
+		return value, nil
+	}
+	if typ == "bytes" || bytesRegexp.MatchString(typ) {
+		b, err := parseBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return hexutil.Encode(b), nil
+	}
+	if match := intRegexp.FindStringSubmatch(typ); match != nil {
+		n, err := parseInteger(typ, match[1] == "u", match[2], value)
+		if err != nil {
+			return nil, err
+		}
+		return n.String(), nil
+	}
+	return nil, fmt.Errorf("unknown type %q", typ)
+}
+
+func parseAddress(value interface{}) (common.Address, error) {
+	s, ok := value.(string)
+	if !ok || !common.IsHexAddress(s) {
+		return common.Address{}, fmt.Errorf("invalid address %v", value)
+	}
+	return common.HexToAddress(s), nil
+}
+
+func parseBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case hexutil.Bytes:
+		return v, nil
+	case string:
+		b, err := hexutil.Decode(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bytes %q: %v", v, err)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("invalid bytes %v", value)
+}
+
+// parseInteger converts a JSON number or a decimal or hex string into an integer,
+// checking that it fits into the given integer type.
+func parseInteger(typ string, unsigned bool, size string, value interface{}) (*big.Int, error) {
+	var n *big.Int
+	switch v := value.(type) {
+	case *big.Int:
+		n = v
+	case float64:
+		// Floats are only exact up to 2^53, reject anything fractional or larger
+		if v != float64(int64(v)) || v > maxSafeInteger || v < -maxSafeInteger {
+			return nil, fmt.Errorf("invalid integer %v", v)
+		}
+		n = big.NewInt(int64(v))
+	case json.Number:
+		parsed, ok := new(big.Int).SetString(string(v), 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %v", v)
+		}
+		n = parsed
+	case string:
+		parsed, ok := math.ParseBig256(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q", v)
+		}
+		n = parsed
+	default:
+		return nil, fmt.Errorf("invalid integer %v", value)
+	}
+	bits, _ := strconv.Atoi(size)
+	if unsigned {
+		if n.Sign() < 0 || n.BitLen() > bits {
+			return nil, fmt.Errorf("integer %v overflows %s", n, typ)
+		}
+	} else {
+		limit := new(big.Int).Lsh(common.Big1, uint(bits-1))
+		if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, fmt.Errorf("integer %v overflows %s", n, typ)
+		}
+	}
+	return n, nil
+}
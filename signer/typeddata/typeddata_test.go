@@ -0,0 +1,243 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package typeddata
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// mailJSON is the example message of the EIP-712 specification.
+const mailJSON = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {
+			"name": "Cow",
+			"wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"
+		},
+		"to": {
+			"name": "Bob",
+			"wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"
+		},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+func loadMail(t *testing.T) *TypedData {
+	var typedData TypedData
+	if err := json.Unmarshal([]byte(mailJSON), &typedData); err != nil {
+		t.Fatalf("failed to unmarshal typed data: %v", err)
+	}
+	return &typedData
+}
+
+func TestEncodeType(t *testing.T) {
+	typedData := loadMail(t)
+
+	want := "Mail(Person from,Person to,string contents)Person(string name,address wallet)"
+	if have := string(typedData.EncodeType("Mail")); have != want {
+		t.Errorf("encoded type mismatch: have %s, want %s", have, want)
+	}
+	wantHash := "0xa0cedeb2dc280ba39b857546d74f5549c3a1d7bdc2dd96bf881f76108e23dac2"
+	if have := hexutil.Encode(typedData.TypeHash("Mail")); have != wantHash {
+		t.Errorf("type hash mismatch: have %s, want %s", have, wantHash)
+	}
+}
+
+func TestSignatureHash(t *testing.T) {
+	typedData := loadMail(t)
+
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		t.Fatalf("failed to hash domain: %v", err)
+	}
+	if have, want := hexutil.Encode(domainSeparator), "0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f"; have != want {
+		t.Errorf("domain separator mismatch: have %s, want %s", have, want)
+	}
+	messageHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		t.Fatalf("failed to hash message: %v", err)
+	}
+	if have, want := hexutil.Encode(messageHash), "0xc52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e"; have != want {
+		t.Errorf("message hash mismatch: have %s, want %s", have, want)
+	}
+	sighash, err := typedData.SignatureHash()
+	if err != nil {
+		t.Fatalf("failed to calculate signature hash: %v", err)
+	}
+	if have, want := hexutil.Encode(sighash), "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"; have != want {
+		t.Errorf("signature hash mismatch: have %s, want %s", have, want)
+	}
+	// Sign with the key of the specification and compare the signature
+	key, _ := crypto.ToECDSA(crypto.Keccak256([]byte("cow")))
+	if addr := crypto.PubkeyToAddress(key.PublicKey); addr != common.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826") {
+		t.Fatalf("signer address mismatch: have %x", addr)
+	}
+	sig, err := crypto.Sign(sighash, key)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if have, want := hexutil.Encode(sig[:32]), "0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d"; have != want {
+		t.Errorf("signature R mismatch: have %s, want %s", have, want)
+	}
+	if have, want := hexutil.Encode(sig[32:64]), "0x07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b91562"; have != want {
+		t.Errorf("signature S mismatch: have %s, want %s", have, want)
+	}
+	if have, want := sig[64]+27, byte(28); have != want {
+		t.Errorf("signature V mismatch: have %d, want %d", have, want)
+	}
+}
+
+func TestInvalidTypedData(t *testing.T) {
+	tests := []struct {
+		modify func(*TypedData)
+		err    string
+	}{
+		{func(td *TypedData) { td.PrimaryType = "Letter" }, "primary type"},
+		{func(td *TypedData) { delete(td.Types, "EIP712Domain") }, "missing EIP712Domain"},
+		{func(td *TypedData) { td.Types["Person"][1].Type = "address1" }, "invalid type"},
+		{func(td *TypedData) { td.Types["Person"][1].Type = "uint7" }, "invalid type"},
+		{func(td *TypedData) { delete(td.Message, "contents") }, "missing field Mail.contents"},
+		{func(td *TypedData) { td.Message["extra"] = "field" }, "more fields than defined"},
+		{func(td *TypedData) { td.Message["contents"] = 5.0 }, "invalid string"},
+		{func(td *TypedData) { td.Message["from"].(map[string]interface{})["wallet"] = "0x01" }, "invalid address"},
+		{func(td *TypedData) { td.Domain.VerifyingContract = "" }, "missing field EIP712Domain.verifyingContract"},
+	}
+	for i, test := range tests {
+		typedData := loadMail(t)
+		test.modify(typedData)
+
+		if _, err := typedData.SignatureHash(); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %q", i, err, test.err)
+		}
+	}
+}
+
+func TestEncodePrimitives(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value interface{}
+		want  string
+		fail  bool
+	}{
+		{typ: "uint8", value: 255.0, want: "0x00000000000000000000000000000000000000000000000000000000000000ff"},
+		{typ: "uint8", value: 256.0, fail: true},
+		{typ: "uint256", value: "0x10", want: "0x0000000000000000000000000000000000000000000000000000000000000010"},
+		{typ: "int8", value: -1.0, want: "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"},
+		{typ: "int8", value: -129.0, fail: true},
+		{typ: "uint64", value: 1.5, fail: true},
+		{typ: "uint64", value: float64(1 << 60), fail: true},
+		{typ: "uint64", value: json.Number("1152921504606846977"), want: "0x0000000000000000000000000000000000000000000000001000000000000001"},
+		{typ: "uint64", value: json.Number("1.5"), fail: true},
+		{typ: "bool", value: true, want: "0x0000000000000000000000000000000000000000000000000000000000000001"},
+		{typ: "bytes2", value: "0xabcd", want: "0xabcd000000000000000000000000000000000000000000000000000000000000"},
+		{typ: "bytes2", value: "0xab", fail: true},
+		{typ: "bytes", value: "0x", want: hexutil.Encode(crypto.Keccak256(nil))},
+	}
+	for i, test := range tests {
+		encoded, err := encodePrimitive(test.typ, test.value)
+		if test.fail {
+			if err == nil {
+				t.Errorf("test %d: expected failure, got %x", i, encoded)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if have := hexutil.Encode(encoded); have != test.want {
+			t.Errorf("test %d: encoding mismatch: have %s, want %s", i, have, test.want)
+		}
+	}
+}
+
+func TestDecodeLargeIntegers(t *testing.T) {
+	input := `{
+		"types": {
+			"EIP712Domain": [{"name": "name", "type": "string"}],
+			"Value": [{"name": "amount", "type": "uint256"}]
+		},
+		"primaryType": "Value",
+		"domain": {"name": "Test"},
+		"message": {"amount": 1152921504606846977}
+	}`
+	var typedData TypedData
+	if err := json.Unmarshal([]byte(input), &typedData); err != nil {
+		t.Fatalf("failed to decode typed data: %v", err)
+	}
+	encoded, err := typedData.encodeData("Value", typedData.Message, 1)
+	if err != nil {
+		t.Fatalf("failed to encode typed data: %v", err)
+	}
+	if have, want := hexutil.Encode(encoded[32:]), "0x0000000000000000000000000000000000000000000000001000000000000001"; have != want {
+		t.Errorf("amount mismatch: have %s, want %s", have, want)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	typedData := loadMail(t)
+
+	nvts, err := typedData.Format()
+	if err != nil {
+		t.Fatalf("failed to format typed data: %v", err)
+	}
+	var output string
+	for _, nvt := range nvts {
+		output += nvt.Pprint(0)
+	}
+	for _, want := range []string{
+		"EIP712Domain [domain]: \n",
+		"  chainId [uint256]: 1\n",
+		"Mail [primary type]: \n",
+		"  from [Person]: \n",
+		"    wallet [address]: 0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826\n",
+		"  contents [string]: Hello, Bob!\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("formatted output missing %q:\n%s", want, output)
+		}
+	}
+}